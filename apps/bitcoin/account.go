@@ -1,9 +1,11 @@
 package bitcoin
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
@@ -61,18 +63,31 @@ func ExtractPkScriptAddr(pkScript []byte, chain byte) (string, error) {
 }
 
 func EncodeAddress(script []byte, chain byte) (string, error) {
-	typ := checkScriptType(script)
+	typ := checkScriptType(script, chain)
 	switch typ {
-	case InputTypeP2WSHMultisigHolderSigner:
-		msh := sha256.Sum256(script)
-		mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	case InputTypeP2WSHMultisigHolderSigner, InputTypeP2SHMultisigHolderSigner:
+		return encodeScriptAddress(script, chain)
+	default:
+		panic(typ)
+	}
+}
+
+// encodeScriptAddress returns the P2WSH address of the safe script, or the
+// P2SH address for the chains without SegWit
+func encodeScriptAddress(script []byte, chain byte) (string, error) {
+	if IsLegacyChain(chain) {
+		msh, err := btcutil.NewAddressScriptHash(script, NetConfig(chain))
 		if err != nil {
 			return "", err
 		}
-		return mwsh.EncodeAddress(), nil
-	default:
-		panic(typ)
+		return msh.EncodeAddress(), nil
+	}
+	msh := sha256.Sum256(script)
+	mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	if err != nil {
+		return "", err
 	}
+	return mwsh.EncodeAddress(), nil
 }
 
 func VerifyHolderKey(public string) error {
@@ -112,32 +127,63 @@ func VerifySignatureDER(public string, msg, sig []byte) error {
 // OP_ENDIF
 // OP_ADD 2 OP_EQUAL
 func BuildWitnessScriptAccount(holder, signer, observer string, lock time.Duration, chain byte) (*WitnessScriptAccount, error) {
+	return BuildWitnessScriptAccountWithHolders([]string{holder}, 1, signer, observer, lock, chain)
+}
+
+// BuildWitnessScriptAccountWithHolders replaces the holder key with a nested
+// multisig of the holder keys if there are more than one holder keys
+//
+// The same script is the P2SH redeem script for the chains without SegWit,
+// e.g. Dogecoin, then it must fit in a single push of 520 bytes, and the
+// timelock in blocks must not exceed the 16 bits relative lock time.
+//
+// thresh(2,multi(M,HOLDER1,...,HOLDERN),s:pk(SIGNER),sj:and_v(v:pk(OBSERVER),n:older(12960)))
+//
+// M <HOLDER1> ... <HOLDERN> N OP_CHECKMULTISIG OP_SWAP <SIGNER> OP_CHECKSIG
+// OP_ADD OP_SWAP OP_SIZE ...
+func BuildWitnessScriptAccountWithHolders(holders []string, threshold int, signer, observer string, lock time.Duration, chain byte) (*WitnessScriptAccount, error) {
+	if len(holders) == 0 || threshold < 1 || threshold > len(holders) {
+		return nil, fmt.Errorf("invalid holders %d/%d", threshold, len(holders))
+	}
 	var pubKeys []*btcutil.AddressPubKey
-	for _, public := range []string{holder, signer, observer} {
+	for _, public := range append(slices.Clone(holders), signer, observer) {
 		pub, err := parseBitcoinCompressedPublicKey(public)
 		if err != nil {
 			return nil, fmt.Errorf("parseBitcoinCompressedPublicKey(%s) => %v", public, err)
 		}
 		pubKeys = append(pubKeys, pub)
 	}
+	holderKeys, pubKeys := pubKeys[:len(holders)], pubKeys[len(holders):]
 
 	if lock < TimeLockMinimum || lock > TimeLockMaximum {
 		return nil, fmt.Errorf("time lock out of range %s", lock.String())
 	}
+	if IsLegacyChain(chain) && lock/BlockDuration(chain) >= 0xffff {
+		return nil, fmt.Errorf("time lock out of range %s", lock.String())
+	}
 	sequence := ParseSequence(lock, chain)
 
 	builder := txscript.NewScriptBuilder()
-	builder.AddData(pubKeys[0].ScriptAddress())
-	builder.AddOp(txscript.OP_CHECKSIG)
+	if len(holderKeys) == 1 {
+		builder.AddData(holderKeys[0].ScriptAddress())
+		builder.AddOp(txscript.OP_CHECKSIG)
+	} else {
+		builder.AddInt64(int64(threshold))
+		for _, pub := range holderKeys {
+			builder.AddData(pub.ScriptAddress())
+		}
+		builder.AddInt64(int64(len(holderKeys)))
+		builder.AddOp(txscript.OP_CHECKMULTISIG)
+	}
 	builder.AddOp(txscript.OP_SWAP)
-	builder.AddData(pubKeys[1].ScriptAddress())
+	builder.AddData(pubKeys[0].ScriptAddress())
 	builder.AddOp(txscript.OP_CHECKSIG)
 	builder.AddOp(txscript.OP_ADD)
 	builder.AddOp(txscript.OP_SWAP)
 	builder.AddOp(txscript.OP_SIZE)
 	builder.AddOp(txscript.OP_0NOTEQUAL)
 	builder.AddOp(txscript.OP_IF)
-	builder.AddData(pubKeys[2].ScriptAddress())
+	builder.AddData(pubKeys[1].ScriptAddress())
 	builder.AddOp(txscript.OP_CHECKSIGVERIFY)
 	builder.AddInt64(sequence)
 	builder.AddOp(txscript.OP_CHECKSEQUENCEVERIFY)
@@ -151,21 +197,75 @@ func BuildWitnessScriptAccount(holder, signer, observer string, lock time.Durati
 	if err != nil {
 		return nil, fmt.Errorf("build.Script() => %v", err)
 	}
-	msh := sha256.Sum256(script)
-	mwsh, err := btcutil.NewAddressWitnessScriptHash(msh[:], NetConfig(chain))
+	if IsLegacyChain(chain) && len(script) > txscript.MaxScriptElementSize {
+		return nil, fmt.Errorf("redeem script too large %d", len(script))
+	}
+	addr, err := encodeScriptAddress(script, chain)
 	if err != nil {
-		return nil, fmt.Errorf("btcutil.NewAddressWitnessScriptHash(%x) => %v", msh[:], err)
+		return nil, fmt.Errorf("encodeScriptAddress(%x) => %v", script, err)
 	}
 
 	return &WitnessScriptAccount{
 		Sequence: uint32(sequence),
 		Script:   script,
-		Address:  mwsh.EncodeAddress(),
+		Address:  addr,
 	}, nil
 }
 
-func CheckMultisigHolderSignerScript(script []byte) bool {
-	return checkScriptType(script) == InputTypeP2WSHMultisigHolderSigner
+// CheckMultisigHolderSignerScript returns true when the script matches the
+// holder and signer multisig template of the safe, the P2WSH witness script,
+// or the same script as the P2SH redeem script on the legacy chains
+func CheckMultisigHolderSignerScript(script []byte, chain byte) bool {
+	if IsLegacyChain(chain) && len(script) > txscript.MaxScriptElementSize {
+		return false
+	}
+	var ops []byte
+	var pushes [][]byte
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		ops = append(ops, tokenizer.Opcode())
+		pushes = append(pushes, tokenizer.Data())
+	}
+	if tokenizer.Err() != nil || len(ops) < 19 {
+		return false
+	}
+
+	key := func(i int) bool {
+		return ops[i] == txscript.OP_DATA_33 && len(pushes[i]) == 33
+	}
+	var i int
+	switch {
+	case key(0) && ops[1] == txscript.OP_CHECKSIG:
+		i = 2
+	case txscript.IsSmallInt(ops[0]):
+		m := txscript.AsSmallInt(ops[0])
+		for i = 1; i < len(ops) && key(i); i++ {
+		}
+		n := i - 1
+		if i+1 >= len(ops) || !txscript.IsSmallInt(ops[i]) || txscript.AsSmallInt(ops[i]) != n {
+			return false
+		}
+		if m < 1 || m > n || ops[i+1] != txscript.OP_CHECKMULTISIG {
+			return false
+		}
+		i = i + 2
+	default:
+		return false
+	}
+
+	if len(ops)-i != 17 || !key(i+1) || !key(i+8) {
+		return false
+	}
+	if len(pushes[i+10]) == 0 && !txscript.IsSmallInt(ops[i+10]) {
+		return false
+	}
+	template := []byte{
+		txscript.OP_SWAP, txscript.OP_DATA_33, txscript.OP_CHECKSIG, txscript.OP_ADD,
+		txscript.OP_SWAP, txscript.OP_SIZE, txscript.OP_0NOTEQUAL, txscript.OP_IF,
+		txscript.OP_DATA_33, txscript.OP_CHECKSIGVERIFY, ops[i+10], txscript.OP_CHECKSEQUENCEVERIFY,
+		txscript.OP_0NOTEQUAL, txscript.OP_ENDIF, txscript.OP_ADD, txscript.OP_2, txscript.OP_EQUAL,
+	}
+	return bytes.Equal(ops[i:], template)
 }
 
 func parseBitcoinCompressedPublicKey(public string) (*btcutil.AddressPubKey, error) {
@@ -182,6 +282,8 @@ func ValueDust(chain byte) int64 {
 		return 1000
 	case ChainLitecoin:
 		return 10000
+	case ChainDogecoin:
+		return 1000000
 	default:
 		panic(chain)
 	}
@@ -191,48 +293,129 @@ func protocolVersion(chain byte) uint32 {
 	switch chain {
 	case ChainBitcoin:
 		return wire.ProtocolVersion
-	case ChainLitecoin:
+	case ChainLitecoin, ChainDogecoin:
 		return 70015
 	default:
 		panic(chain)
 	}
 }
 
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+	NetworkSignet  = "signet"
+)
+
+var network = NetworkMainnet
+
 func init() {
-	ltcParams := NetConfig(ChainLitecoin)
-	err := chaincfg.Register(ltcParams)
-	if err != nil {
-		panic(err)
+	for _, params := range []*chaincfg.Params{litecoinMainNetParams, litecoinTestNetParams, dogecoinMainNetParams, dogecoinTestNetParams} {
+		err := chaincfg.Register(params)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// SetNetwork switches the params of all bitcoin like chains, the signet
+// network uses the bitcoin signet and the litecoin and dogecoin testnets. It must be
+// called before any safe account is parsed or built.
+func SetNetwork(name string) {
+	switch name {
+	case NetworkMainnet, NetworkTestnet, NetworkSignet:
+		network = name
+	default:
+		panic(name)
 	}
 }
 
+func Network() string {
+	return network
+}
+
 func NetConfig(chain byte) *chaincfg.Params {
 	switch chain {
 	case ChainBitcoin:
+		switch network {
+		case NetworkTestnet:
+			return &chaincfg.TestNet3Params
+		case NetworkSignet:
+			return &chaincfg.SigNetParams
+		}
 		return &chaincfg.MainNetParams
 	case ChainLitecoin:
-		return &chaincfg.Params{
-			Net:             0xdbb6c0fb,
-			Bech32HRPSegwit: "ltc",
-
-			PubKeyHashAddrID:        0x30,
-			ScriptHashAddrID:        0x32,
-			WitnessPubKeyHashAddrID: 0x06,
-			WitnessScriptHashAddrID: 0x0A,
-
-			HDPublicKeyID:  [4]byte{0x01, 0x9d, 0xa4, 0x64},
-			HDPrivateKeyID: [4]byte{0x01, 0x9d, 0x9c, 0xfe},
+		if network != NetworkMainnet {
+			return litecoinTestNetParams
+		}
+		return litecoinMainNetParams
+	case ChainDogecoin:
+		if network != NetworkMainnet {
+			return dogecoinTestNetParams
 		}
+		return dogecoinMainNetParams
 	default:
 		panic(chain)
 	}
 }
 
-func checkScriptType(script []byte) int {
-	if len(script) == 33 {
+var litecoinMainNetParams = &chaincfg.Params{
+	Net:             0xdbb6c0fb,
+	Bech32HRPSegwit: "ltc",
+
+	PubKeyHashAddrID:        0x30,
+	ScriptHashAddrID:        0x32,
+	WitnessPubKeyHashAddrID: 0x06,
+	WitnessScriptHashAddrID: 0x0A,
+
+	HDPublicKeyID:  [4]byte{0x01, 0x9d, 0xa4, 0x64},
+	HDPrivateKeyID: [4]byte{0x01, 0x9d, 0x9c, 0xfe},
+}
+
+var litecoinTestNetParams = &chaincfg.Params{
+	Net:             0xf1c8d2fd,
+	Bech32HRPSegwit: "tltc",
+
+	PubKeyHashAddrID:        0x6f,
+	ScriptHashAddrID:        0x3a,
+	WitnessPubKeyHashAddrID: 0x52,
+	WitnessScriptHashAddrID: 0x31,
+
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf},
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94},
+}
+
+// the dogecoin params have no segwit fields, and the testnet HD key ids
+// are the same as bitcoin testnet
+var dogecoinMainNetParams = &chaincfg.Params{
+	Net: 0xc0c0c0c0,
+
+	PubKeyHashAddrID: 0x1e,
+	ScriptHashAddrID: 0x16,
+
+	HDPublicKeyID:  [4]byte{0x02, 0xfa, 0xca, 0xfd},
+	HDPrivateKeyID: [4]byte{0x02, 0xfa, 0xc3, 0x98},
+}
+
+var dogecoinTestNetParams = &chaincfg.Params{
+	Net: 0xdcb7c1fc,
+
+	PubKeyHashAddrID: 0x71,
+	ScriptHashAddrID: 0xc4,
+
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf},
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94},
+}
+
+func checkScriptType(script []byte, chain byte) int {
+	legacy := IsLegacyChain(chain)
+	switch {
+	case len(script) == 33 && legacy:
+		return InputTypeP2PKHAccoutant
+	case len(script) == 33:
 		return InputTypeP2WPKHAccoutant
-	}
-	if len(script) > 100 {
+	case len(script) > 100 && legacy:
+		return InputTypeP2SHMultisigHolderSigner
+	case len(script) > 100:
 		return InputTypeP2WSHMultisigHolderSigner
 	}
 	panic(hex.EncodeToString(script))
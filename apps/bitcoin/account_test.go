@@ -1,17 +1,26 @@
 package bitcoin
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/MixinNetwork/multi-party-sig/pkg/ecdsa"
 	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
+	"github.com/btcsuite/btcd/btcec/v2"
+	becdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	btcpsbt "github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/stretchr/testify/require"
 )
 
@@ -130,3 +139,270 @@ func TestBitcoinSignature(t *testing.T) {
 	require.NotNil(err)
 	require.Nil(sig)
 }
+
+func TestBitcoinScriptDescriptor(t *testing.T) {
+	require := require.New(t)
+
+	checksum, err := DescriptorChecksum("raw(deadbeef)")
+	require.Nil(err)
+	require.Equal("89f8spxm", checksum)
+	checksum, err = DescriptorChecksum("addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)")
+	require.Nil(err)
+	require.Equal("02wpgw69", checksum)
+	_, err = DescriptorChecksum("raw(deadbeef)\n")
+	require.NotNil(err)
+
+	path := []uint32{0, 0}
+	holder, err := ParseDescriptorKey("[9c03cfaf/44'/0'/0']xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w", path)
+	require.Nil(err)
+	signer, err := ParseDescriptorKey("[61315bdf]xpub661MyMwAqRbcGz6ujRJnzrBvWrkz2NdNzYc3ZGBMVPmPBTHomqTiX5RrcTZVYZR2jM75oBU1UFssyMFqHV6GDsreibF2tPMbCcSPnTfqwhM", path)
+	require.Nil(err)
+	observer, err := ParseDescriptorKey("[03d4ea2b]xpub661MyMwAqRbcGxD8XPvZ3fQy7WqAJBvdrEH1kwg1SGDAQprmpGvHsz5rVytTrfFmLTzRiSAMo43R7DhzjR3ucQH5UxBvFB9YDUZVQFiyDKG", path)
+	require.Nil(err)
+	_, err = ParseDescriptorKey("61315bdf]xpub661MyMwAqRbcGz6ujRJnzrBvWrkz2NdNzYc3ZGBMVPmPBTHomqTiX5RrcTZVYZR2jM75oBU1UFssyMFqHV6GDsreibF2tPMbCcSPnTfqwhM", path)
+	require.NotNil(err)
+
+	holderPub, err := holder.Derive()
+	require.Nil(err)
+	signerPub, err := signer.Derive()
+	require.Nil(err)
+	require.Equal("02339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9", signerPub)
+	observerPub, err := observer.Derive()
+	require.Nil(err)
+	require.Equal("0281b901b31b51b93095249db49238299e9292e3c356b302c05b5d5b27dca99d1f", observerPub)
+	wsa, err := BuildWitnessScriptAccount(holderPub, signerPub, observerPub, time.Hour*24*3, ChainBitcoin)
+	require.Nil(err)
+	require.Equal("bc1qrgks3frgprw92rkey7yqs5ge57jddep52es6yn54mudl3kfwvxpsa4r46k", wsa.Address)
+
+	desc, err := BuildWitnessScriptDescriptor(holderPub, signer, observer, wsa.Sequence)
+	require.Nil(err)
+	body, checksum, _ := strings.Cut(desc, "#")
+	require.Equal("wsh(thresh(2,pk("+holderPub+"),s:pk([61315bdf]xpub661MyMwAqRbcGz6ujRJnzrBvWrkz2NdNzYc3ZGBMVPmPBTHomqTiX5RrcTZVYZR2jM75oBU1UFssyMFqHV6GDsreibF2tPMbCcSPnTfqwhM/0/0),sj:and_v(v:pk([03d4ea2b]xpub661MyMwAqRbcGxD8XPvZ3fQy7WqAJBvdrEH1kwg1SGDAQprmpGvHsz5rVytTrfFmLTzRiSAMo43R7DhzjR3ucQH5UxBvFB9YDUZVQFiyDKG/0/0),n:older(432))))", body)
+	require.Len(checksum, 8)
+	_, err = BuildWitnessScriptDescriptor("", signer, observer, wsa.Sequence)
+	require.NotNil(err)
+
+	policy, err := BuildWalletPolicy("Safe Test", holder, signer, observer, wsa.Sequence)
+	require.Nil(err)
+	require.Equal("wsh(thresh(2,pk(@0/**),s:pk(@1/**),sj:and_v(v:pk(@2/**),n:older(432))))", policy.Template)
+	require.Equal("[9c03cfaf/44'/0'/0']xpub6Ci9Nfuo4VkA6gzSdKQK6XYztQ65B52gyTQ4ShB7unGB8tfAqVLS6Nw3v62onLJXEzp8H2UarXRt7nBnAFX7FpwjaSBgo2M7AWp81y4eg1w", policy.Keys[0])
+	signer.Path = []uint32{0, 1}
+	_, err = BuildWalletPolicy("Safe Test", holder, signer, observer, wsa.Sequence)
+	require.NotNil(err)
+	signer.Path = path
+
+	holders := []*DescriptorKey{holder}
+	for i := byte(1); i < 3; i++ {
+		master, err := hdkeychain.NewMaster(sha256.New().Sum([]byte{i}), &chaincfg.MainNetParams)
+		require.Nil(err)
+		xpub, err := master.Neuter()
+		require.Nil(err)
+		dk, err := ParseDescriptorKey(fmt.Sprintf("[%08x]%s", i, xpub.String()), path)
+		require.Nil(err)
+		holders = append(holders, dk)
+	}
+	policy, err = BuildWalletPolicyWithHolders("Safe Test", holders, 2, signer, observer, wsa.Sequence)
+	require.Nil(err)
+	require.Equal("wsh(thresh(2,multi(2,@0/**,@1/**,@2/**),s:pk(@3/**),sj:and_v(v:pk(@4/**),n:older(432))))", policy.Template)
+	require.Len(policy.Keys, 5)
+	require.Equal(holders[1].Info(), policy.Keys[1])
+	require.Equal(signer.Info(), policy.Keys[3])
+	require.Equal(observer.Info(), policy.Keys[4])
+	_, err = BuildWalletPolicyWithHolders("Safe Test", holders, 4, signer, observer, wsa.Sequence)
+	require.NotNil(err)
+	_, err = BuildWalletPolicyWithHolders("Safe Test", nil, 1, signer, observer, wsa.Sequence)
+	require.NotNil(err)
+}
+
+func TestBitcoinHoldersScript(t *testing.T) {
+	require := require.New(t)
+	lock := time.Hour * 24 * 90
+
+	keys := make([]*btcec.PrivateKey, 5)
+	pubs := make([]string, 5)
+	for i := range keys {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("mixin safe holders %d", i)))
+		keys[i], _ = btcec.PrivKeyFromBytes(seed[:])
+		pubs[i] = hex.EncodeToString(keys[i].PubKey().SerializeCompressed())
+	}
+	holders, signer, observer := pubs[:3], pubs[3], pubs[4]
+
+	single, err := BuildWitnessScriptAccountWithHolders(holders[:1], 1, signer, observer, lock, ChainBitcoin)
+	require.Nil(err)
+	old, err := BuildWitnessScriptAccount(holders[0], signer, observer, lock, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(old.Address, single.Address)
+	require.Equal(old.Script, single.Script)
+
+	wsa, err := BuildWitnessScriptAccountWithHolders(holders, 2, signer, observer, lock, ChainBitcoin)
+	require.Nil(err)
+	require.NotEqual(single.Address, wsa.Address)
+	require.Equal(byte(txscript.OP_2), wsa.Script[0])
+	_, err = BuildWitnessScriptAccountWithHolders(holders, 4, signer, observer, lock, ChainBitcoin)
+	require.NotNil(err)
+
+	input := &Input{
+		TransactionHash: "6d0a8b1ee5b1d1d7f8b52bc3d4ff9ff0a2bb2e44d2a9db9f2c35ee53a0e7b58a",
+		Index:           0,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        wsa.Sequence,
+	}
+	outputs := []*Output{{Address: "bc1q7wqpsk0ckquckd7v0e38uqkscjh7v0ncelqpz459hueet5uknamqrlgp2d", Satoshi: 60000}}
+	psbt, err := BuildPartiallySignedTransaction([]*Input{input}, outputs, nil, ChainBitcoin)
+	require.Nil(err)
+
+	sign := func(raw *PartiallySignedTransaction, key *btcec.PrivateKey) *PartiallySignedTransaction {
+		b := raw.Marshal()
+		signed, err := UnmarshalPartiallySignedTransaction(b)
+		require.Nil(err)
+		for idx := range signed.Inputs {
+			sig := becdsa.Sign(key, signed.SigHash(idx)).Serialize()
+			signed.Inputs[idx].PartialSigs = []*btcpsbt.PartialSig{{
+				PubKey:    key.PubKey().SerializeCompressed(),
+				Signature: sig,
+			}}
+		}
+		return signed
+	}
+
+	first := sign(psbt, keys[2])
+	raw := hex.EncodeToString(first.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, holders[2]))
+	require.False(CheckTransactionPartiallySignedByHolders(raw, holders, 2))
+	_, err = first.SignedTransactionWithHolders(holders, 2, signer, observer)
+	require.NotNil(err)
+
+	require.Equal(0, first.MergePartialSignatures(sign(psbt, keys[3]), holders))
+	require.Equal(0, first.MergePartialSignatures(sign(psbt, keys[2]), holders))
+	require.Equal(1, first.MergePartialSignatures(sign(psbt, keys[0]), holders))
+	raw = hex.EncodeToString(first.Marshal())
+	require.True(CheckTransactionPartiallySignedByHolders(raw, holders, 2))
+	_, err = first.SignedTransactionWithHolders(holders, 2, signer, observer)
+	require.NotNil(err)
+
+	require.Equal(1, first.MergePartialSignatures(sign(psbt, keys[3]), []string{signer}))
+	msgTx, err := first.SignedTransactionWithHolders(holders, 2, signer, observer)
+	require.Nil(err)
+
+	pkScript, err := ParseAddress(wsa.Address, ChainBitcoin)
+	require.Nil(err)
+	pof := txscript.NewCannedPrevOutputFetcher(pkScript, input.Satoshi)
+	engine, err := txscript.NewEngine(pkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), input.Satoshi, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+	require.InDelta(mempool.GetTxVirtualSize(btcutil.NewTx(msgTx)), first.EstimateVirtualSize(), 4)
+}
+
+func TestDogecoinHoldersScript(t *testing.T) {
+	require := require.New(t)
+	lock := time.Hour * 24 * 30
+
+	keys := make([]*btcec.PrivateKey, 5)
+	pubs := make([]string, 5)
+	for i := range keys {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("mixin safe holders %d", i)))
+		keys[i], _ = btcec.PrivKeyFromBytes(seed[:])
+		pubs[i] = hex.EncodeToString(keys[i].PubKey().SerializeCompressed())
+	}
+	holders, signer, observer := pubs[:3], pubs[3], pubs[4]
+
+	_, err := BuildWitnessScriptAccountWithHolders(holders, 2, signer, observer, time.Hour*24*90, ChainDogecoin)
+	require.NotNil(err)
+	wsa, err := BuildWitnessScriptAccountWithHolders(holders, 2, signer, observer, lock, ChainDogecoin)
+	require.Nil(err)
+	require.True(strings.HasPrefix(wsa.Address, "A"))
+	btc, err := BuildWitnessScriptAccountWithHolders(holders, 2, signer, observer, lock, ChainBitcoin)
+	require.Nil(err)
+	require.NotEqual(btc.Address, wsa.Address)
+	pkScript, err := ParseAddress(wsa.Address, ChainDogecoin)
+	require.Nil(err)
+	require.Equal(txscript.ScriptHashTy, txscript.GetScriptClass(pkScript))
+	_, err = ParseAddress(btc.Address, ChainDogecoin)
+	require.NotNil(err)
+
+	input := &Input{
+		TransactionHash: "6d0a8b1ee5b1d1d7f8b52bc3d4ff9ff0a2bb2e44d2a9db9f2c35ee53a0e7b58a",
+		Index:           0,
+		Satoshi:         1000000000,
+		Script:          wsa.Script,
+		Sequence:        wsa.Sequence,
+	}
+	outputs := []*Output{{Address: wsa.Address, Satoshi: 600000000}}
+	psbt, err := BuildPartiallySignedTransaction([]*Input{input}, outputs, nil, ChainDogecoin)
+	require.Nil(err)
+	require.Equal(wsa.Script, psbt.Inputs[0].RedeemScript)
+	require.Len(psbt.Inputs[0].WitnessScript, 0)
+
+	sign := func(raw *PartiallySignedTransaction, key *btcec.PrivateKey) *PartiallySignedTransaction {
+		signed, err := UnmarshalPartiallySignedTransaction(raw.Marshal())
+		require.Nil(err)
+		for idx := range signed.Inputs {
+			sig := becdsa.Sign(key, signed.SigHash(idx)).Serialize()
+			signed.Inputs[idx].PartialSigs = []*btcpsbt.PartialSig{{
+				PubKey:    key.PubKey().SerializeCompressed(),
+				Signature: sig,
+			}}
+		}
+		return signed
+	}
+
+	first := sign(psbt, keys[0])
+	require.Equal(1, first.MergePartialSignatures(sign(psbt, keys[1]), holders))
+	require.Equal(1, first.MergePartialSignatures(sign(psbt, keys[3]), []string{signer}))
+	msgTx, err := first.SignedTransactionWithHolders(holders, 2, signer, observer)
+	require.Nil(err)
+	require.False(msgTx.HasWitness())
+	require.NotEmpty(msgTx.TxIn[0].SignatureScript)
+
+	pof := txscript.NewCannedPrevOutputFetcher(pkScript, input.Satoshi)
+	engine, err := txscript.NewEngine(pkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), input.Satoshi, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+	require.InDelta(mempool.GetTxVirtualSize(btcutil.NewTx(msgTx)), first.EstimateVirtualSize(), 8)
+
+	require.True(CheckMultisigHolderSignerScript(wsa.Script, ChainDogecoin))
+	require.True(CheckMultisigHolderSignerScript(btc.Script, ChainBitcoin))
+	require.False(CheckMultisigHolderSignerScript(wsa.Script[:len(wsa.Script)-1], ChainDogecoin))
+	require.False(CheckMultisigHolderSignerScript(keys[0].PubKey().SerializeCompressed(), ChainDogecoin))
+	require.False(CheckMultisigHolderSignerScript(append(wsa.Script, txscript.OP_NOP), ChainDogecoin))
+	require.False(CheckMultisigHolderSignerScript(make([]byte, 128), ChainBitcoin))
+	single, err := BuildWitnessScriptAccountWithHolders(holders[:1], 1, signer, observer, lock, ChainDogecoin)
+	require.Nil(err)
+	require.True(CheckMultisigHolderSignerScript(single.Script, ChainDogecoin))
+
+	// the legacy scriptSig is part of the transaction id, so the id of
+	// the broadcast transaction can be malleated with a non-minimal push,
+	// while the keeper and observer still match the unsigned hash
+	builder := txscript.NewScriptBuilder()
+	tokenizer := txscript.MakeScriptTokenizer(0, msgTx.TxIn[0].SignatureScript)
+	for tokenizer.Next() {
+		data := tokenizer.Data()
+		if len(data) == 0 || len(data) > 75 {
+			builder.AddData(data)
+			continue
+		}
+		builder.AddOps([]byte{txscript.OP_PUSHDATA1, byte(len(data))})
+		builder.AddOps(data)
+	}
+	require.Nil(tokenizer.Err())
+	malleated := msgTx.Copy()
+	malleated.TxIn[0].SignatureScript, err = builder.Script()
+	require.Nil(err)
+	require.NotEqual(msgTx.TxIn[0].SignatureScript, malleated.TxIn[0].SignatureScript)
+	flags := txscript.ScriptBip16 | txscript.ScriptVerifyDERSignatures | txscript.ScriptVerifyCheckSequenceVerify
+	engine, err = txscript.NewEngine(pkScript, malleated, 0, flags, nil, txscript.NewTxSigHashes(malleated, pof), input.Satoshi, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+
+	require.NotEqual(msgTx.TxHash(), malleated.TxHash())
+	require.NotEqual(psbt.Hash(), msgTx.TxHash().String())
+	require.Equal(psbt.Hash(), first.Hash())
+	for _, tx := range []*wire.MsgTx{msgTx, malleated} {
+		unsigned := tx.Copy()
+		for _, in := range unsigned.TxIn {
+			in.SignatureScript = nil
+		}
+		require.Equal(psbt.Hash(), unsigned.TxHash().String())
+	}
+}
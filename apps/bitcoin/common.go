@@ -20,22 +20,31 @@ import (
 const (
 	ChainBitcoin  = 1
 	ChainLitecoin = 5
+	ChainDogecoin = 7
 
 	ValuePrecision = 8
 	ValueSatoshi   = 100000000
 
 	MaxUnspentUtxo = 512
 
+	ConsolidationUnspentUtxo = MaxUnspentUtxo / 4
+	MaxConsolidationInputs   = 256
+
 	TimeLockMinimum = time.Hour * 1
 	TimeLockMaximum = time.Hour * 24 * 365
 
 	ScriptPubKeyTypeWitnessKeyHash    = "witness_v0_keyhash"
 	ScriptPubKeyTypeWitnessScriptHash = "witness_v0_scripthash"
+	ScriptPubKeyTypePubKeyHash        = "pubkeyhash"
+	ScriptPubKeyTypeScriptHash        = "scripthash"
 	SigHashType                       = txscript.SigHashAll | txscript.SigHashAnyOneCanPay
 
 	InputTypeP2WPKHAccoutant             = 1
 	InputTypeP2WSHMultisigHolderSigner   = 2
 	InputTypeP2WSHMultisigObserverSigner = 3
+	InputTypeP2PKHAccoutant              = 4
+	InputTypeP2SHMultisigHolderSigner    = 5
+	InputTypeP2SHMultisigObserverSigner  = 6
 
 	MaxTransactionSequence = 0xffffffff
 	MaxStandardTxWeight    = 300000
@@ -58,7 +67,7 @@ func ParseSatoshi(amount string) int64 {
 
 func ParseAddress(addr string, chain byte) ([]byte, error) {
 	switch chain {
-	case ChainBitcoin, ChainLitecoin:
+	case ChainBitcoin, ChainLitecoin, ChainDogecoin:
 	default:
 		return nil, fmt.Errorf("ParseAddress(%s, %d)", addr, chain)
 	}
@@ -76,19 +85,23 @@ func ParseAddress(addr string, chain byte) ([]byte, error) {
 	return script, nil
 }
 
-func ParseSequence(lock time.Duration, chain byte) int64 {
-	if lock < TimeLockMinimum || lock > TimeLockMaximum {
-		panic(lock.String())
-	}
-	blockDuration := 10 * time.Minute
+func BlockDuration(chain byte) time.Duration {
 	switch chain {
-	case ChainBitcoin:
 	case ChainLitecoin:
-		blockDuration = 150 * time.Second
+		return 150 * time.Second
+	case ChainDogecoin:
+		return time.Minute
 	default:
+		return 10 * time.Minute
+	}
+}
+
+func ParseSequence(lock time.Duration, chain byte) int64 {
+	if lock < TimeLockMinimum || lock > TimeLockMaximum {
+		panic(lock.String())
 	}
 	// FIXME check litecoin timelock consensus as this may exceed 0xffff
-	lock = lock / blockDuration
+	lock = lock / BlockDuration(chain)
 	if lock >= 0xffff {
 		lock = 0xffff
 	}
@@ -101,11 +114,32 @@ func CheckFeeRange(fvb int64, chain byte) bool {
 		return fvb >= 2 && fvb <= 1000
 	case ChainLitecoin:
 		return fvb >= 1 && fvb <= 20
+	case ChainDogecoin:
+		return fvb >= 100 && fvb <= 100000
 	default:
 		panic(chain)
 	}
 }
 
+// IsSafeScriptPubKeyType returns true for the script types could be the safe
+// or accountant outputs, Dogecoin has no SegWit so only the legacy ones
+func IsSafeScriptPubKeyType(typ string, chain byte) bool {
+	switch chain {
+	case ChainBitcoin, ChainLitecoin:
+		return typ == ScriptPubKeyTypeWitnessScriptHash || typ == ScriptPubKeyTypeWitnessKeyHash
+	case ChainDogecoin:
+		return typ == ScriptPubKeyTypeScriptHash || typ == ScriptPubKeyTypePubKeyHash
+	default:
+		panic(chain)
+	}
+}
+
+// IsLegacyChain returns true for the chains without SegWit, whose safe is a
+// P2SH script and the accountant is a P2PKH address
+func IsLegacyChain(chain byte) bool {
+	return chain == ChainDogecoin
+}
+
 func CheckFinalization(num uint64, coinbase bool) bool {
 	if num >= uint64(chaincfg.MainNetParams.CoinbaseMaturity) {
 		return true
@@ -155,6 +189,8 @@ func HashMessageForSignature(msg string, chain byte) []byte {
 	case ChainBitcoin:
 	case ChainLitecoin:
 		prefix = "Litecoin Signed Message:\n"
+	case ChainDogecoin:
+		prefix = "Dogecoin Signed Message:\n"
 	default:
 		panic(chain)
 	}
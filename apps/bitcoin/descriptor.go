@@ -0,0 +1,204 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// DescriptorKey is an extended public key with its origin, i.e. the master
+// fingerprint and optional derivation path, and the safe key is derived from
+// the extended public key with the path
+type DescriptorKey struct {
+	Origin string
+	XPub   string
+	Path   []uint32
+}
+
+// WalletPolicy is the BIP388 wallet policy of a safe, the holder, signer
+// and observer are the @0, @1 and @2 key placeholders in the template, and
+// multiple holders take the first placeholders before the signer and observer
+type WalletPolicy struct {
+	Name     string   `json:"name"`
+	Template string   `json:"template"`
+	Keys     []string `json:"keys"`
+}
+
+func ParseDescriptorKey(key string, path []uint32) (*DescriptorKey, error) {
+	origin, xpub, found := strings.Cut(strings.TrimPrefix(key, "["), "]")
+	if !found || key[0] != '[' || len(origin) < 8 {
+		return nil, fmt.Errorf("invalid descriptor key %s", key)
+	}
+	_, err := hex.DecodeString(origin[:8])
+	if err != nil || (len(origin) > 8 && origin[8] != '/') {
+		return nil, fmt.Errorf("invalid descriptor key origin %s", origin)
+	}
+	_, err = hdkeychain.NewKeyFromString(xpub)
+	if err != nil {
+		return nil, fmt.Errorf("hdkeychain.NewKeyFromString(%s) => %v", xpub, err)
+	}
+	return &DescriptorKey{Origin: origin, XPub: xpub, Path: path}, nil
+}
+
+func (k *DescriptorKey) Info() string {
+	return fmt.Sprintf("[%s]%s", k.Origin, k.XPub)
+}
+
+func (k *DescriptorKey) String() string {
+	var b strings.Builder
+	b.WriteString(k.Info())
+	for _, i := range k.Path {
+		fmt.Fprintf(&b, "/%d", i)
+	}
+	return b.String()
+}
+
+// Derive returns the compressed public key used in the witness script
+func (k *DescriptorKey) Derive() (string, error) {
+	extPub, err := hdkeychain.NewKeyFromString(k.XPub)
+	if err != nil {
+		return "", err
+	}
+	for _, i := range k.Path {
+		extPub, err = extPub.Derive(i)
+		if err != nil {
+			return "", err
+		}
+	}
+	pub, err := extPub.ECPubKey()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(pub.SerializeCompressed()), nil
+}
+
+// BuildWitnessScriptDescriptor returns the checksummed output descriptor of
+// the safe, which could be imported to Bitcoin Core as a watch-only wallet
+//
+// wsh(thresh(2,pk(HOLDER),s:pk(SIGNER),sj:and_v(v:pk(OBSERVER),n:older(N))))
+func BuildWitnessScriptDescriptor(holder string, signer, observer *DescriptorKey, sequence uint32) (string, error) {
+	return BuildWitnessScriptDescriptorWithHolders([]string{holder}, 1, signer, observer, sequence)
+}
+
+// BuildWitnessScriptDescriptorWithHolders replaces the holder key with the
+// multi(M,HOLDER1,...,HOLDERN) fragment if there are more than one keys
+func BuildWitnessScriptDescriptorWithHolders(holders []string, threshold int, signer, observer *DescriptorKey, sequence uint32) (string, error) {
+	for _, holder := range holders {
+		_, err := parseBitcoinCompressedPublicKey(holder)
+		if err != nil {
+			return "", fmt.Errorf("parseBitcoinCompressedPublicKey(%s) => %v", holder, err)
+		}
+	}
+	holder := fmt.Sprintf("pk(%s)", holders[0])
+	if len(holders) > 1 {
+		holder = fmt.Sprintf("multi(%d,%s)", threshold, strings.Join(holders, ","))
+	}
+	desc := buildWitnessScriptMiniscript(holder, signer.String(), observer.String(), sequence)
+	checksum, err := DescriptorChecksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// BuildWalletPolicy returns the BIP388 wallet policy for hardware wallets,
+// the policy requires the holder extended public key, and the receive
+// address at index 0 must be the safe address
+func BuildWalletPolicy(name string, holder, signer, observer *DescriptorKey, sequence uint32) (*WalletPolicy, error) {
+	return BuildWalletPolicyWithHolders(name, []*DescriptorKey{holder}, 1, signer, observer, sequence)
+}
+
+// BuildWalletPolicyWithHolders replaces the holder placeholder with the
+// multi(M,@0/**,...) fragment if there are more than one holder keys, which
+// must be in the same order as the holder keys of the witness script
+func BuildWalletPolicyWithHolders(name string, holders []*DescriptorKey, threshold int, signer, observer *DescriptorKey, sequence uint32) (*WalletPolicy, error) {
+	if len(holders) == 0 || threshold < 1 || threshold > len(holders) {
+		return nil, fmt.Errorf("invalid wallet policy holders %d/%d", threshold, len(holders))
+	}
+	keys := append(slices.Clone(holders), signer, observer)
+	infos := make([]string, len(keys))
+	for i, k := range keys {
+		if len(k.Path) != 2 || k.Path[0] != 0 || k.Path[1] != 0 {
+			return nil, fmt.Errorf("invalid wallet policy key path %s", k)
+		}
+		infos[i] = k.Info()
+	}
+	placeholders := make([]string, len(holders))
+	for i := range holders {
+		placeholders[i] = fmt.Sprintf("@%d/**", i)
+	}
+	holder := fmt.Sprintf("pk(%s)", placeholders[0])
+	if len(holders) > 1 {
+		holder = fmt.Sprintf("multi(%d,%s)", threshold, strings.Join(placeholders, ","))
+	}
+	n := len(holders)
+	return &WalletPolicy{
+		Name:     name,
+		Template: buildWitnessScriptMiniscript(holder, fmt.Sprintf("@%d/**", n), fmt.Sprintf("@%d/**", n+1), sequence),
+		Keys:     infos,
+	}, nil
+}
+
+func buildWitnessScriptMiniscript(holder, signer, observer string, sequence uint32) string {
+	return fmt.Sprintf("wsh(thresh(2,%s,s:pk(%s),sj:and_v(v:pk(%s),n:older(%d))))", holder, signer, observer, sequence)
+}
+
+// DescriptorChecksum computes the BIP380 descriptor checksum
+func DescriptorChecksum(desc string) (string, error) {
+	c, cls, count := uint64(1), uint64(0), 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("invalid descriptor character %q", ch)
+		}
+		c = descriptorPolyMod(c, uint64(pos&31))
+		cls = cls*3 + uint64(pos>>5)
+		count += 1
+		if count == 3 {
+			c = descriptorPolyMod(c, cls)
+			cls, count = 0, 0
+		}
+	}
+	if count > 0 {
+		c = descriptorPolyMod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = descriptorPolyMod(c, 0)
+	}
+	c ^= 1
+
+	checksum := make([]byte, 8)
+	for i := range checksum {
+		checksum[i] = descriptorChecksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(checksum), nil
+}
+
+func descriptorPolyMod(c, val uint64) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ val
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
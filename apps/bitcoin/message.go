@@ -0,0 +1,105 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	messageSignatureTag = "BIP0322-signed-message"
+	MessageSigHashType  = txscript.SigHashAll
+	MessageMaximumSize  = 4096
+)
+
+// HashMessageBIP322 returns the tagged hash of the message, which is pushed
+// in the signature script of the virtual to_spend transaction of BIP-322
+func HashMessageBIP322(message []byte) []byte {
+	return chainhash.TaggedHash([]byte(messageSignatureTag), message)[:]
+}
+
+// BuildPartiallySignedMessage builds the virtual to_sign transaction of the
+// BIP-322 simple signature for the P2WSH safe address of the witness script,
+// the holders and signer sign the input as a normal transaction. The legacy
+// P2SH safes are not supported because BIP-322 simple signatures are witness
+// only.
+func BuildPartiallySignedMessage(message, script []byte, chain byte) (*PartiallySignedTransaction, error) {
+	if len(message) > MessageMaximumSize {
+		return nil, fmt.Errorf("message too large %d", len(message))
+	}
+	if IsLegacyChain(chain) {
+		return nil, fmt.Errorf("BuildPartiallySignedMessage(%d) legacy", chain)
+	}
+	msh := sha256.Sum256(script)
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(msh[:]).Script()
+	if err != nil {
+		return nil, err
+	}
+	sigScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).AddData(HashMessageBIP322(message)).Script()
+	if err != nil {
+		return nil, err
+	}
+
+	toSpend := wire.NewMsgTx(0)
+	prevOut := wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex)
+	toSpend.AddTxIn(&wire.TxIn{PreviousOutPoint: *prevOut, SignatureScript: sigScript, Sequence: 0})
+	toSpend.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	toSign := wire.NewMsgTx(0)
+	hash := toSpend.TxHash()
+	toSign.AddTxIn(&wire.TxIn{PreviousOutPoint: *wire.NewOutPoint(&hash, 0), Sequence: 0})
+	toSign.AddTxOut(wire.NewTxOut(0, []byte{txscript.OP_RETURN}))
+
+	pkt, err := psbt.NewFromUnsignedTx(toSign)
+	if err != nil {
+		return nil, err
+	}
+	pin := &pkt.Inputs[0]
+	pin.WitnessUtxo = toSpend.TxOut[0]
+	pin.WitnessScript = script
+	pin.SighashType = MessageSigHashType
+	return &PartiallySignedTransaction{Packet: pkt}, nil
+}
+
+// MessageSigHash returns the signature hash of the to_sign input, which is
+// signed with SIGHASH_ALL instead of the safe transactions sighash type
+func (psbt *PartiallySignedTransaction) MessageSigHash() []byte {
+	tx := psbt.UnsignedTx
+	pin := psbt.Inputs[0]
+	pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+	tsh := txscript.NewTxSigHashes(tx, pof)
+	hash, err := txscript.CalcWitnessSigHash(pin.WitnessScript, tsh, MessageSigHashType, tx, 0, pin.WitnessUtxo.Value)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// SignedMessageProof encodes the witness of the to_sign input as the BIP-322
+// simple signature, the message is always signed by the holders and signer
+// so the observer key is never used even though the input sequence is zero
+func (psbt *PartiallySignedTransaction) SignedMessageProof(holders []string, threshold int, signer, observer string) (string, error) {
+	msgTx, err := psbt.signedTransactionWithHolders(holders, threshold, signer, observer, false)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	witness := msgTx.TxIn[0].Witness
+	err = wire.WriteVarInt(&buf, 0, uint64(len(witness)))
+	if err != nil {
+		return "", err
+	}
+	for _, item := range witness {
+		err = wire.WriteVarBytes(&buf, 0, item)
+		if err != nil {
+			return "", err
+		}
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
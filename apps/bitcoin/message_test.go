@@ -0,0 +1,79 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	becdsa "github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	btcpsbt "github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinMessageProof(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1", hex.EncodeToString(HashMessageBIP322([]byte(""))))
+	require.Equal("f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a", hex.EncodeToString(HashMessageBIP322([]byte("Hello World"))))
+
+	keys := make([]*btcec.PrivateKey, 5)
+	pubs := make([]string, 5)
+	for i := range keys {
+		seed := sha256.Sum256([]byte(fmt.Sprintf("mixin safe holders %d", i)))
+		keys[i], _ = btcec.PrivKeyFromBytes(seed[:])
+		pubs[i] = hex.EncodeToString(keys[i].PubKey().SerializeCompressed())
+	}
+	holders, signer, observer := pubs[:3], pubs[3], pubs[4]
+	wsa, err := BuildWitnessScriptAccountWithHolders(holders, 2, signer, observer, time.Hour*24*90, ChainBitcoin)
+	require.Nil(err)
+
+	message := []byte("mixin safe proof of reserves")
+	_, err = BuildPartiallySignedMessage(message, wsa.Script, ChainDogecoin)
+	require.NotNil(err)
+	psbt, err := BuildPartiallySignedMessage(message, wsa.Script, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(uint32(0), psbt.UnsignedTx.TxIn[0].Sequence)
+	require.True(psbt.IsRecoveryTransaction())
+
+	hash := psbt.MessageSigHash()
+	for _, i := range []int{0, 2, 3} {
+		psbt.Inputs[0].PartialSigs = append(psbt.Inputs[0].PartialSigs, &btcpsbt.PartialSig{
+			PubKey:    keys[i].PubKey().SerializeCompressed(),
+			Signature: becdsa.Sign(keys[i], hash).Serialize(),
+		})
+	}
+	_, err = psbt.SignedMessageProof(holders, 2, pubs[1], observer)
+	require.NotNil(err)
+	proof, err := psbt.SignedMessageProof(holders, 2, signer, observer)
+	require.Nil(err)
+
+	b, err := base64.StdEncoding.DecodeString(proof)
+	require.Nil(err)
+	r := bytes.NewReader(b)
+	count, err := wire.ReadVarInt(r, 0)
+	require.Nil(err)
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness")
+		require.Nil(err)
+	}
+	require.Equal(0, r.Len())
+	require.Equal(wsa.Script, witness[len(witness)-1])
+
+	pkScript, err := ParseAddress(wsa.Address, ChainBitcoin)
+	require.Nil(err)
+	require.Equal(pkScript, psbt.Inputs[0].WitnessUtxo.PkScript)
+	msgTx := psbt.UnsignedTx.Copy()
+	msgTx.TxIn[0].Witness = witness
+	pof := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	engine, err := txscript.NewEngine(pkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), 0, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+}
@@ -52,6 +52,10 @@ type MemPoolTransaction struct {
 		Base float64 `json:"base"`
 	} `json:"fees"`
 	VSize int64 `json:"vsize"`
+
+	// the legacy fields of dogecoin
+	Fee  float64 `json:"fee"`
+	Size int64   `json:"size"`
 }
 
 type RPCBlock struct {
@@ -76,8 +80,7 @@ func RPCGetTransactionOutput(chain byte, rpc, hash string, index int64) (*RPCTra
 		return nil, nil, nil
 	}
 	out := tx.Vout[index]
-	skt := out.ScriptPubKey.Type
-	if skt != ScriptPubKeyTypeWitnessScriptHash && skt != ScriptPubKeyTypeWitnessKeyHash {
+	if !IsSafeScriptPubKeyType(out.ScriptPubKey.Type, chain) {
 		return nil, nil, nil
 	}
 	if out.ScriptPubKey.Address == "" {
@@ -198,16 +201,23 @@ func RPCGetRawMempoolWithTransactions(rpc string) ([]*RPCTransaction, error) {
 
 	var transactions []*RPCTransaction
 	for id, tx := range txMap {
+		fee, size := tx.Fees.Base, tx.VSize
+		if size == 0 {
+			fee, size = tx.Fee, tx.Size
+		}
 		transactions = append(transactions, &RPCTransaction{
 			TxId:  id,
-			Fee:   tx.Fees.Base,
-			VSize: tx.VSize,
+			Fee:   fee,
+			VSize: size,
 		})
 	}
 	return transactions, nil
 }
 
 func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTransactions, error) {
+	if chain == ChainDogecoin {
+		return rpcGetBlockWithTransactionsLegacy(chain, rpc, hash)
+	}
 	res, err := callBitcoinRPCUntilSufficient(rpc, "getblock", []any{hash, 2})
 	if err != nil {
 		return nil, err
@@ -224,6 +234,30 @@ func RPCGetBlockWithTransactions(chain byte, rpc, hash string) (*RPCBlockWithTra
 	return &b, err
 }
 
+// the dogecoin getblock has no verbosity 2, so the transactions are fetched
+// one by one, and they have no fee field
+func rpcGetBlockWithTransactionsLegacy(chain byte, rpc, hash string) (*RPCBlockWithTransactions, error) {
+	res, err := callBitcoinRPCUntilSufficient(rpc, "getblock", []any{hash, true})
+	if err != nil {
+		return nil, err
+	}
+	var b RPCBlock
+	err = json.Unmarshal(res, &b)
+	if err != nil {
+		return nil, err
+	}
+	block := &RPCBlockWithTransactions{Hash: b.Hash, Height: b.Height}
+	for _, id := range b.Tx {
+		tx, err := RPCGetTransaction(chain, rpc, id)
+		if err != nil {
+			return nil, err
+		}
+		tx.BlockHash = hash
+		block.Tx = append(block.Tx, tx)
+	}
+	return block, nil
+}
+
 func RPCGetBlockAverageFeePerBytes(chain byte, rpc string) (*big.Int, error) {
 	height, err := RPCGetBlockHeight(rpc)
 	if err != nil {
@@ -237,9 +271,15 @@ func RPCGetBlockAverageFeePerBytes(chain byte, rpc string) (*big.Int, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(block.Tx) == 0 {
+		return big.NewInt(0), nil
+	}
 	sum := decimal.NewFromInt(0)
 	count := decimal.NewFromInt(int64(len(block.Tx)))
 	for _, tx := range block.Tx {
+		if tx.VSize == 0 {
+			continue
+		}
 		fee := decimal.NewFromFloat(tx.Fee).Mul(decimal.New(1, 8))
 		size := decimal.NewFromInt(tx.VSize)
 		feePerBytes := fee.Div(size)
@@ -274,6 +314,9 @@ func RPCGetMempoolAverageFeePerBytes(rpc string) (*big.Int, error) {
 		sum = sum.Add(f)
 		count += 1
 	}
+	if count == 0 {
+		return big.NewInt(0), nil
+	}
 	avg := sum.Div(decimal.NewFromInt(count))
 	return avg.Ceil().BigInt(), nil
 }
@@ -350,7 +393,7 @@ func RPCSendRawTransaction(rpc, raw string) (string, error) {
 // FIXME wait for litecoin core update to the latest rpc
 func fixLitecoinLegacyScriptPubKeyRPC(chain byte, tx *RPCTransaction) {
 	switch chain {
-	case ChainLitecoin:
+	case ChainLitecoin, ChainDogecoin:
 		for _, o := range tx.Vout {
 			if len(o.ScriptPubKey.LegacyAddresses) != 1 {
 				continue
@@ -0,0 +1,542 @@
+// Package rpctest implements the subset of Bitcoin Core JSON-RPC used by the
+// safe over an in-memory chain, so the keeper and observer flows can run
+// without a bitcoin node.
+package rpctest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	errorCodeInvalidParameter = -8
+	errorCodeNotFound         = -5
+	errorCodeMethodNotFound   = -32601
+	errorCodeVerifyRejected   = -26
+)
+
+type Block struct {
+	Hash     string
+	Height   uint64
+	Previous string
+	Time     time.Time
+	Tx       []string
+}
+
+type entry struct {
+	tx    *wire.MsgTx
+	block string
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Server is a scriptable chain, all transactions stay in the mempool until a
+// block is mined, and the latest blocks could be reorganized at any time
+type Server struct {
+	chain    byte
+	mutex    sync.Mutex
+	blocks   []*Block
+	hashes   map[string]*Block
+	txs      map[string]*entry
+	spent    map[wire.OutPoint]string
+	mempool  []string
+	coinbase uint64
+	forks    uint64
+	server   *httptest.Server
+}
+
+func NewServer(chain byte) *Server {
+	switch chain {
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainDogecoin:
+	default:
+		panic(chain)
+	}
+	s := &Server{
+		chain:  chain,
+		hashes: make(map[string]*Block),
+		txs:    make(map[string]*entry),
+		spent:  make(map[wire.OutPoint]string),
+	}
+	s.mine(time.Now().UTC())
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Fund sends the satoshi to the address with a new coinbase transaction, which
+// is put in the mempool
+func (s *Server) Fund(address string, satoshi int64) (string, error) {
+	addr, err := btcutil.DecodeAddress(address, bitcoin.NetConfig(s.chain))
+	if err != nil {
+		return "", err
+	}
+	script, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.coinbase += 1
+	tx := wire.NewMsgTx(wire.TxVersion)
+	nonce := binary.BigEndian.AppendUint64([]byte("rpctest"), s.coinbase)
+	prev := wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex)
+	tx.AddTxIn(wire.NewTxIn(prev, nonce, nil))
+	tx.AddTxOut(wire.NewTxOut(satoshi, script))
+	id := tx.TxHash().String()
+	s.txs[id] = &entry{tx: tx}
+	s.mempool = append(s.mempool, id)
+	return id, nil
+}
+
+// SendRawTransaction puts the transaction in the mempool, all its inputs must
+// be known and unspent
+func (s *Server) SendRawTransaction(raw string) (string, error) {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", &rpcError{errorCodeInvalidParameter, err.Error()}
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	err = tx.Deserialize(bytes.NewReader(b))
+	if err != nil {
+		return "", &rpcError{errorCodeInvalidParameter, err.Error()}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	id := tx.TxHash().String()
+	if s.txs[id] != nil {
+		return id, nil
+	}
+	for _, in := range tx.TxIn {
+		prev := s.txs[in.PreviousOutPoint.Hash.String()]
+		if prev == nil || int(in.PreviousOutPoint.Index) >= len(prev.tx.TxOut) {
+			return "", &rpcError{errorCodeVerifyRejected, "bad-txns-inputs-missingorspent"}
+		}
+		if s.spent[in.PreviousOutPoint] != "" {
+			return "", &rpcError{errorCodeVerifyRejected, "txn-mempool-conflict"}
+		}
+	}
+	for _, in := range tx.TxIn {
+		s.spent[in.PreviousOutPoint] = id
+	}
+	s.txs[id] = &entry{tx: tx}
+	s.mempool = append(s.mempool, id)
+	return id, nil
+}
+
+// Mine creates n new blocks, the first one includes all the mempool transactions
+func (s *Server) Mine(n int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var hashes []string
+	for range n {
+		b := s.mine(time.Now().UTC())
+		hashes = append(hashes, b.Hash)
+	}
+	return hashes
+}
+
+// Reorg drops the latest depth blocks, all their transactions are put back
+// to the mempool, then n new blocks are mined on the fork point
+func (s *Server) Reorg(depth, n int) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if depth < 1 || depth >= len(s.blocks) {
+		return nil, fmt.Errorf("reorg depth %d of %d blocks", depth, len(s.blocks))
+	}
+	var pool []string
+	for _, b := range s.blocks[len(s.blocks)-depth:] {
+		for _, id := range b.Tx {
+			s.txs[id].block = ""
+		}
+		pool = append(pool, b.Tx...)
+		delete(s.hashes, b.Hash)
+	}
+	s.blocks = s.blocks[:len(s.blocks)-depth]
+	s.mempool = append(pool, s.mempool...)
+	s.forks += 1
+
+	var hashes []string
+	for range n {
+		b := s.mine(time.Now().UTC())
+		hashes = append(hashes, b.Hash)
+	}
+	return hashes, nil
+}
+
+// DropTransaction evicts the transaction and all its descendants from the
+// mempool, e.g. to simulate a double spend by another transaction
+func (s *Server) DropTransaction(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e := s.txs[id]
+	if e == nil || e.block != "" {
+		return fmt.Errorf("transaction %s not in mempool", id)
+	}
+	s.drop(id)
+	return nil
+}
+
+func (s *Server) Mempool() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string{}, s.mempool...)
+}
+
+func (s *Server) Tip() *Block {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := *s.blocks[len(s.blocks)-1]
+	return &b
+}
+
+func (s *Server) mine(timestamp time.Time) *Block {
+	b := &Block{
+		Height: uint64(len(s.blocks)),
+		Time:   timestamp,
+		Tx:     s.mempool,
+	}
+	if b.Height > 0 {
+		b.Previous = s.blocks[b.Height-1].Hash
+	}
+	seed := fmt.Sprintf("%d:%d:%s:%d:%v", s.chain, s.forks, b.Previous, b.Height, b.Tx)
+	hash := crypto.Sha256Hash([]byte(seed))
+	b.Hash = hex.EncodeToString(hash[:])
+	for _, id := range b.Tx {
+		s.txs[id].block = b.Hash
+	}
+	s.mempool = nil
+	s.blocks = append(s.blocks, b)
+	s.hashes[b.Hash] = b
+	return b
+}
+
+func (s *Server) drop(id string) {
+	e := s.txs[id]
+	if e == nil {
+		return
+	}
+	tx := e.tx
+	for op, child := range s.spent {
+		if op.Hash.String() == id {
+			s.drop(child)
+		}
+	}
+	if !isCoinbase(tx) {
+		for _, in := range tx.TxIn {
+			delete(s.spent, in.PreviousOutPoint)
+		}
+	}
+	delete(s.txs, id)
+	s.mempool = slices.DeleteFunc(s.mempool, func(m string) bool { return m == id })
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Id     any    `json:"id"`
+		Method string `json:"method"`
+		Params []any  `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := s.handle(call.Method, call.Params)
+	body := map[string]any{"id": call.Id, "result": result, "error": nil}
+	if err != nil {
+		body["result"] = nil
+		body["error"] = err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) handle(method string, params []any) (any, error) {
+	switch method {
+	case "getblockchaininfo":
+		tip := s.Tip()
+		return map[string]any{
+			"chain":         "main",
+			"blocks":        tip.Height,
+			"headers":       tip.Height,
+			"bestblockhash": tip.Hash,
+		}, nil
+	case "getblockcount":
+		return s.Tip().Height, nil
+	case "getblockhash":
+		return s.getBlockHash(params)
+	case "getblock":
+		return s.getBlock(params)
+	case "getrawtransaction":
+		return s.getRawTransaction(params)
+	case "getrawmempool":
+		return s.getRawMempool(params)
+	case "sendrawtransaction":
+		raw, ok := paramString(params, 0)
+		if !ok {
+			return nil, &rpcError{errorCodeInvalidParameter, "invalid raw transaction"}
+		}
+		return s.SendRawTransaction(raw)
+	default:
+		return nil, &rpcError{errorCodeMethodNotFound, "Method not found"}
+	}
+}
+
+func (s *Server) getBlockHash(params []any) (any, error) {
+	height, ok := paramNumber(params, 0)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !ok || height < 0 || int(height) >= len(s.blocks) {
+		return nil, &rpcError{errorCodeInvalidParameter, "Block height out of range"}
+	}
+	return s.blocks[height].Hash, nil
+}
+
+func (s *Server) getBlock(params []any) (any, error) {
+	hash, _ := paramString(params, 0)
+	verbosity, ok := paramNumber(params, 1)
+	if !ok {
+		verbosity = 1
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := s.hashes[hash]
+	if b == nil {
+		return nil, &rpcError{errorCodeNotFound, "Block not found"}
+	}
+	block := map[string]any{
+		"hash":          b.Hash,
+		"height":        b.Height,
+		"time":          b.Time.Unix(),
+		"confirmations": uint64(len(s.blocks)) - b.Height,
+		"tx":            b.Tx,
+	}
+	if b.Previous != "" {
+		block["previousblockhash"] = b.Previous
+	}
+	if verbosity > 1 {
+		txs := make([]map[string]any, len(b.Tx))
+		for i, id := range b.Tx {
+			txs[i] = s.verboseTransaction(id)
+		}
+		block["tx"] = txs
+	}
+	return block, nil
+}
+
+func (s *Server) getRawTransaction(params []any) (any, error) {
+	id, _ := paramString(params, 0)
+	verbose, _ := paramNumber(params, 1)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e := s.txs[id]
+	if e == nil {
+		return nil, &rpcError{errorCodeNotFound, "No such mempool or blockchain transaction"}
+	}
+	if verbose == 0 {
+		return serializeTransaction(e.tx), nil
+	}
+	return s.verboseTransaction(id), nil
+}
+
+func (s *Server) getRawMempool(params []any) (any, error) {
+	verbose, _ := paramNumber(params, 0)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if verbose == 0 {
+		return append([]string{}, s.mempool...), nil
+	}
+	pool := make(map[string]any)
+	for _, id := range s.mempool {
+		tx := s.txs[id].tx
+		pool[id] = map[string]any{
+			"vsize": virtualSize(tx),
+			"fees":  map[string]any{"base": satoshiToValue(s.fee(tx))},
+		}
+	}
+	return pool, nil
+}
+
+func (s *Server) verboseTransaction(id string) map[string]any {
+	e := s.txs[id]
+	vin := make([]map[string]any, len(e.tx.TxIn))
+	for i, in := range e.tx.TxIn {
+		if isCoinbase(e.tx) {
+			vin[i] = map[string]any{
+				"coinbase": hex.EncodeToString(in.SignatureScript),
+				"sequence": in.Sequence,
+			}
+			continue
+		}
+		vin[i] = map[string]any{
+			"txid":     in.PreviousOutPoint.Hash.String(),
+			"vout":     in.PreviousOutPoint.Index,
+			"sequence": in.Sequence,
+		}
+	}
+	vout := make([]map[string]any, len(e.tx.TxOut))
+	for i, out := range e.tx.TxOut {
+		vout[i] = map[string]any{
+			"value":        satoshiToValue(out.Value),
+			"n":            i,
+			"scriptPubKey": s.scriptPubKey(out.PkScript),
+		}
+	}
+	tx := map[string]any{
+		"txid":  id,
+		"hash":  e.tx.WitnessHash().String(),
+		"hex":   serializeTransaction(e.tx),
+		"vin":   vin,
+		"vout":  vout,
+		"vsize": virtualSize(e.tx),
+		"fee":   satoshiToValue(s.fee(e.tx)),
+	}
+	if b := s.hashes[e.block]; b != nil {
+		tx["blockhash"] = b.Hash
+		tx["confirmations"] = uint64(len(s.blocks)) - b.Height
+		tx["blocktime"] = b.Time.Unix()
+	}
+	return tx
+}
+
+func (s *Server) scriptPubKey(script []byte) map[string]any {
+	spk := map[string]any{
+		"hex":  hex.EncodeToString(script),
+		"type": scriptType(txscript.GetScriptClass(script)),
+	}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(script, bitcoin.NetConfig(s.chain))
+	if err == nil && len(addrs) == 1 {
+		spk["address"] = addrs[0].EncodeAddress()
+	}
+	return spk
+}
+
+func (s *Server) fee(tx *wire.MsgTx) int64 {
+	if isCoinbase(tx) {
+		return 0
+	}
+	var fee int64
+	for _, in := range tx.TxIn {
+		prev := s.txs[in.PreviousOutPoint.Hash.String()]
+		fee += prev.tx.TxOut[in.PreviousOutPoint.Index].Value
+	}
+	for _, out := range tx.TxOut {
+		fee -= out.Value
+	}
+	return fee
+}
+
+func scriptType(class txscript.ScriptClass) string {
+	switch class {
+	case txscript.WitnessV0ScriptHashTy:
+		return bitcoin.ScriptPubKeyTypeWitnessScriptHash
+	case txscript.WitnessV0PubKeyHashTy:
+		return bitcoin.ScriptPubKeyTypeWitnessKeyHash
+	case txscript.WitnessV1TaprootTy:
+		return "witness_v1_taproot"
+	case txscript.PubKeyHashTy:
+		return "pubkeyhash"
+	case txscript.ScriptHashTy:
+		return "scripthash"
+	case txscript.NullDataTy:
+		return "nulldata"
+	default:
+		return "nonstandard"
+	}
+}
+
+func isCoinbase(tx *wire.MsgTx) bool {
+	if len(tx.TxIn) != 1 {
+		return false
+	}
+	prev := tx.TxIn[0].PreviousOutPoint
+	return prev.Index == wire.MaxPrevOutIndex && prev.Hash == chainhash.Hash{}
+}
+
+func virtualSize(tx *wire.MsgTx) int64 {
+	weight := tx.SerializeSizeStripped()*3 + tx.SerializeSize()
+	return int64((weight + 3) / 4)
+}
+
+func satoshiToValue(satoshi int64) float64 {
+	return float64(satoshi) / bitcoin.ValueSatoshi
+}
+
+func serializeTransaction(tx *wire.MsgTx) string {
+	var buf bytes.Buffer
+	err := tx.Serialize(&buf)
+	if err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func paramString(params []any, i int) (string, bool) {
+	if len(params) <= i {
+		return "", false
+	}
+	v, ok := params[i].(string)
+	return v, ok
+}
+
+// verbose flags are either booleans or numbers in Bitcoin Core
+func paramNumber(params []any, i int) (int64, bool) {
+	if len(params) <= i {
+		return 0, false
+	}
+	switch v := params[i].(type) {
+	case float64:
+		return int64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,130 @@
+package rpctest
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAddress  = "bc1qr0qz4peqhqphsqw5fyqnrz2zn5485rrhaxv43kyrcztmkyuskmkss9qash"
+	testReceiver = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+)
+
+func TestServerDepositAndReorg(t *testing.T) {
+	require := require.New(t)
+	s := NewServer(bitcoin.ChainBitcoin)
+	defer s.Close()
+
+	height, err := bitcoin.RPCGetBlockHeight(s.URL())
+	require.Nil(err)
+	require.Equal(int64(0), height)
+
+	id, err := s.Fund(testAddress, 100000)
+	require.Nil(err)
+	pool, err := bitcoin.RPCGetRawMempool(bitcoin.ChainBitcoin, s.URL())
+	require.Nil(err)
+	require.Len(pool, 1)
+	require.Equal(id, pool[0].TxId)
+	_, out, err := bitcoin.RPCGetTransactionOutput(bitcoin.ChainBitcoin, s.URL(), id, 0)
+	require.Nil(err)
+	require.Equal(testAddress, out.Address)
+	require.Equal(int64(100000), out.Satoshi)
+	require.Equal(^uint64(0), out.Height)
+
+	hashes := s.Mine(2)
+	require.Len(hashes, 2)
+	block, err := bitcoin.RPCGetBlockWithTransactions(bitcoin.ChainBitcoin, s.URL(), hashes[0])
+	require.Nil(err)
+	require.Equal(uint64(1), block.Height)
+	require.Len(block.Tx, 1)
+	require.Equal(id, block.Tx[0].TxId)
+	require.Equal(testAddress, block.Tx[0].Vout[0].ScriptPubKey.Address)
+	_, out, err = bitcoin.RPCGetTransactionOutput(bitcoin.ChainBitcoin, s.URL(), id, 0)
+	require.Nil(err)
+	require.Equal(uint64(1), out.Height)
+
+	forks, err := s.Reorg(2, 1)
+	require.Nil(err)
+	require.Len(forks, 1)
+	require.NotEqual(hashes[0], forks[0])
+	_, err = bitcoin.RPCGetBlock(s.URL(), hashes[1])
+	require.NotNil(err)
+	hash, err := bitcoin.RPCGetBlockHash(s.URL(), 1)
+	require.Nil(err)
+	require.Equal(forks[0], hash)
+	_, out, err = bitcoin.RPCGetTransactionOutput(bitcoin.ChainBitcoin, s.URL(), id, 0)
+	require.Nil(err)
+	require.Equal(uint64(1), out.Height)
+
+	forks, err = s.Reorg(1, 0)
+	require.Nil(err)
+	require.Len(forks, 0)
+	require.Equal([]string{id}, s.Mempool())
+	_, out, err = bitcoin.RPCGetTransactionOutput(bitcoin.ChainBitcoin, s.URL(), id, 0)
+	require.Nil(err)
+	require.Equal(^uint64(0), out.Height)
+}
+
+func TestServerMempool(t *testing.T) {
+	require := require.New(t)
+	s := NewServer(bitcoin.ChainBitcoin)
+	defer s.Close()
+
+	id, err := s.Fund(testAddress, 100000)
+	require.Nil(err)
+	raw := testSpend(require, id, 0, testReceiver, 90000)
+	spend, err := bitcoin.RPCSendRawTransaction(s.URL(), raw)
+	require.Nil(err)
+	_, err = bitcoin.RPCSendRawTransaction(s.URL(), testSpend(require, id, 0, testReceiver, 80000))
+	require.NotNil(err)
+	require.Contains(err.Error(), "txn-mempool-conflict")
+
+	pool, err := bitcoin.RPCGetRawMempoolWithTransactions(s.URL())
+	require.Nil(err)
+	require.Len(pool, 2)
+	for _, tx := range pool {
+		if tx.TxId == spend {
+			require.Equal(0.0001, tx.Fee)
+		}
+	}
+	tx, err := bitcoin.RPCGetTransaction(bitcoin.ChainBitcoin, s.URL(), spend)
+	require.Nil(err)
+	require.Equal("", tx.BlockHash)
+	sender, err := bitcoin.RPCGetTransactionSender(bitcoin.ChainBitcoin, s.URL(), tx)
+	require.Nil(err)
+	require.Equal(testAddress, sender)
+
+	err = s.DropTransaction(id)
+	require.Nil(err)
+	require.Len(s.Mempool(), 0)
+	_, err = bitcoin.RPCGetTransaction(bitcoin.ChainBitcoin, s.URL(), spend)
+	require.NotNil(err)
+
+	id, err = s.Fund(testAddress, 100000)
+	require.Nil(err)
+	s.Mine(1)
+	err = s.DropTransaction(id)
+	require.NotNil(err)
+	spend, err = bitcoin.RPCSendRawTransaction(s.URL(), testSpend(require, id, 0, testReceiver, 90000))
+	require.Nil(err)
+	require.Equal([]string{spend}, s.Mempool())
+}
+
+func testSpend(require *require.Assertions, id string, index uint32, receiver string, satoshi int64) string {
+	hash, err := chainhash.NewHashFromStr(id)
+	require.Nil(err)
+	addr, err := btcutil.DecodeAddress(receiver, bitcoin.NetConfig(bitcoin.ChainBitcoin))
+	require.Nil(err)
+	script, err := txscript.PayToAddrScript(addr)
+	require.Nil(err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, index), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(satoshi, script))
+	return serializeTransaction(tx)
+}
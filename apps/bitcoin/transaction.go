@@ -2,9 +2,12 @@ package bitcoin
 
 import (
 	"bytes"
+	"cmp"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/btcsuite/btcd/blockchain"
@@ -64,11 +67,35 @@ func (psbt *PartiallySignedTransaction) EstimateVirtualSize() int {
 		panic(err)
 	}
 	const witnessSize = 264
-	weight := len(tx.MsgTx().TxIn) * witnessSize
-	weight = weight + 4*len(raw)
+	weight := 4 * len(raw)
+	for _, pin := range psbt.Inputs {
+		if len(pin.RedeemScript) > 0 {
+			// the legacy signature script is not discounted, and the script
+			// length of the redeem script push is not in the witness size
+			script := witnessSize + 3 + estimateHoldersWitnessSize(pin.RedeemScript)
+			weight = weight + 4*script
+			continue
+		}
+		weight = weight + witnessSize + estimateHoldersWitnessSize(pin.WitnessScript)
+	}
 	return weight / 4
 }
 
+// the nested multisig of n holder keys has n-1 more keys and two more small
+// integers in the script, and m-1 more signatures and a dummy in the witness,
+// the script length may also take two more bytes when it grows past 252
+func estimateHoldersWitnessSize(script []byte) int {
+	if len(script) == 0 || script[0] < txscript.OP_1 || script[0] > txscript.OP_16 {
+		return 0
+	}
+	m := int(script[0] - txscript.OP_1 + 1)
+	n := 0
+	for 1+n*34 < len(script) && script[1+n*34] == txscript.OP_DATA_33 {
+		n = n + 1
+	}
+	return (n-1)*34 + 2 + 2 + (m-1)*74 + 1
+}
+
 func UnmarshalPartiallySignedTransaction(b []byte) (*PartiallySignedTransaction, error) {
 	pkt, err := psbt.NewFromRawBytes(bytes.NewReader(b), false)
 	if err != nil {
@@ -84,9 +111,18 @@ func (psbt *PartiallySignedTransaction) IsRecoveryTransaction() bool {
 		psbt.UnsignedTx.TxIn[0].Sequence != MaxTransactionSequence
 }
 
+// SigHash returns the legacy signature hash for the P2SH inputs, which have the
+// redeem script instead of the witness script
 func (psbt *PartiallySignedTransaction) SigHash(idx int) []byte {
 	tx := psbt.UnsignedTx
 	pin := psbt.Inputs[idx]
+	if len(pin.RedeemScript) > 0 {
+		hash, err := txscript.CalcSignatureHash(pin.RedeemScript, SigHashType, tx, idx)
+		if err != nil {
+			panic(err)
+		}
+		return hash
+	}
 	satoshi := pin.WitnessUtxo.Value
 	pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessScript, satoshi)
 	tsh := txscript.NewTxSigHashes(tx, pof)
@@ -98,8 +134,18 @@ func (psbt *PartiallySignedTransaction) SigHash(idx int) []byte {
 }
 
 func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observer string) (*wire.MsgTx, error) {
+	return psbt.SignedTransactionWithHolders([]string{holder}, 1, signer, observer)
+}
+
+// SignedTransactionWithHolders builds the witness of the nested multisig of
+// the holder keys, the signatures are ordered as the keys in the script, and
+// all signatures are empty if the holder keys threshold is not reached
+func (psbt *PartiallySignedTransaction) SignedTransactionWithHolders(holders []string, threshold int, signer, observer string) (*wire.MsgTx, error) {
+	return psbt.signedTransactionWithHolders(holders, threshold, signer, observer, psbt.IsRecoveryTransaction())
+}
+
+func (psbt *PartiallySignedTransaction) signedTransactionWithHolders(holders []string, threshold int, signer, observer string, isRecoveryTransaction bool) (*wire.MsgTx, error) {
 	msgTx := psbt.UnsignedTx.Copy()
-	isRecoveryTransaction := psbt.IsRecoveryTransaction()
 	for idx := range msgTx.TxIn {
 		pin := psbt.Inputs[idx]
 		sigs := make(map[string][]byte, 2)
@@ -112,43 +158,97 @@ func (psbt *PartiallySignedTransaction) SignedTransaction(holder, signer, observ
 			sigs[pub] = sig
 		}
 
-		holderSig := sigs[holder]
+		var holderSigs [][]byte
+		for _, h := range holders {
+			if sig := sigs[h]; sig != nil && len(holderSigs) < threshold {
+				holderSigs = append(holderSigs, append(sig, byte(pin.SighashType)))
+			}
+		}
+		holderSigned := len(holderSigs) == threshold
 		signerSig := sigs[signer]
 		observerSig := sigs[observer]
 		switch {
 		case isRecoveryTransaction:
 			if observerSig == nil {
-				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) observer", holder, signer, observer)
+				return nil, fmt.Errorf("psbt.SignedTransaction(%v, %s, %s) observer", holders, signer, observer)
 			}
-			if holderSig == nil && signerSig == nil {
-				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder&signer", holder, signer, observer)
+			if !holderSigned && signerSig == nil {
+				return nil, fmt.Errorf("psbt.SignedTransaction(%v, %s, %s) holder&signer", holders, signer, observer)
 			}
 		case !isRecoveryTransaction:
-			if holderSig == nil {
-				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) holder", holder, signer, observer)
+			if !holderSigned {
+				return nil, fmt.Errorf("psbt.SignedTransaction(%v, %s, %s) holder", holders, signer, observer)
 			}
 			if signerSig == nil {
-				return nil, fmt.Errorf("psbt.SignedTransaction(%s, %s, %s) signer", holder, signer, observer)
+				return nil, fmt.Errorf("psbt.SignedTransaction(%v, %s, %s) signer", holders, signer, observer)
 			}
 		}
 
+		var stack wire.TxWitness
 		if observerSig != nil {
 			observerSig = append(observerSig, byte(pin.SighashType))
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, observerSig)
+		stack = append(stack, observerSig)
 		if signerSig != nil {
 			signerSig = append(signerSig, byte(pin.SighashType))
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, signerSig)
-		if holderSig != nil {
-			holderSig = append(holderSig, byte(pin.SighashType))
+		stack = append(stack, signerSig)
+		if !holderSigned {
+			holderSigs = make([][]byte, threshold)
+		}
+		if len(holders) > 1 {
+			stack = append(stack, nil)
+		}
+		stack = append(stack, holderSigs...)
+
+		// the signature script pushes the same stack as the witness
+		if len(pin.RedeemScript) > 0 {
+			builder := txscript.NewScriptBuilder()
+			for _, item := range append(stack, pin.RedeemScript) {
+				builder.AddData(item)
+			}
+			script, err := builder.Script()
+			if err != nil {
+				return nil, err
+			}
+			msgTx.TxIn[idx].SignatureScript = script
+			continue
 		}
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, holderSig)
-		msgTx.TxIn[idx].Witness = append(msgTx.TxIn[idx].Witness, pin.WitnessScript)
+		msgTx.TxIn[idx].Witness = append(stack, pin.WitnessScript)
 	}
 	return msgTx, nil
 }
 
+// MergePartialSignatures adds the valid signatures of the public keys from
+// other to the transaction, and returns the number of signatures added
+func (raw *PartiallySignedTransaction) MergePartialSignatures(other *PartiallySignedTransaction, publics []string) int {
+	if raw.Hash() != other.Hash() || len(raw.Inputs) != len(other.Inputs) {
+		return 0
+	}
+	var added int
+	for idx := range raw.Inputs {
+		pin := &raw.Inputs[idx]
+		hash := raw.SigHash(idx)
+		for _, ps := range other.Inputs[idx].PartialSigs {
+			pub := hex.EncodeToString(ps.PubKey)
+			if !slices.Contains(publics, pub) {
+				continue
+			}
+			if slices.ContainsFunc(pin.PartialSigs, func(s *psbt.PartialSig) bool {
+				return bytes.Equal(s.PubKey, ps.PubKey)
+			}) {
+				continue
+			}
+			if VerifySignatureDER(pub, hash, ps.Signature) != nil {
+				continue
+			}
+			pin.PartialSigs = append(pin.PartialSigs, ps)
+			added = added + 1
+		}
+	}
+	return added
+}
+
 func MarshalWiredTransaction(msgTx *wire.MsgTx, encoding wire.MessageEncoding, chain byte) ([]byte, error) {
 	var rawBuffer bytes.Buffer
 	err := msgTx.BtcEncode(&rawBuffer, protocolVersion(chain), encoding)
@@ -159,6 +259,12 @@ func MarshalWiredTransaction(msgTx *wire.MsgTx, encoding wire.MessageEncoding, c
 }
 
 func CheckTransactionPartiallySignedBy(raw, public string) bool {
+	return CheckTransactionPartiallySignedByHolders(raw, []string{public}, 1)
+}
+
+// CheckTransactionPartiallySignedByHolders returns true if all inputs have
+// valid signatures of at least threshold keys of the holders
+func CheckTransactionPartiallySignedByHolders(raw string, holders []string, threshold int) bool {
 	b, _ := hex.DecodeString(raw)
 	psbt, _ := UnmarshalPartiallySignedTransaction(b)
 
@@ -174,12 +280,19 @@ func CheckTransactionPartiallySignedBy(raw, public string) bool {
 			sigs[pub] = sig
 		}
 
-		if sigs[public] == nil {
-			return false
-		}
+		var signed int
 		hash := psbt.SigHash(i)
-		err := VerifySignatureDER(public, hash, sigs[public])
-		if err != nil {
+		for _, public := range holders {
+			if sigs[public] == nil {
+				continue
+			}
+			err := VerifySignatureDER(public, hash, sigs[public])
+			if err != nil {
+				return false
+			}
+			signed = signed + 1
+		}
+		if signed < threshold {
 			return false
 		}
 	}
@@ -222,6 +335,20 @@ func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, c
 	for idx, in := range feeInputs {
 		script := scripts[idx]
 		idx = idx + mainCount
+		if IsLegacyChain(chain) {
+			sig, err := txscript.RawTxInSignature(msgTx, idx, in.Script, txscript.SigHashAll, privateKey)
+			if err != nil {
+				return nil, err
+			}
+			builder := txscript.NewScriptBuilder()
+			builder.AddData(sig)
+			builder.AddData(script)
+			msgTx.TxIn[idx].SignatureScript, err = builder.Script()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		pof := txscript.NewCannedPrevOutputFetcher(in.Script, in.Satoshi)
 		tsh := txscript.NewTxSigHashes(msgTx, pof)
 		hash, err := txscript.CalcWitnessSigHash(in.Script, tsh, txscript.SigHashAll, msgTx, idx, in.Satoshi)
@@ -237,6 +364,111 @@ func SpendSignedTransaction(raw string, feeInputs []*Input, accountant string, c
 	return msgTx, nil
 }
 
+// SelectInputs picks the inputs to spend for the outputs deterministically,
+// it searches for a combination whose change is no more than dust at first,
+// otherwise spends the largest inputs first and the change goes to the safe
+func SelectInputs(inputs []*Input, satoshi int64, chain byte) ([]*Input, error) {
+	sorted := slices.Clone(inputs)
+	slices.SortFunc(sorted, func(a, b *Input) int {
+		if a.Satoshi != b.Satoshi {
+			return cmp.Compare(b.Satoshi, a.Satoshi)
+		}
+		if a.TransactionHash != b.TransactionHash {
+			return strings.Compare(a.TransactionHash, b.TransactionHash)
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	var total int64
+	for _, in := range sorted {
+		total = total + in.Satoshi
+	}
+	if total < satoshi {
+		return nil, BuildInsufficientInputError("main", fmt.Sprint(total), fmt.Sprint(satoshi))
+	}
+
+	selected := selectInputsBranchAndBound(sorted, satoshi, ValueDust(chain))
+	if selected != nil {
+		return selected, nil
+	}
+	var amount int64
+	for _, in := range sorted {
+		selected = append(selected, in)
+		amount = amount + in.Satoshi
+		if amount >= satoshi {
+			break
+		}
+	}
+	return selected, nil
+}
+
+func selectInputsBranchAndBound(sorted []*Input, target, tolerance int64) []*Input {
+	remains := make([]int64, len(sorted)+1)
+	for i := len(sorted) - 1; i >= 0; i-- {
+		remains[i] = remains[i+1] + sorted[i].Satoshi
+	}
+
+	tries := 100000
+	var selection []int
+	var search func(i int, amount int64) bool
+	search = func(i int, amount int64) bool {
+		if tries--; tries < 0 {
+			return false
+		}
+		if amount >= target {
+			return amount <= target+tolerance
+		}
+		if i == len(sorted) || amount+remains[i] < target {
+			return false
+		}
+		selection = append(selection, i)
+		if search(i+1, amount+sorted[i].Satoshi) {
+			return true
+		}
+		selection = selection[:len(selection)-1]
+		return search(i+1, amount)
+	}
+	if !search(0, 0) {
+		return nil
+	}
+
+	selected := make([]*Input, len(selection))
+	for i, idx := range selection {
+		selected[i] = sorted[idx]
+	}
+	return selected
+}
+
+// SelectConsolidationInputs picks the smallest inputs deterministically to
+// consolidate them into a single output back to the safe address
+func SelectConsolidationInputs(inputs []*Input, chain byte) ([]*Input, error) {
+	if len(inputs) < ConsolidationUnspentUtxo {
+		return nil, fmt.Errorf("consolidation inputs %d", len(inputs))
+	}
+	sorted := slices.Clone(inputs)
+	slices.SortFunc(sorted, func(a, b *Input) int {
+		if a.Satoshi != b.Satoshi {
+			return cmp.Compare(a.Satoshi, b.Satoshi)
+		}
+		if a.TransactionHash != b.TransactionHash {
+			return strings.Compare(a.TransactionHash, b.TransactionHash)
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+	if len(sorted) > MaxConsolidationInputs {
+		sorted = sorted[:MaxConsolidationInputs]
+	}
+
+	var total int64
+	for _, in := range sorted {
+		total = total + in.Satoshi
+	}
+	if total <= ValueDust(chain) {
+		return nil, BuildInsufficientInputError("main", fmt.Sprint(total), fmt.Sprint(ValueDust(chain)))
+	}
+	return sorted, nil
+}
+
 func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid []byte, chain byte) (*PartiallySignedTransaction, error) {
 	msgTx := wire.NewMsgTx(2)
 
@@ -317,11 +549,17 @@ func BuildPartiallySignedTransaction(mainInputs []*Input, outputs []*Output, rid
 		if err != nil {
 			panic(address)
 		}
+		// the value is kept in the witness utxo for the legacy P2SH inputs
+		// too, because the keeper checks the input amounts with it
 		pin := psbt.NewPsbtInput(nil, &wire.TxOut{
 			Value:    in.Satoshi,
 			PkScript: pkScript,
 		})
-		pin.WitnessScript = in.Script
+		if IsLegacyChain(chain) {
+			pin.RedeemScript = in.Script
+		} else {
+			pin.WitnessScript = in.Script
+		}
 		pin.SighashType = SigHashType
 		if !pin.IsSane() {
 			panic(address)
@@ -369,8 +607,11 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 			Index: in.Index,
 		},
 	}
-	typ := checkScriptType(in.Script)
-	if in.RouteBackup {
+	typ := checkScriptType(in.Script, chain)
+	switch {
+	case in.RouteBackup && typ == InputTypeP2SHMultisigHolderSigner:
+		typ = InputTypeP2SHMultisigObserverSigner
+	case in.RouteBackup:
 		typ = InputTypeP2WSHMultisigObserverSigner
 	}
 	switch typ {
@@ -406,6 +647,28 @@ func addInput(tx *wire.MsgTx, in *Input, chain byte) (string, error) {
 		}
 		addr = mwsh.EncodeAddress()
 		txIn.Sequence = in.Sequence
+	case InputTypeP2PKHAccoutant:
+		pkh, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(in.Script), NetConfig(chain))
+		if err != nil {
+			return "", err
+		}
+		script, err := txscript.PayToAddrScript(pkh)
+		if err != nil {
+			return "", err
+		}
+		in.Script = script
+		addr = pkh.EncodeAddress()
+		txIn.Sequence = MaxTransactionSequence
+	case InputTypeP2SHMultisigHolderSigner, InputTypeP2SHMultisigObserverSigner:
+		msh, err := btcutil.NewAddressScriptHash(in.Script, NetConfig(chain))
+		if err != nil {
+			return "", err
+		}
+		addr = msh.EncodeAddress()
+		txIn.Sequence = MaxTransactionSequence
+		if typ == InputTypeP2SHMultisigObserverSigner {
+			txIn.Sequence = in.Sequence
+		}
 	default:
 		return "", fmt.Errorf("invalid input type %d", typ)
 	}
@@ -48,3 +48,68 @@ func TestTransaction(t *testing.T) {
 	raw, _ = MarshalWiredTransaction(msgTx, wire.WitnessEncoding, ChainBitcoin)
 	require.Equal("020000000001016daf0a2ca612879093698c5ab6dbcff372e893137d5dfda23615e1489f5e07210000000000ffffffff0310270000000000002200204a8f0888cc30695a20c71ae0d119f4c09743c0d03a7db52774d06c49a52d081a905f0100000000002200204a8f0888cc30695a20c71ae0d119f4c09743c0d03a7db52774d06c49a52d081a0000000000000000126a104525b641cc6e4ed1b2bb0713b786da6b0400483045022100ab98516d06c3a32eae440d58d6a2cfca1a4e545a50d52f56926869916a028a2002203e65b54b64f1d4298d255e4368529291265274eaa9c2d712ee5e3474cf8ae8308147304402206c9adbfea684f9dca42700db018a6aaebbee1f679f553e871351031ccdbff3510220064eeed0c51e0a018b4c275e6585fd81d686c106be1708507a1bd4813affb7a88178210208134c3bb3263598db7f28cb631b34f81d34bfdf3cee163da7c41b6434e92fadac7c2103e17978200e8961fc87358898db7b0d5686aa4f14935d418de9b533d14922a4b3ac937c8292632103c8f64e27a2f3ae961a57184841df19e7d8708ddbc998f0c5abc7197ead70931fad02b001b2926893528700000000", hex.EncodeToString(raw))
 }
+
+func TestSelectInputs(t *testing.T) {
+	require := require.New(t)
+
+	inputs := []*Input{
+		{TransactionHash: "40e228e5a3cba99fd3fc5350a00bfeef8bafb760e26919ec74bca67776c90427", Index: 0, Satoshi: 86560},
+		{TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194", Index: 0, Satoshi: 100000},
+		{TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194", Index: 1, Satoshi: 20000},
+		{TransactionHash: "0e88c368c51fb24421b2a36d82674a5f058eb98d67da844d393b8df00ad2ad3f", Index: 2, Satoshi: 20000},
+	}
+
+	selected, err := SelectInputs(inputs, 12300, ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, 1)
+	require.Equal(int64(100000), selected[0].Satoshi)
+
+	selected, err = SelectInputs(inputs, 106000, ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, 2)
+	require.Equal(int64(86560), selected[0].Satoshi)
+	require.Equal("0e88c368c51fb24421b2a36d82674a5f058eb98d67da844d393b8df00ad2ad3f", selected[1].TransactionHash)
+
+	selected, err = SelectInputs(inputs, 120000, ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, 2)
+	require.Equal(int64(100000), selected[0].Satoshi)
+	require.Equal(int64(20000), selected[1].Satoshi)
+
+	selected, err = SelectInputs(inputs, 190000, ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, 3)
+	require.Equal(int64(206560), selected[0].Satoshi+selected[1].Satoshi+selected[2].Satoshi)
+
+	_, err = SelectInputs(inputs, 226561, ChainBitcoin)
+	require.True(IsInsufficientInputError(err))
+	require.Len(inputs, 4)
+	require.Equal(int64(86560), inputs[0].Satoshi)
+}
+
+func TestSelectConsolidationInputs(t *testing.T) {
+	require := require.New(t)
+
+	var inputs []*Input
+	for i := 0; i < MaxConsolidationInputs+10; i++ {
+		inputs = append(inputs, &Input{
+			TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
+			Index:           uint32(i),
+			Satoshi:         int64(100000 - i),
+		})
+	}
+
+	_, err := SelectConsolidationInputs(inputs[:ConsolidationUnspentUtxo-1], ChainBitcoin)
+	require.NotNil(err)
+
+	selected, err := SelectConsolidationInputs(inputs[:ConsolidationUnspentUtxo], ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, ConsolidationUnspentUtxo)
+
+	selected, err = SelectConsolidationInputs(inputs, ChainBitcoin)
+	require.Nil(err)
+	require.Len(selected, MaxConsolidationInputs)
+	require.Equal(uint32(len(inputs)-1), selected[0].Index)
+	require.Equal(uint32(10), selected[MaxConsolidationInputs-1].Index)
+	require.Equal(uint32(0), inputs[0].Index)
+}
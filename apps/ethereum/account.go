@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -19,7 +20,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
-// create a gnosis safe contract with 2/3 multisig
+// create a gnosis safe contract with 2/3 multisig, or m+1 of n+2 with holders
 // with safe guard to do time lock of observer
 // with deploy2 to determine exact contract address
 
@@ -59,8 +60,17 @@ func UnmarshalGnosisSafe(extra []byte) (*GnosisSafe, error) {
 }
 
 func BuildGnosisSafe(ctx context.Context, rpc, holder, signer, observer, rid string, lock time.Duration, chain byte) (*GnosisSafe, *SafeTransaction, error) {
-	owners, _ := GetSortedSafeOwners(holder, signer, observer)
-	safeAddress := GetSafeAccountAddress(owners, 2).Hex()
+	return BuildGnosisSafeWithHolders(ctx, rpc, []string{holder}, 1, signer, observer, rid, lock, chain)
+}
+
+// BuildGnosisSafeWithHolders adds all holder keys as safe owners, and the
+// safe threshold is the holders threshold plus one, so the holders quorum
+// must sign with the signer. The observer recovery after the time lock
+// requires the signer only when the holders threshold is one, otherwise
+// the observer needs the holders quorum instead.
+func BuildGnosisSafeWithHolders(ctx context.Context, rpc string, holders []string, threshold int, signer, observer, rid string, lock time.Duration, chain byte) (*GnosisSafe, *SafeTransaction, error) {
+	owners, _ := GetSortedSafeOwnersWithHolders(holders, signer, observer)
+	safeAddress := GetSafeAccountAddress(owners, GetSafeThreshold(threshold)).Hex()
 	ob, err := ParseEthereumCompressedPublicKey(observer)
 	if err != nil {
 		return nil, nil, fmt.Errorf("ethereum.ParseEthereumCompressedPublicKey(%s) => %v %v", observer, ob, err)
@@ -85,9 +95,18 @@ func BuildGnosisSafe(ctx context.Context, rpc, holder, signer, observer, rid str
 	}, t, nil
 }
 
+func GetSafeThreshold(holderThreshold int) int64 {
+	return int64(holderThreshold) + 1
+}
+
 func GetSortedSafeOwners(holder, signer, observer string) ([]string, []string) {
+	return GetSortedSafeOwnersWithHolders([]string{holder}, signer, observer)
+}
+
+func GetSortedSafeOwnersWithHolders(holders []string, signer, observer string) ([]string, []string) {
+	keys := append(slices.Clone(holders), signer, observer)
 	var owners []string
-	for _, pub := range []string{holder, signer, observer} {
+	for _, pub := range keys {
 		addr, err := ParseEthereumCompressedPublicKey(pub)
 		if err != nil {
 			panic(pub)
@@ -100,8 +119,8 @@ func GetSortedSafeOwners(holder, signer, observer string) ([]string, []string) {
 		addressMap[a] = i
 	}
 
-	pubs := make([]string, 3)
-	for _, pub := range []string{holder, signer, observer} {
+	pubs := make([]string, len(keys))
+	for _, pub := range keys {
 		addr, _ := ParseEthereumCompressedPublicKey(pub)
 		index := addressMap[addr.Hex()]
 		pubs[index] = pub
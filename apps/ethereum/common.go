@@ -244,11 +244,53 @@ func UnitAmount(amount *big.Int, decimals int32) string {
 	return amt.String()
 }
 
+const (
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+)
+
+var (
+	network          = NetworkMainnet
+	safeGuardAddress = EthereumSafeGuardAddress
+)
+
+// SetNetwork switches ethereum to sepolia and polygon to amoy when name is
+// testnet. The Safe v1.4.1 contracts have the same addresses on all of them,
+// but the Mixin Safe guard must be deployed separately to each testnet.
+func SetNetwork(name, guard string) {
+	switch name {
+	case NetworkMainnet:
+		guard = EthereumSafeGuardAddress
+	case NetworkTestnet:
+		if !common.IsHexAddress(guard) || common.HexToAddress(guard) == (common.Address{}) {
+			panic(guard)
+		}
+	default:
+		panic(name)
+	}
+	network = name
+	safeGuardAddress = common.HexToAddress(guard).Hex()
+}
+
+func Network() string {
+	return network
+}
+
+func SafeGuardAddress() string {
+	return safeGuardAddress
+}
+
 func GetEvmChainID(chain int64) int64 {
 	switch chain {
 	case ChainEthereum:
+		if network == NetworkTestnet {
+			return 11155111
+		}
 		return 1
 	case ChainPolygon:
+		if network == NetworkTestnet {
+			return 80002
+		}
 		return 137
 	case chainMVM:
 		return 73927
@@ -257,17 +299,24 @@ func GetEvmChainID(chain int64) int64 {
 	}
 }
 
+// GetMixinChainID returns the chain asset id, the testnet ids are derived
+// from the mainnet ones and the network name
 func GetMixinChainID(chain int64) string {
+	var id string
 	switch chain {
 	case ChainEthereum:
-		return "43d61dcd-e413-450d-80b8-101d5e903357"
+		id = "43d61dcd-e413-450d-80b8-101d5e903357"
 	case ChainPolygon:
-		return "b7938396-3f94-4e0a-9179-d3440718156f"
+		id = "b7938396-3f94-4e0a-9179-d3440718156f"
 	case chainMVM:
 		return "a0ffd769-5850-4b48-9651-d2ae44a3e64d"
 	default:
 		panic(chain)
 	}
+	if network == NetworkTestnet {
+		return BuildChainAssetId(id, network)
+	}
+	return id
 }
 
 func FetchAsset(chain byte, rpc, address string) (*Asset, error) {
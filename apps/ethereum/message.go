@@ -0,0 +1,47 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	safeMsgTypehash    = "0x60b3cbf8b4a223d68d641b3b6ddf9a298e7f33710cf3d3a9d1146b5a6150fbca"
+	MessageMaximumSize = 4096
+)
+
+// SafeMessageHash returns the EIP-712 hash of the safe message, which is
+// checked by isValidSignature(bytes,bytes) of the compatibility fallback
+// handler. To verify with isValidSignature(bytes32,bytes) of EIP-1271, the
+// message should be the 32 bytes data hash.
+func SafeMessageHash(chainID int64, safeAddress string, message []byte) []byte {
+	typehash, err := hex.DecodeString(safeMsgTypehash[2:])
+	if err != nil {
+		panic(err)
+	}
+	safeMessageHash := crypto.Keccak256(typehash, crypto.Keccak256(message))
+	domainSeparator := crypto.Keccak256(packDomainSeparatorArguments(chainID, safeAddress))
+	return crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator, safeMessageHash)
+}
+
+// PackSafeMessageSignatures concats the eth_sign signatures in the order of
+// the sorted owners, pubs are the public keys of the sorted owners
+func PackSafeMessageSignatures(pubs []string, sigs map[string][]byte) ([]byte, error) {
+	var signature []byte
+	for _, pub := range pubs {
+		sig := sigs[pub]
+		if sig == nil {
+			continue
+		}
+		if len(sig) != 65 || sig[64] <= 30 {
+			return nil, fmt.Errorf("invalid eth_sign signature %x", sig)
+		}
+		signature = append(signature, sig...)
+	}
+	if len(signature) < 65*2 {
+		return nil, fmt.Errorf("insufficient safe message signatures %d", len(signature)/65)
+	}
+	return signature, nil
+}
@@ -151,6 +151,34 @@ func RPCGetBlockWithTransactions(rpc string, height int64) (*RPCBlockWithTransac
 	return &b, err
 }
 
+// RPCGetPendingTransactions returns the transactions of the pending block,
+// which is only a subset of the mempool for most nodes
+func RPCGetPendingTransactions(rpc string) ([]*RPCTransaction, error) {
+	res, err := callEthereumRPCUntilSufficient(rpc, "eth_getBlockByNumber", []any{"pending", true})
+	if err != nil {
+		return nil, err
+	}
+	var b *RPCBlockWithTransactions
+	err = json.Unmarshal(res, &b)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return b.Tx, nil
+}
+
+func RPCGetTransactionCount(rpc, address string) (uint64, error) {
+	res, err := callEthereumRPCUntilSufficient(rpc, "eth_getTransactionCount", []any{address, "latest"})
+	if err != nil {
+		return 0, err
+	}
+	var n string
+	err = json.Unmarshal(res, &n)
+	if err != nil {
+		return 0, err
+	}
+	return ethereumNumberToUint64(n)
+}
+
 func RPCGetGasPrice(rpc string) (*big.Int, error) {
 	res, err := callEthereumRPCUntilSufficient(rpc, "eth_gasPrice", []any{})
 	if err != nil {
@@ -0,0 +1,898 @@
+// Package rpctest implements the subset of EVM JSON-RPC used by the safe over
+// an in-memory chain, so the keeper and observer flows can run without an
+// ethereum or polygon node.
+package rpctest
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	errorCodeInvalidParams  = -32602
+	errorCodeMethodNotFound = -32601
+	errorCodeExecution      = -32000
+
+	gasTransfer = 21000
+	gasContract = 100000
+)
+
+var (
+	selectorTransfer  = crypto.Keccak256([]byte("transfer(address,uint256)"))[:4]
+	selectorBalanceOf = crypto.Keccak256([]byte("balanceOf(address)"))[:4]
+	topicTransfer     = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+type Block struct {
+	Hash     common.Hash
+	Number   uint64
+	Previous common.Hash
+	Time     time.Time
+	Tx       []common.Hash
+}
+
+// Transaction is either a deposit injected by the test or a raw transaction
+// sent to the server, all ERC20 transfer calls succeed with a Transfer log
+type Transaction struct {
+	Hash     common.Hash
+	From     common.Address
+	To       common.Address
+	Value    *big.Int
+	Input    []byte
+	Nonce    uint64
+	Gas      uint64
+	GasPrice uint64
+	Logs     []*types.Log
+
+	deposit bool
+	block   *Block
+	index   int
+}
+
+// CallHandler answers the eth_call to a contract with the call data, which
+// starts with the 4 bytes method selector
+type CallHandler func(data []byte) ([]byte, error)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Server is a scriptable chain, all transactions stay in the mempool until a
+// block is mined, and the latest blocks could be reorganized at any time
+type Server struct {
+	chain     byte
+	mutex     sync.Mutex
+	blocks    []*Block
+	hashes    map[common.Hash]*Block
+	txs       map[common.Hash]*Transaction
+	mempool   []common.Hash
+	contracts map[common.Address]CallHandler
+	gasPrice  uint64
+	deposits  uint64
+	forks     uint64
+	server    *httptest.Server
+}
+
+func NewServer(chain byte) *Server {
+	switch chain {
+	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	default:
+		panic(chain)
+	}
+	s := &Server{
+		chain:     chain,
+		hashes:    make(map[common.Hash]*Block),
+		txs:       make(map[common.Hash]*Transaction),
+		contracts: make(map[common.Address]CallHandler),
+		gasPrice:  30000000000,
+	}
+	s.mine(time.Now().UTC())
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) ChainID() int64 {
+	return ethereum.GetEvmChainID(int64(s.chain))
+}
+
+// HandleCall deploys a contract at the address, all the eth_call to it are
+// answered by the handler
+func (s *Server) HandleCall(address string, handler CallHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.contracts[common.HexToAddress(address)] = handler
+}
+
+// Deposit sends the native value to the address, the transaction is put in
+// the mempool without any signature check
+func (s *Server) Deposit(from, to string, value *big.Int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx := s.deposit(common.HexToAddress(from), common.HexToAddress(to), value, nil)
+	return tx.Hash.Hex()
+}
+
+// DepositToken transfers the ERC20 token to the address, the token contract
+// needs no deployment and its balanceOf is answered from the Transfer logs
+func (s *Server) DepositToken(token, from, to string, value *big.Int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	receiver := common.HexToAddress(to)
+	input := append(slices.Clone(selectorTransfer), common.LeftPadBytes(receiver.Bytes(), 32)...)
+	input = append(input, common.LeftPadBytes(value.Bytes(), 32)...)
+	tx := s.deposit(common.HexToAddress(from), common.HexToAddress(token), new(big.Int), input)
+	return tx.Hash.Hex()
+}
+
+// SendRawTransaction puts the signed transaction in the mempool, the nonce
+// must be the next one of the sender, but the balance is not checked
+func (s *Server) SendRawTransaction(raw []byte) (string, error) {
+	var signed types.Transaction
+	err := signed.UnmarshalBinary(raw)
+	if err != nil {
+		return "", &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+	if signed.ChainId().Int64() != s.ChainID() {
+		return "", &rpcError{errorCodeExecution, "invalid chain id for signer"}
+	}
+	signer := types.LatestSignerForChainID(signed.ChainId())
+	from, err := types.Sender(signer, &signed)
+	if err != nil {
+		return "", &rpcError{errorCodeExecution, "invalid sender"}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.txs[signed.Hash()] != nil {
+		return signed.Hash().Hex(), nil
+	}
+	nonce := s.nonce(from, true)
+	if signed.Nonce() < nonce {
+		return "", &rpcError{errorCodeExecution, "nonce too low"}
+	}
+	if signed.Nonce() > nonce {
+		return "", &rpcError{errorCodeExecution, "nonce too high"}
+	}
+	tx := &Transaction{
+		Hash:     signed.Hash(),
+		From:     from,
+		Value:    signed.Value(),
+		Input:    signed.Data(),
+		Nonce:    signed.Nonce(),
+		Gas:      signed.Gas(),
+		GasPrice: signed.GasPrice().Uint64(),
+	}
+	if to := signed.To(); to != nil {
+		tx.To = *to
+	}
+	s.queue(tx)
+	return tx.Hash.Hex(), nil
+}
+
+// Mine creates n new blocks, the first one includes all the mempool transactions
+func (s *Server) Mine(n int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var hashes []string
+	for range n {
+		b := s.mine(time.Now().UTC())
+		hashes = append(hashes, b.Hash.Hex())
+	}
+	return hashes
+}
+
+// Reorg drops the latest depth blocks, all their transactions are put back
+// to the mempool, then n new blocks are mined on the fork point
+func (s *Server) Reorg(depth, n int) ([]string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if depth < 1 || depth >= len(s.blocks) {
+		return nil, fmt.Errorf("reorg depth %d of %d blocks", depth, len(s.blocks))
+	}
+	var pool []common.Hash
+	for _, b := range s.blocks[len(s.blocks)-depth:] {
+		for _, h := range b.Tx {
+			s.txs[h].block = nil
+		}
+		pool = append(pool, b.Tx...)
+		delete(s.hashes, b.Hash)
+	}
+	s.blocks = s.blocks[:len(s.blocks)-depth]
+	s.mempool = append(pool, s.mempool...)
+	s.forks += 1
+
+	var hashes []string
+	for range n {
+		b := s.mine(time.Now().UTC())
+		hashes = append(hashes, b.Hash.Hex())
+	}
+	return hashes, nil
+}
+
+// DropTransaction evicts the transaction from the mempool, e.g. to simulate
+// a replacement by another transaction with the same nonce
+func (s *Server) DropTransaction(hash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	h := common.HexToHash(hash)
+	tx := s.txs[h]
+	if tx == nil || tx.block != nil {
+		return fmt.Errorf("transaction %s not in mempool", hash)
+	}
+	delete(s.txs, h)
+	s.mempool = slices.DeleteFunc(s.mempool, func(m common.Hash) bool { return m == h })
+	return nil
+}
+
+func (s *Server) Mempool() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var pool []string
+	for _, h := range s.mempool {
+		pool = append(pool, h.Hex())
+	}
+	return pool
+}
+
+func (s *Server) Tip() *Block {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := *s.blocks[len(s.blocks)-1]
+	return &b
+}
+
+func (s *Server) GasPrice() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.gasPrice
+}
+
+func (s *Server) SetGasPrice(price uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.gasPrice = price
+}
+
+func (s *Server) deposit(from, to common.Address, value *big.Int, input []byte) *Transaction {
+	s.deposits += 1
+	seed := binary.BigEndian.AppendUint64([]byte("rpctest"), s.deposits)
+	tx := &Transaction{
+		Hash:     crypto.Keccak256Hash([]byte{s.chain}, seed),
+		From:     from,
+		To:       to,
+		Value:    value,
+		Input:    input,
+		Gas:      gasTransfer,
+		GasPrice: s.gasPrice,
+		deposit:  true,
+	}
+	if len(input) > 0 {
+		tx.Gas = gasContract
+	}
+	s.queue(tx)
+	return tx
+}
+
+func (s *Server) queue(tx *Transaction) {
+	if len(tx.Input) == 68 && slices.Equal(tx.Input[:4], selectorTransfer) {
+		tx.Logs = []*types.Log{{
+			Address: tx.To,
+			Topics: []common.Hash{
+				topicTransfer,
+				common.BytesToHash(tx.From.Bytes()),
+				common.BytesToHash(tx.Input[4:36]),
+			},
+			Data:   slices.Clone(tx.Input[36:68]),
+			TxHash: tx.Hash,
+		}}
+	}
+	s.txs[tx.Hash] = tx
+	s.mempool = append(s.mempool, tx.Hash)
+}
+
+func (s *Server) mine(timestamp time.Time) *Block {
+	b := &Block{
+		Number: uint64(len(s.blocks)),
+		Time:   timestamp,
+		Tx:     s.mempool,
+	}
+	if b.Number > 0 {
+		b.Previous = s.blocks[b.Number-1].Hash
+	}
+	seed := fmt.Sprintf("%d:%d:%s:%d:%v", s.chain, s.forks, b.Previous.Hex(), b.Number, b.Tx)
+	b.Hash = crypto.Keccak256Hash([]byte(seed))
+	var index uint
+	for i, h := range b.Tx {
+		tx := s.txs[h]
+		tx.block, tx.index = b, i
+		for _, l := range tx.Logs {
+			l.BlockNumber = b.Number
+			l.BlockHash = b.Hash
+			l.TxIndex = uint(i)
+			l.Index = index
+			index += 1
+		}
+	}
+	s.mempool = nil
+	s.blocks = append(s.blocks, b)
+	s.hashes[b.Hash] = b
+	return b
+}
+
+func (s *Server) nonce(address common.Address, pending bool) uint64 {
+	var nonce uint64
+	for _, tx := range s.txs {
+		if tx.From != address || tx.deposit {
+			continue
+		}
+		if tx.block == nil && !pending {
+			continue
+		}
+		nonce += 1
+	}
+	return nonce
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Id     any               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := s.handle(call.Method, call.Params)
+	body := map[string]any{"jsonrpc": "2.0", "id": call.Id, "result": result}
+	if err != nil {
+		delete(body, "result")
+		body["error"] = err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) handle(method string, params []json.RawMessage) (any, error) {
+	switch method {
+	case "eth_chainId":
+		return hexutil.Uint64(s.ChainID()), nil
+	case "net_version":
+		return fmt.Sprint(s.ChainID()), nil
+	case "eth_blockNumber":
+		return hexutil.Uint64(s.Tip().Number), nil
+	case "eth_gasPrice", "eth_maxPriorityFeePerGas":
+		return hexutil.Uint64(s.GasPrice()), nil
+	case "eth_getBlockByNumber":
+		return s.getBlockByNumber(params)
+	case "eth_getBlockByHash":
+		return s.getBlockByHash(params)
+	case "eth_getTransactionByHash":
+		return s.getTransactionByHash(params)
+	case "eth_getTransactionReceipt":
+		return s.getTransactionReceipt(params)
+	case "eth_getTransactionCount":
+		return s.getTransactionCount(params)
+	case "eth_getBalance":
+		return s.getBalance(params)
+	case "eth_getLogs":
+		return s.getLogs(params)
+	case "eth_getCode":
+		return s.getCode(params)
+	case "eth_call":
+		return s.call(params)
+	case "eth_estimateGas":
+		return s.estimateGas(params)
+	case "eth_sendRawTransaction":
+		var raw hexutil.Bytes
+		if len(params) < 1 || json.Unmarshal(params[0], &raw) != nil {
+			return nil, &rpcError{errorCodeInvalidParams, "invalid raw transaction"}
+		}
+		return s.SendRawTransaction(raw)
+	case "debug_traceTransaction":
+		return s.traceTransaction(params)
+	case "debug_traceBlockByNumber":
+		return s.traceBlockByNumber(params)
+	default:
+		return nil, &rpcError{errorCodeMethodNotFound, fmt.Sprintf("the method %s does not exist/is not available", method)}
+	}
+}
+
+func (s *Server) getBlockByNumber(params []json.RawMessage) (any, error) {
+	var full bool
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &full)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.paramBlock(params, 0)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	return s.blockJSON(b, full), nil
+}
+
+func (s *Server) getBlockByHash(params []json.RawMessage) (any, error) {
+	var hash string
+	if len(params) < 1 || json.Unmarshal(params[0], &hash) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid block hash"}
+	}
+	var full bool
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &full)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := s.hashes[common.HexToHash(hash)]
+	if b == nil {
+		return nil, nil
+	}
+	return s.blockJSON(b, full), nil
+}
+
+func (s *Server) getTransactionByHash(params []json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.paramTransaction(params)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	return s.transactionJSON(tx), nil
+}
+
+func (s *Server) getTransactionReceipt(params []json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.paramTransaction(params)
+	if err != nil || tx == nil || tx.block == nil {
+		return nil, err
+	}
+	var bloom types.Bloom
+	logs := make([]*types.Log, len(tx.Logs))
+	for i, l := range tx.Logs {
+		bloom.Add(l.Address.Bytes())
+		for _, t := range l.Topics {
+			bloom.Add(t.Bytes())
+		}
+		logs[i] = l
+	}
+	return map[string]any{
+		"transactionHash":   tx.Hash,
+		"transactionIndex":  hexutil.Uint64(tx.index),
+		"blockHash":         tx.block.Hash,
+		"blockNumber":       hexutil.Uint64(tx.block.Number),
+		"from":              tx.From,
+		"to":                tx.To,
+		"cumulativeGasUsed": hexutil.Uint64(tx.Gas),
+		"gasUsed":           hexutil.Uint64(tx.Gas),
+		"effectiveGasPrice": hexutil.Uint64(tx.GasPrice),
+		"contractAddress":   nil,
+		"logs":              logs,
+		"logsBloom":         bloom,
+		"status":            hexutil.Uint64(types.ReceiptStatusSuccessful),
+		"type":              hexutil.Uint64(types.LegacyTxType),
+	}, nil
+}
+
+func (s *Server) getTransactionCount(params []json.RawMessage) (any, error) {
+	var address common.Address
+	if len(params) < 1 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid address"}
+	}
+	var tag string
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &tag)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return hexutil.Uint64(s.nonce(address, tag == "pending")), nil
+}
+
+// getBalance sums all the native values in and out of the address until the
+// block, the gas is free on this chain
+func (s *Server) getBalance(params []json.RawMessage) (any, error) {
+	var address common.Address
+	if len(params) < 1 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid address"}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.paramBlock(params, 1)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, &rpcError{errorCodeExecution, "header not found"}
+	}
+	balance := new(big.Int)
+	for _, blk := range s.blocks[:b.Number+1] {
+		for _, h := range blk.Tx {
+			tx := s.txs[h]
+			if tx.To == address {
+				balance.Add(balance, tx.Value)
+			}
+			if tx.From == address {
+				balance.Sub(balance, tx.Value)
+			}
+		}
+	}
+	if balance.Sign() < 0 {
+		balance.SetInt64(0)
+	}
+	return (*hexutil.Big)(balance), nil
+}
+
+func (s *Server) getLogs(params []json.RawMessage) (any, error) {
+	var filter struct {
+		FromBlock string          `json:"fromBlock"`
+		ToBlock   string          `json:"toBlock"`
+		BlockHash *common.Hash    `json:"blockHash"`
+		Address   json.RawMessage `json:"address"`
+		Topics    []any           `json:"topics"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &filter) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid filter"}
+	}
+	var addresses []common.Address
+	if len(filter.Address) > 0 && json.Unmarshal(filter.Address, &addresses) != nil {
+		var address common.Address
+		if json.Unmarshal(filter.Address, &address) != nil {
+			return nil, &rpcError{errorCodeInvalidParams, "invalid address"}
+		}
+		addresses = []common.Address{address}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var blocks []*Block
+	if filter.BlockHash != nil {
+		if b := s.hashes[*filter.BlockHash]; b != nil {
+			blocks = append(blocks, b)
+		}
+	} else {
+		from, err := s.blockByTag(filter.FromBlock, "latest")
+		if err != nil {
+			return nil, err
+		}
+		to, err := s.blockByTag(filter.ToBlock, "latest")
+		if err != nil {
+			return nil, err
+		}
+		if from != nil && to != nil && from.Number <= to.Number {
+			blocks = s.blocks[from.Number : to.Number+1]
+		}
+	}
+
+	logs := []*types.Log{}
+	for _, b := range blocks {
+		for _, h := range b.Tx {
+			for _, l := range s.txs[h].Logs {
+				if len(addresses) > 0 && !slices.Contains(addresses, l.Address) {
+					continue
+				}
+				if !matchTopics(l.Topics, filter.Topics) {
+					continue
+				}
+				logs = append(logs, l)
+			}
+		}
+	}
+	return logs, nil
+}
+
+func (s *Server) getCode(params []json.RawMessage) (any, error) {
+	var address string
+	if len(params) < 1 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid address"}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	addr := common.HexToAddress(address)
+	if s.contracts[addr] == nil && !s.isToken(addr) {
+		return "0x", nil
+	}
+	return "0x00", nil
+}
+
+func (s *Server) call(params []json.RawMessage) (any, error) {
+	var msg struct {
+		To    string `json:"to"`
+		Data  string `json:"data"`
+		Input string `json:"input"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &msg) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid call"}
+	}
+	input := msg.Input
+	if input == "" {
+		input = msg.Data
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil {
+		return nil, &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+	to := common.HexToAddress(msg.To)
+
+	s.mutex.Lock()
+	handler := s.contracts[to]
+	if handler == nil && len(data) == 36 && slices.Equal(data[:4], selectorBalanceOf) && s.isToken(to) {
+		defer s.mutex.Unlock()
+		b, err := s.paramBlock(params, 1)
+		if err != nil || b == nil {
+			return nil, &rpcError{errorCodeExecution, "header not found"}
+		}
+		owner := common.BytesToAddress(data[4:36])
+		return hexutil.Bytes(common.LeftPadBytes(s.tokenBalance(to, owner, b).Bytes(), 32)), nil
+	}
+	s.mutex.Unlock()
+
+	if handler == nil {
+		return "0x", nil
+	}
+	out, err := handler(data)
+	if err != nil {
+		return nil, &rpcError{errorCodeExecution, "execution reverted: " + err.Error()}
+	}
+	return hexutil.Bytes(out), nil
+}
+
+func (s *Server) estimateGas(params []json.RawMessage) (any, error) {
+	var msg struct {
+		Data  hexutil.Bytes `json:"data"`
+		Input hexutil.Bytes `json:"input"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &msg) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid call"}
+	}
+	if len(msg.Data) > 0 || len(msg.Input) > 0 {
+		return hexutil.Uint64(gasContract), nil
+	}
+	return hexutil.Uint64(gasTransfer), nil
+}
+
+func (s *Server) traceTransaction(params []json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.paramTransaction(params)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, &rpcError{errorCodeExecution, "transaction not found"}
+	}
+	return traceJSON(tx), nil
+}
+
+func (s *Server) traceBlockByNumber(params []json.RawMessage) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, err := s.paramBlock(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, &rpcError{errorCodeExecution, "block not found"}
+	}
+	traces := make([]map[string]any, len(b.Tx))
+	for i, h := range b.Tx {
+		traces[i] = map[string]any{
+			"txHash": h,
+			"result": traceJSON(s.txs[h]),
+		}
+	}
+	return traces, nil
+}
+
+func (s *Server) paramBlock(params []json.RawMessage, i int) (*Block, error) {
+	var tag string
+	if len(params) > i && json.Unmarshal(params[i], &tag) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid block number"}
+	}
+	return s.blockByTag(tag, "latest")
+}
+
+func (s *Server) blockByTag(tag, fallback string) (*Block, error) {
+	if tag == "" {
+		tag = fallback
+	}
+	switch tag {
+	case "latest", "safe", "finalized", "pending":
+		return s.blocks[len(s.blocks)-1], nil
+	case "earliest":
+		return s.blocks[0], nil
+	}
+	num, err := hexutil.DecodeUint64(tag)
+	if err != nil {
+		return nil, &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+	if num >= uint64(len(s.blocks)) {
+		return nil, nil
+	}
+	return s.blocks[num], nil
+}
+
+func (s *Server) paramTransaction(params []json.RawMessage) (*Transaction, error) {
+	var hash string
+	if len(params) < 1 || json.Unmarshal(params[0], &hash) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid transaction hash"}
+	}
+	return s.txs[common.HexToHash(hash)], nil
+}
+
+func (s *Server) isToken(address common.Address) bool {
+	for _, tx := range s.txs {
+		for _, l := range tx.Logs {
+			if l.Address == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Server) tokenBalance(token, owner common.Address, b *Block) *big.Int {
+	balance := new(big.Int)
+	for _, blk := range s.blocks[:b.Number+1] {
+		for _, h := range blk.Tx {
+			for _, l := range s.txs[h].Logs {
+				if l.Address != token || l.Topics[0] != topicTransfer {
+					continue
+				}
+				value := new(big.Int).SetBytes(l.Data)
+				if common.BytesToAddress(l.Topics[2].Bytes()) == owner {
+					balance.Add(balance, value)
+				}
+				if common.BytesToAddress(l.Topics[1].Bytes()) == owner {
+					balance.Sub(balance, value)
+				}
+			}
+		}
+	}
+	if balance.Sign() < 0 {
+		balance.SetInt64(0)
+	}
+	return balance
+}
+
+func (s *Server) blockJSON(b *Block, full bool) map[string]any {
+	txs := make([]any, len(b.Tx))
+	for i, h := range b.Tx {
+		if full {
+			txs[i] = s.transactionJSON(s.txs[h])
+		} else {
+			txs[i] = h
+		}
+	}
+	return map[string]any{
+		"hash":         b.Hash.Hex(),
+		"number":       hexutil.Uint64(b.Number),
+		"parentHash":   b.Previous.Hex(),
+		"timestamp":    hexutil.Uint64(b.Time.Unix()),
+		"transactions": txs,
+	}
+}
+
+func (s *Server) transactionJSON(tx *Transaction) map[string]any {
+	t := map[string]any{
+		"hash":             tx.Hash,
+		"chainId":          hexutil.Uint64(s.ChainID()),
+		"from":             tx.From,
+		"to":               tx.To,
+		"value":            (*hexutil.Big)(tx.Value),
+		"input":            hexutil.Bytes(tx.Input),
+		"nonce":            hexutil.Uint64(tx.Nonce),
+		"gas":              hexutil.Uint64(tx.Gas),
+		"gasPrice":         hexutil.Uint64(tx.GasPrice),
+		"type":             hexutil.Uint64(types.LegacyTxType),
+		"blockHash":        nil,
+		"blockNumber":      nil,
+		"transactionIndex": nil,
+	}
+	if tx.block != nil {
+		t["blockHash"] = tx.block.Hash
+		t["blockNumber"] = hexutil.Uint64(tx.block.Number)
+		t["transactionIndex"] = hexutil.Uint64(tx.index)
+	}
+	return t
+}
+
+// traceJSON is the callTracer result, all transactions are successful top
+// level calls without any internal calls
+func traceJSON(tx *Transaction) map[string]any {
+	return map[string]any{
+		"type":    "CALL",
+		"from":    tx.From,
+		"to":      tx.To,
+		"value":   (*hexutil.Big)(tx.Value),
+		"gas":     hexutil.Uint64(tx.Gas),
+		"gasUsed": hexutil.Uint64(tx.Gas),
+		"input":   hexutil.Bytes(tx.Input),
+		"output":  "0x",
+		"calls":   []any{},
+	}
+}
+
+// matchTopics follows the eth_getLogs topics filter, each position is either
+// null for any, a topic or a list of topics
+func matchTopics(topics []common.Hash, filter []any) bool {
+	if len(filter) > len(topics) {
+		return false
+	}
+	for i, f := range filter {
+		switch v := f.(type) {
+		case nil:
+		case string:
+			if common.HexToHash(v) != topics[i] {
+				return false
+			}
+		case []any:
+			found := len(v) == 0
+			for _, t := range v {
+				if ts, ok := t.(string); ok && common.HexToHash(ts) == topics[i] {
+					found = true
+				}
+			}
+			if !found {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package rpctest
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSender  = "0xA03A8590BB3A2cA5c747c8b99C63DA399424a055"
+	testSafe    = "0x4f5974a056029EFA7e4B7b51a7Bbcb8FEc6E8970"
+	testToken   = "0xc2132D05D31c914a87C6611C10748AEb04B58e8F"
+	testPrivate = "4cb2d6b7d4c8a0d2d2b5a25d8a0c0b1fd59ed2ad7b8ac0b1c4f9d16f53a4b6a1"
+)
+
+func TestServerDeposits(t *testing.T) {
+	require := require.New(t)
+	s := NewServer(ethereum.ChainPolygon)
+	defer s.Close()
+
+	ether := s.Deposit(testSender, testSafe, big.NewInt(1000))
+	token := s.DepositToken(testToken, testSender, testSafe, big.NewInt(2000))
+	require.Equal([]string{ether, token}, s.Mempool())
+	tx, err := ethereum.RPCGetTransactionByHash(s.URL(), ether)
+	require.Nil(err)
+	require.Equal("", tx.BlockHash)
+	require.Equal(uint64(0), tx.BlockHeight)
+
+	hashes := s.Mine(1)
+	height, err := ethereum.RPCGetBlockHeight(s.URL())
+	require.Nil(err)
+	require.Equal(int64(1), height)
+	block, err := ethereum.RPCGetBlockWithTransactions(s.URL(), height)
+	require.Nil(err)
+	require.Equal(hashes[0], block.Hash)
+	require.Len(block.Tx, 2)
+	require.Equal(ether, block.Tx[0].Hash)
+	require.Equal("0x3e8", block.Tx[0].Value)
+
+	tx, err = ethereum.RPCGetTransactionByHash(s.URL(), ether)
+	require.Nil(err)
+	require.Equal(uint64(1), tx.BlockHeight)
+	trace, err := ethereum.RPCDebugTraceTransactionByHash(s.URL(), ether)
+	require.Nil(err)
+	require.Equal("CALL", trace.Type)
+	require.Equal("0x3e8", trace.Value)
+	traces, err := ethereum.RPCDebugTraceBlockByNumber(s.URL(), height)
+	require.Nil(err)
+	require.Len(traces, 2)
+	require.Equal(common.HexToAddress(testToken), common.HexToAddress(traces[1].Result.To))
+
+	transfers, err := ethereum.GetERC20TransferLogFromBlock(context.Background(), s.URL(), ethereum.ChainPolygon, height)
+	require.Nil(err)
+	require.Len(transfers, 1)
+	require.Equal(token, transfers[0].Hash)
+	require.Equal(testToken, transfers[0].TokenAddress)
+	require.Equal(testSafe, transfers[0].Receiver)
+	require.Equal(int64(2000), transfers[0].Value.Int64())
+
+	balance, err := ethereum.RPCGetAssetBalanceAtBlock(s.URL(), testSafe, ethereum.EthereumEmptyAddress, 1)
+	require.Nil(err)
+	require.Equal(int64(1000), balance.Int64())
+	balance, err = ethereum.RPCGetAssetBalanceAtBlock(s.URL(), testSafe, testToken, 1)
+	require.Nil(err)
+	require.Equal(int64(2000), balance.Int64())
+	balance, err = ethereum.RPCGetAssetBalanceAtBlock(s.URL(), testSafe, testToken, 0)
+	require.Nil(err)
+	require.Equal(int64(0), balance.Int64())
+
+	forks, err := s.Reorg(1, 0)
+	require.Nil(err)
+	require.Len(forks, 0)
+	require.Equal([]string{ether, token}, s.Mempool())
+	err = s.DropTransaction(token)
+	require.Nil(err)
+	forks, err = s.Reorg(0, 1)
+	require.NotNil(err)
+	require.Nil(forks)
+	hashes = s.Mine(1)
+	require.NotEqual(block.Hash, hashes[0])
+	transfers, err = ethereum.GetERC20TransferLogFromBlock(context.Background(), s.URL(), ethereum.ChainPolygon, 1)
+	require.Nil(err)
+	require.Len(transfers, 0)
+}
+
+func TestServerSendRawTransaction(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+	s := NewServer(ethereum.ChainEthereum)
+	defer s.Close()
+
+	key, err := crypto.HexToECDSA(testPrivate)
+	require.Nil(err)
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	client, err := ethclient.Dial(s.URL())
+	require.Nil(err)
+	defer client.Close()
+
+	chainId, err := client.ChainID(ctx)
+	require.Nil(err)
+	require.Equal(int64(1), chainId.Int64())
+	nonce, err := client.PendingNonceAt(ctx, from)
+	require.Nil(err)
+	require.Equal(uint64(0), nonce)
+	price, err := client.SuggestGasPrice(ctx)
+	require.Nil(err)
+	require.Equal(s.GasPrice(), price.Uint64())
+
+	to := common.HexToAddress(testSafe)
+	signer := types.LatestSignerForChainID(chainId)
+	tx, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    nonce,
+		To:       &to,
+		Value:    big.NewInt(5000),
+		Gas:      gasTransfer,
+		GasPrice: price,
+	})
+	require.Nil(err)
+	err = client.SendTransaction(ctx, tx)
+	require.Nil(err)
+	err = client.SendTransaction(ctx, tx)
+	require.Nil(err)
+	nonce, err = client.PendingNonceAt(ctx, from)
+	require.Nil(err)
+	require.Equal(uint64(1), nonce)
+
+	stale, err := types.SignNewTx(key, signer, &types.LegacyTx{
+		Nonce:    0,
+		To:       &to,
+		Value:    big.NewInt(1),
+		Gas:      gasTransfer,
+		GasPrice: price,
+	})
+	require.Nil(err)
+	err = client.SendTransaction(ctx, stale)
+	require.NotNil(err)
+	require.Contains(err.Error(), "nonce too low")
+
+	s.Mine(1)
+	receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+	require.Nil(err)
+	require.Equal(types.ReceiptStatusSuccessful, receipt.Status)
+	require.Equal(uint64(1), receipt.BlockNumber.Uint64())
+	balance, err := client.BalanceAt(ctx, to, nil)
+	require.Nil(err)
+	require.Equal(int64(5000), balance.Int64())
+}
@@ -1,10 +1,12 @@
 package ethereum
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"slices"
 	"strings"
 
 	mc "github.com/MixinNetwork/mixin/common"
@@ -97,6 +99,21 @@ func CreateTransaction(ctx context.Context, typ int, chainID int64, id, safeAddr
 	return tx, nil
 }
 
+// EstimateTransactionGas returns a rough upper bound of the gas used by the
+// observer to execute the safe transaction with the outputs
+func EstimateTransactionGas(typ int, outputs int) uint64 {
+	switch typ {
+	case TypeETHTx:
+		return 100000
+	case TypeERC20Tx:
+		return 130000
+	case TypeMultiSendTx:
+		return 100000 + uint64(outputs)*60000
+	default:
+		panic(typ)
+	}
+}
+
 func CreateMultiSendTransaction(ctx context.Context, chainID int64, id, safeAddress string, outputs []*Output, nonce *big.Int) (*SafeTransaction, error) {
 	if nonce == nil {
 		return nil, fmt.Errorf("Invalid ethereum transaction nonce")
@@ -219,7 +236,7 @@ func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
 	}
 	sigsStr := strings.Split(string(signature), ",")
 
-	signatures := make([][]byte, 3)
+	signatures := make([][]byte, max(3, len(sigsStr)))
 	for i, s := range sigsStr {
 		if s == "" {
 			continue
@@ -328,6 +345,41 @@ func (tx *SafeTransaction) ExecTransaction(ctx context.Context, rpc, key string)
 	return t.Hash().Hex(), nil
 }
 
+// ExecTransactionData returns the execTransaction call data to the safe, so
+// the signed transaction could be sent by any account with any wallet
+func (tx *SafeTransaction) ExecTransactionData() ([]byte, error) {
+	var signature []byte
+	count := 0
+	for _, sig := range tx.Signatures {
+		if sig == nil {
+			continue
+		}
+		signature = append(signature, sig...)
+		count += 1
+	}
+	if count < 2 {
+		return nil, fmt.Errorf("SafeTransaction has insufficient signatures")
+	}
+
+	safeAbi, err := abi.GnosisSafeMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	return safeAbi.Pack(
+		"execTransaction",
+		tx.Destination,
+		tx.Value,
+		tx.Data,
+		tx.Operation,
+		tx.SafeTxGas,
+		tx.BaseGas,
+		tx.GasPrice,
+		tx.GasToken,
+		tx.RefundReceiver,
+		signature,
+	)
+}
+
 func (tx *SafeTransaction) ExtractOutputs() []*Output {
 	outputs, err := tx.parseMultiSendData()
 	if err == nil {
@@ -440,7 +492,7 @@ func (tx *SafeTransaction) buildEnableGuradData(observer string, timelock *big.I
 	}
 	args, err := safeAbi.Pack(
 		"setGuard",
-		common.HexToAddress(EthereumSafeGuardAddress),
+		common.HexToAddress(SafeGuardAddress()),
 	)
 	if err != nil {
 		panic(err)
@@ -459,7 +511,7 @@ func (tx *SafeTransaction) buildEnableGuradData(observer string, timelock *big.I
 	if err != nil {
 		panic(err)
 	}
-	guardSafeData := buildMetaTxData(common.HexToAddress(EthereumSafeGuardAddress), big.NewInt(0), args)
+	guardSafeData := buildMetaTxData(common.HexToAddress(SafeGuardAddress()), big.NewInt(0), args)
 
 	data := []byte{}
 	data = append(data, setGuardData...)
@@ -543,6 +595,48 @@ func ProcessSignature(signature []byte) []byte {
 	return signature
 }
 
+// SetSignature puts the signature to the slot of the sorted owner index,
+// the safe with multiple holders has more than three owners
+func (tx *SafeTransaction) SetSignature(index int, sig []byte) {
+	for len(tx.Signatures) <= index {
+		tx.Signatures = append(tx.Signatures, nil)
+	}
+	tx.Signatures[index] = sig
+}
+
+// MergeSignatures copies the valid signatures of the publics from other to
+// the empty slots of tx, pubs are the sorted owners public keys
+func (tx *SafeTransaction) MergeSignatures(other *SafeTransaction, pubs, publics []string) int {
+	if !bytes.Equal(tx.Message, other.Message) {
+		return 0
+	}
+	var merged int
+	for i, sig := range other.Signatures {
+		if sig == nil || i >= len(pubs) || !slices.Contains(publics, pubs[i]) {
+			continue
+		}
+		if i < len(tx.Signatures) && tx.Signatures[i] != nil {
+			continue
+		}
+		if VerifyMessageSignature(pubs[i], tx.Message, sig) != nil {
+			continue
+		}
+		tx.SetSignature(i, sig)
+		merged = merged + 1
+	}
+	return merged
+}
+
+func CheckTransactionPartiallySignedByHolders(raw string, holders []string, threshold int) bool {
+	var signed int
+	for _, h := range holders {
+		if CheckTransactionPartiallySignedBy(raw, h) {
+			signed = signed + 1
+		}
+	}
+	return signed >= threshold
+}
+
 func CheckTransactionPartiallySignedBy(raw, public string) bool {
 	b, _ := hex.DecodeString(raw)
 	st, _ := UnmarshalSafeTransaction(b)
@@ -0,0 +1,196 @@
+// Package rpctest implements the Mixin network asset API and the polygon
+// factory contract of the bond assets over an in-memory registry, so the
+// keeper and observer flows can run without the Mixin network.
+package rpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	gc "github.com/ethereum/go-ethereum/common"
+	"github.com/gofrs/uuid/v5"
+)
+
+// Asset is the Mixin network asset returned by the /network/assets/:id stand-in,
+// the id is either the asset id or the kernel asset id
+type Asset struct {
+	AssetId   string `json:"asset_id"`
+	MixinId   string `json:"mixin_id"`
+	AssetKey  string `json:"asset_key"`
+	Symbol    string `json:"symbol"`
+	Name      string `json:"name"`
+	Precision uint32 `json:"precision"`
+	ChainId   string `json:"chain_id"`
+}
+
+// Server answers the Mixin asset API and the polygon factory contract, the
+// bond assets are registered once the safe approved by the keeper
+type Server struct {
+	mutex  sync.Mutex
+	assets map[string]*Asset
+	bonds  map[gc.Address]string
+	server *httptest.Server
+}
+
+func NewServer() *Server {
+	r := &Server{
+		assets: make(map[string]*Asset),
+		bonds:  make(map[gc.Address]string),
+	}
+	for _, a := range []*Asset{{
+		AssetId:   common.SafeBitcoinChainId,
+		AssetKey:  common.SafeBitcoinChainId,
+		Symbol:    "BTC",
+		Name:      "Bitcoin",
+		Precision: 8,
+		ChainId:   common.SafeBitcoinChainId,
+	}, {
+		AssetId:   common.SafeLitecoinChainId,
+		AssetKey:  common.SafeLitecoinChainId,
+		Symbol:    "LTC",
+		Name:      "Litecoin",
+		Precision: 8,
+		ChainId:   common.SafeLitecoinChainId,
+	}, {
+		AssetId:   common.SafeEthereumChainId,
+		AssetKey:  "0x0000000000000000000000000000000000000000",
+		Symbol:    "ETH",
+		Name:      "Ether",
+		Precision: 18,
+		ChainId:   common.SafeEthereumChainId,
+	}, {
+		AssetId:   common.SafePolygonChainId,
+		AssetKey:  "0x0000000000000000000000000000000000000000",
+		Symbol:    "POL",
+		Name:      "Polygon",
+		Precision: 18,
+		ChainId:   common.SafePolygonChainId,
+	}, {
+		AssetId:   common.SafeSolanaChainId,
+		AssetKey:  solana.SolanaEmptyAddress,
+		Symbol:    "SOL",
+		Name:      "Solana",
+		Precision: solana.ValuePrecision,
+		ChainId:   common.SafeSolanaChainId,
+	}} {
+		r.RegisterAsset(a)
+	}
+	r.server = httptest.NewServer(r)
+	return r
+}
+
+func (r *Server) URL() string {
+	return r.server.URL
+}
+
+func (r *Server) Close() {
+	r.server.Close()
+}
+
+func (r *Server) RegisterAsset(a *Asset) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if a.MixinId == "" {
+		a.MixinId = crypto.Sha256Hash([]byte(a.AssetId)).String()
+	}
+	r.assets[a.AssetId] = a
+}
+
+func (r *Server) ReadAsset(id string) *Asset {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if a := r.assets[id]; a != nil {
+		return a
+	}
+	// the asset API also accepts the kernel asset id
+	for _, a := range r.assets {
+		if a.MixinId == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// RegisterBond deploys the bond asset of the safe holder in the factory, and
+// returns the bond asset id, the same as the keeper getBondAssetId
+func (r *Server) RegisterBond(entry, chainAssetId, holder string) string {
+	chain := r.ReadAsset(chainAssetId)
+	if chain == nil {
+		panic(chainAssetId)
+	}
+	addr := abi.GetFactoryAssetAddress(entry, chainAssetId, chain.Symbol, chain.Name, holder)
+	key := strings.ToLower(addr.String())
+	id := ethereum.GenerateAssetId(common.SafeChainPolygon, key)
+	r.RegisterAsset(&Asset{
+		AssetId:   id,
+		AssetKey:  key,
+		Symbol:    "safe" + chain.Symbol,
+		Name:      chain.Name + " @ Mixin Safe",
+		Precision: 18,
+		ChainId:   common.SafePolygonChainId,
+	})
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bonds[addr] = chainAssetId
+	return id
+}
+
+// CallFactory handles the eth_call of the factory assets(address) method,
+// which returns the chain asset id of a deployed bond asset, otherwise zero
+func (r *Server) CallFactory(data []byte) ([]byte, error) {
+	fabi, err := abi.FactoryContractMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	method := fabi.Methods["assets"]
+	if len(data) < 4 || !bytes.Equal(data[:4], method.ID) {
+		return nil, fmt.Errorf("factory method %x not supported", data)
+	}
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, err
+	}
+	addr := args[0].(gc.Address)
+
+	r.mutex.Lock()
+	chainAssetId := r.bonds[addr]
+	r.mutex.Unlock()
+
+	id := new(big.Int)
+	if chainAssetId != "" {
+		id.SetBytes(uuid.Must(uuid.FromString(chainAssetId)).Bytes())
+	}
+	return method.Outputs.Pack(id)
+}
+
+func (r *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	id, found := strings.CutPrefix(req.URL.Path, "/network/assets/")
+	if !found {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	a := r.ReadAsset(id)
+	if a == nil {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"error": map[string]any{"status": 404, "code": 10404, "description": "not found"},
+		})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": a})
+}
@@ -0,0 +1,159 @@
+package solana
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"slices"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+)
+
+// the Mixin Safe program is a Squads like multisig program with its source in
+// apps/solana/program, each safe has a config account derived from the create
+// key, and a vault account derived from the config to hold SOL and SPL tokens
+//
+// the config members are the holders, the signer and the observer, and the
+// program requires two votes of the holders quorum, the signer and the
+// observer, the same as the bitcoin safe script. The holders signatures make
+// one vote only when the threshold met, all signatures are verified by the
+// preceding ed25519 program instruction, and the observer signature is only
+// counted after the time lock since the last execution
+//
+// the program doesn't use the durable nonce to order the safe transactions,
+// because the nonce account belongs to the observer fee payer, instead the
+// config nonce in each transfer prevents replay, and the durable nonce only
+// keeps the Solana transaction from expiring before broadcast
+
+const (
+	seedConfig = "safe"
+	seedVault  = "vault"
+)
+
+type SafeAccount struct {
+	Sequence uint32
+	Address  string
+	Config   string
+	TxHash   string
+}
+
+func (sa *SafeAccount) Marshal() []byte {
+	enc := mc.NewEncoder()
+	enc.WriteUint32(sa.Sequence)
+	bitcoin.WriteBytes(enc, []byte(sa.Address))
+	bitcoin.WriteBytes(enc, []byte(sa.Config))
+	bitcoin.WriteBytes(enc, []byte(sa.TxHash))
+	return enc.Bytes()
+}
+
+func UnmarshalSafeAccount(extra []byte) (*SafeAccount, error) {
+	dec := mc.NewDecoder(extra)
+	sequence, err := dec.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	config, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SafeAccount{
+		Sequence: sequence,
+		Address:  string(addr),
+		Config:   string(config),
+		TxHash:   string(hash),
+	}, nil
+}
+
+// BuildSafeAccountWithHolders derives the safe config and vault addresses
+// from the request id, and returns the create safe transaction which must be
+// signed by the holders threshold and the signer before the observer deploys
+// it, the holders alone can't create or spend the safe whatever the threshold
+func BuildSafeAccountWithHolders(holders []string, threshold int, signer, observer, rid string, lock time.Duration) (*SafeAccount, *SafeTransaction, error) {
+	if lock < TimeLockMinimum || lock > TimeLockMaximum {
+		return nil, nil, fmt.Errorf("time lock out of range %s", lock.String())
+	}
+	if threshold < 1 || threshold > len(holders) || len(holders) > 16 {
+		return nil, nil, fmt.Errorf("invalid holders threshold %d/%d", threshold, len(holders))
+	}
+	owners := GetSafeOwnersWithHolders(holders, signer, observer)
+	for _, pub := range owners {
+		err := VerifyHolderKey(pub)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	sequence := lock / time.Hour
+
+	createKey := sha256.Sum256([]byte(rid))
+	config, _ := FindProgramAddress([][]byte{[]byte(seedConfig), createKey[:]}, SafeProgramId())
+	vault := GetSafeVaultAddress(config)
+
+	data := createKey[:]
+	data = append(data, byte(threshold), byte(len(holders)))
+	for _, pub := range owners {
+		key, _ := PublicKeyFromHex(pub)
+		data = append(data, key[:]...)
+	}
+	data = binary.LittleEndian.AppendUint64(data, uint64(lock/time.Second))
+
+	t := &SafeTransaction{
+		Type:        TypeCreateSafe,
+		SafeAddress: vault.String(),
+		Config:      config.String(),
+		Data:        data,
+		Signatures:  make([][]byte, len(owners)),
+	}
+	t.Message = t.GetTransactionHash()
+	t.TxHash = t.Hash(rid)
+	err := t.CheckSize(threshold + 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &SafeAccount{
+		Sequence: uint32(sequence),
+		Address:  vault.String(),
+		Config:   config.String(),
+		TxHash:   t.TxHash,
+	}, t, nil
+}
+
+func GetSafeVaultAddress(config PublicKey) PublicKey {
+	vault, _ := FindProgramAddress([][]byte{[]byte(seedVault), config[:]}, SafeProgramId())
+	return vault
+}
+
+// GetSafeOwnersWithHolders returns the safe members in the config order,
+// which is also the order of the safe transaction signatures
+func GetSafeOwnersWithHolders(holders []string, signer, observer string) []string {
+	return append(slices.Clone(holders), signer, observer)
+}
+
+// ParseCreateSafeData returns the holders threshold, all owners and the time
+// lock from the create safe transaction data
+func ParseCreateSafeData(data []byte) (int, []string, time.Duration, error) {
+	if len(data) < 34 {
+		return 0, nil, 0, fmt.Errorf("invalid create safe data %x", data)
+	}
+	threshold, count := int(data[32]), int(data[33])
+	if len(data) != 34+(count+2)*32+8 {
+		return 0, nil, 0, fmt.Errorf("invalid create safe data %x", data)
+	}
+	var owners []string
+	for i := 0; i < count+2; i++ {
+		offset := 34 + i*32
+		owners = append(owners, PublicKey(data[offset:offset+32]).Hex())
+	}
+	lock := binary.LittleEndian.Uint64(data[len(data)-8:])
+	return threshold, owners, time.Duration(lock) * time.Second, nil
+}
@@ -0,0 +1,272 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"time"
+
+	"filippo.io/edwards25519"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	ChainSolana = 8
+
+	ValuePrecision = 9
+	ValueDust      = 1000000
+
+	TimeLockMinimum = time.Hour * 1
+	TimeLockMaximum = time.Hour * 24 * 365
+
+	// the rough slot time, the finalized commitment is about 32 slots behind
+	BlockDuration = 400 * time.Millisecond
+
+	SolanaEmptyAddress = "11111111111111111111111111111111"
+
+	NetworkMainnet = "mainnet"
+	NetworkTestnet = "testnet"
+
+	solanaMainnetChainId = "64692c23-8971-4cf4-84a7-4dd1271dd887"
+)
+
+var (
+	SystemProgramId           = MustPublicKeyFromBase58("11111111111111111111111111111111")
+	TokenProgramId            = MustPublicKeyFromBase58("TokenkegQfeZyiNwAJbNbGKPFXCWuBvf9Ss623VQ5DA")
+	AssociatedTokenProgramId  = MustPublicKeyFromBase58("ATokenGPvbdGVxr1b2hvZbsiqW5xWH25efTNsLJA8knL")
+	Ed25519ProgramId          = MustPublicKeyFromBase58("Ed25519SigVerify111111111111111111111111111")
+	SysvarRecentBlockhashesId = MustPublicKeyFromBase58("SysvarRecentB1ockHashes11111111111111111111")
+	SysvarInstructionsId      = MustPublicKeyFromBase58("Sysvar1nstructions1111111111111111111111111")
+	TokenMetadataProgramId    = MustPublicKeyFromBase58("metaqbxxUerdq28cj1RbAWkYQm3ybzjb6a8bt518x1s")
+)
+
+var (
+	network       = NetworkMainnet
+	safeProgramId PublicKey
+)
+
+// SetNetwork switches the SOL chain asset id to the testnet one, the
+// addresses are the same for all Solana clusters
+func SetNetwork(name string) {
+	switch name {
+	case NetworkMainnet, NetworkTestnet:
+	default:
+		panic(name)
+	}
+	network = name
+}
+
+// SetSafeProgram sets the deployed Mixin Safe multisig program, all safe
+// account addresses are derived from it and the program must be the same
+// for all keeper and observer nodes
+func SetSafeProgram(address string) {
+	pub, err := PublicKeyFromBase58(address)
+	if err != nil {
+		panic(address)
+	}
+	safeProgramId = pub
+}
+
+func SafeProgramId() PublicKey {
+	if safeProgramId.IsZero() {
+		panic("solana safe program not set")
+	}
+	return safeProgramId
+}
+
+func GetMixinChainID() string {
+	if network == NetworkTestnet {
+		return ethereum.BuildChainAssetId(solanaMainnetChainId, network)
+	}
+	return solanaMainnetChainId
+}
+
+// GenerateAssetId returns the Mixin asset id of the SPL token mint
+func GenerateAssetId(mint string) string {
+	if mint == SolanaEmptyAddress {
+		panic(mint)
+	}
+	_, err := PublicKeyFromBase58(mint)
+	if err != nil {
+		panic(mint)
+	}
+	return ethereum.BuildChainAssetId(GetMixinChainID(), mint)
+}
+
+type Asset struct {
+	Address  string
+	Id       string
+	Symbol   string
+	Name     string
+	Decimals uint32
+	Chain    byte
+}
+
+type PublicKey [32]byte
+
+func PublicKeyFromBase58(s string) (PublicKey, error) {
+	var pub PublicKey
+	b := base58.Decode(s)
+	if len(b) != len(pub) || base58.Encode(b) != s {
+		return pub, fmt.Errorf("invalid solana address %s", s)
+	}
+	copy(pub[:], b)
+	return pub, nil
+}
+
+func MustPublicKeyFromBase58(s string) PublicKey {
+	pub, err := PublicKeyFromBase58(s)
+	if err != nil {
+		panic(err)
+	}
+	return pub
+}
+
+// PublicKeyFromHex parses the hex encoded ed25519 public key, which is the
+// key format of the holder, signer and observer in safe requests
+func PublicKeyFromHex(public string) (PublicKey, error) {
+	var pub PublicKey
+	b, err := hex.DecodeString(public)
+	if err != nil || len(b) != len(pub) {
+		return pub, fmt.Errorf("invalid solana public key %s", public)
+	}
+	copy(pub[:], b)
+	return pub, nil
+}
+
+func (pub PublicKey) String() string {
+	return base58.Encode(pub[:])
+}
+
+func (pub PublicKey) Hex() string {
+	return hex.EncodeToString(pub[:])
+}
+
+func (pub PublicKey) IsZero() bool {
+	return pub == PublicKey{}
+}
+
+func (pub PublicKey) IsOnCurve() bool {
+	_, err := new(edwards25519.Point).SetBytes(pub[:])
+	return err == nil
+}
+
+// FindProgramAddress returns the off curve program derived address of the
+// seeds with the highest bump seed
+func FindProgramAddress(seeds [][]byte, program PublicKey) (PublicKey, byte) {
+	for bump := 255; bump >= 0; bump-- {
+		h := sha256.New()
+		for _, s := range seeds {
+			if len(s) > 32 {
+				panic(hex.EncodeToString(s))
+			}
+			h.Write(s)
+		}
+		h.Write([]byte{byte(bump)})
+		h.Write(program[:])
+		h.Write([]byte("ProgramDerivedAddress"))
+		var pda PublicKey
+		copy(pda[:], h.Sum(nil))
+		if !pda.IsOnCurve() {
+			return pda, byte(bump)
+		}
+	}
+	panic(program.String())
+}
+
+// FindAssociatedTokenAddress returns the SPL token account of the owner
+func FindAssociatedTokenAddress(owner, mint PublicKey) PublicKey {
+	seeds := [][]byte{owner[:], TokenProgramId[:], mint[:]}
+	ata, _ := FindProgramAddress(seeds, AssociatedTokenProgramId)
+	return ata
+}
+
+func VerifyHolderKey(public string) error {
+	pub, err := PublicKeyFromHex(public)
+	if err != nil {
+		return err
+	}
+	if !pub.IsOnCurve() {
+		return fmt.Errorf("invalid solana public key %s", public)
+	}
+	return nil
+}
+
+func VerifyMessageSignature(public string, msg, sig []byte) error {
+	pub, err := PublicKeyFromHex(public)
+	if err != nil {
+		panic(public)
+	}
+	if len(sig) != ed25519.SignatureSize || !ed25519.Verify(pub[:], msg, sig) {
+		return fmt.Errorf("ed25519.Verify(%s, %x, %x)", public, msg, sig)
+	}
+	return nil
+}
+
+func NormalizeAddress(addr string) string {
+	pub, err := PublicKeyFromBase58(addr)
+	if err != nil {
+		return ""
+	}
+	return pub.String()
+}
+
+func ParseAmount(amount string, decimals int32) uint64 {
+	amt, err := decimal.NewFromString(amount)
+	if err != nil {
+		panic(amount)
+	}
+	amt = amt.Mul(decimal.New(1, decimals))
+	if !amt.IsInteger() || amt.IsNegative() || amt.BigInt().Cmp(new(big.Int).SetUint64(^uint64(0))) > 0 {
+		panic(amount)
+	}
+	return amt.BigInt().Uint64()
+}
+
+func UnitAmount(amount uint64, decimals int32) string {
+	amt := decimal.NewFromBigInt(new(big.Int).SetUint64(amount), -decimals)
+	return amt.String()
+}
+
+// CheckFinalization returns true when the slot is rooted, the observer only
+// reads finalized blocks so one confirmation is enough
+func CheckFinalization(num uint64) bool {
+	return num >= 1
+}
+
+func encodeCompactU16(b []byte, n int) []byte {
+	if n < 0 || n > 0xffff {
+		panic(n)
+	}
+	for {
+		elem := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, elem)
+		}
+		b = append(b, elem|0x80)
+	}
+}
+
+func decodeCompactU16(b []byte) (int, int, error) {
+	var n int
+	for i := 0; i < 3; i++ {
+		if i >= len(b) {
+			return 0, 0, fmt.Errorf("invalid compact u16 %x", b)
+		}
+		n |= int(b[i]&0x7f) << (i * 7)
+		if b[i]&0x80 == 0 {
+			return n, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid compact u16 %x", b)
+}
+
+func appendUint64(b []byte, n uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, n)
+}
@@ -0,0 +1,341 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+// https://solana.com/docs/core/transactions
+// a legacy transaction is the compact array of signatures followed by the
+// message, and the message is signed by all the required signers
+
+const (
+	TransactionSizeLimit = 1232
+	signatureSize        = ed25519.SignatureSize
+)
+
+type Hash [32]byte
+
+func HashFromBase58(s string) (Hash, error) {
+	pub, err := PublicKeyFromBase58(s)
+	return Hash(pub), err
+}
+
+func (h Hash) String() string {
+	return base58.Encode(h[:])
+}
+
+type AccountMeta struct {
+	PublicKey  PublicKey
+	IsSigner   bool
+	IsWritable bool
+}
+
+type Instruction struct {
+	ProgramId PublicKey
+	Accounts  []*AccountMeta
+	Data      []byte
+}
+
+type CompiledInstruction struct {
+	ProgramIndex byte
+	Accounts     []byte
+	Data         []byte
+}
+
+type MessageHeader struct {
+	RequiredSignatures       byte
+	ReadonlySignedAccounts   byte
+	ReadonlyUnsignedAccounts byte
+}
+
+type Message struct {
+	Header          MessageHeader
+	AccountKeys     []PublicKey
+	RecentBlockhash Hash
+	Instructions    []*CompiledInstruction
+}
+
+type Transaction struct {
+	Signatures [][]byte
+	Message    *Message
+}
+
+// NewTransaction compiles the instructions to a legacy message, the payer is
+// always the first account, then the accounts are ordered by writable
+// signers, readonly signers, writable and readonly non signers
+func NewTransaction(payer PublicKey, blockhash Hash, instructions []*Instruction) (*Transaction, error) {
+	metas := []*AccountMeta{{PublicKey: payer, IsSigner: true, IsWritable: true}}
+	merge := func(m *AccountMeta) {
+		for _, o := range metas {
+			if o.PublicKey == m.PublicKey {
+				o.IsSigner = o.IsSigner || m.IsSigner
+				o.IsWritable = o.IsWritable || m.IsWritable
+				return
+			}
+		}
+		metas = append(metas, &AccountMeta{PublicKey: m.PublicKey, IsSigner: m.IsSigner, IsWritable: m.IsWritable})
+	}
+	for _, ix := range instructions {
+		for _, a := range ix.Accounts {
+			merge(a)
+		}
+		merge(&AccountMeta{PublicKey: ix.ProgramId})
+	}
+
+	var keys []PublicKey
+	var header MessageHeader
+	for _, group := range [][2]bool{{true, true}, {true, false}, {false, true}, {false, false}} {
+		for _, m := range metas {
+			if m.IsSigner != group[0] || m.IsWritable != group[1] {
+				continue
+			}
+			keys = append(keys, m.PublicKey)
+			switch {
+			case m.IsSigner && m.IsWritable:
+				header.RequiredSignatures += 1
+			case m.IsSigner:
+				header.RequiredSignatures += 1
+				header.ReadonlySignedAccounts += 1
+			case !m.IsWritable:
+				header.ReadonlyUnsignedAccounts += 1
+			}
+		}
+	}
+	if len(keys) > 256 {
+		return nil, fmt.Errorf("too many accounts %d", len(keys))
+	}
+	index := func(pub PublicKey) byte {
+		for i, k := range keys {
+			if k == pub {
+				return byte(i)
+			}
+		}
+		panic(pub.String())
+	}
+
+	msg := &Message{
+		Header:          header,
+		AccountKeys:     keys,
+		RecentBlockhash: blockhash,
+	}
+	for _, ix := range instructions {
+		ci := &CompiledInstruction{
+			ProgramIndex: index(ix.ProgramId),
+			Data:         ix.Data,
+		}
+		for _, a := range ix.Accounts {
+			ci.Accounts = append(ci.Accounts, index(a.PublicKey))
+		}
+		msg.Instructions = append(msg.Instructions, ci)
+	}
+	tx := &Transaction{
+		Signatures: make([][]byte, header.RequiredSignatures),
+		Message:    msg,
+	}
+	if len(tx.Serialize()) > TransactionSizeLimit {
+		return nil, fmt.Errorf("transaction too large %d", len(tx.Serialize()))
+	}
+	return tx, nil
+}
+
+func (m *Message) Serialize() []byte {
+	b := []byte{m.Header.RequiredSignatures, m.Header.ReadonlySignedAccounts, m.Header.ReadonlyUnsignedAccounts}
+	b = encodeCompactU16(b, len(m.AccountKeys))
+	for _, k := range m.AccountKeys {
+		b = append(b, k[:]...)
+	}
+	b = append(b, m.RecentBlockhash[:]...)
+	b = encodeCompactU16(b, len(m.Instructions))
+	for _, ix := range m.Instructions {
+		b = append(b, ix.ProgramIndex)
+		b = encodeCompactU16(b, len(ix.Accounts))
+		b = append(b, ix.Accounts...)
+		b = encodeCompactU16(b, len(ix.Data))
+		b = append(b, ix.Data...)
+	}
+	return b
+}
+
+func (m *Message) IsWritable(i int) bool {
+	h := m.Header
+	if i < int(h.RequiredSignatures) {
+		return i < int(h.RequiredSignatures-h.ReadonlySignedAccounts)
+	}
+	return i < len(m.AccountKeys)-int(h.ReadonlyUnsignedAccounts)
+}
+
+func (m *Message) Signers() []PublicKey {
+	return m.AccountKeys[:m.Header.RequiredSignatures]
+}
+
+func (tx *Transaction) Serialize() []byte {
+	b := encodeCompactU16(nil, len(tx.Signatures))
+	for _, sig := range tx.Signatures {
+		if len(sig) == 0 {
+			sig = make([]byte, signatureSize)
+		}
+		b = append(b, sig...)
+	}
+	return append(b, tx.Message.Serialize()...)
+}
+
+// Hash is the transaction id, which is the first signature of the payer
+func (tx *Transaction) Hash() string {
+	if len(tx.Signatures) == 0 || len(tx.Signatures[0]) == 0 {
+		return ""
+	}
+	return EncodeSignature(tx.Signatures[0])
+}
+
+func EncodeSignature(sig []byte) string {
+	return base58.Encode(sig)
+}
+
+func DecodeSignature(s string) ([]byte, error) {
+	sig := base58.Decode(s)
+	if len(sig) != ed25519.SignatureSize || base58.Encode(sig) != s {
+		return nil, fmt.Errorf("invalid solana signature %s", s)
+	}
+	return sig, nil
+}
+
+func (tx *Transaction) Sign(key ed25519.PrivateKey) error {
+	var pub PublicKey
+	copy(pub[:], key.Public().(ed25519.PublicKey))
+	for i, k := range tx.Message.Signers() {
+		if k != pub {
+			continue
+		}
+		tx.Signatures[i] = ed25519.Sign(key, tx.Message.Serialize())
+		return nil
+	}
+	return fmt.Errorf("invalid transaction signer %s", pub)
+}
+
+func (tx *Transaction) VerifySignatures() error {
+	msg := tx.Message.Serialize()
+	signers := tx.Message.Signers()
+	if len(tx.Signatures) != len(signers) {
+		return fmt.Errorf("invalid signatures count %d %d", len(tx.Signatures), len(signers))
+	}
+	for i, k := range signers {
+		if !ed25519.Verify(k[:], msg, tx.Signatures[i]) {
+			return fmt.Errorf("invalid signature of %s", k)
+		}
+	}
+	return nil
+}
+
+func UnmarshalTransaction(b []byte) (*Transaction, error) {
+	r := &reader{b: b}
+	count, err := r.compact()
+	if err != nil {
+		return nil, err
+	}
+	tx := &Transaction{Message: &Message{}}
+	for i := 0; i < count; i++ {
+		sig, err := r.next(signatureSize)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(sig, make([]byte, signatureSize)) {
+			sig = nil
+		}
+		tx.Signatures = append(tx.Signatures, sig)
+	}
+	header, err := r.next(3)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x80 != 0 {
+		return nil, fmt.Errorf("versioned transaction not supported")
+	}
+	m := tx.Message
+	m.Header = MessageHeader{header[0], header[1], header[2]}
+	if int(m.Header.RequiredSignatures) != count {
+		return nil, fmt.Errorf("invalid signatures count %d %d", count, m.Header.RequiredSignatures)
+	}
+	count, err = r.compact()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		k, err := r.next(32)
+		if err != nil {
+			return nil, err
+		}
+		m.AccountKeys = append(m.AccountKeys, PublicKey(k))
+	}
+	if len(m.AccountKeys) < int(m.Header.RequiredSignatures) {
+		return nil, fmt.Errorf("invalid account keys count %d", len(m.AccountKeys))
+	}
+	hash, err := r.next(32)
+	if err != nil {
+		return nil, err
+	}
+	m.RecentBlockhash = Hash(hash)
+	count, err = r.compact()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < count; i++ {
+		pi, err := r.next(1)
+		if err != nil {
+			return nil, err
+		}
+		ix := &CompiledInstruction{ProgramIndex: pi[0]}
+		n, err := r.compact()
+		if err != nil {
+			return nil, err
+		}
+		ix.Accounts, err = r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		n, err = r.compact()
+		if err != nil {
+			return nil, err
+		}
+		ix.Data, err = r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range append([]byte{ix.ProgramIndex}, ix.Accounts...) {
+			if int(a) >= len(m.AccountKeys) {
+				return nil, fmt.Errorf("invalid account index %d", a)
+			}
+		}
+		m.Instructions = append(m.Instructions, ix)
+	}
+	if r.offset != len(b) {
+		return nil, fmt.Errorf("transaction trailing data %d", len(b)-r.offset)
+	}
+	return tx, nil
+}
+
+type reader struct {
+	b      []byte
+	offset int
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if r.offset+n > len(r.b) {
+		return nil, fmt.Errorf("transaction too short %d %d", len(r.b), r.offset+n)
+	}
+	b := bytes.Clone(r.b[r.offset : r.offset+n])
+	r.offset += n
+	return b, nil
+}
+
+func (r *reader) compact() (int, error) {
+	n, size, err := decodeCompactU16(r.b[r.offset:])
+	if err != nil {
+		return 0, err
+	}
+	r.offset += size
+	return n, nil
+}
@@ -0,0 +1,130 @@
+use solana_program::{hash::hash, msg, program_error::ProgramError, pubkey::Pubkey};
+
+pub const SEED_CONFIG: &[u8] = b"safe";
+pub const SEED_VAULT: &[u8] = b"vault";
+
+pub const MESSAGE_PREFIX: &[u8] = b"MIXIN:SAFE:SOLANA:";
+
+pub const TYPE_CREATE_SAFE: u8 = 1;
+pub const TYPE_TRANSFER: u8 = 2;
+
+pub const HOLDERS_LIMIT: usize = 16;
+pub const OUTPUTS_LIMIT: usize = 8;
+
+pub const TIME_LOCK_MINIMUM: u64 = 3600;
+pub const TIME_LOCK_MAXIMUM: u64 = 3600 * 24 * 365;
+
+pub const OUTPUT_KIND_NATIVE: u8 = 0;
+pub const OUTPUT_KIND_TOKEN: u8 = 1;
+
+pub const SOL_DECIMALS: u8 = 9;
+
+pub enum SafeInstruction<'a> {
+    /// Creates the safe config, the data is also the signed create safe
+    /// transaction data.
+    ///
+    /// Accounts: payer (signer, writable), config (writable), vault, the
+    /// system program and the instructions sysvar.
+    CreateSafe {
+        data: &'a [u8],
+        create_key: &'a [u8],
+        threshold: u8,
+        holders: Vec<Pubkey>,
+        signer: Pubkey,
+        observer: Pubkey,
+        time_lock: u64,
+    },
+    /// Transfers SOL and SPL tokens from the vault, the nonce must be the
+    /// config nonce, which increases after each execution.
+    ///
+    /// Accounts: payer (signer, writable), config (writable), vault
+    /// (writable), the instructions sysvar, the system program and the token
+    /// program, then the receiver of each SOL output, or the mint, the vault
+    /// token account and the receiver token account of each token output.
+    ExecuteSafe { nonce: u64, outputs: Vec<Output> },
+}
+
+pub struct Output {
+    pub kind: u8,
+    pub amount: u64,
+    pub decimals: u8,
+}
+
+/// The anchor style instruction discriminator, the same as the one used by
+/// apps/solana to build the safe instructions.
+pub fn discriminator(name: &str) -> [u8; 8] {
+    let sum = hash(format!("global:{}", name).as_bytes());
+    let mut d = [0u8; 8];
+    d.copy_from_slice(&sum.to_bytes()[..8]);
+    d
+}
+
+impl<'a> SafeInstruction<'a> {
+    pub fn unpack(input: &'a [u8]) -> Result<Self, ProgramError> {
+        if input.len() < 8 {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        let (tag, rest) = input.split_at(8);
+        if tag == discriminator("create_safe") {
+            return Self::unpack_create_safe(rest);
+        }
+        if tag == discriminator("execute_safe") {
+            return Self::unpack_execute_safe(rest);
+        }
+        msg!("safe instruction {:?} not supported", tag);
+        Err(ProgramError::InvalidInstructionData)
+    }
+
+    // create_key (32) | threshold (1) | holders count (1) | holders, signer
+    // and observer (32 each) | time lock seconds (u64 le)
+    fn unpack_create_safe(data: &'a [u8]) -> Result<Self, ProgramError> {
+        if data.len() < 34 {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        let threshold = data[32];
+        let count = data[33] as usize;
+        if data.len() != 34 + (count + 2) * 32 + 8 {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        let mut owners = Vec::with_capacity(count + 2);
+        for i in 0..count + 2 {
+            let offset = 34 + i * 32;
+            owners.push(Pubkey::try_from(&data[offset..offset + 32]).unwrap());
+        }
+        let observer = owners.pop().unwrap();
+        let signer = owners.pop().unwrap();
+        let lock = u64::from_le_bytes(data[data.len() - 8..].try_into().unwrap());
+        Ok(Self::CreateSafe {
+            data,
+            create_key: &data[..32],
+            threshold,
+            holders: owners,
+            signer,
+            observer,
+            time_lock: lock,
+        })
+    }
+
+    // nonce (u64 le) | outputs count (1) | kind (1), amount (u64 le) and
+    // decimals (1) of each output
+    fn unpack_execute_safe(data: &'a [u8]) -> Result<Self, ProgramError> {
+        if data.len() < 9 {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        let nonce = u64::from_le_bytes(data[..8].try_into().unwrap());
+        let count = data[8] as usize;
+        let data = &data[9..];
+        if data.len() != count * 10 {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        let outputs = data
+            .chunks_exact(10)
+            .map(|o| Output {
+                kind: o[0],
+                amount: u64::from_le_bytes(o[1..9].try_into().unwrap()),
+                decimals: o[9],
+            })
+            .collect();
+        Ok(Self::ExecuteSafe { nonce, outputs })
+    }
+}
@@ -0,0 +1,30 @@
+//! The Mixin Safe multisig program of the Solana safe accounts.
+//!
+//! Each safe has a config account derived from the create key, and a vault
+//! account derived from the config to hold SOL and SPL tokens. The config
+//! members are the holders, the signer and the observer, and the program
+//! requires the holders threshold plus one signatures of all members, which
+//! are verified by the preceding ed25519 program instruction. The observer
+//! signature is only counted after the time lock since the last execution.
+//!
+//! The instruction data layouts and the signed digest are the same as the
+//! safe transactions built by apps/solana, and the rpctest package executes
+//! the same rules for the keeper and observer tests.
+
+pub mod instruction;
+pub mod processor;
+pub mod state;
+pub mod verify;
+
+use solana_program::{account_info::AccountInfo, entrypoint::ProgramResult, pubkey::Pubkey};
+
+#[cfg(not(feature = "no-entrypoint"))]
+solana_program::entrypoint!(process_instruction);
+
+pub fn process_instruction(
+    program_id: &Pubkey,
+    accounts: &[AccountInfo],
+    data: &[u8],
+) -> ProgramResult {
+    processor::process(program_id, accounts, data)
+}
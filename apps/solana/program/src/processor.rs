@@ -0,0 +1,281 @@
+use solana_program::{
+    account_info::{next_account_info, AccountInfo},
+    clock::Clock,
+    entrypoint::ProgramResult,
+    hash::hashv,
+    msg,
+    program::{invoke, invoke_signed},
+    program_error::ProgramError,
+    program_pack::Pack,
+    pubkey::Pubkey,
+    rent::Rent,
+    system_instruction, system_program,
+    sysvar::{instructions, Sysvar},
+};
+use spl_associated_token_account::get_associated_token_address;
+
+use crate::{instruction::*, state::SafeConfig, verify::Verified};
+
+pub fn process(program_id: &Pubkey, accounts: &[AccountInfo], data: &[u8]) -> ProgramResult {
+    match SafeInstruction::unpack(data)? {
+        SafeInstruction::CreateSafe {
+            data,
+            create_key,
+            threshold,
+            holders,
+            signer,
+            observer,
+            time_lock,
+        } => {
+            // the create safe transaction takes the nonce 0
+            let safe = SafeConfig {
+                create_key: create_key.try_into().unwrap(),
+                config_bump: 0,
+                vault_bump: 0,
+                threshold,
+                holders,
+                signer,
+                observer,
+                time_lock,
+                nonce: 1,
+                executed_at: 0,
+            };
+            process_create_safe(program_id, accounts, data, safe)
+        }
+        SafeInstruction::ExecuteSafe { nonce, outputs } => {
+            process_execute_safe(program_id, accounts, nonce, &outputs)
+        }
+    }
+}
+
+fn process_create_safe(
+    program_id: &Pubkey,
+    accounts: &[AccountInfo],
+    data: &[u8],
+    mut safe: SafeConfig,
+) -> ProgramResult {
+    let iter = &mut accounts.iter();
+    let payer = next_account_info(iter)?;
+    let config = next_account_info(iter)?;
+    let vault = next_account_info(iter)?;
+    let system = next_account_info(iter)?;
+    let sysvar = next_account_info(iter)?;
+    if !payer.is_signer {
+        return Err(ProgramError::MissingRequiredSignature);
+    }
+    if *system.key != system_program::ID || *sysvar.key != instructions::ID {
+        return Err(ProgramError::IncorrectProgramId);
+    }
+
+    let (pda, config_bump) =
+        Pubkey::find_program_address(&[SEED_CONFIG, &safe.create_key], program_id);
+    let (vault_pda, vault_bump) =
+        Pubkey::find_program_address(&[SEED_VAULT, config.key.as_ref()], program_id);
+    if pda != *config.key || vault_pda != *vault.key {
+        msg!("invalid safe config {} {}", config.key, vault.key);
+        return Err(ProgramError::InvalidSeeds);
+    }
+    if !config.data_is_empty() || *config.owner != system_program::ID {
+        return Err(ProgramError::AccountAlreadyInitialized);
+    }
+    if safe.holders.is_empty() || safe.holders.len() > HOLDERS_LIMIT {
+        return Err(ProgramError::InvalidInstructionData);
+    }
+    if safe.threshold < 1 || safe.threshold as usize > safe.holders.len() {
+        return Err(ProgramError::InvalidInstructionData);
+    }
+    if safe.time_lock < TIME_LOCK_MINIMUM || safe.time_lock > TIME_LOCK_MAXIMUM {
+        return Err(ProgramError::InvalidInstructionData);
+    }
+
+    let digest = safe_transaction_digest(program_id, config.key, TYPE_CREATE_SAFE, data);
+    Verified::load(sysvar)?.check_safe_signatures(&safe, &digest, false)?;
+
+    let seeds: &[&[u8]] = &[SEED_CONFIG, &safe.create_key, &[config_bump]];
+    create_config_account(program_id, payer, config, system, seeds)?;
+    safe.config_bump = config_bump;
+    safe.vault_bump = vault_bump;
+    safe.executed_at = Clock::get()?.unix_timestamp;
+    safe.pack(&mut config.try_borrow_mut_data()?)
+}
+
+// the config address is known before creation, so it may be funded by
+// anyone, then it is allocated and assigned instead of created
+fn create_config_account<'a>(
+    program_id: &Pubkey,
+    payer: &AccountInfo<'a>,
+    config: &AccountInfo<'a>,
+    system: &AccountInfo<'a>,
+    seeds: &[&[u8]],
+) -> ProgramResult {
+    let required = Rent::get()?.minimum_balance(SafeConfig::LEN);
+    let current = config.lamports();
+    if current == 0 {
+        let ix = system_instruction::create_account(
+            payer.key,
+            config.key,
+            required,
+            SafeConfig::LEN as u64,
+            program_id,
+        );
+        return invoke_signed(
+            &ix,
+            &[payer.clone(), config.clone(), system.clone()],
+            &[seeds],
+        );
+    }
+    if current < required {
+        let ix = system_instruction::transfer(payer.key, config.key, required - current);
+        invoke(&ix, &[payer.clone(), config.clone(), system.clone()])?;
+    }
+    let ix = system_instruction::allocate(config.key, SafeConfig::LEN as u64);
+    invoke_signed(&ix, &[config.clone(), system.clone()], &[seeds])?;
+    let ix = system_instruction::assign(config.key, program_id);
+    invoke_signed(&ix, &[config.clone(), system.clone()], &[seeds])
+}
+
+fn process_execute_safe(
+    program_id: &Pubkey,
+    accounts: &[AccountInfo],
+    nonce: u64,
+    outputs: &[Output],
+) -> ProgramResult {
+    let iter = &mut accounts.iter();
+    let payer = next_account_info(iter)?;
+    let config = next_account_info(iter)?;
+    let vault = next_account_info(iter)?;
+    let sysvar = next_account_info(iter)?;
+    let system = next_account_info(iter)?;
+    let token = next_account_info(iter)?;
+    if !payer.is_signer {
+        return Err(ProgramError::MissingRequiredSignature);
+    }
+    if *sysvar.key != instructions::ID
+        || *system.key != system_program::ID
+        || *token.key != spl_token::ID
+    {
+        return Err(ProgramError::IncorrectProgramId);
+    }
+    if config.owner != program_id {
+        return Err(ProgramError::IncorrectProgramId);
+    }
+    let mut safe = SafeConfig::unpack(&config.try_borrow_data()?)?;
+    let vault_pda = Pubkey::create_program_address(
+        &[SEED_VAULT, config.key.as_ref(), &[safe.vault_bump]],
+        program_id,
+    )?;
+    if vault_pda != *vault.key {
+        return Err(ProgramError::InvalidSeeds);
+    }
+    // the config nonce orders the safe transactions, the durable nonce of the
+    // observer fee payer only keeps the Solana transaction from expiring
+    if nonce != safe.nonce {
+        msg!("invalid safe nonce {} {}", nonce, safe.nonce);
+        return Err(ProgramError::InvalidArgument);
+    }
+    if outputs.is_empty() || outputs.len() > OUTPUTS_LIMIT {
+        return Err(ProgramError::InvalidInstructionData);
+    }
+
+    // the token outputs commit to the receiver wallet, which is the owner of
+    // the receiver token account
+    let mut payload = nonce.to_le_bytes().to_vec();
+    let mut transfers = Vec::with_capacity(outputs.len());
+    for o in outputs {
+        match o.kind {
+            OUTPUT_KIND_NATIVE => {
+                let receiver = next_account_info(iter)?;
+                if o.decimals != SOL_DECIMALS {
+                    return Err(ProgramError::InvalidInstructionData);
+                }
+                payload.extend_from_slice(system_program::ID.as_ref());
+                payload.extend_from_slice(receiver.key.as_ref());
+                transfers.push((o, receiver, None));
+            }
+            OUTPUT_KIND_TOKEN => {
+                let mint = next_account_info(iter)?;
+                let source = next_account_info(iter)?;
+                let destination = next_account_info(iter)?;
+                if *source.key != get_associated_token_address(vault.key, mint.key) {
+                    msg!("invalid vault token account {}", source.key);
+                    return Err(ProgramError::InvalidAccountData);
+                }
+                if *destination.owner != spl_token::ID {
+                    return Err(ProgramError::IllegalOwner);
+                }
+                let account = spl_token::state::Account::unpack(&destination.try_borrow_data()?)?;
+                if account.mint != *mint.key {
+                    msg!("invalid token account {}", destination.key);
+                    return Err(ProgramError::InvalidAccountData);
+                }
+                payload.extend_from_slice(mint.key.as_ref());
+                payload.extend_from_slice(account.owner.as_ref());
+                transfers.push((o, destination, Some((mint, source))));
+            }
+            _ => return Err(ProgramError::InvalidInstructionData),
+        }
+        payload.extend_from_slice(&o.amount.to_le_bytes());
+        payload.push(o.decimals);
+    }
+
+    let now = Clock::get()?.unix_timestamp;
+    let unlocked = now > safe.executed_at.saturating_add(safe.time_lock as i64);
+    let digest = safe_transaction_digest(program_id, config.key, TYPE_TRANSFER, &payload);
+    Verified::load(sysvar)?.check_safe_signatures(&safe, &digest, unlocked)?;
+
+    let seeds: &[&[u8]] = &[SEED_VAULT, config.key.as_ref(), &[safe.vault_bump]];
+    for (o, receiver, token_accounts) in transfers {
+        match token_accounts {
+            None => {
+                let ix = system_instruction::transfer(vault.key, receiver.key, o.amount);
+                invoke_signed(
+                    &ix,
+                    &[vault.clone(), receiver.clone(), system.clone()],
+                    &[seeds],
+                )?;
+            }
+            Some((mint, source)) => {
+                let ix = spl_token::instruction::transfer_checked(
+                    token.key,
+                    source.key,
+                    mint.key,
+                    receiver.key,
+                    vault.key,
+                    &[],
+                    o.amount,
+                    o.decimals,
+                )?;
+                let infos = [
+                    source.clone(),
+                    mint.clone(),
+                    receiver.clone(),
+                    vault.clone(),
+                    token.clone(),
+                ];
+                invoke_signed(&ix, &infos, &[seeds])?;
+            }
+        }
+    }
+
+    safe.nonce += 1;
+    safe.executed_at = now;
+    safe.pack(&mut config.try_borrow_mut_data()?)
+}
+
+/// The digest signed by all the safe members, the same as the Go
+/// SafeTransaction GetTransactionHash.
+fn safe_transaction_digest(
+    program_id: &Pubkey,
+    config: &Pubkey,
+    typ: u8,
+    payload: &[u8],
+) -> [u8; 32] {
+    hashv(&[
+        MESSAGE_PREFIX,
+        program_id.as_ref(),
+        config.as_ref(),
+        &[typ],
+        payload,
+    ])
+    .to_bytes()
+}
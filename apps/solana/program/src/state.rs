@@ -0,0 +1,80 @@
+use solana_program::{hash::hash, program_error::ProgramError, pubkey::Pubkey};
+
+use crate::instruction::HOLDERS_LIMIT;
+
+/// The safe config account data, the holders are stored in a fixed size
+/// array so the account size never changes.
+pub struct SafeConfig {
+    pub create_key: [u8; 32],
+    pub config_bump: u8,
+    pub vault_bump: u8,
+    pub threshold: u8,
+    pub holders: Vec<Pubkey>,
+    pub signer: Pubkey,
+    pub observer: Pubkey,
+    pub time_lock: u64,
+    pub nonce: u64,
+    pub executed_at: i64,
+}
+
+impl SafeConfig {
+    pub const LEN: usize = 8 + 32 + 4 + HOLDERS_LIMIT * 32 + 32 + 32 + 8 + 8 + 8;
+
+    fn discriminator() -> [u8; 8] {
+        let sum = hash(b"account:SafeConfig");
+        let mut d = [0u8; 8];
+        d.copy_from_slice(&sum.to_bytes()[..8]);
+        d
+    }
+
+    pub fn unpack(data: &[u8]) -> Result<Self, ProgramError> {
+        if data.len() != Self::LEN || data[..8] != Self::discriminator() {
+            return Err(ProgramError::InvalidAccountData);
+        }
+        let count = data[43] as usize;
+        if count == 0 || count > HOLDERS_LIMIT {
+            return Err(ProgramError::InvalidAccountData);
+        }
+        let read_key = |offset: usize| Pubkey::try_from(&data[offset..offset + 32]).unwrap();
+        let read_u64 =
+            |offset: usize| u64::from_le_bytes(data[offset..offset + 8].try_into().unwrap());
+        let holders = (0..count).map(|i| read_key(44 + i * 32)).collect();
+        let offset = 44 + HOLDERS_LIMIT * 32;
+        Ok(Self {
+            create_key: data[8..40].try_into().unwrap(),
+            config_bump: data[40],
+            vault_bump: data[41],
+            threshold: data[42],
+            holders,
+            signer: read_key(offset),
+            observer: read_key(offset + 32),
+            time_lock: read_u64(offset + 64),
+            nonce: read_u64(offset + 72),
+            executed_at: read_u64(offset + 80) as i64,
+        })
+    }
+
+    pub fn pack(&self, data: &mut [u8]) -> Result<(), ProgramError> {
+        if data.len() != Self::LEN || self.holders.is_empty() || self.holders.len() > HOLDERS_LIMIT
+        {
+            return Err(ProgramError::InvalidAccountData);
+        }
+        data.fill(0);
+        data[..8].copy_from_slice(&Self::discriminator());
+        data[8..40].copy_from_slice(&self.create_key);
+        data[40] = self.config_bump;
+        data[41] = self.vault_bump;
+        data[42] = self.threshold;
+        data[43] = self.holders.len() as u8;
+        for (i, h) in self.holders.iter().enumerate() {
+            data[44 + i * 32..76 + i * 32].copy_from_slice(h.as_ref());
+        }
+        let offset = 44 + HOLDERS_LIMIT * 32;
+        data[offset..offset + 32].copy_from_slice(self.signer.as_ref());
+        data[offset + 32..offset + 64].copy_from_slice(self.observer.as_ref());
+        data[offset + 64..offset + 72].copy_from_slice(&self.time_lock.to_le_bytes());
+        data[offset + 72..offset + 80].copy_from_slice(&self.nonce.to_le_bytes());
+        data[offset + 80..offset + 88].copy_from_slice(&self.executed_at.to_le_bytes());
+        Ok(())
+    }
+}
@@ -0,0 +1,164 @@
+use solana_program::{
+    account_info::AccountInfo,
+    ed25519_program, msg,
+    program_error::ProgramError,
+    pubkey::Pubkey,
+    sysvar::instructions::{load_current_index_checked, load_instruction_at_checked},
+};
+
+use crate::state::SafeConfig;
+
+const OFFSETS_SIZE: usize = 14;
+const CURRENT_INSTRUCTION: u16 = u16::MAX;
+const SIGNATURE_SIZE: usize = 64;
+
+/// The public keys and messages verified by the ed25519 program instructions
+/// preceding the current safe instruction in the same transaction.
+pub struct Verified(Vec<(Pubkey, Vec<u8>)>);
+
+impl Verified {
+    pub fn load(sysvar: &AccountInfo) -> Result<Self, ProgramError> {
+        let current = load_current_index_checked(sysvar)?;
+        let mut verified = Vec::new();
+        for i in 0..current {
+            let ix = load_instruction_at_checked(i as usize, sysvar)?;
+            if ix.program_id != ed25519_program::ID {
+                continue;
+            }
+            parse_ed25519_instruction(&ix.data, &mut verified)?;
+        }
+        Ok(Self(verified))
+    }
+
+    fn signed(&self, key: &Pubkey, digest: &[u8]) -> bool {
+        self.0.iter().any(|(k, m)| k == key && m == digest)
+    }
+
+    /// Requires two votes of the holders quorum, the signer and the observer,
+    /// the same as the bitcoin safe script. The holders signatures count as a
+    /// single vote only when the threshold met, and the observer is counted
+    /// only after the time lock.
+    pub fn check_safe_signatures(
+        &self,
+        safe: &SafeConfig,
+        digest: &[u8],
+        unlocked: bool,
+    ) -> Result<(), ProgramError> {
+        let holders = safe
+            .holders
+            .iter()
+            .filter(|h| self.signed(h, digest))
+            .count();
+        let mut votes = 0;
+        if holders >= safe.threshold as usize {
+            votes += 1;
+        }
+        if self.signed(&safe.signer, digest) {
+            votes += 1;
+        }
+        if unlocked && self.signed(&safe.observer, digest) {
+            votes += 1;
+        }
+        if votes < 2 {
+            msg!(
+                "insufficient safe signatures {}/{} {}",
+                holders,
+                safe.threshold,
+                votes
+            );
+            return Err(ProgramError::MissingRequiredSignature);
+        }
+        Ok(())
+    }
+}
+
+// the ed25519 program verified all the signatures before this transaction
+// executed, so only the offsets referring to the instruction itself are read
+fn parse_ed25519_instruction(
+    data: &[u8],
+    verified: &mut Vec<(Pubkey, Vec<u8>)>,
+) -> Result<(), ProgramError> {
+    if data.len() < 2 || data.len() < 2 + data[0] as usize * OFFSETS_SIZE {
+        return Err(ProgramError::InvalidInstructionData);
+    }
+    let read = |offset: u16, size: usize| -> Result<&[u8], ProgramError> {
+        let offset = offset as usize;
+        data.get(offset..offset + size)
+            .ok_or(ProgramError::InvalidInstructionData)
+    };
+    for i in 0..data[0] as usize {
+        let base = 2 + i * OFFSETS_SIZE;
+        let mut offsets = [0u16; 7];
+        for (j, o) in offsets.iter_mut().enumerate() {
+            *o = u16::from_le_bytes([data[base + j * 2], data[base + j * 2 + 1]]);
+        }
+        if offsets[1] != CURRENT_INSTRUCTION
+            || offsets[3] != CURRENT_INSTRUCTION
+            || offsets[6] != CURRENT_INSTRUCTION
+        {
+            return Err(ProgramError::InvalidInstructionData);
+        }
+        read(offsets[0], SIGNATURE_SIZE)?;
+        let key = Pubkey::try_from(read(offsets[2], 32)?).unwrap();
+        let msg = read(offsets[4], offsets[5] as usize)?;
+        verified.push((key, msg.to_vec()));
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn safe(threshold: u8) -> SafeConfig {
+        SafeConfig {
+            create_key: [0; 32],
+            config_bump: 0,
+            vault_bump: 0,
+            threshold,
+            holders: vec![
+                Pubkey::new_unique(),
+                Pubkey::new_unique(),
+                Pubkey::new_unique(),
+            ],
+            signer: Pubkey::new_unique(),
+            observer: Pubkey::new_unique(),
+            time_lock: 0,
+            nonce: 1,
+            executed_at: 0,
+        }
+    }
+
+    fn verified(keys: &[Pubkey], digest: &[u8]) -> Verified {
+        Verified(keys.iter().map(|k| (*k, digest.to_vec())).collect())
+    }
+
+    #[test]
+    fn test_check_safe_signatures() {
+        let digest = [7u8; 32];
+        let safe = safe(2);
+
+        let holders = verified(&safe.holders, &digest);
+        assert!(holders.check_safe_signatures(&safe, &digest, true).is_err());
+        let one = verified(&[safe.holders[0], safe.signer], &digest);
+        assert!(one.check_safe_signatures(&safe, &digest, false).is_err());
+
+        let quorum = verified(&[safe.holders[0], safe.holders[2], safe.signer], &digest);
+        assert!(quorum.check_safe_signatures(&safe, &digest, false).is_ok());
+        assert!(quorum
+            .check_safe_signatures(&safe, &[8u8; 32], false)
+            .is_err());
+
+        let observer = verified(&[safe.holders[0], safe.holders[1], safe.observer], &digest);
+        assert!(observer
+            .check_safe_signatures(&safe, &digest, false)
+            .is_err());
+        assert!(observer.check_safe_signatures(&safe, &digest, true).is_ok());
+
+        let recovery = verified(&[safe.signer, safe.observer], &digest);
+        assert!(recovery
+            .check_safe_signatures(&safe, &digest, false)
+            .is_err());
+        assert!(recovery.check_safe_signatures(&safe, &digest, true).is_ok());
+    }
+}
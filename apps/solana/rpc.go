@@ -0,0 +1,494 @@
+package solana
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	commitmentFinalized = "finalized"
+
+	// the SPL token deposit index is the token account index with the offset,
+	// so it never conflicts with the SOL deposit of the same transaction
+	TokenTransferIndexOffset = 1 << 16
+
+	mintAccountSize         = 82
+	nonceAccountSize        = 80
+	nonceStateInitialized   = 1
+	rpcErrorSlotSkipped     = -32007
+	rpcErrorLongTermStorage = -32009
+)
+
+type RPCBlock struct {
+	Blockhash         string            `json:"blockhash"`
+	PreviousBlockhash string            `json:"previousBlockhash"`
+	ParentSlot        uint64            `json:"parentSlot"`
+	BlockTime         int64             `json:"blockTime"`
+	BlockHeight       uint64            `json:"blockHeight"`
+	Transactions      []*RPCTransaction `json:"transactions"`
+
+	Slot uint64
+	Time time.Time
+}
+
+type RPCTokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	ProgramId     string `json:"programId"`
+	UiTokenAmount struct {
+		Amount   string `json:"amount"`
+		Decimals int    `json:"decimals"`
+	} `json:"uiTokenAmount"`
+}
+
+type RPCTransactionMeta struct {
+	Err               any                `json:"err"`
+	Fee               uint64             `json:"fee"`
+	PreBalances       []uint64           `json:"preBalances"`
+	PostBalances      []uint64           `json:"postBalances"`
+	PreTokenBalances  []*RPCTokenBalance `json:"preTokenBalances"`
+	PostTokenBalances []*RPCTokenBalance `json:"postTokenBalances"`
+	LoadedAddresses   *struct {
+		Writable []string `json:"writable"`
+		Readonly []string `json:"readonly"`
+	} `json:"loadedAddresses"`
+}
+
+type RPCTransaction struct {
+	Slot        uint64              `json:"slot"`
+	BlockTime   int64               `json:"blockTime"`
+	Meta        *RPCTransactionMeta `json:"meta"`
+	Transaction struct {
+		Signatures []string `json:"signatures"`
+		Message    struct {
+			AccountKeys     []string `json:"accountKeys"`
+			RecentBlockhash string   `json:"recentBlockhash"`
+		} `json:"message"`
+	} `json:"transaction"`
+}
+
+type RPCAccount struct {
+	Lamports uint64   `json:"lamports"`
+	Owner    string   `json:"owner"`
+	Data     []string `json:"data"`
+}
+
+type Transfer struct {
+	Hash         string
+	Index        int64
+	TokenAddress string
+	AssetId      string
+	Sender       string
+	Receiver     string
+	Value        *big.Int
+}
+
+func (tx *RPCTransaction) Hash() string {
+	return tx.Transaction.Signatures[0]
+}
+
+// AccountKeys returns the static keys and the keys loaded from lookup
+// tables, which is the account index of the balances in meta
+func (tx *RPCTransaction) AccountKeys() []string {
+	keys := tx.Transaction.Message.AccountKeys
+	if tx.Meta != nil && tx.Meta.LoadedAddresses != nil {
+		keys = append(keys, tx.Meta.LoadedAddresses.Writable...)
+		keys = append(keys, tx.Meta.LoadedAddresses.Readonly...)
+	}
+	return keys
+}
+
+// ExtractTransfers returns all the SOL and SPL token balance increases of
+// the successful transaction, the fee payer is the sender of all transfers
+func ExtractTransfers(tx *RPCTransaction) []*Transfer {
+	if tx.Meta == nil || tx.Meta.Err != nil || len(tx.Transaction.Signatures) == 0 {
+		return nil
+	}
+	keys := tx.AccountKeys()
+	if len(keys) == 0 || len(tx.Meta.PreBalances) != len(tx.Meta.PostBalances) || len(tx.Meta.PostBalances) > len(keys) {
+		return nil
+	}
+	sender := keys[0]
+
+	var transfers []*Transfer
+	for i, post := range tx.Meta.PostBalances {
+		pre := tx.Meta.PreBalances[i]
+		if i == 0 || post <= pre {
+			continue
+		}
+		transfers = append(transfers, &Transfer{
+			Hash:         tx.Hash(),
+			Index:        int64(i),
+			TokenAddress: SolanaEmptyAddress,
+			AssetId:      GetMixinChainID(),
+			Sender:       sender,
+			Receiver:     keys[i],
+			Value:        new(big.Int).SetUint64(post - pre),
+		})
+	}
+
+	pres := make(map[int]*big.Int)
+	for _, b := range tx.Meta.PreTokenBalances {
+		amt, ok := new(big.Int).SetString(b.UiTokenAmount.Amount, 10)
+		if ok {
+			pres[b.AccountIndex] = amt
+		}
+	}
+	for _, b := range tx.Meta.PostTokenBalances {
+		if b.ProgramId != "" && b.ProgramId != TokenProgramId.String() {
+			continue
+		}
+		if NormalizeAddress(b.Mint) != b.Mint || NormalizeAddress(b.Owner) != b.Owner {
+			continue
+		}
+		post, ok := new(big.Int).SetString(b.UiTokenAmount.Amount, 10)
+		if !ok {
+			continue
+		}
+		pre := pres[b.AccountIndex]
+		if pre == nil {
+			pre = big.NewInt(0)
+		}
+		if post.Cmp(pre) <= 0 {
+			continue
+		}
+		transfers = append(transfers, &Transfer{
+			Hash:         tx.Hash(),
+			Index:        int64(b.AccountIndex) + TokenTransferIndexOffset,
+			TokenAddress: b.Mint,
+			AssetId:      GenerateAssetId(b.Mint),
+			Sender:       sender,
+			Receiver:     b.Owner,
+			Value:        new(big.Int).Sub(post, pre),
+		})
+	}
+	return transfers
+}
+
+// VerifyDeposit returns the transfer of the finalized transaction matching
+// the token address, receiver, index and amount, or nil if not found
+func VerifyDeposit(rpc, hash, tokenAddress, receiver string, index int64, amount *big.Int) (*Transfer, *RPCTransaction, error) {
+	tx, err := RPCGetTransaction(rpc, hash)
+	logger.Printf("solana.RPCGetTransaction(%s) => %v %v", hash, tx, err)
+	if err != nil || tx == nil {
+		return nil, nil, fmt.Errorf("malicious solana deposit or node not in sync? %s %v", hash, err)
+	}
+	for _, t := range ExtractTransfers(tx) {
+		if t.TokenAddress == tokenAddress && t.Index == index && t.Receiver == receiver && amount.Cmp(t.Value) == 0 {
+			return t, tx, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+func RPCGetSlot(rpc string) (uint64, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getSlot", []any{
+		map[string]any{"commitment": commitmentFinalized},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var slot uint64
+	err = json.Unmarshal(res, &slot)
+	return slot, err
+}
+
+// RPCGetBlock returns nil if the slot is skipped by the leader
+func RPCGetBlock(rpc string, slot uint64) (*RPCBlock, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getBlock", []any{slot, map[string]any{
+		"commitment":                     commitmentFinalized,
+		"encoding":                       "json",
+		"transactionDetails":             "full",
+		"rewards":                        false,
+		"maxSupportedTransactionVersion": 0,
+	}})
+	if err != nil {
+		if strings.Contains(err.Error(), fmt.Sprint(rpcErrorSlotSkipped)) ||
+			strings.Contains(err.Error(), fmt.Sprint(rpcErrorLongTermStorage)) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var b RPCBlock
+	err = json.Unmarshal(res, &b)
+	if err != nil {
+		return nil, err
+	}
+	b.Slot = slot
+	b.Time = time.Unix(b.BlockTime, 0)
+	for _, tx := range b.Transactions {
+		tx.Slot, tx.BlockTime = slot, b.BlockTime
+	}
+	return &b, nil
+}
+
+// RPCGetTransaction returns nil if the transaction is not finalized
+func RPCGetTransaction(rpc, signature string) (*RPCTransaction, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getTransaction", []any{signature, map[string]any{
+		"commitment":                     commitmentFinalized,
+		"encoding":                       "json",
+		"maxSupportedTransactionVersion": 0,
+	}})
+	if err != nil || string(res) == "null" {
+		return nil, err
+	}
+	var tx RPCTransaction
+	err = json.Unmarshal(res, &tx)
+	return &tx, err
+}
+
+// RPCGetSignaturesForAddress returns the finalized transaction signatures of
+// the address, newest first, and stops at the until signature
+func RPCGetSignaturesForAddress(rpc, address, until string, limit int) ([]string, error) {
+	opts := map[string]any{
+		"commitment": commitmentFinalized,
+		"limit":      limit,
+	}
+	if until != "" {
+		opts["until"] = until
+	}
+	res, err := callSolanaRPCUntilSufficient(rpc, "getSignaturesForAddress", []any{address, opts})
+	if err != nil {
+		return nil, err
+	}
+	var items []struct {
+		Signature string `json:"signature"`
+		Err       any    `json:"err"`
+	}
+	err = json.Unmarshal(res, &items)
+	if err != nil {
+		return nil, err
+	}
+	var signatures []string
+	for _, item := range items {
+		if item.Err == nil {
+			signatures = append(signatures, item.Signature)
+		}
+	}
+	return signatures, nil
+}
+
+func RPCGetBalance(rpc, address string) (uint64, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getBalance", []any{address, map[string]any{
+		"commitment": commitmentFinalized,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	var balance struct {
+		Value uint64 `json:"value"`
+	}
+	err = json.Unmarshal(res, &balance)
+	return balance.Value, err
+}
+
+// RPCGetAccountInfo returns nil if the account doesn't exist
+func RPCGetAccountInfo(rpc, address string) (*RPCAccount, []byte, error) {
+	res, err := callSolanaRPCUntilSufficient(rpc, "getAccountInfo", []any{address, map[string]any{
+		"commitment": commitmentFinalized,
+		"encoding":   "base64",
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	var info struct {
+		Value *RPCAccount `json:"value"`
+	}
+	err = json.Unmarshal(res, &info)
+	if err != nil || info.Value == nil {
+		return nil, nil, err
+	}
+	if len(info.Value.Data) != 2 || info.Value.Data[1] != "base64" {
+		return nil, nil, fmt.Errorf("invalid account data %v", info.Value.Data)
+	}
+	data, err := base64.StdEncoding.DecodeString(info.Value.Data[0])
+	return info.Value, data, err
+}
+
+// RPCGetNonceAccount returns the authority and the current durable nonce of
+// the initialized nonce account
+func RPCGetNonceAccount(rpc, address string) (PublicKey, Hash, error) {
+	account, data, err := RPCGetAccountInfo(rpc, address)
+	if err != nil {
+		return PublicKey{}, Hash{}, err
+	}
+	if account == nil || account.Owner != SystemProgramId.String() {
+		return PublicKey{}, Hash{}, fmt.Errorf("invalid nonce account %s", address)
+	}
+	return ParseNonceAccountData(data)
+}
+
+func ParseNonceAccountData(data []byte) (PublicKey, Hash, error) {
+	if len(data) != nonceAccountSize {
+		return PublicKey{}, Hash{}, fmt.Errorf("invalid nonce account data %x", data)
+	}
+	if binary.LittleEndian.Uint32(data[4:8]) != nonceStateInitialized {
+		return PublicKey{}, Hash{}, fmt.Errorf("uninitialized nonce account %x", data)
+	}
+	return PublicKey(data[8:40]), Hash(data[40:72]), nil
+}
+
+// FetchAsset reads the decimals from the SPL token mint account, and the
+// name and symbol from the token metadata account of the mint
+func FetchAsset(rpc, mint string) (*Asset, error) {
+	key, err := PublicKeyFromBase58(mint)
+	if err != nil {
+		return nil, err
+	}
+	account, data, err := RPCGetAccountInfo(rpc, mint)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.Owner != TokenProgramId.String() || len(data) != mintAccountSize || data[45] != 1 {
+		return nil, fmt.Errorf("invalid solana mint %s", mint)
+	}
+
+	seeds := [][]byte{[]byte("metadata"), TokenMetadataProgramId[:], key[:]}
+	meta, _ := FindProgramAddress(seeds, TokenMetadataProgramId)
+	account, data, err = RPCGetAccountInfo(rpc, meta.String())
+	if err != nil {
+		return nil, err
+	}
+	if account == nil || account.Owner != TokenMetadataProgramId.String() {
+		return nil, fmt.Errorf("invalid solana mint metadata %s %s", mint, meta)
+	}
+	name, symbol, err := parseTokenMetadata(data, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Asset{
+		Address:  mint,
+		Id:       GenerateAssetId(mint),
+		Name:     name,
+		Symbol:   symbol,
+		Decimals: uint32(data[44]),
+		Chain:    ChainSolana,
+	}, nil
+}
+
+// parseTokenMetadata parses the borsh encoded metadata account, which begins
+// with the key, update authority, mint, name and symbol
+func parseTokenMetadata(data []byte, mint PublicKey) (string, string, error) {
+	r := &reader{b: data}
+	prefix, err := r.next(1 + 32 + 32)
+	if err != nil || !bytes.Equal(prefix[33:], mint[:]) {
+		return "", "", fmt.Errorf("invalid token metadata %x", data)
+	}
+	readString := func() (string, error) {
+		size, err := r.next(4)
+		if err != nil {
+			return "", err
+		}
+		v, err := r.next(int(binary.LittleEndian.Uint32(size)))
+		return strings.TrimRight(string(v), "\x00"), err
+	}
+	name, err := readString()
+	if err != nil {
+		return "", "", err
+	}
+	symbol, err := readString()
+	return name, symbol, err
+}
+
+func RPCSendTransaction(rpc string, tx *Transaction) (string, error) {
+	raw := base64.StdEncoding.EncodeToString(tx.Serialize())
+	res, err := callSolanaRPCUntilSufficient(rpc, "sendTransaction", []any{raw, map[string]any{
+		"encoding":            "base64",
+		"preflightCommitment": commitmentFinalized,
+	}})
+	if err != nil {
+		return "", err
+	}
+	var signature string
+	err = json.Unmarshal(res, &signature)
+	if err != nil {
+		return "", err
+	}
+	if signature != tx.Hash() {
+		return "", fmt.Errorf("invalid transaction signature %s %s", signature, tx.Hash())
+	}
+	return signature, nil
+}
+
+func callSolanaRPCUntilSufficient(rpc, method string, params []any) ([]byte, error) {
+	for {
+		res, err := callSolanaRPC(rpc, method, params)
+		if err == nil {
+			return res, nil
+		}
+		logger.Printf("callSolanaRPC(%s, %s, %v) => %v", rpc, method, params, err)
+		reason := strings.ToLower(err.Error())
+		switch {
+		case strings.Contains(reason, "timeout"):
+		case strings.Contains(reason, "eof"):
+		case strings.Contains(reason, "handshake"):
+		case strings.Contains(reason, "429 too many requests"):
+		default:
+			return res, err
+		}
+		time.Sleep(7 * time.Second)
+	}
+}
+
+func callSolanaRPC(rpc, method string, params []any) ([]byte, error) {
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	body, err := json.Marshal(map[string]any{
+		"method":  method,
+		"params":  params,
+		"id":      time.Now().UnixNano(),
+		"jsonrpc": "2.0",
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	req, err := http.NewRequest("POST", rpc, bytes.NewReader(body))
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+
+	req.Close = true
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, buildRPCError(rpc, method, params, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, buildRPCError(rpc, method, params, fmt.Errorf("%s", resp.Status))
+	}
+	var result struct {
+		Data  json.RawMessage `json:"result"`
+		Error any             `json:"error"`
+	}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return nil, fmt.Errorf("%v (%s)", buildRPCError(rpc, method, params, err), string(body))
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("%v (%s)", buildRPCError(rpc, method, params, err), string(body))
+	}
+	return result.Data, nil
+}
+
+func buildRPCError(rpc, method string, params []any, err error) error {
+	return fmt.Errorf("callSolanaRPC(%s, %s, %v) => %v", rpc, method, params, err)
+}
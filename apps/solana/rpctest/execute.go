@@ -0,0 +1,331 @@
+package rpctest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/MixinNetwork/safe/apps/solana"
+)
+
+type invocation struct {
+	state   *state
+	message *solana.Message
+	signers []solana.PublicKey
+	// the public keys and messages verified by the preceding ed25519
+	// program instructions, which are read by the safe program
+	verified map[solana.PublicKey][][]byte
+}
+
+func (s *Server) execute(st *state, tx *solana.Transaction) error {
+	msg := tx.Message
+	ctx := &invocation{
+		state:    st,
+		message:  msg,
+		signers:  msg.Signers(),
+		verified: make(map[solana.PublicKey][][]byte),
+	}
+
+	payer := msg.AccountKeys[0]
+	fee := uint64(len(tx.Signatures)) * LamportsPerSignature
+	if st.lamports[payer] < fee {
+		return fmt.Errorf("insufficient funds for fee %s", payer)
+	}
+	st.lamports[payer] -= fee
+
+	durable := s.checkDurableNonce(st, msg)
+	if !durable && !s.checkRecentBlockhash(msg.RecentBlockhash) {
+		return fmt.Errorf("blockhash not found %s", msg.RecentBlockhash)
+	}
+
+	for i, ix := range msg.Instructions {
+		program := msg.AccountKeys[ix.ProgramIndex]
+		var accounts []solana.PublicKey
+		for _, a := range ix.Accounts {
+			accounts = append(accounts, msg.AccountKeys[a])
+		}
+		var err error
+		switch program {
+		case solana.SystemProgramId:
+			err = s.executeSystem(ctx, i, accounts, ix.Data)
+		case solana.Ed25519ProgramId:
+			err = ctx.executeEd25519(ix.Data)
+		case solana.AssociatedTokenProgramId:
+			err = s.executeAssociatedToken(ctx, accounts, ix.Data)
+		case solana.SafeProgramId():
+			err = s.executeSafe(ctx, accounts, ix.Data)
+		default:
+			err = fmt.Errorf("program %s not supported", program)
+		}
+		if err != nil {
+			return fmt.Errorf("instruction %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// checkDurableNonce returns true if the first instruction advances the
+// nonce account, and the recent blockhash must be the durable nonce
+func (s *Server) checkDurableNonce(st *state, msg *solana.Message) bool {
+	if len(msg.Instructions) == 0 {
+		return false
+	}
+	ix := msg.Instructions[0]
+	if msg.AccountKeys[ix.ProgramIndex] != solana.SystemProgramId || len(ix.Data) != 4 || len(ix.Accounts) != 3 {
+		return false
+	}
+	if binary.LittleEndian.Uint32(ix.Data) != 4 {
+		return false
+	}
+	account := st.nonces[msg.AccountKeys[ix.Accounts[0]]]
+	return account != nil && account.Nonce == msg.RecentBlockhash
+}
+
+func (s *Server) checkRecentBlockhash(hash solana.Hash) bool {
+	start := max(0, len(s.blocks)-150)
+	for _, b := range s.blocks[start:] {
+		if b.Hash == hash && !b.Skipped {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctx *invocation) isSigner(key solana.PublicKey) bool {
+	return slices.Contains(ctx.signers, key)
+}
+
+func (s *Server) executeSystem(ctx *invocation, index int, accounts []solana.PublicKey, data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("invalid system instruction %x", data)
+	}
+	switch binary.LittleEndian.Uint32(data) {
+	case 2:
+		if len(data) != 12 || len(accounts) != 2 {
+			return fmt.Errorf("invalid transfer instruction %x", data)
+		}
+		from, to, lamports := accounts[0], accounts[1], binary.LittleEndian.Uint64(data[4:])
+		if !ctx.isSigner(from) {
+			return fmt.Errorf("missing required signature %s", from)
+		}
+		if ctx.state.lamports[from] < lamports {
+			return fmt.Errorf("insufficient lamports %s", from)
+		}
+		ctx.state.lamports[from] -= lamports
+		ctx.state.lamports[to] += lamports
+		return nil
+	case 4:
+		if index != 0 || len(accounts) != 3 || accounts[1] != solana.SysvarRecentBlockhashesId {
+			return fmt.Errorf("invalid advance nonce instruction")
+		}
+		account := ctx.state.nonces[accounts[0]]
+		if account == nil || account.Authority != accounts[2] || !ctx.isSigner(accounts[2]) {
+			return fmt.Errorf("invalid nonce account authority %s", accounts[0])
+		}
+		if account.Nonce != ctx.message.RecentBlockhash {
+			return fmt.Errorf("nonce %s is not the recent blockhash", account.Nonce)
+		}
+		next := sha256.Sum256(append(account.Nonce[:], s.blocks[len(s.blocks)-1].Hash[:]...))
+		account.Nonce = solana.Hash(next)
+		return nil
+	default:
+		return fmt.Errorf("system instruction %x not supported", data)
+	}
+}
+
+func (ctx *invocation) executeEd25519(data []byte) error {
+	keys, sigs, msgs, err := solana.ParseEd25519VerifyInstruction(data)
+	if err != nil {
+		return err
+	}
+	for i, k := range keys {
+		if !ed25519.Verify(k[:], msgs[i], sigs[i]) {
+			return fmt.Errorf("invalid ed25519 signature of %s", k)
+		}
+		ctx.verified[k] = append(ctx.verified[k], msgs[i])
+	}
+	return nil
+}
+
+func (s *Server) executeAssociatedToken(ctx *invocation, accounts []solana.PublicKey, data []byte) error {
+	if !bytes.Equal(data, []byte{1}) || len(accounts) != 6 {
+		return fmt.Errorf("invalid associated token instruction %x", data)
+	}
+	payer, ata, owner, mint := accounts[0], accounts[1], accounts[2], accounts[3]
+	if !ctx.isSigner(payer) {
+		return fmt.Errorf("missing required signature %s", payer)
+	}
+	if ata != solana.FindAssociatedTokenAddress(owner, mint) {
+		return fmt.Errorf("invalid associated token address %s", ata)
+	}
+	if _, found := s.mints[mint]; !found {
+		return fmt.Errorf("invalid mint %s", mint)
+	}
+	if ctx.state.tokens[ata] != nil {
+		return nil
+	}
+	ctx.state.tokens[ata] = &tokenAccount{Mint: mint, Owner: owner}
+	return nil
+}
+
+func (s *Server) executeSafe(ctx *invocation, accounts []solana.PublicKey, data []byte) error {
+	if len(data) < 8 {
+		return fmt.Errorf("invalid safe instruction %x", data)
+	}
+	switch {
+	case bytes.Equal(data[:8], solana.SafeInstructionDiscriminator("create_safe")):
+		return s.executeCreateSafe(ctx, accounts, data[8:])
+	case bytes.Equal(data[:8], solana.SafeInstructionDiscriminator("execute_safe")):
+		return s.executeSafeTransfer(ctx, accounts, data[8:])
+	default:
+		return fmt.Errorf("safe instruction %x not supported", data[:8])
+	}
+}
+
+func (s *Server) executeCreateSafe(ctx *invocation, accounts []solana.PublicKey, data []byte) error {
+	if len(accounts) != 5 {
+		return fmt.Errorf("invalid create safe accounts %d", len(accounts))
+	}
+	threshold, owners, lock, err := solana.ParseCreateSafeData(data)
+	if err != nil {
+		return err
+	}
+	config, vault := accounts[1], accounts[2]
+	pda, _ := solana.FindProgramAddress([][]byte{[]byte("safe"), data[:32]}, solana.SafeProgramId())
+	if pda != config || solana.GetSafeVaultAddress(config) != vault {
+		return fmt.Errorf("invalid safe config %s", config)
+	}
+	if ctx.state.safes[config] != nil {
+		return fmt.Errorf("safe config %s already in use", config)
+	}
+	if threshold < 1 || threshold > len(owners)-2 {
+		return fmt.Errorf("invalid safe threshold %d", threshold)
+	}
+	safe := &safeConfig{Threshold: threshold, Timelock: lock}
+	for _, o := range owners {
+		key, _ := solana.PublicKeyFromHex(o)
+		safe.Holders = append(safe.Holders, key)
+	}
+	safe.Holders, safe.Signer, safe.Observer = safe.Holders[:len(owners)-2], safe.Holders[len(owners)-2], safe.Holders[len(owners)-1]
+
+	st := &solana.SafeTransaction{Type: solana.TypeCreateSafe, Config: config.String(), Data: data}
+	err = ctx.checkSafeSignatures(safe, st.GetTransactionHash(), false)
+	if err != nil {
+		return err
+	}
+	safe.Nonce, safe.ExecutedAt = 1, s.clock
+	ctx.state.safes[config] = safe
+	return nil
+}
+
+func (s *Server) executeSafeTransfer(ctx *invocation, accounts []solana.PublicKey, data []byte) error {
+	if len(accounts) < 6 || len(data) < 9 {
+		return fmt.Errorf("invalid execute safe instruction %x", data)
+	}
+	config, vault := accounts[1], accounts[2]
+	safe := ctx.state.safes[config]
+	if safe == nil || solana.GetSafeVaultAddress(config) != vault {
+		return fmt.Errorf("invalid safe config %s", config)
+	}
+	nonce, count := binary.LittleEndian.Uint64(data), int(data[8])
+	if nonce != safe.Nonce {
+		return fmt.Errorf("invalid safe nonce %d %d", nonce, safe.Nonce)
+	}
+	data, accounts = data[9:], accounts[6:]
+	if len(data) != count*10 || len(accounts) == 0 && count > 0 {
+		return fmt.Errorf("invalid execute safe outputs %x", data)
+	}
+
+	type transfer struct {
+		from, to solana.PublicKey
+		token    bool
+	}
+	var outputs []*solana.Output
+	var transfers []*transfer
+	for i := 0; i < count; i++ {
+		kind, amount, decimals := data[i*10], binary.LittleEndian.Uint64(data[i*10+1:]), data[i*10+9]
+		o := &solana.Output{Amount: new(big.Int).SetUint64(amount), Decimals: decimals}
+		switch {
+		case kind == 0 && len(accounts) > 0:
+			o.TokenAddress = solana.SolanaEmptyAddress
+			o.Destination = accounts[0].String()
+			transfers = append(transfers, &transfer{from: vault, to: accounts[0]})
+			accounts = accounts[1:]
+		case kind == 1 && len(accounts) > 2:
+			mint, source, destination := accounts[0], accounts[1], accounts[2]
+			receiver := ctx.state.tokens[destination]
+			if receiver == nil || receiver.Mint != mint || s.mints[mint] != decimals {
+				return fmt.Errorf("invalid token account %s", destination)
+			}
+			if source != solana.FindAssociatedTokenAddress(vault, mint) {
+				return fmt.Errorf("invalid vault token account %s", source)
+			}
+			o.TokenAddress = mint.String()
+			o.Destination = receiver.Owner.String()
+			transfers = append(transfers, &transfer{from: source, to: destination, token: true})
+			accounts = accounts[3:]
+		default:
+			return fmt.Errorf("invalid execute safe output %d", i)
+		}
+		outputs = append(outputs, o)
+	}
+
+	st := &solana.SafeTransaction{Type: solana.TypeTransfer, Config: config.String(), Nonce: nonce, Outputs: outputs}
+	err := ctx.checkSafeSignatures(safe, st.GetTransactionHash(), s.clock.After(safe.ExecutedAt.Add(safe.Timelock)))
+	if err != nil {
+		return err
+	}
+
+	for i, t := range transfers {
+		amount := outputs[i].Amount.Uint64()
+		if !t.token {
+			if ctx.state.lamports[t.from] < amount {
+				return fmt.Errorf("insufficient lamports %s", t.from)
+			}
+			ctx.state.lamports[t.from] -= amount
+			ctx.state.lamports[t.to] += amount
+			continue
+		}
+		source := ctx.state.tokens[t.from]
+		if source == nil || source.Amount < amount {
+			return fmt.Errorf("insufficient token balance %s", t.from)
+		}
+		source.Amount -= amount
+		ctx.state.tokens[t.to].Amount += amount
+	}
+	safe.Nonce += 1
+	safe.ExecutedAt = s.clock
+	return nil
+}
+
+// checkSafeSignatures requires two votes of the holders quorum, the signer
+// and the observer, the holders count as one vote when the threshold met, and
+// the observer is counted only after time lock
+func (ctx *invocation) checkSafeSignatures(safe *safeConfig, digest []byte, unlocked bool) error {
+	signed := func(key solana.PublicKey) bool {
+		return slices.ContainsFunc(ctx.verified[key], func(m []byte) bool { return bytes.Equal(m, digest) })
+	}
+	var holders, votes int
+	for _, h := range safe.Holders {
+		if signed(h) {
+			holders += 1
+		}
+	}
+	if holders >= safe.Threshold {
+		votes += 1
+	}
+	if signed(safe.Signer) {
+		votes += 1
+	}
+	if unlocked && signed(safe.Observer) {
+		votes += 1
+	}
+	if votes < 2 {
+		return fmt.Errorf("insufficient safe signatures %d/%d %d", holders, safe.Threshold, votes)
+	}
+	return nil
+}
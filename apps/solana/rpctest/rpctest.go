@@ -0,0 +1,645 @@
+// Package rpctest implements the subset of Solana JSON-RPC used by the safe
+// over an in-memory cluster, which also executes the system, ed25519,
+// associated token and Mixin Safe program instructions, so the keeper and
+// observer flows can run without a Solana validator.
+package rpctest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/solana"
+)
+
+const (
+	errorCodeInvalidParams  = -32602
+	errorCodeMethodNotFound = -32601
+	errorCodePreflight      = -32002
+	errorCodeSlotSkipped    = -32007
+
+	LamportsPerSignature = 5000
+)
+
+type Block struct {
+	Slot     uint64
+	Hash     solana.Hash
+	Previous solana.Hash
+	Time     time.Time
+	Tx       []string
+	Skipped  bool
+}
+
+type TokenBalance struct {
+	AccountIndex int
+	Mint         solana.PublicKey
+	Owner        solana.PublicKey
+	Amount       uint64
+}
+
+// Transaction is either a deposit injected by the test or a transaction sent
+// to the server, its balances are recorded when it's accepted
+type Transaction struct {
+	Signature         string
+	Signatures        []string
+	AccountKeys       []solana.PublicKey
+	RecentBlockhash   solana.Hash
+	Fee               uint64
+	PreBalances       []uint64
+	PostBalances      []uint64
+	PreTokenBalances  []*TokenBalance
+	PostTokenBalances []*TokenBalance
+
+	block *Block
+}
+
+type tokenAccount struct {
+	Mint   solana.PublicKey
+	Owner  solana.PublicKey
+	Amount uint64
+}
+
+type nonceAccount struct {
+	Authority solana.PublicKey
+	Nonce     solana.Hash
+}
+
+type safeConfig struct {
+	Threshold  int
+	Holders    []solana.PublicKey
+	Signer     solana.PublicKey
+	Observer   solana.PublicKey
+	Timelock   time.Duration
+	Nonce      uint64
+	ExecutedAt time.Time
+}
+
+type state struct {
+	lamports map[solana.PublicKey]uint64
+	tokens   map[solana.PublicKey]*tokenAccount
+	nonces   map[solana.PublicKey]*nonceAccount
+	safes    map[solana.PublicKey]*safeConfig
+}
+
+func (st *state) clone() *state {
+	c := &state{
+		lamports: maps.Clone(st.lamports),
+		tokens:   make(map[solana.PublicKey]*tokenAccount),
+		nonces:   make(map[solana.PublicKey]*nonceAccount),
+		safes:    make(map[solana.PublicKey]*safeConfig),
+	}
+	for k, v := range st.tokens {
+		t := *v
+		c.tokens[k] = &t
+	}
+	for k, v := range st.nonces {
+		n := *v
+		c.nonces[k] = &n
+	}
+	for k, v := range st.safes {
+		s := *v
+		c.safes[k] = &s
+	}
+	return c
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%d %s", e.Code, e.Message)
+}
+
+// Server is a scriptable cluster, the transactions are executed when they
+// are accepted, and become finalized when a slot is mined
+type Server struct {
+	mutex    sync.Mutex
+	blocks   []*Block
+	txs      map[string]*Transaction
+	mempool  []string
+	mints    map[solana.PublicKey]byte
+	state    *state
+	clock    time.Time
+	deposits uint64
+	server   *httptest.Server
+}
+
+func NewServer() *Server {
+	s := &Server{
+		txs:   make(map[string]*Transaction),
+		mints: make(map[solana.PublicKey]byte),
+		state: &state{
+			lamports: make(map[solana.PublicKey]uint64),
+			tokens:   make(map[solana.PublicKey]*tokenAccount),
+			nonces:   make(map[solana.PublicKey]*nonceAccount),
+			safes:    make(map[solana.PublicKey]*safeConfig),
+		},
+		clock: time.Now().UTC(),
+	}
+	s.mine(false)
+	s.server = httptest.NewServer(s)
+	return s
+}
+
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Deposit transfers the lamports to the address, the sender balance is
+// not checked
+func (s *Server) Deposit(from, to string, lamports uint64) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sender, receiver := solana.MustPublicKeyFromBase58(from), solana.MustPublicKeyFromBase58(to)
+	keys := []solana.PublicKey{sender, receiver, solana.SystemProgramId}
+	tx := s.deposit(keys, func() {
+		s.state.lamports[receiver] += lamports
+	})
+	tx.PreBalances[0] = lamports + LamportsPerSignature
+	return tx.Signature
+}
+
+// DepositToken transfers the SPL token to the associated token account of
+// the address, the account is created if not exists
+func (s *Server) DepositToken(mint, from, to string, amount uint64, decimals byte) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token := solana.MustPublicKeyFromBase58(mint)
+	if d, found := s.mints[token]; found && d != decimals {
+		panic(mint)
+	}
+	s.mints[token] = decimals
+	sender, receiver := solana.MustPublicKeyFromBase58(from), solana.MustPublicKeyFromBase58(to)
+	ata := solana.FindAssociatedTokenAddress(receiver, token)
+	keys := []solana.PublicKey{sender, solana.FindAssociatedTokenAddress(sender, token), ata, token, solana.TokenProgramId}
+	tx := s.deposit(keys, func() {
+		if s.state.tokens[ata] == nil {
+			s.state.tokens[ata] = &tokenAccount{Mint: token, Owner: receiver}
+		}
+		s.state.tokens[ata].Amount += amount
+	})
+	return tx.Signature
+}
+
+// CreateNonceAccount initializes a durable nonce account of the authority
+func (s *Server) CreateNonceAccount(authority string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.deposits += 1
+	seed := binary.BigEndian.AppendUint64([]byte("rpctest:nonce"), s.deposits)
+	account := solana.PublicKey(sha256.Sum256(seed))
+	s.state.nonces[account] = &nonceAccount{
+		Authority: solana.MustPublicKeyFromBase58(authority),
+		Nonce:     solana.Hash(sha256.Sum256(account[:])),
+	}
+	s.state.lamports[account] = 1447680
+	return account.String()
+}
+
+// SendTransaction executes the transaction, all the state changes are
+// reverted if any instruction fails, which is the preflight error
+func (s *Server) SendTransaction(raw []byte) (string, error) {
+	tx, err := solana.UnmarshalTransaction(raw)
+	if err != nil {
+		return "", &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+	err = tx.VerifySignatures()
+	if err != nil {
+		return "", &rpcError{errorCodePreflight, err.Error()}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.txs[tx.Hash()] != nil {
+		return tx.Hash(), nil
+	}
+	st := s.state.clone()
+	t := &Transaction{
+		Signature:       tx.Hash(),
+		AccountKeys:     tx.Message.AccountKeys,
+		RecentBlockhash: tx.Message.RecentBlockhash,
+		Fee:             uint64(len(tx.Signatures)) * LamportsPerSignature,
+	}
+	for _, sig := range tx.Signatures {
+		t.Signatures = append(t.Signatures, solana.EncodeSignature(sig))
+	}
+	t.PreBalances, t.PreTokenBalances = s.balances(st, t.AccountKeys)
+
+	err = s.execute(st, tx)
+	if err != nil {
+		return "", &rpcError{errorCodePreflight, err.Error()}
+	}
+	s.state = st
+	t.PostBalances, t.PostTokenBalances = s.balances(st, t.AccountKeys)
+	s.txs[t.Signature] = t
+	s.mempool = append(s.mempool, t.Signature)
+	return t.Signature, nil
+}
+
+// Mine creates n new slots, the first one includes all the accepted
+// transactions, and all slots are finalized immediately
+func (s *Server) Mine(n int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var hashes []string
+	for range n {
+		b := s.mine(false)
+		hashes = append(hashes, b.Hash.String())
+	}
+	return hashes
+}
+
+// Skip creates a slot skipped by the leader, which has no block
+func (s *Server) Skip() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mine(true)
+}
+
+// Advance moves the cluster clock, which is used by the safe time lock
+func (s *Server) Advance(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clock = s.clock.Add(d)
+}
+
+func (s *Server) Mempool() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return slices.Clone(s.mempool)
+}
+
+func (s *Server) Tip() *Block {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b := *s.blocks[len(s.blocks)-1]
+	return &b
+}
+
+func (s *Server) Balance(address string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.state.lamports[solana.MustPublicKeyFromBase58(address)]
+}
+
+func (s *Server) TokenBalance(owner, mint string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ata := solana.FindAssociatedTokenAddress(solana.MustPublicKeyFromBase58(owner), solana.MustPublicKeyFromBase58(mint))
+	account := s.state.tokens[ata]
+	if account == nil {
+		return 0
+	}
+	return account.Amount
+}
+
+// SafeNonce returns the next nonce of the safe config, or -1 if the safe
+// is not created
+func (s *Server) SafeNonce(config string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	safe := s.state.safes[solana.MustPublicKeyFromBase58(config)]
+	if safe == nil {
+		return -1
+	}
+	return int64(safe.Nonce)
+}
+
+func (s *Server) deposit(keys []solana.PublicKey, apply func()) *Transaction {
+	s.deposits += 1
+	seed := binary.BigEndian.AppendUint64([]byte("rpctest:deposit"), s.deposits)
+	a, b := sha256.Sum256(seed), sha256.Sum256(append(seed, 1))
+	tx := &Transaction{
+		Signature:       solana.EncodeSignature(append(a[:], b[:]...)),
+		AccountKeys:     keys,
+		RecentBlockhash: s.blocks[len(s.blocks)-1].Hash,
+		Fee:             LamportsPerSignature,
+	}
+	tx.Signatures = []string{tx.Signature}
+	tx.PreBalances, tx.PreTokenBalances = s.balances(s.state, keys)
+	apply()
+	tx.PostBalances, tx.PostTokenBalances = s.balances(s.state, keys)
+	s.txs[tx.Signature] = tx
+	s.mempool = append(s.mempool, tx.Signature)
+	return tx
+}
+
+func (s *Server) balances(st *state, keys []solana.PublicKey) ([]uint64, []*TokenBalance) {
+	var lamports []uint64
+	var tokens []*TokenBalance
+	for i, k := range keys {
+		lamports = append(lamports, st.lamports[k])
+		if t := st.tokens[k]; t != nil {
+			tokens = append(tokens, &TokenBalance{AccountIndex: i, Mint: t.Mint, Owner: t.Owner, Amount: t.Amount})
+		}
+	}
+	return lamports, tokens
+}
+
+func (s *Server) mine(skipped bool) *Block {
+	b := &Block{
+		Slot:    uint64(len(s.blocks)),
+		Time:    s.clock,
+		Skipped: skipped,
+	}
+	if len(s.blocks) > 0 {
+		b.Previous = s.blocks[len(s.blocks)-1].Hash
+	}
+	seed := fmt.Sprintf("%s:%d:%v", b.Previous, b.Slot, skipped)
+	b.Hash = solana.Hash(sha256.Sum256([]byte(seed)))
+	if skipped {
+		b.Hash = b.Previous
+	} else {
+		b.Tx = s.mempool
+		for _, h := range b.Tx {
+			s.txs[h].block = b
+		}
+		s.mempool = nil
+	}
+	s.blocks = append(s.blocks, b)
+	return b
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var call struct {
+		Id     any               `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&call)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := s.handle(call.Method, call.Params)
+	body := map[string]any{"jsonrpc": "2.0", "id": call.Id, "result": result}
+	if err != nil {
+		delete(body, "result")
+		body["error"] = err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func (s *Server) handle(method string, params []json.RawMessage) (any, error) {
+	switch method {
+	case "getSlot":
+		return s.Tip().Slot, nil
+	case "getBlock":
+		return s.getBlock(params)
+	case "getTransaction":
+		return s.getTransaction(params)
+	case "getSignaturesForAddress":
+		return s.getSignaturesForAddress(params)
+	case "getBalance":
+		return s.getBalance(params)
+	case "getAccountInfo":
+		return s.getAccountInfo(params)
+	case "sendTransaction":
+		var encoded string
+		if len(params) < 1 || json.Unmarshal(params[0], &encoded) != nil {
+			return nil, &rpcError{errorCodeInvalidParams, "invalid transaction"}
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, &rpcError{errorCodeInvalidParams, err.Error()}
+		}
+		return s.SendTransaction(raw)
+	default:
+		return nil, &rpcError{errorCodeMethodNotFound, fmt.Sprintf("Method not found %s", method)}
+	}
+}
+
+func (s *Server) getBlock(params []json.RawMessage) (any, error) {
+	var slot uint64
+	if len(params) < 1 || json.Unmarshal(params[0], &slot) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid slot"}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if slot >= uint64(len(s.blocks)) {
+		return nil, &rpcError{errorCodeSlotSkipped, fmt.Sprintf("Block not available for slot %d", slot)}
+	}
+	b := s.blocks[slot]
+	if b.Skipped {
+		return nil, &rpcError{errorCodeSlotSkipped, fmt.Sprintf("Slot %d was skipped", slot)}
+	}
+	parent := uint64(0)
+	for i := int(slot) - 1; i >= 0; i-- {
+		if !s.blocks[i].Skipped {
+			parent = uint64(i)
+			break
+		}
+	}
+	txs := []any{}
+	for _, h := range b.Tx {
+		txs = append(txs, s.transactionJSON(s.txs[h]))
+	}
+	return map[string]any{
+		"blockhash":         b.Hash.String(),
+		"previousBlockhash": b.Previous.String(),
+		"parentSlot":        parent,
+		"blockTime":         b.Time.Unix(),
+		"blockHeight":       b.Slot,
+		"transactions":      txs,
+	}, nil
+}
+
+func (s *Server) getTransaction(params []json.RawMessage) (any, error) {
+	var signature string
+	if len(params) < 1 || json.Unmarshal(params[0], &signature) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid signature"}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx := s.txs[signature]
+	if tx == nil || tx.block == nil {
+		return nil, nil
+	}
+	res := s.transactionJSON(tx)
+	res["slot"] = tx.block.Slot
+	res["blockTime"] = tx.block.Time.Unix()
+	return res, nil
+}
+
+func (s *Server) getSignaturesForAddress(params []json.RawMessage) (any, error) {
+	var address string
+	var opts struct {
+		Until string `json:"until"`
+		Limit int    `json:"limit"`
+	}
+	if len(params) < 1 || json.Unmarshal(params[0], &address) != nil {
+		return nil, &rpcError{errorCodeInvalidParams, "invalid address"}
+	}
+	if len(params) > 1 {
+		_ = json.Unmarshal(params[1], &opts)
+	}
+	key, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		return nil, &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []any{}
+	for i := len(s.blocks) - 1; i >= 0; i-- {
+		b := s.blocks[i]
+		for j := len(b.Tx) - 1; j >= 0; j-- {
+			tx := s.txs[b.Tx[j]]
+			if tx.Signature == opts.Until {
+				return items, nil
+			}
+			if !slices.Contains(tx.AccountKeys, key) {
+				continue
+			}
+			if opts.Limit > 0 && len(items) >= opts.Limit {
+				return items, nil
+			}
+			items = append(items, map[string]any{
+				"signature": tx.Signature,
+				"slot":      b.Slot,
+				"err":       nil,
+				"blockTime": b.Time.Unix(),
+			})
+		}
+	}
+	return items, nil
+}
+
+func (s *Server) getBalance(params []json.RawMessage) (any, error) {
+	key, err := paramAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return map[string]any{
+		"context": map[string]any{"slot": len(s.blocks) - 1},
+		"value":   s.state.lamports[key],
+	}, nil
+}
+
+func (s *Server) getAccountInfo(params []json.RawMessage) (any, error) {
+	key, err := paramAddress(params)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var owner solana.PublicKey
+	var data []byte
+	switch {
+	case s.state.nonces[key] != nil:
+		n := s.state.nonces[key]
+		owner = solana.SystemProgramId
+		data = binary.LittleEndian.AppendUint32(data, 1)
+		data = binary.LittleEndian.AppendUint32(data, 1)
+		data = append(data, n.Authority[:]...)
+		data = append(data, n.Nonce[:]...)
+		data = binary.LittleEndian.AppendUint64(data, LamportsPerSignature)
+	case s.state.safes[key] != nil:
+		owner = solana.SafeProgramId()
+	case s.state.tokens[key] != nil:
+		owner = solana.TokenProgramId
+	case s.state.lamports[key] > 0:
+		owner = solana.SystemProgramId
+	default:
+		return map[string]any{"context": map[string]any{"slot": len(s.blocks) - 1}, "value": nil}, nil
+	}
+	return map[string]any{
+		"context": map[string]any{"slot": len(s.blocks) - 1},
+		"value": map[string]any{
+			"lamports":   s.state.lamports[key],
+			"owner":      owner.String(),
+			"data":       []string{base64.StdEncoding.EncodeToString(data), "base64"},
+			"executable": false,
+		},
+	}, nil
+}
+
+func (s *Server) transactionJSON(tx *Transaction) map[string]any {
+	var keys []string
+	for _, k := range tx.AccountKeys {
+		keys = append(keys, k.String())
+	}
+	tokens := func(balances []*TokenBalance) []any {
+		res := []any{}
+		for _, b := range balances {
+			res = append(res, map[string]any{
+				"accountIndex": b.AccountIndex,
+				"mint":         b.Mint.String(),
+				"owner":        b.Owner.String(),
+				"programId":    solana.TokenProgramId.String(),
+				"uiTokenAmount": map[string]any{
+					"amount":   fmt.Sprint(b.Amount),
+					"decimals": s.mints[b.Mint],
+				},
+			})
+		}
+		return res
+	}
+	return map[string]any{
+		"transaction": map[string]any{
+			"signatures": tx.Signatures,
+			"message": map[string]any{
+				"accountKeys":     keys,
+				"recentBlockhash": tx.RecentBlockhash.String(),
+			},
+		},
+		"meta": map[string]any{
+			"err":               nil,
+			"fee":               tx.Fee,
+			"preBalances":       tx.PreBalances,
+			"postBalances":      tx.PostBalances,
+			"preTokenBalances":  tokens(tx.PreTokenBalances),
+			"postTokenBalances": tokens(tx.PostTokenBalances),
+		},
+	}
+}
+
+func paramAddress(params []json.RawMessage) (solana.PublicKey, error) {
+	var address string
+	if len(params) < 1 || json.Unmarshal(params[0], &address) != nil {
+		return solana.PublicKey{}, &rpcError{errorCodeInvalidParams, "invalid address"}
+	}
+	key, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		return key, &rpcError{errorCodeInvalidParams, err.Error()}
+	}
+	return key, nil
+}
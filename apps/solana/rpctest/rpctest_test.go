@@ -0,0 +1,235 @@
+package rpctest
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSender = "9WzDXwBbmkg8ZTbNMqUxvQRAyrZzDsGYdLVL9zYtAWWM"
+	testMint   = "EPjFWdd5AufqSSqeM5qvC2AH3RPfBQhE6wAqW5z6Jgn5"
+)
+
+func init() {
+	solana.SetSafeProgram("D3ob8sTZ9hMnVBhLThD1Xd8Su8btV8gY73p7JEuZiAHE")
+}
+
+func TestServerDeposits(t *testing.T) {
+	require := require.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	receiver := testKey(1)
+	address := solana.PublicKey(receiver.Public().(ed25519.PublicKey)).String()
+	sol := s.Deposit(testSender, address, 1234567)
+	token := s.DepositToken(testMint, testSender, address, 2000, 6)
+	require.Equal([]string{sol, token}, s.Mempool())
+	tx, err := solana.RPCGetTransaction(s.URL(), sol)
+	require.Nil(err)
+	require.Nil(tx)
+
+	s.Skip()
+	hashes := s.Mine(1)
+	slot, err := solana.RPCGetSlot(s.URL())
+	require.Nil(err)
+	require.Equal(uint64(2), slot)
+	block, err := solana.RPCGetBlock(s.URL(), 1)
+	require.Nil(err)
+	require.Nil(block)
+	block, err = solana.RPCGetBlock(s.URL(), 2)
+	require.Nil(err)
+	require.Equal(hashes[0], block.Blockhash)
+	require.Equal(uint64(0), block.ParentSlot)
+	require.Len(block.Transactions, 2)
+
+	transfers := solana.ExtractTransfers(block.Transactions[0])
+	require.Len(transfers, 1)
+	require.Equal(sol, transfers[0].Hash)
+	require.Equal(int64(1), transfers[0].Index)
+	require.Equal(solana.SolanaEmptyAddress, transfers[0].TokenAddress)
+	require.Equal(testSender, transfers[0].Sender)
+	require.Equal(address, transfers[0].Receiver)
+	require.Equal(int64(1234567), transfers[0].Value.Int64())
+
+	transfers = solana.ExtractTransfers(block.Transactions[1])
+	require.Len(transfers, 1)
+	require.Equal(token, transfers[0].Hash)
+	require.Equal(int64(2+solana.TokenTransferIndexOffset), transfers[0].Index)
+	require.Equal(testMint, transfers[0].TokenAddress)
+	require.Equal(solana.GenerateAssetId(testMint), transfers[0].AssetId)
+	require.Equal(address, transfers[0].Receiver)
+	require.Equal(int64(2000), transfers[0].Value.Int64())
+
+	tx, err = solana.RPCGetTransaction(s.URL(), token)
+	require.Nil(err)
+	require.Equal(uint64(2), tx.Slot)
+	sigs, err := solana.RPCGetSignaturesForAddress(s.URL(), address, "", 10)
+	require.Nil(err)
+	require.Equal([]string{sol}, sigs)
+	sigs, err = solana.RPCGetSignaturesForAddress(s.URL(), address, sol, 10)
+	require.Nil(err)
+	require.Len(sigs, 0)
+
+	balance, err := solana.RPCGetBalance(s.URL(), address)
+	require.Nil(err)
+	require.Equal(uint64(1234567), balance)
+	require.Equal(uint64(2000), s.TokenBalance(address, testMint))
+}
+
+func TestServerSafe(t *testing.T) {
+	require := require.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	keys := []ed25519.PrivateKey{testKey(1), testKey(2), testKey(3), testKey(4)}
+	var owners []string
+	for _, k := range keys {
+		owners = append(owners, hex.EncodeToString(k.Public().(ed25519.PublicKey)))
+	}
+	payer := testKey(5)
+	payerAddress := solana.PublicKey(payer.Public().(ed25519.PublicKey))
+	s.Deposit(testSender, payerAddress.String(), 100000000)
+	nonceAccount := solana.MustPublicKeyFromBase58(s.CreateNonceAccount(payerAddress.String()))
+	s.Mine(1)
+
+	sa, st, err := solana.BuildSafeAccountWithHolders(owners[:2], 1, owners[2], owners[3], "rid", time.Hour)
+	require.Nil(err)
+	require.Equal(int64(-1), s.SafeNonce(sa.Config))
+	st.SetSignature(0, ed25519.Sign(keys[0], st.Message))
+	_, err = st.BuildTransaction(owners, payerAddress, nonceAccount, solana.Hash{})
+	require.NotNil(err)
+	st.SetSignature(2, ed25519.Sign(keys[2], st.Message))
+	tx := testBuildTransaction(require, s, st, owners, payer, nonceAccount)
+	hash, err := solana.RPCSendTransaction(s.URL(), tx)
+	require.Nil(err)
+	require.Equal(tx.Hash(), hash)
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	s.Mine(1)
+	require.Equal(int64(1), s.SafeNonce(sa.Config))
+	account, _, err := solana.RPCGetAccountInfo(s.URL(), sa.Config)
+	require.Nil(err)
+	require.Equal(solana.SafeProgramId().String(), account.Owner)
+
+	s.Deposit(testSender, sa.Address, 50000000)
+	s.DepositToken(testMint, testSender, sa.Address, 3000, 6)
+	s.Mine(1)
+	receiver := solana.PublicKey(testKey(6).Public().(ed25519.PublicKey)).String()
+	outputs := []*solana.Output{{
+		TokenAddress: solana.SolanaEmptyAddress,
+		Destination:  receiver,
+		Amount:       big.NewInt(20000000),
+		Decimals:     solana.ValuePrecision,
+	}, {
+		TokenAddress: testMint,
+		Destination:  receiver,
+		Amount:       big.NewInt(1000),
+		Decimals:     6,
+	}}
+	st, err = solana.CreateTransactionFromOutputs("transfer", sa.Address, sa.Config, outputs, 1)
+	require.Nil(err)
+	st.SetSignature(1, ed25519.Sign(keys[1], st.Message))
+	st.SetSignature(3, ed25519.Sign(keys[3], st.Message))
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	require.Contains(err.Error(), "insufficient safe signatures")
+
+	s.Advance(time.Hour + time.Second)
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.Nil(err)
+	s.Mine(1)
+	require.Equal(int64(2), s.SafeNonce(sa.Config))
+	require.Equal(uint64(30000000), s.Balance(sa.Address))
+	require.Equal(uint64(20000000), s.Balance(receiver))
+	require.Equal(uint64(2000), s.TokenBalance(sa.Address, testMint))
+	require.Equal(uint64(1000), s.TokenBalance(receiver, testMint))
+
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid safe nonce")
+}
+
+func TestServerSafeHolders(t *testing.T) {
+	require := require.New(t)
+	s := NewServer()
+	defer s.Close()
+
+	keys := []ed25519.PrivateKey{testKey(1), testKey(2), testKey(3), testKey(4), testKey(5)}
+	var owners []string
+	for _, k := range keys {
+		owners = append(owners, hex.EncodeToString(k.Public().(ed25519.PublicKey)))
+	}
+	payer := testKey(6)
+	payerAddress := solana.PublicKey(payer.Public().(ed25519.PublicKey))
+	s.Deposit(testSender, payerAddress.String(), 100000000)
+	nonceAccount := solana.MustPublicKeyFromBase58(s.CreateNonceAccount(payerAddress.String()))
+	s.Mine(1)
+
+	sa, st, err := solana.BuildSafeAccountWithHolders(owners[:3], 2, owners[3], owners[4], "holders", time.Hour)
+	require.Nil(err)
+	for i := range 3 {
+		st.SetSignature(i, ed25519.Sign(keys[i], st.Message))
+	}
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	require.Contains(err.Error(), "insufficient safe signatures")
+	st.Signatures = make([][]byte, len(owners))
+	st.SetSignature(0, ed25519.Sign(keys[0], st.Message))
+	st.SetSignature(3, ed25519.Sign(keys[3], st.Message))
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	st.SetSignature(2, ed25519.Sign(keys[2], st.Message))
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.Nil(err)
+	s.Mine(1)
+	require.Equal(int64(1), s.SafeNonce(sa.Config))
+
+	s.Deposit(testSender, sa.Address, 50000000)
+	s.Mine(1)
+	receiver := solana.PublicKey(testKey(7).Public().(ed25519.PublicKey)).String()
+	outputs := []*solana.Output{{
+		TokenAddress: solana.SolanaEmptyAddress,
+		Destination:  receiver,
+		Amount:       big.NewInt(20000000),
+		Decimals:     solana.ValuePrecision,
+	}}
+	st, err = solana.CreateTransactionFromOutputs("transfer", sa.Address, sa.Config, outputs, 1)
+	require.Nil(err)
+	for i := range 3 {
+		st.SetSignature(i, ed25519.Sign(keys[i], st.Message))
+	}
+	s.Advance(time.Hour + time.Second)
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.NotNil(err)
+	require.Contains(err.Error(), "insufficient safe signatures")
+	require.Equal(uint64(50000000), s.Balance(sa.Address))
+
+	st.SetSignature(4, ed25519.Sign(keys[4], st.Message))
+	_, err = solana.RPCSendTransaction(s.URL(), testBuildTransaction(require, s, st, owners, payer, nonceAccount))
+	require.Nil(err)
+	s.Mine(1)
+	require.Equal(int64(2), s.SafeNonce(sa.Config))
+	require.Equal(uint64(20000000), s.Balance(receiver))
+}
+
+func testBuildTransaction(require *require.Assertions, s *Server, st *solana.SafeTransaction, owners []string, payer ed25519.PrivateKey, nonceAccount solana.PublicKey) *solana.Transaction {
+	authority, nonce, err := solana.RPCGetNonceAccount(s.URL(), nonceAccount.String())
+	require.Nil(err)
+	require.Equal(solana.PublicKey(payer.Public().(ed25519.PublicKey)), authority)
+	tx, err := st.BuildTransaction(owners, authority, nonceAccount, nonce)
+	require.Nil(err)
+	require.Nil(tx.Sign(payer))
+	return tx
+}
+
+func testKey(i byte) ed25519.PrivateKey {
+	seed := sha256.Sum256([]byte{i})
+	return ed25519.NewKeyFromSeed(seed[:])
+}
@@ -0,0 +1,549 @@
+package solana
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+)
+
+const (
+	TypeCreateSafe = 1
+	TypeTransfer   = 2
+
+	// the ed25519 verification of all signatures must fit in one legacy
+	// transaction with the outputs, so the outputs count is limited
+	OutputsLimit = 8
+
+	systemInstructionTransfer     = 2
+	systemInstructionAdvanceNonce = 4
+
+	associatedTokenInstructionCreateIdempotent = 1
+
+	outputKindNative = 0
+	outputKindToken  = 1
+
+	ed25519OffsetsSize = 14
+	ed25519CurrentIx   = 0xffff
+
+	messagePrefix = "MIXIN:SAFE:SOLANA:"
+)
+
+// the safe transaction is either the create safe transaction, or the SOL and
+// SPL tokens transfer from the vault, and all the owners sign the message,
+// which is the sha256 digest of the safe transaction
+type SafeTransaction struct {
+	TxHash      string
+	Type        byte
+	SafeAddress string
+	Config      string
+	Nonce       uint64
+	Data        []byte
+	Outputs     []*Output
+	Message     []byte
+	Signatures  [][]byte
+}
+
+// Output transfers SOL when the token address is the empty address, otherwise
+// transfers the SPL token to the associated token account of the destination
+type Output struct {
+	TokenAddress string
+	Destination  string
+	Amount       *big.Int
+	Decimals     byte
+}
+
+func CreateTransactionFromOutputs(id, safeAddress, config string, outputs []*Output, nonce uint64) (*SafeTransaction, error) {
+	if len(outputs) == 0 || len(outputs) > OutputsLimit {
+		return nil, fmt.Errorf("invalid outputs count %d", len(outputs))
+	}
+	if NormalizeAddress(safeAddress) != safeAddress || NormalizeAddress(config) != config {
+		return nil, fmt.Errorf("invalid safe address %s %s", safeAddress, config)
+	}
+	for _, o := range outputs {
+		if NormalizeAddress(o.Destination) != o.Destination || o.Destination == safeAddress {
+			return nil, fmt.Errorf("invalid output destination %s", o.Destination)
+		}
+		if NormalizeAddress(o.TokenAddress) != o.TokenAddress {
+			return nil, fmt.Errorf("invalid output token %s", o.TokenAddress)
+		}
+		if o.Amount == nil || o.Amount.Sign() <= 0 || !o.Amount.IsUint64() {
+			return nil, fmt.Errorf("invalid output amount %v", o.Amount)
+		}
+		if o.TokenAddress == SolanaEmptyAddress && o.Decimals != ValuePrecision {
+			return nil, fmt.Errorf("invalid SOL decimals %d", o.Decimals)
+		}
+	}
+	tx := &SafeTransaction{
+		Type:        TypeTransfer,
+		SafeAddress: safeAddress,
+		Config:      config,
+		Nonce:       nonce,
+		Outputs:     outputs,
+	}
+	tx.Message = tx.GetTransactionHash()
+	tx.TxHash = tx.Hash(id)
+	return tx, nil
+}
+
+func (tx *SafeTransaction) Hash(id string) string {
+	var txData []byte
+	txData = append(txData, []byte(id)...)
+	txData = append(txData, tx.Message...)
+	hash := sha256.Sum256(txData)
+	return hex.EncodeToString(hash[:])
+}
+
+// GetTransactionHash is the digest verified by the safe program, it commits
+// to the program, the safe config and all the instruction arguments
+func (tx *SafeTransaction) GetTransactionHash() []byte {
+	program := SafeProgramId()
+	config := MustPublicKeyFromBase58(tx.Config)
+	data := []byte(messagePrefix)
+	data = append(data, program[:]...)
+	data = append(data, config[:]...)
+	data = append(data, tx.Type)
+	switch tx.Type {
+	case TypeCreateSafe:
+		data = append(data, tx.Data...)
+	case TypeTransfer:
+		data = appendUint64(data, tx.Nonce)
+		for _, o := range tx.Outputs {
+			mint := MustPublicKeyFromBase58(o.TokenAddress)
+			receiver := MustPublicKeyFromBase58(o.Destination)
+			data = append(data, mint[:]...)
+			data = append(data, receiver[:]...)
+			data = appendUint64(data, o.Amount.Uint64())
+			data = append(data, o.Decimals)
+		}
+	default:
+		panic(tx.Type)
+	}
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+func (tx *SafeTransaction) Marshal() []byte {
+	enc := mc.NewEncoder()
+	enc.WriteUint64(uint64(tx.Type))
+	enc.WriteUint64(tx.Nonce)
+	bitcoin.WriteBytes(enc, []byte(tx.TxHash))
+	bitcoin.WriteBytes(enc, []byte(tx.SafeAddress))
+	bitcoin.WriteBytes(enc, []byte(tx.Config))
+	bitcoin.WriteBytes(enc, tx.Data)
+	enc.WriteUint64(uint64(len(tx.Outputs)))
+	for _, o := range tx.Outputs {
+		bitcoin.WriteBytes(enc, []byte(o.TokenAddress))
+		bitcoin.WriteBytes(enc, []byte(o.Destination))
+		enc.WriteUint64(o.Amount.Uint64())
+		enc.WriteUint64(uint64(o.Decimals))
+	}
+	bitcoin.WriteBytes(enc, tx.Message)
+
+	var signatures []string
+	for _, sig := range tx.Signatures {
+		signatures = append(signatures, hex.EncodeToString(sig))
+	}
+	sigs := strings.Join(signatures, ",")
+	bitcoin.WriteBytes(enc, []byte(sigs))
+	return enc.Bytes()
+}
+
+func UnmarshalSafeTransaction(b []byte) (*SafeTransaction, error) {
+	dec := mc.NewDecoder(b)
+	typ, err := dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case TypeCreateSafe, TypeTransfer:
+	default:
+		return nil, fmt.Errorf("invalid safe transaction type %d", typ)
+	}
+	nonce, err := dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	hash, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	safeAddress, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	config, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	data, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	count, err := dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	if count > OutputsLimit {
+		return nil, fmt.Errorf("invalid outputs count %d", count)
+	}
+	var outputs []*Output
+	for i := uint64(0); i < count; i++ {
+		token, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		destination, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		amount, err := dec.ReadUint64()
+		if err != nil {
+			return nil, err
+		}
+		decimals, err := dec.ReadUint64()
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &Output{
+			TokenAddress: string(token),
+			Destination:  string(destination),
+			Amount:       new(big.Int).SetUint64(amount),
+			Decimals:     byte(decimals),
+		})
+	}
+	msg, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	signature, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	var signatures [][]byte
+	for _, s := range strings.Split(string(signature), ",") {
+		sig, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(sig) == 0 {
+			sig = nil
+		}
+		signatures = append(signatures, sig)
+	}
+
+	return &SafeTransaction{
+		TxHash:      string(hash),
+		Type:        byte(typ),
+		SafeAddress: string(safeAddress),
+		Config:      string(config),
+		Nonce:       nonce,
+		Data:        data,
+		Outputs:     outputs,
+		Message:     msg,
+		Signatures:  signatures,
+	}, nil
+}
+
+func (tx *SafeTransaction) ExtractOutputs() []*Output {
+	if tx.Type != TypeTransfer {
+		return nil
+	}
+	return tx.Outputs
+}
+
+// SetSignature puts the signature to the slot of the owner index, which is
+// the order of GetSafeOwnersWithHolders
+func (tx *SafeTransaction) SetSignature(index int, sig []byte) {
+	for len(tx.Signatures) <= index {
+		tx.Signatures = append(tx.Signatures, nil)
+	}
+	tx.Signatures[index] = sig
+}
+
+// MergeSignatures copies the valid signatures of the publics from other to
+// the empty slots of tx, owners are the safe owners public keys
+func (tx *SafeTransaction) MergeSignatures(other *SafeTransaction, owners, publics []string) int {
+	if !bytes.Equal(tx.Message, other.Message) {
+		return 0
+	}
+	var merged int
+	for i, sig := range other.Signatures {
+		if sig == nil || i >= len(owners) || !slices.Contains(publics, owners[i]) {
+			continue
+		}
+		if i < len(tx.Signatures) && tx.Signatures[i] != nil {
+			continue
+		}
+		if VerifyMessageSignature(owners[i], tx.Message, sig) != nil {
+			continue
+		}
+		tx.SetSignature(i, sig)
+		merged = merged + 1
+	}
+	return merged
+}
+
+// BuildTransaction returns the unsigned Solana transaction of the safe
+// transaction with all its signatures, the payer is also the authority of
+// the durable nonce account, and must sign it before broadcast
+func (tx *SafeTransaction) BuildTransaction(owners []string, payer, nonceAccount PublicKey, nonce Hash) (*Transaction, error) {
+	var keys []PublicKey
+	var sigs [][]byte
+	for i, sig := range tx.Signatures {
+		if sig == nil {
+			continue
+		}
+		if i >= len(owners) {
+			return nil, fmt.Errorf("invalid signature index %d", i)
+		}
+		err := VerifyMessageSignature(owners[i], tx.Message, sig)
+		if err != nil {
+			return nil, err
+		}
+		pub, _ := PublicKeyFromHex(owners[i])
+		keys = append(keys, pub)
+		sigs = append(sigs, sig)
+	}
+	if len(keys) < 2 {
+		return nil, fmt.Errorf("SafeTransaction has insufficient signatures")
+	}
+	config, err := PublicKeyFromBase58(tx.Config)
+	if err != nil {
+		return nil, err
+	}
+	vault, err := PublicKeyFromBase58(tx.SafeAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	ixs := []*Instruction{
+		AdvanceNonceInstruction(nonceAccount, payer),
+		Ed25519VerifyInstruction(keys, sigs, tx.Message),
+	}
+	switch tx.Type {
+	case TypeCreateSafe:
+		ixs = append(ixs, createSafeInstruction(payer, config, vault, tx.Data))
+	case TypeTransfer:
+		for _, o := range tx.Outputs {
+			if o.TokenAddress == SolanaEmptyAddress {
+				continue
+			}
+			mint := MustPublicKeyFromBase58(o.TokenAddress)
+			receiver := MustPublicKeyFromBase58(o.Destination)
+			ixs = append(ixs, CreateAssociatedTokenAccountInstruction(payer, receiver, mint))
+		}
+		ixs = append(ixs, executeSafeInstruction(payer, config, vault, tx.Nonce, tx.Outputs))
+	default:
+		panic(tx.Type)
+	}
+	return NewTransaction(payer, nonce, ixs)
+}
+
+// CheckSize returns error if the safe transaction signed by the number of
+// owners can't fit in a Solana transaction
+func (tx *SafeTransaction) CheckSize(signatures int) error {
+	var owners []string
+	dummy := &SafeTransaction{Type: tx.Type, Config: tx.Config, SafeAddress: tx.SafeAddress, Nonce: tx.Nonce, Data: tx.Data, Outputs: tx.Outputs, Message: tx.Message}
+	for i := 0; i < signatures; i++ {
+		seed := sha256.Sum256(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		priv := ed25519.NewKeyFromSeed(seed[:])
+		owners = append(owners, hex.EncodeToString(priv.Public().(ed25519.PublicKey)))
+		dummy.SetSignature(i, ed25519.Sign(priv, tx.Message))
+	}
+	payer := PublicKey(sha256.Sum256([]byte("payer")))
+	nonce := PublicKey(sha256.Sum256([]byte("nonce")))
+	_, err := dummy.BuildTransaction(owners, payer, nonce, Hash{})
+	return err
+}
+
+func CheckTransactionPartiallySignedByHolders(raw string, owners, holders []string, threshold int) bool {
+	var signed int
+	for _, h := range holders {
+		if CheckTransactionPartiallySignedBy(raw, owners, h) {
+			signed = signed + 1
+		}
+	}
+	return signed >= threshold
+}
+
+func CheckTransactionPartiallySignedBy(raw string, owners []string, public string) bool {
+	b, _ := hex.DecodeString(raw)
+	st, err := UnmarshalSafeTransaction(b)
+	if err != nil {
+		return false
+	}
+	for i, sig := range st.Signatures {
+		if sig == nil || i >= len(owners) || owners[i] != public {
+			continue
+		}
+		return VerifyMessageSignature(public, st.Message, sig) == nil
+	}
+	return false
+}
+
+func AdvanceNonceInstruction(nonceAccount, authority PublicKey) *Instruction {
+	return &Instruction{
+		ProgramId: SystemProgramId,
+		Accounts: []*AccountMeta{
+			{PublicKey: nonceAccount, IsWritable: true},
+			{PublicKey: SysvarRecentBlockhashesId},
+			{PublicKey: authority, IsSigner: true},
+		},
+		Data: binary.LittleEndian.AppendUint32(nil, systemInstructionAdvanceNonce),
+	}
+}
+
+func TransferInstruction(from, to PublicKey, lamports uint64) *Instruction {
+	data := binary.LittleEndian.AppendUint32(nil, systemInstructionTransfer)
+	return &Instruction{
+		ProgramId: SystemProgramId,
+		Accounts: []*AccountMeta{
+			{PublicKey: from, IsSigner: true, IsWritable: true},
+			{PublicKey: to, IsWritable: true},
+		},
+		Data: appendUint64(data, lamports),
+	}
+}
+
+func CreateAssociatedTokenAccountInstruction(payer, owner, mint PublicKey) *Instruction {
+	return &Instruction{
+		ProgramId: AssociatedTokenProgramId,
+		Accounts: []*AccountMeta{
+			{PublicKey: payer, IsSigner: true, IsWritable: true},
+			{PublicKey: FindAssociatedTokenAddress(owner, mint), IsWritable: true},
+			{PublicKey: owner},
+			{PublicKey: mint},
+			{PublicKey: SystemProgramId},
+			{PublicKey: TokenProgramId},
+		},
+		Data: []byte{associatedTokenInstructionCreateIdempotent},
+	}
+}
+
+// Ed25519VerifyInstruction verifies all the signatures of the same message,
+// the message is shared by all the signature offsets
+func Ed25519VerifyInstruction(keys []PublicKey, sigs [][]byte, msg []byte) *Instruction {
+	if len(keys) != len(sigs) || len(keys) > 255 {
+		panic(len(keys))
+	}
+	count := len(keys)
+	data := []byte{byte(count), 0}
+	msgOffset := 2 + count*ed25519OffsetsSize
+	offset := msgOffset + len(msg)
+	for i := 0; i < count; i++ {
+		pubOffset := offset + i*(32+signatureSize)
+		sigOffset := pubOffset + 32
+		for _, n := range []int{sigOffset, ed25519CurrentIx, pubOffset, ed25519CurrentIx, msgOffset, len(msg), ed25519CurrentIx} {
+			data = binary.LittleEndian.AppendUint16(data, uint16(n))
+		}
+	}
+	data = append(data, msg...)
+	for i, k := range keys {
+		data = append(data, k[:]...)
+		data = append(data, sigs[i]...)
+	}
+	return &Instruction{ProgramId: Ed25519ProgramId, Data: data}
+}
+
+// ParseEd25519VerifyInstruction returns the public keys, signatures and
+// messages of the ed25519 program instruction data
+func ParseEd25519VerifyInstruction(data []byte) ([]PublicKey, [][]byte, [][]byte, error) {
+	if len(data) < 2 || len(data) < 2+int(data[0])*ed25519OffsetsSize {
+		return nil, nil, nil, fmt.Errorf("invalid ed25519 instruction %x", data)
+	}
+	read := func(offset, size int) ([]byte, error) {
+		if offset+size > len(data) {
+			return nil, fmt.Errorf("invalid ed25519 instruction offset %d", offset)
+		}
+		return data[offset : offset+size], nil
+	}
+	var keys []PublicKey
+	var sigs, msgs [][]byte
+	for i := 0; i < int(data[0]); i++ {
+		var offsets [7]int
+		for j := range offsets {
+			offsets[j] = int(binary.LittleEndian.Uint16(data[2+i*ed25519OffsetsSize+j*2:]))
+		}
+		if offsets[1] != ed25519CurrentIx || offsets[3] != ed25519CurrentIx || offsets[6] != ed25519CurrentIx {
+			return nil, nil, nil, fmt.Errorf("invalid ed25519 instruction index %v", offsets)
+		}
+		sig, err := read(offsets[0], signatureSize)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		pub, err := read(offsets[2], 32)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		msg, err := read(offsets[4], offsets[5])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		keys = append(keys, PublicKey(pub))
+		sigs = append(sigs, sig)
+		msgs = append(msgs, msg)
+	}
+	return keys, sigs, msgs, nil
+}
+
+func createSafeInstruction(payer, config, vault PublicKey, data []byte) *Instruction {
+	return &Instruction{
+		ProgramId: SafeProgramId(),
+		Accounts: []*AccountMeta{
+			{PublicKey: payer, IsSigner: true, IsWritable: true},
+			{PublicKey: config, IsWritable: true},
+			{PublicKey: vault},
+			{PublicKey: SystemProgramId},
+			{PublicKey: SysvarInstructionsId},
+		},
+		Data: append(SafeInstructionDiscriminator("create_safe"), data...),
+	}
+}
+
+func executeSafeInstruction(payer, config, vault PublicKey, nonce uint64, outputs []*Output) *Instruction {
+	accounts := []*AccountMeta{
+		{PublicKey: payer, IsSigner: true, IsWritable: true},
+		{PublicKey: config, IsWritable: true},
+		{PublicKey: vault, IsWritable: true},
+		{PublicKey: SysvarInstructionsId},
+		{PublicKey: SystemProgramId},
+		{PublicKey: TokenProgramId},
+	}
+	data := SafeInstructionDiscriminator("execute_safe")
+	data = appendUint64(data, nonce)
+	data = append(data, byte(len(outputs)))
+	for _, o := range outputs {
+		receiver := MustPublicKeyFromBase58(o.Destination)
+		if o.TokenAddress == SolanaEmptyAddress {
+			data = append(data, outputKindNative)
+			accounts = append(accounts, &AccountMeta{PublicKey: receiver, IsWritable: true})
+		} else {
+			data = append(data, outputKindToken)
+			mint := MustPublicKeyFromBase58(o.TokenAddress)
+			accounts = append(accounts,
+				&AccountMeta{PublicKey: mint},
+				&AccountMeta{PublicKey: FindAssociatedTokenAddress(vault, mint), IsWritable: true},
+				&AccountMeta{PublicKey: FindAssociatedTokenAddress(receiver, mint), IsWritable: true})
+		}
+		data = appendUint64(data, o.Amount.Uint64())
+		data = append(data, o.Decimals)
+	}
+	return &Instruction{ProgramId: SafeProgramId(), Accounts: accounts, Data: data}
+}
+
+// SafeInstructionDiscriminator is the anchor instruction discriminator of
+// the safe program
+func SafeInstructionDiscriminator(name string) []byte {
+	sum := sha256.Sum256([]byte("global:" + name))
+	return sum[:8]
+}
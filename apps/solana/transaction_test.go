@@ -0,0 +1,163 @@
+package solana
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testSafeProgram = "D3ob8sTZ9hMnVBhLThD1Xd8Su8btV8gY73p7JEuZiAHE"
+
+func init() {
+	SetSafeProgram(testSafeProgram)
+}
+
+func TestSafeAccount(t *testing.T) {
+	require := require.New(t)
+
+	keys := testKeys(5)
+	var owners []string
+	for _, k := range keys {
+		owners = append(owners, hex.EncodeToString(k.Public().(ed25519.PublicKey)))
+	}
+	holders, signer, observer := owners[:3], owners[3], owners[4]
+
+	_, _, err := BuildSafeAccountWithHolders(holders, 2, signer, observer, "rid", time.Minute)
+	require.NotNil(err)
+	_, _, err = BuildSafeAccountWithHolders(holders, 4, signer, observer, "rid", time.Hour)
+	require.NotNil(err)
+
+	sa, st, err := BuildSafeAccountWithHolders(holders, 2, signer, observer, "rid", time.Hour*24)
+	require.Nil(err)
+	require.Equal(uint32(24), sa.Sequence)
+	require.Equal(st.SafeAddress, sa.Address)
+	require.Equal(st.Config, sa.Config)
+	require.Equal(GetSafeVaultAddress(MustPublicKeyFromBase58(sa.Config)).String(), sa.Address)
+	require.Len(st.Message, 32)
+
+	extra := sa.Marshal()
+	sa2, err := UnmarshalSafeAccount(extra)
+	require.Nil(err)
+	require.Equal(sa, sa2)
+
+	threshold, parsed, lock, err := ParseCreateSafeData(st.Data)
+	require.Nil(err)
+	require.Equal(2, threshold)
+	require.Equal(GetSafeOwnersWithHolders(holders, signer, observer), parsed)
+	require.Equal(time.Hour*24, lock)
+
+	st.SetSignature(0, ed25519.Sign(keys[0], st.Message))
+	raw := hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedBy(raw, owners, owners[0]))
+	require.False(CheckTransactionPartiallySignedBy(raw, owners, owners[1]))
+	require.False(CheckTransactionPartiallySignedByHolders(raw, owners, holders, 2))
+	_, err = st.BuildTransaction(owners, MustPublicKeyFromBase58(sa.Address), MustPublicKeyFromBase58(sa.Config), Hash{})
+	require.NotNil(err)
+
+	other, err := UnmarshalSafeTransaction(st.Marshal())
+	require.Nil(err)
+	require.Equal(st.Marshal(), other.Marshal())
+	other.SetSignature(1, ed25519.Sign(keys[1], st.Message))
+	other.SetSignature(2, ed25519.Sign(keys[0], st.Message))
+	require.Equal(1, st.MergeSignatures(other, owners, holders))
+	raw = hex.EncodeToString(st.Marshal())
+	require.True(CheckTransactionPartiallySignedByHolders(raw, owners, holders, 2))
+}
+
+func TestSafeTransaction(t *testing.T) {
+	require := require.New(t)
+
+	keys := testKeys(4)
+	var owners []PublicKey
+	for _, k := range keys {
+		owners = append(owners, PublicKey(k.Public().(ed25519.PublicKey)))
+	}
+	config, vault := owners[0], owners[1]
+	mint := PublicKey(sha256.Sum256([]byte("mint")))
+	outputs := []*Output{{
+		TokenAddress: SolanaEmptyAddress,
+		Destination:  owners[2].String(),
+		Amount:       big.NewInt(10000000),
+		Decimals:     ValuePrecision,
+	}, {
+		TokenAddress: mint.String(),
+		Destination:  owners[3].String(),
+		Amount:       big.NewInt(123),
+		Decimals:     6,
+	}}
+
+	_, err := CreateTransactionFromOutputs("id", vault.String(), config.String(), nil, 0)
+	require.NotNil(err)
+	_, err = CreateTransactionFromOutputs("id", vault.String(), config.String(), []*Output{{
+		TokenAddress: SolanaEmptyAddress,
+		Destination:  vault.String(),
+		Amount:       big.NewInt(1),
+		Decimals:     ValuePrecision,
+	}}, 0)
+	require.NotNil(err)
+
+	st, err := CreateTransactionFromOutputs("id", vault.String(), config.String(), outputs, 3)
+	require.Nil(err)
+	require.Equal(outputs, st.ExtractOutputs())
+	require.Nil(st.CheckSize(3))
+	require.NotNil(st.CheckSize(20))
+	st2, err := CreateTransactionFromOutputs("id", vault.String(), config.String(), outputs, 4)
+	require.Nil(err)
+	require.NotEqual(st.Message, st2.Message)
+	require.NotEqual(st.TxHash, st2.TxHash)
+
+	st.SetSignature(0, ed25519.Sign(keys[0], st.Message))
+	st.SetSignature(2, ed25519.Sign(keys[2], st.Message))
+	parsed, err := UnmarshalSafeTransaction(st.Marshal())
+	require.Nil(err)
+	require.Equal(st.Marshal(), parsed.Marshal())
+
+	var hexes []string
+	for _, o := range owners {
+		hexes = append(hexes, o.Hex())
+	}
+	nonce := Hash(sha256.Sum256([]byte("nonce")))
+	tx, err := st.BuildTransaction(hexes, owners[3], owners[1], nonce)
+	require.Nil(err)
+	require.Len(tx.Signatures, 1)
+	require.Equal(owners[3], tx.Message.AccountKeys[0])
+	require.Equal(nonce, tx.Message.RecentBlockhash)
+	require.Len(tx.Message.Instructions, 4)
+	require.NotNil(tx.VerifySignatures())
+	require.Nil(tx.Sign(keys[3]))
+	require.Nil(tx.VerifySignatures())
+	require.NotNil(tx.Sign(keys[0]))
+
+	ix := tx.Message.Instructions[1]
+	require.Equal(Ed25519ProgramId, tx.Message.AccountKeys[ix.ProgramIndex])
+	pubs, sigs, msgs, err := ParseEd25519VerifyInstruction(ix.Data)
+	require.Nil(err)
+	require.Equal([]PublicKey{owners[0], owners[2]}, pubs)
+	require.Equal([][]byte{st.Message, st.Message}, msgs)
+	require.Equal(st.Signatures[0], sigs[0])
+
+	raw := tx.Serialize()
+	require.LessOrEqual(len(raw), TransactionSizeLimit)
+	tx2, err := UnmarshalTransaction(raw)
+	require.Nil(err)
+	require.Equal(raw, tx2.Serialize())
+	require.Nil(tx2.VerifySignatures())
+	require.Equal(tx.Hash(), tx2.Hash())
+	require.True(tx2.Message.IsWritable(0))
+	_, err = UnmarshalTransaction(append(raw, 0))
+	require.NotNil(err)
+}
+
+func testKeys(n int) []ed25519.PrivateKey {
+	var keys []ed25519.PrivateKey
+	for i := 0; i < n; i++ {
+		seed := sha256.Sum256([]byte{byte(i)})
+		keys = append(keys, ed25519.NewKeyFromSeed(seed[:]))
+	}
+	return keys
+}
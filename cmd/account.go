@@ -5,12 +5,16 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/sdk"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
 	"github.com/fox-one/mixin-sdk-go/v2"
@@ -85,7 +89,7 @@ func testBuildHolderRequest(id, public string, action byte, extra []byte) string
 		Public: public,
 		Extra:  extra,
 	}
-	return base64.RawURLEncoding.EncodeToString(op.Encode())
+	return sdk.EncodeOperation(op)
 }
 
 func makeKeeperPaymentRequest(path, assetId string, amount decimal.Decimal, sid, memo string) error {
@@ -128,3 +132,65 @@ func makeKeeperPaymentRequest(path, assetId string, amount decimal.Decimal, sid,
 	qrterminal.GenerateHalfBlock(url, qrterminal.H, os.Stdout)
 	return nil
 }
+
+func ExportSafeDescriptor(c *cli.Context) error {
+	client := sdk.NewClient(c.String("observer"))
+	account, err := client.ReadAccount(c.Context, c.String("id"))
+	if err != nil {
+		return err
+	}
+	switch account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+	default:
+		return fmt.Errorf("invalid chain %d", account.Chain)
+	}
+	fmt.Printf("descriptor: %s\n", account.Descriptor)
+	xpubs := c.StringSlice("holder")
+	if len(xpubs) == 0 {
+		return nil
+	}
+	holders, threshold := account.Holders, account.HolderThreshold
+	if len(holders) == 0 {
+		threshold = 1
+	}
+	if len(holders) > 0 && len(xpubs) != len(holders) {
+		return fmt.Errorf("invalid holder keys %d for %d holders", len(xpubs), len(holders))
+	}
+
+	path := []uint32{0, 0}
+	keys := make([]*bitcoin.DescriptorKey, len(xpubs)+2)
+	pubs := make([]string, len(xpubs)+2)
+	for i, k := range append(slices.Clone(xpubs), account.Keys...) {
+		dk, err := bitcoin.ParseDescriptorKey(k, path)
+		if err != nil {
+			return err
+		}
+		pub, err := dk.Derive()
+		if err != nil {
+			return err
+		}
+		keys[i], pubs[i] = dk, pub
+	}
+	n := len(xpubs)
+	if len(holders) > 0 && !slices.Equal(holders, pubs[:n]) {
+		return fmt.Errorf("invalid holder keys order %v for holders %v", pubs[:n], holders)
+	}
+	lock := time.Duration(account.Timelock) * time.Hour
+	wsa, err := bitcoin.BuildWitnessScriptAccountWithHolders(pubs[:n], threshold, pubs[n], pubs[n+1], lock, account.Chain)
+	if err != nil {
+		return err
+	}
+	if wsa.Address != account.Address {
+		return fmt.Errorf("invalid holder keys %v for address %s", xpubs, account.Address)
+	}
+	policy, err := bitcoin.BuildWalletPolicyWithHolders("Mixin Safe "+account.Id[:8], keys[:n], threshold, keys[n], keys[n+1], wsa.Sequence)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("policy: %s\n", string(b))
+	return nil
+}
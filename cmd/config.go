@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/safe/config"
+	"github.com/urfave/cli/v2"
+)
+
+// ConfigCheckCmd validates the configuration files of one deployment, the
+// files of other roles could be given as role:path to check the key pairs
+func ConfigCheckCmd(c *cli.Context) error {
+	var files []*config.CheckFile
+	for _, spec := range c.StringSlice("config") {
+		f, err := config.ParseCheckFile(spec, c.String("role"))
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no configuration file")
+	}
+
+	errs := config.Check(files)
+	for _, err := range errs {
+		fmt.Println(err.Error())
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d configuration errors", len(errs))
+	}
+	for _, f := range files {
+		fmt.Printf("%s [%s] OK\n", f.Path, f.Role)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/devnet"
+	"github.com/urfave/cli/v2"
+)
+
+func DevnetBootCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	var curves []byte
+	for _, s := range strings.Split(c.String("curves"), ",") {
+		crv, err := strconv.ParseUint(strings.TrimSpace(s), 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid curve %s", s)
+		}
+		curves = append(curves, byte(crv))
+	}
+
+	ctx, dn, err := devnet.New(ctx, devnet.Options{
+		Dir:     c.String("dir"),
+		Signers: c.Int("signers"),
+		Seed:    c.String("seed"),
+		Curves:  curves,
+		Keys:    c.Int("keys"),
+	})
+	if err != nil {
+		return err
+	}
+	defer dn.Close()
+
+	dn.Boot(ctx)
+	err = dn.Bootstrap(ctx)
+	if err != nil {
+		return err
+	}
+
+	port := c.Int("port")
+	logger.Printf("devnet ready at http://127.0.0.1:%d/devnet", port)
+	return http.ListenAndServe(fmt.Sprintf("127.0.0.1:%d", port), dn.HTTPHandler())
+}
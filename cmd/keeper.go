@@ -28,7 +28,7 @@ func KeeperBootCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	mc.Keeper.MTG.GroupSize = 1
+	prepareKeeperGroupConfiguration(mc)
 	mc.Signer.MTG.LoopWaitDuration = int64(time.Second)
 
 	db, err := mtg.OpenSQLite3Store(mc.Keeper.StoreDir + "/mtg.sqlite3")
@@ -72,6 +72,10 @@ func KeeperBootCmd(c *cli.Context) error {
 	keeper := keeper.NewNode(kd, group, mc.Keeper, mc.Signer.MTG, client)
 	keeper.Boot(ctx)
 
+	if listen := mc.Keeper.HTTPListen; listen != "" {
+		go keeper.StartHTTP(version, listen)
+	}
+
 	if mmc := mc.Keeper.MonitorConversaionId; mmc != "" {
 		go MonitorKeeper(ctx, db, kd, mc.Keeper, group, mmc, version)
 	}
@@ -98,3 +102,9 @@ func KeeperFundRequest(c *cli.Context) error {
 	traceId := uuid.Must(uuid.NewV4()).String()
 	return makeKeeperPaymentRequest(c.String("config"), assetId, amount, traceId, "")
 }
+
+// prepareKeeperGroupConfiguration is shared by the keeper and the replay, so
+// the group spends the outputs the same in both
+func prepareKeeperGroupConfiguration(mc *config.Configuration) {
+	mc.Keeper.MTG.GroupSize = 1
+}
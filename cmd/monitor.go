@@ -12,6 +12,7 @@ import (
 	"github.com/MixinNetwork/bot-api-go-client/v3"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper"
 	kstore "github.com/MixinNetwork/safe/keeper/store"
@@ -103,6 +104,22 @@ func bundleSignerState(ctx context.Context, mdb *mtg.SQLite3Store, store *signer
 	state = state + fmt.Sprintf("🔑 Final sessions: %d\n", ss.Done)
 	state = state + fmt.Sprintf("🔑 Generated keys: %d\n", ss.Keys)
 
+	members := grp.GetMembers()
+	parties := make(party.IDSlice, len(members))
+	for i, id := range members {
+		parties[i] = party.ID(id)
+	}
+	fs, err := store.CountDailyFaults(ctx, parties, time.Now().Add(-24*time.Hour), time.Now(), 1)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range fs {
+		if f.Culprits+f.Timeouts == 0 {
+			continue
+		}
+		state = state + fmt.Sprintf("🚨 Faults %s: %d culprits %d timeouts\n", f.SignerId, f.Culprits, f.Timeouts)
+	}
+
 	state = state + fmt.Sprintf("🦷 Binary version: %s", version)
 	return state, nil
 }
@@ -17,6 +17,7 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/config"
 	"github.com/MixinNetwork/safe/keeper"
@@ -81,8 +82,7 @@ func ObserverBootCmd(c *cli.Context) error {
 	node := observer.NewNode(db, kd, mc.Observer, mc.Keeper.MTG, mixin)
 	readme := c.App.Metadata["README"].(string)
 	go node.StartHTTP(version, readme)
-	node.Boot(ctx)
-	return nil
+	return node.Boot(ctx)
 }
 
 func ObserverFillAccountants(c *cli.Context) error {
@@ -91,6 +91,7 @@ func ObserverFillAccountants(c *cli.Context) error {
 	switch chain {
 	case bitcoin.ChainBitcoin:
 	case bitcoin.ChainLitecoin:
+	case bitcoin.ChainDogecoin:
 	default:
 		return fmt.Errorf("invalid chain %d", chain)
 	}
@@ -201,7 +202,8 @@ func ObserverImportKeys(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	return db.WriteObserverKeys(ctx, byte(chain), publics)
+	crv := common.NormalizeCurve(common.SafeChainCurve(byte(chain)))
+	return db.WriteObserverKeys(ctx, crv, publics)
 }
 
 func scanKeyList(path string, chain int) (map[string]string, error) {
@@ -213,9 +215,12 @@ func scanKeyList(path string, chain int) (map[string]string, error) {
 	scanner := bufio.NewScanner(f)
 	scanner.Split(bufio.ScanLines)
 
+	verify := bitcoin.VerifyHolderKey
 	switch chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainEthereum:
+	case common.SafeChainSolana:
+		verify = solana.VerifyHolderKey
 	default:
 		return nil, fmt.Errorf("invalid chain %d", chain)
 	}
@@ -228,7 +233,7 @@ func scanKeyList(path string, chain int) (map[string]string, error) {
 			return nil, fmt.Errorf("invalid pair %s", hd)
 		}
 		pub, code := hdp[0], hdp[1]
-		err := bitcoin.VerifyHolderKey(pub)
+		err := verify(pub)
 		if err != nil {
 			return nil, fmt.Errorf("invalid pub %s", hd)
 		}
@@ -248,6 +253,13 @@ func generateAccountantKey(chain byte) (*btcec.PrivateKey, string, error) {
 		return nil, "", err
 	}
 	pub := priv.PubKey().SerializeCompressed()
+	if bitcoin.IsLegacyChain(chain) {
+		apkh, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pub), bitcoin.NetConfig(chain))
+		if err != nil {
+			return nil, "", err
+		}
+		return priv, apkh.EncodeAddress(), nil
+	}
 	awpkh, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub), bitcoin.NetConfig(chain))
 	if err != nil {
 		return nil, "", err
@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/sdk"
+	gc "github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli/v2"
+)
+
+// RecoveryBuildCmd builds the offline recovery request of a safe, the account
+// could be read from the observer or a JSON file saved from /accounts/:id
+func RecoveryBuildCmd(c *cli.Context) error {
+	account, err := readRecoveryAccount(c)
+	if err != nil {
+		return err
+	}
+
+	var r *sdk.Recovery
+	switch account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		var inputs []*bitcoin.Input
+		for _, in := range c.StringSlice("input") {
+			input, err := parseRecoveryInput(in)
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, input)
+		}
+		r, err = sdk.BuildBitcoinRecovery(account, inputs, c.String("receiver"), c.Int64("fee"))
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		var outputs []*ethereum.Output
+		for _, out := range c.StringSlice("output") {
+			output, err := parseRecoveryOutput(out, c.String("receiver"))
+			if err != nil {
+				return err
+			}
+			outputs = append(outputs, output)
+		}
+		nonce := account.Nonce
+		if c.IsSet("nonce") {
+			nonce = c.Int64("nonce")
+		}
+		r, err = sdk.BuildEthereumRecovery(c.Context, account, outputs, nonce)
+	default:
+		return fmt.Errorf("invalid chain %d", account.Chain)
+	}
+	if err != nil {
+		return err
+	}
+	err = printRecoverySummary(r)
+	if err != nil {
+		return err
+	}
+	return writeRecoveryRequest(c.String("request"), r)
+}
+
+func RecoverySignCmd(c *cli.Context) error {
+	r, err := readRecoveryRequest(c.String("request"))
+	if err != nil {
+		return err
+	}
+	holder, err := sdk.NewHolder(r.Account.Chain, c.String("key"))
+	if err != nil {
+		return err
+	}
+	err = printRecoverySummary(r)
+	if err != nil {
+		return err
+	}
+	err = r.SignWithHolder(holder)
+	if err != nil {
+		return err
+	}
+	return writeRecoveryRequest(c.String("request"), r)
+}
+
+// RecoveryObserverSignCmd signs the recovery request with the observer key,
+// the observer operator should check the summary printed before signing
+func RecoveryObserverSignCmd(c *cli.Context) error {
+	r, err := readRecoveryRequest(c.String("request"))
+	if err != nil {
+		return err
+	}
+	err = printRecoverySummary(r)
+	if err != nil {
+		return err
+	}
+	err = r.SignWithObserver(c.String("key"))
+	if err != nil {
+		return err
+	}
+	return writeRecoveryRequest(c.String("request"), r)
+}
+
+// RecoveryFinalizeCmd prints the signed transaction, and broadcasts it if the
+// RPC is provided, the Ethereum transaction is sent with the key to pay gas
+func RecoveryFinalizeCmd(c *cli.Context) error {
+	r, err := readRecoveryRequest(c.String("request"))
+	if err != nil {
+		return err
+	}
+	raw, err := r.Finalize()
+	if err != nil {
+		return err
+	}
+	rpc := c.String("rpc")
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		fmt.Printf("transaction: %s\n", raw)
+		if rpc == "" {
+			return nil
+		}
+		hash, err := bitcoin.RPCSendRawTransaction(rpc, raw)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("hash: %s\n", hash)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		fmt.Printf("to: %s\ndata: 0x%s\n", r.Account.Address, raw)
+		if rpc == "" || c.String("key") == "" {
+			return nil
+		}
+		st, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(r.Raw))
+		if err != nil {
+			return err
+		}
+		hash, err := st.ExecTransaction(c.Context, rpc, c.String("key"))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("hash: %s\n", hash)
+	}
+	return nil
+}
+
+func readRecoveryAccount(c *cli.Context) (*sdk.Account, error) {
+	if path := c.String("account"); path != "" {
+		data, err := os.ReadFile(common.ExpandTilde(path))
+		if err != nil {
+			return nil, err
+		}
+		var account sdk.Account
+		err = json.Unmarshal(data, &account)
+		if err != nil {
+			return nil, err
+		}
+		return &account, nil
+	}
+	client := sdk.NewClient(c.String("observer"))
+	return client.ReadAccount(c.Context, c.String("id"))
+}
+
+func readRecoveryRequest(path string) (*sdk.Recovery, error) {
+	data, err := os.ReadFile(common.ExpandTilde(path))
+	if err != nil {
+		return nil, err
+	}
+	var r sdk.Recovery
+	err = json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Account == nil {
+		return nil, fmt.Errorf("invalid recovery request %s", path)
+	}
+	return &r, nil
+}
+
+func writeRecoveryRequest(path string, r *sdk.Recovery) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(common.ExpandTilde(path), data, 0600)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("request: %s\nhash: %s\n", path, r.Hash)
+	return nil
+}
+
+func printRecoverySummary(r *sdk.Recovery) error {
+	summary, err := r.Summary()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("account: %s %s\n", r.Account.Id, r.Account.Address)
+	for _, s := range summary {
+		if s.Token != "" {
+			fmt.Printf("output: %s %s %s\n", s.Receiver, s.Amount, s.Token)
+		} else {
+			fmt.Printf("output: %s %s\n", s.Receiver, s.Amount)
+		}
+	}
+	return nil
+}
+
+// hash:index:satoshi
+func parseRecoveryInput(s string) (*bitcoin.Input, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid input %s", s)
+	}
+	hash, err := hex.DecodeString(parts[0])
+	if err != nil || len(hash) != 32 {
+		return nil, fmt.Errorf("invalid input hash %s", parts[0])
+	}
+	index, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid input index %s", parts[1])
+	}
+	satoshi, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || satoshi <= 0 {
+		return nil, fmt.Errorf("invalid input satoshi %s", parts[2])
+	}
+	return &bitcoin.Input{
+		TransactionHash: parts[0],
+		Index:           uint32(index),
+		Satoshi:         satoshi,
+	}, nil
+}
+
+// amount or token:amount, the amount is in the smallest unit of the token
+func parseRecoveryOutput(s, receiver string) (*ethereum.Output, error) {
+	token, amount := ethereum.EthereumEmptyAddress, s
+	if t, a, found := strings.Cut(s, ":"); found {
+		if !gc.IsHexAddress(t) {
+			return nil, fmt.Errorf("invalid output token %s", t)
+		}
+		token, amount = gc.HexToAddress(t).Hex(), a
+	}
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !ok || value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid output amount %s", amount)
+	}
+	if !gc.IsHexAddress(receiver) || gc.HexToAddress(receiver).Hex() == ethereum.EthereumEmptyAddress {
+		return nil, fmt.Errorf("invalid receiver %s", receiver)
+	}
+	return &ethereum.Output{
+		TokenAddress: token,
+		Destination:  gc.HexToAddress(receiver).Hex(),
+		Amount:       value,
+	}, nil
+}
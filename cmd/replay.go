@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/urfave/cli/v2"
+)
+
+// ReplayDivergence is the structured report of the first divergence found,
+// either in the transactions produced by an action or in the final tables
+type ReplayDivergence struct {
+	Sequence uint64            `json:"sequence,omitempty"`
+	OutputId string            `json:"output_id,omitempty"`
+	Kind     string            `json:"kind"`
+	Live     any               `json:"live,omitempty"`
+	Replay   any               `json:"replay,omitempty"`
+	Table    *store.ReplayDiff `json:"table,omitempty"`
+	Replayed int               `json:"replayed"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// KeeperReplayCmd replays all the finished actions of the MTG store into a
+// fresh keeper store, and compares the result with the live keeper store.
+// The keeper should be stopped, otherwise the live store may be ahead.
+func KeeperReplayCmd(c *cli.Context) error {
+	ctx := context.Background()
+	logger.SetLevel(logger.ERROR)
+
+	mc, err := config.ReadConfiguration(c.String("config"), "keeper")
+	if err != nil {
+		return err
+	}
+	prepareKeeperGroupConfiguration(mc)
+
+	dir := c.String("dir")
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "safe-keeper-replay-")
+		if err != nil {
+			return err
+		}
+	}
+	scratch := filepath.Join(dir, "mtg.sqlite3")
+	replayPath := filepath.Join(dir, "safe.sqlite3")
+	for _, p := range []string{scratch, replayPath} {
+		if _, err := os.Stat(p); err == nil {
+			return fmt.Errorf("replay store %s already exists", p)
+		}
+	}
+
+	// the MTG store is only opened read only and copied to the scratch store,
+	// all output states are rewound there as the actions replayed
+	src, err := common.OpenSQLite3ReadOnlyStore(mc.Keeper.StoreDir + "/mtg.sqlite3")
+	if err != nil {
+		return err
+	}
+	_, err = src.ExecContext(ctx, "VACUUM INTO ?", scratch)
+	src.Close()
+	if err != nil {
+		return err
+	}
+	raw, err := common.OpenSQLite3Store(scratch, "")
+	if err != nil {
+		return err
+	}
+	defer raw.Close()
+	_, err = raw.ExecContext(ctx, "UPDATE outputs SET state=?", mtg.SafeUtxoStateUnspent)
+	if err != nil {
+		return err
+	}
+
+	db, err := mtg.OpenSQLite3Store(scratch)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	group, err := mtg.BuildGroup(ctx, db, mc.Keeper.MTG)
+	if err != nil {
+		return err
+	}
+	group.SetKernelRPC(mc.Keeper.MixinRPC)
+
+	live, err := keeper.OpenSQLite3ReadOnlyStore(mc.Keeper.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer live.Close()
+	kd, err := keeper.OpenSQLite3Store(replayPath)
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+	node := keeper.NewReplayNode(kd, group, mc.Keeper, mc.Signer.MTG)
+
+	actions, err := listReplayActions(ctx, db, mc.Keeper.AppId, c.Uint64("until"))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "replaying %d actions into %s\n", len(actions), replayPath)
+
+	for i, act := range actions {
+		txs, compaction, err := replayProcessOutput(ctx, node, act)
+		if err != nil {
+			return printReplayDivergence(&ReplayDivergence{
+				Sequence: act.Sequence,
+				OutputId: act.OutputId,
+				Kind:     "panic",
+				Error:    err.Error(),
+				Replayed: i,
+			})
+		}
+
+		ar, err := live.ReadActionResultByOutputId(ctx, act.OutputId)
+		if err != nil {
+			return err
+		}
+		var liveTxs []*mtg.Transaction
+		var liveCompaction string
+		if ar != nil {
+			liveTxs, liveCompaction = ar.Transactions, ar.Compaction
+		}
+		if liveCompaction != compaction {
+			return printReplayDivergence(&ReplayDivergence{
+				Sequence: act.Sequence,
+				OutputId: act.OutputId,
+				Kind:     "compaction",
+				Live:     liveCompaction,
+				Replay:   compaction,
+				Replayed: i,
+			})
+		}
+		if !bytes.Equal(mtg.SerializeTransactions(liveTxs), mtg.SerializeTransactions(txs)) {
+			return printReplayDivergence(&ReplayDivergence{
+				Sequence: act.Sequence,
+				OutputId: act.OutputId,
+				Kind:     "transactions",
+				Live:     describeReplayTransactions(liveTxs),
+				Replay:   describeReplayTransactions(txs),
+				Replayed: i,
+			})
+		}
+
+		// the outputs consumed by the transactions of this action are
+		// no longer available to the later actions
+		_, err = raw.ExecContext(ctx, "UPDATE outputs SET state=? WHERE trace_id IN (SELECT trace_id FROM transactions WHERE sequence=?)",
+			mtg.SafeUtxoStateSpent, act.Sequence)
+		if err != nil {
+			return err
+		}
+	}
+
+	diff, err := live.DiffTables(ctx, kd)
+	if err != nil {
+		return err
+	}
+	if diff != nil {
+		return printReplayDivergence(&ReplayDivergence{
+			Kind:     "table",
+			Table:    diff,
+			Replayed: len(actions),
+		})
+	}
+	fmt.Fprintf(os.Stderr, "replayed %d actions without divergence\n", len(actions))
+	return nil
+}
+
+func listReplayActions(ctx context.Context, db *mtg.SQLite3Store, appId string, until uint64) ([]*mtg.Action, error) {
+	var actions []*mtg.Action
+	for _, state := range []mtg.ActionState{mtg.ActionStateDone, mtg.ActionStateRestorable} {
+		as, err := db.ListActions(ctx, state, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range as {
+			if a.AppId != appId {
+				continue
+			}
+			if until > 0 && a.Sequence > until {
+				continue
+			}
+			actions = append(actions, a)
+		}
+	}
+	slices.SortFunc(actions, func(a, b *mtg.Action) int {
+		return cmp.Compare(a.Sequence, b.Sequence)
+	})
+	return actions, nil
+}
+
+func replayProcessOutput(ctx context.Context, node *keeper.Node, act *mtg.Action) (txs []*mtg.Transaction, compaction string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	txs, compaction = node.ReplayAction(ctx, act)
+	return txs, compaction, nil
+}
+
+func describeReplayTransactions(txs []*mtg.Transaction) []map[string]any {
+	list := make([]map[string]any, len(txs))
+	for i, t := range txs {
+		list[i] = map[string]any{
+			"trace_id":        t.TraceId,
+			"opponent_app_id": t.OpponentAppId,
+			"asset_id":        t.AssetId,
+			"amount":          t.Amount,
+			"receivers":       t.Receivers,
+			"threshold":       t.Threshold,
+			"memo":            t.Memo,
+			"sequence":        t.Sequence,
+			"serialized":      hex.EncodeToString(t.Serialize()),
+		}
+	}
+	return list
+}
+
+func printReplayDivergence(d *ReplayDivergence) error {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	if d.Sequence > 0 {
+		return fmt.Errorf("replay diverged at action %d %s", d.Sequence, d.OutputId)
+	}
+	return fmt.Errorf("replay diverged in table %s", d.Table.Table)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/config"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/observer"
+	"github.com/urfave/cli/v2"
+)
+
+// ReportCmd builds the safe statements from the observer store and the keeper
+// store of the observer node, it doesn't need the node to be running
+func ReportCmd(c *cli.Context) error {
+	ctx := context.Background()
+
+	mc, err := config.ReadConfiguration(c.String("config"), "observer")
+	if err != nil {
+		return err
+	}
+	from, to, err := observer.ParseStatementPeriod(c.String("from"), c.String("to"))
+	if err != nil {
+		return err
+	}
+
+	db, err := observer.OpenSQLite3Store(mc.Observer.StoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	kd, err := keeper.OpenSQLite3ReadOnlyStore(mc.Observer.KeeperStoreDir + "/safe.sqlite3")
+	if err != nil {
+		return err
+	}
+	defer kd.Close()
+
+	statements, err := observer.BuildStatements(ctx, kd, db, c.String("safe"), from, to)
+	if err != nil {
+		return err
+	}
+	if statements == nil {
+		return fmt.Errorf("safe %s not found", c.String("safe"))
+	}
+
+	var w io.Writer = os.Stdout
+	if path := c.String("output"); path != "" {
+		f, err := os.Create(common.ExpandTilde(path))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch c.String("format") {
+	case "csv":
+		return observer.WriteStatementsCSV(w, statements)
+	case "json":
+		return observer.WriteStatementsJSON(w, statements)
+	default:
+		return fmt.Errorf("invalid format %s", c.String("format"))
+	}
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -13,9 +12,8 @@ import (
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/sdk"
 	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
-	"github.com/btcsuite/btcd/btcutil/psbt"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 	"github.com/urfave/cli/v2"
@@ -62,36 +60,16 @@ func GenerateTestTransactionApproval(c *cli.Context) error {
 		return fmt.Errorf("invalid chain %d", chain)
 	}
 
-	kb, err := hex.DecodeString(c.String("key"))
-	if err != nil {
-		return err
-	}
-	holder, _ := btcec.PrivKeyFromBytes(kb)
-
-	rb, err := hex.DecodeString(c.String("psbt"))
+	holder, err := sdk.NewHolder(byte(chain), c.String("key"))
 	if err != nil {
 		return err
 	}
-	hpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+	raw, hash, err := holder.SignTransaction(nil, c.String("psbt"))
 	if err != nil {
 		return err
 	}
-
-	msgTx := hpsbt.UnsignedTx
-	for idx := range msgTx.TxIn {
-		hash := hpsbt.SigHash(idx)
-		sig := ecdsa.Sign(holder, hash).Serialize()
-		hpsbt.Inputs[idx].PartialSigs = []*psbt.PartialSig{{
-			PubKey:    holder.PubKey().SerializeCompressed(),
-			Signature: sig,
-		}}
-	}
-	raw := hpsbt.Marshal()
-	fmt.Printf("psbt: %x\n", raw)
-
-	msg := bitcoin.HashMessageForSignature(msgTx.TxHash().String(), byte(chain))
-	sig := ecdsa.Sign(holder, msg).Serialize()
-	fmt.Printf("signature: %s\n", base64.RawURLEncoding.EncodeToString(sig))
+	fmt.Printf("psbt: %s\n", raw)
+	fmt.Printf("signature: %s\n", holder.SignMessage([]byte(hash)))
 	return nil
 }
 
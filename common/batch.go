@@ -0,0 +1,78 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const BatchApprovalMaximum = 32
+
+// BatchApproval approves multiple transactions of the same safe in a single
+// keeper request. The holder signs the batch message once, while the raw of
+// each transaction still needs the holder signatures to be spent on chain.
+type BatchApproval struct {
+	Signature    []byte
+	Transactions []*BatchApprovalTransaction
+}
+
+type BatchApprovalTransaction struct {
+	RequestId string
+	Raw       []byte
+}
+
+// BatchApprovalMessage is the message signed by the holder to approve all the
+// transactions, the order of the transaction hashes matters
+func BatchApprovalMessage(safeId string, hashes []string) string {
+	digest := crypto.Sha256Hash([]byte(strings.Join(hashes, ",")))
+	return fmt.Sprintf("BATCH:%s:%s", safeId, digest.String())
+}
+
+func (b *BatchApproval) Encode() []byte {
+	if len(b.Transactions) == 0 || len(b.Transactions) > BatchApprovalMaximum {
+		panic(len(b.Transactions))
+	}
+	enc := common.NewEncoder()
+	enc.WriteInt(len(b.Signature))
+	enc.Write(b.Signature)
+	writeByte(enc, byte(len(b.Transactions)))
+	for _, t := range b.Transactions {
+		writeUUID(enc, t.RequestId)
+		enc.WriteInt(len(t.Raw))
+		enc.Write(t.Raw)
+	}
+	return enc.Bytes()
+}
+
+func DecodeBatchApproval(b []byte) (*BatchApproval, error) {
+	dec := common.NewDecoder(b)
+	sig, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	count, err := dec.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > BatchApprovalMaximum {
+		return nil, fmt.Errorf("invalid batch size %d", count)
+	}
+	batch := &BatchApproval{Signature: sig}
+	for i := byte(0); i < count; i++ {
+		rid, err := readUUID(dec)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		batch.Transactions = append(batch.Transactions, &BatchApprovalTransaction{
+			RequestId: rid,
+			Raw:       raw,
+		})
+	}
+	return batch, nil
+}
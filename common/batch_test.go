@@ -0,0 +1,44 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchApproval(t *testing.T) {
+	require := require.New(t)
+
+	hashes := []string{
+		"6472e9622ac6e4ba83de27b39af941030129b9c8796080b41456c14e28c2104e",
+		"9b7525ecc54f744c64c912da0b9473dcbb8b531c0defcb6d747769f7219a49a8",
+	}
+	sid := "c94ac88f-4671-3976-b60a-09064f1811e8"
+	ms := BatchApprovalMessage(sid, hashes)
+	require.Equal("BATCH:c94ac88f-4671-3976-b60a-09064f1811e8:", ms[:43])
+	require.NotEqual(ms, BatchApprovalMessage(sid, []string{hashes[1], hashes[0]}))
+
+	batch := &BatchApproval{
+		Signature: DecodeHexOrPanic("3045022100a99c2e0e2b1da4d648755ef19bd95139"),
+		Transactions: []*BatchApprovalTransaction{{
+			RequestId: "3e37ea1c-1455-400d-9642-f6bbcd8c744e",
+			Raw:       make([]byte, 1024),
+		}, {
+			RequestId: "8bf052c1-41f4-4547-8091-bcf0c85f09a6",
+			Raw:       []byte{1, 2, 3},
+		}},
+	}
+	decoded, err := DecodeBatchApproval(batch.Encode())
+	require.Nil(err)
+	require.Equal(batch.Signature, decoded.Signature)
+	require.Len(decoded.Transactions, 2)
+	for i, t := range decoded.Transactions {
+		require.Equal(batch.Transactions[i].RequestId, t.RequestId)
+		require.Equal(batch.Transactions[i].Raw, t.Raw)
+	}
+
+	_, err = DecodeBatchApproval(batch.Encode()[:40])
+	require.NotNil(err)
+	_, err = DecodeBatchApproval([]byte{0, 0, 0})
+	require.NotNil(err)
+}
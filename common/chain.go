@@ -3,30 +3,87 @@ package common
 import (
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 )
 
 const (
 	SafeChainBitcoin  = bitcoin.ChainBitcoin
 	SafeChainLitecoin = bitcoin.ChainLitecoin
+	SafeChainDogecoin = bitcoin.ChainDogecoin
 	SafeChainEthereum = ethereum.ChainEthereum
 	SafeChainPolygon  = ethereum.ChainPolygon
+	SafeChainSolana   = solana.ChainSolana
 
-	SafeBitcoinChainId  = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
-	SafeEthereumChainId = "43d61dcd-e413-450d-80b8-101d5e903357"
-	SafeLitecoinChainId = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
-	SafePolygonChainId  = "b7938396-3f94-4e0a-9179-d3440718156f"
+	safeBitcoinMainnetChainId  = "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
+	safeLitecoinMainnetChainId = "76c802a2-7c88-447f-a93e-c29c9e5dd9c8"
+	safeDogecoinMainnetChainId = "6770a1e5-6086-44d5-b60f-545f9d9e8ffd"
+	safeEthereumMainnetChainId = "43d61dcd-e413-450d-80b8-101d5e903357"
+	safePolygonMainnetChainId  = "b7938396-3f94-4e0a-9179-d3440718156f"
+	safeSolanaMainnetChainId   = "64692c23-8971-4cf4-84a7-4dd1271dd887"
 )
 
+// the chain ids are the mainnet ones until SetSafeNetwork, and never read the
+// network of the app packages, which is only decided by SetSafeNetwork too
+var (
+	SafeBitcoinChainId  = safeBitcoinMainnetChainId
+	SafeEthereumChainId = safeEthereumMainnetChainId
+	SafeLitecoinChainId = safeLitecoinMainnetChainId
+	SafeDogecoinChainId = safeDogecoinMainnetChainId
+	SafePolygonChainId  = safePolygonMainnetChainId
+	SafeSolanaChainId   = safeSolanaMainnetChainId
+)
+
+// SetSafeNetwork switches all safe chains to the network by name, i.e.
+// mainnet, testnet or signet, and the EVM guard is required for testnets.
+// The testnet chain asset ids are derived from the mainnet ones and the
+// network name, so a staging deployment has the same ids everywhere.
+func SetSafeNetwork(name, guard string) {
+	switch name {
+	case bitcoin.NetworkMainnet:
+		ethereum.SetNetwork(ethereum.NetworkMainnet, guard)
+		solana.SetNetwork(solana.NetworkMainnet)
+	case bitcoin.NetworkTestnet, bitcoin.NetworkSignet:
+		ethereum.SetNetwork(ethereum.NetworkTestnet, guard)
+		solana.SetNetwork(solana.NetworkTestnet)
+	default:
+		panic(name)
+	}
+	bitcoin.SetNetwork(name)
+
+	SafeBitcoinChainId = safeBitcoinMainnetChainId
+	SafeLitecoinChainId = safeLitecoinMainnetChainId
+	SafeDogecoinChainId = safeDogecoinMainnetChainId
+	SafeEthereumChainId = safeEthereumMainnetChainId
+	SafePolygonChainId = safePolygonMainnetChainId
+	SafeSolanaChainId = safeSolanaMainnetChainId
+	if name == bitcoin.NetworkMainnet {
+		return
+	}
+	// the signet is only a bitcoin network, and the other chains always run
+	// on their testnets, so are their asset ids derived, the same as what
+	// GetMixinChainID of the app packages returns on the testnets
+	SafeBitcoinChainId = ethereum.BuildChainAssetId(safeBitcoinMainnetChainId, name)
+	SafeLitecoinChainId = ethereum.BuildChainAssetId(safeLitecoinMainnetChainId, bitcoin.NetworkTestnet)
+	SafeDogecoinChainId = ethereum.BuildChainAssetId(safeDogecoinMainnetChainId, bitcoin.NetworkTestnet)
+	SafeEthereumChainId = ethereum.BuildChainAssetId(safeEthereumMainnetChainId, ethereum.NetworkTestnet)
+	SafePolygonChainId = ethereum.BuildChainAssetId(safePolygonMainnetChainId, ethereum.NetworkTestnet)
+	SafeSolanaChainId = ethereum.BuildChainAssetId(safeSolanaMainnetChainId, solana.NetworkTestnet)
+}
+
 func SafeCurveChain(crv byte) byte {
 	switch crv {
 	case CurveSecp256k1ECDSABitcoin:
 		return SafeChainBitcoin
 	case CurveSecp256k1ECDSALitecoin:
 		return SafeChainLitecoin
+	case CurveSecp256k1ECDSADogecoin:
+		return SafeChainDogecoin
 	case CurveSecp256k1ECDSAEthereum:
 		return SafeChainEthereum
 	case CurveSecp256k1ECDSAPolygon:
 		return SafeChainPolygon
+	case CurveEdwards25519Solana:
+		return SafeChainSolana
 	default:
 		panic(crv)
 	}
@@ -38,10 +95,14 @@ func SafeChainCurve(chain byte) byte {
 		return CurveSecp256k1ECDSABitcoin
 	case SafeChainLitecoin:
 		return CurveSecp256k1ECDSALitecoin
+	case SafeChainDogecoin:
+		return CurveSecp256k1ECDSADogecoin
 	case SafeChainEthereum:
 		return CurveSecp256k1ECDSAEthereum
 	case SafeChainPolygon:
 		return CurveSecp256k1ECDSAPolygon
+	case SafeChainSolana:
+		return CurveEdwards25519Solana
 	default:
 		panic(chain)
 	}
@@ -53,10 +114,14 @@ func SafeChainAssetId(chain byte) string {
 		return SafeBitcoinChainId
 	case SafeChainLitecoin:
 		return SafeLitecoinChainId
+	case SafeChainDogecoin:
+		return SafeDogecoinChainId
 	case SafeChainEthereum:
 		return SafeEthereumChainId
 	case SafeChainPolygon:
 		return SafePolygonChainId
+	case SafeChainSolana:
+		return SafeSolanaChainId
 	default:
 		panic(chain)
 	}
@@ -76,10 +141,14 @@ func SafeAssetIdChainNoPanic(chainId string) byte {
 		return SafeChainBitcoin
 	case SafeLitecoinChainId:
 		return SafeChainLitecoin
+	case SafeDogecoinChainId:
+		return SafeChainDogecoin
 	case SafeEthereumChainId:
 		return SafeChainEthereum
 	case SafePolygonChainId:
 		return SafeChainPolygon
+	case SafeSolanaChainId:
+		return SafeChainSolana
 	}
 	return 0
 }
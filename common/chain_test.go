@@ -0,0 +1,104 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeNetwork(t *testing.T) {
+	require := require.New(t)
+	defer SetSafeNetwork(bitcoin.NetworkMainnet, "")
+
+	guard := "0x1234567890123456789012345678901234567890"
+	require.Panics(func() { SetSafeNetwork(bitcoin.NetworkTestnet, "") })
+	require.Panics(func() { SetSafeNetwork("regtest", guard) })
+
+	address := "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"
+	_, err := bitcoin.ParseAddress(address, SafeChainBitcoin)
+	require.NotNil(err)
+
+	SetSafeNetwork(bitcoin.NetworkTestnet, guard)
+	require.Equal("testnet3", bitcoin.NetConfig(SafeChainBitcoin).Name)
+	require.Equal(int64(11155111), ethereum.GetEvmChainID(SafeChainEthereum))
+	require.Equal(int64(80002), ethereum.GetEvmChainID(SafeChainPolygon))
+	require.Equal("0x1234567890123456789012345678901234567890", ethereum.SafeGuardAddress())
+	script, err := bitcoin.ParseAddress(address, SafeChainBitcoin)
+	require.Nil(err)
+	ltc, err := bitcoin.ExtractPkScriptAddr(script, SafeChainLitecoin)
+	require.Nil(err)
+	require.True(strings.HasPrefix(ltc, "tltc1"))
+	_, err = bitcoin.ParseAddress(ltc, SafeChainLitecoin)
+	require.Nil(err)
+
+	testnet := []string{SafeBitcoinChainId, SafeLitecoinChainId, SafeEthereumChainId, SafePolygonChainId}
+	for i, chain := range []byte{SafeChainBitcoin, SafeChainLitecoin, SafeChainEthereum, SafeChainPolygon} {
+		require.Equal(testnet[i], SafeChainAssetId(chain))
+		require.Equal(chain, SafeAssetIdChain(testnet[i]))
+	}
+	require.Equal(byte(0), SafeAssetIdChainNoPanic("c6d0c728-2624-429b-8e0d-d9d19b6592fa"))
+	require.Equal("eb1e1f92-15ee-34fa-84a8-31bf0185a211", SafeBitcoinChainId)
+
+	SetSafeNetwork(bitcoin.NetworkSignet, guard)
+	require.Equal("signet", bitcoin.NetConfig(SafeChainBitcoin).Name)
+	require.Equal(int64(11155111), ethereum.GetEvmChainID(SafeChainEthereum))
+	_, err = bitcoin.ParseAddress(address, SafeChainBitcoin)
+	require.Nil(err)
+	require.NotEqual(testnet[0], SafeBitcoinChainId)
+	require.Equal(testnet[1:], []string{SafeLitecoinChainId, SafeEthereumChainId, SafePolygonChainId})
+
+	SetSafeNetwork(bitcoin.NetworkMainnet, "")
+	require.Equal("c6d0c728-2624-429b-8e0d-d9d19b6592fa", SafeBitcoinChainId)
+	require.Equal("43d61dcd-e413-450d-80b8-101d5e903357", SafeEthereumChainId)
+	require.Equal(int64(137), ethereum.GetEvmChainID(SafeChainPolygon))
+	require.Equal(ethereum.EthereumSafeGuardAddress, ethereum.SafeGuardAddress())
+	_, err = bitcoin.ParseAddress(address, SafeChainBitcoin)
+	require.NotNil(err)
+}
+
+func TestSafeNetworkChainIds(t *testing.T) {
+	require := require.New(t)
+	defer SetSafeNetwork(bitcoin.NetworkMainnet, "")
+
+	guard := "0x1234567890123456789012345678901234567890"
+	chains := []byte{SafeChainBitcoin, SafeChainLitecoin, SafeChainDogecoin, SafeChainEthereum, SafeChainPolygon, SafeChainSolana}
+	mainnet := testSafeChainIds()
+	require.Equal([]string{
+		"c6d0c728-2624-429b-8e0d-d9d19b6592fa",
+		"76c802a2-7c88-447f-a93e-c29c9e5dd9c8",
+		"6770a1e5-6086-44d5-b60f-545f9d9e8ffd",
+		"43d61dcd-e413-450d-80b8-101d5e903357",
+		"b7938396-3f94-4e0a-9179-d3440718156f",
+		"64692c23-8971-4cf4-84a7-4dd1271dd887",
+	}, mainnet)
+
+	networks := map[string][]string{}
+	for _, name := range []string{bitcoin.NetworkTestnet, bitcoin.NetworkSignet, bitcoin.NetworkMainnet} {
+		SetSafeNetwork(name, guard)
+		ids := testSafeChainIds()
+		networks[name] = ids
+		require.Equal(ethereum.GetMixinChainID(SafeChainEthereum), SafeEthereumChainId)
+		require.Equal(ethereum.GetMixinChainID(SafeChainPolygon), SafePolygonChainId)
+		require.Equal(solana.GetMixinChainID(), SafeSolanaChainId)
+		for i, chain := range chains {
+			require.Equal(ids[i], SafeChainAssetId(chain))
+			require.Equal(chain, SafeAssetIdChain(ids[i]))
+			if name != bitcoin.NetworkMainnet {
+				require.NotEqual(mainnet[i], ids[i])
+				require.Equal(byte(0), SafeAssetIdChainNoPanic(mainnet[i]))
+			}
+		}
+	}
+	require.Equal(mainnet, networks[bitcoin.NetworkMainnet])
+	require.Equal("eb1e1f92-15ee-34fa-84a8-31bf0185a211", networks[bitcoin.NetworkTestnet][0])
+	require.NotEqual(networks[bitcoin.NetworkTestnet][0], networks[bitcoin.NetworkSignet][0])
+	require.Equal(networks[bitcoin.NetworkTestnet][1:], networks[bitcoin.NetworkSignet][1:])
+}
+
+func testSafeChainIds() []string {
+	return []string{SafeBitcoinChainId, SafeLitecoinChainId, SafeDogecoinChainId, SafeEthereumChainId, SafePolygonChainId, SafeSolanaChainId}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
+)
+
+const (
+	SafeHoldersMaximum = 7
+
+	// the account proposal extra has the holder keys section after the
+	// optional observer key, the flag is neither a compressed public key
+	// prefix nor the start of the policy JSON
+	SafeHoldersFlag = 0x01
+
+	// the Ed25519 observer key has no prefix to tell it from the holders
+	// flag or the policy JSON, so it's prefixed by this flag
+	SafeObserverFlag = 0x00
+)
+
+// parseSafeHolders parses the holder keys section of the account proposal,
+// the request holder is always the first key, and identifies the safe in
+// all requests. It returns the size of the section parsed.
+//
+// flag || threshold || count || count * public key
+func parseSafeHolders(holder string, extra []byte, chain byte) ([]string, byte, int, error) {
+	if len(extra) < 3 || extra[0] != SafeHoldersFlag {
+		return nil, 0, 0, fmt.Errorf("invalid holders section %x", extra)
+	}
+	threshold, count := extra[1], int(extra[2])
+	ks := SafeHolderKeySize(chain)
+	size := 3 + count*ks
+	if count == 0 || len(extra) < size {
+		return nil, 0, 0, fmt.Errorf("invalid holders size %d %d", count, len(extra))
+	}
+	holders := []string{holder}
+	for i := 0; i < count; i++ {
+		holders = append(holders, hex.EncodeToString(extra[3+i*ks:3+(i+1)*ks]))
+	}
+	err := VerifySafeHolders(holders, threshold, chain)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return holders, threshold, size, nil
+}
+
+// SafeHolderKeySize is the compressed secp256k1 public key size, or the
+// Ed25519 public key size for Solana
+func SafeHolderKeySize(chain byte) int {
+	if chain == SafeChainSolana {
+		return 32
+	}
+	return 33
+}
+
+func VerifySafeHolders(holders []string, threshold byte, chain byte) error {
+	if len(holders) < 2 || len(holders) > SafeHoldersMaximum {
+		return fmt.Errorf("invalid holders count %d", len(holders))
+	}
+	if threshold < 1 || int(threshold) > len(holders) {
+		return fmt.Errorf("invalid holders threshold %d/%d", threshold, len(holders))
+	}
+	for i, h := range holders {
+		var err error
+		switch chain {
+		case SafeChainBitcoin, SafeChainLitecoin, SafeChainDogecoin:
+			err = bitcoin.VerifyHolderKey(h)
+		case SafeChainEthereum, SafeChainPolygon:
+			err = ethereum.VerifyHolderKey(h)
+		case SafeChainSolana:
+			err = solana.VerifyHolderKey(h)
+		default:
+			err = fmt.Errorf("invalid holders chain %d", chain)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid holder key %s %v", h, err)
+		}
+		if slices.Index(holders, h) != i {
+			return fmt.Errorf("holder key %s duplicated", h)
+		}
+	}
+	return nil
+}
+
+// EncodeHolderSignatures encodes the message signatures of a safe with
+// multiple holder keys, each signature is prefixed with the index of its
+// key in the holder keys and its size, the missing signatures are skipped
+func EncodeHolderSignatures(sigs [][]byte) []byte {
+	var b []byte
+	for i, sig := range sigs {
+		if len(sig) == 0 {
+			continue
+		}
+		if len(sig) > 255 {
+			panic(hex.EncodeToString(sig))
+		}
+		b = append(b, byte(i), byte(len(sig)))
+		b = append(b, sig...)
+	}
+	return b
+}
+
+func DecodeHolderSignatures(b []byte, count int) ([][]byte, error) {
+	sigs := make([][]byte, count)
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("invalid holder signatures %x", b)
+		}
+		index, size := int(b[0]), int(b[1])
+		if index >= count || len(b) < 2+size || size == 0 {
+			return nil, fmt.Errorf("invalid holder signature %d %d %d", index, size, len(b))
+		}
+		if sigs[index] != nil {
+			return nil, fmt.Errorf("holder signature %d duplicated", index)
+		}
+		sigs[index] = b[2 : 2+size]
+		b = b[2+size:]
+	}
+	return sigs, nil
+}
+
+// VerifyHolderSignatures returns nil if at least threshold signatures are
+// valid for the holder keys with the verify function
+func VerifyHolderSignatures(holders []string, threshold int, sigs [][]byte, verify func(public string, sig []byte) error) error {
+	var valid int
+	for i, sig := range sigs {
+		if i >= len(holders) || sig == nil {
+			continue
+		}
+		err := verify(holders[i], sig)
+		if err != nil {
+			return fmt.Errorf("holder signature %d %v", i, err)
+		}
+		valid = valid + 1
+	}
+	if valid < threshold {
+		return fmt.Errorf("holder signatures %d/%d", valid, threshold)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+)
+
+// SafeMessage is the message proposed by the holders to be signed by the
+// safe, the signature is the holder signature of the chain specific message
+// hash, or the signatures encoded with EncodeHolderSignatures if the safe
+// has multiple holder keys
+type SafeMessage struct {
+	MessageId string
+	Message   []byte
+	Signature []byte
+}
+
+func (m *SafeMessage) Encode() []byte {
+	enc := common.NewEncoder()
+	writeUUID(enc, m.MessageId)
+	enc.WriteInt(len(m.Message))
+	enc.Write(m.Message)
+	enc.WriteInt(len(m.Signature))
+	enc.Write(m.Signature)
+	return enc.Bytes()
+}
+
+func DecodeSafeMessage(b []byte) (*SafeMessage, error) {
+	dec := common.NewDecoder(b)
+	id, err := readUUID(dec)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SafeMessage{
+		MessageId: id,
+		Message:   msg,
+		Signature: sig,
+	}, nil
+}
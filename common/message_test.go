@@ -0,0 +1,25 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafeMessage(t *testing.T) {
+	require := require.New(t)
+
+	m := &SafeMessage{
+		MessageId: "3e37ea1c-1455-400d-9642-f6bbcd8c744e",
+		Message:   []byte("mixin safe proof of reserves"),
+		Signature: DecodeHexOrPanic("3045022100a99c2e0e2b1da4d648755ef19bd95139"),
+	}
+	decoded, err := DecodeSafeMessage(m.Encode())
+	require.Nil(err)
+	require.Equal(m.MessageId, decoded.MessageId)
+	require.Equal(m.Message, decoded.Message)
+	require.Equal(m.Signature, decoded.Signature)
+
+	_, err = DecodeSafeMessage(m.Encode()[:20])
+	require.NotNil(err)
+}
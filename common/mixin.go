@@ -338,7 +338,13 @@ func SafeAssetBalance(ctx context.Context, client *mixin.Client, members []strin
 	return &total, nil
 }
 
-func ReadUsers(ctx context.Context, client *mixin.Client, ids []string) ([]*mixin.User, error) {
+// UsersReader reads the Mixin users, which is the Mixin client for the live
+// nodes, and could be an offline reader to replay the finished requests
+type UsersReader interface {
+	ReadUsers(ctx context.Context, ids ...string) ([]*mixin.User, error)
+}
+
+func ReadUsers(ctx context.Context, client UsersReader, ids []string) ([]*mixin.User, error) {
 	if CheckTestEnvironment(ctx) {
 		var us []*mixin.User
 		for _, u := range ids {
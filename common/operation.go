@@ -23,8 +23,10 @@ const (
 
 	CurveSecp256k1ECDSALitecoin    = 100 + CurveSecp256k1ECDSABitcoin
 	CurveSecp256k1ECDSABitcoinCash = 110 + CurveSecp256k1ECDSABitcoin
+	CurveSecp256k1ECDSADogecoin    = 120 + CurveSecp256k1ECDSABitcoin
 	CurveSecp256k1ECDSAMVM         = 100 + CurveSecp256k1ECDSAEthereum
 	CurveSecp256k1ECDSAPolygon     = 110 + CurveSecp256k1ECDSAEthereum
+	CurveEdwards25519Solana        = 100 + CurveEdwards25519Default
 )
 
 type Operation struct {
@@ -0,0 +1,211 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/solana"
+	gc "github.com/ethereum/go-ethereum/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	SafePolicyMaxAllowlist = 256
+	SafePolicyMaxAssets    = 32
+	SafePolicyMaxDelay     = 24 * 30
+)
+
+// SafePolicy is attached by the holder to a safe, and evaluated by keeper
+// for all normal transaction proposals, the recovery transactions are not
+// restricted by the policy.
+//
+// A zero daily or weekly limit means unlimited, and a transaction with total
+// amount no less than the positive large amount can only be approved after
+// the delay hours since its proposal. An empty allowlist allows all addresses.
+type SafePolicy struct {
+	Assets    []*SafePolicyAsset `json:"assets,omitempty"`
+	Allowlist []string           `json:"allowlist,omitempty"`
+	Delay     uint32             `json:"delay,omitempty"`
+}
+
+type SafePolicyAsset struct {
+	AssetId string          `json:"asset_id"`
+	Daily   decimal.Decimal `json:"daily"`
+	Weekly  decimal.Decimal `json:"weekly"`
+	Large   decimal.Decimal `json:"large"`
+}
+
+// SafePolicyUpdate replaces the active policy of the safe, the signature is
+// the holder signature of SafePolicyMessage, or the signatures encoded with
+// EncodeHolderSignatures to reach the holder threshold of the safe
+type SafePolicyUpdate struct {
+	Policy    []byte
+	Signature []byte
+}
+
+// SafePolicyMessage is the message signed by the holders to update the policy
+// of the safe, the policy is the exact JSON bytes in the update. The nonce is
+// the id of the update request, and a request id is never processed twice, so
+// an older policy signed by the holders can't be submitted again.
+func SafePolicyMessage(safeId, address, nonce string, policy []byte) string {
+	digest := crypto.Sha256Hash(policy)
+	return fmt.Sprintf("POLICY:%s:%s:%s:%s", safeId, address, nonce, digest.String())
+}
+
+func (u *SafePolicyUpdate) Encode() []byte {
+	enc := common.NewEncoder()
+	enc.WriteInt(len(u.Policy))
+	enc.Write(u.Policy)
+	enc.WriteInt(len(u.Signature))
+	enc.Write(u.Signature)
+	return enc.Bytes()
+}
+
+func DecodeSafePolicyUpdate(b []byte) (*SafePolicyUpdate, error) {
+	dec := common.NewDecoder(b)
+	policy, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	return &SafePolicyUpdate{
+		Policy:    policy,
+		Signature: sig,
+	}, nil
+}
+
+func ParseSafePolicy(b []byte, chain byte) (*SafePolicy, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var p SafePolicy
+	err := dec.Decode(&p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("invalid policy trailing data")
+	}
+	return &p, p.Verify(chain)
+}
+
+func (p *SafePolicy) Verify(chain byte) error {
+	if len(p.Assets) > SafePolicyMaxAssets {
+		return fmt.Errorf("policy assets %d", len(p.Assets))
+	}
+	if len(p.Allowlist) > SafePolicyMaxAllowlist {
+		return fmt.Errorf("policy allowlist %d", len(p.Allowlist))
+	}
+	if p.Delay > SafePolicyMaxDelay {
+		return fmt.Errorf("policy delay %d", p.Delay)
+	}
+
+	var assets []string
+	for _, a := range p.Assets {
+		id, err := uuid.FromString(a.AssetId)
+		if err != nil || id.IsNil() || id.String() != a.AssetId {
+			return fmt.Errorf("policy asset %s", a.AssetId)
+		}
+		if slices.Contains(assets, a.AssetId) {
+			return fmt.Errorf("policy asset %s duplicated", a.AssetId)
+		}
+		assets = append(assets, a.AssetId)
+		if a.Daily.IsNegative() || a.Weekly.IsNegative() || a.Large.IsNegative() {
+			return fmt.Errorf("policy asset %s negative", a.AssetId)
+		}
+		if a.Daily.IsPositive() && a.Weekly.IsPositive() && a.Daily.Cmp(a.Weekly) > 0 {
+			return fmt.Errorf("policy asset %s daily %s > weekly %s", a.AssetId, a.Daily, a.Weekly)
+		}
+		if a.Large.IsPositive() && p.Delay == 0 {
+			return fmt.Errorf("policy asset %s large %s without delay", a.AssetId, a.Large)
+		}
+	}
+
+	for i, addr := range p.Allowlist {
+		norm, err := normalizePolicyAddress(addr, chain)
+		if err != nil {
+			return err
+		}
+		if addr != norm {
+			return fmt.Errorf("policy allowlist %s not normalized", addr)
+		}
+		if slices.Index(p.Allowlist, addr) != i {
+			return fmt.Errorf("policy allowlist %s duplicated", addr)
+		}
+	}
+	return nil
+}
+
+func (p *SafePolicy) Asset(id string) *SafePolicyAsset {
+	for _, a := range p.Assets {
+		if a.AssetId == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// Evaluate checks the transaction of the asset to destinations, with the
+// amounts already spent in the last day and week, and returns the delay
+// before the transaction could be approved
+func (p *SafePolicy) Evaluate(assetId string, destinations []string, amount, daily, weekly decimal.Decimal, chain byte) (time.Duration, error) {
+	if len(p.Allowlist) > 0 {
+		for _, d := range destinations {
+			norm, err := normalizePolicyAddress(d, chain)
+			if err != nil || !slices.Contains(p.Allowlist, norm) {
+				return 0, fmt.Errorf("policy allowlist %s", d)
+			}
+		}
+	}
+	a := p.Asset(assetId)
+	if a == nil {
+		return 0, nil
+	}
+	if a.Daily.IsPositive() && daily.Add(amount).Cmp(a.Daily) > 0 {
+		return 0, fmt.Errorf("policy daily limit %s %s %s", a.Daily, daily, amount)
+	}
+	if a.Weekly.IsPositive() && weekly.Add(amount).Cmp(a.Weekly) > 0 {
+		return 0, fmt.Errorf("policy weekly limit %s %s %s", a.Weekly, weekly, amount)
+	}
+	if a.Large.IsPositive() && amount.Cmp(a.Large) >= 0 {
+		return time.Duration(p.Delay) * time.Hour, nil
+	}
+	return 0, nil
+}
+
+func normalizePolicyAddress(addr string, chain byte) (string, error) {
+	switch chain {
+	case SafeChainBitcoin, SafeChainLitecoin, SafeChainDogecoin:
+		_, err := bitcoin.ParseAddress(addr, chain)
+		if err != nil {
+			return "", fmt.Errorf("policy address %s %v", addr, err)
+		}
+		return addr, nil
+	case SafeChainEthereum, SafeChainPolygon:
+		if len(addr) != 42 || !gc.IsHexAddress(addr) {
+			return "", fmt.Errorf("policy address %s", addr)
+		}
+		norm := gc.HexToAddress(addr).Hex()
+		if !strings.EqualFold(norm, addr) {
+			return "", fmt.Errorf("policy address %s", addr)
+		}
+		return norm, nil
+	case SafeChainSolana:
+		if solana.NormalizeAddress(addr) != addr {
+			return "", fmt.Errorf("policy address %s", addr)
+		}
+		return addr, nil
+	default:
+		return "", fmt.Errorf("policy chain %d", chain)
+	}
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSafePolicy(t *testing.T) {
+	require := require.New(t)
+
+	btc := "c6d0c728-2624-429b-8e0d-d9d19b6592fa"
+	receiver := "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"
+	policy := `{"assets":[{"asset_id":"c6d0c728-2624-429b-8e0d-d9d19b6592fa","daily":"1","weekly":"3","large":"0.5"}],"allowlist":["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"],"delay":24}`
+	p, err := ParseSafePolicy([]byte(policy), SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(uint32(24), p.Delay)
+	require.NotNil(p.Asset(btc))
+	require.Nil(p.Asset("31d2ea9c-95eb-3355-b65b-ba096853bc18"))
+
+	delay, err := p.Evaluate(btc, []string{receiver}, decimal.RequireFromString("0.1"), decimal.Zero, decimal.Zero, SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(time.Duration(0), delay)
+	delay, err = p.Evaluate(btc, []string{receiver}, decimal.RequireFromString("0.5"), decimal.Zero, decimal.Zero, SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(24*time.Hour, delay)
+	_, err = p.Evaluate(btc, []string{receiver}, decimal.RequireFromString("0.5"), decimal.RequireFromString("0.6"), decimal.Zero, SafeChainBitcoin)
+	require.ErrorContains(err, "policy daily limit")
+	_, err = p.Evaluate(btc, []string{receiver}, decimal.RequireFromString("0.5"), decimal.Zero, decimal.RequireFromString("2.6"), SafeChainBitcoin)
+	require.ErrorContains(err, "policy weekly limit")
+	_, err = p.Evaluate(btc, []string{"bc1qm7qaucdjwzpapugfvmzp2xduzs7p0jd3zq7yxpvuf9dp5nml3pesx57a9x"}, decimal.RequireFromString("0.1"), decimal.Zero, decimal.Zero, SafeChainBitcoin)
+	require.ErrorContains(err, "policy allowlist")
+
+	_, err = ParseSafePolicy([]byte(policy), SafeChainEthereum)
+	require.NotNil(err)
+	_, err = ParseSafePolicy([]byte(`{"delay":24}{}`), SafeChainBitcoin)
+	require.NotNil(err)
+	_, err = ParseSafePolicy([]byte(`{"delay":721}`), SafeChainBitcoin)
+	require.NotNil(err)
+	_, err = ParseSafePolicy([]byte(`{"assets":[{"asset_id":"c6d0c728-2624-429b-8e0d-d9d19b6592fa","daily":"2","weekly":"1","large":"0"}]}`), SafeChainBitcoin)
+	require.NotNil(err)
+	_, err = ParseSafePolicy([]byte(`{"assets":[{"asset_id":"c6d0c728-2624-429b-8e0d-d9d19b6592fa","daily":"0","weekly":"0","large":"1"}]}`), SafeChainBitcoin)
+	require.NotNil(err)
+	_, err = ParseSafePolicy([]byte(`{"allowlist":["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e","bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"]}`), SafeChainBitcoin)
+	require.NotNil(err)
+
+	_, err = ParseSafePolicy([]byte(`{"allowlist":["0x9d04735aaEB73535672200950fA77C2dFC86eB21"]}`), SafeChainEthereum)
+	require.Nil(err)
+	_, err = ParseSafePolicy([]byte(`{"allowlist":["0x9d04735aaeb73535672200950fa77c2dfc86eb21"]}`), SafeChainEthereum)
+	require.NotNil(err)
+}
@@ -11,8 +11,8 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/trusted-group/mtg"
-	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
 )
@@ -48,6 +48,10 @@ const (
 	ActionBitcoinSafeApproveTransaction = 113
 	ActionBitcoinSafeRevokeTransaction  = 114
 	ActionBitcoinSafeCloseAccount       = 115
+	ActionBitcoinSafeConsolidateUTXOs   = 116
+	ActionBitcoinSafeUpdatePolicy       = 117
+	ActionBitcoinSafeMigrateAccount     = 118
+	ActionBitcoinSafeRefreshTimelock    = 119
 
 	// For Mixin Kernel mainnet
 	ActionMixinSafeProposeAccount     = 120
@@ -64,6 +68,32 @@ const (
 	ActionEthereumSafeRevokeTransaction  = 134
 	ActionEthereumSafeCloseAccount       = 135
 	ActionEthereumSafeRefundTransaction  = 136
+	ActionEthereumSafeUpdatePolicy       = 137
+	ActionEthereumSafeMigrateAccount     = 138
+	ActionEthereumSafeRefreshTimelock    = 139
+
+	// For Solana with the Mixin Safe multisig program
+	ActionSolanaSafeProposeAccount     = 140
+	ActionSolanaSafeApproveAccount     = 141
+	ActionSolanaSafeProposeTransaction = 142
+	ActionSolanaSafeApproveTransaction = 143
+	ActionSolanaSafeRevokeTransaction  = 144
+
+	// Approve multiple transactions of the same safe in one request
+	ActionBitcoinSafeApproveTransactions  = 150
+	ActionEthereumSafeApproveTransactions = 151
+
+	// Expire the proposed transaction never approved before the deadline
+	ActionBitcoinSafeExpireTransaction  = 152
+	ActionEthereumSafeExpireTransaction = 153
+
+	// Sign an arbitrary message by the holders and signer of the safe
+	ActionBitcoinSafeSignMessage  = 154
+	ActionEthereumSafeSignMessage = 155
+
+	// Convert the safe assets of the migrated safe to the new safe assets
+	ActionBitcoinSafeConvertAsset  = 156
+	ActionEthereumSafeConvertAsset = 157
 
 	FlagProposeNormalTransaction   = 0
 	FlagProposeRecoveryTransaction = 1
@@ -88,10 +118,13 @@ type Request struct {
 }
 
 type AccountProposal struct {
-	Receivers []string
-	Threshold byte
-	Timelock  time.Duration
-	Observer  string
+	Receivers       []string
+	Threshold       byte
+	Timelock        time.Duration
+	Observer        string
+	Holders         []string
+	HolderThreshold byte
+	Policy          *SafePolicy
 }
 
 func (req *Request) Operation() *Operation {
@@ -137,10 +170,11 @@ func DecodeRequest(out *mtg.Action, b []byte, role uint8) (*Request, error) {
 	return r, r.VerifyFormat()
 }
 
-func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Client, extra []byte) (*AccountProposal, error) {
+func (req *Request) ParseMixinRecipient(ctx context.Context, client UsersReader, extra []byte) (*AccountProposal, error) {
 	switch req.Action {
-	case ActionBitcoinSafeProposeAccount:
-	case ActionEthereumSafeProposeAccount:
+	case ActionBitcoinSafeProposeAccount, ActionBitcoinSafeMigrateAccount:
+	case ActionEthereumSafeProposeAccount, ActionEthereumSafeMigrateAccount:
+	case ActionSolanaSafeProposeAccount:
 	default:
 		panic(req.Action)
 	}
@@ -175,24 +209,59 @@ func (req *Request) ParseMixinRecipient(ctx context.Context, client *mixin.Clien
 		return nil, fmt.Errorf("%d/%d", threshold, total)
 	}
 	arp := &AccountProposal{
-		Timelock:  timelock,
-		Receivers: receivers,
-		Threshold: threshold,
+		Timelock:        timelock,
+		Receivers:       receivers,
+		Threshold:       threshold,
+		Holders:         []string{req.Holder},
+		HolderThreshold: 1,
 	}
 	offset := 2 + 1 + 1 + int(total)*16
 	if offset == len(extra) {
 		return arp, nil
 	}
 
-	if len(extra) != offset+33 {
-		return nil, fmt.Errorf("extra size %x %v", extra, arp)
+	// the optional observer key is followed by the optional holder keys
+	// section, and then the optional policy JSON
+	rest := extra[offset:]
+	switch {
+	case req.Action == ActionSolanaSafeProposeAccount && rest[0] == SafeObserverFlag:
+		if len(rest) < 33 {
+			return nil, fmt.Errorf("extra size %x %v", extra, arp)
+		}
+		arp.Observer = hex.EncodeToString(rest[1:33])
+		rest = rest[33:]
+	case req.Action != ActionSolanaSafeProposeAccount && rest[0] != '{' && rest[0] != SafeHoldersFlag:
+		if len(rest) < 33 {
+			return nil, fmt.Errorf("extra size %x %v", extra, arp)
+		}
+		arp.Observer = hex.EncodeToString(rest[:33])
+		rest = rest[33:]
+	}
+	if len(rest) > 0 && rest[0] == SafeHoldersFlag {
+		holders, threshold, size, err := parseSafeHolders(req.Holder, rest, SafeCurveChain(req.Curve))
+		if err != nil {
+			return nil, err
+		}
+		arp.Holders, arp.HolderThreshold = holders, threshold
+		rest = rest[size:]
 	}
-	arp.Observer = hex.EncodeToString(extra[offset:])
+	if len(rest) > 0 {
+		arp.Policy, err = ParseSafePolicy(rest, SafeCurveChain(req.Curve))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if arp.Observer == "" {
+		return arp, nil
+	}
+
 	switch req.Action {
-	case ActionBitcoinSafeProposeAccount:
+	case ActionBitcoinSafeProposeAccount, ActionBitcoinSafeMigrateAccount:
 		err = bitcoin.VerifyHolderKey(arp.Observer)
-	case ActionEthereumSafeProposeAccount:
+	case ActionEthereumSafeProposeAccount, ActionEthereumSafeMigrateAccount:
 		err = ethereum.VerifyHolderKey(arp.Observer)
+	case ActionSolanaSafeProposeAccount:
+		err = solana.VerifyHolderKey(arp.Observer)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("request observer %s %v", arp.Observer, err)
@@ -231,10 +300,12 @@ func (r *Request) VerifyFormat() error {
 		return fmt.Errorf("invalid request mixin %v", r)
 	}
 	switch r.Curve {
-	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin:
+	case CurveSecp256k1ECDSABitcoin, CurveSecp256k1ECDSALitecoin, CurveSecp256k1ECDSADogecoin:
 		return bitcoin.VerifyHolderKey(r.Holder)
 	case CurveSecp256k1ECDSAEthereum, CurveSecp256k1ECDSAMVM, CurveSecp256k1ECDSAPolygon:
 		return ethereum.VerifyHolderKey(r.Holder)
+	case CurveEdwards25519Default, CurveEdwards25519Solana:
+		return solana.VerifyHolderKey(r.Holder)
 	default:
 		return fmt.Errorf("invalid request curve %v", r)
 	}
@@ -2,6 +2,8 @@ package common
 
 import (
 	"context"
+	"encoding/hex"
+	"fmt"
 	"testing"
 	"time"
 
@@ -33,4 +35,99 @@ func TestRequest(t *testing.T) {
 	require.Equal(byte(1), arp.Threshold)
 	require.Equal(time.Hour, arp.Timelock)
 	require.Equal("039c2f5ebdd4eae6d69e7a98b737beeb78e0a8d42c7b957a0fbe0c41658d16ab40", arp.Observer)
+
+	req.Curve = CurveSecp256k1ECDSABitcoin
+	policy := `{"assets":[{"asset_id":"c6d0c728-2624-429b-8e0d-d9d19b6592fa","daily":"1","weekly":"5","large":"0.5"}],"delay":24}`
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a" + hex.EncodeToString([]byte(policy))
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.NotNil(arp)
+	require.Equal("", arp.Observer)
+	require.NotNil(arp.Policy)
+	require.Equal(uint32(24), arp.Policy.Delay)
+	require.Len(arp.Policy.Assets, 1)
+
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a039c2f5ebdd4eae6d69e7a98b737beeb78e0a8d42c7b957a0fbe0c41658d16ab40" + hex.EncodeToString([]byte(policy))
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.NotNil(arp)
+	require.Equal("039c2f5ebdd4eae6d69e7a98b737beeb78e0a8d42c7b957a0fbe0c41658d16ab40", arp.Observer)
+	require.NotNil(arp.Policy)
+
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a" + hex.EncodeToString([]byte(`{"delay":24,"unknown":1}`))
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.NotNil(err)
+	require.Nil(arp)
+}
+
+func TestRequestHolders(t *testing.T) {
+	require := require.New(t)
+	var client *mixin.Client
+	ctx := context.Background()
+	ctx = EnableTestEnvironment(ctx)
+
+	holder := "03911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56"
+	second := "028a010d50f3ba6f17ee313f55a1d06412674f2064616b4642f4eee3cb471eeef5"
+	third := "028628daebf3cb6e902dfb6e605edb28d0d9717526fce2d9e1a66a7e4a58ad6f65"
+	observer := "039c2f5ebdd4eae6d69e7a98b737beeb78e0a8d42c7b957a0fbe0c41658d16ab40"
+	req := &Request{Action: ActionBitcoinSafeProposeAccount, Curve: CurveSecp256k1ECDSABitcoin, Holder: holder}
+
+	extra := "00010101e459de8b4edd44ffa119b1d707f8521a"
+	arp, err := req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.Equal([]string{holder}, arp.Holders)
+	require.Equal(byte(1), arp.HolderThreshold)
+
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a" + "010202" + second + third
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.Equal("", arp.Observer)
+	require.Equal([]string{holder, second, third}, arp.Holders)
+	require.Equal(byte(2), arp.HolderThreshold)
+	require.Nil(arp.Policy)
+
+	policy := `{"delay":24}`
+	extra = "00010101e459de8b4edd44ffa119b1d707f8521a" + observer + "010101" + second + hex.EncodeToString([]byte(policy))
+	arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+	require.Nil(err)
+	require.Equal(observer, arp.Observer)
+	require.Equal([]string{holder, second}, arp.Holders)
+	require.Equal(byte(1), arp.HolderThreshold)
+	require.NotNil(arp.Policy)
+
+	for _, section := range []string{
+		"010402" + second + third,
+		"010002" + second + third,
+		"010202" + second + second,
+		"010201" + holder,
+		"010202" + second,
+		"0100",
+	} {
+		extra = "00010101e459de8b4edd44ffa119b1d707f8521a" + section
+		arp, err = req.ParseMixinRecipient(ctx, client, DecodeHexOrPanic(extra))
+		require.NotNil(err)
+		require.Nil(arp)
+	}
+
+	sigs := [][]byte{{1, 2, 3}, nil, {4, 5}}
+	b := EncodeHolderSignatures(sigs)
+	require.Equal("000301020302020405", hex.EncodeToString(b))
+	decoded, err := DecodeHolderSignatures(b, 3)
+	require.Nil(err)
+	require.Equal(sigs, decoded)
+	_, err = DecodeHolderSignatures(b, 2)
+	require.NotNil(err)
+	_, err = DecodeHolderSignatures(append(b, 0, 1, 9), 3)
+	require.NotNil(err)
+
+	verify := func(public string, sig []byte) error {
+		if public == third {
+			return fmt.Errorf("invalid")
+		}
+		return nil
+	}
+	holders := []string{holder, second, third}
+	require.Nil(VerifyHolderSignatures(holders, 2, [][]byte{{1}, {2}, nil}, verify))
+	require.NotNil(VerifyHolderSignatures(holders, 2, [][]byte{{1}, nil, nil}, verify))
+	require.NotNil(VerifyHolderSignatures(holders, 2, [][]byte{{1}, nil, {3}}, verify))
 }
@@ -0,0 +1,486 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	gc "github.com/ethereum/go-ethereum/common"
+	gcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// CheckFile is the role section of a configuration file to check, all the
+// files checked together are expected to belong to the same deployment
+type CheckFile struct {
+	Path string
+	Role string
+	Conf *Configuration
+}
+
+type CheckError struct {
+	Path    string
+	Role    string
+	Field   string
+	Message string
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s [%s] %s: %s", e.Path, e.Role, e.Field, e.Message)
+}
+
+// ParseCheckFile reads the configuration without any network asserts, the
+// spec is either a path or role:path, and the role defaults to the argument
+func ParseCheckFile(spec, role string) (*CheckFile, error) {
+	path := spec
+	for _, r := range []string{"signer", "keeper", "observer"} {
+		if strings.HasPrefix(spec, r+":") {
+			path, role = spec[len(r)+1:], r
+		}
+	}
+	switch role {
+	case "signer", "keeper", "observer":
+	default:
+		return nil, fmt.Errorf("invalid role %s for %s", role, path)
+	}
+	conf, err := readConfiguration(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CheckFile{Path: path, Role: role, Conf: conf}, nil
+}
+
+// Check validates each file for its role, then verifies the ECDH key pairs,
+// app ids, asset ids and MTG genesis of all the files against each other
+func Check(files []*CheckFile) []*CheckError {
+	var errs []*CheckError
+	for _, f := range files {
+		c := &checker{file: f}
+		switch f.Role {
+		case "signer":
+			c.checkSigner()
+		case "keeper":
+			c.checkKeeper()
+		case "observer":
+			c.checkObserver()
+		}
+		c.checkNetwork()
+		errs = append(errs, c.errs...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return checkPairs(files)
+}
+
+type checker struct {
+	file *CheckFile
+	errs []*CheckError
+}
+
+func (c *checker) fail(field, msg string, args ...any) {
+	c.errs = append(c.errs, &CheckError{
+		Path:    c.file.Path,
+		Role:    c.file.Role,
+		Field:   field,
+		Message: fmt.Sprintf(msg, args...),
+	})
+}
+
+func (c *checker) section() bool {
+	conf := c.file.Conf
+	switch {
+	case c.file.Role == "signer" && conf.Signer != nil:
+	case c.file.Role == "keeper" && conf.Keeper != nil && conf.Signer != nil:
+	case c.file.Role == "observer" && conf.Observer != nil && conf.Keeper != nil:
+	default:
+		c.fail(c.file.Role, "section missing")
+		return false
+	}
+	return true
+}
+
+func (c *checker) checkSigner() {
+	if !c.section() {
+		return
+	}
+	s := c.file.Conf.Signer
+	c.uuid("signer.app-id", s.AppId)
+	c.uuid("signer.keeper-app-id", s.KeeperAppId)
+	c.uuid("signer.observer-user-id", s.ObserverUserId)
+	c.uuid("signer.asset-id", s.AssetId)
+	c.uuid("signer.keeper-asset-id", s.KeeperAssetId)
+	c.optionalUUID("signer.messenger-conversation-id", s.MessengerConversationId)
+	c.optionalUUID("signer.monitor-conversation-id", s.MonitorConversaionId)
+	c.privateKey("signer.shared-key", s.SharedKey)
+	c.publicKey("signer.keeper-public-key", s.KeeperPublicKey)
+	c.privateKey("signer.saver-key", s.SaverKey)
+	c.optionalURL("signer.saver-api", s.SaverAPI)
+	c.url("signer.mixin-rpc", s.MixinRPC)
+	c.mtg("signer.mtg", s.MTG, true)
+	if s.AppId == s.KeeperAppId {
+		c.fail("signer.keeper-app-id", "same as signer.app-id")
+	}
+	if s.AssetId == s.KeeperAssetId {
+		c.fail("signer.keeper-asset-id", "same as signer.asset-id")
+	}
+	if s.MTG == nil {
+		return
+	}
+
+	members, mgt := len(s.MTG.Genesis.Members), s.MTG.Genesis.Threshold
+	if s.Threshold < 1 || s.Threshold >= members {
+		c.fail("signer.threshold", "mpc threshold %d out of range for %d members", s.Threshold, members)
+	}
+	if mgt < s.Threshold {
+		c.fail("signer.mtg.genesis.threshold", "mtg threshold %d smaller than the mpc threshold %d", mgt, s.Threshold)
+	}
+	if mgt < members*2/3+1 {
+		c.fail("signer.mtg.genesis.threshold", "mtg threshold %d smaller than %d, the 2/3 of %d members", mgt, members*2/3+1, members)
+	}
+}
+
+func (c *checker) checkKeeper() {
+	if !c.section() {
+		return
+	}
+	k := c.file.Conf.Keeper
+	c.uuid("keeper.app-id", k.AppId)
+	c.uuid("keeper.signer-app-id", k.SignerAppId)
+	c.uuid("keeper.asset-id", k.AssetId)
+	c.uuid("keeper.observer-asset-id", k.ObserverAssetId)
+	c.uuid("keeper.observer-user-id", k.ObserverUserId)
+	c.optionalUUID("keeper.monitor-conversation-id", k.MonitorConversaionId)
+	c.privateKey("keeper.shared-key", k.SharedKey)
+	c.publicKey("keeper.signer-public-key", k.SignerPublicKey)
+	c.publicKey("keeper.observer-public-key", k.ObserverPublicKey)
+	c.url("keeper.mixin-messenger-api", k.MixinMessengerAPI)
+	c.url("keeper.mixin-rpc", k.MixinRPC)
+	c.url("keeper.bitcoin-rpc", k.BitcoinRPC)
+	c.url("keeper.litecoin-rpc", k.LitecoinRPC)
+	c.optionalURL("keeper.dogecoin-rpc", k.DogecoinRPC)
+	c.optionalURL("keeper.solana-rpc", k.SolanaRPC)
+	c.url("keeper.ethereum-rpc", k.EthereumRPC)
+	c.url("keeper.polygon-rpc", k.PolygonRPC)
+	c.address("keeper.polygon-factory-address", k.PolygonFactoryAddress)
+	c.address("keeper.polygon-observer-deposit-entry", k.PolygonObserverDepositEntry)
+	c.address("keeper.polygon-keeper-deposit-entry", k.PolygonKeeperDepositEntry)
+	c.mtg("keeper.mtg", k.MTG, true)
+	c.mtg("signer.mtg", c.file.Conf.Signer.MTG, false)
+	if k.AssetId == k.ObserverAssetId {
+		c.fail("keeper.observer-asset-id", "same as keeper.asset-id")
+	}
+	if k.SharedKey != "" && k.SharedKey == k.ObserverPublicKey {
+		c.fail("keeper.observer-public-key", "same as keeper.shared-key")
+	}
+}
+
+func (c *checker) checkObserver() {
+	if !c.section() {
+		return
+	}
+	o := c.file.Conf.Observer
+	c.uuid("observer.keeper-app-id", o.KeeperAppId)
+	c.uuid("observer.asset-id", o.AssetId)
+	c.uuid("observer.custom-key-price-asset-id", o.CustomKeyPriceAssetId)
+	c.uuid("observer.operation-price-asset-id", o.OperationPriceAssetId)
+	c.uuid("observer.app.app-id", o.App.AppId)
+	c.uuid("observer.app.session-id", o.App.SessionId)
+	c.optionalUUID("observer.monitor-conversation-id", o.MonitorConversaionId)
+	c.privateKey("observer.private-key", o.PrivateKey)
+	c.publicKey("observer.keeper-public-key", o.KeeperPublicKey)
+	c.privateKey("observer.app.spend-private-key", o.App.SpendPrivateKey)
+	c.amount("observer.custom-key-price-amount", o.CustomKeyPriceAmount)
+	c.amount("observer.operation-price-amount", o.OperationPriceAmount)
+	c.amount("observer.transaction-minimum", o.TransactionMinimum)
+	c.url("observer.mixin-messenger-api", o.MixinMessengerAPI)
+	c.url("observer.mixin-rpc", o.MixinRPC)
+	c.url("observer.bitcoin-rpc", o.BitcoinRPC)
+	c.url("observer.litecoin-rpc", o.LitecoinRPC)
+	c.optionalURL("observer.dogecoin-rpc", o.DogecoinRPC)
+	c.optionalURL("observer.solana-rpc", o.SolanaRPC)
+	c.url("observer.ethereum-rpc", o.EthereumRPC)
+	c.url("observer.polygon-rpc", o.PolygonRPC)
+	c.address("observer.polygon-factory-address", o.PolygonFactoryAddress)
+	c.address("observer.polygon-observer-deposit-entry", o.PolygonObserverDepositEntry)
+	c.address("observer.polygon-keeper-deposit-entry", o.PolygonKeeperDepositEntry)
+	if o.EVMKey != "" {
+		_, err := gcrypto.HexToECDSA(strings.TrimPrefix(o.EVMKey, "0x"))
+		if err != nil {
+			c.fail("observer.evm-key", "invalid evm private key %v", err)
+		}
+	}
+	if o.Timestamp <= 0 {
+		c.fail("observer.timestamp", "must be the nanoseconds to start from")
+	}
+	c.mtg("keeper.mtg", c.file.Conf.Keeper.MTG, false)
+}
+
+// checkNetwork runs the same mainnet or testnet asserts as the node boot,
+// which panic on any mismatch
+func (c *checker) checkNetwork() {
+	if len(c.errs) > 0 {
+		return
+	}
+	conf := c.file.Conf
+	network := MainNetworkName
+	if conf.Dev != nil && conf.Dev.Network != "" {
+		network = conf.Dev.Network
+	}
+	switch network {
+	case MainNetworkName, TestNetworkName:
+	case TestnetNetworkName, SignetNetworkName:
+		c.address("dev.evm-guard-address", conf.Dev.EvmGuardAddress)
+		return
+	default:
+		c.fail("dev.network", "unknown network %s", network)
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.fail("dev.network", "%s network assert %v", network, r)
+		}
+	}()
+	dev := DevConfig{Network: network}
+	if conf.Dev != nil {
+		dev = *conf.Dev
+		dev.Network = network
+	}
+	check := &Configuration{Signer: conf.Signer, Keeper: conf.Keeper, Observer: conf.Observer, Dev: &dev}
+	check.checkMainnet(c.file.Role)
+	check.checkTestnet(c.file.Role)
+}
+
+func (c *checker) uuid(field, id string) {
+	if id == "" {
+		c.fail(field, "missing uuid")
+		return
+	}
+	u, err := uuid.FromString(id)
+	if err != nil || u.String() != id {
+		c.fail(field, "invalid uuid %s", id)
+	}
+}
+
+func (c *checker) optionalUUID(field, id string) {
+	if id != "" {
+		c.uuid(field, id)
+	}
+}
+
+func (c *checker) privateKey(field, key string) {
+	k, err := crypto.KeyFromString(key)
+	if err != nil {
+		c.fail(field, "invalid ed25519 private key %v", err)
+		return
+	}
+	if !k.HasValue() || !c.canonical(k) {
+		c.fail(field, "invalid ed25519 private key %s", key)
+	}
+}
+
+func (c *checker) canonical(k crypto.Key) (valid bool) {
+	defer func() {
+		if recover() != nil {
+			valid = false
+		}
+	}()
+	k.Public()
+	return true
+}
+
+func (c *checker) publicKey(field, key string) {
+	k, err := crypto.KeyFromString(key)
+	if err != nil {
+		c.fail(field, "invalid ed25519 public key %v", err)
+		return
+	}
+	if !k.HasValue() || !k.CheckKey() {
+		c.fail(field, "invalid ed25519 public key %s", key)
+	}
+}
+
+func (c *checker) url(field, u string) {
+	p, err := url.Parse(u)
+	if err != nil || p.Host == "" || (p.Scheme != "http" && p.Scheme != "https") {
+		c.fail(field, "invalid http url %s", u)
+	}
+}
+
+func (c *checker) optionalURL(field, u string) {
+	if u != "" {
+		c.url(field, u)
+	}
+}
+
+func (c *checker) address(field, addr string) {
+	if !gc.IsHexAddress(addr) || gc.HexToAddress(addr).Hex() == ethereum.EthereumEmptyAddress {
+		c.fail(field, "invalid evm address %s", addr)
+	}
+}
+
+func (c *checker) amount(field, amt string) {
+	d, err := decimal.NewFromString(amt)
+	if err != nil || d.Sign() <= 0 {
+		c.fail(field, "invalid positive amount %s", amt)
+	}
+}
+
+func (c *checker) mtg(field string, conf *mtg.Configuration, app bool) {
+	if conf == nil {
+		c.fail(field, "section missing")
+		return
+	}
+	members, threshold := conf.Genesis.Members, conf.Genesis.Threshold
+	for _, m := range members {
+		c.uuid(field+".genesis.members", m)
+	}
+	sorted := slices.Clone(members)
+	slices.Sort(sorted)
+	if len(slices.Compact(sorted)) != len(members) {
+		c.fail(field+".genesis.members", "duplicated members")
+	}
+	if threshold < 1 || threshold > len(members) {
+		c.fail(field+".genesis.threshold", "threshold %d out of range for %d members", threshold, len(members))
+	}
+	if conf.Genesis.Epoch == 0 {
+		c.fail(field+".genesis.epoch", "missing epoch")
+	}
+	if !app {
+		return
+	}
+	c.uuid(field+".app.app-id", conf.App.AppId)
+	c.uuid(field+".app.session-id", conf.App.SessionId)
+	if !slices.Contains(members, conf.App.AppId) {
+		c.fail(field+".app.app-id", "%s not in the genesis members", conf.App.AppId)
+	}
+	c.privateKey(field+".app.spend-private-key", conf.App.SpendPrivateKey)
+}
+
+func checkPairs(files []*CheckFile) []*CheckError {
+	var errs []*CheckError
+	fail := func(a, b *CheckFile, field, msg string, args ...any) {
+		errs = append(errs, &CheckError{
+			Path:    a.Path + " <> " + b.Path,
+			Role:    a.Role + "/" + b.Role,
+			Field:   field,
+			Message: fmt.Sprintf(msg, args...),
+		})
+	}
+	public := func(priv string) string {
+		k, _ := crypto.KeyFromString(priv)
+		return k.Public().String()
+	}
+
+	for i, a := range files {
+		for _, b := range files[i+1:] {
+			if a.Role == b.Role {
+				checkSameRole(a, b, fail)
+				continue
+			}
+			a, b := a, b
+			if a.Role > b.Role {
+				a, b = b, a
+			}
+			switch a.Role + "/" + b.Role {
+			case "keeper/signer":
+				k, s := a.Conf.Keeper, b.Conf.Signer
+				if pub := public(k.SharedKey); pub != s.KeeperPublicKey {
+					fail(a, b, "signer.keeper-public-key", "%s mismatch keeper.shared-key public %s", s.KeeperPublicKey, pub)
+				}
+				if pub := public(s.SharedKey); pub != k.SignerPublicKey {
+					fail(a, b, "keeper.signer-public-key", "%s mismatch signer.shared-key public %s", k.SignerPublicKey, pub)
+				}
+				if k.AppId != s.KeeperAppId {
+					fail(a, b, "signer.keeper-app-id", "%s mismatch keeper.app-id %s", s.KeeperAppId, k.AppId)
+				}
+				if k.SignerAppId != s.AppId {
+					fail(a, b, "keeper.signer-app-id", "%s mismatch signer.app-id %s", k.SignerAppId, s.AppId)
+				}
+				if k.AssetId != s.KeeperAssetId {
+					fail(a, b, "signer.keeper-asset-id", "%s mismatch keeper.asset-id %s", s.KeeperAssetId, k.AssetId)
+				}
+				if a.Conf.Signer != nil && !genesisEqual(a.Conf.Signer.MTG, s.MTG) {
+					fail(a, b, "signer.mtg.genesis", "the signer genesis of keeper mismatch")
+				}
+			case "keeper/observer":
+				k, o := a.Conf.Keeper, b.Conf.Observer
+				if pub := public(o.PrivateKey); pub != k.ObserverPublicKey {
+					fail(a, b, "keeper.observer-public-key", "%s mismatch observer.private-key public %s", k.ObserverPublicKey, pub)
+				}
+				if pub := public(k.SharedKey); pub != o.KeeperPublicKey {
+					fail(a, b, "observer.keeper-public-key", "%s mismatch keeper.shared-key public %s", o.KeeperPublicKey, pub)
+				}
+				if k.AppId != o.KeeperAppId {
+					fail(a, b, "observer.keeper-app-id", "%s mismatch keeper.app-id %s", o.KeeperAppId, k.AppId)
+				}
+				if k.ObserverAssetId != o.AssetId {
+					fail(a, b, "observer.asset-id", "%s mismatch keeper.observer-asset-id %s", o.AssetId, k.ObserverAssetId)
+				}
+				if k.ObserverUserId != o.App.AppId {
+					fail(a, b, "keeper.observer-user-id", "%s mismatch observer.app.app-id %s", k.ObserverUserId, o.App.AppId)
+				}
+				if !genesisEqual(k.MTG, b.Conf.Keeper.MTG) {
+					fail(a, b, "keeper.mtg.genesis", "the keeper genesis of observer mismatch")
+				}
+			case "observer/signer":
+				o, s := a.Conf.Observer, b.Conf.Signer
+				if s.ObserverUserId != o.App.AppId {
+					fail(a, b, "signer.observer-user-id", "%s mismatch observer.app.app-id %s", s.ObserverUserId, o.App.AppId)
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func checkSameRole(a, b *CheckFile, fail func(a, b *CheckFile, field, msg string, args ...any)) {
+	switch a.Role {
+	case "signer":
+		x, y := a.Conf.Signer, b.Conf.Signer
+		if x.MTG.App.AppId == y.MTG.App.AppId {
+			fail(a, b, "signer.mtg.app.app-id", "duplicated signer node %s", x.MTG.App.AppId)
+		}
+		if x.AppId != y.AppId || x.KeeperAppId != y.KeeperAppId || x.AssetId != y.AssetId || x.KeeperAssetId != y.KeeperAssetId {
+			fail(a, b, "signer", "app or asset ids mismatch")
+		}
+		if x.SharedKey != y.SharedKey || x.KeeperPublicKey != y.KeeperPublicKey {
+			fail(a, b, "signer.shared-key", "shared keys mismatch")
+		}
+		if x.Threshold != y.Threshold || !genesisEqual(x.MTG, y.MTG) {
+			fail(a, b, "signer.mtg.genesis", "threshold or genesis mismatch")
+		}
+	case "keeper":
+		x, y := a.Conf.Keeper, b.Conf.Keeper
+		if x.MTG.App.AppId == y.MTG.App.AppId {
+			fail(a, b, "keeper.mtg.app.app-id", "duplicated keeper node %s", x.MTG.App.AppId)
+		}
+		if x.AppId != y.AppId || x.SignerAppId != y.SignerAppId || x.AssetId != y.AssetId || x.ObserverAssetId != y.ObserverAssetId {
+			fail(a, b, "keeper", "app or asset ids mismatch")
+		}
+		if x.SharedKey != y.SharedKey || x.SignerPublicKey != y.SignerPublicKey || x.ObserverPublicKey != y.ObserverPublicKey {
+			fail(a, b, "keeper.shared-key", "shared keys mismatch")
+		}
+		if !genesisEqual(x.MTG, y.MTG) {
+			fail(a, b, "keeper.mtg.genesis", "genesis mismatch")
+		}
+	case "observer":
+		fail(a, b, "observer", "only one observer is allowed")
+	}
+}
+
+func genesisEqual(a, b *mtg.Configuration) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	x, y := slices.Clone(a.Genesis.Members), slices.Clone(b.Genesis.Members)
+	slices.Sort(x)
+	slices.Sort(y)
+	return slices.Equal(x, y) && a.Genesis.Threshold == b.Genesis.Threshold && a.Genesis.Epoch == b.Genesis.Epoch
+}
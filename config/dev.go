@@ -6,15 +6,24 @@ import (
 	_ "net/http/pprof"
 
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
 )
 
 const MainNetworkName = "main"
 const TestNetworkName = "test"
 
+// the testnet and signet networks run all safe chains on their testnets,
+// while the test network is a staging group on the mainnet chains
+const TestnetNetworkName = "testnet"
+const SignetNetworkName = "signet"
+
 type DevConfig struct {
 	ProfilePort int    `toml:"profile-port"`
 	LogLevel    int    `toml:"log-level"`
 	Network     string `toml:"network"`
+	// the mixin safe guard contract deployed to sepolia and amoy
+	EvmGuardAddress string `toml:"evm-guard-address"`
 }
 
 func handleDevConfig(c *DevConfig) {
@@ -30,4 +39,13 @@ func handleDevConfig(c *DevConfig) {
 	if c.Network == "" {
 		c.Network = MainNetworkName
 	}
+	switch c.Network {
+	case MainNetworkName, TestNetworkName:
+	case TestnetNetworkName:
+		common.SetSafeNetwork(bitcoin.NetworkTestnet, c.EvmGuardAddress)
+	case SignetNetworkName:
+		common.SetSafeNetwork(bitcoin.NetworkSignet, c.EvmGuardAddress)
+	default:
+		panic(c.Network)
+	}
 }
@@ -23,6 +23,19 @@ type Configuration struct {
 }
 
 func ReadConfiguration(path, role string) (*Configuration, error) {
+	conf, err := readConfiguration(path)
+	if err != nil {
+		return nil, err
+	}
+	handleDevConfig(conf.Dev)
+	conf.checkMainnet(role)
+	conf.checkTestnet(role)
+	sort.Strings(conf.Keeper.MTG.Genesis.Members)
+	sort.Strings(conf.Signer.MTG.Genesis.Members)
+	return conf, nil
+}
+
+func readConfiguration(path string) (*Configuration, error) {
 	if strings.HasPrefix(path, "~/") {
 		usr, _ := user.Current()
 		path = filepath.Join(usr.HomeDir, (path)[2:])
@@ -33,15 +46,7 @@ func ReadConfiguration(path, role string) (*Configuration, error) {
 	}
 	var conf Configuration
 	err = toml.Unmarshal(f, &conf)
-	if err != nil {
-		return nil, err
-	}
-	handleDevConfig(conf.Dev)
-	conf.checkMainnet(role)
-	conf.checkTestnet(role)
-	sort.Strings(conf.Keeper.MTG.Genesis.Members)
-	sort.Strings(conf.Signer.MTG.Genesis.Members)
-	return &conf, nil
+	return &conf, err
 }
 
 func (c *Configuration) checkMainnet(role string) {
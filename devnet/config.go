@@ -0,0 +1,231 @@
+package devnet
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/observer"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+const (
+	// the app session is never used against the Mixin API in the test
+	// environment, these are the same values of the example configuration
+	appSessionId         = "194ac88f-4671-3976-b60a-09064f1811e8"
+	appSessionPrivateKey = "9b727c4954c0f29d9e76258a97f45c4c32a748c3536bee03e486a17d7ba59409"
+	appServerPublicKey   = "849bd198be846981839a5e5bef929cf8b71543ec31d5ff3cee4f272656a921d5"
+	appSpendPrivateKey   = "6004d10dab1c2ee8fb512399eeb9aa8ce2112eee07c20df780fb76d840cbcd0e"
+
+	mtgGenesisEpoch = 15903300
+
+	polygonFactoryAddress       = "0x4D17777E0AC12C6a0d4DEF1204278cFEAe142a1E"
+	polygonObserverDepositEntry = "0x4A2eea63775F0407E1f0d147571a46959479dE12"
+	polygonKeeperDepositEntry   = "0x5A3A6E35038f33458c13F3b5349ee5Ae1e94a8d9"
+
+	operationPriceAmount = "1"
+	transactionMinimum   = "0.0001"
+)
+
+type Options struct {
+	// Dir holds all the node stores, it must be empty or not exist
+	Dir string
+	// Signers is the number of the signer nodes, and each of them
+	// runs a keeper node as well
+	Signers int
+	// Seed derives all the ids and keys, the same seed and options
+	// always produce the same devnet identities
+	Seed string
+	// Curves to request signer keys and add observer keys for
+	Curves []byte
+	// Keys is the number of spare keys prepared for each curve
+	Keys int
+}
+
+func (opts *Options) normalize() error {
+	if opts.Dir == "" {
+		return fmt.Errorf("devnet dir is required")
+	}
+	if opts.Signers == 0 {
+		opts.Signers = 4
+	}
+	if opts.Signers < 2 || opts.Signers > 32 {
+		return fmt.Errorf("devnet signers %d", opts.Signers)
+	}
+	if opts.Seed == "" {
+		opts.Seed = "devnet"
+	}
+	if len(opts.Curves) == 0 {
+		opts.Curves = []byte{common.CurveSecp256k1ECDSABitcoin}
+	}
+	for _, crv := range opts.Curves {
+		switch crv {
+		case common.CurveSecp256k1ECDSABitcoin:
+		case common.CurveSecp256k1ECDSAEthereum:
+		default:
+			return fmt.Errorf("devnet curve %d", crv)
+		}
+	}
+	if opts.Keys == 0 {
+		opts.Keys = 1
+	}
+	if opts.Keys < 0 || opts.Keys > keeper.SignerKeygenMaximum {
+		return fmt.Errorf("devnet keys %d", opts.Keys)
+	}
+	return nil
+}
+
+// Identities are all the ids and keys of the devnet derived from the seed
+type Identities struct {
+	SignerAppId      string   `json:"signer_app_id"`
+	KeeperAppId      string   `json:"keeper_app_id"`
+	SignerAssetId    string   `json:"signer_asset_id"`
+	KeeperAssetId    string   `json:"keeper_asset_id"`
+	ObserverAssetId  string   `json:"observer_asset_id"`
+	ObserverUserId   string   `json:"observer_user_id"`
+	HolderUserId     string   `json:"holder_user_id"`
+	OperationAssetId string   `json:"operation_asset_id"`
+	Signers          []string `json:"signers"`
+
+	KeeperPrivateKey   string `json:"-"`
+	SignerPrivateKey   string `json:"-"`
+	ObserverPrivateKey string `json:"-"`
+	// the secp256k1 key used as the holder of all observer requests
+	DummyHolderPrivateKey string `json:"-"`
+}
+
+func (opts *Options) identities() *Identities {
+	ids := &Identities{
+		SignerAppId:      opts.uniqueId("signer-app"),
+		KeeperAppId:      opts.uniqueId("keeper-app"),
+		SignerAssetId:    opts.uniqueId("signer-asset"),
+		KeeperAssetId:    opts.uniqueId("keeper-asset"),
+		ObserverAssetId:  opts.uniqueId("observer-asset"),
+		ObserverUserId:   opts.uniqueId("observer"),
+		HolderUserId:     opts.uniqueId("holder"),
+		OperationAssetId: opts.uniqueId("operation-asset"),
+
+		KeeperPrivateKey:      opts.privateKey("keeper").String(),
+		SignerPrivateKey:      opts.privateKey("signer").String(),
+		ObserverPrivateKey:    opts.privateKey("observer").String(),
+		DummyHolderPrivateKey: opts.seedHash("dummy-holder").String(),
+	}
+	for i := range opts.Signers {
+		ids.Signers = append(ids.Signers, opts.uniqueId(fmt.Sprintf("signer-%d", i)))
+	}
+	slices.Sort(ids.Signers)
+	return ids
+}
+
+func (ids *Identities) DummyHolder() string {
+	_, pub := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(ids.DummyHolderPrivateKey))
+	return fmt.Sprintf("%x", pub.SerializeCompressed())
+}
+
+func (ids *Identities) keeperMembers() []string {
+	members := append(slices.Clone(ids.Signers), ids.ObserverUserId)
+	slices.Sort(members)
+	return members
+}
+
+func (opts *Options) signerConfiguration(ids *Identities, member, dir, saver string) *signer.Configuration {
+	conf := &signer.Configuration{
+		AppId:           ids.SignerAppId,
+		KeeperAppId:     ids.KeeperAppId,
+		StoreDir:        dir,
+		ObserverUserId:  ids.ObserverUserId,
+		Threshold:       len(ids.Signers) * 2 / 3,
+		SharedKey:       ids.SignerPrivateKey,
+		AssetId:         ids.SignerAssetId,
+		KeeperAssetId:   ids.KeeperAssetId,
+		KeeperPublicKey: publicKey(ids.KeeperPrivateKey),
+		SaverAPI:        saver,
+		SaverKey:        opts.privateKey("saver:" + member).String(),
+		MTG:             buildMTGConfiguration(member, ids.Signers),
+	}
+	conf.MTG.StoreDir = dir
+	return conf
+}
+
+func (opts *Options) keeperConfiguration(ids *Identities, member, dir string) *keeper.Configuration {
+	conf := &keeper.Configuration{
+		AppId:                       ids.KeeperAppId,
+		SignerAppId:                 ids.SignerAppId,
+		StoreDir:                    dir,
+		SharedKey:                   ids.KeeperPrivateKey,
+		SignerPublicKey:             publicKey(ids.SignerPrivateKey),
+		AssetId:                     ids.KeeperAssetId,
+		ObserverAssetId:             ids.ObserverAssetId,
+		ObserverPublicKey:           publicKey(ids.ObserverPrivateKey),
+		ObserverUserId:              ids.ObserverUserId,
+		PolygonFactoryAddress:       polygonFactoryAddress,
+		PolygonObserverDepositEntry: polygonObserverDepositEntry,
+		PolygonKeeperDepositEntry:   polygonKeeperDepositEntry,
+		MTG:                         buildMTGConfiguration(member, ids.keeperMembers()),
+	}
+	conf.MTG.StoreDir = dir
+	conf.MTG.GroupSize = 1
+	return conf
+}
+
+func (opts *Options) observerConfiguration(ids *Identities, dir string) *observer.Configuration {
+	conf := &observer.Configuration{
+		KeeperAppId:                 ids.KeeperAppId,
+		StoreDir:                    dir,
+		PrivateKey:                  ids.ObserverPrivateKey,
+		KeeperPublicKey:             publicKey(ids.KeeperPrivateKey),
+		AssetId:                     ids.ObserverAssetId,
+		CustomKeyPriceAssetId:       ids.OperationAssetId,
+		CustomKeyPriceAmount:        operationPriceAmount,
+		OperationPriceAssetId:       ids.OperationAssetId,
+		OperationPriceAmount:        operationPriceAmount,
+		TransactionMinimum:          transactionMinimum,
+		PolygonFactoryAddress:       polygonFactoryAddress,
+		PolygonObserverDepositEntry: polygonObserverDepositEntry,
+		PolygonKeeperDepositEntry:   polygonKeeperDepositEntry,
+	}
+	conf.App.AppId = ids.ObserverUserId
+	conf.App.SessionId = appSessionId
+	conf.App.SessionPrivateKey = appSessionPrivateKey
+	conf.App.ServerPublicKey = appServerPublicKey
+	conf.App.SpendPrivateKey = appSpendPrivateKey
+	return conf
+}
+
+func buildMTGConfiguration(member string, members []string) *mtg.Configuration {
+	conf := &mtg.Configuration{}
+	conf.App.AppId = member
+	conf.App.SessionId = appSessionId
+	conf.App.SessionPrivateKey = appSessionPrivateKey
+	conf.App.ServerPublicKey = appServerPublicKey
+	conf.App.SpendPrivateKey = appSpendPrivateKey
+	conf.Genesis.Members = slices.Clone(members)
+	conf.Genesis.Threshold = len(members)*2/3 + 1
+	conf.Genesis.Epoch = mtgGenesisEpoch
+	return conf
+}
+
+func (opts *Options) uniqueId(name string) string {
+	return common.UniqueId(opts.Seed, name)
+}
+
+func (opts *Options) seedHash(name string) crypto.Hash {
+	return crypto.Sha256Hash([]byte(opts.Seed + ":" + name))
+}
+
+func (opts *Options) privateKey(name string) crypto.Key {
+	seed := opts.seedHash(name)
+	return crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+}
+
+func publicKey(priv string) string {
+	key, err := crypto.KeyFromString(priv)
+	if err != nil {
+		panic(priv)
+	}
+	return key.Public().String()
+}
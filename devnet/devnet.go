@@ -0,0 +1,507 @@
+// Package devnet runs the signer group, the keeper group and the observer of
+// Mixin Safe in one process, with an in-memory MTG sequencer, a loopback
+// messenger and the chain stand-ins of the rpctest packages, so all the flows
+// could be driven end to end offline.
+package devnet
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	btcrpc "github.com/MixinNetwork/safe/apps/bitcoin/rpctest"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	evmrpc "github.com/MixinNetwork/safe/apps/ethereum/rpctest"
+	mixinrpc "github.com/MixinNetwork/safe/apps/mixin/rpctest"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/saver"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	bitcoinNetworkFeeRate = 10
+	signerKeygenTimeout   = 10 * time.Minute
+)
+
+type signerNode struct {
+	id    string
+	node  *signer.Node
+	group *mtg.Group
+}
+
+type keeperNode struct {
+	id    string
+	node  *keeper.Node
+	store *store.SQLite3Store
+	mtg   *mtg.SQLite3Store
+	group *mtg.Group
+}
+
+// ObserverKey is a BIP32 key added by the observer to the keeper
+type ObserverKey struct {
+	Curve     byte   `json:"curve"`
+	Public    string `json:"public"`
+	ChainCode string `json:"chain_code"`
+	Private   string `json:"private"`
+}
+
+type Devnet struct {
+	opts      *Options
+	ids       *Identities
+	sequencer *sequencer
+	registry  *mixinrpc.Server
+	saver     *saver.SQLite3Store
+	loopback  *loopback
+	signers   []*signerNode
+	keepers   []*keeperNode
+	observer  *observerNode
+
+	observerAESKey [32]byte
+	observerKeys   []*ObserverKey
+
+	Bitcoin  *btcrpc.Server
+	Litecoin *btcrpc.Server
+	Ethereum *evmrpc.Server
+	Polygon  *evmrpc.Server
+}
+
+// New creates all the node stores in the options dir, the returned context is
+// the test environment which must be used for all the devnet methods
+func New(ctx context.Context, opts Options) (context.Context, *Devnet, error) {
+	err := opts.normalize()
+	if err != nil {
+		return ctx, nil, err
+	}
+	entries, err := os.ReadDir(opts.Dir)
+	if err == nil && len(entries) > 0 {
+		return ctx, nil, fmt.Errorf("devnet dir %s not empty", opts.Dir)
+	}
+	err = os.MkdirAll(opts.Dir, 0700)
+	if err != nil {
+		return ctx, nil, err
+	}
+	ctx = common.EnableTestEnvironment(ctx)
+
+	ids := opts.identities()
+	d := &Devnet{
+		opts:      &opts,
+		ids:       ids,
+		sequencer: newSequencer(ids.KeeperAppId),
+		registry:  mixinrpc.NewServer(),
+		Bitcoin:   btcrpc.NewServer(bitcoin.ChainBitcoin),
+		Litecoin:  btcrpc.NewServer(bitcoin.ChainLitecoin),
+		Ethereum:  evmrpc.NewServer(ethereum.ChainEthereum),
+		Polygon:   evmrpc.NewServer(ethereum.ChainPolygon),
+	}
+	d.observerAESKey = common.ECDHEd25519(ids.ObserverPrivateKey, publicKey(ids.KeeperPrivateKey))
+	d.Polygon.HandleCall(polygonFactoryAddress, d.registry.CallFactory)
+	d.registry.RegisterAsset(&mixinrpc.Asset{
+		AssetId:   ids.OperationAssetId,
+		AssetKey:  ids.OperationAssetId,
+		Symbol:    "DEV",
+		Name:      "Devnet Operation",
+		Precision: 8,
+		ChainId:   common.SafeBitcoinChainId,
+	})
+	d.loopback = newLoopback(ids.Signers, d.sequencer.enqueue)
+
+	saverAPI, err := d.startSaver(ctx)
+	if err != nil {
+		return ctx, nil, err
+	}
+	for i, id := range ids.Signers {
+		dir := filepath.Join(opts.Dir, fmt.Sprintf("signer-%d", i))
+		sn, err := d.buildSigner(ctx, id, dir, saverAPI)
+		if err != nil {
+			return ctx, nil, err
+		}
+		d.signers = append(d.signers, sn)
+
+		dir = filepath.Join(opts.Dir, fmt.Sprintf("keeper-%d", i))
+		kn, err := d.buildKeeper(ctx, id, dir)
+		if err != nil {
+			return ctx, nil, err
+		}
+		d.keepers = append(d.keepers, kn)
+	}
+	d.observer, err = d.buildObserver(ctx, filepath.Join(opts.Dir, "observer"))
+	if err != nil {
+		return ctx, nil, err
+	}
+	return ctx, d, nil
+}
+
+func (d *Devnet) startSaver(ctx context.Context) (string, error) {
+	dir := filepath.Join(d.opts.Dir, "saver")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+	d.saver, err = saver.OpenSQLite3Store(filepath.Join(dir, "data.sqlite3"))
+	if err != nil {
+		return "", err
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+	go func() {
+		err := saver.StartHTTP(d.saver, port)
+		logger.Printf("saver.StartHTTP(%d) => %v", port, err)
+	}()
+	return fmt.Sprintf("http://127.0.0.1:%d", port), nil
+}
+
+func (d *Devnet) buildSigner(ctx context.Context, id, dir, saverAPI string) (*signerNode, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	conf := d.opts.signerConfiguration(d.ids, id, dir, saverAPI)
+	err = d.saver.WriteNodePublicKey(ctx, id, publicKey(conf.SaverKey))
+	if err != nil {
+		return nil, err
+	}
+	kd, err := signer.OpenSQLite3Store(filepath.Join(dir, "mpc.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+	md, err := mtg.OpenSQLite3Store(filepath.Join(dir, "mtg.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+	group, err := mtg.BuildGroup(ctx, md, conf.MTG)
+	if err != nil {
+		return nil, err
+	}
+	keeperMTG := buildMTGConfiguration(id, d.ids.keeperMembers())
+	node := signer.NewNode(kd, group, d.loopback.endpoint(id), conf, keeperMTG, nil)
+	group.AttachWorker(conf.AppId, node)
+	return &signerNode{id: id, node: node, group: group}, nil
+}
+
+func (d *Devnet) buildKeeper(ctx context.Context, id, dir string) (*keeperNode, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	conf := d.opts.keeperConfiguration(d.ids, id, dir)
+	conf.MixinMessengerAPI = d.registry.URL()
+	conf.BitcoinRPC = d.Bitcoin.URL()
+	conf.LitecoinRPC = d.Litecoin.URL()
+	conf.EthereumRPC = d.Ethereum.URL()
+	conf.PolygonRPC = d.Polygon.URL()
+	kd, err := keeper.OpenSQLite3Store(filepath.Join(dir, "safe.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+	md, err := mtg.OpenSQLite3Store(filepath.Join(dir, "mtg.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+	group, err := mtg.BuildGroup(ctx, md, conf.MTG)
+	if err != nil {
+		return nil, err
+	}
+	signerMTG := buildMTGConfiguration(id, d.ids.Signers)
+	node := keeper.NewNode(kd, group, conf, signerMTG, nil)
+	group.AttachWorker(conf.AppId, node)
+	return &keeperNode{id: id, node: node, store: kd, mtg: md, group: group}, nil
+}
+
+// Boot starts the sequencer, the signer and the observer loops, all of them
+// stop when the context canceled, but the stores are kept in the dir
+func (d *Devnet) Boot(ctx context.Context) {
+	go d.loopSequencer(ctx)
+	for _, s := range d.signers {
+		s.node.TestBoot(ctx)
+	}
+	d.observer.node.TestBoot(ctx, d.observer)
+}
+
+func (d *Devnet) Close() {
+	d.registry.Close()
+	d.Bitcoin.Close()
+	d.Litecoin.Close()
+	d.Ethereum.Close()
+	d.Polygon.Close()
+}
+
+func (d *Devnet) Identities() *Identities {
+	return d.ids
+}
+
+func (d *Devnet) ObserverKeys() []*ObserverKey {
+	return d.observerKeys
+}
+
+// KeeperStore is the store of the first keeper node, all keeper stores are
+// identical after the same actions processed
+func (d *Devnet) KeeperStore() *store.SQLite3Store {
+	return d.keepers[0].store
+}
+
+func (d *Devnet) AssetAPI() string {
+	return d.registry.URL()
+}
+
+func (d *Devnet) RegisterAsset(a *mixinrpc.Asset) {
+	d.registry.RegisterAsset(a)
+}
+
+// Now is the devnet clock used as the sequencer timestamp of keeper actions
+func (d *Devnet) Now() time.Time {
+	return d.sequencer.now()
+}
+
+// Advance moves the devnet clock, e.g. to expire the safe timelock, the chain
+// stand-ins are not affected
+func (d *Devnet) Advance(duration time.Duration) time.Time {
+	return d.sequencer.advance(duration)
+}
+
+func (d *Devnet) ListActions(offset uint64, limit int) []*ActionRecord {
+	return d.sequencer.listActions(offset, limit)
+}
+
+func (d *Devnet) ListTransactions(offset uint64, limit int) []*Transaction {
+	return d.sequencer.listTransactions(offset, limit)
+}
+
+// WaitIdle waits until all the actions in queue processed, the signer sessions
+// may still be running in background
+func (d *Devnet) WaitIdle(ctx context.Context) error {
+	for !d.sequencer.idle() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// Bootstrap prepares the devnet as the observer does after boot: operation
+// params and network status of all chains, observer keys and signer keys.
+// The keeper checks the key maturity with the wall clock, so all these actions
+// are sequenced one maturity ago, then the clock is moved back to present.
+func (d *Devnet) Bootstrap(ctx context.Context) error {
+	d.Advance(-keeper.SafeKeyBackupMaturity)
+	for _, chain := range []byte{
+		common.SafeChainBitcoin,
+		common.SafeChainLitecoin,
+		common.SafeChainEthereum,
+		common.SafeChainPolygon,
+	} {
+		err := d.sendOperationParams(ctx, chain)
+		if err != nil {
+			return err
+		}
+		_, err = d.Mine(ctx, chain, 1)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, crv := range d.opts.Curves {
+		for i := range d.opts.Keys {
+			err := d.addObserverKey(ctx, crv, i)
+			if err != nil {
+				return err
+			}
+		}
+		id := common.UniqueId(d.ids.DummyHolder(), fmt.Sprintf("devnet-signer-keys:%d", crv))
+		_, err := d.SendObserverRequest(ctx, &ObserverRequest{
+			Id:     id,
+			Action: common.ActionObserverRequestSignerKeys,
+			Curve:  crv,
+			Extra:  []byte{byte(d.opts.Keys)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, crv := range d.opts.Curves {
+		err := d.waitSpareKeys(ctx, crv, common.RequestRoleSigner, d.opts.Keys, signerKeygenTimeout)
+		if err != nil {
+			return err
+		}
+		err = d.waitSpareKeys(ctx, crv, common.RequestRoleObserver, d.opts.Keys, time.Minute)
+		if err != nil {
+			return err
+		}
+	}
+	err := d.WaitIdle(ctx)
+	d.Advance(keeper.SafeKeyBackupMaturity)
+	return err
+}
+
+func (d *Devnet) waitSpareKeys(ctx context.Context, crv byte, role, count int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ready := true
+		for _, k := range d.keepers {
+			c, err := k.store.CountSpareKeys(ctx, crv, common.RequestFlagNone, role)
+			if err != nil {
+				return err
+			}
+			ready = ready && c >= count
+		}
+		if ready {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("devnet spare keys %d %d timeout", crv, role)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (d *Devnet) sendOperationParams(ctx context.Context, chain byte) error {
+	amount := decimal.RequireFromString(operationPriceAmount).Mul(decimal.New(1, 8))
+	minimum := decimal.RequireFromString(transactionMinimum).Mul(decimal.New(1, 8))
+	extra := []byte{chain}
+	extra = append(extra, uuid.Must(uuid.FromString(d.ids.OperationAssetId)).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(amount.IntPart()))
+	extra = binary.BigEndian.AppendUint64(extra, uint64(minimum.IntPart()))
+	_, err := d.SendObserverRequest(ctx, &ObserverRequest{
+		Id:     common.UniqueId(d.ids.OperationAssetId, fmt.Sprintf("devnet-operation-params:%d", chain)),
+		Action: common.ActionObserverSetOperationParams,
+		Curve:  chainCurve(chain),
+		Extra:  extra,
+	})
+	return err
+}
+
+func (d *Devnet) addObserverKey(ctx context.Context, crv byte, i int) error {
+	seed := d.opts.seedHash(fmt.Sprintf("observer-key:%d:%d", crv, i))
+	master, err := hdkeychain.NewMaster(seed[:], &chaincfg.MainNetParams)
+	if err != nil {
+		return err
+	}
+	priv, err := master.ECPrivKey()
+	if err != nil {
+		return err
+	}
+	key := &ObserverKey{
+		Curve:     crv,
+		Public:    fmt.Sprintf("%x", priv.PubKey().SerializeCompressed()),
+		ChainCode: fmt.Sprintf("%x", master.ChainCode()),
+		Private:   fmt.Sprintf("%x", priv.Serialize()),
+	}
+	extra := append([]byte{common.RequestRoleObserver}, master.ChainCode()...)
+	extra = append(extra, common.RequestFlagNone)
+	_, err = d.SendObserverRequest(ctx, &ObserverRequest{
+		Id:     common.UniqueId(key.Public, "devnet-observer-key"),
+		Action: common.ActionObserverAddKey,
+		Curve:  crv,
+		Public: key.Public,
+		Extra:  extra,
+	})
+	if err != nil {
+		return err
+	}
+	d.observerKeys = append(d.observerKeys, key)
+	return nil
+}
+
+// Mine produces blocks on the chain stand-in, and sends the new network status
+// to the keeper as the observer does
+func (d *Devnet) Mine(ctx context.Context, chain byte, blocks int) ([]string, error) {
+	var hashes []string
+	extra := []byte{chain}
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		s := d.Bitcoin
+		if chain == common.SafeChainLitecoin {
+			s = d.Litecoin
+		}
+		hashes = s.Mine(blocks)
+		tip := s.Tip()
+		hash, err := crypto.HashFromString(tip.Hash)
+		if err != nil {
+			return nil, err
+		}
+		extra = binary.BigEndian.AppendUint64(extra, bitcoinNetworkFeeRate)
+		extra = binary.BigEndian.AppendUint64(extra, tip.Height)
+		extra = append(extra, hash[:]...)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		s := d.Ethereum
+		if chain == common.SafeChainPolygon {
+			s = d.Polygon
+		}
+		hashes = s.Mine(blocks)
+		tip := s.Tip()
+		extra = binary.BigEndian.AppendUint64(extra, s.GasPrice())
+		extra = binary.BigEndian.AppendUint64(extra, tip.Number)
+		extra = append(extra, tip.Hash[:]...)
+	default:
+		return nil, fmt.Errorf("devnet chain %d", chain)
+	}
+	id := common.UniqueId(common.SafeChainAssetId(chain), fmt.Sprintf("devnet-network:%x", extra))
+	_, err := d.SendObserverRequest(ctx, &ObserverRequest{
+		Id:     id,
+		Action: common.ActionObserverUpdateNetworkStatus,
+		Curve:  chainCurve(chain),
+		Extra:  extra,
+	})
+	return hashes, err
+}
+
+// registerBondAssets deploys the bond assets of the approved safes in the
+// polygon factory stand-in, and registers them in the Mixin asset stand-in
+func (d *Devnet) registerBondAssets(ctx context.Context) {
+	safes, err := d.keepers[0].store.ListSafesWithState(ctx, keeper.SafeStateApproved)
+	if err != nil {
+		panic(err)
+	}
+	for _, safe := range safes {
+		if d.registry.ReadAsset(safe.SafeAssetId) != nil {
+			continue
+		}
+		d.registerBond(safe.Chain, safe.Holder)
+	}
+}
+
+func (d *Devnet) registerBond(chain byte, holder string) string {
+	chainAssetId := common.SafeChainAssetId(chain)
+	id := d.registry.RegisterBond(polygonKeeperDepositEntry, chainAssetId, holder)
+	logger.Printf("devnet.registerBond(%s, %s) => %s", chainAssetId, holder, id)
+	return id
+}
+
+func chainCurve(chain byte) byte {
+	switch chain {
+	case common.SafeChainBitcoin:
+		return common.CurveSecp256k1ECDSABitcoin
+	case common.SafeChainLitecoin:
+		return common.CurveSecp256k1ECDSALitecoin
+	case common.SafeChainEthereum:
+		return common.CurveSecp256k1ECDSAEthereum
+	case common.SafeChainPolygon:
+		return common.CurveSecp256k1ECDSAPolygon
+	default:
+		panic(chain)
+	}
+}
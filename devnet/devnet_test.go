@@ -0,0 +1,297 @@
+package devnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevnetBitcoinSafe(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(logger.ERROR)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	ctx, d, err := New(ctx, Options{Dir: t.TempDir()})
+	require.Nil(err)
+	defer d.Close()
+	d.Boot(ctx)
+	err = d.Bootstrap(ctx)
+	require.Nil(err)
+	for _, r := range d.ListActions(0, 100) {
+		require.Equal("", r.Error)
+	}
+
+	ids := d.Identities()
+	holder := ids.DummyHolder()
+	sp := testProposeBitcoinSafe(ctx, require, d)
+	rid := sp.RequestId
+
+	extra := uuid.Must(uuid.FromString(rid)).Bytes()
+	extra = append(extra, testSignBitcoinSafeApproval(ids, sp)...)
+	_, err = d.SendObserverRequest(ctx, &ObserverRequest{
+		Id:     common.UniqueId(rid, "approve"),
+		Action: common.ActionBitcoinSafeApproveAccount,
+		Curve:  common.CurveSecp256k1ECDSABitcoin,
+		Public: holder,
+		Extra:  extra,
+	})
+	require.Nil(err)
+	err = d.WaitIdle(ctx)
+	require.Nil(err)
+
+	safe, err := d.KeeperStore().ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.NotNil(safe)
+	require.Equal(sp.Address, safe.Address)
+	require.Equal(int(keeper.SafeStateApproved), int(safe.State))
+	require.NotNil(d.registry.ReadAsset(safe.SafeAssetId))
+	for _, r := range d.ListActions(0, 100) {
+		require.Equal("", r.Error)
+	}
+}
+
+func TestDevnetBitcoinDepositAndWithdrawal(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(logger.ERROR)
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	ctx, d, err := New(ctx, Options{Dir: t.TempDir()})
+	require.Nil(err)
+	defer d.Close()
+	d.Boot(ctx)
+	err = d.Bootstrap(ctx)
+	require.Nil(err)
+
+	ids := d.Identities()
+	holder := ids.DummyHolder()
+	handler := d.HTTPHandler()
+	sp := testProposeBitcoinSafe(ctx, require, d)
+	rid := sp.RequestId
+
+	// the holder approves the account proposal through the observer
+	err = testWaitUntil(ctx, func() bool {
+		proposed, err := d.ObserverStore().CheckAccountProposed(ctx, sp.Address)
+		require.Nil(err)
+		return proposed
+	})
+	require.Nil(err)
+	code := testPostJSON(handler, "/accounts/"+rid, map[string]any{
+		"action":    "approve",
+		"address":   sp.Address,
+		"signature": base64.RawURLEncoding.EncodeToString(testSignBitcoinSafeApproval(ids, sp)),
+	})
+	require.Equal(http.StatusOK, code)
+	err = testWaitUntil(ctx, func() bool {
+		safe, err := d.KeeperStore().ReadSafe(ctx, holder)
+		require.Nil(err)
+		return safe != nil && safe.State == keeper.SafeStateApproved
+	})
+	require.Nil(err)
+	safe, err := d.KeeperStore().ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(sp.Address, safe.Address)
+
+	// the observer finds the deposit in blocks and sends it to the keeper
+	sender := testBitcoinAddress(require, ids.HolderUserId)
+	_, err = d.Bitcoin.Fund(d.Accountant(), 1000000)
+	require.Nil(err)
+	coinbase, err := d.Bitcoin.Fund(sender, 10000000)
+	require.Nil(err)
+	_, err = d.Mine(ctx, common.SafeChainBitcoin, 1)
+	require.Nil(err)
+	deposit := testBitcoinTransfer(require, d, coinbase, 0, testOutput{sp.Address, 100000}, testOutput{sender, 9898000})
+	_, err = d.Mine(ctx, common.SafeChainBitcoin, 3)
+	require.Nil(err)
+	err = testWaitUntil(ctx, func() bool {
+		utxos, err := d.KeeperStore().ListAllBitcoinUTXOsForHolder(ctx, holder)
+		require.Nil(err)
+		return len(utxos) == 1
+	})
+	require.Nil(err)
+	utxos, err := d.KeeperStore().ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Equal(deposit, utxos[0].TransactionHash)
+	require.Equal(int64(100000), utxos[0].Satoshi)
+
+	// the holder proposes the withdrawal with the safe bond asset
+	info, err := d.KeeperStore().ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	require.Nil(err)
+	extra := []byte{0}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(sender)...)
+	tid := common.UniqueId(rid, "withdrawal")
+	_, err = d.SendHolderRequest(ctx, &HolderRequest{
+		Id:      tid,
+		Action:  common.ActionBitcoinSafeProposeTransaction,
+		Curve:   common.CurveSecp256k1ECDSABitcoin,
+		Public:  holder,
+		AssetId: safe.SafeAssetId,
+		Amount:  decimal.RequireFromString("0.0005"),
+		Extra:   extra,
+	})
+	require.Nil(err)
+	err = d.WaitIdle(ctx)
+	require.Nil(err)
+	tx, err := d.KeeperStore().ReadTransactionByRequestId(ctx, tid)
+	require.Nil(err)
+	require.NotNil(tx)
+	err = testWaitUntil(ctx, func() bool {
+		approval, err := d.ObserverStore().ReadTransactionApproval(ctx, tx.TransactionHash)
+		require.Nil(err)
+		return approval != nil
+	})
+	require.Nil(err)
+
+	// the holder signs and pays the approval, then the observer asks the
+	// keeper for the signatures, and spends the transaction with the fee
+	// paid by the accountant
+	psTx := testSignBitcoinTransaction(require, tx.RawTransaction, ids.DummyHolderPrivateKey)
+	code = testPostJSON(handler, "/transactions/"+tid, map[string]any{
+		"chain":  common.SafeChainBitcoin,
+		"action": "approve",
+		"raw":    hex.EncodeToString(psTx.Marshal()),
+	})
+	require.Equal(http.StatusOK, code)
+	testBitcoinTransfer(require, d, deposit, 1, testOutput{sender, 9896000})
+	d.PayObserver(ids.OperationAssetId, decimal.RequireFromString(operationPriceAmount), tx.TransactionHash)
+	err = testWaitUntil(ctx, func() bool {
+		approval, err := d.ObserverStore().ReadTransactionApproval(ctx, tx.TransactionHash)
+		require.Nil(err)
+		return approval.SpentHash.Valid
+	})
+	require.Nil(err)
+	approval, err := d.ObserverStore().ReadTransactionApproval(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, int(approval.State))
+	require.Contains(d.Bitcoin.Mempool(), approval.SpentHash.String)
+
+	_, err = d.Mine(ctx, common.SafeChainBitcoin, 1)
+	require.Nil(err)
+	err = d.WaitIdle(ctx)
+	require.Nil(err)
+	for _, r := range d.ListActions(0, 1000) {
+		require.Equal("", r.Error)
+	}
+}
+
+func testProposeBitcoinSafe(ctx context.Context, require *require.Assertions, d *Devnet) *store.SafeProposal {
+	ids := d.Identities()
+	extra := binary.BigEndian.AppendUint16(nil, uint16(bitcoin.TimeLockMinimum/time.Hour))
+	extra = append(extra, 1, 1)
+	extra = append(extra, uuid.Must(uuid.FromString(ids.HolderUserId)).Bytes()...)
+	rid := common.UniqueId(ids.HolderUserId, "propose")
+	_, err := d.SendHolderRequest(ctx, &HolderRequest{
+		Id:      rid,
+		Action:  common.ActionBitcoinSafeProposeAccount,
+		Curve:   common.CurveSecp256k1ECDSABitcoin,
+		Public:  ids.DummyHolder(),
+		AssetId: ids.OperationAssetId,
+		Amount:  decimal.RequireFromString(operationPriceAmount),
+		Extra:   extra,
+	})
+	require.Nil(err)
+	err = d.WaitIdle(ctx)
+	require.Nil(err)
+	sp, err := d.KeeperStore().ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	require.NotNil(sp)
+	return sp
+}
+
+func testSignBitcoinSafeApproval(ids *Identities, sp *store.SafeProposal) []byte {
+	ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
+	hash := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+	hp, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(ids.DummyHolderPrivateKey))
+	return ecdsa.Sign(hp, hash).Serialize()
+}
+
+func testSignBitcoinTransaction(require *require.Assertions, raw, priv string) *bitcoin.PartiallySignedTransaction {
+	psTx, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	key, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(priv))
+	for idx := range psTx.UnsignedTx.TxIn {
+		hash := psTx.SigHash(idx)
+		psTx.Inputs[idx].PartialSigs = []*psbt.PartialSig{{
+			PubKey:    key.PubKey().SerializeCompressed(),
+			Signature: ecdsa.Sign(key, hash).Serialize(),
+		}}
+	}
+	return psTx
+}
+
+func testBitcoinAddress(require *require.Assertions, seed string) string {
+	h := crypto.Sha256Hash([]byte(seed))
+	_, pub := btcec.PrivKeyFromBytes(h[:])
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub.SerializeCompressed()), bitcoin.NetConfig(bitcoin.ChainBitcoin))
+	require.Nil(err)
+	return addr.EncodeAddress()
+}
+
+type testOutput struct {
+	address string
+	satoshi int64
+}
+
+// testBitcoinTransfer spends the output to the receivers, the stand-in does
+// not verify the signatures, and the rest of the output is the fee
+func testBitcoinTransfer(require *require.Assertions, d *Devnet, id string, index uint32, outputs ...testOutput) string {
+	hash, err := chainhash.NewHashFromStr(id)
+	require.Nil(err)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, index), nil, nil))
+	for _, out := range outputs {
+		script, err := bitcoin.ParseAddress(out.address, common.SafeChainBitcoin)
+		require.Nil(err)
+		tx.AddTxOut(wire.NewTxOut(out.satoshi, script))
+	}
+	var buf bytes.Buffer
+	err = tx.Serialize(&buf)
+	require.Nil(err)
+	txid, err := d.Bitcoin.SendRawTransaction(hex.EncodeToString(buf.Bytes()))
+	require.Nil(err)
+	return txid
+}
+
+func testPostJSON(handler http.Handler, path string, body any) int {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(common.MarshalJSONOrPanic(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w.Code
+}
+
+func testWaitUntil(ctx context.Context, done func() bool) error {
+	for !done() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package devnet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	mixinrpc "github.com/MixinNetwork/safe/apps/mixin/rpctest"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/dimfeld/httptreemux/v5"
+	"github.com/shopspring/decimal"
+)
+
+const httpListLimit = 500
+
+// HTTPHandler is the control API of the devnet, so a script could drive all
+// the flows by sending requests, mining blocks and advancing the clock. All
+// the other paths are served by the observer HTTP API, e.g. to approve the
+// accounts and transactions as the holder does.
+func (d *Devnet) HTTPHandler() http.Handler {
+	router := httptreemux.New()
+	router.PanicHandler = common.HandlePanic
+	router.NotFoundHandler = d.observer.node.HTTPHandler("devnet", "").ServeHTTP
+
+	router.GET("/devnet", d.httpIndex)
+	router.POST("/devnet/holder", d.httpSendHolderRequest)
+	router.POST("/devnet/observer", d.httpSendObserverRequest)
+	router.POST("/devnet/observer/payments", d.httpPayObserver)
+	router.POST("/devnet/storage", d.httpWriteStorage)
+	router.POST("/devnet/assets", d.httpRegisterAsset)
+	router.POST("/devnet/clock", d.httpAdvanceClock)
+	router.POST("/devnet/chains/:chain/mine", d.httpMine)
+	router.POST("/devnet/chains/:chain/fund", d.httpFund)
+	router.GET("/devnet/actions", d.httpListActions)
+	router.GET("/devnet/transactions", d.httpListTransactions)
+	return common.HandleCORS(router)
+}
+
+func (d *Devnet) httpIndex(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"identities":    d.ids,
+		"dummy_holder":  d.ids.DummyHolder(),
+		"observer_keys": d.observerKeys,
+		"asset_api":     d.AssetAPI(),
+		"accountant":    d.Accountant(),
+		"rpc": map[string]any{
+			"bitcoin":  d.Bitcoin.URL(),
+			"litecoin": d.Litecoin.URL(),
+			"ethereum": d.Ethereum.URL(),
+			"polygon":  d.Polygon.URL(),
+		},
+		"clock": d.Now(),
+	})
+}
+
+func (d *Devnet) httpSendHolderRequest(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Id      string `json:"id"`
+		Action  byte   `json:"action"`
+		Curve   byte   `json:"curve"`
+		Public  string `json:"public"`
+		AssetId string `json:"asset_id"`
+		Amount  string `json:"amount"`
+		Extra   string `json:"extra"`
+		Storage string `json:"storage"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	amount, err := decimal.NewFromString(body.Amount)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "amount"})
+		return
+	}
+	extra, err1 := hex.DecodeString(body.Extra)
+	storage, err2 := hex.DecodeString(body.Storage)
+	if err1 != nil || err2 != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "extra"})
+		return
+	}
+	act, err := d.SendHolderRequest(r.Context(), &HolderRequest{
+		Id:      body.Id,
+		Action:  body.Action,
+		Curve:   body.Curve,
+		Public:  body.Public,
+		AssetId: body.AssetId,
+		Amount:  amount,
+		Extra:   extra,
+		Storage: storage,
+	})
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"output_id":        act.OutputId,
+		"transaction_hash": act.TransactionHash,
+	})
+}
+
+func (d *Devnet) httpSendObserverRequest(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Id      string `json:"id"`
+		Action  byte   `json:"action"`
+		Curve   byte   `json:"curve"`
+		Public  string `json:"public"`
+		Extra   string `json:"extra"`
+		Storage string `json:"storage"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	extra, err1 := hex.DecodeString(body.Extra)
+	storage, err2 := hex.DecodeString(body.Storage)
+	if err1 != nil || err2 != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "extra"})
+		return
+	}
+	act, err := d.SendObserverRequest(r.Context(), &ObserverRequest{
+		Id:      body.Id,
+		Action:  body.Action,
+		Curve:   body.Curve,
+		Public:  body.Public,
+		Extra:   extra,
+		Storage: storage,
+	})
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"output_id":        act.OutputId,
+		"transaction_hash": act.TransactionHash,
+	})
+}
+
+func (d *Devnet) httpPayObserver(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		AssetId string          `json:"asset_id"`
+		Amount  decimal.Decimal `json:"amount"`
+		Memo    string          `json:"memo"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	if body.AssetId == "" || !body.Amount.IsPositive() {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "amount"})
+		return
+	}
+	id := d.PayObserver(body.AssetId, body.Amount, body.Memo)
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"snapshot_id": id})
+}
+
+func (d *Devnet) httpWriteStorage(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Data string `json:"data"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	data, err := hex.DecodeString(body.Data)
+	if err != nil || len(data) == 0 {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "data"})
+		return
+	}
+	ref, err := d.WriteObserverStorage(r.Context(), data)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"hash": ref.String()})
+}
+
+func (d *Devnet) httpRegisterAsset(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var a mixinrpc.Asset
+	err := json.NewDecoder(r.Body).Decode(&a)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	if a.AssetId == "" || a.ChainId == "" || a.Symbol == "" {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "asset"})
+		return
+	}
+	d.RegisterAsset(&a)
+	common.RenderJSON(w, r, http.StatusOK, a)
+}
+
+func (d *Devnet) httpAdvanceClock(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Advance string `json:"advance"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	duration, err := time.ParseDuration(body.Advance)
+	if err != nil || duration < 0 {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "advance"})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"clock": d.Advance(duration)})
+}
+
+func (d *Devnet) httpMine(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	chain, err := strconv.ParseUint(params["chain"], 10, 8)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "chain"})
+		return
+	}
+	var body struct {
+		Blocks int `json:"blocks"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Blocks < 1 {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "blocks"})
+		return
+	}
+	hashes, err := d.Mine(r.Context(), byte(chain), body.Blocks)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"blocks": hashes})
+}
+
+func (d *Devnet) httpFund(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Address string `json:"address"`
+		Satoshi int64  `json:"satoshi"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil || body.Satoshi < 1 {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "satoshi"})
+		return
+	}
+	var hash string
+	switch params["chain"] {
+	case fmt.Sprint(common.SafeChainBitcoin):
+		hash, err = d.Bitcoin.Fund(body.Address, body.Satoshi)
+	case fmt.Sprint(common.SafeChainLitecoin):
+		hash, err = d.Litecoin.Fund(body.Address, body.Satoshi)
+	default:
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "chain"})
+		return
+	}
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err.Error()})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{"hash": hash})
+}
+
+func (d *Devnet) httpListActions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, _ := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	common.RenderJSON(w, r, http.StatusOK, d.ListActions(offset, httpListLimit))
+}
+
+func (d *Devnet) httpListTransactions(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	offset, _ := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	common.RenderJSON(w, r, http.StatusOK, d.ListTransactions(offset, httpListLimit))
+}
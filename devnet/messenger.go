@@ -0,0 +1,78 @@
+package devnet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/messenger"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+const loopbackInboxSize = 4096
+
+// loopback replaces the Mixin Messenger group conversation of the signers,
+// each signer has its own endpoint so the peer is always the real sender
+type loopback struct {
+	inboxes map[string]chan *messenger.MixinMessage
+	queue   func(act *mtg.Action)
+}
+
+type loopbackEndpoint struct {
+	id      string
+	network *loopback
+}
+
+func newLoopback(members []string, queue func(act *mtg.Action)) *loopback {
+	n := &loopback{
+		inboxes: make(map[string]chan *messenger.MixinMessage),
+		queue:   queue,
+	}
+	for _, id := range members {
+		n.inboxes[id] = make(chan *messenger.MixinMessage, loopbackInboxSize)
+	}
+	return n
+}
+
+func (n *loopback) endpoint(id string) *loopbackEndpoint {
+	if n.inboxes[id] == nil {
+		panic(id)
+	}
+	return &loopbackEndpoint{id: id, network: n}
+}
+
+// ReceiveMessage blocks until a message arrives, the signer panics on any
+// error, so it never returns one even after the devnet closed
+func (e *loopbackEndpoint) ReceiveMessage(ctx context.Context) (*messenger.MixinMessage, error) {
+	return <-e.network.inboxes[e.id], nil
+}
+
+func (e *loopbackEndpoint) QueueMessage(ctx context.Context, receiver string, b []byte) error {
+	inbox := e.network.inboxes[receiver]
+	if inbox == nil {
+		return fmt.Errorf("loopback receiver %s not found", receiver)
+	}
+	inbox <- &messenger.MixinMessage{
+		Peer:      e.id,
+		Data:      b,
+		CreatedAt: time.Now().UTC(),
+	}
+	return nil
+}
+
+// QueueMTGOutput receives the signer results which are sent to the signer
+// MTG as transactions in production
+func (e *loopbackEndpoint) QueueMTGOutput(ctx context.Context, b []byte) error {
+	var act mtg.Action
+	err := json.Unmarshal(b, &act)
+	logger.Verbosef("loopback.QueueMTGOutput(%s) => %s %v", e.id, act.OutputId, err)
+	if err != nil {
+		return err
+	}
+	act.TransactionHash = crypto.Sha256Hash([]byte(act.OutputId)).String()
+	e.network.queue(&act)
+	return nil
+}
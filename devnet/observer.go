@@ -0,0 +1,211 @@
+package devnet
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/observer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/shopspring/decimal"
+)
+
+// the devnet keepers build transactions without spending any outputs, so
+// the observer always sees the keepers hold sufficient bond assets
+const observerBondBalance = "1000000"
+
+// observerNode runs the observer against the chain stand-ins, and it is the
+// observer TestNetwork, all the transactions sent to the observer by the
+// keeper or holders are its snapshots
+type observerNode struct {
+	d          *Devnet
+	node       *observer.Node
+	store      *observer.SQLite3Store
+	accountant string
+	mutex      sync.Mutex
+	snapshots  []*mixin.SafeSnapshot
+}
+
+func (d *Devnet) buildObserver(ctx context.Context, dir string) (*observerNode, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	conf := d.opts.observerConfiguration(d.ids, dir)
+	conf.MixinMessengerAPI = d.registry.URL()
+	conf.BitcoinRPC = d.Bitcoin.URL()
+	conf.LitecoinRPC = d.Litecoin.URL()
+	conf.EthereumRPC = d.Ethereum.URL()
+	conf.PolygonRPC = d.Polygon.URL()
+	db, err := observer.OpenSQLite3Store(filepath.Join(dir, "observer.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+
+	seed := d.opts.seedHash("observer-accountant")
+	priv, pub := btcec.PrivKeyFromBytes(seed[:])
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pub.SerializeCompressed()), bitcoin.NetConfig(bitcoin.ChainBitcoin))
+	if err != nil {
+		return nil, err
+	}
+	accountant := addr.EncodeAddress()
+	crv := common.SafeChainCurve(common.SafeChainBitcoin)
+	err = db.WriteAccountantKeys(ctx, crv, map[string]*btcec.PrivateKey{accountant: priv})
+	if err != nil {
+		return nil, err
+	}
+
+	keeperMTG := buildMTGConfiguration(d.ids.ObserverUserId, d.ids.keeperMembers())
+	node := observer.NewNode(db, d.keepers[0].store, conf, keeperMTG, nil)
+	return &observerNode{d: d, node: node, store: db, accountant: accountant}, nil
+}
+
+// receiveTransaction converts the transaction sent to the observer by the
+// keeper to the observer snapshot. The bond asset is deployed once the account
+// proposed, because the observer checks the bond before the holder approval.
+func (o *observerNode) receiveTransaction(ctx context.Context, t *mtg.Transaction, tx *Transaction) {
+	if tx.OpponentAppId != o.d.ids.ObserverUserId {
+		return
+	}
+	if op := tx.Operation; op != nil {
+		switch op.Type {
+		case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+			sp, err := o.d.keepers[0].store.ReadSafeProposal(ctx, op.Id)
+			if err != nil {
+				panic(err)
+			}
+			if sp != nil {
+				o.d.registerBond(sp.Chain, sp.Holder)
+			}
+		}
+	}
+	memo := mtg.EncodeMixinExtraBase64(t.OpponentAppId, []byte(t.Memo))
+	o.appendSnapshot(&mixin.SafeSnapshot{
+		SnapshotID: common.UniqueId(t.TraceId, "snapshot"),
+		RequestID:  t.TraceId,
+		UserID:     o.d.ids.ObserverUserId,
+		AssetID:    t.AssetId,
+		Amount:     decimal.RequireFromString(t.Amount),
+		Memo:       hex.EncodeToString([]byte(memo)),
+		CreatedAt:  tx.CreatedAt,
+	})
+}
+
+// appendSnapshot keeps the snapshots ordered by time, as the observer reads
+// them with the time checkpoint
+func (o *observerNode) appendSnapshot(s *mixin.SafeSnapshot) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if n := len(o.snapshots); n > 0 {
+		last := o.snapshots[n-1].CreatedAt
+		if !s.CreatedAt.After(last) {
+			s.CreatedAt = last.Add(time.Microsecond)
+		}
+	}
+	o.snapshots = append(o.snapshots, s)
+}
+
+func (o *observerNode) ReadSafeSnapshots(ctx context.Context, offset time.Time, limit int) ([]*mixin.SafeSnapshot, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	var snapshots []*mixin.SafeSnapshot
+	for _, s := range o.snapshots {
+		if !s.CreatedAt.After(offset) || len(snapshots) >= limit {
+			continue
+		}
+		c := *s
+		snapshots = append(snapshots, &c)
+	}
+	return snapshots, nil
+}
+
+// ReadTransactionExtra reads the storage extra written by the keeper in the
+// test environment, it is the same as the Mixin transaction request extra
+func (o *observerNode) ReadTransactionExtra(ctx context.Context, traceId string) (string, error) {
+	extra, err := o.d.keepers[0].store.ReadProperty(ctx, traceId)
+	if err != nil || extra != "" {
+		return extra, err
+	}
+	return "", fmt.Errorf("devnet transaction %s not found", traceId)
+}
+
+// WriteStorage decrypts the observer storage as the keeper does with the
+// kernel transaction extra, then writes it as the observer request storage
+func (o *observerNode) WriteStorage(ctx context.Context, extra []byte, traceId string) (crypto.Hash, error) {
+	raw := common.AESDecrypt(o.d.observerAESKey[:], extra)
+	if len(raw) < 16 {
+		return crypto.Hash{}, fmt.Errorf("devnet storage %s malformed %x", traceId, extra)
+	}
+	return o.d.WriteObserverStorage(ctx, raw[16:])
+}
+
+func (o *observerNode) SendTransaction(ctx context.Context, assetId string, receivers []string, threshold int, amount decimal.Decimal, memo, traceId string, references []crypto.Hash) error {
+	if !slices.Equal(receivers, o.d.ids.keeperMembers()) {
+		return fmt.Errorf("devnet observer transaction %s receivers %v", traceId, receivers)
+	}
+	if !o.d.sequencer.trace(traceId) {
+		return nil
+	}
+	logger.Printf("devnet.observer.SendTransaction(%s, %s, %s, %v)", traceId, assetId, amount, references)
+	o.d.sequencer.enqueue(&mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(traceId, "output"),
+			TransactionHash: crypto.Sha256Hash([]byte(traceId)).String(),
+			AppId:           o.d.ids.KeeperAppId,
+			Senders:         []string{o.d.ids.ObserverUserId},
+			AssetId:         assetId,
+			Amount:          amount,
+			Extra:           hex.EncodeToString([]byte(memo)),
+		},
+	})
+	return nil
+}
+
+func (o *observerNode) AssetBalance(ctx context.Context, members []string, threshold int, assetId string) (*mc.Integer, error) {
+	balance := mc.NewIntegerFromString(observerBondBalance)
+	return &balance, nil
+}
+
+// PayObserver sends the asset to the observer as a holder does, e.g. to pay
+// the transaction approval with the transaction hash as the memo
+func (d *Devnet) PayObserver(assetId string, amount decimal.Decimal, memo string) string {
+	id := common.UniqueId(assetId, fmt.Sprintf("devnet-payment:%s:%d", memo, time.Now().UnixNano()))
+	d.observer.appendSnapshot(&mixin.SafeSnapshot{
+		SnapshotID: id,
+		RequestID:  id,
+		UserID:     d.ids.ObserverUserId,
+		OpponentID: d.ids.HolderUserId,
+		AssetID:    assetId,
+		Amount:     amount,
+		Memo:       hex.EncodeToString([]byte(memo)),
+		CreatedAt:  d.Now(),
+	})
+	return id
+}
+
+// ObserverStore is the store of the observer, which tracks the deposits,
+// account and transaction approvals of all the safes
+func (d *Devnet) ObserverStore() *observer.SQLite3Store {
+	return d.observer.store
+}
+
+// Accountant is the bitcoin address of the observer accountant, it must be
+// funded to pay the fee of the safe transactions
+func (d *Devnet) Accountant() string {
+	return d.observer.accountant
+}
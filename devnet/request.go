@@ -0,0 +1,166 @@
+package devnet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// ObserverRequest is sent by the observer to the keeper, the public is the
+// dummy holder if empty. The storage is written as the observer storage
+// transaction, and its reference hash appended to the extra.
+type ObserverRequest struct {
+	Id      string `json:"id"`
+	Action  byte   `json:"action"`
+	Curve   byte   `json:"curve"`
+	Public  string `json:"public"`
+	Extra   []byte `json:"extra"`
+	Storage []byte `json:"storage"`
+}
+
+// HolderRequest is sent by the holder or anyone else to the keeper, with any
+// asset and amount. The storage is referenced by the request transaction, and
+// the extra is the storage hash in this case.
+type HolderRequest struct {
+	Id      string          `json:"id"`
+	Action  byte            `json:"action"`
+	Curve   byte            `json:"curve"`
+	Public  string          `json:"public"`
+	AssetId string          `json:"asset_id"`
+	Amount  decimal.Decimal `json:"amount"`
+	Extra   []byte          `json:"extra"`
+	Storage []byte          `json:"storage"`
+}
+
+func (d *Devnet) SendObserverRequest(ctx context.Context, r *ObserverRequest) (*mtg.Action, error) {
+	if r.Id == "" || r.Action == 0 || r.Curve == 0 {
+		return nil, fmt.Errorf("invalid observer request %v", r)
+	}
+	public := r.Public
+	if public == "" {
+		public = d.ids.DummyHolder()
+	}
+	extra := r.Extra
+	if len(r.Storage) > 0 {
+		ref, err := d.WriteObserverStorage(ctx, r.Storage)
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, ref[:]...)
+	}
+	op := &common.Operation{
+		Id:     r.Id,
+		Type:   r.Action,
+		Curve:  r.Curve,
+		Public: public,
+		Extra:  extra,
+	}
+	b := common.AESEncrypt(d.observerAESKey[:], op.Encode(), op.Id)
+	if len(b) > 160 {
+		return nil, fmt.Errorf("observer request %v too large %d", r, len(b))
+	}
+	memo := mtg.EncodeMixinExtraBase64(d.ids.KeeperAppId, b)
+	act := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(op.Id, "output"),
+			TransactionHash: crypto.Sha256Hash([]byte(op.Id)).String(),
+			AppId:           d.ids.KeeperAppId,
+			Senders:         []string{d.ids.ObserverUserId},
+			AssetId:         d.ids.ObserverAssetId,
+			Amount:          decimal.NewFromInt(1),
+			Extra:           hex.EncodeToString([]byte(memo)),
+		},
+	}
+	d.sequencer.enqueue(act)
+	return act, nil
+}
+
+// WriteObserverStorage makes the data readable by the keeper as the storage
+// transaction referenced by the observer request, the storage is addressed by
+// its content so the same data written again is a no-op
+func (d *Devnet) WriteObserverStorage(ctx context.Context, data []byte) (crypto.Hash, error) {
+	ref := crypto.Sha256Hash(data)
+	val := base64.RawURLEncoding.EncodeToString(data)
+	for _, k := range d.keepers {
+		old, err := k.store.ReadProperty(ctx, ref.String())
+		if err != nil || old != "" {
+			return ref, err
+		}
+		err = k.store.WriteProperty(ctx, ref.String(), val)
+		if err != nil {
+			return ref, err
+		}
+	}
+	return ref, nil
+}
+
+func (d *Devnet) SendHolderRequest(ctx context.Context, r *HolderRequest) (*mtg.Action, error) {
+	if r.Id == "" || r.Action == 0 || r.Curve == 0 || r.Public == "" {
+		return nil, fmt.Errorf("invalid holder request %v", r)
+	}
+	if r.AssetId == "" || !r.Amount.IsPositive() {
+		return nil, fmt.Errorf("invalid holder request payment %s %s", r.AssetId, r.Amount)
+	}
+	op := &common.Operation{
+		Id:     r.Id,
+		Type:   r.Action,
+		Curve:  r.Curve,
+		Public: r.Public,
+		Extra:  r.Extra,
+	}
+	hash := crypto.Sha256Hash([]byte(op.Id))
+	if len(r.Storage) > 0 {
+		stx := mc.NewTransactionV5(mc.XINAssetId)
+		stx.Extra = r.Storage
+		sver := stx.AsVersioned()
+		ref := sver.PayloadHash()
+		op.Extra = ref[:]
+
+		tx := mc.NewTransactionV5(mc.XINAssetId)
+		tx.References = []crypto.Hash{ref}
+		tx.Extra = []byte(op.Id)
+		ver := tx.AsVersioned()
+		hash = ver.PayloadHash()
+		for _, v := range []*mc.VersionedTransaction{sver, ver} {
+			err := d.writeKernelTransaction(ctx, v)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	memo := mtg.EncodeMixinExtraBase64(d.ids.KeeperAppId, op.Encode())
+	act := &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(op.Id, "output"),
+			TransactionHash: hash.String(),
+			AppId:           d.ids.KeeperAppId,
+			Senders:         []string{d.ids.HolderUserId},
+			AssetId:         r.AssetId,
+			Amount:          r.Amount,
+			Extra:           hex.EncodeToString([]byte(memo)),
+		},
+	}
+	d.sequencer.enqueue(act)
+	return act, nil
+}
+
+// writeKernelTransaction caches the transaction in all keeper MTG stores, so
+// the group reads it without the Mixin kernel RPC
+func (d *Devnet) writeKernelTransaction(ctx context.Context, ver *mc.VersionedTransaction) error {
+	key := fmt.Sprintf("readKernelTransactionUntilSufficient(%s)", ver.PayloadHash())
+	val := base64.RawURLEncoding.EncodeToString(ver.Marshal())
+	for _, k := range d.keepers {
+		err := k.mtg.WriteCache(ctx, key, val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
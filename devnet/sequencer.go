@@ -0,0 +1,373 @@
+package devnet
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// ActionRecord is the result of an action sequenced by the devnet MTG
+type ActionRecord struct {
+	Sequence     uint64    `json:"sequence"`
+	OutputId     string    `json:"output_id"`
+	AppId        string    `json:"app_id"`
+	AssetId      string    `json:"asset_id"`
+	Amount       string    `json:"amount"`
+	Senders      []string  `json:"senders"`
+	Transactions []string  `json:"transactions"`
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Transaction is a transaction sent by the groups to the observer, holders or
+// the storage, the observer operation and storage are decoded if possible
+type Transaction struct {
+	Sequence      uint64            `json:"sequence"`
+	TraceId       string            `json:"trace_id"`
+	OpponentAppId string            `json:"opponent_app_id"`
+	AssetId       string            `json:"asset_id"`
+	Amount        string            `json:"amount"`
+	Receivers     []string          `json:"receivers"`
+	Threshold     int               `json:"threshold"`
+	Memo          string            `json:"memo"`
+	Operation     *common.Operation `json:"operation,omitempty"`
+	Storage       string            `json:"storage,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// sequencer orders all the actions of both the keeper and signer groups, and
+// it is the clock of the keeper, which could be advanced to skip timelocks.
+// The signer actions always use the wall clock, because the signer drops the
+// sessions created too long ago.
+type sequencer struct {
+	clocked  string
+	mutex    sync.Mutex
+	notify   chan struct{}
+	queue    []*mtg.Action
+	busy     bool
+	sequence uint64
+	offset   time.Duration
+	traces   map[string]bool
+	actions  []*ActionRecord
+	outbound []*Transaction
+}
+
+func newSequencer(clocked string) *sequencer {
+	return &sequencer{
+		clocked:  clocked,
+		notify:   make(chan struct{}, 1),
+		sequence: mtgGenesisEpoch,
+		traces:   make(map[string]bool),
+	}
+}
+
+func (s *sequencer) now() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return time.Now().UTC().Add(s.offset)
+}
+
+func (s *sequencer) advance(d time.Duration) time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.offset += d
+	return time.Now().UTC().Add(s.offset)
+}
+
+func (s *sequencer) enqueue(act *mtg.Action) {
+	s.mutex.Lock()
+	s.queue = append(s.queue, act)
+	s.mutex.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// next assigns the sequence and timestamp to the next action in queue
+func (s *sequencer) next(ctx context.Context) *mtg.Action {
+	for {
+		s.mutex.Lock()
+		if len(s.queue) > 0 {
+			act := s.queue[0]
+			s.queue = s.queue[1:]
+			s.busy = true
+			s.sequence += 1
+			act.Sequence = s.sequence
+			act.SequencerCreatedAt = time.Now().UTC()
+			if act.AppId == s.clocked {
+				act.SequencerCreatedAt = act.SequencerCreatedAt.Add(s.offset)
+			}
+			s.mutex.Unlock()
+			return act
+		}
+		s.busy = false
+		s.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.notify:
+		}
+	}
+}
+
+func (s *sequencer) idle() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return !s.busy && len(s.queue) == 0
+}
+
+func (s *sequencer) record(r *ActionRecord, txs []*Transaction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.actions = append(s.actions, r)
+	s.outbound = append(s.outbound, txs...)
+}
+
+// trace returns false if the transaction has been delivered already, all the
+// nodes of a group build the same transaction with the same trace id
+func (s *sequencer) trace(id string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.traces[id] {
+		return false
+	}
+	s.traces[id] = true
+	return true
+}
+
+func (s *sequencer) listActions(offset uint64, limit int) []*ActionRecord {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var list []*ActionRecord
+	for _, r := range s.actions {
+		if r.Sequence > offset && len(list) < limit {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+func (s *sequencer) listTransactions(offset uint64, limit int) []*Transaction {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var list []*Transaction
+	for _, t := range s.outbound {
+		if t.Sequence > offset && len(list) < limit {
+			list = append(list, t)
+		}
+	}
+	return list
+}
+
+func (d *Devnet) loopSequencer(ctx context.Context) {
+	for {
+		act := d.sequencer.next(ctx)
+		if act == nil {
+			return
+		}
+		d.processAction(ctx, act)
+	}
+}
+
+func (d *Devnet) processAction(ctx context.Context, act *mtg.Action) {
+	r := &ActionRecord{
+		Sequence:  act.Sequence,
+		OutputId:  act.OutputId,
+		AppId:     act.AppId,
+		AssetId:   act.AssetId,
+		Amount:    act.Amount.String(),
+		Senders:   act.Senders,
+		CreatedAt: act.SequencerCreatedAt,
+	}
+
+	var txs []*mtg.Transaction
+	var err error
+	switch act.AppId {
+	case d.ids.KeeperAppId:
+		txs, err = d.processKeeperAction(ctx, act)
+		d.registerBondAssets(ctx)
+	case d.ids.SignerAppId:
+		txs, err = d.processSignerAction(ctx, act)
+	default:
+		err = fmt.Errorf("unknown app %s", act.AppId)
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	logger.Printf("devnet.processAction(%d, %s, %s) => %d %v", act.Sequence, act.AppId, act.OutputId, len(txs), err)
+
+	var outbound []*Transaction
+	for _, t := range txs {
+		r.Transactions = append(r.Transactions, t.TraceId)
+		if !d.sequencer.trace(t.TraceId) {
+			continue
+		}
+		switch t.OpponentAppId {
+		case d.ids.KeeperAppId:
+			d.sequencer.enqueue(d.buildGroupAction(t, d.ids.KeeperAppId, d.ids.Signers))
+		case d.ids.SignerAppId:
+			d.sequencer.enqueue(d.buildGroupAction(t, d.ids.SignerAppId, d.ids.keeperMembers()))
+		default:
+			tx := d.decodeTransaction(ctx, act, t)
+			d.observer.receiveTransaction(ctx, t, tx)
+			outbound = append(outbound, tx)
+		}
+	}
+	d.sequencer.record(r, outbound)
+}
+
+// processKeeperAction runs the action on all keeper nodes, and the results
+// must be identical, otherwise the keeper is not deterministic
+func (d *Devnet) processKeeperAction(ctx context.Context, act *mtg.Action) ([]*mtg.Transaction, error) {
+	var results [][]*mtg.Transaction
+	for _, k := range d.keepers {
+		out := cloneAction(act, k.group)
+		txs, compaction, err := processOutput(ctx, k.node, out)
+		if err != nil {
+			return nil, fmt.Errorf("keeper %s panic: %v", k.id, err)
+		}
+		if compaction != "" {
+			return nil, fmt.Errorf("keeper %s compaction: %s", k.id, compaction)
+		}
+		results = append(results, txs)
+	}
+	for i := 1; i < len(results); i++ {
+		a := mtg.SerializeTransactions(results[0])
+		b := mtg.SerializeTransactions(results[i])
+		if !bytes.Equal(a, b) {
+			return results[0], fmt.Errorf("keeper %s diverged from %s", d.keepers[i].id, d.keepers[0].id)
+		}
+	}
+	return results[0], nil
+}
+
+// processSignerAction runs the action on all signer nodes, they produce the
+// same transaction only after enough signer results received
+func (d *Devnet) processSignerAction(ctx context.Context, act *mtg.Action) ([]*mtg.Transaction, error) {
+	var txs []*mtg.Transaction
+	filter := make(map[string]bool)
+	for _, s := range d.signers {
+		out := cloneAction(act, s.group)
+		ts, compaction, err := processOutput(ctx, s.node, out)
+		if err != nil {
+			return txs, fmt.Errorf("signer %s panic: %v", s.id, err)
+		}
+		if compaction != "" {
+			return txs, fmt.Errorf("signer %s compaction: %s", s.id, compaction)
+		}
+		for _, t := range ts {
+			if filter[t.TraceId] {
+				continue
+			}
+			filter[t.TraceId] = true
+			txs = append(txs, t)
+		}
+	}
+	return txs, nil
+}
+
+// buildGroupAction converts a transaction between the keeper and signer
+// groups to the action received by the opponent group
+func (d *Devnet) buildGroupAction(t *mtg.Transaction, appId string, senders []string) *mtg.Action {
+	memo := mtg.EncodeMixinExtraBase64(appId, []byte(t.Memo))
+	return &mtg.Action{
+		UnifiedOutput: mtg.UnifiedOutput{
+			OutputId:        common.UniqueId(t.TraceId, "output"),
+			TransactionHash: crypto.Sha256Hash([]byte(t.TraceId)).String(),
+			AppId:           appId,
+			Senders:         senders,
+			AssetId:         t.AssetId,
+			Amount:          decimal.RequireFromString(t.Amount),
+			Extra:           hex.EncodeToString([]byte(memo)),
+		},
+	}
+}
+
+func (d *Devnet) decodeTransaction(ctx context.Context, act *mtg.Action, t *mtg.Transaction) *Transaction {
+	tx := &Transaction{
+		Sequence:      act.Sequence,
+		TraceId:       t.TraceId,
+		OpponentAppId: t.OpponentAppId,
+		AssetId:       t.AssetId,
+		Amount:        t.Amount,
+		Receivers:     t.Receivers,
+		Threshold:     t.Threshold,
+		Memo:          hex.EncodeToString([]byte(t.Memo)),
+		CreatedAt:     act.SequencerCreatedAt,
+	}
+	if t.OpponentAppId != d.ids.ObserverUserId {
+		return tx
+	}
+	b := common.AESDecrypt(d.observerAESKey[:], []byte(t.Memo))
+	op, err := common.DecodeOperation(b)
+	if err != nil {
+		return tx
+	}
+	tx.Operation = op
+	if len(op.Extra) != 16 {
+		return tx
+	}
+	// the keeper writes the storage extra to the property in test environment
+	sid := uuid.FromBytesOrNil(op.Extra).String()
+	v, err := d.keepers[0].store.ReadProperty(ctx, sid)
+	if err != nil || v == "" {
+		return tx
+	}
+	sb, err := hex.DecodeString(v)
+	if err != nil {
+		return tx
+	}
+	raw, err := common.Base91Decode(string(sb))
+	if err != nil {
+		return tx
+	}
+	tx.Storage = hex.EncodeToString(raw)
+	return tx
+}
+
+type outputProcessor interface {
+	ProcessOutput(ctx context.Context, out *mtg.Action) ([]*mtg.Transaction, string)
+}
+
+func processOutput(ctx context.Context, node outputProcessor, act *mtg.Action) (txs []*mtg.Transaction, compaction string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	txs, compaction = node.ProcessOutput(ctx, act)
+	return txs, compaction, nil
+}
+
+// cloneAction gives each node its own copy of the action, the same as all
+// nodes receive the action from their own MTG in production
+func cloneAction(act *mtg.Action, group *mtg.Group) *mtg.Action {
+	var out mtg.Action
+	err := json.Unmarshal(common.MarshalJSONOrPanic(act), &out)
+	if err != nil {
+		panic(err)
+	}
+	out.TestAttachActionToGroup(group)
+	return &out
+}
@@ -3,6 +3,7 @@ module github.com/MixinNetwork/safe
 go 1.23.4
 
 require (
+	filippo.io/edwards25519 v1.1.0
 	github.com/MixinNetwork/bot-api-go-client/v3 v3.9.4
 	github.com/MixinNetwork/mixin v0.18.20
 	github.com/MixinNetwork/multi-party-sig v0.4.1
@@ -28,7 +29,6 @@ require (
 )
 
 require (
-	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/MixinNetwork/go-number v0.1.1 // indirect
 	github.com/StackExchange/wmi v1.2.1 // indirect
@@ -0,0 +1,146 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+// checkSafeTransactionApprovable returns false if the transaction could not be
+// approved by the observer request, or it is still locked by the safe policy
+func (node *Node) checkSafeTransactionApprovable(ctx context.Context, req *common.Request, tx *store.Transaction) bool {
+	if tx == nil || tx.State == common.RequestStateDone || tx.Holder != req.Holder {
+		return false
+	}
+	if node.checkSafeOutflowLocked(ctx, req, tx.TransactionHash) {
+		return false
+	}
+	return node.checkSafeMigrationApproved(ctx, tx.TransactionHash)
+}
+
+// The holder could approve multiple transactions of the same safe with a
+// single signature over all the transaction hashes, or the holder signatures
+// reaching the holder threshold of the safe, and pay the observer only once. The observer sends the batch with the holder signed raw of each
+// transaction, and the batch fails if any of the transactions is invalid.
+func (node *Node) processSafeApproveTransactions(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		if req.Action != common.ActionBitcoinSafeApproveTransactions {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if req.Action != common.ActionEthereumSafeApproveTransactions {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 32 {
+		return node.failRequest(ctx, req, "")
+	}
+	var ref crypto.Hash
+	copy(ref[:], extra)
+	raw := node.readStorageExtraFromObserver(ctx, ref)
+	batch, err := common.DecodeBatchApproval(raw)
+	logger.Printf("common.DecodeBatchApproval(%x) => %v %v", raw, batch, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var hashes []string
+	var txs []*store.Transaction
+	for _, bt := range batch.Transactions {
+		tx, err := node.store.ReadTransactionByRequestId(ctx, bt.RequestId)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, bt.RequestId, err))
+		}
+		if !node.checkSafeTransactionApprovable(ctx, req, tx) {
+			return node.failRequest(ctx, req, "")
+		}
+		if slices.Contains(hashes, tx.TransactionHash) {
+			return node.failRequest(ctx, req, "")
+		}
+		hashes = append(hashes, tx.TransactionHash)
+		txs = append(txs, tx)
+	}
+
+	ms := common.BatchApprovalMessage(safe.RequestId, hashes)
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		msg := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		err = node.verifySafeMessageSignatureWithHolders(ctx, safe, msg, batch.Signature, bitcoin.VerifySignatureDER)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		err = node.verifySafeMessageSignatureWithHolders(ctx, safe, []byte(ms), batch.Signature, ethereum.VerifyMessageSignature)
+	}
+	logger.Printf("node.verifySafeMessageSignatureWithHolders(%s, %x) => %v", ms, batch.Signature, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var requests []*store.SignatureRequest
+	var approvals []*store.TransactionSignatureRequests
+	for i, tx := range txs {
+		raw := batch.Transactions[i].Raw
+		approval := &store.TransactionSignatureRequests{TransactionHash: tx.TransactionHash}
+		switch safe.Chain {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+			srs, valid := node.buildBitcoinApprovalSignatureRequests(ctx, req, safe, tx, raw)
+			if !valid {
+				return node.failRequest(ctx, req, "")
+			}
+			approval.Requests = srs
+		case common.SafeChainEthereum, common.SafeChainPolygon:
+			t, err := ethereum.UnmarshalSafeTransaction(raw)
+			logger.Printf("ethereum.UnmarshalSafeTransaction(%x) => %v %v", raw, t, err)
+			if err != nil {
+				return node.failRequest(ctx, req, "")
+			}
+			proposed, _ := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+			if !bytes.Equal(t.Message, proposed.Message) {
+				return node.failRequest(ctx, req, "")
+			}
+			sr, valid := node.buildEthereumApprovalSignatureRequest(ctx, req, safe, tx, t)
+			if !valid {
+				return node.failRequest(ctx, req, "")
+			}
+			approval.Raw = hex.EncodeToString(t.Marshal())
+			approval.Requests = []*store.SignatureRequest{sr}
+		}
+		requests = append(requests, approval.Requests...)
+		approvals = append(approvals, approval)
+	}
+
+	mtxs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
+	if len(mtxs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteBatchSignatureRequestsWithRequest(ctx, approvals, req, mtxs)
+	logger.Printf("store.WriteBatchSignatureRequestsWithRequest(%v, %d, %v) => %v", hashes, len(requests), req, err)
+	if err != nil {
+		panic(err)
+	}
+	return mtxs, ""
+}
@@ -0,0 +1,119 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperApproveTransactionsLocked(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+
+	bondId := testPrepareBond(ctx, require, node, db)
+	testPrepareDeposits(ctx, require, node, mpc, 86560, 100000)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	policy := []byte(`{"assets":[{"asset_id":"` + common.SafeBitcoinChainId + `","daily":"0","weekly":"0","large":"0.0002"}],"delay":2}`)
+	id := uuid.Must(uuid.NewV4()).String()
+	ms := common.SafePolicyMessage(safe.RequestId, safe.Address, id, policy)
+	update := &common.SafePolicyUpdate{Policy: policy, Signature: testBitcoinSignMessage(testBitcoinKeyHolderPrivate, ms)}
+	testUpdateSafePolicy(ctx, require, node, db, holder, id, update)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+
+	// the small transaction is not delayed, while the large one is locked
+	small := testBatchProposeTransaction(ctx, require, node, bondId, decimal.NewFromFloat(0.000123))
+	outflow, err := node.store.ReadSafeOutflow(ctx, small)
+	require.Nil(err)
+	require.Equal(outflow.CreatedAt, outflow.UnlockAt)
+	large := testBatchProposeTransaction(ctx, require, node, bondId, decimal.NewFromFloat(0.0003))
+	outflow, err = node.store.ReadSafeOutflow(ctx, large)
+	require.Nil(err)
+	require.Equal(outflow.CreatedAt.Add(2*time.Hour), outflow.UnlockAt)
+
+	privates := []string{testBitcoinKeyHolderPrivate}
+	id = testBatchApproveTransactions(ctx, require, node, holder, privates, privates, []string{small, large}, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	for _, hash := range []string{small, large} {
+		tx, err := node.store.ReadTransaction(ctx, hash)
+		require.Nil(err)
+		require.Equal(common.RequestStateInitial, tx.State)
+		requests, err := node.store.ListAllSignaturesForTransaction(ctx, hash, common.RequestStateInitial)
+		require.Nil(err)
+		require.Len(requests, 0)
+	}
+
+	// the same batch is approved after the large transaction unlocked
+	id = testBatchApproveTransactions(ctx, require, node, holder, privates, privates, []string{small, large}, 3*time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	for _, hash := range []string{small, large} {
+		tx, err := node.store.ReadTransaction(ctx, hash)
+		require.Nil(err)
+		require.Equal(common.RequestStatePending, tx.State)
+		psTx, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+		require.Nil(err)
+		requests, err := node.store.ListAllSignaturesForTransaction(ctx, hash, common.RequestStateInitial)
+		require.Nil(err)
+		require.Len(requests, len(psTx.UnsignedTx.TxIn))
+	}
+}
+
+func testBatchProposeTransaction(ctx context.Context, require *require.Assertions, node *Node, bondId string, amount decimal.Decimal) string {
+	rid := uuid.Must(uuid.NewV4()).String()
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	info, _ := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	extra := []byte{0}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testTransactionReceiver)...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, amount)
+	testStep(ctx, require, node, out)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeProposeTransaction)
+	psTx, err := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Equal(psTx.Hash(), tx.TransactionHash)
+	require.Equal(hex.EncodeToString(b), tx.RawTransaction)
+	return tx.TransactionHash
+}
+
+// testBatchApproveTransactions sends the observer request to approve all the
+// transactions, the raw of each transaction is signed by the privates, and
+// the batch message is signed by the signers with the holder signatures
+func testBatchApproveTransactions(ctx context.Context, require *require.Assertions, node *Node, holder string, privates, signers []string, hashes []string, delay time.Duration) string {
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	holders, _, err := node.store.ReadSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	require.Nil(err)
+
+	batch := &common.BatchApproval{}
+	for _, hash := range hashes {
+		tx, err := node.store.ReadTransaction(ctx, hash)
+		require.Nil(err)
+		psTx := testHoldersSignBitcoinTransaction(tx.RawTransaction, privates)
+		batch.Transactions = append(batch.Transactions, &common.BatchApprovalTransaction{
+			RequestId: tx.RequestId,
+			Raw:       psTx.Marshal(),
+		})
+	}
+	ms := common.BatchApprovalMessage(safe.RequestId, hashes)
+	batch.Signature = testHoldersSignBitcoinMessage(holders, signers, ms)
+	ref := testWriteStorageRaw(ctx, require, node, batch.Encode())
+
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeApproveTransactions, ref[:], common.CurveSecp256k1ECDSABitcoin)
+	out.SequencerCreatedAt = out.SequencerCreatedAt.Add(delay)
+	testStep(ctx, require, node, out)
+	return id
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -16,6 +19,7 @@ import (
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/gofrs/uuid/v5"
 	"github.com/shopspring/decimal"
@@ -151,7 +155,7 @@ func (node *Node) processBitcoinSafeCloseAccount(ctx context.Context, req *commo
 	var requests []*store.SignatureRequest
 	for idx := range msgTx.TxIn {
 		pop := msgTx.TxIn[idx].PreviousOutPoint
-		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, safe.Chain)
 		logger.Printf("node.checkBitcoinUTXOSignatureRequired(%s, %d) => %t", pop.Hash.String(), pop.Index, required)
 		if !required {
 			continue
@@ -216,8 +220,9 @@ func (node *Node) closeBitcoinAccountWithHolder(ctx context.Context, req *common
 	opsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(raw)
 	msgTx := opsbt.UnsignedTx
 	txHash := msgTx.TxHash().String()
-	signedByHolder := bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), safe.Holder)
-	logger.Printf("bitcoin.CheckTransactionPartiallySignedBy(%x, %s) => %t", raw, safe.Holder, signedByHolder)
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	signedByHolder := bitcoin.CheckTransactionPartiallySignedByHolders(hex.EncodeToString(raw), holders, threshold)
+	logger.Printf("bitcoin.CheckTransactionPartiallySignedByHolders(%x, %v, %d) => %t", raw, holders, threshold, signedByHolder)
 	if !signedByHolder {
 		return node.failRequest(ctx, req, "")
 	}
@@ -316,12 +321,12 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 	if arp.Observer != "" && arp.Observer != observer {
 		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
 	}
-	if !common.CheckUnique(req.Holder, signer, observer) {
+	if !checkSafeKeysUnique(arp.Holders, signer, observer) {
 		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
 	}
 	path := bitcoinDefaultDerivationPath()
 
-	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, req.Holder, signer, observer, path, arp.Timelock, chain)
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, arp.Holders, int(arp.HolderThreshold), signer, observer, path, arp.Timelock, chain)
 	logger.Verbosef("node.buildBitcoinWitnessAccountWithDerivation(%v) => %v %v", req, wsa, err)
 	if err != nil {
 		panic(err)
@@ -363,7 +368,9 @@ func (node *Node) processBitcoinSafeProposeAccount(ctx context.Context, req *com
 		CreatedAt: req.CreatedAt,
 		UpdatedAt: req.CreatedAt,
 	}
-	err = node.store.WriteSafeProposalWithRequest(ctx, sp, txs, req)
+	holders := buildSafeHolders(req, chain, wsa.Address, arp)
+	policy := buildSafePolicy(req, chain, arp.Policy, req.CreatedAt)
+	err = node.store.WriteSafeProposalWithRequest(ctx, sp, holders, policy, txs, req)
 	if err != nil {
 		panic(err)
 	}
@@ -385,7 +392,7 @@ func (node *Node) processBitcoinSafeApproveAccount(ctx context.Context, req *com
 	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, assetId, req.Holder)
 
 	extra := req.ExtraBytes()
-	if len(extra) < 64 {
+	if len(extra) < 48 {
 		return node.failRequest(ctx, req, "")
 	}
 	rid, err := uuid.FromBytes(extra[:16])
@@ -403,9 +410,12 @@ func (node *Node) processBitcoinSafeApproveAccount(ctx context.Context, req *com
 		return node.failRequest(ctx, req, "")
 	}
 
+	holders, threshold := node.readSafeHolderKeys(ctx, sp.Address, sp.Holder)
 	ms := fmt.Sprintf("APPROVE:%s:%s", rid.String(), sp.Address)
 	msg := bitcoin.HashMessageForSignature(ms, sp.Chain)
-	err = bitcoin.VerifySignatureDER(req.Holder, msg, extra[16:])
+	_, err = verifySafeApprovalSignatures(holders, threshold, node.readSafeApprovalSignature(ctx, holders, extra[16:]), func(public string, sig []byte) error {
+		return bitcoin.VerifySignatureDER(public, msg, sig)
+	})
 	logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", req, err)
 	if err != nil {
 		return node.failRequest(ctx, req, "")
@@ -478,6 +488,8 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
 		assetId = common.SafeLitecoinChainId
+	case common.SafeChainDogecoin:
+		assetId = common.SafeDogecoinChainId
 	default:
 		panic(safe.Chain)
 	}
@@ -527,7 +539,8 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	if err != nil {
 		panic(fmt.Errorf("store.ListAllBitcoinUTXOsForHolder(%s) => %v", req.Holder, err))
 	}
-	switch extra[0] {
+	flag := extra[0]
+	switch flag {
 	case common.FlagProposeNormalTransaction:
 	case common.FlagProposeRecoveryTransaction:
 		for _, input := range mainInputs {
@@ -552,18 +565,18 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 	}
 
 	var outputs []*bitcoin.Output
+	var selected []string
 	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
 	if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
 		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
-		extra := stx.Extra
-		var recipients [][2]string // TODO better encoding
-		err = json.Unmarshal(extra, &recipients)
+		recipients, inputs, err := parseBitcoinTransactionRecipients(stx.Extra)
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
+		selected = inputs
 		for _, rp := range recipients {
 			script, err := bitcoin.ParseAddress(rp[0], safe.Chain)
-			logger.Printf("bitcoin.ParseAddress(%s, %d) => %x %v", string(extra), safe.Chain, script, err)
+			logger.Printf("bitcoin.ParseAddress(%s, %d) => %x %v", rp[0], safe.Chain, script, err)
 			if err != nil {
 				return node.failRequest(ctx, req, "")
 			}
@@ -604,6 +617,33 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 		return node.failRequest(ctx, req, "")
 	}
 
+	var outflow *store.SafeOutflow
+	if flag == common.FlagProposeNormalTransaction {
+		outflow, err = node.evaluateSafePolicy(ctx, req, safe, assetId, safePolicyDestinations(recipients), total)
+		if err != nil {
+			return node.failRequestWithPolicyViolation(ctx, req, safe, err.Error())
+		}
+	}
+
+	switch {
+	case flag == common.FlagProposeRecoveryTransaction && len(selected) > 0:
+		return node.failRequest(ctx, req, "")
+	case flag == common.FlagProposeRecoveryTransaction:
+	case len(selected) > 0:
+		mainInputs = node.readBitcoinSelectedUTXOs(ctx, safe, mainInputs, selected)
+		if len(mainInputs) == 0 {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		satoshi := bitcoin.ParseSatoshi(total.String())
+		inputs, err := bitcoin.SelectInputs(mainInputs, satoshi, safe.Chain)
+		logger.Printf("bitcoin.SelectInputs(%s, %d) => %d %v", req.Holder, satoshi, len(inputs), err)
+		if bitcoin.IsInsufficientInputError(err) {
+			return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+		}
+		mainInputs = inputs
+	}
+
 	psbt, err := bitcoin.BuildPartiallySignedTransaction(mainInputs, outputs, req.Operation().IdBytes(), safe.Chain)
 	logger.Printf("bitcoin.BuildPartiallySignedTransaction(%v) => %v %v", req, psbt, err)
 	if bitcoin.IsInsufficientInputError(err) {
@@ -641,6 +681,119 @@ func (node *Node) processBitcoinSafeProposeTransaction(ctx context.Context, req
 		CreatedAt:       req.CreatedAt,
 		UpdatedAt:       req.CreatedAt,
 	}
+	if outflow != nil {
+		outflow.TransactionHash = tx.TransactionHash
+	}
+	transacionInputs := store.TransactionInputsFromBitcoin(mainInputs)
+	err = node.store.WriteTransactionWithOutflowAndRequest(ctx, tx, transacionInputs, outflow, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// The observer proposes the consolidation of a safe with too many unspent
+// outputs, with the holder signatures of CONSOLIDATE:<safe request>:<address>:<id>
+// reaching the holder threshold of the safe. The authorisation only allows the
+// proposal, and the holders should still approve the transaction with the
+// normal signer flow, because the holder signatures of each input are required
+// by the safe script.
+func (node *Node) processBitcoinSafeConsolidateUTXOs(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	return node.processBitcoinSafeSelfSpend(ctx, req, "CONSOLIDATE", func(safe *store.Safe, inputs []*bitcoin.Input) []*bitcoin.Input {
+		inputs, err := bitcoin.SelectConsolidationInputs(inputs, safe.Chain)
+		logger.Printf("bitcoin.SelectConsolidationInputs(%s) => %d %v", safe.Holder, len(inputs), err)
+		if err != nil {
+			return nil
+		}
+		return inputs
+	})
+}
+
+// safeSelfSpendMessage is signed by the holders to let observer propose a
+// transaction spending the safe funds to the safe itself, the id is the nonce
+// chosen by the holders and used as the request id, and a request id is never
+// processed twice, so each authorisation could only propose one transaction.
+func safeSelfSpendMessage(prefix string, safe *store.Safe, id string) string {
+	return fmt.Sprintf("%s:%s:%s:%s", prefix, safe.RequestId, safe.Address, id)
+}
+
+// processBitcoinSafeSelfSpend verifies the holder signatures of the self spend
+// message with the prefix, and proposes the transaction spending the inputs
+// selected to the safe address itself, so the change deposit won't mint any
+// safe asset. The inputs are all the unspent outputs requiring the holder
+// signature, sorted by age, and no transaction is proposed if none selected.
+func (node *Node) processBitcoinSafeSelfSpend(ctx context.Context, req *common.Request, prefix string, selectInputs func(safe *store.Safe, inputs []*bitcoin.Input) []*bitcoin.Input) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	holders, _ := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	sig := node.readSafeApprovalSignature(ctx, holders, req.ExtraBytes())
+	msg := bitcoin.HashMessageForSignature(safeSelfSpendMessage(prefix, safe, req.Id), safe.Chain)
+	err = node.verifySafeMessageSignatureWithHolders(ctx, safe, msg, sig, bitcoin.VerifySignatureDER)
+	logger.Printf("node.verifySafeMessageSignatureWithHolders(%s, %v) => %v", prefix, req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	_, assetId := node.bitcoinParams(safe.Chain)
+	mainInputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllBitcoinUTXOsForHolder(%s) => %v", req.Holder, err))
+	}
+	mainInputs = slices.DeleteFunc(mainInputs, func(in *bitcoin.Input) bool {
+		hash, _ := chainhash.NewHashFromStr(in.TransactionHash)
+		return !node.checkBitcoinUTXOSignatureRequired(ctx, wire.OutPoint{Hash: *hash, Index: in.Index}, safe.Chain)
+	})
+	mainInputs = selectInputs(safe, mainInputs)
+	if len(mainInputs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(mainInputs, nil, req.Operation().IdBytes(), safe.Chain)
+	logger.Printf("bitcoin.BuildPartiallySignedTransaction(%v) => %v %v", req, psbt, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := psbt.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionBitcoinSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	tx := &store.Transaction{
+		TransactionHash: psbt.Hash(),
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            "[]",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
 	transacionInputs := store.TransactionInputsFromBitcoin(mainInputs)
 	err = node.store.WriteTransactionWithRequest(ctx, tx, transacionInputs, txs, req)
 	if err != nil {
@@ -673,29 +826,47 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
 	if err != nil {
 		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
-	} else if tx == nil {
-		return node.failRequest(ctx, req, "")
-	} else if tx.State == common.RequestStateDone {
-		return node.failRequest(ctx, req, "")
-	} else if tx.Holder != req.Holder {
+	}
+	if !node.checkSafeTransactionApprovable(ctx, req, tx) {
 		return node.failRequest(ctx, req, "")
 	}
 
 	var ref crypto.Hash
 	copy(ref[:], extra[16:])
 	raw := node.readStorageExtraFromObserver(ctx, ref)
-	signed := bitcoin.CheckTransactionPartiallySignedBy(hex.EncodeToString(raw), tx.Holder)
-	logger.Printf("bitcoin.CheckTransactionPartiallySignedBy(%x, %s) => %t", raw, tx.Holder, signed)
-	if !signed {
+	requests, valid := node.buildBitcoinApprovalSignatureRequests(ctx, req, safe, tx, raw)
+	if !valid {
 		return node.failRequest(ctx, req, "")
 	}
+
+	txs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, requests, tx.TransactionHash, "", req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, len(requests), req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// buildBitcoinApprovalSignatureRequests checks the raw signed by the holders,
+// and returns the signature requests of all inputs not signed yet
+func (node *Node) buildBitcoinApprovalSignatureRequests(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, raw []byte) ([]*store.SignatureRequest, bool) {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	signed := bitcoin.CheckTransactionPartiallySignedByHolders(hex.EncodeToString(raw), holders, threshold)
+	logger.Printf("bitcoin.CheckTransactionPartiallySignedByHolders(%x, %v, %d) => %t", raw, holders, threshold, signed)
+	if !signed {
+		return nil, false
+	}
 	hpsbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(raw)
 
 	b := common.DecodeHexOrPanic(tx.RawTransaction)
 	psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 	msgTx := psbt.UnsignedTx
 	if msgTx.TxHash() != hpsbt.UnsignedTx.TxHash() {
-		return node.failRequest(ctx, req, "")
+		return nil, false
 	}
 
 	var requests []*store.SignatureRequest
@@ -706,7 +877,7 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 			continue
 		}
 
-		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, safe.Chain)
 		logger.Printf("node.checkBitcoinUTXOSignatureRequired(%s, %d) => %t", pop.Hash.String(), pop.Index, required)
 		if !required {
 			continue
@@ -733,17 +904,7 @@ func (node *Node) processBitcoinSafeApproveTransaction(ctx context.Context, req
 		sr.RequestId = common.UniqueId(req.Id, sr.Message)
 		requests = append(requests, sr)
 	}
-
-	txs := node.buildSignerSignRequests(ctx, req, requests, safe.Path)
-	if len(txs) == 0 {
-		return node.failRequest(ctx, req, "")
-	}
-	err = node.store.WriteSignatureRequestsWithRequest(ctx, requests, tx.TransactionHash, "", req, txs)
-	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, len(requests), req, err)
-	if err != nil {
-		panic(err)
-	}
-	return txs, ""
+	return requests, true
 }
 
 func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
@@ -781,7 +942,7 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 
 	for idx := range msgTx.TxIn {
 		pop := msgTx.TxIn[idx].PreviousOutPoint
-		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, safe.Chain)
 		if !required {
 			continue
 		}
@@ -830,7 +991,7 @@ func (node *Node) processBitcoinSafeSignatureResponse(ctx context.Context, req *
 	return txs, ""
 }
 
-func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, holder, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
+func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, holders []string, threshold int, signer, observer string, path []byte, timelock time.Duration, chain byte) (*bitcoin.WitnessScriptAccount, error) {
 	sdk, err := node.deriveBIP32WithPath(ctx, signer, path)
 	logger.Verbosef("bitcoin.DeriveBIP32(%s) => %s %v", signer, sdk, err)
 	if err != nil {
@@ -841,7 +1002,7 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", observer, err)
 	}
-	return bitcoin.BuildWitnessScriptAccount(holder, sdk, odk, timelock, chain)
+	return bitcoin.BuildWitnessScriptAccountWithHolders(holders, threshold, sdk, odk, timelock, chain)
 }
 
 func (node *Node) deriveBIP32WithPath(ctx context.Context, public string, path8 []byte) (string, error) {
@@ -874,7 +1035,74 @@ func (node *Node) checkTransactionIndexSignaturePending(ctx context.Context, has
 	return old.CreatedAt.Add(SafeSignatureTimeout).After(req.CreatedAt), nil
 }
 
-func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint) bool {
+// readBitcoinSelectedUTXOs returns the holder selected outpoints, which must
+// all be unspent and not pending in other transactions of the safe
+func (node *Node) readBitcoinSelectedUTXOs(ctx context.Context, safe *store.Safe, unspent []*bitcoin.Input, selected []string) []*bitcoin.Input {
+	if len(selected) > bitcoin.MaxUnspentUtxo {
+		return nil
+	}
+	var inputs []*bitcoin.Input
+	for _, s := range selected {
+		pop, err := parseBitcoinOutpoint(s)
+		if err != nil {
+			return nil
+		}
+		i := slices.IndexFunc(unspent, func(in *bitcoin.Input) bool {
+			return in.TransactionHash == pop.Hash.String() && in.Index == pop.Index
+		})
+		if i < 0 || slices.Contains(inputs, unspent[i]) {
+			return nil
+		}
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, safe.Chain)
+		logger.Printf("node.checkBitcoinUTXOSignatureRequired(%s, %d) => %t", pop.Hash.String(), pop.Index, required)
+		if !required {
+			return nil
+		}
+		inputs = append(inputs, unspent[i])
+	}
+	return inputs
+}
+
+func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint, chain byte) bool {
 	utxo, _, _ := node.store.ReadBitcoinUTXO(ctx, pop.Hash.String(), int(pop.Index))
-	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script)
+	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script, chain)
+}
+
+// parseBitcoinTransactionRecipients decodes the referenced transaction proposal,
+// either a recipients list, or an object with the holder selected outpoints
+//
+// [["address","amount"]]
+// {"recipients":[["address","amount"]],"inputs":["hash:index"]}
+func parseBitcoinTransactionRecipients(extra []byte) ([][2]string, []string, error) {
+	var recipients [][2]string // TODO better encoding
+	err := json.Unmarshal(extra, &recipients)
+	if err == nil {
+		return recipients, nil, nil
+	}
+	var proposal struct {
+		Recipients [][2]string `json:"recipients"`
+		Inputs     []string    `json:"inputs"`
+	}
+	err = json.Unmarshal(extra, &proposal)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proposal.Recipients, proposal.Inputs, nil
+}
+
+func parseBitcoinOutpoint(s string) (wire.OutPoint, error) {
+	var pop wire.OutPoint
+	hash, index, found := strings.Cut(s, ":")
+	if !found {
+		return pop, fmt.Errorf("invalid outpoint %s", s)
+	}
+	h, err := chainhash.NewHashFromStr(hash)
+	if err != nil || h.String() != hash {
+		return pop, fmt.Errorf("invalid outpoint %s", s)
+	}
+	i, err := strconv.ParseUint(index, 10, 32)
+	if err != nil || strconv.FormatUint(i, 10) != index {
+		return pop, fmt.Errorf("invalid outpoint %s", s)
+	}
+	return *wire.NewOutPoint(h, uint32(i)), nil
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -18,6 +19,9 @@ const (
 	SafeSignatureTimeout  = 10 * time.Minute
 	SafeKeyBackupMaturity = 24 * time.Hour
 
+	SafeProposalExpiryMinimum = time.Hour
+	SafeProposalExpiryMaximum = 90 * 24 * time.Hour
+
 	SafeStateApproved = common.RequestStateDone
 	SafeStatePending  = common.RequestStatePending
 	SafeStateClosed   = common.RequestStateFailed
@@ -31,6 +35,10 @@ func ethereumDefaultDerivationPath() []byte {
 	return []byte{0, 0, 0, 0}
 }
 
+func solanaDefaultDerivationPath() []byte {
+	return []byte{0, 0, 0, 0}
+}
+
 func (node *Node) failRequest(ctx context.Context, req *common.Request, assetId string) ([]*mtg.Transaction, string) {
 	logger.Printf("node.failRequest(%v, %s)", req, assetId)
 	err := node.store.FailRequest(ctx, req, assetId, nil)
@@ -84,7 +92,7 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 	switch common.NormalizeCurve(common.SafeChainCurve(safe.Chain)) {
 	case common.CurveSecp256k1ECDSABitcoin:
 		msg := bitcoin.HashMessageForSignature(ms, safe.Chain)
-		err := bitcoin.VerifySignatureDER(safe.Holder, msg, sig)
+		err := node.verifySafeMessageSignatureWithAnyHolder(ctx, safe, msg, sig, bitcoin.VerifySignatureDER)
 		logger.Printf("holder: bitcoin.VerifySignatureDER(%s, %x) => %v", ms, sig, err)
 		if err != nil {
 			odk, err := node.deriveBIP32WithPath(ctx, safe.Observer, common.DecodeHexOrPanic(safe.Path))
@@ -99,7 +107,7 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 		}
 	case common.CurveSecp256k1ECDSAEthereum:
 		msg := []byte(ms)
-		err := ethereum.VerifyMessageSignature(safe.Holder, msg, sig)
+		err := node.verifySafeMessageSignatureWithAnyHolder(ctx, safe, msg, sig, ethereum.VerifyMessageSignature)
 		logger.Printf("holder: ethereum.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
 		if err != nil {
 			err = ethereum.VerifyMessageSignature(safe.Observer, msg, sig)
@@ -108,6 +116,17 @@ func (node *Node) verifySafeMessageSignatureWithHolderOrObserver(ctx context.Con
 				return err
 			}
 		}
+	case common.CurveEdwards25519Default:
+		msg := []byte(ms)
+		err := node.verifySafeMessageSignatureWithAnyHolder(ctx, safe, msg, sig, solana.VerifyMessageSignature)
+		logger.Printf("holder: solana.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+		if err != nil {
+			err = solana.VerifyMessageSignature(safe.Observer, msg, sig)
+			logger.Printf("observer: solana.VerifyMessageSignature(%s, %x) => %v", ms, sig, err)
+			if err != nil {
+				return err
+			}
+		}
 	default:
 		panic(safe.Chain)
 	}
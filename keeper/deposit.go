@@ -13,6 +13,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -30,7 +31,7 @@ type Deposit struct {
 	Amount       *big.Int
 }
 
-func parseDepositExtra(req *common.Request) (*Deposit, error) {
+func (node *Node) parseDepositExtra(ctx context.Context, req *common.Request) (*Deposit, error) {
 	extra := req.ExtraBytes()
 	if len(extra) < 1+16+32+8 {
 		return nil, fmt.Errorf("invalid deposit extra %s", req.ExtraHEX)
@@ -44,7 +45,7 @@ func parseDepositExtra(req *common.Request) (*Deposit, error) {
 	}
 	extra = extra[17:]
 	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		deposit.Hash = hex.EncodeToString(extra[0:32])
 		deposit.Index = binary.BigEndian.Uint64(extra[32:40])
 		deposit.Amount = new(big.Int).SetBytes(extra[40:])
@@ -56,6 +57,22 @@ func parseDepositExtra(req *common.Request) (*Deposit, error) {
 		deposit.AssetAddress = gc.BytesToAddress(extra[32:52]).Hex()
 		deposit.Index = binary.BigEndian.Uint64(extra[52:60])
 		deposit.Amount = new(big.Int).SetBytes(extra[60:])
+	case common.SafeChainSolana:
+		// the solana signature and mint are too large for the extra, so
+		// they are written to the storage referenced by the request
+		var ref crypto.Hash
+		copy(ref[:], extra[0:32])
+		raw := node.readStorageExtraFromObserver(ctx, ref)
+		if len(raw) != 64+32 {
+			return nil, fmt.Errorf("invalid deposit storage %x", raw)
+		}
+		deposit.Hash = solana.EncodeSignature(raw[0:64])
+		deposit.AssetAddress = solana.PublicKey(raw[64:96]).String()
+		deposit.Index = binary.BigEndian.Uint64(extra[32:40])
+		deposit.Amount = new(big.Int).SetBytes(extra[40:])
+		if !deposit.Amount.IsUint64() {
+			return nil, fmt.Errorf("invalid deposit amount %s", deposit.Amount.String())
+		}
 	default:
 		return nil, fmt.Errorf("invalid deposit chain %d", deposit.Chain)
 	}
@@ -67,7 +84,7 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 	if req.Role != common.RequestRoleObserver {
 		panic(req.Role)
 	}
-	deposit, err := parseDepositExtra(req)
+	deposit, err := node.parseDepositExtra(ctx, req)
 	logger.Printf("req.parseDepositExtra(%v) => %v %v", req, deposit, err)
 	if err != nil {
 		return node.failRequest(ctx, req, "")
@@ -115,10 +132,12 @@ func (node *Node) CreateHolderDeposit(ctx context.Context, req *common.Request)
 	}
 
 	switch deposit.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.doBitcoinHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset, plan.TransactionMinimum)
 	case common.SafeChainEthereum, common.SafeChainPolygon:
 		return node.doEthereumHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset)
+	case common.SafeChainSolana:
+		return node.doSolanaHolderDeposit(ctx, req, deposit, safe, bond.AssetId, asset)
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -130,7 +149,7 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		panic(asset.Decimals)
 	}
 	switch asset.AssetId {
-	case common.SafeBitcoinChainId, common.SafeLitecoinChainId:
+	case common.SafeBitcoinChainId, common.SafeLitecoinChainId, common.SafeDogecoinChainId:
 	default:
 		panic(asset.AssetId)
 	}
@@ -185,8 +204,13 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		return node.failRequest(ctx, req, "")
 	}
 
+	sender, err := bitcoin.RPCGetTransactionSender(safe.Chain, rpc, btx)
+	if err != nil {
+		panic(fmt.Errorf("bitcoin.RPCGetTransactionSender(%s) => %v", btx.TxId, err))
+	}
+
 	var txs []*mtg.Transaction
-	if !change {
+	if !change && !node.checkSafeMigrationDeposit(ctx, safe, sender) {
 		tx := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), amount.String(), nil, req.Id)
 		if tx == nil {
 			// no compaction needed, just retry from observer
@@ -194,11 +218,6 @@ func (node *Node) doBitcoinHolderDeposit(ctx context.Context, req *common.Reques
 		}
 		txs = append(txs, tx)
 	}
-
-	sender, err := bitcoin.RPCGetTransactionSender(safe.Chain, rpc, btx)
-	if err != nil {
-		panic(fmt.Errorf("bitcoin.RPCGetTransactionSender(%s) => %v", btx.TxId, err))
-	}
 	err = node.store.WriteBitcoinOutputFromRequest(ctx, safe, output, req, asset.AssetId, sender, txs)
 	if err != nil {
 		panic(err)
@@ -238,17 +257,21 @@ func (node *Node) doEthereumHolderDeposit(ctx context.Context, req *common.Reque
 		return node.failRequest(ctx, req, "")
 	}
 
-	t := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), decimal.NewFromBigInt(deposit.Amount, -int32(asset.Decimals)).String(), nil, req.Id)
-	if t == nil {
-		// no compaction needed, just retry from observer
-		return node.failRequest(ctx, req, "")
+	var txs []*mtg.Transaction
+	if !node.checkSafeMigrationDeposit(ctx, safe, output.Sender) {
+		t := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), decimal.NewFromBigInt(deposit.Amount, -int32(asset.Decimals)).String(), nil, req.Id)
+		if t == nil {
+			// no compaction needed, just retry from observer
+			return node.failRequest(ctx, req, "")
+		}
+		txs = append(txs, t)
 	}
-	err = node.store.CreateEthereumBalanceDepositFromRequest(ctx, safe, safeBalance, deposit.Hash, int64(deposit.Index), deposit.Amount, output.Sender, req, []*mtg.Transaction{t})
+	err = node.store.CreateEthereumBalanceDepositFromRequest(ctx, safe, safeBalance, deposit.Hash, int64(deposit.Index), deposit.Amount, output.Sender, req, txs)
 	logger.Printf("store.UpdateEthereumBalanceFromRequest(%v) => %v", req, err)
 	if err != nil {
 		panic(err)
 	}
-	return []*mtg.Transaction{t}, ""
+	return txs, ""
 }
 
 func (node *Node) checkBitcoinChange(ctx context.Context, deposit *Deposit, btx *bitcoin.RPCTransaction) (bool, error) {
@@ -260,9 +283,10 @@ func (node *Node) checkBitcoinChange(ctx context.Context, deposit *Deposit, btx
 	if err != nil {
 		return false, err
 	}
+	// all outputs are change for the consolidation without recipients
 	var recipients []map[string]string
 	err = json.Unmarshal([]byte(tx.Data), &recipients)
-	if err != nil || len(recipients) == 0 {
+	if err != nil {
 		return false, fmt.Errorf("store.ReadTransaction(%s) => %s", spentBy, tx.Data)
 	}
 	return deposit.Index >= uint64(len(recipients)), nil
@@ -284,7 +308,8 @@ func (node *Node) verifyBitcoinTransaction(ctx context.Context, req *common.Requ
 	switch typ {
 	case bitcoin.InputTypeP2WSHMultisigHolderSigner:
 		path := common.DecodeHexOrPanic(safe.Path)
-		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, safe.Holder, safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
+		holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holders, threshold, safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
 		if err != nil {
 			panic(err)
 		}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"slices"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -74,7 +75,7 @@ func (node *Node) processEthereumSafeCloseAccount(ctx context.Context, req *comm
 	if err != nil {
 		return node.failRequest(ctx, req, "")
 	}
-	signedByObserver, err := node.checkEthereumTransactionSignedBy(safe, t, safe.Observer)
+	signedByObserver, err := node.checkEthereumTransactionSignedBy(ctx, safe, t, safe.Observer)
 	logger.Printf("node.checkEthereumTransactionSignedBy(%v, %s) => %t %v", t, safe.Observer, signedByObserver, err)
 	if err != nil {
 		panic(err)
@@ -184,8 +185,8 @@ func (node *Node) processEthereumSafeCloseAccount(ctx context.Context, req *comm
 
 func (node *Node) closeEthereumAccountWithHolder(ctx context.Context, req *common.Request, safe *store.Safe, raw []byte) ([]*mtg.Transaction, string) {
 	t, _ := ethereum.UnmarshalSafeTransaction(raw)
-	signedByHolder, err := node.checkEthereumTransactionSignedBy(safe, t, safe.Holder)
-	logger.Printf("node.checkEthereumTransactionSignedBy(%v, %s) => %t %v", t, safe.Holder, signedByHolder, err)
+	signedByHolder, err := node.checkEthereumTransactionSignedByHolders(ctx, safe, t)
+	logger.Printf("node.checkEthereumTransactionSignedByHolders(%v, %s) => %t %v", t, safe.Holder, signedByHolder, err)
 	if err != nil {
 		panic(err)
 	} else if !signedByHolder {
@@ -313,13 +314,13 @@ func (node *Node) processEthereumSafeProposeAccount(ctx context.Context, req *co
 	if arp.Observer != "" && arp.Observer != observer {
 		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
 	}
-	if !common.CheckUnique(req.Holder, signer, observer) {
+	if !checkSafeKeysUnique(arp.Holders, signer, observer) {
 		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
 	}
 
 	rpc, assetId := node.ethereumParams(chain)
-	gs, t, err := ethereum.BuildGnosisSafe(ctx, rpc, req.Holder, signer, observer, req.Id, arp.Timelock, chain)
-	logger.Verbosef("ethereum.BuildGnosisSafe(%v) => %v %v", req, gs, err)
+	gs, t, err := ethereum.BuildGnosisSafeWithHolders(ctx, rpc, arp.Holders, int(arp.HolderThreshold), signer, observer, req.Id, arp.Timelock, chain)
+	logger.Verbosef("ethereum.BuildGnosisSafeWithHolders(%v) => %v %v", req, gs, err)
 	if err != nil {
 		panic(err)
 	}
@@ -374,7 +375,9 @@ func (node *Node) processEthereumSafeProposeAccount(ctx context.Context, req *co
 		CreatedAt: req.CreatedAt,
 		UpdatedAt: req.CreatedAt,
 	}
-	err = node.store.WriteEthereumSafeProposalWithRequest(ctx, sp, tx, txs, req)
+	holders := buildSafeHolders(req, chain, gs.Address, arp)
+	policy := buildSafePolicy(req, chain, arp.Policy, req.CreatedAt)
+	err = node.store.WriteEthereumSafeProposalWithRequest(ctx, sp, holders, policy, tx, txs, req)
 	if err != nil {
 		panic(err)
 	}
@@ -401,7 +404,7 @@ func (node *Node) processEthereumSafeApproveAccount(ctx context.Context, req *co
 	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, assetId, req.Holder)
 
 	extra := req.ExtraBytes()
-	if len(extra) < 64 {
+	if len(extra) < 48 {
 		return node.failRequest(ctx, req, "")
 	}
 	rid, err := uuid.FromBytes(extra[:16])
@@ -438,16 +441,23 @@ func (node *Node) processEthereumSafeApproveAccount(ctx context.Context, req *co
 		panic(err)
 	}
 
-	err = ethereum.VerifyMessageSignature(req.Holder, t.Message, extra[16:])
+	holders, threshold := node.readSafeHolderKeys(ctx, sp.Address, sp.Holder)
+	sigs, err := verifySafeApprovalSignatures(holders, threshold, node.readSafeApprovalSignature(ctx, holders, extra[16:]), func(public string, sig []byte) error {
+		if len(sig) != 65 {
+			return fmt.Errorf("invalid approval signature %x", sig)
+		}
+		return ethereum.VerifyMessageSignature(public, t.Message, sig)
+	})
 	logger.Printf("ethereum.VerifyMessageSignature(%v) => %v", req, err)
 	if err != nil {
 		return node.failRequest(ctx, req, "")
 	}
-	_, pubs := ethereum.GetSortedSafeOwners(sp.Holder, sp.Signer, sp.Observer)
-	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v", sp.Holder, sp.Signer, sp.Observer, pubs)
+	_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, sp.Signer, sp.Observer)
+	logger.Printf("ethereum.GetSortedSafeOwnersWithHolders(%v, %s, %s) => %v", holders, sp.Signer, sp.Observer, pubs)
 	for i, pub := range pubs {
-		if pub == sp.Holder {
-			t.Signatures[i] = extra[16:]
+		j := slices.Index(holders, pub)
+		if j >= 0 && sigs[j] != nil {
+			t.SetSignature(i, sigs[j])
 		}
 	}
 
@@ -647,6 +657,14 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		return node.failRequest(ctx, req, "")
 	}
 
+	var outflow *store.SafeOutflow
+	if flag == common.FlagProposeNormalTransaction {
+		outflow, err = node.evaluateSafePolicy(ctx, req, safe, id.String(), safePolicyDestinations(recipients), total)
+		if err != nil {
+			return node.failRequestWithPolicyViolation(ctx, req, safe, err.Error())
+		}
+	}
+
 	var t *ethereum.SafeTransaction
 	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
 	txType := ethereum.TypeETHTx
@@ -745,7 +763,10 @@ func (node *Node) processEthereumSafeProposeTransaction(ctx context.Context, req
 		CreatedAt:       req.CreatedAt,
 		UpdatedAt:       req.CreatedAt,
 	}
-	err = node.store.WriteTransactionWithRequest(ctx, tx, nil, txs, req)
+	if outflow != nil {
+		outflow.TransactionHash = tx.TransactionHash
+	}
+	err = node.store.WriteTransactionWithOutflowAndRequest(ctx, tx, nil, outflow, txs, req)
 	if err != nil {
 		panic(err)
 	}
@@ -776,11 +797,8 @@ func (node *Node) processEthereumSafeApproveTransaction(ctx context.Context, req
 	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
 	if err != nil {
 		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
-	} else if tx == nil {
-		return node.failRequest(ctx, req, "")
-	} else if tx.State == common.RequestStateDone {
-		return node.failRequest(ctx, req, "")
-	} else if tx.Holder != req.Holder {
+	}
+	if !node.checkSafeTransactionApprovable(ctx, req, tx) {
 		return node.failRequest(ctx, req, "")
 	}
 
@@ -792,13 +810,32 @@ func (node *Node) processEthereumSafeApproveTransaction(ctx context.Context, req
 	if err != nil {
 		panic(err)
 	}
+	sr, valid := node.buildEthereumApprovalSignatureRequest(ctx, req, safe, tx, t)
+	if !valid {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		// no compaction needed, just retry from observer
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(t.Marshal()), req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
 
-	signed, err := node.checkEthereumTransactionSignedBy(safe, t, safe.Holder)
-	logger.Printf("node.checkEthereumTransactionSignedBy(%v, %s) => %t %v", t, safe.Holder, signed, err)
+// buildEthereumApprovalSignatureRequest checks the safe transaction signed by
+// the holders, and returns the signature request of the signer
+func (node *Node) buildEthereumApprovalSignatureRequest(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, t *ethereum.SafeTransaction) (*store.SignatureRequest, bool) {
+	signed, err := node.checkEthereumTransactionSignedByHolders(ctx, safe, t)
+	logger.Printf("node.checkEthereumTransactionSignedByHolders(%v, %s) => %t %v", t, safe.Holder, signed, err)
 	if err != nil {
 		panic(err)
 	} else if !signed {
-		return node.failRequest(ctx, req, "")
+		return nil, false
 	}
 
 	hash := ethereum.HashMessageForSignature(hex.EncodeToString(t.Message))
@@ -813,17 +850,7 @@ func (node *Node) processEthereumSafeApproveTransaction(ctx context.Context, req
 		UpdatedAt:       req.CreatedAt,
 	}
 	sr.RequestId = common.UniqueId(req.Id, sr.Message)
-	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
-	if len(txs) == 0 {
-		// no compaction needed, just retry from observer
-		return node.failRequest(ctx, req, "")
-	}
-	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(t.Marshal()), req, txs)
-	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
-	if err != nil {
-		panic(err)
-	}
-	return txs, ""
+	return sr, true
 }
 
 func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
@@ -857,6 +884,13 @@ func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req
 	} else if tx.State != common.RequestStateDone {
 		return node.failRequest(ctx, req, "")
 	}
+	// the migration sweep pays no safe asset to refund
+	m, err := node.store.ReadSafeMigrationByTransaction(ctx, tx.TransactionHash)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeMigrationByTransaction(%s) => %v", tx.TransactionHash, err))
+	} else if m != nil {
+		return node.failRequest(ctx, req, "")
+	}
 
 	b := common.DecodeHexOrPanic(tx.RawTransaction)
 	st, err := ethereum.UnmarshalSafeTransaction(b)
@@ -880,6 +914,9 @@ func (node *Node) processEthereumSafeRefundTransaction(ctx context.Context, req
 	if err != nil {
 		panic(err)
 	}
+	if txRequest.Action == common.ActionEthereumSafeRefreshTimelock {
+		return node.failRequest(ctx, req, "")
+	}
 	meta, err := node.fetchAssetMeta(ctx, txRequest.AssetId)
 	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
 	if err != nil {
@@ -939,8 +976,9 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 	if len(requests) != 1 {
 		panic(fmt.Errorf("invalid signature requests len: %d", len(requests)))
 	}
-	_, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
-	logger.Printf("ethereum.GetSortedSafeOwners(%v) => %v", safe, pubs)
+	holders, _ := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	logger.Printf("ethereum.GetSortedSafeOwnersWithHolders(%v) => %v", safe, pubs)
 	for i, pub := range pubs {
 		if pub != safe.Signer {
 			continue
@@ -951,7 +989,7 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 		if err != nil {
 			panic(requests[0].Signature.String)
 		}
-		t.Signatures[i] = sig
+		t.SetSignature(i, sig)
 	}
 	raw := hex.EncodeToString(t.Marshal())
 
@@ -1039,17 +1077,35 @@ func (node *Node) processEthereumSafeSignatureResponse(ctx context.Context, req
 	return txs, ""
 }
 
-func (node *Node) checkEthereumTransactionSignedBy(safe *store.Safe, t *ethereum.SafeTransaction, public string) (bool, error) {
-	_, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
-	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v", safe.Holder, safe.Signer, safe.Observer, pubs)
+func (node *Node) checkEthereumTransactionSignedBy(ctx context.Context, safe *store.Safe, t *ethereum.SafeTransaction, public string) (bool, error) {
+	holders, _ := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	logger.Printf("ethereum.GetSortedSafeOwnersWithHolders(%v, %s, %s) => %v", holders, safe.Signer, safe.Observer, pubs)
 	for i, k := range pubs {
-		sig := t.Signatures[i]
-		if k != public || sig == nil {
+		if k != public || i >= len(t.Signatures) || t.Signatures[i] == nil {
 			continue
 		}
+		sig := t.Signatures[i]
 		err := ethereum.VerifyMessageSignature(public, t.Message, sig)
-		logger.Printf("ethereum.VerifyMessageSignature(%s, %x, %x) => %v", safe.Holder, t.Message, sig, err)
+		logger.Printf("ethereum.VerifyMessageSignature(%s, %x, %x) => %v", public, t.Message, sig, err)
 		return err == nil, nil
 	}
 	return false, nil
 }
+
+// checkEthereumTransactionSignedByHolders returns true if the transaction
+// has been signed by the holders threshold of the safe holder keys
+func (node *Node) checkEthereumTransactionSignedByHolders(ctx context.Context, safe *store.Safe, t *ethereum.SafeTransaction) (bool, error) {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	var signed int
+	for _, h := range holders {
+		ok, err := node.checkEthereumTransactionSignedBy(ctx, safe, t, h)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			signed = signed + 1
+		}
+	}
+	return signed >= threshold, nil
+}
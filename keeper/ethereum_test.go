@@ -10,13 +10,13 @@ import (
 	"fmt"
 	"math/big"
 	"os"
-	"strings"
 	"testing"
 	"time"
 
 	mc "github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	safeabi "github.com/MixinNetwork/safe/apps/ethereum/abi"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/signer"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -51,7 +51,7 @@ func TestEthereumKeeper(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "ca6324635b0c87409e9d8488e7f6bcc1fd8224c276a3788b1a8c56ddb4e20f07", common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
 
 	_, assetId := node.ethereumParams(common.SafeChainPolygon)
 	txHash := testEthereumProposeTransaction(ctx, require, node, testEthereumBondAssetId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e")
@@ -71,7 +71,7 @@ func TestEthereumKeeperERC20(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "ca6324635b0c87409e9d8488e7f6bcc1fd8224c276a3788b1a8c56ddb4e20f07", common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
 
 	cnbAssetId := ethereum.GenerateAssetId(common.SafeChainPolygon, testEthereumUSDTAddress)
 	require.Equal(testEthereumUSDTAssetId, cnbAssetId)
@@ -83,7 +83,7 @@ func TestEthereumKeeperERC20(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "55523d5ca29884f93dfa1c982177555ac5e13be49df10017054cb71aaba96595", cnbAssetId, testEthereumUSDTAddress, "100")
+	testEthereumObserverHolderDeposit(ctx, require, node, cnbAssetId, testEthereumUSDTAddress, "100")
 
 	txHash := testEthereumProposeERC20Transaction(ctx, require, node, testEthereumUSDTBondAssetId, "3e37ea1c-1455-400d-9642-f6bbcd8c7441")
 	testEthereumApproveTransaction(ctx, require, node, txHash, cnbAssetId, signers)
@@ -93,7 +93,7 @@ func TestEthereumKeeperCloseAccountWithSignerObserver(t *testing.T) {
 	require := require.New(t)
 	ctx, node, db, _, signers := testEthereumPrepare(require)
 	for i := 0; i < 10; i++ {
-		testEthereumUpdateNetworkStatus(ctx, require, node, 52430860, "55877f07c696cbf6e75174d7e8c2313d62aa665101be2c53dd1bd5f3a85507b1")
+		testUpdateEthereumNetworkTip(ctx, require, node)
 	}
 
 	observer := testEthereumPublicKey(testEthereumKeyObserver)
@@ -103,7 +103,7 @@ func TestEthereumKeeperCloseAccountWithSignerObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "ca6324635b0c87409e9d8488e7f6bcc1fd8224c276a3788b1a8c56ddb4e20f07", common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
 
 	cnbAssetId := ethereum.GenerateAssetId(common.SafeChainPolygon, testEthereumUSDTAddress)
 	require.Equal(testEthereumUSDTAssetId, cnbAssetId)
@@ -115,7 +115,7 @@ func TestEthereumKeeperCloseAccountWithSignerObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "55523d5ca29884f93dfa1c982177555ac5e13be49df10017054cb71aaba96595", cnbAssetId, testEthereumUSDTAddress, "100")
+	testEthereumObserverHolderDeposit(ctx, require, node, cnbAssetId, testEthereumUSDTAddress, "100")
 
 	txHash := testEthereumProposeRecoveryTransaction(ctx, require, node, cnbBondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e")
 	id := uuid.Must(uuid.NewV4()).String()
@@ -186,7 +186,7 @@ func TestEthereumKeeperCloseAccountWithHolderObserver(t *testing.T) {
 	require := require.New(t)
 	ctx, node, db, _, _ := testEthereumPrepare(require)
 	for i := 0; i < 10; i++ {
-		testEthereumUpdateNetworkStatus(ctx, require, node, 52430860, "55877f07c696cbf6e75174d7e8c2313d62aa665101be2c53dd1bd5f3a85507b1")
+		testUpdateEthereumNetworkTip(ctx, require, node)
 	}
 
 	holder := testEthereumPublicKey(testEthereumKeyHolder)
@@ -197,7 +197,7 @@ func TestEthereumKeeperCloseAccountWithHolderObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "ca6324635b0c87409e9d8488e7f6bcc1fd8224c276a3788b1a8c56ddb4e20f07", common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
 
 	cnbAssetId := ethereum.GenerateAssetId(common.SafeChainPolygon, testEthereumUSDTAddress)
 	require.Equal(testEthereumUSDTAssetId, cnbAssetId)
@@ -209,7 +209,7 @@ func TestEthereumKeeperCloseAccountWithHolderObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	testEthereumObserverHolderDeposit(ctx, require, node, "55523d5ca29884f93dfa1c982177555ac5e13be49df10017054cb71aaba96595", cnbAssetId, testEthereumUSDTAddress, "100")
+	testEthereumObserverHolderDeposit(ctx, require, node, cnbAssetId, testEthereumUSDTAddress, "100")
 
 	safe, _ := node.store.ReadSafe(ctx, holder)
 	chainId := ethereum.GetEvmChainID(common.SafeChainPolygon)
@@ -266,6 +266,33 @@ func TestEthereumKeeperCloseAccountWithHolderObserver(t *testing.T) {
 }
 
 func testEthereumPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	ctx, node, db, mpc, signers := testEthereumPrepareKeys(require)
+	observer := testEthereumPublicKey(testEthereumKeyObserver)
+	rid, gs := testEthereumProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSAEthereum)
+	testEthereumApproveAccount(ctx, require, node, rid, gs, signers, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSAEthereum)
+	for i := 0; i < 10; i++ {
+		testUpdateEthereumNetworkTip(ctx, require, node)
+	}
+
+	holder := testEthereumPublicKey(testEthereumKeyHolder)
+	safe, _ := node.store.ReadSafe(ctx, holder)
+	require.Equal(int64(1), safe.Nonce)
+	return ctx, node, db, mpc, signers
+}
+
+// testEthereumPrepareBond writes the bond output of the asset to pay for the
+// requests of the test safe holder
+func testEthereumPrepareBond(ctx context.Context, require *require.Assertions, node *Node, db *mtg.SQLite3Store, bondId string) {
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(100000000000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{UnifiedOutput: *output})
+}
+
+// testEthereumPrepareKeys prepares the signer and observer keys and the
+// operation params, but no safe is proposed yet
+func testEthereumPrepareKeys(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
 	logger.SetLevel(logger.INFO)
 	ctx, signers, _ := signer.TestPrepare(require)
 	mpc, cc := signer.TestCMPPrepareKeys(ctx, require, signers, common.CurveSecp256k1ECDSAEthereum)
@@ -326,17 +353,6 @@ func testEthereumPrepare(require *require.Assertions) (context.Context, *Node, *
 	for i := 0; i < 10; i++ {
 		testEthereumUpdateAccountPrice(ctx, require, node)
 	}
-	rid, gs := testEthereumProposeAccount(ctx, require, node, mpc, observer)
-	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSAEthereum)
-	testEthereumApproveAccount(ctx, require, node, rid, gs, signers, mpc, observer)
-	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSAEthereum)
-	for i := 0; i < 10; i++ {
-		testEthereumUpdateNetworkStatus(ctx, require, node, 52430860, "55877f07c696cbf6e75174d7e8c2313d62aa665101be2c53dd1bd5f3a85507b1")
-	}
-
-	holder := testEthereumPublicKey(testEthereumKeyHolder)
-	safe, _ := node.store.ReadSafe(ctx, holder)
-	require.Equal(int64(1), safe.Nonce)
 	return ctx, node, db, mpc, signers
 }
 
@@ -607,35 +623,36 @@ func testEthereumApproveAccount(ctx context.Context, require *require.Assertions
 	bondId := testDeployBondContract(ctx, require, node, testEthereumSafeAddress, common.SafePolygonChainId)
 	require.Equal(testEthereumBondAssetId, bondId)
 
-	owners, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
+	owners, _ := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
+	safeAddress := ethereum.GetSafeAccountAddress(owners, 2)
+	require.Equal(testEthereumSafeAddress, safeAddress.Hex())
+	testEthereumDeploySafe(require, safeAddress.Hex(), safe.Timelock)
 	rpc, _ := node.ethereumParams(safe.Chain)
-	raw, err = hex.DecodeString(tx.RawTransaction)
-	require.Nil(err)
-	t, err = ethereum.UnmarshalSafeTransaction(raw)
+	isGuarded, isDeployed, err := ethereum.CheckSafeAccountDeployed(rpc, safeAddress.Hex())
 	require.Nil(err)
-	var index int64
-	for i, pub := range pubs {
-		if pub == safe.Observer {
-			index = int64(i)
-		}
-	}
-	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
-	safeAddress, err := ethereum.GetOrDeploySafeAccount(ctx, rpc, os.Getenv("MVM_DEPLOYER"), chainId, owners, 2, int64(safe.Timelock/time.Hour), index, t)
-	require.Nil(err)
-	require.Equal(testEthereumSafeAddress, safeAddress.Hex())
+	require.True(isGuarded)
+	require.True(isDeployed)
+}
+
+func testEthereumObserverHolderDeposit(ctx context.Context, require *require.Assertions, node *Node, assetId, assetAddress, balance string) {
+	holder := testPublicKey(testEthereumKeyHolder)
+	testEthereumObserverSafeDeposit(ctx, require, node, holder, testEthereumSafeAddress, assetId, assetAddress, balance)
+}
+
+func testEthereumObserverSafeDeposit(ctx context.Context, require *require.Assertions, node *Node, holder, safeAddress, assetId, assetAddress, balance string) {
+	sender := ethereumAddressFromPriv(testEthereumKeyDummyHolder)
+	testEthereumObserverSafeDepositFrom(ctx, require, node, sender, holder, safeAddress, assetId, assetAddress, balance)
 }
 
-func testEthereumObserverHolderDeposit(ctx context.Context, require *require.Assertions, node *Node, txHash, assetId, assetAddress, balance string) {
+func testEthereumObserverSafeDepositFrom(ctx context.Context, require *require.Assertions, node *Node, sender, holder, safeAddress, assetId, assetAddress, balance string) string {
 	id := uuid.Must(uuid.NewV4()).String()
 	amt, err := decimal.NewFromString(balance)
 	require.Nil(err)
-	b, err := hex.DecodeString(txHash)
+	txHash := testFundEthereumAddressFrom(ctx, require, node, sender, safeAddress, assetAddress, amt.BigInt())
+	b, err := hex.DecodeString(txHash[2:])
 	require.Nil(err)
 
 	rpc, _ := node.ethereumParams(common.SafeChainPolygon)
-	if !strings.HasPrefix(txHash, "0x") {
-		txHash = "0x" + txHash
-	}
 	etx, err := ethereum.RPCGetTransactionByHash(rpc, txHash)
 	require.Nil(err)
 	index := 0
@@ -645,7 +662,7 @@ func testEthereumObserverHolderDeposit(ctx context.Context, require *require.Ass
 		require.Nil(err)
 		transfers, _ := ethereum.LoopCalls(common.SafeChainPolygon, common.SafePolygonChainId, txHash, traces, 0)
 		for _, t := range transfers {
-			if t.TokenAddress == ethereum.EthereumEmptyAddress && t.Receiver == testEthereumSafeAddress {
+			if t.TokenAddress == ethereum.EthereumEmptyAddress && t.Receiver == safeAddress {
 				index = int(t.Index)
 			}
 		}
@@ -653,7 +670,7 @@ func testEthereumObserverHolderDeposit(ctx context.Context, require *require.Ass
 		transfers, err := ethereum.GetERC20TransferLogFromBlock(ctx, rpc, common.SafeChainPolygon, int64(etx.BlockHeight))
 		require.Nil(err)
 		for _, t := range transfers {
-			if t.TokenAddress == assetAddress && t.Receiver == testEthereumSafeAddress {
+			if t.TokenAddress == assetAddress && t.Receiver == safeAddress {
 				index = int(t.Index)
 			}
 		}
@@ -665,17 +682,72 @@ func testEthereumObserverHolderDeposit(ctx context.Context, require *require.Ass
 	extra = binary.BigEndian.AppendUint64(extra, uint64(index))
 	extra = append(extra, amt.BigInt().Bytes()...)
 
-	bondId := testDeployBondContract(ctx, require, node, testEthereumSafeAddress, assetId)
+	bondId := testDeployBondContract(ctx, require, node, safeAddress, assetId)
 
-	holder := testPublicKey(testEthereumKeyHolder)
 	out := testBuildObserverRequest(node, id, holder, common.ActionObserverHolderDeposit, extra, common.CurveSecp256k1ECDSAPolygon)
 	testStep(ctx, require, node, out)
 
 	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, assetId, holder)
 	require.Equal(bondId, safeAssetId)
-	safeBalance, err := node.store.ReadEthereumBalance(ctx, testEthereumSafeAddress, assetId, safeAssetId)
+	safeBalance, err := node.store.ReadEthereumBalance(ctx, safeAddress, assetId, safeAssetId)
 	require.Nil(err)
 	require.Equal(balance, safeBalance.BigBalance().String())
+	return id
+}
+
+// testUpdateEthereumNetworkTip sends the latest block of the test polygon chain
+func testUpdateEthereumNetworkTip(ctx context.Context, require *require.Assertions, node *Node) {
+	tip := testNetwork.polygon.Tip()
+	testEthereumUpdateNetworkStatus(ctx, require, node, int(tip.Number), tip.Hash.Hex()[2:])
+}
+
+// testFundEthereumSafe deposits the native or ERC20 asset to the test safe,
+// and finalizes the deposit in the test polygon chain
+func testFundEthereumSafe(ctx context.Context, require *require.Assertions, node *Node, assetAddress string, amount *big.Int) string {
+	return testFundEthereumAddress(ctx, require, node, testEthereumSafeAddress, assetAddress, amount)
+}
+
+func testFundEthereumAddress(ctx context.Context, require *require.Assertions, node *Node, receiver, assetAddress string, amount *big.Int) string {
+	sender := ethereumAddressFromPriv(testEthereumKeyDummyHolder)
+	return testFundEthereumAddressFrom(ctx, require, node, sender, receiver, assetAddress, amount)
+}
+
+func testFundEthereumAddressFrom(ctx context.Context, require *require.Assertions, node *Node, sender, receiver, assetAddress string, amount *big.Int) string {
+	var hash string
+	if assetAddress == ethereum.EthereumEmptyAddress {
+		hash = testNetwork.polygon.Deposit(sender, receiver, amount)
+	} else {
+		hash = testNetwork.polygon.DepositToken(assetAddress, sender, receiver, amount)
+	}
+	testNetwork.polygon.Mine(256)
+	testUpdateEthereumNetworkTip(ctx, require, node)
+	return hash
+}
+
+// testEthereumDeploySafe deploys the guarded safe in the test polygon chain,
+// and the guard reports the last safe transaction long before the timelock
+func testEthereumDeploySafe(require *require.Assertions, address string, timelock time.Duration) {
+	sabi, err := safeabi.GnosisSafeMetaData.GetAbi()
+	require.Nil(err)
+	gabi, err := safeabi.MixinSafeGuardMetaData.GetAbi()
+	require.Nil(err)
+	latest := time.Now().Add(-timelock - 2*time.Hour)
+
+	testNetwork.polygon.HandleCall(address, func(data []byte) ([]byte, error) {
+		method, err := sabi.MethodById(data)
+		if err != nil || method.Name != "getStorageAt" {
+			return nil, fmt.Errorf("safe method %x not supported", data)
+		}
+		guard := gc.LeftPadBytes(gc.HexToAddress(ethereum.EthereumSafeGuardAddress).Bytes(), 32)
+		return method.Outputs.Pack(guard)
+	})
+	testNetwork.polygon.HandleCall(ethereum.EthereumSafeGuardAddress, func(data []byte) ([]byte, error) {
+		method, err := gabi.MethodById(data)
+		if err != nil || method.Name != "safeLastTxTime" {
+			return nil, fmt.Errorf("guard method %x not supported", data)
+		}
+		return method.Outputs.Pack(big.NewInt(latest.Unix()))
+	})
 }
 
 func testEthereumUpdateNetworkStatus(ctx context.Context, require *require.Assertions, node *Node, blockHeight int, blockHash string) {
@@ -717,6 +789,7 @@ func testEthereumUpdateAccountPrice(ctx context.Context, require *require.Assert
 	require.Equal(testAccountPriceAssetId, plan.OperationPriceAsset)
 	require.Equal(fmt.Sprint(testAccountPriceAmount), plan.OperationPriceAmount.String())
 	require.Equal("0.0001", plan.TransactionMinimum.String())
+	require.Equal(time.Duration(0), plan.ProposalExpiry)
 }
 
 func testEthereumSignMessage(require *require.Assertions, priv string, message []byte) []byte {
@@ -0,0 +1,84 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+)
+
+// readSafeProposalExpiry returns the expiry of the proposed transaction by
+// the operation params when proposed, zero means it never expires
+func (node *Node) readSafeProposalExpiry(ctx context.Context, tx *store.Transaction) time.Duration {
+	params, err := node.store.ReadLatestOperationParams(ctx, tx.Chain, tx.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d, %s) => %v %v", tx.Chain, tx.CreatedAt, params, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", tx.Chain, err))
+	}
+	if params == nil {
+		return 0
+	}
+	return params.ProposalExpiry
+}
+
+// The observer expires the proposed transaction never approved before the
+// expiry of the operation params, the inputs or nonce are released and the
+// safe asset is refunded to the safe receivers the same as the revocation.
+func (node *Node) processSafeExpireTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		if req.Action != common.ActionBitcoinSafeExpireTransaction {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if req.Action != common.ActionEthereumSafeExpireTransaction {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 16 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid := uuid.Must(uuid.FromBytes(extra))
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	} else if tx == nil {
+		return node.failRequest(ctx, req, "")
+	} else if tx.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if tx.State != common.RequestStateInitial {
+		return node.failRequest(ctx, req, "")
+	}
+
+	expiry := node.readSafeProposalExpiry(ctx, tx)
+	if expiry == 0 || tx.CreatedAt.Add(expiry).After(req.CreatedAt) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	txRequest, err := node.store.ReadRequest(ctx, rid.String())
+	logger.Printf("store.ReadRequest(%s) => %v %v", rid.String(), txRequest, err)
+	if err != nil || txRequest == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	return node.revokeSafeTransaction(ctx, req, safe, tx, txRequest)
+}
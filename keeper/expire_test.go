@@ -0,0 +1,78 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperExpireTransaction(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+
+	bondId := testPrepareBond(ctx, require, node, db)
+	testPrepareDeposits(ctx, require, node, mpc, 86560, 100000)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	rid := "3e37ea1c-1455-400d-9642-f6bbcd8c744e"
+	transactionHash := testSafeProposeTransaction(ctx, require, node, bondId, rid, "79455d53c7933fdfd097105242c8226a175a0e49ef7a5fcdcc8a3987870a2b90", "70736274ff0100a40200000001f02a271bcaee3267858c41e716fc41255cfe6e9c307c776cf233791a3bc594750000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814909456010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 1)
+
+	// the proposal could not be expired before the deadline
+	for _, delay := range []time.Duration{0, SafeProposalExpiryMaximum - time.Hour} {
+		id := testSafeExpireTransaction(ctx, require, node, holder, rid, delay)
+		testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+		v, err := node.store.ReadProperty(ctx, id)
+		require.Nil(err)
+		require.Equal("", v)
+		tx, err := node.store.ReadTransaction(ctx, transactionHash)
+		require.Nil(err)
+		require.Equal(common.RequestStateInitial, tx.State)
+		pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+		require.Nil(err)
+		require.Len(pendings, 1)
+	}
+
+	id := testSafeExpireTransaction(ctx, require, node, holder, rid, SafeProposalExpiryMaximum+time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	tx, err := node.store.ReadTransaction(ctx, transactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, tx.State)
+	outputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(outputs, 2)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+
+	// the safe asset paid for the proposal is refunded to the safe receivers
+	v, err := node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	var om map[string]any
+	err = json.Unmarshal([]byte(v), &om)
+	require.Nil(err)
+	require.Equal(bondId, om["asset_id"])
+	require.Equal("0.000123", om["amount"])
+	require.Equal([]any{testSafeBondReceiverId}, om["receivers"])
+	require.Equal(hex.EncodeToString([]byte("refund")), om["memo"])
+
+	id = testSafeExpireTransaction(ctx, require, node, holder, rid, SafeProposalExpiryMaximum+2*time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+}
+
+func testSafeExpireTransaction(ctx context.Context, require *require.Assertions, node *Node, holder, rid string, delay time.Duration) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.Must(uuid.FromString(rid)).Bytes()
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeExpireTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
+	out.SequencerCreatedAt = out.SequencerCreatedAt.Add(delay)
+	testStep(ctx, require, node, out)
+	return id
+}
@@ -9,8 +9,10 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
 	"github.com/gofrs/uuid/v5"
 )
@@ -98,20 +100,36 @@ func (node *Node) getActionRole(act byte) byte {
 		return common.RequestRoleObserver
 	case common.ActionMigrateSafeToken:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount, common.ActionSolanaSafeProposeAccount:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+	case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionSolanaSafeApproveAccount:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction, common.ActionSolanaSafeProposeTransaction:
 		return common.RequestRoleHolder
-	case common.ActionBitcoinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction:
+	case common.ActionBitcoinSafeApproveTransaction, common.ActionEthereumSafeApproveTransaction, common.ActionSolanaSafeApproveTransaction:
 		return common.RequestRoleObserver
-	case common.ActionBitcoinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction:
+	case common.ActionBitcoinSafeRevokeTransaction, common.ActionEthereumSafeRevokeTransaction, common.ActionSolanaSafeRevokeTransaction:
 		return common.RequestRoleObserver
 	case common.ActionBitcoinSafeCloseAccount, common.ActionEthereumSafeCloseAccount:
 		return common.RequestRoleObserver
 	case common.ActionEthereumSafeRefundTransaction:
 		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeConsolidateUTXOs:
+		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeUpdatePolicy, common.ActionEthereumSafeUpdatePolicy:
+		return common.RequestRoleHolder
+	case common.ActionBitcoinSafeMigrateAccount, common.ActionEthereumSafeMigrateAccount:
+		return common.RequestRoleHolder
+	case common.ActionBitcoinSafeConvertAsset, common.ActionEthereumSafeConvertAsset:
+		return common.RequestRoleHolder
+	case common.ActionBitcoinSafeRefreshTimelock, common.ActionEthereumSafeRefreshTimelock:
+		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeApproveTransactions, common.ActionEthereumSafeApproveTransactions:
+		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeExpireTransaction, common.ActionEthereumSafeExpireTransaction:
+		return common.RequestRoleObserver
+	case common.ActionBitcoinSafeSignMessage, common.ActionEthereumSafeSignMessage:
+		return common.RequestRoleObserver
 	default:
 		return 0
 	}
@@ -194,6 +212,14 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.processSafeRevokeTransaction(ctx, req)
 	case common.ActionBitcoinSafeCloseAccount:
 		return node.processBitcoinSafeCloseAccount(ctx, req)
+	case common.ActionBitcoinSafeConsolidateUTXOs:
+		return node.processBitcoinSafeConsolidateUTXOs(ctx, req)
+	case common.ActionBitcoinSafeUpdatePolicy:
+		return node.processSafeUpdatePolicy(ctx, req)
+	case common.ActionBitcoinSafeMigrateAccount:
+		return node.processBitcoinSafeMigrateAccount(ctx, req)
+	case common.ActionBitcoinSafeRefreshTimelock:
+		return node.processBitcoinSafeRefreshTimelock(ctx, req)
 	case common.ActionEthereumSafeProposeAccount:
 		return node.processEthereumSafeProposeAccount(ctx, req)
 	case common.ActionEthereumSafeApproveAccount:
@@ -208,6 +234,30 @@ func (node *Node) processRequest(ctx context.Context, req *common.Request) ([]*m
 		return node.processEthereumSafeCloseAccount(ctx, req)
 	case common.ActionEthereumSafeRefundTransaction:
 		return node.processEthereumSafeRefundTransaction(ctx, req)
+	case common.ActionEthereumSafeUpdatePolicy:
+		return node.processSafeUpdatePolicy(ctx, req)
+	case common.ActionEthereumSafeMigrateAccount:
+		return node.processEthereumSafeMigrateAccount(ctx, req)
+	case common.ActionEthereumSafeRefreshTimelock:
+		return node.processEthereumSafeRefreshTimelock(ctx, req)
+	case common.ActionSolanaSafeProposeAccount:
+		return node.processSolanaSafeProposeAccount(ctx, req)
+	case common.ActionSolanaSafeApproveAccount:
+		return node.processSolanaSafeApproveAccount(ctx, req)
+	case common.ActionSolanaSafeProposeTransaction:
+		return node.processSolanaSafeProposeTransaction(ctx, req)
+	case common.ActionSolanaSafeApproveTransaction:
+		return node.processSolanaSafeApproveTransaction(ctx, req)
+	case common.ActionSolanaSafeRevokeTransaction:
+		return node.processSafeRevokeTransaction(ctx, req)
+	case common.ActionBitcoinSafeApproveTransactions, common.ActionEthereumSafeApproveTransactions:
+		return node.processSafeApproveTransactions(ctx, req)
+	case common.ActionBitcoinSafeExpireTransaction, common.ActionEthereumSafeExpireTransaction:
+		return node.processSafeExpireTransaction(ctx, req)
+	case common.ActionBitcoinSafeSignMessage, common.ActionEthereumSafeSignMessage:
+		return node.processSafeSignMessage(ctx, req)
+	case common.ActionBitcoinSafeConvertAsset, common.ActionEthereumSafeConvertAsset:
+		return node.processSafeConvertAsset(ctx, req)
 	default:
 		panic(req.Action)
 	}
@@ -258,6 +308,12 @@ func (node *Node) processKeyAdd(ctx context.Context, req *common.Request) ([]*mt
 		if err != nil {
 			return node.failRequest(ctx, req, "")
 		}
+	case common.CurveEdwards25519Default:
+		err = solana.VerifyHolderKey(req.Holder)
+		logger.Printf("solana.VerifyHolderKey(%s, %x) => %v", req.Holder, chainCode, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
 	default:
 		panic(req.Curve)
 	}
@@ -284,6 +340,9 @@ func (node *Node) processSignerSignatureResponse(ctx context.Context, req *commo
 	if err != nil {
 		panic(fmt.Errorf("store.ReadTransaction(%v) => %s %v", req, old.TransactionHash, err))
 	}
+	if tx == nil {
+		return node.processSafeMessageSignatureResponse(ctx, req, old)
+	}
 	safe, err := node.store.ReadSafe(ctx, tx.Holder)
 	if err != nil {
 		panic(fmt.Errorf("store.ReadSafe(%s) => %v", tx.Holder, err))
@@ -292,10 +351,12 @@ func (node *Node) processSignerSignatureResponse(ctx context.Context, req *commo
 		return node.failRequest(ctx, req, "")
 	}
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.processBitcoinSafeSignatureResponse(ctx, req, safe, tx, old)
 	case common.SafeChainEthereum, common.SafeChainPolygon:
 		return node.processEthereumSafeSignatureResponse(ctx, req, safe, tx, old)
+	case common.SafeChainSolana:
+		return node.processSolanaSafeSignatureResponse(ctx, req, safe, tx, old)
 	default:
 		panic(safe.Chain)
 	}
@@ -345,6 +406,25 @@ func (node *Node) processSafeRevokeTransaction(ctx context.Context, req *common.
 		return node.failRequest(ctx, req, "")
 	}
 
+	return node.revokeSafeTransaction(ctx, req, safe, tx, txRequest)
+}
+
+// revokeSafeTransaction releases the inputs or nonce locked by the proposed
+// transaction, and refunds the safe asset paid by the proposal to receivers
+func (node *Node) revokeSafeTransaction(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, txRequest *common.Request) ([]*mtg.Transaction, string) {
+	// the consolidation and timelock refresh are proposed by observer without
+	// any safe asset paid, and the migration has paid the operation price for
+	// the new safe
+	switch txRequest.Action {
+	case common.ActionBitcoinSafeConsolidateUTXOs, common.ActionBitcoinSafeMigrateAccount, common.ActionEthereumSafeMigrateAccount,
+		common.ActionBitcoinSafeRefreshTimelock, common.ActionEthereumSafeRefreshTimelock:
+		err := node.store.RevokeTransactionWithRequest(ctx, tx, safe, req, nil)
+		if err != nil {
+			panic(err)
+		}
+		return nil, ""
+	}
+
 	entry := node.fetchBondAssetReceiver(ctx, safe.Address, tx.AssetId)
 	safeAssetId := node.getBondAssetId(ctx, entry, tx.AssetId, tx.Holder)
 	bondId := crypto.Sha256Hash([]byte(safeAssetId))
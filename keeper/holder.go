@@ -0,0 +1,96 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+)
+
+func buildSafeHolders(req *common.Request, chain byte, address string, arp *common.AccountProposal) *store.SafeHolders {
+	if len(arp.Holders) < 2 {
+		return nil
+	}
+	return &store.SafeHolders{
+		RequestId: req.Id,
+		Holder:    req.Holder,
+		Chain:     chain,
+		Address:   address,
+		Keys:      arp.Holders,
+		Threshold: arp.HolderThreshold,
+		CreatedAt: req.CreatedAt,
+	}
+}
+
+func checkSafeKeysUnique(holders []string, signer, observer string) bool {
+	var keys []any
+	for _, h := range holders {
+		keys = append(keys, h)
+	}
+	return common.CheckUnique(append(keys, signer, observer)...)
+}
+
+// readSafeHolderKeys returns the holder keys and threshold of the safe or
+// the safe proposal address, the holder is always the first key
+func (node *Node) readSafeHolderKeys(ctx context.Context, address, holder string) ([]string, int) {
+	holders, threshold, err := node.store.ReadSafeHolderKeys(ctx, address, holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeHolderKeys(%s, %s) => %v", address, holder, err))
+	}
+	return holders, threshold
+}
+
+// readSafeApprovalSignature returns the approval signature of the holder in
+// the request extra, the encoded signatures of multiple holder keys are too
+// large for the request, so the extra is the observer storage hash
+func (node *Node) readSafeApprovalSignature(ctx context.Context, holders []string, extra []byte) []byte {
+	if len(holders) == 1 {
+		return extra
+	}
+	if len(extra) != 32 {
+		return nil
+	}
+	var ref crypto.Hash
+	copy(ref[:], extra)
+	return node.readStorageExtraFromObserver(ctx, ref)
+}
+
+// verifySafeApprovalSignatures verifies the account approval signature of the
+// holder, or the holder signatures encoded with common.EncodeHolderSignatures
+// if the safe has multiple holder keys
+func verifySafeApprovalSignatures(holders []string, threshold int, sig []byte, verify func(public string, sig []byte) error) ([][]byte, error) {
+	if len(holders) == 1 {
+		return [][]byte{sig}, verify(holders[0], sig)
+	}
+	sigs, err := common.DecodeHolderSignatures(sig, len(holders))
+	if err != nil {
+		return nil, err
+	}
+	return sigs, common.VerifyHolderSignatures(holders, threshold, sigs, verify)
+}
+
+// verifySafeMessageSignatureWithHolders verifies the message signature of the
+// holder, or the holder signatures encoded with common.EncodeHolderSignatures
+// which must reach the holder threshold of the safe
+func (node *Node) verifySafeMessageSignatureWithHolders(ctx context.Context, safe *store.Safe, msg, sig []byte, verify func(public string, msg, sig []byte) error) error {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	_, err := verifySafeApprovalSignatures(holders, threshold, sig, func(public string, sig []byte) error {
+		return verify(public, msg, sig)
+	})
+	return err
+}
+
+// verifySafeMessageSignatureWithAnyHolder accepts the message signature of any
+// holder key, which is only enough for the operations without funds moved
+func (node *Node) verifySafeMessageSignatureWithAnyHolder(ctx context.Context, safe *store.Safe, msg, sig []byte, verify func(public string, msg, sig []byte) error) error {
+	holders, _ := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	for _, h := range holders {
+		err := verify(h, msg, sig)
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid holders signature %x", sig)
+}
@@ -0,0 +1,433 @@
+package keeper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+var testExtraHolderPrivates = []string{
+	"c36cc79885e3fdb21ec1f5de3803ed9e53bbbb2b2f98bf682798b93f0e3cf49b",
+	"62ffd78027a0714d33e312d92df4165cee041cad15d7cfe007cf1c34cfda2ada",
+}
+
+func TestBitcoinKeeperHolders(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, signers := testPrepareKeys(require)
+
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	privates := append([]string{testBitcoinKeyHolderPrivate}, testExtraHolderPrivates...)
+	holders := testHolderPublicKeys(privates)
+	holder := holders[0]
+
+	rid := uuid.Must(uuid.NewV4()).String()
+	extra := testHoldersRecipient(holders, 2)
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeAccount, testAccountPriceAssetId, extra, price)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeProposeAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.NotEqual(testSafeAddress, wsa.Address)
+	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holders, 2, mpc, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(public.Address, wsa.Address)
+	sp, err := node.store.ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	require.Equal(wsa.Address, sp.Address)
+	keys, threshold, err := node.store.ReadSafeHolderKeys(ctx, sp.Address, holder)
+	require.Nil(err)
+	require.Equal(holders, keys)
+	require.Equal(2, threshold)
+
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid, wsa.Address)
+	sigs := make([][]byte, len(holders))
+	sigs[0] = testBitcoinSignMessage(privates[0], ms)
+	id := testHoldersApproveAccount(ctx, require, node, holder, rid, sigs[0], common.ActionBitcoinSafeApproveAccount, common.CurveSecp256k1ECDSABitcoin)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	ref := testWriteHolderSignatures(ctx, require, node, sigs)
+	id = testHoldersApproveAccount(ctx, require, node, holder, rid, ref[:], common.ActionBitcoinSafeApproveAccount, common.CurveSecp256k1ECDSABitcoin)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Nil(safe)
+
+	sigs[2] = testBitcoinSignMessage(privates[2], ms)
+	ref = testWriteHolderSignatures(ctx, require, node, sigs)
+	id = testHoldersApproveAccount(ctx, require, node, holder, rid, ref[:], common.ActionBitcoinSafeApproveAccount, common.CurveSecp256k1ECDSABitcoin)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	b = testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeApproveAccount)
+	wsa, err = bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.Equal(public.Address, wsa.Address)
+	safe, err = node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(wsa.Address, safe.Address)
+	for i := 0; i < 10; i++ {
+		testUpdateBitcoinNetworkTip(ctx, require, node)
+	}
+
+	// the consolidation authorisation requires the holder threshold as well
+	nonce := uuid.Must(uuid.NewV4()).String()
+	ms = safeSelfSpendMessage("CONSOLIDATE", safe, nonce)
+	out = testBuildObserverRequest(node, nonce, holder, common.ActionBitcoinSafeConsolidateUTXOs, testBitcoinSignMessage(privates[1], ms), common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testCheckRequestState(ctx, require, node, nonce, common.RequestStateFailed)
+	msg := bitcoin.HashMessageForSignature(ms, safe.Chain)
+	sig := testHoldersSignBitcoinMessage(holders, privates[1:2], ms)
+	require.NotNil(node.verifySafeMessageSignatureWithHolders(ctx, safe, msg, sig, bitcoin.VerifySignatureDER))
+	sigs = [][]byte{nil, testBitcoinSignMessage(privates[1], ms), testBitcoinSignMessage(privates[2], ms)}
+	ref = testWriteHolderSignatures(ctx, require, node, sigs)
+	sig = node.readSafeApprovalSignature(ctx, holders, ref[:])
+	require.Equal(testHoldersSignBitcoinMessage(holders, privates[1:], ms), sig)
+	require.Nil(node.verifySafeMessageSignatureWithHolders(ctx, safe, msg, sig, bitcoin.VerifySignatureDER))
+
+	bondId := testDeployBondContract(ctx, require, node, safe.Address, common.SafeBitcoinChainId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+	input := testFundBitcoinAddress(ctx, require, node, safe.Address, 100000)
+	testHoldersBitcoinDeposit(ctx, require, node, holder, input)
+
+	// the timelock refresh spends the safe funds with the holder threshold
+	nonce = uuid.Must(uuid.NewV4()).String()
+	ms = safeSelfSpendMessage("REFRESH", safe, nonce)
+	out = testBuildObserverRequest(node, nonce, holder, common.ActionBitcoinSafeRefreshTimelock, testBitcoinSignMessage(privates[1], ms), common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	testCheckRequestState(ctx, require, node, nonce, common.RequestStateFailed)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Nil(tx)
+
+	rid = uuid.Must(uuid.NewV4()).String()
+	info, err := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainBitcoin, time.Now())
+	require.Nil(err)
+	extra = []byte{0}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testTransactionReceiver)...)
+	out = testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, decimal.NewFromFloat(0.000123))
+	testStep(ctx, require, node, out)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	// the batch signature of a single holder can't approve the transactions,
+	// even with the raw of each transaction signed by the holder threshold
+	id = testBatchApproveTransactions(ctx, require, node, holder, []string{privates[0], privates[2]}, privates[:1], []string{tx.TransactionHash}, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	hpsbt := testHoldersSignBitcoinTransaction(tx.RawTransaction, privates[:1])
+	id = testHoldersApproveBitcoinTransaction(ctx, require, node, holder, tx.RequestId, hpsbt)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	hpsbt = testHoldersSignBitcoinTransaction(tx.RawTransaction, []string{privates[0], privates[2]})
+	id = testHoldersApproveBitcoinTransaction(ctx, require, node, holder, tx.RequestId, hpsbt)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, len(hpsbt.UnsignedTx.TxIn))
+	for _, r := range requests {
+		msg, _ := hex.DecodeString(r.Message)
+		out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
+		op := signer.TestProcessOutput(ctx, require, signers, out, r.RequestId)
+		out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
+		testStep(ctx, require, node, out)
+	}
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, tx.State)
+
+	spsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	require.Equal(len(hpsbt.UnsignedTx.TxIn)*2, spsbt.MergePartialSignatures(hpsbt, holders))
+	path := common.DecodeHexOrPanic(safe.Path)
+	sdk, err := node.deriveBIP32WithPath(ctx, safe.Signer, path)
+	require.Nil(err)
+	odk, err := node.deriveBIP32WithPath(ctx, safe.Observer, path)
+	require.Nil(err)
+	msgTx, err := spsbt.SignedTransactionWithHolders(holders, 2, sdk, odk)
+	require.Nil(err)
+	for idx, pin := range spsbt.Inputs {
+		pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+		engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, idx, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+	}
+
+	input = testFundBitcoinAddress(ctx, require, node, safe.Address, 100000)
+	testHoldersBitcoinDeposit(ctx, require, node, holder, input)
+	rid = uuid.Must(uuid.NewV4()).String()
+	out = testBuildHolderRequest(node, rid, holder, common.ActionBitcoinSafeProposeTransaction, bondId, extra, decimal.NewFromFloat(0.000123))
+	testStep(ctx, require, node, out)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	id = testBatchApproveTransactions(ctx, require, node, holder, []string{privates[0], privates[2]}, []string{privates[1], privates[2]}, []string{tx.TransactionHash}, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStatePending, tx.State)
+}
+
+func TestEthereumKeeperHolders(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, signers := testEthereumPrepareKeys(require)
+
+	observer := testEthereumPublicKey(testEthereumKeyObserver)
+	privates := append([]string{testEthereumKeyHolder}, testExtraHolderPrivates...)
+	holders := testHolderPublicKeys(privates)
+	holder := holders[0]
+
+	rid := uuid.Must(uuid.NewV4()).String()
+	extra := testHoldersRecipient(holders, 2)
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionEthereumSafeProposeAccount, testAccountPriceAssetId, extra, price)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionEthereumSafeProposeAccount)
+	gs, err := ethereum.UnmarshalGnosisSafe(b)
+	require.Nil(err)
+	require.NotEqual(testEthereumSafeAddress, gs.Address)
+	owners, _ := ethereum.GetSortedSafeOwnersWithHolders(holders, mpc, observer)
+	require.Len(owners, 5)
+	addr := ethereum.GetSafeAccountAddress(owners, ethereum.GetSafeThreshold(2))
+	require.Equal(addr.Hex(), gs.Address)
+
+	tx, err := node.store.ReadTransaction(ctx, gs.TxHash)
+	require.Nil(err)
+	st, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	sigs := make([][]byte, len(holders))
+	sigs[0] = testEthereumSignMessage(require, privates[0], st.Message)
+	id := testHoldersApproveAccount(ctx, require, node, holder, rid, sigs[0], common.ActionEthereumSafeApproveAccount, common.CurveSecp256k1ECDSAPolygon)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	ref := testWriteHolderSignatures(ctx, require, node, sigs)
+	id = testHoldersApproveAccount(ctx, require, node, holder, rid, ref[:], common.ActionEthereumSafeApproveAccount, common.CurveSecp256k1ECDSAPolygon)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Nil(safe)
+
+	sigs[2] = testEthereumSignMessage(require, privates[2], st.Message)
+	ref = testWriteHolderSignatures(ctx, require, node, sigs)
+	id = testHoldersApproveAccount(ctx, require, node, holder, rid, ref[:], common.ActionEthereumSafeApproveAccount, common.CurveSecp256k1ECDSAPolygon)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	testHoldersEthereumSign(ctx, require, node, signers, gs.TxHash, mpc)
+	safe, err = node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(gs.Address, safe.Address)
+	require.Equal(common.RequestStateDone, int(safe.State))
+	testEthereumDeploySafe(require, safe.Address, safe.Timelock)
+	for i := 0; i < 10; i++ {
+		testUpdateEthereumNetworkTip(ctx, require, node)
+	}
+
+	bondId := testDeployBondContract(ctx, require, node, safe.Address, common.SafePolygonChainId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(100000000000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{
+		UnifiedOutput: *output,
+	})
+	testEthereumObserverSafeDeposit(ctx, require, node, holder, safe.Address, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+
+	rid = uuid.Must(uuid.NewV4()).String()
+	info, err := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainPolygon, time.Now())
+	require.Nil(err)
+	extra = []byte{0}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testEthereumTransactionReceiver)...)
+	out = testBuildHolderRequest(node, rid, holder, common.ActionEthereumSafeProposeTransaction, bondId, extra, decimal.NewFromFloat(0.0001))
+	testStep(ctx, require, node, out)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	st, err = ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	id = testHoldersApproveEthereumTransaction(ctx, require, node, holder, tx.RequestId, st, pubs, map[string]string{holders[0]: privates[0]})
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+
+	signed := map[string]string{holders[0]: privates[0], holders[2]: privates[2]}
+	id = testHoldersApproveEthereumTransaction(ctx, require, node, holder, tx.RequestId, st, pubs, signed)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	testHoldersEthereumSign(ctx, require, node, signers, tx.TransactionHash, safe.Signer)
+	tx, err = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	st, err = ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	for i, pub := range pubs {
+		switch {
+		case pub == safe.Signer || signed[pub] != "":
+			require.Nil(ethereum.VerifyMessageSignature(pub, st.Message, st.Signatures[i]))
+		default:
+			require.True(i >= len(st.Signatures) || st.Signatures[i] == nil)
+		}
+	}
+	ok, err := node.checkEthereumTransactionSignedByHolders(ctx, safe, st)
+	require.Nil(err)
+	require.True(ok)
+}
+
+func testHolderPublicKeys(privates []string) []string {
+	var holders []string
+	for _, priv := range privates {
+		holders = append(holders, testPublicKey(priv))
+	}
+	return holders
+}
+
+// testHoldersRecipient appends the holder keys section after the request
+// holder to the account proposal extra
+func testHoldersRecipient(holders []string, threshold byte) []byte {
+	extra := append(testRecipient(), common.SafeHoldersFlag, threshold, byte(len(holders)-1))
+	for _, h := range holders[1:] {
+		extra = append(extra, common.DecodeHexOrPanic(h)...)
+	}
+	return extra
+}
+
+// testWriteHolderSignatures writes the encoded holder signatures as the
+// observer storage, which is too large for the account approval request
+func testWriteHolderSignatures(ctx context.Context, require *require.Assertions, node *Node, sigs [][]byte) crypto.Hash {
+	raw := common.EncodeHolderSignatures(sigs)
+	ref := crypto.Sha256Hash(raw)
+	err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	return ref
+}
+
+// testHoldersSignBitcoinMessage signs the message with the privates, and
+// encodes the signatures by the holder index if the safe has multiple holders
+func testHoldersSignBitcoinMessage(holders, privates []string, ms string) []byte {
+	if len(holders) == 1 {
+		return testBitcoinSignMessage(privates[0], ms)
+	}
+	sigs := make([][]byte, len(holders))
+	for _, priv := range privates {
+		i := slices.Index(holders, testPublicKey(priv))
+		sigs[i] = testBitcoinSignMessage(priv, ms)
+	}
+	return common.EncodeHolderSignatures(sigs)
+}
+
+func testHoldersApproveAccount(ctx context.Context, require *require.Assertions, node *Node, holder, rid string, sig []byte, action, crv byte) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.FromStringOrNil(rid).Bytes()
+	extra = append(extra, sig...)
+	out := testBuildObserverRequest(node, id, holder, action, extra, crv)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+func testHoldersBitcoinDeposit(ctx context.Context, require *require.Assertions, node *Node, holder string, input *bitcoin.Input) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	hash, _ := crypto.HashFromString(input.TransactionHash)
+	extra := []byte{common.SafeChainBitcoin}
+	extra = append(extra, uuid.Must(uuid.FromString(common.SafeBitcoinChainId)).Bytes()...)
+	extra = append(extra, hash[:]...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(input.Index))
+	extra = append(extra, big.NewInt(input.Satoshi).Bytes()...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionObserverHolderDeposit, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+
+	utxos, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(utxos, 1)
+	require.Equal(input.TransactionHash, utxos[0].TransactionHash)
+	require.Equal(input.Satoshi, utxos[0].Satoshi)
+	return id
+}
+
+func testHoldersSignBitcoinTransaction(raw string, privates []string) *bitcoin.PartiallySignedTransaction {
+	psTx, _ := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	for idx := range psTx.UnsignedTx.TxIn {
+		hash := psTx.SigHash(idx)
+		psTx.Inputs[idx].PartialSigs = nil
+		for _, priv := range privates {
+			key, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(priv))
+			psTx.Inputs[idx].PartialSigs = append(psTx.Inputs[idx].PartialSigs, &psbt.PartialSig{
+				PubKey:    key.PubKey().SerializeCompressed(),
+				Signature: ecdsa.Sign(key, hash).Serialize(),
+			})
+		}
+	}
+	return psTx
+}
+
+func testHoldersApproveBitcoinTransaction(ctx context.Context, require *require.Assertions, node *Node, holder, rid string, psTx *bitcoin.PartiallySignedTransaction) string {
+	raw := psTx.Marshal()
+	ref := crypto.Sha256Hash(raw)
+	err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.Must(uuid.FromString(rid)).Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeApproveTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+func testHoldersApproveEthereumTransaction(ctx context.Context, require *require.Assertions, node *Node, holder, rid string, st *ethereum.SafeTransaction, pubs []string, signed map[string]string) string {
+	st.Signatures = nil
+	for i, pub := range pubs {
+		if priv := signed[pub]; priv != "" {
+			st.SetSignature(i, testEthereumSignMessage(require, priv, st.Message))
+		}
+	}
+	raw := st.Marshal()
+	ref := crypto.Sha256Hash(raw)
+	err := node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.Must(uuid.FromString(rid)).Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionEthereumSafeApproveTransaction, extra, common.CurveSecp256k1ECDSAPolygon)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+// testHoldersEthereumSign sends the only signature request of the transaction
+// to the signers, and the signature response back to the keeper
+func testHoldersEthereumSign(ctx context.Context, require *require.Assertions, node *Node, signers []*signer.Node, txHash, public string) {
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, txHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+	msg, _ := hex.DecodeString(requests[0].Message)
+	out := testBuildSignerOutput(node, requests[0].RequestId, public, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSAEthereum)
+	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
+	out = testBuildSignerOutput(node, requests[0].RequestId, public, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSAEthereum)
+	testStep(ctx, require, node, out)
+	tx, err := node.store.ReadTransaction(ctx, txHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, tx.State)
+}
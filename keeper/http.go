@@ -0,0 +1,304 @@
+package keeper
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/dimfeld/httptreemux/v5"
+)
+
+// the keeper HTTP API is read only, so any keeper node could be queried to
+// cross check the answers from the observer
+func (node *Node) StartHTTP(version, listen string) {
+	handler := node.HTTPHandler(version)
+	err := http.ListenAndServe(listen, handler)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) HTTPHandler(version string) http.Handler {
+	router := httptreemux.New()
+	router.PanicHandler = common.HandlePanic
+	router.NotFoundHandler = common.HandleNotFound
+
+	router.GET("/", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		node.httpIndex(w, r, version)
+	})
+	router.GET("/requests", node.httpListRequests)
+	router.GET("/requests/:id", node.httpGetRequest)
+	router.GET("/safes/:id", node.httpGetSafe)
+	router.GET("/safes/:id/balances", node.httpGetSafeBalances)
+	router.GET("/actions/:id", node.httpGetActionResult)
+	router.GET("/chains/:chain/infos", node.httpListNetworkInfos)
+	router.GET("/chains/:chain/params", node.httpListOperationParams)
+	return common.HandleCORS(router)
+}
+
+func (node *Node) httpIndex(w http.ResponseWriter, r *http.Request, version string) {
+	req, err := node.store.ReadLatestRequest(r.Context())
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	var sequence uint64
+	if req != nil {
+		sequence = req.Sequence
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"version":  version,
+		"app_id":   node.conf.AppId,
+		"node_id":  node.conf.MTG.App.AppId,
+		"sequence": sequence,
+	})
+}
+
+func (node *Node) httpListRequests(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var state int
+	if s := r.URL.Query().Get("state"); s != "" {
+		state = slices.IndexFunc([]int{
+			common.RequestStateInitial,
+			common.RequestStatePending,
+			common.RequestStateDone,
+			common.RequestStateFailed,
+		}, func(i int) bool { return common.StateName(i) == s }) + 1
+		if state == 0 {
+			common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "state"})
+			return
+		}
+	}
+	offset, _ := strconv.ParseUint(r.URL.Query().Get("offset"), 10, 64)
+	requests, err := node.store.ListRequests(r.Context(), state, offset)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]map[string]any, 0)
+	for _, req := range requests {
+		view = append(view, viewRequest(req))
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func (node *Node) httpGetRequest(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	req, err := node.store.ReadRequest(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if req == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "request"})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, viewRequest(req))
+}
+
+func (node *Node) httpGetSafe(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	safe, err := node.readSafeByHolderOrAddress(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if safe == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"holder":        safe.Holder,
+		"chain":         safe.Chain,
+		"signer":        safe.Signer,
+		"observer":      safe.Observer,
+		"timelock":      int64(safe.Timelock / time.Hour),
+		"path":          safe.Path,
+		"address":       safe.Address,
+		"receivers":     safe.Receivers,
+		"threshold":     safe.Threshold,
+		"request_id":    safe.RequestId,
+		"nonce":         safe.Nonce,
+		"state":         common.StateName(int(safe.State)),
+		"safe_asset_id": safe.SafeAssetId,
+		"created_at":    safe.CreatedAt,
+		"updated_at":    safe.UpdatedAt,
+	})
+}
+
+func (node *Node) httpGetSafeBalances(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	safe, err := node.readSafeByHolderOrAddress(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if safe == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+
+	view := make([]map[string]any, 0)
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		for _, state := range []int{common.RequestStateInitial, common.RequestStatePending} {
+			var utxos []*bitcoin.Input
+			switch state {
+			case common.RequestStateInitial:
+				utxos, err = node.store.ListAllBitcoinUTXOsForHolder(r.Context(), safe.Holder)
+			case common.RequestStatePending:
+				utxos, err = node.store.ListPendingBitcoinUTXOsForHolder(r.Context(), safe.Holder)
+			}
+			if err != nil {
+				common.RenderError(w, r, err)
+				return
+			}
+			for _, u := range utxos {
+				view = append(view, map[string]any{
+					"asset_id":         common.SafeChainAssetId(safe.Chain),
+					"transaction_hash": u.TransactionHash,
+					"index":            u.Index,
+					"amount":           u.Satoshi,
+					"state":            common.StateName(state),
+				})
+			}
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon, common.SafeChainSolana:
+		balances, err := node.store.ReadAllEthereumTokenBalances(r.Context(), safe.Address)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		for _, b := range balances {
+			view = append(view, map[string]any{
+				"asset_id":       b.AssetId,
+				"asset_address":  b.AssetAddress,
+				"safe_asset_id":  b.SafeAssetId,
+				"amount":         b.BigBalance().String(),
+				"latest_tx_hash": b.LatestTxHash,
+				"updated_at":     b.UpdatedAt,
+			})
+		}
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func (node *Node) httpGetActionResult(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	ar, err := node.store.ReadActionResultByOutputId(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if ar == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "action"})
+		return
+	}
+	txs := make([]map[string]any, 0)
+	for _, t := range ar.Transactions {
+		txs = append(txs, map[string]any{
+			"trace_id":        t.TraceId,
+			"opponent_app_id": t.OpponentAppId,
+			"asset_id":        t.AssetId,
+			"receivers":       t.Receivers,
+			"threshold":       t.Threshold,
+			"amount":          t.Amount,
+			"memo":            t.Memo,
+		})
+	}
+	common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		"output_id":    ar.ActionId,
+		"request_id":   ar.RequestId,
+		"compaction":   ar.Compaction,
+		"transactions": txs,
+		"created_at":   ar.CreatedAt,
+	})
+}
+
+func (node *Node) httpListNetworkInfos(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	chain, offset, valid := parseChainAndOffset(r, params)
+	if !valid {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "chain"})
+		return
+	}
+	infos, err := node.store.ListNetworkInfos(r.Context(), chain, offset)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]map[string]any, 0)
+	for _, info := range infos {
+		view = append(view, map[string]any{
+			"id":         info.RequestId,
+			"chain":      info.Chain,
+			"fee":        info.Fee,
+			"height":     info.Height,
+			"hash":       info.Hash,
+			"created_at": info.CreatedAt,
+		})
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func (node *Node) httpListOperationParams(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	chain, offset, valid := parseChainAndOffset(r, params)
+	if !valid {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "chain"})
+		return
+	}
+	ops, err := node.store.ListOperationParams(r.Context(), chain, offset)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]map[string]any, 0)
+	for _, p := range ops {
+		view = append(view, map[string]any{
+			"id":                  p.RequestId,
+			"chain":               p.Chain,
+			"price_asset_id":      p.OperationPriceAsset,
+			"price_amount":        p.OperationPriceAmount.String(),
+			"transaction_minimum": p.TransactionMinimum.String(),
+			"proposal_expiry":     int64(p.ProposalExpiry / time.Second),
+			"created_at":          p.CreatedAt,
+		})
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+func (node *Node) readSafeByHolderOrAddress(ctx context.Context, id string) (*store.Safe, error) {
+	safe, err := node.store.ReadSafe(ctx, id)
+	if err != nil || safe != nil {
+		return safe, err
+	}
+	return node.store.ReadSafeByAddress(ctx, id)
+}
+
+func parseChainAndOffset(r *http.Request, params map[string]string) (byte, time.Time, bool) {
+	chain, _ := strconv.ParseInt(params["chain"], 10, 64)
+	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin, common.SafeChainEthereum, common.SafeChainPolygon, common.SafeChainSolana:
+		return byte(chain), time.Unix(0, offset), true
+	default:
+		return 0, time.Time{}, false
+	}
+}
+
+func viewRequest(req *common.Request) map[string]any {
+	return map[string]any{
+		"id":          req.Id,
+		"mixin_hash":  req.MixinHash.String(),
+		"mixin_index": req.MixinIndex,
+		"asset_id":    req.AssetId,
+		"amount":      req.Amount.String(),
+		"role":        req.Role,
+		"action":      req.Action,
+		"curve":       req.Curve,
+		"holder":      req.Holder,
+		"extra":       req.ExtraHEX,
+		"state":       common.StateName(int(req.State)),
+		"sequence":    req.Sequence,
+		"created_at":  req.CreatedAt,
+	}
+}
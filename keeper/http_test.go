@@ -0,0 +1,128 @@
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeeperHTTP(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kd, err := OpenSQLite3Store(t.TempDir() + "/safe.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	conf := &Configuration{AppId: "ac495e24-72a5-3c53-aa33-8f90cf007b9d", MTG: &mtg.Configuration{}}
+	node := &Node{conf: conf, store: kd}
+
+	var outputs []string
+	for i := range 3 {
+		req := &common.Request{
+			Id:         uuid.Must(uuid.NewV4()).String(),
+			MixinIndex: i,
+			AssetId:    testAccountPriceAssetId,
+			Amount:     decimal.NewFromInt(1),
+			Role:       common.RequestRoleObserver,
+			Action:     common.ActionObserverUpdateNetworkStatus,
+			Curve:      common.CurveSecp256k1ECDSABitcoin,
+			State:      common.RequestStateInitial,
+			CreatedAt:  time.Now().UTC(),
+			Sequence:   uint64(100 + i),
+			Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+		}
+		err = kd.WriteRequestIfNotExist(ctx, req)
+		require.Nil(err)
+		if i == 2 {
+			continue
+		}
+		info := &store.NetworkInfo{
+			RequestId: req.Id,
+			Chain:     common.SafeChainBitcoin,
+			Fee:       10,
+			Height:    uint64(793574 + i),
+			Hash:      "00000000000000000002a4f5cd899ea457314c808897c5c5f1f1cd6ffe2b266a",
+			CreatedAt: req.CreatedAt,
+		}
+		err = kd.WriteNetworkInfoFromRequest(ctx, info, req)
+		require.Nil(err)
+		outputs = append(outputs, req.Output.OutputId)
+	}
+	handler := node.HTTPHandler("test")
+
+	var index map[string]any
+	testKeeperHTTPGet(require, handler, "/", http.StatusOK, &index)
+	require.Equal(conf.AppId, index["app_id"])
+	require.Equal(float64(102), index["sequence"])
+
+	var requests []map[string]any
+	testKeeperHTTPGet(require, handler, "/requests", http.StatusOK, &requests)
+	require.Len(requests, 3)
+	testKeeperHTTPGet(require, handler, "/requests?state=done", http.StatusOK, &requests)
+	require.Len(requests, 2)
+	testKeeperHTTPGet(require, handler, "/requests?state=initial&offset=101", http.StatusOK, &requests)
+	require.Len(requests, 1)
+	require.Equal(float64(102), requests[0]["sequence"])
+	testKeeperHTTPGet(require, handler, "/requests?state=unknown", http.StatusBadRequest, nil)
+	id := requests[0]["id"].(string)
+	var req map[string]any
+	testKeeperHTTPGet(require, handler, "/requests/"+id, http.StatusOK, &req)
+	require.Equal("initial", req["state"])
+	require.Equal(float64(common.ActionObserverUpdateNetworkStatus), req["action"])
+	testKeeperHTTPGet(require, handler, "/requests/unknown", http.StatusNotFound, nil)
+
+	var result map[string]any
+	testKeeperHTTPGet(require, handler, "/actions/"+outputs[1], http.StatusOK, &result)
+	require.Equal(outputs[1], result["output_id"])
+	require.Len(result["transactions"], 0)
+	testKeeperHTTPGet(require, handler, "/actions/unknown", http.StatusNotFound, nil)
+
+	var infos, params []map[string]any
+	testKeeperHTTPGet(require, handler, "/chains/1/infos", http.StatusOK, &infos)
+	require.Len(infos, 2)
+	require.Equal(float64(793575), infos[1]["height"])
+	testKeeperHTTPGet(require, handler, "/chains/1/params", http.StatusOK, &params)
+	require.Len(params, 0)
+	testKeeperHTTPGet(require, handler, "/chains/2/infos", http.StatusOK, &infos)
+	require.Len(infos, 0)
+	testKeeperHTTPGet(require, handler, "/chains/9/infos", http.StatusBadRequest, nil)
+	testKeeperHTTPGet(require, handler, "/safes/unknown", http.StatusNotFound, nil)
+}
+
+func testKeeperHTTP(require *require.Assertions, node *Node, holder string, utxos int) {
+	handler := node.HTTPHandler("test")
+
+	var safe map[string]any
+	testKeeperHTTPGet(require, handler, "/safes/"+holder, http.StatusOK, &safe)
+	require.Equal(holder, safe["holder"])
+	require.Equal("done", safe["state"])
+	testKeeperHTTPGet(require, handler, "/safes/"+safe["address"].(string), http.StatusOK, &safe)
+	require.Equal(holder, safe["holder"])
+	testKeeperHTTPGet(require, handler, "/safes/unknown", http.StatusNotFound, nil)
+
+	var balances []map[string]any
+	testKeeperHTTPGet(require, handler, "/safes/"+holder+"/balances", http.StatusOK, &balances)
+	require.Len(balances, utxos)
+	require.Equal(common.SafeBitcoinChainId, balances[0]["asset_id"])
+}
+
+func testKeeperHTTPGet(require *require.Assertions, handler http.Handler, path string, code int, data any) {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(code, w.Code, path)
+	if data != nil {
+		err := json.Unmarshal(w.Body.Bytes(), data)
+		require.Nil(err)
+	}
+}
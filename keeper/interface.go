@@ -10,6 +10,7 @@ type Configuration struct {
 	SignerAppId                 string             `toml:"signer-app-id"`
 	StoreDir                    string             `toml:"store-dir"`
 	MonitorConversaionId        string             `toml:"monitor-conversation-id"`
+	HTTPListen                  string             `toml:"http-listen"`
 	SharedKey                   string             `toml:"shared-key"`
 	SignerPublicKey             string             `toml:"signer-public-key"`
 	AssetId                     string             `toml:"asset-id"`
@@ -20,11 +21,14 @@ type Configuration struct {
 	MixinRPC                    string             `toml:"mixin-rpc"`
 	BitcoinRPC                  string             `toml:"bitcoin-rpc"`
 	LitecoinRPC                 string             `toml:"litecoin-rpc"`
+	DogecoinRPC                 string             `toml:"dogecoin-rpc"`
 	EthereumRPC                 string             `toml:"ethereum-rpc"`
 	PolygonRPC                  string             `toml:"polygon-rpc"`
 	PolygonFactoryAddress       string             `toml:"polygon-factory-address"`
 	PolygonObserverDepositEntry string             `toml:"polygon-observer-deposit-entry"`
 	PolygonKeeperDepositEntry   string             `toml:"polygon-keeper-deposit-entry"`
+	SolanaRPC                   string             `toml:"solana-rpc"`
+	SolanaProgram               string             `toml:"solana-program"`
 	MTG                         *mtg.Configuration `toml:"mtg"`
 }
 
@@ -14,10 +14,15 @@ import (
 	"testing"
 	"time"
 
+	mc "github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
+	btcrpc "github.com/MixinNetwork/safe/apps/bitcoin/rpctest"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	evmrpc "github.com/MixinNetwork/safe/apps/ethereum/rpctest"
+	mixinrpc "github.com/MixinNetwork/safe/apps/mixin/rpctest"
+	solrpc "github.com/MixinNetwork/safe/apps/solana/rpctest"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/signer"
@@ -58,6 +63,15 @@ const (
 
 var sequence uint64 = 5000000
 
+// testNetwork is the in-memory chains and Mixin asset API of the test node,
+// they are started again for each node built
+var testNetwork struct {
+	bitcoin *btcrpc.Server
+	polygon *evmrpc.Server
+	solana  *solrpc.Server
+	mixin   *mixinrpc.Server
+}
+
 func TestBitcoinKeeper(t *testing.T) {
 	require := require.New(t)
 	ctx, node, db, mpc, signers := testPrepare(require)
@@ -70,15 +84,9 @@ func TestBitcoinKeeper(t *testing.T) {
 	node.ProcessOutput(ctx, &mtg.Action{
 		UnifiedOutput: *output,
 	})
-	input := &bitcoin.Input{
-		TransactionHash: "40e228e5a3cba99fd3fc5350a00bfeef8bafb760e26919ec74bca67776c90427",
-		Index:           0, Satoshi: 86560,
-	}
+	input := testFundBitcoinSafe(ctx, require, node, 86560)
 	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
-	input = &bitcoin.Input{
-		TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
-		Index:           0, Satoshi: 100000,
-	}
+	input = testFundBitcoinSafe(ctx, require, node, 100000)
 	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 2)
 
 	holder := testPublicKey(testBitcoinKeyHolderPrivate)
@@ -88,14 +96,15 @@ func TestBitcoinKeeper(t *testing.T) {
 	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
 	require.Len(pendings, 0)
+	testKeeperHTTP(require, node, holder, 2)
 
-	transactionHash := testSafeProposeTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e", "18d6e8a1bcce1b1dddbfed5826cde933dc55ba65a733fc5a2198f113c86e31d0", "70736274ff0100cd02000000022704c97677a6bc74ec1969e260b7af8beffe0ba05053fcd39fa9cba3e528e2400000000000ffffffff9451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c850000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b981490b4a8020000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012b2052010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b292689352870001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
+	transactionHash := testSafeProposeTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e", "79455d53c7933fdfd097105242c8226a175a0e49ef7a5fcdcc8a3987870a2b90", "70736274ff0100a40200000001f02a271bcaee3267858c41e716fc41255cfe6e9c307c776cf233791a3bc594750000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814909456010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(outputs, 0)
+	require.Len(outputs, 1)
 	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(pendings, 2)
+	require.Len(pendings, 1)
 	testSafeRevokeTransaction(ctx, require, node, transactionHash, false)
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
@@ -104,13 +113,13 @@ func TestBitcoinKeeper(t *testing.T) {
 	require.Nil(err)
 	require.Len(pendings, 0)
 
-	transactionHash = testSafeProposeTransaction(ctx, require, node, bondId, "8bf052c1-41f4-4547-8091-bcf0c85f09a6", "29091ae146a7ab2e547d0e3fddd21f65a8de4314d66ffc2c4c11f3fa444ea706", "70736274ff0100cd02000000022704c97677a6bc74ec1969e260b7af8beffe0ba05053fcd39fa9cba3e528e2400000000000ffffffff9451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c850000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b981490b4a8020000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a108bf052c141f445478091bcf0c85f09a6000000000001012b2052010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b292689352870001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
+	transactionHash = testSafeProposeTransaction(ctx, require, node, bondId, "8bf052c1-41f4-4547-8091-bcf0c85f09a6", "81172baba7e1aa6038ec2c3f5f444ffa7a4d8f62fd0e8118eb58a43b23898499", "70736274ff0100a40200000001f02a271bcaee3267858c41e716fc41255cfe6e9c307c776cf233791a3bc594750000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814909456010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a108bf052c141f445478091bcf0c85f09a6000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(outputs, 0)
+	require.Len(outputs, 1)
 	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(pendings, 2)
+	require.Len(pendings, 1)
 	testSafeRevokeTransaction(ctx, require, node, transactionHash, true)
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
@@ -119,16 +128,17 @@ func TestBitcoinKeeper(t *testing.T) {
 	require.Nil(err)
 	require.Len(pendings, 0)
 
-	transactionHash = testSafeProposeTransaction(ctx, require, node, bondId, "b0a22078-0a86-459d-93f4-a1aadbf2b9b7", "5f489b710d495808d7693f0d1b62b6af05d0af69b52980d3e4263c66dde9e676", "70736274ff0100cd02000000022704c97677a6bc74ec1969e260b7af8beffe0ba05053fcd39fa9cba3e528e2400000000000ffffffff9451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c850000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b981490b4a8020000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a10b0a220780a86459d93f4a1aadbf2b9b7000000000001012b2052010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b292689352870001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
+	transactionHash = testSafeProposeTransaction(ctx, require, node, bondId, "b0a22078-0a86-459d-93f4-a1aadbf2b9b7", "b2073523c3435a85611a5c8ed81ec2535a067290d61affebc5f61101fe4af082", "70736274ff0100a40200000001f02a271bcaee3267858c41e716fc41255cfe6e9c307c776cf233791a3bc594750000000000ffffffff030c30000000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814909456010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f88730000000000000000126a10b0a220780a86459d93f4a1aadbf2b9b7000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b2926893528700000000")
 	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(outputs, 0)
+	require.Len(outputs, 1)
 	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(pendings, 2)
+	require.Len(pendings, 1)
 	signedRaw := testSafeApproveTransaction(ctx, require, node, transactionHash, signers)
+	outputs, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
-	require.Len(outputs, 0)
+	require.Len(outputs, 1)
 	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
 	require.Nil(err)
 	require.Len(pendings, 0)
@@ -137,6 +147,69 @@ func TestBitcoinKeeper(t *testing.T) {
 	testAccountantSpentTransaction(ctx, require, signedRaw, testHolderSigner)
 }
 
+func TestBitcoinKeeperConsolidateUTXOs(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+	testPrepareBond(ctx, require, node, db)
+
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	var inputs []*bitcoin.Input
+	for i := range bitcoin.ConsolidationUnspentUtxo {
+		satoshi := int64(10000 + i)
+		hash, err := testNetwork.bitcoin.Fund(testSafeAddress, satoshi)
+		require.Nil(err)
+		inputs = append(inputs, &bitcoin.Input{TransactionHash: hash, Index: 0, Satoshi: satoshi})
+	}
+	testNetwork.bitcoin.Mine(int(chaincfg.MainNetParams.CoinbaseMaturity))
+	testUpdateBitcoinNetworkTip(ctx, require, node)
+	for i, in := range inputs {
+		testObserverHolderDeposit(ctx, require, node, mpc, observer, in, i+1)
+	}
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	nonce := uuid.Must(uuid.NewV4()).String()
+	sig := testBitcoinSignMessage(testBitcoinKeyHolderPrivate, safeSelfSpendMessage("CONSOLIDATE", safe, nonce))
+
+	// the signature is bound to the nonce, so it fails with any other request id
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeConsolidateUTXOs, sig, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+
+	out = testBuildObserverRequest(node, nonce, holder, common.ActionBitcoinSafeConsolidateUTXOs, sig, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, bitcoin.ConsolidationUnspentUtxo)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxIn, bitcoin.ConsolidationUnspentUtxo)
+	require.Len(psbt.UnsignedTx.TxOut, 2)
+	script, err := bitcoin.ParseAddress(testSafeAddress, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(script, psbt.UnsignedTx.TxOut[0].PkScript)
+
+	// the transaction is revoked, then the same authorisation replayed again
+	testSafeRevokeTransaction(ctx, require, node, tx.TransactionHash, false)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+	testObserverRequestReplay(ctx, require, node, nonce, holder, common.ActionBitcoinSafeConsolidateUTXOs, sig, common.CurveSecp256k1ECDSABitcoin)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+	txs, err := node.store.ListTransactionsForHolder(ctx, holder, common.RequestStateInitial, time.Now().Add(time.Hour))
+	require.Nil(err)
+	require.Len(txs, 0)
+}
+
 func TestBitcoinKeeperCloseAccountWithSignerObserver(t *testing.T) {
 	require := require.New(t)
 	ctx, node, db, mpc, signers := testPrepare(require)
@@ -150,11 +223,7 @@ func TestBitcoinKeeperCloseAccountWithSignerObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	input := &bitcoin.Input{
-		TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
-		Index:           0,
-		Satoshi:         100000,
-	}
+	input := testFundBitcoinSafe(ctx, require, node, 100000)
 	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
 
 	public := testPublicKey(testBitcoinKeyHolderPrivate)
@@ -167,7 +236,7 @@ func TestBitcoinKeeperCloseAccountWithSignerObserver(t *testing.T) {
 	require.Nil(err)
 	require.Len(pendings, 0)
 
-	transactionHash := testSafeProposeRecoveryTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e", "cbddccdd13631eb68a1d65ace28abd547f62a0937d093d7ba4d0e97f6d86955e", "70736274ff01007902000000019451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c8500000000000600000002a086010000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814900000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b29268935287000000")
+	transactionHash := testSafeProposeRecoveryTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c744e", "8b1c5b0aaac8003fe4462f381ae1b9460be88ca4030647a89e0e22df045fc623", "70736274ff0100790200000001ba32f371281dab6206b8aac4055606b0eb10e5753b768cf1cb6476917436833400000000000600000002a086010000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814900000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b29268935287000000")
 	utxos, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
 	require.Nil(err)
 	require.Len(utxos, 0)
@@ -211,11 +280,7 @@ func TestBitcoinKeeperCloseAccountWithHolderObserver(t *testing.T) {
 		UnifiedOutput: *output,
 	}
 	node.ProcessOutput(ctx, action)
-	input := &bitcoin.Input{
-		TransactionHash: "851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
-		Index:           0,
-		Satoshi:         100000,
-	}
+	input := testFundBitcoinSafe(ctx, require, node, 100000)
 	testObserverHolderDeposit(ctx, require, node, mpc, observer, input, 1)
 
 	public := testPublicKey(testBitcoinKeyHolderPrivate)
@@ -228,7 +293,7 @@ func TestBitcoinKeeperCloseAccountWithHolderObserver(t *testing.T) {
 	require.Nil(err)
 	require.Len(pendings, 0)
 
-	holderSignedRaw := testHolderApproveTransaction("70736274ff01007902000000019451d4f1cbcd85535e80b54b9b151225783e11365840be166df67df179e91c8500000000000600000002a086010000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814900000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b29268935287000000")
+	holderSignedRaw := testHolderApproveTransaction("70736274ff0100790200000001ba32f371281dab6206b8aac4055606b0eb10e5753b768cf1cb6476917436833400000000000600000002a086010000000000220020fbf817b9dd1197a37e47af0a99b2f3ea252caf13f5ea2a18cc6bec9a1b9814900000000000000000126a103e37ea1c1455400d9642f6bbcd8c744e000000000001012ba086010000000000220020df81de61b27083d0f10966c41519bc143c17c9b1103c43059c495a1a4f7f8873010304810000000105762103911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56ac7c2102339baf159c94cc116562d609097ff3c3bd340a34b9f7d50cc22b8d520301a7c9ac937c829263210333870af2985a674f28bb12290bb0eb403987c2211d9f26267cc4d45ae6797e7cad56b29268935287000000")
 	signedRaw := testSafeCloseAccount(ctx, require, node, public, "", holderSignedRaw, signers, action)
 	utxos, err = node.store.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
 	require.Nil(err)
@@ -252,6 +317,45 @@ func TestBitcoinKeeperCloseAccountWithHolderObserver(t *testing.T) {
 }
 
 func testPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	ctx, node, db, mpc, signers := testPrepareKeys(require)
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	rid, publicKey := testSafeProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+	testSafeApproveAccount(ctx, require, node, mpc, observer, rid, publicKey)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+	for i := 0; i < 10; i++ {
+		testUpdateBitcoinNetworkTip(ctx, require, node)
+	}
+
+	return ctx, node, db, mpc, signers
+}
+
+// testPrepareBond deploys the bond contract of the test safe, and writes the
+// bond output to pay for the requests of the safe holder
+func testPrepareBond(ctx context.Context, require *require.Assertions, node *Node, db *mtg.SQLite3Store) string {
+	bondId := testDeployBondContract(ctx, require, node, testSafeAddress, common.SafeBitcoinChainId)
+	require.Equal(testBondAssetId, bondId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{UnifiedOutput: *output})
+	return bondId
+}
+
+// testPrepareDeposits funds the test safe with each satoshi amount in order,
+// and all the deposits are confirmed by the observer
+func testPrepareDeposits(ctx context.Context, require *require.Assertions, node *Node, mpc string, satoshis ...int64) []*bitcoin.Input {
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	inputs := make([]*bitcoin.Input, len(satoshis))
+	for i, satoshi := range satoshis {
+		inputs[i] = testFundBitcoinSafe(ctx, require, node, satoshi)
+		testObserverHolderDeposit(ctx, require, node, mpc, observer, inputs[i], i+1)
+	}
+	return inputs
+}
+
+// testPrepareKeys prepares the signer and observer keys and the operation
+// params, but no safe is proposed yet
+func testPrepareKeys(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
 	logger.SetLevel(logger.INFO)
 	ctx, signers, _ := signer.TestPrepare(require)
 	mpc, cc := signer.TestCMPPrepareKeys(ctx, require, signers, common.CurveSecp256k1ECDSABitcoin)
@@ -313,14 +417,6 @@ func testPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLi
 	for i := 0; i < 10; i++ {
 		testUpdateAccountPrice(ctx, require, node)
 	}
-	rid, publicKey := testSafeProposeAccount(ctx, require, node, mpc, observer)
-	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
-	testSafeApproveAccount(ctx, require, node, mpc, observer, rid, publicKey)
-	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
-	for i := 0; i < 10; i++ {
-		testUpdateNetworkStatus(ctx, require, node, 793574, "00000000000000000002a4f5cd899ea457314c808897c5c5f1f1cd6ffe2b266a")
-	}
-
 	return ctx, node, db, mpc, signers
 }
 
@@ -331,6 +427,7 @@ func testUpdateAccountPrice(ctx context.Context, require *require.Assertions, no
 	extra = append(extra, uuid.Must(uuid.FromString(testAccountPriceAssetId)).Bytes()...)
 	extra = binary.BigEndian.AppendUint64(extra, testAccountPriceAmount*100000000)
 	extra = binary.BigEndian.AppendUint64(extra, 10000)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(SafeProposalExpiryMaximum/time.Second))
 	dummy := testPublicKey(testBitcoinKeyDummyHolderPrivate)
 	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverSetOperationParams, extra, common.CurveSecp256k1ECDSABitcoin)
 	testStep(ctx, require, node, out)
@@ -340,6 +437,7 @@ func testUpdateAccountPrice(ctx context.Context, require *require.Assertions, no
 	require.Equal(testAccountPriceAssetId, plan.OperationPriceAsset)
 	require.Equal(fmt.Sprint(testAccountPriceAmount), plan.OperationPriceAmount.String())
 	require.Equal("0.0001", plan.TransactionMinimum.String())
+	require.Equal(SafeProposalExpiryMaximum, plan.ProposalExpiry)
 }
 
 func testUpdateNetworkStatus(ctx context.Context, require *require.Assertions, node *Node, blockHeight int, blockHash string) {
@@ -364,6 +462,26 @@ func testUpdateNetworkStatus(ctx context.Context, require *require.Assertions, n
 	require.Equal(hash.String(), info.Hash)
 }
 
+// testUpdateBitcoinNetworkTip sends the latest block of the test bitcoin chain
+func testUpdateBitcoinNetworkTip(ctx context.Context, require *require.Assertions, node *Node) {
+	tip := testNetwork.bitcoin.Tip()
+	testUpdateNetworkStatus(ctx, require, node, int(tip.Height), tip.Hash)
+}
+
+// testFundBitcoinSafe deposits the satoshi to the test safe with a coinbase
+// transaction, which is then matured in the test bitcoin chain
+func testFundBitcoinSafe(ctx context.Context, require *require.Assertions, node *Node, satoshi int64) *bitcoin.Input {
+	return testFundBitcoinAddress(ctx, require, node, testSafeAddress, satoshi)
+}
+
+func testFundBitcoinAddress(ctx context.Context, require *require.Assertions, node *Node, address string, satoshi int64) *bitcoin.Input {
+	hash, err := testNetwork.bitcoin.Fund(address, satoshi)
+	require.Nil(err)
+	testNetwork.bitcoin.Mine(int(chaincfg.MainNetParams.CoinbaseMaturity))
+	testUpdateBitcoinNetworkTip(ctx, require, node)
+	return &bitcoin.Input{TransactionHash: hash, Index: 0, Satoshi: satoshi}
+}
+
 func testSafeRevokeTransaction(ctx context.Context, require *require.Assertions, node *Node, transactionHash string, signByObserver bool) {
 	id := uuid.Must(uuid.NewV4()).String()
 
@@ -406,9 +524,9 @@ func testAccountantSpentTransaction(_ context.Context, require *require.Assertio
 
 	switch testType {
 	case testHolderSigner:
-		require.Equal("fcc2dc6e90d454ec76cc48925096281735ed85ccd93a73b87cd303be9f28478e", tx.TxHash().String())
+		require.Equal("4c302c6c11de65d132a9b5eb2ee14b9bcfd9ee168a99ab6a3fe088a3914dc2cc", tx.TxHash().String())
 	case testSignerObserver, testHolderObserver:
-		require.Equal("09f837325c7285c2e118942536677926221a2eb882457b0f6aecc52b197aa201", tx.TxHash().String())
+		require.Equal("5714ecf7a65e984cfbf888cfa2a894ee225157d08dfce0a63a31812e39b2df5a", tx.TxHash().String())
 	}
 	logger.Printf("%x", rb)
 }
@@ -440,35 +558,36 @@ func testSafeApproveTransaction(ctx context.Context, require *require.Assertions
 
 	out := testBuildObserverRequest(node, id, testPublicKey(testBitcoinKeyHolderPrivate), common.ActionBitcoinSafeApproveTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
 	testStep(ctx, require, node, out)
+	inputs := len(psTx.UnsignedTx.TxIn)
 	requests, err := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
 	require.Nil(err)
-	require.Len(requests, 2)
-	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
-	require.Equal(common.RequestStatePending, tx.State)
-
-	msg, _ := hex.DecodeString(requests[0].Message)
-	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
-	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
-	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
-	testStep(ctx, require, node, out)
-	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
-	require.Len(requests, 1)
-	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
-	require.Len(requests, 1)
+	require.Len(requests, inputs)
 	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
 	require.Equal(common.RequestStatePending, tx.State)
 
-	msg, _ = hex.DecodeString(requests[1].Message)
-	out = testBuildSignerOutput(node, requests[1].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
-	op = signer.TestProcessOutput(ctx, require, signers, out, requests[1].RequestId)
-	out = testBuildSignerOutput(node, requests[1].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
-	testStep(ctx, require, node, out)
+	for idx := range inputs {
+		r := requests[idx]
+		msg, _ := hex.DecodeString(r.Message)
+		out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
+		op := signer.TestProcessOutput(ctx, require, signers, out, r.RequestId)
+		out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
+		testStep(ctx, require, node, out)
+		if idx+1 == inputs {
+			break
+		}
+		pendings, _ := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
+		require.Len(pendings, idx+1)
+		initials, _ := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+		require.Len(initials, inputs-idx-1)
+		tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+		require.Equal(common.RequestStatePending, tx.State)
+	}
 	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
 	require.Len(requests, 0)
 	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
 	require.Len(requests, 0)
 	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateDone)
-	require.Len(requests, 2)
+	require.Len(requests, inputs)
 	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
 	require.Equal(common.RequestStateDone, tx.State)
 
@@ -514,7 +633,7 @@ func testSafeProposeTransaction(ctx context.Context, require *require.Assertions
 	addr, _ := script.Address(&chaincfg.MainNetParams)
 	require.Equal(testTransactionReceiver, addr.EncodeAddress())
 	change := tx.TxOut[1]
-	require.Equal(int64(174260), change.Value)
+	require.Equal(int64(87700), change.Value)
 	script, _ = txscript.ParsePkScript(change.PkScript)
 	addr, _ = script.Address(&chaincfg.MainNetParams)
 	require.Equal(testSafeAddress, addr.EncodeAddress())
@@ -616,8 +735,9 @@ func (node *Node) testSignerHolderApproveTransaction(ctx context.Context, requir
 }
 
 func testSafeCloseAccount(ctx context.Context, require *require.Assertions, node *Node, holder, transactionHash, holderSignedRaw string, signers []*signer.Node, action *mtg.Action) string {
+	testNetwork.bitcoin.Mine(int(bitcoin.ParseSequence(testTimelockDuration, common.SafeChainBitcoin)) + 100)
 	for i := 0; i < 10; i++ {
-		testUpdateNetworkStatus(ctx, require, node, 797082, "00000000000000000004f8a108a06a9f61389c7340d8a3fa431a534ff339402a")
+		testUpdateBitcoinNetworkTip(ctx, require, node)
 	}
 
 	safe, _ := node.store.ReadSafe(ctx, holder)
@@ -747,7 +867,7 @@ func testObserverHolderDeposit(ctx context.Context, require *require.Assertions,
 	extra = append(extra, big.NewInt(input.Satoshi).Bytes()...)
 
 	holder := testPublicKey(testBitcoinKeyHolderPrivate)
-	wsa, _ := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	wsa, _ := node.buildBitcoinWitnessAccountWithDerivation(ctx, []string{holder}, 1, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
 
 	out := testBuildObserverRequest(node, id, holder, common.ActionObserverHolderDeposit, extra, common.CurveSecp256k1ECDSABitcoin)
 	testStep(ctx, require, node, out)
@@ -761,7 +881,7 @@ func testObserverHolderDeposit(ctx context.Context, require *require.Assertions,
 	require.Equal(wsa.Script, utxo.Script)
 	require.Equal(hash.String(), utxo.TransactionHash)
 	require.Equal(uint32(6), wsa.Sequence)
-	require.True(bitcoin.CheckMultisigHolderSignerScript(utxo.Script))
+	require.True(bitcoin.CheckMultisigHolderSignerScript(utxo.Script, common.SafeChainBitcoin))
 }
 
 func testSafeProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) (string, string) {
@@ -783,7 +903,7 @@ func testSafeProposeAccount(ctx context.Context, require *require.Assertions, no
 	require.Equal(holder, safe.Holder)
 	require.Equal(signer, safe.Signer)
 	require.Equal(observer, safe.Observer)
-	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, []string{holder}, 1, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
 	require.Nil(err)
 	require.Equal(testSafeAddress, public.Address)
 	require.Equal(public.Address, safe.Address)
@@ -818,7 +938,7 @@ func testSafeApproveAccount(ctx context.Context, require *require.Assertions, no
 	require.Equal(holder, safe.Holder)
 	require.Equal(signer, safe.Signer)
 	require.Equal(observer, safe.Observer)
-	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holder, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	public, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, []string{holder}, 1, signer, observer, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
 	require.Nil(err)
 	require.Equal(testSafeAddress, public.Address)
 	require.Equal(public.Address, safe.Address)
@@ -890,6 +1010,10 @@ func testReadObserverResponse(ctx context.Context, require *require.Assertions,
 		params, _ := node.store.ReadLatestOperationParams(ctx, common.SafeChainPolygon, time.Now())
 		require.Equal(params.OperationPriceAsset, om["asset_id"])
 		require.Equal(params.OperationPriceAmount.String(), om["amount"])
+	case common.ActionSolanaSafeApproveAccount:
+		params, _ := node.store.ReadLatestOperationParams(ctx, common.SafeChainSolana, time.Now())
+		require.Equal(params.OperationPriceAsset, om["asset_id"])
+		require.Equal(params.OperationPriceAmount.String(), om["amount"])
 	default:
 		require.Equal(node.conf.ObserverAssetId, om["asset_id"])
 		require.Equal("1", om["amount"])
@@ -911,8 +1035,11 @@ func testBuildHolderRequest(node *Node, id, public string, action byte, assetId
 	crv := byte(common.CurveSecp256k1ECDSABitcoin)
 	switch action {
 	case common.ActionBitcoinSafeProposeAccount, common.ActionBitcoinSafeProposeTransaction:
-	case common.ActionEthereumSafeProposeAccount, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionEthereumSafeProposeAccount, common.ActionEthereumSafeProposeTransaction,
+		common.ActionEthereumSafeMigrateAccount, common.ActionEthereumSafeConvertAsset:
 		crv = common.CurveSecp256k1ECDSAPolygon
+	case common.ActionSolanaSafeProposeAccount, common.ActionSolanaSafeProposeTransaction:
+		crv = common.CurveEdwards25519Solana
 	}
 	op := &common.Operation{
 		Id:     id,
@@ -937,6 +1064,60 @@ func testBuildHolderRequest(node *Node, id, public string, action byte, assetId
 	}
 }
 
+// testObserverRequestReplay sends the handled observer request again with
+// another output, which must be ignored because the request id is handled
+func testObserverRequestReplay(ctx context.Context, require *require.Assertions, node *Node, id, public string, action byte, extra []byte, crv byte) {
+	out := testBuildObserverRequest(node, id, public, action, extra, crv)
+	out.OutputId = common.UniqueId(id, "replay")
+	txs, asset := node.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	require.Equal("", asset)
+}
+
+// testBuildHolderStorageRequest builds the holder request with the extra too
+// large for the memo, the memo is the hash of the storage transaction, which
+// is referenced by the request transaction in the kernel
+func testBuildHolderStorageRequest(ctx context.Context, require *require.Assertions, node *Node, db *mtg.SQLite3Store, id, public string, action byte, assetId string, extra []byte, amount decimal.Decimal) *mtg.Action {
+	storage := mc.NewTransactionV5(mc.XINAssetId)
+	storage.Extra = extra
+	sver := storage.AsVersioned()
+	ref := sver.PayloadHash()
+
+	out := testBuildHolderRequest(node, id, public, action, assetId, ref[:], amount)
+	tx := mc.NewTransactionV5(mc.XINAssetId)
+	tx.References = []crypto.Hash{ref}
+	for hash, ver := range map[string]*mc.VersionedTransaction{
+		out.TransactionHash: tx.AsVersioned(),
+		ref.String():        sver,
+	} {
+		key := fmt.Sprintf("readKernelTransactionUntilSufficient(%s)", hash)
+		val, err := db.ReadCache(ctx, key)
+		require.Nil(err)
+		if val != "" {
+			continue
+		}
+		err = db.WriteCache(ctx, key, base64.RawURLEncoding.EncodeToString(ver.Marshal()))
+		require.Nil(err)
+	}
+	return out
+}
+
+func testCheckRequestState(ctx context.Context, require *require.Assertions, node *Node, id string, state int) {
+	req, err := node.store.ReadRequest(ctx, id)
+	require.Nil(err)
+	require.Equal(state, int(req.State))
+}
+
+func testBitcoinSignMessage(priv, ms string) []byte {
+	msg := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+	return testBitcoinSignHash(priv, msg)
+}
+
+func testBitcoinSignHash(priv string, hash []byte) []byte {
+	key, _ := btcec.PrivKeyFromBytes(common.DecodeHexOrPanic(priv))
+	return ecdsa.Sign(key, hash).Serialize()
+}
+
 func testBuildObserverRequest(node *Node, id, public string, action byte, extra []byte, crv byte) *mtg.Action {
 	sequence += 10
 
@@ -976,6 +1157,8 @@ func testBuildSignerOutput(node *Node, id, public string, action byte, extra []b
 	case common.CurveSecp256k1ECDSABitcoin:
 	case common.CurveSecp256k1ECDSAEthereum, common.CurveSecp256k1ECDSAPolygon:
 		path = ethereumDefaultDerivationPath()
+	case common.CurveEdwards25519Default:
+		path = solanaDefaultDerivationPath()
 	default:
 		panic(crv)
 	}
@@ -1020,11 +1203,10 @@ func testBuildSignerOutput(node *Node, id, public string, action byte, extra []b
 func testDeployBondContract(ctx context.Context, require *require.Assertions, node *Node, addr, assetId string) string {
 	safe, _ := node.store.ReadSafeByAddress(ctx, addr)
 	asset, _ := node.fetchAssetMeta(ctx, assetId)
-	err := abi.GetOrDeployFactoryAsset(ctx, node.conf.PolygonRPC, os.Getenv("MVM_DEPLOYER"), asset.AssetId, asset.Symbol, asset.Name, node.conf.PolygonKeeperDepositEntry, safe.Holder)
-	require.Nil(err)
+	testNetwork.mixin.RegisterBond(node.conf.PolygonKeeperDepositEntry, asset.AssetId, safe.Holder)
 	bond := abi.GetFactoryAssetAddress(node.conf.PolygonKeeperDepositEntry, assetId, asset.Symbol, asset.Name, safe.Holder)
 	assetKey := strings.ToLower(bond.String())
-	err = ethereum.VerifyAssetKey(assetKey)
+	err := ethereum.VerifyAssetKey(assetKey)
 	require.Nil(err)
 	asset, _ = node.fetchAssetMeta(ctx, ethereum.GenerateAssetId(common.SafeChainPolygon, assetKey))
 	return asset.AssetId
@@ -1042,9 +1224,33 @@ func testBuildNode(ctx context.Context, require *require.Assertions, root string
 	require.Nil(err)
 	conf.Keeper.MTG.GroupSize = 1
 
-	if rpc := os.Getenv("POLYGONRPC"); rpc != "" {
-		conf.Keeper.PolygonRPC = rpc
+	testNetwork.bitcoin = btcrpc.NewServer(bitcoin.ChainBitcoin)
+	testNetwork.polygon = evmrpc.NewServer(ethereum.ChainPolygon)
+	testNetwork.solana = solrpc.NewServer()
+	testNetwork.mixin = mixinrpc.NewServer()
+	testNetwork.polygon.HandleCall(conf.Keeper.PolygonFactoryAddress, testNetwork.mixin.CallFactory)
+	for _, a := range []*mixinrpc.Asset{{
+		AssetId:   common.SafePolygonChainId,
+		AssetKey:  ethereum.EthereumEmptyAddress,
+		Symbol:    "MATIC",
+		Name:      "Polygon",
+		Precision: 18,
+		ChainId:   common.SafePolygonChainId,
+	}, {
+		AssetId:   testEthereumUSDTAssetId,
+		AssetKey:  strings.ToLower(testEthereumUSDTAddress),
+		Symbol:    "USDT",
+		Name:      "(PoS) Tether USD",
+		Precision: 6,
+		ChainId:   common.SafePolygonChainId,
+	}} {
+		testNetwork.mixin.RegisterAsset(a)
 	}
+	conf.Keeper.BitcoinRPC = testNetwork.bitcoin.URL()
+	conf.Keeper.PolygonRPC = testNetwork.polygon.URL()
+	conf.Keeper.SolanaRPC = testNetwork.solana.URL()
+	conf.Keeper.SolanaProgram = testSolanaProgram
+	conf.Keeper.MixinMessengerAPI = testNetwork.mixin.URL()
 
 	conf.Keeper.StoreDir = root
 	if !(strings.HasPrefix(conf.Keeper.StoreDir, "/tmp/") || strings.HasPrefix(conf.Keeper.StoreDir, "/var/folders")) {
@@ -1156,3 +1362,34 @@ func testGenerateDummyExtra(node *Node) string {
 	e := mtg.EncodeMixinExtraBase64(node.conf.AppId, nil)
 	return hex.EncodeToString([]byte(e))
 }
+
+func TestParseBitcoinTransactionRecipients(t *testing.T) {
+	require := require.New(t)
+
+	recipients, inputs, err := parseBitcoinTransactionRecipients([]byte(`[["bc1ql0up0wwazxt6xlj84u9fnvhnagjjetcn7h4z5xxvd0kf5xuczjgqq2aehc","0.000123"]]`))
+	require.Nil(err)
+	require.Len(recipients, 1)
+	require.Len(inputs, 0)
+
+	recipients, inputs, err = parseBitcoinTransactionRecipients([]byte(`{"recipients":[["bc1ql0up0wwazxt6xlj84u9fnvhnagjjetcn7h4z5xxvd0kf5xuczjgqq2aehc","0.000123"]],"inputs":["851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194:0"]}`))
+	require.Nil(err)
+	require.Len(recipients, 1)
+	require.Equal("0.000123", recipients[0][1])
+	require.Len(inputs, 1)
+	pop, err := parseBitcoinOutpoint(inputs[0])
+	require.Nil(err)
+	require.Equal("851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194", pop.Hash.String())
+	require.Equal(uint32(0), pop.Index)
+
+	_, _, err = parseBitcoinTransactionRecipients([]byte(`"recipients"`))
+	require.NotNil(err)
+	for _, s := range []string{
+		"851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194",
+		"851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d45194:01",
+		"851ce979f17df66d16be405836113e782512159b4bb5805e5385cdcbf1d4519:0",
+		"851CE979F17DF66D16BE405836113E782512159B4BB5805E5385CDCBF1D45194:0",
+	} {
+		_, err = parseBitcoinOutpoint(s)
+		require.NotNil(err)
+	}
+}
@@ -0,0 +1,282 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/gofrs/uuid/v5"
+)
+
+// The holders could prove the control of the safe address to exchanges and
+// auditors by signing an arbitrary message together with the signer. The
+// message is proved with a BIP-322 simple signature for the P2WSH safes, or
+// an EIP-712 safe message signature verifiable through isValidSignature of
+// EIP-1271 for the Gnosis safes, the observer key is never used.
+func (node *Node) processSafeSignMessage(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain || safe.State != common.RequestStateDone {
+		return node.failRequest(ctx, req, "")
+	}
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		if req.Action != common.ActionBitcoinSafeSignMessage {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if req.Action != common.ActionEthereumSafeSignMessage {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 32 {
+		return node.failRequest(ctx, req, "")
+	}
+	var ref crypto.Hash
+	copy(ref[:], extra)
+	raw := node.readStorageExtraFromObserver(ctx, ref)
+	sm, err := common.DecodeSafeMessage(raw)
+	logger.Printf("common.DecodeSafeMessage(%x) => %v %v", raw, sm, err)
+	if err != nil || len(sm.Message) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var hash, messageHash, signed string
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		path := common.DecodeHexOrPanic(safe.Path)
+		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holders, threshold, safe.Signer, safe.Observer, path, safe.Timelock, safe.Chain)
+		if err != nil {
+			panic(err)
+		}
+		if wsa.Address != safe.Address {
+			panic(safe.Address)
+		}
+		mpsbt, err := bitcoin.BuildPartiallySignedMessage(sm.Message, wsa.Script, safe.Chain)
+		logger.Printf("bitcoin.BuildPartiallySignedMessage(%x) => %v %v", sm.Message, mpsbt, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		sighash := mpsbt.MessageSigHash()
+		sigs, err := verifySafeApprovalSignatures(holders, threshold, sm.Signature, func(public string, sig []byte) error {
+			return bitcoin.VerifySignatureDER(public, sighash, sig)
+		})
+		logger.Printf("bitcoin.VerifySignatureDER(%v, %x) => %v", holders, sm.Signature, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		for i, sig := range sigs {
+			if sig == nil {
+				continue
+			}
+			mpsbt.Inputs[0].PartialSigs = append(mpsbt.Inputs[0].PartialSigs, &psbt.PartialSig{
+				PubKey:    common.DecodeHexOrPanic(holders[i]),
+				Signature: sig,
+			})
+		}
+		hash = hex.EncodeToString(sighash)
+		messageHash = mpsbt.Hash()
+		signed = hex.EncodeToString(mpsbt.Marshal())
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if len(sm.Message) > ethereum.MessageMaximumSize {
+			return node.failRequest(ctx, req, "")
+		}
+		chainId := ethereum.GetEvmChainID(int64(safe.Chain))
+		smh := ethereum.SafeMessageHash(chainId, safe.Address, sm.Message)
+		_, err := verifySafeMessageHolderSignatures(holders, threshold, smh, sm.Signature)
+		logger.Printf("verifySafeMessageHolderSignatures(%v, %x) => %v", holders, sm.Signature, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		hash = hex.EncodeToString(ethereum.HashMessageForSignature(hex.EncodeToString(smh)))
+		messageHash = hex.EncodeToString(smh)
+		signed = hex.EncodeToString(sm.Signature)
+	}
+	if sm.MessageId != common.UniqueId(safe.RequestId, messageHash) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	old, err := node.store.ReadSafeMessageByHash(ctx, messageHash)
+	logger.Printf("store.ReadSafeMessageByHash(%s) => %v %v", messageHash, old, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeMessageByHash(%s) => %v", messageHash, err))
+	}
+	if old != nil && old.State != common.RequestStatePending {
+		return node.failRequest(ctx, req, "")
+	}
+	pending, err := node.checkTransactionIndexSignaturePending(ctx, messageHash, 0, req)
+	logger.Printf("node.checkTransactionIndexSignaturePending(%s, 0) => %t %v", messageHash, pending, err)
+	if err != nil {
+		panic(err)
+	} else if pending {
+		return node.failRequest(ctx, req, "")
+	}
+
+	m := &store.SafeMessage{
+		MessageId:   sm.MessageId,
+		MessageHash: messageHash,
+		Holder:      safe.Holder,
+		Chain:       safe.Chain,
+		Message:     hex.EncodeToString(sm.Message),
+		Raw:         signed,
+		RequestId:   req.Id,
+		State:       common.RequestStatePending,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	sr := &store.SignatureRequest{
+		TransactionHash: messageHash,
+		InputIndex:      0,
+		Signer:          safe.Signer,
+		Curve:           req.Curve,
+		Message:         hash,
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSafeMessageWithRequest(ctx, m, []*store.SignatureRequest{sr}, txs, req)
+	logger.Printf("store.WriteSafeMessageWithRequest(%v, %v) => %v", m, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// verifySafeMessageHolderSignatures verifies the eth_sign signatures of the
+// holders, which must have the v adjusted for the Gnosis safe already
+func verifySafeMessageHolderSignatures(holders []string, threshold int, hash, sig []byte) ([][]byte, error) {
+	return verifySafeApprovalSignatures(holders, threshold, sig, func(public string, sig []byte) error {
+		if len(sig) != 65 || sig[64] <= 30 {
+			return fmt.Errorf("invalid eth_sign signature %x", sig)
+		}
+		return ethereum.VerifyMessageSignature(public, hash, sig)
+	})
+}
+
+func (node *Node) processSafeMessageSignatureResponse(ctx context.Context, req *common.Request, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	m, err := node.store.ReadSafeMessageByHash(ctx, old.TransactionHash)
+	logger.Printf("store.ReadSafeMessageByHash(%s) => %v %v", old.TransactionHash, m, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeMessageByHash(%s) => %v", old.TransactionHash, err))
+	}
+	if m == nil || m.State != common.RequestStatePending {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, m.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", m.Holder, err))
+	}
+	if safe.Signer != req.Holder {
+		return node.failRequest(ctx, req, "")
+	}
+
+	sig := req.ExtraBytes()
+	msg := common.DecodeHexOrPanic(old.Message)
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+
+	var typ byte
+	var proof string
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		spk, err := node.deriveBIP32WithPath(ctx, safe.Signer, common.DecodeHexOrPanic(safe.Path))
+		if err != nil {
+			panic(fmt.Errorf("node.deriveBIP32WithPath(%s, %s) => %v", safe.Signer, safe.Path, err))
+		}
+		err = bitcoin.VerifySignatureDER(spk, msg, sig)
+		logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", req, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		mpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(m.Raw))
+		if err != nil {
+			panic(err)
+		}
+		mpsbt.Inputs[0].PartialSigs = append(mpsbt.Inputs[0].PartialSigs, &psbt.PartialSig{
+			PubKey:    common.DecodeHexOrPanic(spk),
+			Signature: sig,
+		})
+		proof, err = mpsbt.SignedMessageProof(holders, threshold, spk, safe.Observer)
+		if err != nil {
+			panic(err)
+		}
+		typ = common.ActionBitcoinSafeSignMessage
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		err = ethereum.VerifyHashSignature(safe.Signer, msg, sig)
+		logger.Printf("ethereum.VerifyHashSignature(%v) => %v", req, err)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		smh := common.DecodeHexOrPanic(m.MessageHash)
+		hsigs, err := verifySafeMessageHolderSignatures(holders, threshold, smh, common.DecodeHexOrPanic(m.Raw))
+		if err != nil {
+			panic(err)
+		}
+		sigs := map[string][]byte{safe.Signer: ethereum.ProcessSignature(sig)}
+		for i, s := range hsigs {
+			if s != nil {
+				sigs[holders[i]] = s
+			}
+		}
+		_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+		packed, err := ethereum.PackSafeMessageSignatures(pubs, sigs)
+		if err != nil {
+			panic(err)
+		}
+		proof = hex.EncodeToString(packed)
+		typ = common.ActionEthereumSafeSignMessage
+	default:
+		panic(safe.Chain)
+	}
+
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	data := append(uuid.Must(uuid.FromString(m.MessageId)).Bytes(), []byte(proof)...)
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(data)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(m.MessageHash, stx.TraceId)
+	crv := common.SafeChainCurve(safe.Chain)
+	t := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if t == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, t)
+
+	err = node.store.FinishSafeMessageWithRequest(ctx, m, proof, txs, req)
+	logger.Printf("store.FinishSafeMessageWithRequest(%s, %s, %v) => %v", m.MessageId, proof, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
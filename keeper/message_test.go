@@ -0,0 +1,120 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperSignMessage(t *testing.T) {
+	require := require.New(t)
+	ctx, node, _, _, signers := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, holders, threshold, safe.Signer, safe.Observer, common.DecodeHexOrPanic(safe.Path), safe.Timelock, safe.Chain)
+	require.Nil(err)
+	require.Equal(safe.Address, wsa.Address)
+
+	message := []byte("mixin safe proof of reserves")
+	mpsbt, err := bitcoin.BuildPartiallySignedMessage(message, wsa.Script, safe.Chain)
+	require.Nil(err)
+	messageHash := mpsbt.Hash()
+	sm := &common.SafeMessage{
+		MessageId: common.UniqueId(safe.RequestId, messageHash),
+		Message:   message,
+	}
+
+	// the message must be signed by the safe holder
+	sm.Signature = testBitcoinSignHash(testBitcoinKeyDummyHolderPrivate, mpsbt.MessageSigHash())
+	id := testSafeSignMessage(ctx, require, node, holder, sm)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	m, err := node.store.ReadSafeMessage(ctx, sm.MessageId)
+	require.Nil(err)
+	require.Nil(m)
+
+	sm.Signature = testBitcoinSignHash(testBitcoinKeyHolderPrivate, mpsbt.MessageSigHash())
+	id = testSafeSignMessage(ctx, require, node, holder, sm)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	m, err = node.store.ReadSafeMessage(ctx, sm.MessageId)
+	require.Nil(err)
+	require.Equal(messageHash, m.MessageHash)
+	require.Equal(hex.EncodeToString(message), m.Message)
+	require.Equal(common.RequestStatePending, m.State)
+	require.False(m.Proof.Valid)
+
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, messageHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+	r := requests[0]
+	require.Equal(hex.EncodeToString(mpsbt.MessageSigHash()), r.Message)
+	msg, _ := hex.DecodeString(r.Message)
+	out := testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
+	op := signer.TestProcessOutput(ctx, require, signers, out, r.RequestId)
+	out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+
+	requests, err = node.store.ListAllSignaturesForTransaction(ctx, messageHash, common.RequestStateDone)
+	require.Nil(err)
+	require.Len(requests, 1)
+	m, err = node.store.ReadSafeMessage(ctx, sm.MessageId)
+	require.Nil(err)
+	require.Equal(common.RequestStateDone, m.State)
+	require.True(m.Proof.Valid)
+
+	// the observer receives the proof of the message
+	data := append(uuid.Must(uuid.FromString(sm.MessageId)).Bytes(), []byte(m.Proof.String)...)
+	sTraceId := crypto.Blake3Hash([]byte(common.Base91Encode(data))).String()
+	sTraceId = mtg.UniqueId(sTraceId, sTraceId)
+	rid := common.UniqueId(messageHash, sTraceId)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeSignMessage)
+	require.Equal(data, b)
+
+	// the proof is the BIP-322 simple signature of the safe address
+	b, err = base64.StdEncoding.DecodeString(m.Proof.String)
+	require.Nil(err)
+	reader := bytes.NewReader(b)
+	count, err := wire.ReadVarInt(reader, 0)
+	require.Nil(err)
+	witness := make(wire.TxWitness, count)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(reader, 0, txscript.MaxScriptSize, "witness")
+		require.Nil(err)
+	}
+	require.Equal(0, reader.Len())
+	require.Equal(wsa.Script, witness[len(witness)-1])
+	pkScript, err := bitcoin.ParseAddress(safe.Address, safe.Chain)
+	require.Nil(err)
+	msgTx := mpsbt.UnsignedTx.Copy()
+	msgTx.TxIn[0].Witness = witness
+	pof := txscript.NewCannedPrevOutputFetcher(pkScript, 0)
+	engine, err := txscript.NewEngine(pkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), 0, pof)
+	require.Nil(err)
+	require.Nil(engine.Execute())
+
+	// the signed message could not be signed again
+	id = testSafeSignMessage(ctx, require, node, holder, sm)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+}
+
+func testSafeSignMessage(ctx context.Context, require *require.Assertions, node *Node, holder string, sm *common.SafeMessage) string {
+	ref := testWriteStorageRaw(ctx, require, node, sm.Encode())
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeSignMessage, ref[:], common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	return id
+}
@@ -28,7 +28,12 @@ func (node *Node) getMigrateAsset(ctx context.Context, safe *store.Safe, assetId
 
 func (node *Node) Migrate(ctx context.Context) error {
 	logger.Printf("keeper.Migrate() ...")
-	err := node.store.MigrateDepositCreated(ctx)
+	err := node.store.MigrateOperationParamsExpiry(ctx)
+	logger.Printf("keeper.MigrateOperationParamsExpiry() => %v", err)
+	if err != nil {
+		return err
+	}
+	err = node.store.MigrateDepositCreated(ctx)
 	logger.Printf("keeper.MigrateDepositCreated() => %v", err)
 	if err != nil {
 		return err
@@ -136,7 +141,7 @@ func (node *Node) checkSafeTokenMigration(ctx context.Context, req *common.Reque
 		return node.failRequest(ctx, req, "")
 	}
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		if safe.SafeAssetId != req.AssetId {
 			panic(req.AssetId)
 		}
@@ -0,0 +1,578 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// The holder migrates the safe to a new safe with new holder, signer and
+// observer keys, e.g. when the holder key is suspected to be leaked or
+// a different timelock is wanted. The request pays the operation price,
+// and the extra is the new holder key followed by the account proposal
+// extra of the new safe, or the hash of the storage transaction referenced.
+//
+// Migration Processes:
+// 1 keeper proposes the new safe, and the sweep transaction of all the old safe funds to the new safe address
+// 2 new holder approves the new safe, POST /accounts/:id with action 'approve'
+// 3 old holder signs the sweep transaction, POST /transactions/:id with action 'approve', and then signer signs
+// 4 the old safe is closed when the sweep is fully signed
+// 5 the sweep deposit to the new safe mints no safe assets
+// 6 the old safe assets are converted to the new safe assets, see processSafeConvertAsset
+//
+// The safe assets of the old safe could not be used for transactions after
+// the migration, because they are only accepted by an approved safe.
+func (node *Node) processBitcoinSafeMigrateAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+	if !node.checkSafeMigrationPayment(ctx, req, safe) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	nreq, arp := node.parseSafeMigrationRequest(ctx, req)
+	if arp == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	count, err := node.store.CountUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.CountUnfinishedTransactionsByHolder(%s) => %d %v", safe.Holder, count, err)
+	if err != nil {
+		panic(err)
+	} else if count != 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	mainInputs, err := node.store.ListAllBitcoinUTXOsForHolder(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllBitcoinUTXOsForHolder(%s) => %v", req.Holder, err))
+	}
+	if len(mainInputs) == 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	signer, observer := node.assignSafeMigrationKeys(ctx, nreq, arp)
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	path := bitcoinDefaultDerivationPath()
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, arp.Holders, int(arp.HolderThreshold), signer, observer, path, arp.Timelock, chain)
+	logger.Verbosef("node.buildBitcoinWitnessAccountWithDerivation(%v) => %v %v", nreq, wsa, err)
+	if err != nil {
+		panic(err)
+	}
+	old, err := node.store.ReadSafeProposalByAddress(ctx, wsa.Address)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", wsa.Address, err))
+	} else if old != nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	var total int64
+	for _, in := range mainInputs {
+		total = total + in.Satoshi
+	}
+	outputs := []*bitcoin.Output{{Address: wsa.Address, Satoshi: total}}
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(mainInputs, outputs, req.Operation().IdBytes(), safe.Chain)
+	logger.Printf("bitcoin.BuildPartiallySignedTransaction(%v) => %v %v", req, psbt, err)
+	if err != nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	extra := wsa.Marshal()
+	raw := psbt.Marshal()
+	txs := node.buildSafeMigrationResponses(ctx, req, chain, extra, raw, psbt.Hash())
+	if len(txs) == 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    nreq.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   wsa.Address,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	_, assetId := node.bitcoinParams(safe.Chain)
+	amt := decimal.New(total, -bitcoin.ValuePrecision)
+	data := common.MarshalJSONOrPanic([]map[string]string{{
+		"receiver": wsa.Address,
+		"amount":   amt.String(),
+	}})
+	tx := &store.Transaction{
+		TransactionHash: psbt.Hash(),
+		RawTransaction:  hex.EncodeToString(raw),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	m := buildSafeMigration(req, safe, sp, tx)
+	holders := buildSafeHolders(nreq, chain, wsa.Address, arp)
+	policy := buildSafePolicy(nreq, chain, arp.Policy, req.CreatedAt)
+	outflow := node.buildSafeMigrationOutflow(ctx, req, safe, tx, assetId, amt)
+	inputs := store.TransactionInputsFromBitcoin(mainInputs)
+	err = node.store.WriteSafeMigrationWithRequest(ctx, m, sp, holders, policy, nil, tx, inputs, outflow, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processEthereumSafeMigrateAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+	if !node.checkSafeMigrationPayment(ctx, req, safe) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	nreq, arp := node.parseSafeMigrationRequest(ctx, req)
+	if arp == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.ReadUnfinishedTransactionsByHolder(%s) => %v %v", safe.Holder, len(pendings), err)
+	if err != nil {
+		panic(err)
+	} else if len(pendings) > 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	signer, observer := node.assignSafeMigrationKeys(ctx, nreq, arp)
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	rpc, assetId := node.ethereumParams(chain)
+	gs, t, err := ethereum.BuildGnosisSafeWithHolders(ctx, rpc, arp.Holders, int(arp.HolderThreshold), signer, observer, req.Id, arp.Timelock, chain)
+	logger.Verbosef("ethereum.BuildGnosisSafeWithHolders(%v) => %v %v", nreq, gs, err)
+	if err != nil {
+		panic(err)
+	}
+	old, err := node.store.ReadSafeProposalByAddress(ctx, gs.Address)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", gs.Address, err))
+	} else if old != nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	balances, err := node.store.ReadAllEthereumTokenBalances(ctx, safe.Address)
+	logger.Printf("store.ReadAllEthereumTokenBalances(%s) => %v %v", safe.Address, balances, err)
+	if err != nil {
+		panic(err)
+	}
+	var outputs []*ethereum.Output
+	var recipients []map[string]string
+	for _, b := range balances {
+		if b.BigBalance().Cmp(big.NewInt(0)) <= 0 {
+			continue
+		}
+		decimals := int32(ethereum.ValuePrecision)
+		if b.AssetAddress != ethereum.EthereumEmptyAddress {
+			asset, err := node.store.ReadAssetMeta(ctx, b.AssetId)
+			logger.Printf("store.ReadAssetMeta(%s) => %v %v", b.AssetId, asset, err)
+			if err != nil {
+				panic(err)
+			}
+			decimals = int32(asset.Decimals)
+		}
+		outputs = append(outputs, &ethereum.Output{
+			Destination:  gs.Address,
+			Amount:       b.BigBalance(),
+			TokenAddress: b.AssetAddress,
+		})
+		r := map[string]string{
+			"receiver": gs.Address,
+			"amount":   decimal.NewFromBigInt(b.BigBalance(), -decimals).String(),
+		}
+		if b.AssetAddress != ethereum.EthereumEmptyAddress {
+			r["token"] = b.AssetAddress
+		}
+		recipients = append(recipients, r)
+	}
+	if len(outputs) == 0 || len(outputs) > 256 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txType := ethereum.TypeMultiSendTx
+	if len(outputs) == 1 {
+		txType = ethereum.TypeETHTx
+		if outputs[0].TokenAddress != ethereum.EthereumEmptyAddress {
+			txType = ethereum.TypeERC20Tx
+		}
+	}
+	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
+	st, err := ethereum.CreateTransactionFromOutputs(ctx, txType, chainId, req.Id, safe.Address, outputs, big.NewInt(safe.Nonce))
+	logger.Printf("ethereum.CreateTransactionFromOutputs(%d, %d, %s, %s, %v, %d) => %v %v",
+		txType, chainId, req.Id, safe.Address, outputs, safe.Nonce, st, err)
+	if err != nil {
+		panic(err)
+	}
+
+	extra := gs.Marshal()
+	raw := st.Marshal()
+	txs := node.buildSafeMigrationResponses(ctx, req, chain, extra, raw, st.TxHash)
+	if len(txs) == 0 {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+
+	path := ethereumDefaultDerivationPath()
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    nreq.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   gs.Address,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	// the deploy transaction belongs to the new holder, and the request id
+	// is left to the sweep transaction of the old holder
+	deploy := &store.Transaction{
+		TransactionHash: t.TxHash,
+		RawTransaction:  hex.EncodeToString(t.Marshal()),
+		Holder:          nreq.Holder,
+		Chain:           chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            "",
+		RequestId:       common.UniqueId(req.Id, gs.Address),
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	tx := &store.Transaction{
+		TransactionHash: st.TxHash,
+		RawTransaction:  hex.EncodeToString(raw),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            string(common.MarshalJSONOrPanic(recipients)),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	m := buildSafeMigration(req, safe, sp, tx)
+	holders := buildSafeHolders(nreq, chain, gs.Address, arp)
+	policy := buildSafePolicy(nreq, chain, arp.Policy, req.CreatedAt)
+	outflow := node.buildSafeMigrationOutflow(ctx, req, safe, tx, assetId, decimal.Zero)
+	err = node.store.WriteSafeMigrationWithRequest(ctx, m, sp, holders, policy, deploy, tx, nil, outflow, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) checkSafeMigrationPayment(ctx context.Context, req *common.Request, safe *store.Safe) bool {
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return false
+	}
+	return req.AssetId == plan.OperationPriceAsset && req.Amount.Cmp(plan.OperationPriceAmount) >= 0
+}
+
+// parseSafeMigrationRequest returns the request of the new holder to propose
+// the new safe, with the account proposal parsed from the extra
+func (node *Node) parseSafeMigrationRequest(ctx context.Context, req *common.Request) (*common.Request, *common.AccountProposal) {
+	extra := req.ExtraBytes()
+	if len(extra) <= 33 {
+		return nil, nil
+	}
+	nreq := *req
+	nreq.Holder = hex.EncodeToString(extra[:33])
+	err := nreq.VerifyFormat()
+	logger.Printf("req.VerifyFormat(%s) => %v", nreq.Holder, err)
+	if err != nil || nreq.Holder == req.Holder {
+		return nil, nil
+	}
+	safe, err := node.store.ReadSafe(ctx, nreq.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", nreq.Holder, err))
+	} else if safe != nil {
+		return nil, nil
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return nil, nil
+	}
+
+	rce := extra[33:]
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && bytes.Equal(ver.References[0][:], rce) {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := nreq.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", nreq, arp, err)
+	if err != nil {
+		return nil, nil
+	}
+	return &nreq, arp
+}
+
+func (node *Node) assignSafeMigrationKeys(ctx context.Context, nreq *common.Request, arp *common.AccountProposal) (string, string) {
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, nreq, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", nreq.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", nreq, err))
+	}
+	if signer == "" || observer == "" {
+		return "", ""
+	}
+	if arp.Observer != "" && arp.Observer != observer {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", nreq, arp, observer))
+	}
+	if !checkSafeKeysUnique(arp.Holders, signer, observer) {
+		return "", ""
+	}
+	return signer, observer
+}
+
+// buildSafeMigrationResponses tells the observer both the new safe proposal
+// and the sweep transaction proposal, so they could be approved as usual
+func (node *Node) buildSafeMigrationResponses(ctx context.Context, req *common.Request, chain byte, account, raw []byte, hash string) []*mtg.Transaction {
+	crv := common.SafeChainCurve(chain)
+	typ := byte(common.ActionBitcoinSafeProposeAccount)
+	switch crv {
+	case common.CurveSecp256k1ECDSABitcoin, common.CurveSecp256k1ECDSALitecoin, common.CurveSecp256k1ECDSADogecoin:
+	default:
+		typ = byte(common.ActionEthereumSafeProposeAccount)
+	}
+
+	astx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(account)))
+	if astx == nil {
+		return nil
+	}
+	at := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, astx.TraceId)
+	if at == nil {
+		return nil
+	}
+
+	tstx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(raw)))
+	if tstx == nil {
+		return nil
+	}
+	id := common.UniqueId(req.Id, hash)
+	tt := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ+2, crv, tstx.TraceId)
+	if tt == nil {
+		return nil
+	}
+	return []*mtg.Transaction{astx, at, tstx, tt}
+}
+
+// buildSafeMigrationOutflow locks the sweep transaction for the delay of the
+// active policy of the old safe, so a leaked holder key can't migrate all the
+// funds away immediately
+func (node *Node) buildSafeMigrationOutflow(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, assetId string, amount decimal.Decimal) *store.SafeOutflow {
+	sp, err := node.store.ReadActiveSafePolicy(ctx, safe.Holder, req.CreatedAt)
+	logger.Printf("store.ReadActiveSafePolicy(%s) => %v %v", safe.Holder, sp, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadActiveSafePolicy(%s) => %v", safe.Holder, err))
+	} else if sp == nil {
+		return nil
+	}
+	delay := time.Duration(sp.Parse().Delay) * time.Hour
+	return &store.SafeOutflow{
+		RequestId:       req.Id,
+		Holder:          safe.Holder,
+		TransactionHash: tx.TransactionHash,
+		AssetId:         assetId,
+		Amount:          amount,
+		UnlockAt:        req.CreatedAt.Add(delay),
+		CreatedAt:       req.CreatedAt,
+	}
+}
+
+func buildSafeMigration(req *common.Request, safe *store.Safe, sp *store.SafeProposal, tx *store.Transaction) *store.SafeMigration {
+	return &store.SafeMigration{
+		RequestId:       req.Id,
+		Chain:           safe.Chain,
+		Holder:          safe.Holder,
+		Address:         safe.Address,
+		NewHolder:       sp.Holder,
+		NewAddress:      sp.Address,
+		TransactionHash: tx.TransactionHash,
+		State:           common.RequestStatePending,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+}
+
+// checkSafeMigrationApproved returns false if the transaction is a migration
+// sweep and the new safe is not approved yet, the funds should never be
+// moved to a safe not ready to receive deposits
+func (node *Node) checkSafeMigrationApproved(ctx context.Context, hash string) bool {
+	m, err := node.store.ReadSafeMigrationByTransaction(ctx, hash)
+	logger.Printf("store.ReadSafeMigrationByTransaction(%s) => %v %v", hash, m, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeMigrationByTransaction(%s) => %v", hash, err))
+	} else if m == nil {
+		return true
+	}
+	safe, err := node.store.ReadSafeByAddress(ctx, m.NewAddress)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeByAddress(%s) => %v", m.NewAddress, err))
+	}
+	return safe != nil && safe.State == SafeStateApproved
+}
+
+// checkSafeMigrationDeposit returns true if the deposit is swept from the old
+// safe migrated to the safe, no safe assets are minted for the sweep because
+// the old safe assets are converted to the new safe assets
+func (node *Node) checkSafeMigrationDeposit(ctx context.Context, safe *store.Safe, sender string) bool {
+	m, err := node.store.ReadLatestSafeMigration(ctx, sender)
+	logger.Printf("store.ReadLatestSafeMigration(%s) => %v %v", sender, m, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestSafeMigration(%s) => %v", sender, err))
+	}
+	return m != nil && m.State == common.RequestStateDone && m.NewAddress == safe.Address
+}
+
+// The safe assets of the migrated safe are converted to the safe assets of the
+// new safe one to one, and paid to the receivers of the new safe. The request
+// holder is the old holder, and the request pays the old safe assets, which are
+// kept by the keeper so the old supply is burnt. The total conversion of each
+// asset never exceeds the amount swept to the new safe.
+func (node *Node) processSafeConvertAsset(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		if req.Action != common.ActionBitcoinSafeConvertAsset {
+			return node.failRequest(ctx, req, "")
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if req.Action != common.ActionEthereumSafeConvertAsset {
+			return node.failRequest(ctx, req, "")
+		}
+	default:
+		return node.failRequest(ctx, req, "")
+	}
+
+	m, err := node.store.ReadLatestSafeMigration(ctx, safe.Address)
+	logger.Printf("store.ReadLatestSafeMigration(%s) => %v %v", safe.Address, m, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestSafeMigration(%s) => %v", safe.Address, err))
+	} else if m == nil || m.State != common.RequestStateDone {
+		return node.failRequest(ctx, req, "")
+	}
+	neo, err := node.store.ReadSafe(ctx, m.NewHolder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", m.NewHolder, err))
+	} else if neo == nil || neo.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransaction(ctx, m.TransactionHash)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransaction(%s) => %v", m.TransactionHash, err))
+	}
+	var recipients []map[string]string
+	err = json.Unmarshal([]byte(tx.Data), &recipients)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransaction(%s) => %s", m.TransactionHash, tx.Data))
+	}
+
+	for _, r := range recipients {
+		assetId := tx.AssetId
+		if r["token"] != "" {
+			assetId = ethereum.GenerateAssetId(safe.Chain, r["token"])
+		}
+		entry := node.fetchBondAssetReceiver(ctx, safe.Address, assetId)
+		if node.getBondAssetId(ctx, entry, assetId, safe.Holder) != req.AssetId {
+			continue
+		}
+		converted, err := node.store.ReadSafeMigrationConvertedAmount(ctx, m.RequestId, req.AssetId)
+		logger.Printf("store.ReadSafeMigrationConvertedAmount(%s, %s) => %s %v", m.RequestId, req.AssetId, converted, err)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadSafeMigrationConvertedAmount(%s) => %v", m.RequestId, err))
+		}
+		swept := decimal.RequireFromString(r["amount"])
+		if converted.Add(req.Amount).Cmp(swept) > 0 {
+			return node.failRequest(ctx, req, "")
+		}
+
+		entry = node.fetchBondAssetReceiver(ctx, neo.Address, assetId)
+		safeAssetId := node.getBondAssetId(ctx, entry, assetId, neo.Holder)
+		logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, assetId, neo.Holder, safeAssetId)
+		t := node.buildTransaction(ctx, req.Output, neo.RequestId, safeAssetId, neo.Receivers, int(neo.Threshold), req.Amount.String(), nil, req.Id)
+		if t == nil {
+			return node.failRequest(ctx, req, safeAssetId)
+		}
+		c := &store.SafeMigrationConversion{
+			RequestId:   req.Id,
+			MigrationId: m.RequestId,
+			AssetId:     req.AssetId,
+			Amount:      req.Amount,
+			CreatedAt:   req.CreatedAt,
+		}
+		err = node.store.WriteSafeMigrationConversionWithRequest(ctx, c, []*mtg.Transaction{t}, req)
+		if err != nil {
+			panic(err)
+		}
+		return []*mtg.Transaction{t}, ""
+	}
+	return node.failRequest(ctx, req, "")
+}
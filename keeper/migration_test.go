@@ -0,0 +1,562 @@
+package keeper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	gc "github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testMigrationHolderPrivates = []string{
+		"849cccf4c397ee0b6526f92d660cee6fa798eb70a56084973b7460c72a2debce",
+		"07d20e044cf7ee5c6063ed43a3fd89fe0be4630c39c126c44f0243b430db678e",
+	}
+	testMigrationSignerPrivates = []string{
+		"916247f08fbcc27408f7bc24ff4434b204d9193ae3583032c2a58a20b4b6bc04",
+		"87426df34b2f293165e6a44eacf5883c5859e3d6ba0b746537dcea14dc0ddfa2",
+	}
+	testMigrationObserverPrivates = []string{
+		"b19fecaf8be4cb443e4a353a46fc823cd51e5db9afa0706aa1af45e022e49935",
+		"e8f47195b4277d89e192660148ee54b2dabedc52718e04bfe94383259539e12e",
+	}
+)
+
+func TestBitcoinKeeperMigrateAccount(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, signers := testPrepare(require)
+	bondId := testPrepareBond(ctx, require, node, db)
+	deposits := testPrepareDeposits(ctx, require, node, mpc, 86560, 100000)
+	first, second := deposits[0], deposits[1]
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+
+	// no spare keys for the new safe
+	newPriv := testMigrationHolderPrivates[0]
+	newHolder := testPublicKey(newPriv)
+	rid := testMigrateAccount(ctx, require, node, holder, newHolder, common.ActionBitcoinSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateFailed)
+	m, err := node.store.ReadLatestSafeMigration(ctx, testSafeAddress)
+	require.Nil(err)
+	require.Nil(m)
+
+	newSigner, newObserver := testMigrationSpareKeys(ctx, require, node, 0, common.CurveSecp256k1ECDSABitcoin)
+	rid = testMigrateAccount(ctx, require, node, holder, newHolder, common.ActionBitcoinSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveSecp256k1ECDSABitcoin)
+
+	sp, err := node.store.ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	require.Equal(newHolder, sp.Holder)
+	require.Equal(newSigner, sp.Signer)
+	require.Equal(newObserver, sp.Observer)
+	wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, []string{newHolder}, 1, newSigner, newObserver, bitcoinDefaultDerivationPath(), testTimelockDuration, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(wsa.Address, sp.Address)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionBitcoinSafeProposeAccount)
+	account, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.Equal(sp.Address, account.Address)
+
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Equal(holder, tx.Holder)
+	require.Equal(common.RequestStateInitial, tx.State)
+	b = testReadObserverResponse(ctx, require, node, common.UniqueId(rid, tx.TransactionHash), common.ActionBitcoinSafeProposeTransaction)
+	require.Equal(common.DecodeHexOrPanic(tx.RawTransaction), b)
+	psTx, err := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	require.Equal(tx.TransactionHash, psTx.Hash())
+	msgTx := psTx.UnsignedTx
+	require.Len(msgTx.TxIn, 2)
+	inputs := map[string]bool{}
+	for _, in := range msgTx.TxIn {
+		inputs[in.PreviousOutPoint.Hash.String()] = true
+	}
+	require.True(inputs[first.TransactionHash])
+	require.True(inputs[second.TransactionHash])
+	addr, err := btcutil.DecodeAddress(sp.Address, bitcoin.NetConfig(common.SafeChainBitcoin))
+	require.Nil(err)
+	script, err := txscript.PayToAddrScript(addr)
+	require.Nil(err)
+	require.Len(msgTx.TxOut, 2)
+	require.Equal(script, msgTx.TxOut[0].PkScript)
+	require.Equal(first.Satoshi+second.Satoshi, msgTx.TxOut[0].Value)
+	require.Equal(int64(0), msgTx.TxOut[1].Value)
+	require.True(txscript.IsNullData(msgTx.TxOut[1].PkScript))
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 2)
+
+	m, err = node.store.ReadLatestSafeMigration(ctx, testSafeAddress)
+	require.Nil(err)
+	require.Equal(rid, m.RequestId)
+	require.Equal(sp.Address, m.NewAddress)
+	require.Equal(tx.TransactionHash, m.TransactionHash)
+	require.Equal(common.RequestStatePending, m.State)
+	outflow, err := node.store.ReadSafeOutflow(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Nil(outflow)
+
+	// the old safe assets could not be converted before the sweep
+	cid := testConvertSafeAsset(ctx, require, node, holder, common.ActionBitcoinSafeConvertAsset, bondId, decimal.RequireFromString("0.001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateFailed)
+
+	// the new safe is not approved yet
+	id := testMigrationApproveBitcoinSweep(ctx, require, node, tx.TransactionHash, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	testMigrationApproveBitcoinAccount(ctx, require, node, newPriv, rid, sp.Address)
+	neo, err := node.store.ReadSafe(ctx, newHolder)
+	require.Nil(err)
+	require.Equal(byte(SafeStateApproved), neo.State)
+	require.Equal(sp.Address, neo.Address)
+
+	id = testMigrationApproveBitcoinSweep(ctx, require, node, tx.TransactionHash, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+
+	// the old safe is closed only after all inputs signed
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 2)
+	for idx := range len(requests) {
+		r := requests[idx]
+		msg := common.DecodeHexOrPanic(r.Message)
+		out := testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveSecp256k1ECDSABitcoin)
+		op := signer.TestProcessOutput(ctx, require, signers, out, r.RequestId)
+		out = testBuildSignerOutput(node, r.RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveSecp256k1ECDSABitcoin)
+		testStep(ctx, require, node, out)
+
+		old, _ := node.store.ReadSafe(ctx, holder)
+		m, _ = node.store.ReadLatestSafeMigration(ctx, testSafeAddress)
+		if idx+1 < len(requests) {
+			require.Equal(byte(SafeStateApproved), old.State)
+			require.Equal(common.RequestStatePending, m.State)
+			continue
+		}
+		require.Equal(byte(SafeStateClosed), old.State)
+		require.Equal(common.RequestStateDone, m.State)
+	}
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateDone, tx.State)
+
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStateDone)
+	signed := make(map[int][]byte)
+	for _, r := range requests {
+		signed[r.InputIndex] = common.DecodeHexOrPanic(r.Signature.String)
+	}
+	msgTx = node.testSignerHolderApproveTransaction(ctx, require, tx.RawTransaction, signed, safe.Signer, safe.Path)
+	for idx, pin := range psTx.Inputs {
+		pof := txscript.NewCannedPrevOutputFetcher(pin.WitnessUtxo.PkScript, pin.WitnessUtxo.Value)
+		engine, err := txscript.NewEngine(pin.WitnessUtxo.PkScript, msgTx, idx, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), pin.WitnessUtxo.Value, pof)
+		require.Nil(err)
+		require.Nil(engine.Execute())
+	}
+	raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, bitcoin.ChainBitcoin)
+	require.Nil(err)
+	hash, err := testNetwork.bitcoin.SendRawTransaction(hex.EncodeToString(raw))
+	require.Nil(err)
+	testNetwork.bitcoin.Mine(1)
+	testUpdateBitcoinNetworkTip(ctx, require, node)
+
+	// the sweep deposit to the new safe mints no new safe assets
+	newBondId := testDeployBondContract(ctx, require, node, sp.Address, common.SafeBitcoinChainId)
+	require.NotEqual(bondId, newBondId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, newBondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(1000000))
+	require.Nil(err)
+	node.ProcessOutput(ctx, &mtg.Action{UnifiedOutput: *output})
+	input := &bitcoin.Input{TransactionHash: hash, Index: 0, Satoshi: first.Satoshi + second.Satoshi}
+	id = testHoldersBitcoinDeposit(ctx, require, node, newHolder, input)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	v, err := node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	require.Equal("", v)
+
+	// the old safe assets are converted to the new safe assets, never more than swept
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionBitcoinSafeConvertAsset, bondId, decimal.RequireFromString("0.001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateDone)
+	testCheckConvertedSafeAsset(ctx, require, node, cid, newBondId, "0.001")
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionBitcoinSafeConvertAsset, bondId, decimal.RequireFromString("0.001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateFailed)
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionBitcoinSafeConvertAsset, newBondId, decimal.RequireFromString("0.0008656"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateFailed)
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionBitcoinSafeConvertAsset, bondId, decimal.RequireFromString("0.0008656"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateDone)
+	testCheckConvertedSafeAsset(ctx, require, node, cid, newBondId, "0.0008656")
+	converted, err := node.store.ReadSafeMigrationConvertedAmount(ctx, rid, bondId)
+	require.Nil(err)
+	require.Equal("0.0018656", converted.String())
+}
+
+func TestBitcoinKeeperMigrateAccountDelay(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+	testPrepareBond(ctx, require, node, db)
+	testPrepareDeposits(ctx, require, node, mpc, 100000)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	policy := []byte(`{"delay":2}`)
+	id := uuid.Must(uuid.NewV4()).String()
+	ms := common.SafePolicyMessage(safe.RequestId, safe.Address, id, policy)
+	update := &common.SafePolicyUpdate{Policy: policy, Signature: testBitcoinSignMessage(testBitcoinKeyHolderPrivate, ms)}
+	testUpdateSafePolicy(ctx, require, node, db, holder, id, update)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+
+	newPriv := testMigrationHolderPrivates[0]
+	testMigrationSpareKeys(ctx, require, node, 0, common.CurveSecp256k1ECDSABitcoin)
+	rid := testMigrateAccount(ctx, require, node, holder, testPublicKey(newPriv), common.ActionBitcoinSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+	sp, err := node.store.ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	outflow, err := node.store.ReadSafeOutflow(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(rid, outflow.RequestId)
+	require.Equal(tx.CreatedAt.Add(2*time.Hour), outflow.UnlockAt)
+	testMigrationApproveBitcoinAccount(ctx, require, node, newPriv, rid, sp.Address)
+
+	// the sweep is locked by the policy delay of the old safe
+	id = testMigrationApproveBitcoinSweep(ctx, require, node, tx.TransactionHash, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	id = testMigrationApproveBitcoinSweep(ctx, require, node, tx.TransactionHash, time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	id = testMigrationApproveBitcoinSweep(ctx, require, node, tx.TransactionHash, 3*time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, tx.TransactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+}
+
+func TestBitcoinKeeperMigrateAccountRevokeExpire(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+	testPrepareBond(ctx, require, node, db)
+	testPrepareDeposits(ctx, require, node, mpc, 100000)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	testMigrationSpareKeys(ctx, require, node, 0, common.CurveSecp256k1ECDSABitcoin)
+	rid := testMigrateAccount(ctx, require, node, holder, testPublicKey(testMigrationHolderPrivates[0]), common.ActionBitcoinSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	outflow, err := node.store.ReadSafeOutflow(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Nil(outflow)
+
+	testSafeRevokeTransaction(ctx, require, node, tx.TransactionHash, false)
+	m, err := node.store.ReadSafeMigrationByTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, m.State)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(SafeStateApproved), safe.State)
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+
+	testMigrationSpareKeys(ctx, require, node, 1, common.CurveSecp256k1ECDSABitcoin)
+	rid = testMigrateAccount(ctx, require, node, holder, testPublicKey(testMigrationHolderPrivates[1]), common.ActionBitcoinSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 1)
+
+	id := testSafeExpireTransaction(ctx, require, node, holder, rid, 0)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	id = testSafeExpireTransaction(ctx, require, node, holder, rid, SafeProposalExpiryMaximum+time.Hour)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	tx, _ = node.store.ReadTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateFailed, tx.State)
+	m, err = node.store.ReadSafeMigrationByTransaction(ctx, tx.TransactionHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, m.State)
+	safe, err = node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(SafeStateApproved), safe.State)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+}
+
+func TestEthereumKeeperMigrateAccount(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, signers := testEthereumPrepare(require)
+
+	testEthereumPrepareBond(ctx, require, node, db, testEthereumBondAssetId)
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+	usdtBondId := testDeployBondContract(ctx, require, node, testEthereumSafeAddress, testEthereumUSDTAssetId)
+	require.Equal(testEthereumUSDTBondAssetId, usdtBondId)
+	testEthereumPrepareBond(ctx, require, node, db, usdtBondId)
+	testEthereumObserverHolderDeposit(ctx, require, node, testEthereumUSDTAssetId, testEthereumUSDTAddress, "100")
+
+	holder := testEthereumPublicKey(testEthereumKeyHolder)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	newPriv := testMigrationHolderPrivates[0]
+	newHolder := testEthereumPublicKey(newPriv)
+	newSigner, newObserver := testMigrationSpareKeys(ctx, require, node, 0, common.CurveSecp256k1ECDSAEthereum)
+	rid := testMigrateAccount(ctx, require, node, holder, newHolder, common.ActionEthereumSafeMigrateAccount)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateDone)
+
+	sp, err := node.store.ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	require.Equal(newHolder, sp.Holder)
+	require.Equal(newSigner, sp.Signer)
+	require.Equal(newObserver, sp.Observer)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionEthereumSafeProposeAccount)
+	gs, err := ethereum.UnmarshalGnosisSafe(b)
+	require.Nil(err)
+	require.Equal(sp.Address, gs.Address)
+
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid)
+	require.Nil(err)
+	require.Equal(holder, tx.Holder)
+	b = testReadObserverResponse(ctx, require, node, common.UniqueId(rid, tx.TransactionHash), common.ActionEthereumSafeProposeTransaction)
+	require.Equal(common.DecodeHexOrPanic(tx.RawTransaction), b)
+	st, err := ethereum.UnmarshalSafeTransaction(b)
+	require.Nil(err)
+	require.Equal(testEthereumSafeAddress, st.SafeAddress)
+	require.Equal(ethereum.EthereumMultiSendAddress, st.Destination.Hex())
+	require.Equal(safe.Nonce, st.Nonce.Int64())
+	outputs := st.ExtractOutputs()
+	require.Len(outputs, 2)
+	amounts := map[string]string{}
+	for _, o := range outputs {
+		require.Equal(gs.Address, o.Destination)
+		amounts[o.TokenAddress] = o.Amount.String()
+	}
+	require.Equal("100000000000000", amounts[ethereum.EthereumEmptyAddress])
+	require.Equal("100", amounts[testEthereumUSDTAddress])
+	var recipients []map[string]string
+	err = json.Unmarshal([]byte(tx.Data), &recipients)
+	require.Nil(err)
+	require.Len(recipients, 2)
+
+	// the new safe is not approved yet
+	_, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
+	id := testMigrationApproveEthereumSweep(ctx, require, node, holder, rid, st, pubs)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	testMigrationApproveEthereumAccount(ctx, require, node, newPriv, testMigrationSignerPrivates[0], rid, gs)
+	testEthereumDeploySafe(require, gs.Address, sp.Timelock)
+
+	id = testMigrationApproveEthereumSweep(ctx, require, node, holder, rid, st, pubs)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	old, _ := node.store.ReadSafe(ctx, holder)
+	require.Equal(byte(SafeStateApproved), old.State)
+	m, _ := node.store.ReadSafeMigrationByTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStatePending, m.State)
+	testHoldersEthereumSign(ctx, require, node, signers, tx.TransactionHash, mpc)
+	old, _ = node.store.ReadSafe(ctx, holder)
+	require.Equal(byte(SafeStateClosed), old.State)
+	require.Equal(safe.Nonce+1, old.Nonce)
+	m, _ = node.store.ReadSafeMigrationByTransaction(ctx, tx.TransactionHash)
+	require.Equal(common.RequestStateDone, m.State)
+	balances, err := node.store.ReadAllEthereumTokenBalances(ctx, testEthereumSafeAddress)
+	require.Nil(err)
+	for _, b := range balances {
+		require.Equal(int64(0), b.BigBalance().Int64())
+	}
+
+	// the sweep deposits to the new safe mint no new safe assets
+	for _, d := range []struct{ assetId, assetAddress, amount string }{
+		{common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000"},
+		{testEthereumUSDTAssetId, testEthereumUSDTAddress, "100"},
+	} {
+		id = testEthereumObserverSafeDepositFrom(ctx, require, node, testEthereumSafeAddress, newHolder, gs.Address, d.assetId, d.assetAddress, d.amount)
+		testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+		v, err := node.store.ReadProperty(ctx, id)
+		require.Nil(err)
+		require.Equal("", v)
+	}
+
+	newBondId := testDeployBondContract(ctx, require, node, gs.Address, common.SafePolygonChainId)
+	newUSDTBondId := testDeployBondContract(ctx, require, node, gs.Address, testEthereumUSDTAssetId)
+	require.NotEqual(testEthereumBondAssetId, newBondId)
+	require.NotEqual(usdtBondId, newUSDTBondId)
+	for _, id := range []string{newBondId, newUSDTBondId} {
+		sequence += 10
+		testEthereumPrepareBond(ctx, require, node, db, id)
+	}
+	cid := testConvertSafeAsset(ctx, require, node, holder, common.ActionEthereumSafeConvertAsset, usdtBondId, decimal.RequireFromString("0.0001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateDone)
+	testCheckConvertedSafeAsset(ctx, require, node, cid, newUSDTBondId, "0.0001")
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionEthereumSafeConvertAsset, usdtBondId, decimal.RequireFromString("0.0001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateFailed)
+	cid = testConvertSafeAsset(ctx, require, node, holder, common.ActionEthereumSafeConvertAsset, testEthereumBondAssetId, decimal.RequireFromString("0.0001"))
+	testCheckRequestState(ctx, require, node, cid, common.RequestStateDone)
+	testCheckConvertedSafeAsset(ctx, require, node, cid, newBondId, "0.0001")
+}
+
+// testMigrationSpareKeys adds the spare signer and observer keys, which are
+// assigned to the new safe of the next migration. The key requests are dated
+// back by the backup maturity, so they are not the latest request to step
+func testMigrationSpareKeys(ctx context.Context, require *require.Assertions, node *Node, index int, crv byte) (string, string) {
+	signer := testPublicKey(testMigrationSignerPrivates[index])
+	chainCode := common.DecodeHexOrPanic(testBitcoinKeyObserverChainCode)
+	extra := append([]byte{common.RequestRoleSigner}, chainCode...)
+	extra = append(extra, common.RequestFlagNone)
+	out := testBuildSignerOutput(node, uuid.Must(uuid.NewV4()).String(), signer, common.OperationTypeKeygenOutput, extra, crv)
+	node.ProcessOutput(ctx, out)
+
+	observer := testPublicKey(testMigrationObserverPrivates[index])
+	if crv != common.CurveSecp256k1ECDSABitcoin {
+		chainCode = make([]byte, 32)
+	}
+	extra = append([]byte{common.RequestRoleObserver}, chainCode...)
+	extra = append(extra, common.RequestFlagNone)
+	out = testBuildObserverRequest(node, uuid.Must(uuid.NewV4()).String(), observer, common.ActionObserverAddKey, extra, crv)
+	node.ProcessOutput(ctx, out)
+	testSpareKeys(ctx, require, node, 0, 1, 1, crv)
+	return signer, observer
+}
+
+func testMigrateAccount(ctx context.Context, require *require.Assertions, node *Node, holder, newHolder string, action byte) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := common.DecodeHexOrPanic(newHolder)
+	extra = append(extra, testRecipient()...)
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequest(node, id, holder, action, testAccountPriceAssetId, extra, price)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+func testMigrationApproveBitcoinAccount(ctx context.Context, require *require.Assertions, node *Node, priv, rid, address string) {
+	holder := testPublicKey(priv)
+	ms := fmt.Sprintf("APPROVE:%s:%s", rid, address)
+	sig := testBitcoinSignMessage(priv, ms)
+	id := testHoldersApproveAccount(ctx, require, node, holder, rid, sig, common.ActionBitcoinSafeApproveAccount, common.CurveSecp256k1ECDSABitcoin)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionBitcoinSafeApproveAccount)
+	wsa, err := bitcoin.UnmarshalWitnessScriptAccount(b)
+	require.Nil(err)
+	require.Equal(address, wsa.Address)
+}
+
+// testMigrationApproveEthereumAccount approves the deploy transaction of the
+// new safe with the new holder, and signs it with the spare signer key
+func testMigrationApproveEthereumAccount(ctx context.Context, require *require.Assertions, node *Node, priv, signerPriv, rid string, gs *ethereum.GnosisSafe) {
+	holder := testEthereumPublicKey(priv)
+	tx, err := node.store.ReadTransaction(ctx, gs.TxHash)
+	require.Nil(err)
+	t, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	sig := testEthereumSignMessage(require, priv, t.Message)
+	testHoldersApproveAccount(ctx, require, node, holder, rid, sig, common.ActionEthereumSafeApproveAccount, common.CurveSecp256k1ECDSAPolygon)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, gs.TxHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+
+	key, err := gc.HexToECDSA(signerPriv)
+	require.Nil(err)
+	sig, err = gc.Sign(common.DecodeHexOrPanic(requests[0].Message), key)
+	require.Nil(err)
+	sp, err := node.store.ReadSafeProposal(ctx, rid)
+	require.Nil(err)
+	out := testBuildSignerOutput(node, requests[0].RequestId, sp.Signer, common.OperationTypeSignOutput, sig, common.CurveSecp256k1ECDSAEthereum)
+	testStep(ctx, require, node, out)
+
+	id := common.UniqueId(requests[0].RequestId, gs.Address)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionEthereumSafeApproveAccount)
+	rgs, err := ethereum.UnmarshalGnosisSafe(b)
+	require.Nil(err)
+	require.Equal(gs.Address, rgs.Address)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(byte(SafeStateApproved), safe.State)
+	require.Equal(gs.Address, safe.Address)
+}
+
+// testMigrationApproveBitcoinSweep approves the sweep transaction with the old
+// holder signature, and the request is created after the delay
+func testMigrationApproveBitcoinSweep(ctx context.Context, require *require.Assertions, node *Node, hash string, delay time.Duration) string {
+	tx, err := node.store.ReadTransaction(ctx, hash)
+	require.Nil(err)
+	psTx := testHoldersSignBitcoinTransaction(tx.RawTransaction, []string{testBitcoinKeyHolderPrivate})
+	ref := testWriteStorageRaw(ctx, require, node, psTx.Marshal())
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id, tx.Holder, common.ActionBitcoinSafeApproveTransaction, extra, common.CurveSecp256k1ECDSABitcoin)
+	out.SequencerCreatedAt = out.SequencerCreatedAt.Add(delay)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+// testMigrationApproveEthereumSweep approves the sweep transaction with the
+// old holder signature, the same raw could be approved multiple times
+func testMigrationApproveEthereumSweep(ctx context.Context, require *require.Assertions, node *Node, holder, rid string, st *ethereum.SafeTransaction, pubs []string) string {
+	st.Signatures = nil
+	for i, pub := range pubs {
+		if pub == holder {
+			st.SetSignature(i, testEthereumSignMessage(require, testEthereumKeyHolder, st.Message))
+		}
+	}
+	ref := testWriteStorageRaw(ctx, require, node, st.Marshal())
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := uuid.Must(uuid.FromString(rid)).Bytes()
+	extra = append(extra, ref[:]...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionEthereumSafeApproveTransaction, extra, common.CurveSecp256k1ECDSAPolygon)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+func testWriteStorageRaw(ctx context.Context, require *require.Assertions, node *Node, raw []byte) crypto.Hash {
+	ref := crypto.Sha256Hash(raw)
+	v, err := node.store.ReadProperty(ctx, ref.String())
+	require.Nil(err)
+	if v == "" {
+		err = node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+		require.Nil(err)
+	}
+	return ref
+}
+
+func testConvertSafeAsset(ctx context.Context, require *require.Assertions, node *Node, holder string, action byte, assetId string, amount decimal.Decimal) string {
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildHolderRequest(node, id, holder, action, assetId, nil, amount)
+	testStep(ctx, require, node, out)
+	return id
+}
+
+func testCheckConvertedSafeAsset(ctx context.Context, require *require.Assertions, node *Node, id, assetId, amount string) {
+	v, err := node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	var om map[string]any
+	err = json.Unmarshal([]byte(v), &om)
+	require.Nil(err)
+	require.Equal(assetId, om["asset_id"])
+	require.Equal(amount, om["amount"])
+	require.Equal([]any{testSafeBondReceiverId}, om["receivers"])
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/MixinNetwork/trusted-group/mtg"
@@ -59,7 +60,7 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 		panic(req.Id)
 	}
 	switch info.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		info.Hash = hex.EncodeToString(extra[17:])
 		valid, err := node.verifyBitcoinNetworkInfo(info, old)
 		logger.Printf("node.verifyBitcoinNetworkInfo(%s, %v) => %t", req.Id, info, valid)
@@ -77,6 +78,18 @@ func (node *Node) writeNetworkInfo(ctx context.Context, req *common.Request) ([]
 		} else if !valid {
 			return node.failRequest(ctx, req, "")
 		}
+	case common.SafeChainSolana:
+		if len(extra[17:]) != 32 {
+			return node.failRequest(ctx, req, "")
+		}
+		info.Hash = solana.Hash(extra[17:]).String()
+		valid, err := node.verifySolanaNetworkInfo(info, old)
+		logger.Printf("node.verifySolanaNetworkInfo(%s, %v) => %t", req.Id, info, valid)
+		if err != nil {
+			panic(fmt.Errorf("node.verifySolanaNetworkInfo(%v) => %v", info, err))
+		} else if !valid {
+			return node.failRequest(ctx, req, "")
+		}
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -93,7 +106,7 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 		panic(req.Role)
 	}
 	extra := req.ExtraBytes()
-	if len(extra) != 33 {
+	if len(extra) != 33 && len(extra) != 41 {
 		return node.failRequest(ctx, req, "")
 	}
 
@@ -104,8 +117,10 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 	switch chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
+	case common.SafeChainDogecoin:
 	case common.SafeChainEthereum:
 	case common.SafeChainPolygon:
+	case common.SafeChainSolana:
 	default:
 		return node.failRequest(ctx, req, "")
 	}
@@ -115,12 +130,24 @@ func (node *Node) writeOperationParams(ctx context.Context, req *common.Request)
 	amount := decimal.NewFromBigInt(abu, -8)
 	mbu := new(big.Int).SetUint64(binary.BigEndian.Uint64(extra[25:33]))
 	minimum := decimal.NewFromBigInt(mbu, -8)
+	var expiry time.Duration
+	if len(extra) == 41 {
+		seconds := binary.BigEndian.Uint64(extra[33:41])
+		if seconds > uint64(SafeProposalExpiryMaximum/time.Second) {
+			return node.failRequest(ctx, req, "")
+		}
+		expiry = time.Duration(seconds) * time.Second
+		if expiry < SafeProposalExpiryMinimum {
+			return node.failRequest(ctx, req, "")
+		}
+	}
 	params := &store.OperationParams{
 		RequestId:            req.Id,
 		Chain:                chain,
 		OperationPriceAsset:  assetId.String(),
 		OperationPriceAmount: amount,
 		TransactionMinimum:   minimum,
+		ProposalExpiry:       expiry,
 		CreatedAt:            req.CreatedAt,
 	}
 	err := node.store.WriteOperationParamsFromRequest(ctx, params, req)
@@ -184,6 +211,8 @@ func (node *Node) bitcoinParams(chain byte) (string, string) {
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainDogecoin:
+		return node.conf.DogecoinRPC, common.SafeDogecoinChainId
 	default:
 		panic(chain)
 	}
@@ -205,14 +234,20 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
+	var token *ethereum.Asset
 	switch chain {
-	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		rpc, _ := node.ethereumParams(chain)
+		token, err = ethereum.FetchAsset(chain, rpc, assetContract)
+	case common.SafeChainSolana:
+		var st *solana.Asset
+		st, err = solana.FetchAsset(node.conf.SolanaRPC, assetContract)
+		if st != nil {
+			token = (*ethereum.Asset)(st)
+		}
 	default:
 		panic(chain)
 	}
-	rpc, _ := node.ethereumParams(chain)
-	token, err := ethereum.FetchAsset(chain, rpc, assetContract)
 	if err != nil {
 		return nil, err
 	}
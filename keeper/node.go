@@ -9,6 +9,7 @@ import (
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -24,7 +25,7 @@ type Node struct {
 	signerAESKey   [32]byte
 	observerAESKey [32]byte
 	store          *store.SQLite3Store
-	mixin          *mixin.Client
+	mixin          common.UsersReader
 }
 
 func NewNode(store *store.SQLite3Store, group *mtg.Group, conf *Configuration, signer *mtg.Configuration, mixin *mixin.Client) *Node {
@@ -38,6 +39,9 @@ func NewNode(store *store.SQLite3Store, group *mtg.Group, conf *Configuration, s
 	node.observerAESKey = common.ECDHEd25519(conf.SharedKey, conf.ObserverPublicKey)
 	node.mixin = mixin
 	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	if conf.SolanaProgram != "" {
+		solana.SetSafeProgram(conf.SolanaProgram)
+	}
 	return node
 }
 
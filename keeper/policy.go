@@ -0,0 +1,194 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// The holder updates the safe policy with the operation price paid, and the
+// extra is the encoded policy update or the hash of the storage transaction
+// referenced. The policy must be signed by the holders with the threshold of
+// the safe for this request id, and becomes active after the delay of the current active policy,
+// so a leaked holder key can't lift the limits immediately.
+func (node *Node) processSafeUpdatePolicy(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(extra) == 32 && len(ver.References) == 1 && ver.References[0].String() == req.ExtraHEX {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		extra = stx.Extra
+	}
+	update, err := common.DecodeSafePolicyUpdate(extra)
+	logger.Printf("common.DecodeSafePolicyUpdate(%x) => %v %v", extra, update, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.verifySafePolicySignatures(ctx, safe, req.Id, update)
+	logger.Printf("node.verifySafePolicySignatures(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	policy, err := common.ParseSafePolicy(update.Policy, chain)
+	logger.Printf("common.ParseSafePolicy(%x) => %v %v", update.Policy, policy, err)
+	if err != nil {
+		return node.failRequestWithPolicyViolation(ctx, req, safe, err.Error())
+	}
+
+	activeAt := req.CreatedAt
+	old, err := node.store.ReadActiveSafePolicy(ctx, safe.Holder, req.CreatedAt)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadActiveSafePolicy(%s) => %v", safe.Holder, err))
+	} else if old != nil {
+		activeAt = activeAt.Add(time.Duration(old.Parse().Delay) * time.Hour)
+	}
+
+	sp := buildSafePolicy(req, chain, policy, activeAt)
+	err = node.store.WriteSafePolicyWithRequest(ctx, sp, nil, req)
+	if err != nil {
+		panic(err)
+	}
+	return nil, ""
+}
+
+func (node *Node) verifySafePolicySignatures(ctx context.Context, safe *store.Safe, nonce string, update *common.SafePolicyUpdate) error {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	ms := common.SafePolicyMessage(safe.RequestId, safe.Address, nonce, update.Policy)
+	var verify func(public string, sig []byte) error
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		msg := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		verify = func(public string, sig []byte) error {
+			return bitcoin.VerifySignatureDER(public, msg, sig)
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		verify = func(public string, sig []byte) error {
+			if len(sig) != 65 {
+				return fmt.Errorf("invalid policy signature %x", sig)
+			}
+			return ethereum.VerifyMessageSignature(public, []byte(ms), sig)
+		}
+	default:
+		return fmt.Errorf("invalid policy chain %d", safe.Chain)
+	}
+	_, err := verifySafeApprovalSignatures(holders, threshold, update.Signature, verify)
+	return err
+}
+
+func buildSafePolicy(req *common.Request, chain byte, policy *common.SafePolicy, activeAt time.Time) *store.SafePolicy {
+	if policy == nil {
+		return nil
+	}
+	return &store.SafePolicy{
+		RequestId: req.Id,
+		Holder:    req.Holder,
+		Chain:     chain,
+		Policy:    string(common.MarshalJSONOrPanic(policy)),
+		ActiveAt:  activeAt,
+		CreatedAt: req.CreatedAt,
+	}
+}
+
+// evaluateSafePolicy checks the normal transaction proposal against the active
+// policy of the safe, and returns the outflow to record with the transaction
+func (node *Node) evaluateSafePolicy(ctx context.Context, req *common.Request, safe *store.Safe, assetId string, destinations []string, amount decimal.Decimal) (*store.SafeOutflow, error) {
+	outflow := &store.SafeOutflow{
+		RequestId: req.Id,
+		Holder:    safe.Holder,
+		AssetId:   assetId,
+		Amount:    amount,
+		UnlockAt:  req.CreatedAt,
+		CreatedAt: req.CreatedAt,
+	}
+	sp, err := node.store.ReadActiveSafePolicy(ctx, safe.Holder, req.CreatedAt)
+	logger.Printf("store.ReadActiveSafePolicy(%s) => %v %v", safe.Holder, sp, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadActiveSafePolicy(%s) => %v", safe.Holder, err))
+	} else if sp == nil {
+		return outflow, nil
+	}
+
+	daily, err := node.store.ReadSafeOutflowTotal(ctx, safe.Holder, assetId, req.CreatedAt.Add(-24*time.Hour))
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeOutflowTotal(%s, %s) => %v", safe.Holder, assetId, err))
+	}
+	weekly, err := node.store.ReadSafeOutflowTotal(ctx, safe.Holder, assetId, req.CreatedAt.Add(-7*24*time.Hour))
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeOutflowTotal(%s, %s) => %v", safe.Holder, assetId, err))
+	}
+	delay, err := sp.Parse().Evaluate(assetId, destinations, amount, daily, weekly, safe.Chain)
+	logger.Printf("SafePolicy.Evaluate(%s, %v, %s, %s, %s) => %s %v", assetId, destinations, amount, daily, weekly, delay, err)
+	if err != nil {
+		return nil, err
+	}
+	outflow.UnlockAt = req.CreatedAt.Add(delay)
+	return outflow, nil
+}
+
+// checkSafeOutflowLocked returns true if the transaction is still delayed by
+// the policy, then the observer should approve it again after unlocked
+func (node *Node) checkSafeOutflowLocked(ctx context.Context, req *common.Request, hash string) bool {
+	outflow, err := node.store.ReadSafeOutflow(ctx, hash)
+	logger.Printf("store.ReadSafeOutflow(%s) => %v %v", hash, outflow, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeOutflow(%s) => %v", hash, err))
+	}
+	return outflow != nil && req.CreatedAt.Before(outflow.UnlockAt)
+}
+
+func (node *Node) failRequestWithPolicyViolation(ctx context.Context, req *common.Request, safe *store.Safe, reason string) ([]*mtg.Transaction, string) {
+	logger.Printf("node.failRequestWithPolicyViolation(%v) => %s", req, reason)
+	t := node.buildTransaction(ctx, req.Output, node.conf.AppId, req.AssetId, safe.Receivers, int(safe.Threshold), req.Amount.String(), []byte("refund"), req.Id)
+	if t == nil {
+		return node.failRequest(ctx, req, req.AssetId)
+	}
+	err := node.store.FailRequestWithPolicyViolation(ctx, req, reason, []*mtg.Transaction{t})
+	if err != nil {
+		panic(err)
+	}
+	return []*mtg.Transaction{t}, ""
+}
+
+func safePolicyDestinations(recipients []map[string]string) []string {
+	destinations := make([]string, len(recipients))
+	for i, r := range recipients {
+		destinations[i] = r["receiver"]
+	}
+	return destinations
+}
@@ -0,0 +1,113 @@
+package keeper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperUpdatePolicy(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, _, _ := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	policy := []byte(`{"allowlist":["` + testTransactionReceiver + `"]}`)
+	id := uuid.Must(uuid.NewV4()).String()
+	ms := common.SafePolicyMessage(safe.RequestId, safe.Address, id, policy)
+
+	update := &common.SafePolicyUpdate{Policy: policy}
+	rid := uuid.Must(uuid.NewV4()).String()
+	testUpdateSafePolicy(ctx, require, node, db, holder, rid, update)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateFailed)
+	sp, err := node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Nil(sp)
+
+	update.Signature = testBitcoinSignMessage(testBitcoinKeyDummyHolderPrivate, ms)
+	rid = uuid.Must(uuid.NewV4()).String()
+	testUpdateSafePolicy(ctx, require, node, db, holder, rid, update)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateFailed)
+	sp, err = node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Nil(sp)
+
+	update.Signature = testBitcoinSignMessage(testBitcoinKeyHolderPrivate, ms)
+	update.Policy = []byte(`{}`)
+	rid = uuid.Must(uuid.NewV4()).String()
+	testUpdateSafePolicy(ctx, require, node, db, holder, rid, update)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateFailed)
+	sp, err = node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Nil(sp)
+
+	update.Policy = policy
+	rid = uuid.Must(uuid.NewV4()).String()
+	testUpdateSafePolicy(ctx, require, node, db, holder, rid, update)
+	testCheckRequestState(ctx, require, node, rid, common.RequestStateFailed)
+	sp, err = node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Nil(sp)
+
+	testUpdateSafePolicy(ctx, require, node, db, holder, id, update)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateDone)
+	sp, err = node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.NotNil(sp)
+	require.Equal(id, sp.RequestId)
+	require.Equal([]string{testTransactionReceiver}, sp.Parse().Allowlist)
+}
+
+// the holders lift the allowlist and then tighten it again, the older looser
+// policy signed by the holders can't be submitted again to lift it
+func TestBitcoinKeeperUpdatePolicyReplay(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, _, _ := testPrepare(require)
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	loose := []byte(`{}`)
+	looseId := uuid.Must(uuid.NewV4()).String()
+	ms := common.SafePolicyMessage(safe.RequestId, safe.Address, looseId, loose)
+	looseUpdate := &common.SafePolicyUpdate{Policy: loose, Signature: testBitcoinSignMessage(testBitcoinKeyHolderPrivate, ms)}
+	testUpdateSafePolicy(ctx, require, node, db, holder, looseId, looseUpdate)
+	testCheckRequestState(ctx, require, node, looseId, common.RequestStateDone)
+
+	strict := []byte(`{"allowlist":["` + testTransactionReceiver + `"]}`)
+	strictId := uuid.Must(uuid.NewV4()).String()
+	ms = common.SafePolicyMessage(safe.RequestId, safe.Address, strictId, strict)
+	strictUpdate := &common.SafePolicyUpdate{Policy: strict, Signature: testBitcoinSignMessage(testBitcoinKeyHolderPrivate, ms)}
+	testUpdateSafePolicy(ctx, require, node, db, holder, strictId, strictUpdate)
+	testCheckRequestState(ctx, require, node, strictId, common.RequestStateDone)
+	sp, err := node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(strictId, sp.RequestId)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	testUpdateSafePolicy(ctx, require, node, db, holder, id, looseUpdate)
+	testCheckRequestState(ctx, require, node, id, common.RequestStateFailed)
+	out := testBuildHolderRequest(node, looseId, holder, common.ActionBitcoinSafeUpdatePolicy, testAccountPriceAssetId, looseUpdate.Encode(), decimal.NewFromFloat(testAccountPriceAmount))
+	out.TransactionHash = crypto.Sha256Hash([]byte(id)).String()
+	out.OutputId = common.UniqueId(looseId, "replay")
+	txs, _ := node.ProcessOutput(ctx, out)
+	require.Len(txs, 0)
+	testCheckRequestState(ctx, require, node, looseId, common.RequestStateDone)
+	sp, err = node.store.ReadActiveSafePolicy(ctx, holder, time.Now())
+	require.Nil(err)
+	require.Equal(strictId, sp.RequestId)
+	require.Equal([]string{testTransactionReceiver}, sp.Parse().Allowlist)
+}
+
+func testUpdateSafePolicy(ctx context.Context, require *require.Assertions, node *Node, db *mtg.SQLite3Store, holder, id string, update *common.SafePolicyUpdate) {
+	out := testBuildHolderStorageRequest(ctx, require, node, db, id, holder, common.ActionBitcoinSafeUpdatePolicy, testAccountPriceAssetId, update.Encode(), decimal.NewFromFloat(testAccountPriceAmount))
+	testStep(ctx, require, node, out)
+}
@@ -0,0 +1,38 @@
+package keeper
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/fox-one/mixin-sdk-go/v2"
+)
+
+// replayUsersReader answers the account receivers lookup without the Mixin
+// API, all receivers are assumed to have safe, so a proposal rejected by the
+// live keeper for a receiver without safe shows up as a divergence there.
+type replayUsersReader struct{}
+
+func (replayUsersReader) ReadUsers(ctx context.Context, ids ...string) ([]*mixin.User, error) {
+	us := make([]*mixin.User, len(ids))
+	for i, id := range ids {
+		us[i] = &mixin.User{UserID: id, HasSafe: true}
+	}
+	return us, nil
+}
+
+// NewReplayNode builds a keeper node to replay the finished actions into a
+// fresh store, which never calls the Mixin API.
+func NewReplayNode(store *store.SQLite3Store, group *mtg.Group, conf *Configuration, signer *mtg.Configuration) *Node {
+	node := NewNode(store, group, conf, signer, nil)
+	node.mixin = replayUsersReader{}
+	return node
+}
+
+// ReplayAction processes a finished action again as the group does. The
+// group only attaches the actions to itself in group.Run, so the attach hook
+// of mtg is used here, which also resets the outputs consumed by the action.
+func (node *Node) ReplayAction(ctx context.Context, act *mtg.Action) ([]*mtg.Transaction, string) {
+	act.TestAttachActionToGroup(node.group)
+	return node.ProcessOutput(ctx, act)
+}
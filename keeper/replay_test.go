@@ -0,0 +1,96 @@
+package keeper
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeeperReplay(t *testing.T) {
+	require := require.New(t)
+	ctx, _, _ := signer.TestPrepare(require)
+
+	root, err := os.MkdirTemp("", "safe-keeper-test-")
+	require.Nil(err)
+	node, _ := testBuildNode(ctx, require, root)
+
+	actions := testBuildReplayActions(node)
+	for _, out := range actions {
+		testStep(ctx, require, node, out)
+	}
+	testSpareKeys(ctx, require, node, 0, 0, 1, common.CurveSecp256k1ECDSABitcoin)
+
+	rs, err := store.OpenSQLite3Store(root + "/replay.sqlite3")
+	require.Nil(err)
+	defer rs.Close()
+	replay := NewReplayNode(rs, node.group, node.conf, node.signer)
+	for _, out := range actions {
+		live, err := node.store.ReadActionResultByOutputId(ctx, out.OutputId)
+		require.Nil(err)
+		require.NotNil(live)
+		txs, asset := replay.ReplayAction(ctx, out)
+		require.Equal("", asset)
+		require.Len(txs, len(live.Transactions))
+		for i, tx := range txs {
+			require.Equal(live.Transactions[i].TraceId, tx.TraceId)
+		}
+	}
+
+	diff, err := node.store.DiffTables(ctx, rs)
+	require.Nil(err)
+	require.Nil(diff)
+
+	err = rs.WriteProperty(ctx, uuid.Must(uuid.NewV4()).String(), "divergence")
+	require.Nil(err)
+	diff, err = node.store.DiffTables(ctx, rs)
+	require.Nil(err)
+	require.NotNil(diff)
+	require.Equal("properties", diff.Table)
+	require.Equal("divergence", diff.Replay["value"])
+}
+
+// testBuildReplayActions builds a small observer action stream, which adds
+// the observer key, requests signer keys and updates the network settings
+func testBuildReplayActions(node *Node) []*mtg.Action {
+	dummy := testPublicKey(testBitcoinKeyDummyHolderPrivate)
+	observer := testPublicKey(testBitcoinKeyObserverPrivate)
+	occ := common.DecodeHexOrPanic(testBitcoinKeyObserverChainCode)
+	extra := append([]byte{common.RequestRoleObserver}, occ...)
+	extra = append(extra, common.RequestFlagNone)
+	id := uuid.Must(uuid.NewV4()).String()
+	actions := []*mtg.Action{
+		testBuildObserverRequest(node, id, observer, common.ActionObserverAddKey, extra, common.CurveSecp256k1ECDSABitcoin),
+	}
+
+	id = uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverRequestSignerKeys, []byte{4}, common.CurveSecp256k1ECDSABitcoin)
+	actions = append(actions, out)
+
+	extra = []byte{common.SafeChainBitcoin}
+	extra = append(extra, uuid.Must(uuid.FromString(testAccountPriceAssetId)).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, testAccountPriceAmount*100000000)
+	extra = binary.BigEndian.AppendUint64(extra, 10000)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(SafeProposalExpiryMaximum/time.Second))
+	id = uuid.Must(uuid.NewV4()).String()
+	out = testBuildObserverRequest(node, id, dummy, common.ActionObserverSetOperationParams, extra, common.CurveSecp256k1ECDSABitcoin)
+	actions = append(actions, out)
+
+	extra = []byte{common.SafeChainBitcoin}
+	extra = binary.BigEndian.AppendUint64(extra, uint64(bitcoinMinimumFeeRate))
+	tip := testNetwork.bitcoin.Tip()
+	hash, _ := crypto.HashFromString(tip.Hash)
+	extra = binary.BigEndian.AppendUint64(extra, tip.Height)
+	extra = append(extra, hash[:]...)
+	id = uuid.Must(uuid.NewV4()).String()
+	out = testBuildObserverRequest(node, id, dummy, common.ActionObserverUpdateNetworkStatus, extra, common.CurveSecp256k1ECDSABitcoin)
+	return append(actions, out)
+}
@@ -0,0 +1,846 @@
+package keeper
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+func (node *Node) processSolanaSafeProposeAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	if req.Curve != common.CurveEdwards25519Solana {
+		panic(req.Curve)
+	}
+	rce := req.ExtraBytes()
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(rce) == 32 && len(ver.References) == 1 && bytes.Equal(ver.References[0][:], rce) {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		rce = stx.Extra
+	}
+	arp, err := req.ParseMixinRecipient(ctx, node.mixin, rce)
+	logger.Printf("req.ParseMixinRecipient(%v) => %v %v", req, arp, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("node.ReadLatestOperationParams(%d) => %v", chain, err))
+	} else if plan == nil || !plan.OperationPriceAmount.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if req.AssetId != plan.OperationPriceAsset {
+		return node.failRequest(ctx, req, "")
+	}
+	if req.Amount.Cmp(plan.OperationPriceAmount) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if safe != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	old, err := node.store.ReadSafeProposal(ctx, req.Id)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%s) => %v", req.Id, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	signer, observer, err := node.store.AssignSignerAndObserverToHolder(ctx, req, SafeKeyBackupMaturity, arp.Observer)
+	logger.Printf("store.AssignSignerAndObserverToHolder(%s) => %s %s %v", req.Holder, signer, observer, err)
+	if err != nil {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v", req, err))
+	}
+	if signer == "" || observer == "" {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	if arp.Observer != "" && arp.Observer != observer {
+		panic(fmt.Errorf("store.AssignSignerAndObserverToHolder(%v) => %v %s", req, arp, observer))
+	}
+	if !checkSafeKeysUnique(arp.Holders, signer, observer) {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+
+	sa, t, err := solana.BuildSafeAccountWithHolders(arp.Holders, int(arp.HolderThreshold), signer, observer, req.Id, arp.Timelock)
+	logger.Verbosef("solana.BuildSafeAccountWithHolders(%v) => %v %v", req, sa, err)
+	if err != nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	old, err = node.store.ReadSafeProposalByAddress(ctx, sa.Address)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", sa.Address, err))
+	} else if old != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	tx := &store.Transaction{
+		TransactionHash: t.TxHash,
+		RawTransaction:  hex.EncodeToString(t.Marshal()),
+		Holder:          req.Holder,
+		Chain:           chain,
+		AssetId:         common.SafeSolanaChainId,
+		State:           common.RequestStateInitial,
+		Data:            "",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+
+	extra := sa.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionSolanaSafeProposeAccount)
+	crv := common.SafeChainCurve(chain)
+	tt := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if tt == nil {
+		return node.refundAndFailRequest(ctx, req, arp.Receivers, int(arp.Threshold))
+	}
+	txs = append(txs, tt)
+
+	path := solanaDefaultDerivationPath()
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     chain,
+		Holder:    req.Holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  arp.Timelock,
+		Path:      hex.EncodeToString(path),
+		Address:   sa.Address,
+		Extra:     extra,
+		Receivers: arp.Receivers,
+		Threshold: arp.Threshold,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	holders := buildSafeHolders(req, chain, sa.Address, arp)
+	policy := buildSafePolicy(req, chain, arp.Policy, req.CreatedAt)
+	err = node.store.WriteEthereumSafeProposalWithRequest(ctx, sp, holders, policy, tx, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeApproveAccount(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	if req.Curve != common.CurveEdwards25519Solana {
+		panic(req.Curve)
+	}
+	old, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	} else if old != nil && old.State != common.RequestStatePending {
+		return node.failRequest(ctx, req, "")
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, common.SafeSolanaChainId, req.Holder)
+
+	extra := req.ExtraBytes()
+	if len(extra) < 16+32 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	sp, err := node.store.ReadSafeProposal(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafeProposal(%v) => %s %v", req, rid.String(), err))
+	} else if sp == nil {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Holder != req.Holder {
+		return node.failRequest(ctx, req, "")
+	} else if sp.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	sa, err := solana.UnmarshalSafeAccount(sp.Extra)
+	logger.Printf("solana.UnmarshalSafeAccount(%x) => %v %v", sp.Extra, sa, err)
+	if err != nil {
+		panic(err)
+	}
+	tx, err := node.store.ReadTransaction(ctx, sa.TxHash)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransaction(%s) => %v %v", sa.TxHash, tx, err))
+	}
+	if tx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	rawB := common.DecodeHexOrPanic(tx.RawTransaction)
+	t, err := solana.UnmarshalSafeTransaction(rawB)
+	logger.Printf("solana.UnmarshalSafeTransaction(%x) => %v %v", rawB, t, err)
+	if err != nil {
+		panic(err)
+	}
+
+	holders, threshold := node.readSafeHolderKeys(ctx, sp.Address, sp.Holder)
+	sigs, err := verifySafeApprovalSignatures(holders, threshold, node.readSafeApprovalSignature(ctx, holders, extra[16:]), func(public string, sig []byte) error {
+		return solana.VerifyMessageSignature(public, t.Message, sig)
+	})
+	logger.Printf("solana.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	for i := range holders {
+		if sigs[i] != nil {
+			t.SetSignature(i, sigs[i])
+		}
+	}
+
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       SafeStatePending,
+		Nonce:       0,
+		SafeAssetId: safeAssetId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	err = node.store.WriteUnfinishedSafe(ctx, safe)
+	if err != nil {
+		panic(fmt.Errorf("store.WriteUnfinishedSafe(%v) => %v", safe, err))
+	}
+
+	sr := &store.SignatureRequest{
+		TransactionHash: tx.TransactionHash,
+		InputIndex:      0,
+		Signer:          sp.Signer,
+		Curve:           req.Curve,
+		Message:         hex.EncodeToString(t.Message),
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(t.Marshal()), req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeProposeTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleHolder {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.ReadUnfinishedTransactionsByHolder(%s) => %v %v", safe.Holder, len(pendings), err)
+	if len(pendings) > 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	meta, err := node.fetchAssetMeta(ctx, req.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s) => %v %v", req.AssetId, meta, err)
+	if err != nil {
+		panic(fmt.Errorf("node.fetchAssetMeta(%s) => %v", req.AssetId, err))
+	}
+	if meta.Chain != common.SafeChainPolygon {
+		return node.failRequest(ctx, req, "")
+	}
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, meta.AssetKey)
+	logger.Printf("abi.CheckFactoryAssetDeployed(%s) => %v %v", meta.AssetKey, deployed, err)
+	if err != nil || deployed.Sign() <= 0 {
+		panic(fmt.Errorf("api.CheckFatoryAssetDeployed(%s) => %v", meta.AssetKey, err))
+	}
+	id := uuid.Must(uuid.FromBytes(deployed.Bytes()))
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestOperationParams(%d) => %v %v", safe.Chain, plan, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadLatestOperationParams(%d) => %v", safe.Chain, err))
+	} else if plan == nil || !plan.TransactionMinimum.IsPositive() {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	if req.Amount.Cmp(plan.TransactionMinimum) < 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	entry := node.fetchBondAssetReceiver(ctx, safe.Address, id.String())
+	safeAssetId := node.getBondAssetId(ctx, entry, id.String(), req.Holder)
+	logger.Printf("node.getBondAssetId(%s, %s, %s) => %s", entry, id.String(), req.Holder, safeAssetId)
+	if req.AssetId != safeAssetId {
+		panic(req.AssetId)
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) < 33 {
+		return node.failRequest(ctx, req, "")
+	}
+	flag, extra := extra[0], extra[1:]
+	iid, err := uuid.FromBytes(extra[:16])
+	if err != nil || iid.String() == uuid.Nil.String() {
+		return node.failRequest(ctx, req, "")
+	}
+	info, err := node.store.ReadNetworkInfo(ctx, iid.String())
+	logger.Printf("store.ReadNetworkInfo(%s) => %v %v", iid.String(), info, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadNetworkInfo(%s) => %v", iid.String(), err))
+	}
+	if info == nil || info.Chain != safe.Chain {
+		return node.failRequest(ctx, req, "")
+	}
+	balance, err := node.store.ReadEthereumBalance(ctx, safe.Address, id.String(), safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, id.String(), balance, err)
+	if err != nil {
+		panic(err)
+	}
+	if balance.SafeAssetId != req.AssetId {
+		panic(balance.SafeAssetId)
+	}
+	if balance.AssetAddress == "" {
+		return node.failRequest(ctx, req, "")
+	}
+	decimals := int32(solana.ValuePrecision)
+	if balance.AssetAddress != solana.SolanaEmptyAddress {
+		asset, err := node.store.ReadAssetMeta(ctx, id.String())
+		logger.Printf("store.ReadAssetMeta(%s) => %v %v", id.String(), asset, err)
+		if err != nil {
+			panic(err)
+		}
+		decimals = int32(asset.Decimals)
+	}
+
+	var outputs []*solana.Output
+	ver, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, req.MixinHash.String())
+	if len(extra[16:]) == 32 && len(ver.References) == 1 && ver.References[0].String() == hex.EncodeToString(extra[16:]) {
+		stx, _ := node.group.ReadKernelTransactionUntilSufficient(ctx, ver.References[0].String())
+		extra := stx.Extra
+		var recipients [][2]string // TODO better encoding
+		err = json.Unmarshal(extra, &recipients)
+		if err != nil {
+			return node.failRequest(ctx, req, "")
+		}
+		for _, rp := range recipients {
+			amt, err := decimal.NewFromString(rp[1])
+			if err != nil {
+				return node.failRequest(ctx, req, "")
+			}
+			if amt.Cmp(plan.TransactionMinimum) < 0 {
+				return node.failRequest(ctx, req, "")
+			}
+			amount, valid := parseSolanaAmount(amt, decimals)
+			if !valid {
+				return node.failRequest(ctx, req, "")
+			}
+			outputs = append(outputs, &solana.Output{
+				Destination:  rp[0],
+				Amount:       amount,
+				TokenAddress: balance.AssetAddress,
+				Decimals:     byte(decimals),
+			})
+		}
+	} else {
+		amount, valid := parseSolanaAmount(req.Amount, decimals)
+		if !valid {
+			return node.failRequest(ctx, req, "")
+		}
+		outputs = []*solana.Output{{
+			Destination:  string(extra[16:]),
+			Amount:       amount,
+			TokenAddress: balance.AssetAddress,
+			Decimals:     byte(decimals),
+		}}
+	}
+
+	total := decimal.Zero
+	recipients := make([]map[string]string, len(outputs))
+	for i, out := range outputs {
+		norm := solana.NormalizeAddress(out.Destination)
+		if norm != out.Destination || norm == solana.SolanaEmptyAddress || norm == safe.Address {
+			logger.Printf("invalid output destination: %s, %s", out.Destination, safe.Address)
+			return node.failRequest(ctx, req, "")
+		}
+		amt := decimal.NewFromBigInt(out.Amount, -decimals)
+		r := map[string]string{
+			"receiver": out.Destination, "amount": amt.String(),
+		}
+		if out.TokenAddress != solana.SolanaEmptyAddress {
+			r["token"] = out.TokenAddress
+		}
+		recipients[i] = r
+		total = total.Add(amt)
+	}
+	if len(outputs) > solana.OutputsLimit || !total.Equal(req.Amount) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var outflow *store.SafeOutflow
+	switch flag {
+	case common.FlagProposeNormalTransaction:
+		outflow, err = node.evaluateSafePolicy(ctx, req, safe, id.String(), safePolicyDestinations(recipients), total)
+		if err != nil {
+			return node.failRequestWithPolicyViolation(ctx, req, safe, err.Error())
+		}
+	case common.FlagProposeRecoveryTransaction:
+		if len(outputs) != 1 {
+			logger.Printf("invalid recovery transaction outputs: %d", len(outputs))
+			return node.failRequest(ctx, req, "")
+		}
+		balances, err := node.store.ReadAllEthereumTokenBalances(ctx, safe.Address)
+		logger.Printf("store.ReadAllEthereumTokenBalances(%s) => %v %v", safe.Address, balances, err)
+		if err != nil {
+			panic(err)
+		}
+		for _, b := range balances {
+			if b.AssetId == balance.AssetId || b.BigBalance().Sign() == 0 {
+				continue
+			}
+			asset, err := node.store.ReadAssetMeta(ctx, b.AssetId)
+			logger.Printf("store.ReadAssetMeta(%s) => %v %v", b.AssetId, asset, err)
+			if err != nil {
+				panic(err)
+			}
+			output := &solana.Output{
+				Destination:  string(extra[16:]),
+				Amount:       b.BigBalance(),
+				TokenAddress: b.AssetAddress,
+				Decimals:     byte(asset.Decimals),
+			}
+			outputs = append(outputs, output)
+
+			amt := decimal.NewFromBigInt(output.Amount, int32(-asset.Decimals))
+			r := map[string]string{
+				"receiver": output.Destination, "amount": amt.String(),
+			}
+			if output.TokenAddress != solana.SolanaEmptyAddress {
+				r["token"] = output.TokenAddress
+			}
+			recipients = append(recipients, r)
+		}
+	default:
+		logger.Printf("invalid transaction flag: %d", flag)
+		return node.failRequest(ctx, req, "")
+	}
+
+	sa, err := solana.UnmarshalSafeAccount(safe.Extra)
+	if err != nil {
+		panic(err)
+	}
+	t, err := solana.CreateTransactionFromOutputs(req.Id, safe.Address, sa.Config, outputs, uint64(safe.Nonce))
+	logger.Printf("solana.CreateTransactionFromOutputs(%s, %s, %v, %d) => %v %v",
+		req.Id, safe.Address, outputs, safe.Nonce, t, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	t.Signatures = make([][]byte, len(owners))
+	err = t.CheckSize(threshold + 1)
+	logger.Printf("solana.CheckSize(%d, %d) => %v", len(holders), threshold, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra = t.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionSolanaSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	tt := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if tt == nil {
+		return node.refundAndFailRequest(ctx, req, safe.Receivers, int(safe.Threshold))
+	}
+	txs = append(txs, tt)
+
+	data := common.MarshalJSONOrPanic(recipients)
+	tx := &store.Transaction{
+		TransactionHash: t.TxHash,
+		RawTransaction:  hex.EncodeToString(t.Marshal()),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         id.String(),
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	if outflow != nil {
+		outflow.TransactionHash = tx.TransactionHash
+	}
+	err = node.store.WriteTransactionWithOutflowAndRequest(ctx, tx, nil, outflow, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+func (node *Node) processSolanaSafeApproveTransaction(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+
+	extra := req.ExtraBytes()
+	if len(extra) != 48 {
+		return node.failRequest(ctx, req, "")
+	}
+	rid, err := uuid.FromBytes(extra[:16])
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	tx, err := node.store.ReadTransactionByRequestId(ctx, rid.String())
+	if err != nil {
+		panic(fmt.Errorf("store.ReadTransactionByRequestId(%v) => %s %v", req, rid.String(), err))
+	}
+	if !node.checkSafeTransactionApprovable(ctx, req, tx) {
+		return node.failRequest(ctx, req, "")
+	}
+
+	var ref crypto.Hash
+	copy(ref[:], extra[16:])
+	raw := node.readStorageExtraFromObserver(ctx, ref)
+	t, err := solana.UnmarshalSafeTransaction(raw)
+	logger.Printf("solana.UnmarshalSafeTransaction(%x) => %v %v", raw, t, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	proposed, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(proposed.Message, t.Message) {
+		return node.failRequest(ctx, req, "")
+	}
+	sr, valid := node.buildSolanaApprovalSignatureRequest(ctx, req, safe, tx, t)
+	if !valid {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := node.buildSignerSignRequests(ctx, req, []*store.SignatureRequest{sr}, safe.Path)
+	if len(txs) == 0 {
+		// no compaction needed, just retry from observer
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.WriteSignatureRequestsWithRequest(ctx, []*store.SignatureRequest{sr}, tx.TransactionHash, hex.EncodeToString(t.Marshal()), req, txs)
+	logger.Printf("store.WriteSignatureRequestsWithRequest(%s, %d, %v) => %v", tx.TransactionHash, 1, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// buildSolanaApprovalSignatureRequest checks the safe transaction signed by
+// the holders, and returns the signature request of the signer, whose message
+// is the safe transaction digest verified by the safe program
+func (node *Node) buildSolanaApprovalSignatureRequest(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, t *solana.SafeTransaction) (*store.SignatureRequest, bool) {
+	signed := node.checkSolanaTransactionSignedByHolders(ctx, safe, t)
+	logger.Printf("node.checkSolanaTransactionSignedByHolders(%v, %s) => %t", t, safe.Holder, signed)
+	if !signed {
+		return nil, false
+	}
+
+	sr := &store.SignatureRequest{
+		TransactionHash: tx.TransactionHash,
+		InputIndex:      0,
+		Signer:          safe.Signer,
+		Curve:           req.Curve,
+		Message:         hex.EncodeToString(t.Message),
+		State:           common.RequestStateInitial,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	sr.RequestId = common.UniqueId(req.Id, sr.Message)
+	return sr, true
+}
+
+func (node *Node) processSolanaSafeSignatureResponse(ctx context.Context, req *common.Request, safe *store.Safe, tx *store.Transaction, old *store.SignatureRequest) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleSigner {
+		panic(req.Role)
+	}
+
+	sig := req.ExtraBytes()
+	msg := common.DecodeHexOrPanic(old.Message)
+	err := solana.VerifyMessageSignature(safe.Signer, msg, sig)
+	logger.Printf("solana.VerifyMessageSignature(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.FinishSignatureRequest(ctx, req)
+	logger.Printf("store.FinishSignatureRequest(%s) => %v", req.Id, err)
+	if err != nil {
+		panic(fmt.Errorf("store.FinishSignatureRequest(%s) => %v", req.Id, err))
+	}
+
+	rawB := common.DecodeHexOrPanic(tx.RawTransaction)
+	t, err := solana.UnmarshalSafeTransaction(rawB)
+	logger.Printf("solana.UnmarshalSafeTransaction(%x) => %v %v", rawB, t, err)
+	if err != nil {
+		panic(err)
+	}
+	if !bytes.Equal(t.Message, msg) {
+		panic(old.Message)
+	}
+
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, old.TransactionHash, common.RequestStatePending)
+	logger.Printf("store.ListAllSignaturesForTransaction(%s) => %d %v", old.TransactionHash, len(requests), err)
+	if err != nil {
+		panic(fmt.Errorf("store.ListAllSignaturesForTransaction(%s) => %v", old.TransactionHash, err))
+	}
+	if len(requests) != 1 {
+		panic(fmt.Errorf("invalid signature requests len: %d", len(requests)))
+	}
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	sig = common.DecodeHexOrPanic(requests[0].Signature.String)
+	err = solana.VerifyMessageSignature(safe.Signer, t.Message, sig)
+	if err != nil {
+		panic(requests[0].Signature.String)
+	}
+	t.SetSignature(slices.Index(owners, safe.Signer), sig)
+	raw := hex.EncodeToString(t.Marshal())
+
+	if safe.State == common.RequestStatePending {
+		sp, err := node.store.ReadSafeProposalByAddress(ctx, safe.Address)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadSafeProposalByAddress(%s) => %v", safe.Address, err))
+		}
+		spr, err := node.store.ReadRequest(ctx, sp.RequestId)
+		if err != nil {
+			panic(fmt.Errorf("store.ReadRequest(%s) => %v", sp.RequestId, err))
+		}
+		st, parsed, lock, err := solana.ParseCreateSafeData(t.Data)
+		if err != nil || t.Type != solana.TypeCreateSafe {
+			panic(fmt.Errorf("solana.ParseCreateSafeData(%x) => %v", t.Data, err))
+		}
+		if st != threshold || !slices.Equal(parsed, owners) || lock != sp.Timelock {
+			panic(fmt.Errorf("invalid safe create transaction %x", t.Data))
+		}
+
+		stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(safe.Extra)))
+		if stx == nil {
+			return node.failRequest(ctx, req, "")
+		}
+		txs := []*mtg.Transaction{stx}
+
+		typ := byte(common.ActionSolanaSafeApproveAccount)
+		crv := common.SafeChainCurve(safe.Chain)
+		id := common.UniqueId(req.Id, safe.Address)
+		tx := node.buildObserverResponseWithAssetAndStorageTraceId(ctx, id, req.Output, typ, crv, spr.AssetId, spr.Amount.String(), stx.TraceId)
+		if tx == nil {
+			return node.failRequest(ctx, req, spr.AssetId)
+		}
+		txs = append(txs, tx)
+
+		err = node.store.FinishSafeWithRequest(ctx, old.TransactionHash, raw, req, safe, txs)
+		if err != nil {
+			panic(err)
+		}
+		return txs, ""
+	}
+
+	sbm, err := node.store.ReadAllEthereumTokenBalancesMap(ctx, safe.Address)
+	logger.Printf("store.ReadAllEthereumTokenBalancesMap(%s) => %v %v", safe.Address, sbm, err)
+	if err != nil {
+		panic(err)
+	}
+	for _, o := range t.ExtractOutputs() {
+		sb := sbm[o.TokenAddress]
+		if sb == nil || sb.BigBalance().Cmp(o.Amount) < 0 {
+			logger.Printf("safe %s balance %v lower than %d", safe.Address, sb, o.Amount)
+			return node.failRequest(ctx, req, "")
+		}
+		sb.UpdateBalance(new(big.Int).Neg(o.Amount))
+	}
+
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(t.Marshal())))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	id := common.UniqueId(old.TransactionHash, stx.TraceId)
+	typ := byte(common.ActionSolanaSafeApproveTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	tt := node.buildObserverResponseWithStorageTraceId(ctx, id, req.Output, typ, crv, stx.TraceId)
+	if tt == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, tt)
+
+	err = node.store.FinishTransactionSignaturesWithRequest(ctx, old.TransactionHash, raw, req, 0, safe, sbm, txs)
+	logger.Printf("store.FinishTransactionSignaturesWithRequest(%s, %s, %v) => %v", old.TransactionHash, raw, req, err)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
+
+// parseSolanaAmount returns the amount in the token base unit, which must be
+// an integer within the u64 range of the SPL token amount
+func parseSolanaAmount(amt decimal.Decimal, decimals int32) (*big.Int, bool) {
+	unit := amt.Shift(decimals)
+	if !unit.IsInteger() || !unit.BigInt().IsUint64() || unit.Sign() <= 0 {
+		return nil, false
+	}
+	return unit.BigInt(), true
+}
+
+// checkSolanaTransactionSignedByHolders returns true if the transaction
+// has been signed by the holders threshold of the safe holder keys, and
+// the holder signatures are always in the first slots of the safe owners
+func (node *Node) checkSolanaTransactionSignedByHolders(ctx context.Context, safe *store.Safe, t *solana.SafeTransaction) bool {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	if len(t.Signatures) != len(owners) {
+		return false
+	}
+	raw := hex.EncodeToString(t.Marshal())
+	return solana.CheckTransactionPartiallySignedByHolders(raw, owners, holders, threshold)
+}
+
+func (node *Node) doSolanaHolderDeposit(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe, safeAssetId string, asset *store.Asset) ([]*mtg.Transaction, string) {
+	if asset.AssetId == common.SafeSolanaChainId && asset.Decimals != solana.ValuePrecision {
+		panic(asset.Decimals)
+	}
+	deposited, err := node.store.ReadDeposit(ctx, deposit.Hash, int64(deposit.Index))
+	logger.Printf("store.ReadDeposit(%s, %d, %s, %s) => %v %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, deposited, err)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadDeposit(%s, %d, %s, %s) => %v", deposit.Hash, int64(deposit.Index), asset.AssetId, safe.Address, err))
+	} else if deposited != nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	safeBalance, err := node.store.ReadEthereumBalance(ctx, safe.Address, asset.AssetId, safeAssetId)
+	logger.Printf("store.ReadEthereumBalance(%s, %s) => %v %v", safe.Address, asset.AssetId, safeBalance, err)
+	if err != nil {
+		panic(err)
+	}
+	safeBalance.UpdateBalance(deposit.Amount)
+	if safeBalance.AssetAddress == "" {
+		safeBalance.AssetAddress = deposit.AssetAddress
+	}
+
+	output, err := node.verifySolanaTransaction(ctx, req, deposit, safe)
+	logger.Printf("node.verifySolanaTransaction(%v) => %v %v", req, output, err)
+	if err != nil {
+		panic(fmt.Errorf("node.verifySolanaTransaction(%s) => %v", deposit.Hash, err))
+	}
+	if output == nil {
+		return node.failRequest(ctx, req, "")
+	}
+
+	t := node.buildTransaction(ctx, req.Output, safe.RequestId, safeAssetId, safe.Receivers, int(safe.Threshold), decimal.NewFromBigInt(deposit.Amount, -int32(asset.Decimals)).String(), nil, req.Id)
+	if t == nil {
+		// no compaction needed, just retry from observer
+		return node.failRequest(ctx, req, "")
+	}
+	err = node.store.CreateEthereumBalanceDepositFromRequest(ctx, safe, safeBalance, deposit.Hash, int64(deposit.Index), deposit.Amount, output.Sender, req, []*mtg.Transaction{t})
+	logger.Printf("store.CreateEthereumBalanceDepositFromRequest(%v) => %v", req, err)
+	if err != nil {
+		panic(err)
+	}
+	return []*mtg.Transaction{t}, ""
+}
+
+func (node *Node) verifySolanaTransaction(ctx context.Context, req *common.Request, deposit *Deposit, safe *store.Safe) (*solana.Transfer, error) {
+	info, err := node.store.ReadLatestNetworkInfo(ctx, safe.Chain, req.CreatedAt)
+	logger.Printf("store.ReadLatestNetworkInfo(%d) => %v %v", safe.Chain, info, err)
+	if err != nil || info == nil {
+		return nil, err
+	}
+	if info.CreatedAt.After(req.CreatedAt) {
+		return nil, fmt.Errorf("malicious solana network info %v", info)
+	}
+
+	t, stx, err := solana.VerifyDeposit(node.conf.SolanaRPC, deposit.Hash, deposit.AssetAddress, safe.Address, int64(deposit.Index), deposit.Amount)
+	if err != nil || t == nil {
+		return nil, fmt.Errorf("malicious solana deposit or node not in sync? %s %v", deposit.Hash, err)
+	}
+	if t.AssetId != deposit.Asset {
+		return nil, fmt.Errorf("malicious solana deposit %s", deposit.Hash)
+	}
+
+	confirmations := info.Height - stx.Slot + 1
+	if info.Height < stx.Slot {
+		confirmations = 0
+	}
+	if !solana.CheckFinalization(confirmations) {
+		return nil, fmt.Errorf("solana.CheckFinalization(%s)", deposit.Hash)
+	}
+	return t, nil
+}
+
+func (node *Node) verifySolanaNetworkInfo(info, old *store.NetworkInfo) (bool, error) {
+	if _, err := solana.HashFromBase58(info.Hash); err != nil {
+		return false, nil
+	}
+	if old != nil && old.Hash == info.Hash {
+		if old.Height != info.Height {
+			return false, fmt.Errorf("malicious solana block %s", info.Hash)
+		}
+		return true, nil
+	}
+	block, err := solana.RPCGetBlock(node.conf.SolanaRPC, info.Height)
+	if err != nil || block == nil {
+		return false, fmt.Errorf("malicious solana block or node not in sync? %s %v", info.Hash, err)
+	}
+	if block.Blockhash != info.Hash {
+		return false, fmt.Errorf("malicious solana block %s", info.Hash)
+	}
+	return true, nil
+}
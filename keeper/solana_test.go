@@ -0,0 +1,430 @@
+package keeper
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSolanaProgram             = "D3ob8sTZ9hMnVBhLThD1Xd8Su8btV8gY73p7JEuZiAHE"
+	testSolanaSafeRequestId       = "c6bfe1b6-3d2a-4b6e-9f0b-3a5b0e6c2d41"
+	testSolanaSafeAddress         = "GeqTpTQPbEdgJGZxqLXi3g1BqpqfiRRJqUhhpHhxn9Dj"
+	testSolanaKeyHolder           = "1eb8c3d56c19d36fdb1e98c861382726eeee5bbbc894a2e20686f119dd278fb4"
+	testSolanaKeyObserver         = "0d781467fd2f6eedd65b4664f734b594e4744d60d4daf5fcb1bd3a0272b6d955"
+	testSolanaKeyDummyHolder      = "2ba4a2e846e42e001c6b1fdebad1f598d9f3e6f752c39b326f06a7818ac42e98"
+	testSolanaKeyPayer            = "9536c1cbe71ee13ba1dcf63c5cde9853d7a1c2d653f13f8fbe5fd4e75d481687"
+	testSolanaDepositSender       = "66yJPugKJqjkJFN6XnuwgcchawjS7vq57KJgAfN86w9L"
+	testSolanaTransactionReceiver = "14iWFYTAaNttKeLfA1TC1ELri8ajMgULJ457aQ8z1gLR"
+	testSolanaDepositLamports     = 1000000
+	testSolanaTransferLamports    = 100000
+)
+
+func TestSolanaKeeper(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, _, signers := testSolanaPrepare(require)
+
+	bondId := testDeployBondContract(ctx, require, node, testSolanaSafeAddress, common.SafeSolanaChainId)
+	output, err := testWriteOutput(ctx, db, node.conf.AppId, bondId, testGenerateDummyExtra(node), sequence, decimal.NewFromInt(100000000000000))
+	require.Nil(err)
+	action := &mtg.Action{
+		UnifiedOutput: *output,
+	}
+	node.ProcessOutput(ctx, action)
+	testSolanaObserverHolderDeposit(ctx, require, node, bondId, testSolanaDepositLamports)
+
+	txHash := testSolanaProposeTransaction(ctx, require, node, bondId, "3e37ea1c-1455-400d-9642-f6bbcd8c7442")
+	raw := testSolanaApproveTransaction(ctx, require, node, txHash, bondId, signers)
+
+	testSolanaSendTransaction(require, node, raw)
+	require.Equal(uint64(testSolanaTransferLamports), testNetwork.solana.Balance(testSolanaTransactionReceiver))
+	require.Equal(uint64(testSolanaDepositLamports-testSolanaTransferLamports), testNetwork.solana.Balance(testSolanaSafeAddress))
+	safe, _ := node.store.ReadSafe(ctx, testSolanaPublicKey(testSolanaKeyHolder))
+	sa, err := solana.UnmarshalSafeAccount(safe.Extra)
+	require.Nil(err)
+	require.Equal(safe.Nonce, testNetwork.solana.SafeNonce(sa.Config))
+}
+
+func testSolanaPrepare(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	ctx, node, db, mpc, signers := testSolanaPrepareKeys(require)
+	observer := testSolanaPublicKey(testSolanaKeyObserver)
+	sa := testSolanaProposeAccount(ctx, require, node, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Default)
+	raw := testSolanaApproveAccount(ctx, require, node, sa, signers, mpc, observer)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Default)
+
+	require.Equal(int64(-1), testNetwork.solana.SafeNonce(sa.Config))
+	testSolanaSendTransaction(require, node, raw)
+	require.Equal(int64(1), testNetwork.solana.SafeNonce(sa.Config))
+	testUpdateSolanaNetworkTip(ctx, require, node)
+
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	safe, _ := node.store.ReadSafe(ctx, holder)
+	require.Equal(int64(SafeStateApproved), int64(safe.State))
+	require.Equal(int64(1), safe.Nonce)
+	return ctx, node, db, mpc, signers
+}
+
+// testSolanaPrepareKeys prepares the signer and observer keys and the
+// operation params, but no safe is proposed yet
+func testSolanaPrepareKeys(require *require.Assertions) (context.Context, *Node, *mtg.SQLite3Store, string, []*signer.Node) {
+	logger.SetLevel(logger.INFO)
+	ctx, signers, _ := signer.TestPrepare(require)
+	mpc := signer.TestFROSTPrepareKeys(ctx, require, signers, common.CurveEdwards25519Default)
+
+	root, err := os.MkdirTemp("", "safe-keeper-test-")
+	require.Nil(err)
+	node, db := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+	timestamp, err := node.timestamp(ctx)
+	require.Nil(err)
+	require.Equal(node.conf.MTG.Genesis.Epoch, timestamp)
+	testSpareKeys(ctx, require, node, 0, 0, 0, common.CurveEdwards25519Default)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	extra := append([]byte{common.RequestRoleSigner}, make([]byte, 32)...)
+	extra = append(extra, common.RequestFlagNone)
+	out := testBuildSignerOutput(node, id, mpc, common.OperationTypeKeygenOutput, extra, common.CurveEdwards25519Default)
+	testStep(ctx, require, node, out)
+	v, err := node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	require.Equal("", v)
+	testSpareKeys(ctx, require, node, 0, 1, 0, common.CurveEdwards25519Default)
+
+	id = uuid.Must(uuid.NewV4()).String()
+	observer := testSolanaPublicKey(testSolanaKeyObserver)
+	extra = append([]byte{common.RequestRoleObserver}, make([]byte, 32)...)
+	extra = append(extra, common.RequestFlagNone)
+	out = testBuildObserverRequest(node, id, observer, common.ActionObserverAddKey, extra, common.CurveEdwards25519Default)
+	testStep(ctx, require, node, out)
+	v, err = node.store.ReadProperty(ctx, id)
+	require.Nil(err)
+	require.Equal("", v)
+	testSpareKeys(ctx, require, node, 0, 1, 1, common.CurveEdwards25519Default)
+
+	testSolanaUpdateAccountPrice(ctx, require, node)
+	return ctx, node, db, mpc, signers
+}
+
+func testSolanaUpdateAccountPrice(ctx context.Context, require *require.Assertions, node *Node) {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	extra := []byte{common.SafeChainSolana}
+	extra = append(extra, uuid.Must(uuid.FromString(testAccountPriceAssetId)).Bytes()...)
+	extra = binary.BigEndian.AppendUint64(extra, testAccountPriceAmount*100000000)
+	extra = binary.BigEndian.AppendUint64(extra, 10000)
+	dummy := testSolanaPublicKey(testSolanaKeyDummyHolder)
+	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverSetOperationParams, extra, common.CurveEdwards25519Solana)
+	testStep(ctx, require, node, out)
+
+	plan, err := node.store.ReadLatestOperationParams(ctx, common.SafeChainSolana, time.Now())
+	require.Nil(err)
+	require.Equal(testAccountPriceAssetId, plan.OperationPriceAsset)
+	require.Equal(decimal.NewFromFloat(testAccountPriceAmount).String(), plan.OperationPriceAmount.String())
+	require.Equal("0.0001", plan.TransactionMinimum.String())
+}
+
+func testSolanaUpdateNetworkStatus(ctx context.Context, require *require.Assertions, node *Node, slot uint64, hash solana.Hash) {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	extra := []byte{common.SafeChainSolana}
+	extra = binary.BigEndian.AppendUint64(extra, 5000)
+	extra = binary.BigEndian.AppendUint64(extra, slot)
+	extra = append(extra, hash[:]...)
+	dummy := testSolanaPublicKey(testSolanaKeyDummyHolder)
+	out := testBuildObserverRequest(node, id, dummy, common.ActionObserverUpdateNetworkStatus, extra, common.CurveEdwards25519Solana)
+	testStep(ctx, require, node, out)
+
+	info, err := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainSolana, time.Now())
+	require.Nil(err)
+	require.NotNil(info)
+	require.Equal(byte(common.SafeChainSolana), info.Chain)
+	require.Equal(slot, info.Height)
+	require.Equal(hash.String(), info.Hash)
+}
+
+// testUpdateSolanaNetworkTip sends the latest slot of the test solana chain
+func testUpdateSolanaNetworkTip(ctx context.Context, require *require.Assertions, node *Node) {
+	tip := testNetwork.solana.Tip()
+	testSolanaUpdateNetworkStatus(ctx, require, node, tip.Slot, tip.Hash)
+}
+
+func testSolanaProposeAccount(ctx context.Context, require *require.Assertions, node *Node, signer, observer string) *solana.SafeAccount {
+	id := testSolanaSafeRequestId
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	extra := testRecipient()
+	price := decimal.NewFromFloat(testAccountPriceAmount)
+	out := testBuildHolderRequest(node, id, holder, common.ActionSolanaSafeProposeAccount, testAccountPriceAssetId, extra, price)
+	testStep(ctx, require, node, out)
+	b := testReadObserverResponse(ctx, require, node, id, common.ActionSolanaSafeProposeAccount)
+	sa, err := solana.UnmarshalSafeAccount(b)
+	require.Nil(err)
+	require.Equal(testSolanaSafeAddress, sa.Address)
+
+	safe, err := node.store.ReadSafeProposal(ctx, id)
+	require.Nil(err)
+	require.Equal(id, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(signer, safe.Signer)
+	require.Equal(observer, safe.Observer)
+	require.Equal(sa.Address, safe.Address)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+
+	tx, err := node.store.ReadTransaction(ctx, sa.TxHash)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+	return sa
+}
+
+func testSolanaApproveAccount(ctx context.Context, require *require.Assertions, node *Node, sa *solana.SafeAccount, signers []*signer.Node, safeSigner, safeObserver string) string {
+	approveRequestId := uuid.Must(uuid.NewV4()).String()
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+
+	tx, err := node.store.ReadTransaction(ctx, sa.TxHash)
+	require.Nil(err)
+	raw, err := hex.DecodeString(tx.RawTransaction)
+	require.Nil(err)
+	t, err := solana.UnmarshalSafeTransaction(raw)
+	require.Nil(err)
+	require.Equal(byte(solana.TypeCreateSafe), t.Type)
+	signature := testSolanaSignMessage(testSolanaKeyHolder, t.Message)
+
+	extra := uuid.FromStringOrNil(testSolanaSafeRequestId).Bytes()
+	extra = append(extra, signature...)
+	out := testBuildObserverRequest(node, approveRequestId, holder, common.ActionSolanaSafeApproveAccount, extra, common.CurveEdwards25519Solana)
+	testStep(ctx, require, node, out)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, sa.TxHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+	tx, _ = node.store.ReadTransaction(ctx, sa.TxHash)
+	require.Equal(common.RequestStatePending, tx.State)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(common.RequestStatePending, int(safe.State))
+
+	msg, _ := hex.DecodeString(requests[0].Message)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safeSigner, common.OperationTypeSignInput, msg, common.CurveEdwards25519Default)
+	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safeSigner, common.OperationTypeSignOutput, op.Extra, common.CurveEdwards25519Default)
+	testStep(ctx, require, node, out)
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, sa.TxHash, common.RequestStateDone)
+	require.Len(requests, 1)
+
+	id := common.UniqueId(requests[0].RequestId, sa.Address)
+	r := testReadObserverResponse(ctx, require, node, id, common.ActionSolanaSafeApproveAccount)
+	approved, err := solana.UnmarshalSafeAccount(r)
+	require.Nil(err)
+	require.Equal(sa.Address, approved.Address)
+	require.Equal(sa.Config, approved.Config)
+	tx, _ = node.store.ReadTransaction(ctx, sa.TxHash)
+	require.Equal(common.RequestStateDone, tx.State)
+
+	safe, err = node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	require.Equal(approveRequestId, safe.RequestId)
+	require.Equal(holder, safe.Holder)
+	require.Equal(safeSigner, safe.Signer)
+	require.Equal(safeObserver, safe.Observer)
+	require.Equal(sa.Address, safe.Address)
+	require.Equal(byte(1), safe.Threshold)
+	require.Len(safe.Receivers, 1)
+	require.Equal(testSafeBondReceiverId, safe.Receivers[0])
+
+	owners := solana.GetSafeOwnersWithHolders([]string{holder}, safe.Signer, safe.Observer)
+	require.True(solana.CheckTransactionPartiallySignedBy(tx.RawTransaction, owners, holder))
+	require.True(solana.CheckTransactionPartiallySignedBy(tx.RawTransaction, owners, safe.Signer))
+	return tx.RawTransaction
+}
+
+func testSolanaObserverHolderDeposit(ctx context.Context, require *require.Assertions, node *Node, bondId string, lamports uint64) {
+	id := uuid.Must(uuid.NewV4()).String()
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	hash := testNetwork.solana.Deposit(testSolanaDepositSender, testSolanaSafeAddress, lamports)
+	testNetwork.solana.Mine(1)
+	testUpdateSolanaNetworkTip(ctx, require, node)
+
+	rtx, err := solana.RPCGetTransaction(node.conf.SolanaRPC, hash)
+	require.Nil(err)
+	index := int64(-1)
+	for _, t := range solana.ExtractTransfers(rtx) {
+		if t.TokenAddress == solana.SolanaEmptyAddress && t.Receiver == testSolanaSafeAddress {
+			index = t.Index
+		}
+	}
+	require.True(index >= 0)
+
+	sig, err := solana.DecodeSignature(hash)
+	require.Nil(err)
+	mint := solana.MustPublicKeyFromBase58(solana.SolanaEmptyAddress)
+	raw := append(sig, mint[:]...)
+	ref := mc.Sha256Hash(raw)
+	err = node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+
+	extra := []byte{common.SafeChainSolana}
+	extra = append(extra, uuid.Must(uuid.FromString(common.SafeSolanaChainId)).Bytes()...)
+	extra = append(extra, ref[:]...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(index))
+	extra = append(extra, new(big.Int).SetUint64(lamports).Bytes()...)
+	out := testBuildObserverRequest(node, id, holder, common.ActionObserverHolderDeposit, extra, common.CurveEdwards25519Solana)
+	testStep(ctx, require, node, out)
+
+	safeAssetId := node.getBondAssetId(ctx, node.conf.PolygonKeeperDepositEntry, common.SafeSolanaChainId, holder)
+	require.Equal(bondId, safeAssetId)
+	balance, err := node.store.ReadEthereumBalance(ctx, testSolanaSafeAddress, common.SafeSolanaChainId, safeAssetId)
+	require.Nil(err)
+	require.Equal(int64(lamports), balance.BigBalance().Int64())
+	require.Equal(solana.SolanaEmptyAddress, balance.AssetAddress)
+}
+
+func testSolanaProposeTransaction(ctx context.Context, require *require.Assertions, node *Node, bondId string, rid string) string {
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	info, err := node.store.ReadLatestNetworkInfo(ctx, common.SafeChainSolana, time.Now())
+	require.Nil(err)
+	extra := []byte{common.FlagProposeNormalTransaction}
+	extra = append(extra, uuid.Must(uuid.FromString(info.RequestId)).Bytes()...)
+	extra = append(extra, []byte(testSolanaTransactionReceiver)...)
+	out := testBuildHolderRequest(node, rid, holder, common.ActionSolanaSafeProposeTransaction, bondId, extra, decimal.NewFromFloat(0.0001))
+	testStep(ctx, require, node, out)
+
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionSolanaSafeProposeTransaction)
+	t, err := solana.UnmarshalSafeTransaction(b)
+	require.Nil(err)
+	require.Equal(byte(solana.TypeTransfer), t.Type)
+	require.Equal(testSolanaSafeAddress, t.SafeAddress)
+	require.Equal(uint64(1), t.Nonce)
+	outputs := t.ExtractOutputs()
+	require.Len(outputs, 1)
+	require.Equal(solana.SolanaEmptyAddress, outputs[0].TokenAddress)
+	require.Equal(testSolanaTransactionReceiver, outputs[0].Destination)
+	require.Equal(int64(testSolanaTransferLamports), outputs[0].Amount.Int64())
+
+	stx, err := node.store.ReadTransaction(ctx, t.TxHash)
+	require.Nil(err)
+	require.Equal(hex.EncodeToString(t.Marshal()), stx.RawTransaction)
+	require.Equal("[{\"amount\":\"0.0001\",\"receiver\":\""+testSolanaTransactionReceiver+"\"}]", stx.Data)
+	require.Equal(common.RequestStateInitial, stx.State)
+	return stx.TransactionHash
+}
+
+func testSolanaApproveTransaction(ctx context.Context, require *require.Assertions, node *Node, transactionHash, bondId string, signers []*signer.Node) string {
+	id := uuid.Must(uuid.NewV4()).String()
+
+	tx, _ := node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateInitial, tx.State)
+	raw, err := hex.DecodeString(tx.RawTransaction)
+	require.Nil(err)
+	t, err := solana.UnmarshalSafeTransaction(raw)
+	require.Nil(err)
+
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	safe, _ := node.store.ReadSafe(ctx, tx.Holder)
+	owners := solana.GetSafeOwnersWithHolders([]string{holder}, safe.Signer, safe.Observer)
+	t.SetSignature(0, testSolanaSignMessage(testSolanaKeyHolder, t.Message))
+
+	raw = t.Marshal()
+	ref := mc.Sha256Hash(raw)
+	err = node.store.WriteProperty(ctx, ref.String(), base64.RawURLEncoding.EncodeToString(raw))
+	require.Nil(err)
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	extra = append(extra, ref[:]...)
+
+	out := testBuildObserverRequest(node, id, holder, common.ActionSolanaSafeApproveTransaction, extra, common.CurveEdwards25519Solana)
+	testStep(ctx, require, node, out)
+	requests, err := node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateInitial)
+	require.Nil(err)
+	require.Len(requests, 1)
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStatePending, tx.State)
+
+	msg, _ := hex.DecodeString(requests[0].Message)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignInput, msg, common.CurveEdwards25519Default)
+	op := signer.TestProcessOutput(ctx, require, signers, out, requests[0].RequestId)
+	out = testBuildSignerOutput(node, requests[0].RequestId, safe.Signer, common.OperationTypeSignOutput, op.Extra, common.CurveEdwards25519Default)
+	testStep(ctx, require, node, out)
+
+	tx, _ = node.store.ReadTransaction(ctx, transactionHash)
+	require.Equal(common.RequestStateDone, tx.State)
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStatePending)
+	require.Len(requests, 0)
+	requests, _ = node.store.ListAllSignaturesForTransaction(ctx, transactionHash, common.RequestStateDone)
+	require.Len(requests, 1)
+	require.True(solana.CheckTransactionPartiallySignedBy(tx.RawTransaction, owners, holder))
+	require.True(solana.CheckTransactionPartiallySignedBy(tx.RawTransaction, owners, safe.Signer))
+
+	mb := common.DecodeHexOrPanic(tx.RawTransaction)
+	sTraceId := mc.Blake3Hash([]byte(common.Base91Encode(mb))).String()
+	sTraceId = mtg.UniqueId(sTraceId, sTraceId)
+	rid := common.UniqueId(tx.TransactionHash, sTraceId)
+	b := testReadObserverResponse(ctx, require, node, rid, common.ActionSolanaSafeApproveTransaction)
+	require.Equal(mb, b)
+
+	balance, err := node.store.ReadEthereumBalance(ctx, safe.Address, common.SafeSolanaChainId, bondId)
+	require.Nil(err)
+	require.Equal(int64(testSolanaDepositLamports-testSolanaTransferLamports), balance.BigBalance().Int64())
+
+	safe, _ = node.store.ReadSafe(ctx, tx.Holder)
+	require.Equal(int64(2), safe.Nonce)
+	return tx.RawTransaction
+}
+
+// testSolanaSendTransaction builds the fully signed safe transaction with a
+// new durable nonce account of the fee payer, and finalizes it in the test
+// solana chain
+func testSolanaSendTransaction(require *require.Assertions, node *Node, raw string) {
+	t, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	proposal, err := node.store.ReadSafeProposalByAddress(context.Background(), t.SafeAddress)
+	require.Nil(err)
+	owners := solana.GetSafeOwnersWithHolders([]string{holder}, proposal.Signer, proposal.Observer)
+
+	payer := testSolanaPrivateKey(testSolanaKeyPayer)
+	payerAddress := solana.PublicKey(payer.Public().(ed25519.PublicKey))
+	testNetwork.solana.Deposit(testSolanaDepositSender, payerAddress.String(), 100000000)
+	nonceAccount := testNetwork.solana.CreateNonceAccount(payerAddress.String())
+	testNetwork.solana.Mine(1)
+
+	authority, nonce, err := solana.RPCGetNonceAccount(node.conf.SolanaRPC, nonceAccount)
+	require.Nil(err)
+	require.Equal(payerAddress, authority)
+	tx, err := t.BuildTransaction(owners, authority, solana.MustPublicKeyFromBase58(nonceAccount), nonce)
+	require.Nil(err)
+	require.Nil(tx.Sign(payer))
+	hash, err := solana.RPCSendTransaction(node.conf.SolanaRPC, tx)
+	require.Nil(err)
+	require.Equal(tx.Hash(), hash)
+	testNetwork.solana.Mine(1)
+}
+
+func testSolanaPrivateKey(priv string) ed25519.PrivateKey {
+	seed := common.DecodeHexOrPanic(priv)
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+func testSolanaPublicKey(priv string) string {
+	pub := testSolanaPrivateKey(priv).Public().(ed25519.PublicKey)
+	return hex.EncodeToString(pub)
+}
+
+func testSolanaSignMessage(priv string, msg []byte) []byte {
+	return ed25519.Sign(testSolanaPrivateKey(priv), msg)
+}
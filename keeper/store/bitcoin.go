@@ -116,6 +116,29 @@ func (s *SQLite3Store) ReadUnspentUtxoCountForSafe(ctx context.Context, address
 	return count, err
 }
 
+// ReadOldestBitcoinUTXOForSafe returns the oldest unspent output of the safe,
+// which decides when the observer key could be used for recovery
+func (s *SQLite3Store) ReadOldestBitcoinUTXOForSafe(ctx context.Context, address string) (*bitcoin.Input, time.Time, error) {
+	query := "SELECT transaction_hash, output_index, satoshi, created_at FROM bitcoin_outputs WHERE address=? AND state IN (?, ?) ORDER BY created_at ASC, request_id ASC LIMIT 1"
+	row := s.db.QueryRowContext(ctx, query, address, common.RequestStateInitial, common.RequestStatePending)
+
+	var input bitcoin.Input
+	var createdAt time.Time
+	err := row.Scan(&input.TransactionHash, &input.Index, &input.Satoshi, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, time.Time{}, nil
+	}
+	return &input, createdAt, err
+}
+
+func (s *SQLite3Store) ReadBitcoinSpentSatoshi(ctx context.Context, transactionHash string) (int64, error) {
+	query := "SELECT COALESCE(SUM(satoshi), 0) FROM bitcoin_outputs WHERE spent_by=?"
+	row := s.db.QueryRowContext(ctx, query, transactionHash)
+	var satoshi int64
+	err := row.Scan(&satoshi)
+	return satoshi, err
+}
+
 func (s *SQLite3Store) listAllBitcoinUTXOsForAddress(ctx context.Context, receiver string, chain byte, state int) ([]*bitcoin.Input, error) {
 	cols := strings.Join([]string{"transaction_hash", "output_index", "satoshi", "script", "sequence"}, ",")
 	query := fmt.Sprintf("SELECT %s FROM bitcoin_outputs WHERE address=? AND state=? ORDER BY created_at ASC, request_id ASC", cols)
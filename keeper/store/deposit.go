@@ -46,3 +46,23 @@ func (s *SQLite3Store) ReadDeposit(ctx context.Context, hash string, index int64
 	}
 	return &d, err
 }
+
+func (s *SQLite3Store) ListDepositsForHolder(ctx context.Context, holder string, until time.Time) ([]*Deposit, error) {
+	query := fmt.Sprintf("SELECT %s FROM deposits WHERE holder=? AND state=? AND created_at<? ORDER BY created_at ASC, transaction_hash ASC, output_index ASC", strings.Join(depositsCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, holder, common.RequestStateDone, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []*Deposit
+	for rows.Next() {
+		var d Deposit
+		err = rows.Scan(&d.TransactionHash, &d.OutputIndex, &d.AssetId, &d.Amount, &d.Receiver, &d.Sender, &d.State, &d.Chain, &d.Holder, &d.Category, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		deposits = append(deposits, &d)
+	}
+	return deposits, nil
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SafeHolders is written only for the safe with multiple holder keys, the
+// holder of the safe is always the first key
+type SafeHolders struct {
+	RequestId string
+	Holder    string
+	Chain     byte
+	Address   string
+	Keys      []string
+	Threshold byte
+	CreatedAt time.Time
+}
+
+var safeHoldersCols = []string{"request_id", "holder", "chain", "address", "keys", "threshold", "created_at"}
+
+func (h *SafeHolders) values() []any {
+	return []any{h.RequestId, h.Holder, h.Chain, h.Address, strings.Join(h.Keys, ";"), h.Threshold, h.CreatedAt}
+}
+
+func (s *SQLite3Store) ReadSafeHolders(ctx context.Context, address string) (*SafeHolders, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_holders WHERE address=?", strings.Join(safeHoldersCols, ","))
+	row := s.db.QueryRowContext(ctx, query, address)
+
+	var h SafeHolders
+	var keys string
+	err := row.Scan(&h.RequestId, &h.Holder, &h.Chain, &h.Address, &keys, &h.Threshold, &h.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	h.Keys = strings.Split(keys, ";")
+	return &h, nil
+}
+
+// ReadSafeHolderKeys returns the holder keys and threshold of the safe
+// address, which is the single holder with threshold 1 for the safe
+// without multiple holder keys
+func (s *SQLite3Store) ReadSafeHolderKeys(ctx context.Context, address, holder string) ([]string, int, error) {
+	h, err := s.ReadSafeHolders(ctx, address)
+	if err != nil {
+		return nil, 0, err
+	} else if h == nil {
+		return []string{holder}, 1, nil
+	}
+	if h.Holder != holder {
+		panic(fmt.Errorf("safe holders %s mismatch %s", h.Holder, holder))
+	}
+	return h.Keys, int(h.Threshold), nil
+}
+
+func (s *SQLite3Store) writeSafeHolders(ctx context.Context, tx *sql.Tx, h *SafeHolders) error {
+	err := s.execOne(ctx, tx, buildInsertionSQL("safe_holders", safeHoldersCols), h.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_holders %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+// SafeMessage is the message signed by the holders and signer of the safe,
+// the message hash is used as the transaction hash of the signature request.
+// The raw is the holders signed PSBT of the BIP-322 message for the Bitcoin
+// like chains, or the holders signatures of the safe message for Ethereum.
+type SafeMessage struct {
+	MessageId   string
+	MessageHash string
+	Holder      string
+	Chain       byte
+	Message     string
+	Raw         string
+	Proof       sql.NullString
+	RequestId   string
+	State       int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+var safeMessageCols = []string{"message_id", "message_hash", "holder", "chain", "message", "raw", "proof", "request_id", "state", "created_at", "updated_at"}
+
+func (m *SafeMessage) values() []any {
+	return []any{m.MessageId, m.MessageHash, m.Holder, m.Chain, m.Message, m.Raw, m.Proof, m.RequestId, m.State, m.CreatedAt, m.UpdatedAt}
+}
+
+func safeMessageFromRow(row *sql.Row) (*SafeMessage, error) {
+	var m SafeMessage
+	err := row.Scan(&m.MessageId, &m.MessageHash, &m.Holder, &m.Chain, &m.Message, &m.Raw, &m.Proof, &m.RequestId, &m.State, &m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *SQLite3Store) ReadSafeMessage(ctx context.Context, id string) (*SafeMessage, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_messages WHERE message_id=?", strings.Join(safeMessageCols, ","))
+	row := s.db.QueryRowContext(ctx, query, id)
+	return safeMessageFromRow(row)
+}
+
+func (s *SQLite3Store) ReadSafeMessageByHash(ctx context.Context, hash string) (*SafeMessage, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_messages WHERE message_hash=?", strings.Join(safeMessageCols, ","))
+	row := s.db.QueryRowContext(ctx, query, hash)
+	return safeMessageFromRow(row)
+}
+
+// WriteSafeMessageWithRequest writes the message if not exists, and the new
+// signature requests of the message, which may be requested again after the
+// previous signature request timeout
+func (s *SQLite3Store) WriteSafeMessageWithRequest(ctx context.Context, m *SafeMessage, requests []*SignatureRequest, txs []*mtg.Transaction, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT message_id FROM safe_messages WHERE message_id=?", m.MessageId)
+	if err != nil {
+		return err
+	}
+	if !existed {
+		err = s.execOne(ctx, tx, buildInsertionSQL("safe_messages", safeMessageCols), m.values()...)
+		if err != nil {
+			return fmt.Errorf("INSERT safe_messages %v", err)
+		}
+	}
+
+	for _, r := range requests {
+		vals := []any{r.RequestId, r.TransactionHash, r.InputIndex, r.Signer, r.Curve, r.Message, r.Signature, r.State, r.CreatedAt, r.UpdatedAt}
+		err = s.execOne(ctx, tx, buildInsertionSQL("signature_requests", signatureCols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT signature_requests %v", err)
+		}
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) FinishSafeMessageWithRequest(ctx context.Context, m *SafeMessage, proof string, txs []*mtg.Transaction, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	_, err = tx.ExecContext(ctx, "UPDATE signature_requests SET state=?, updated_at=? WHERE transaction_hash=?",
+		common.RequestStateDone, req.CreatedAt, m.MessageHash)
+	if err != nil {
+		return fmt.Errorf("UPDATE signature_requests %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE safe_messages SET proof=?, state=?, updated_at=? WHERE message_id=? AND state=?",
+		proof, common.RequestStateDone, req.CreatedAt, m.MessageId, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_messages %v", err)
+	}
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -103,6 +103,33 @@ func (s *SQLite3Store) CheckFullyMigrated(ctx context.Context) bool {
 	return true
 }
 
+// the proposal expiry is added to the operation params after the table
+// created, and all the old params never expire the proposals
+func (s *SQLite3Store) MigrateOperationParamsExpiry(ctx context.Context) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	query := "SELECT COUNT(*) FROM pragma_table_info('operation_params') WHERE name='proposal_expiry'"
+	err = tx.QueryRowContext(ctx, query).Scan(&count)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	query = "ALTER TABLE operation_params ADD COLUMN proposal_expiry INTEGER NOT NULL DEFAULT 0"
+	_, err = tx.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 // FIXME remove this
 func (s *SQLite3Store) MigrateDepositCreated(ctx context.Context) error {
 	s.mutex.Lock()
@@ -184,6 +211,7 @@ func (s *SQLite3Store) Migrate(ctx context.Context, ss, es []*MigrateAsset) erro
 		switch asset.Chain {
 		case common.SafeChainBitcoin:
 		case common.SafeChainLitecoin:
+		case common.SafeChainDogecoin:
 		default:
 			sql := "UPDATE ethereum_balances SET safe_asset_id=? where address=? AND asset_id=? AND safe_asset_id IS NULL"
 			err = s.execOne(ctx, tx, sql, asset.SafeAssetId, asset.Address, asset.AssetId)
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+// SafeMigration moves all funds of the safe at address to the new safe
+// proposed by the same request, the old safe is closed when the sweep
+// transaction is fully signed
+type SafeMigration struct {
+	RequestId       string
+	Chain           byte
+	Holder          string
+	Address         string
+	NewHolder       string
+	NewAddress      string
+	TransactionHash string
+	State           int
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var safeMigrationCols = []string{"request_id", "chain", "holder", "address", "new_holder", "new_address", "transaction_hash", "state", "created_at", "updated_at"}
+
+func (m *SafeMigration) values() []any {
+	return []any{m.RequestId, m.Chain, m.Holder, m.Address, m.NewHolder, m.NewAddress, m.TransactionHash, m.State, m.CreatedAt, m.UpdatedAt}
+}
+
+func safeMigrationFromRow(row *sql.Row) (*SafeMigration, error) {
+	var m SafeMigration
+	err := row.Scan(&m.RequestId, &m.Chain, &m.Holder, &m.Address, &m.NewHolder, &m.NewAddress, &m.TransactionHash, &m.State, &m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *SQLite3Store) ReadSafeMigrationByTransaction(ctx context.Context, transactionHash string) (*SafeMigration, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_migrations WHERE transaction_hash=?", strings.Join(safeMigrationCols, ","))
+	row := s.db.QueryRowContext(ctx, query, transactionHash)
+	return safeMigrationFromRow(row)
+}
+
+// ReadLatestSafeMigration returns the last migration from the safe address
+func (s *SQLite3Store) ReadLatestSafeMigration(ctx context.Context, address string) (*SafeMigration, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_migrations WHERE address=? ORDER BY created_at DESC, request_id DESC LIMIT 1", strings.Join(safeMigrationCols, ","))
+	row := s.db.QueryRowContext(ctx, query, address)
+	return safeMigrationFromRow(row)
+}
+
+// WriteSafeMigrationWithRequest writes the new safe proposal and the sweep
+// transaction of the old safe in one go, the deploy transaction is only
+// required for the Ethereum like chains
+func (s *SQLite3Store) WriteSafeMigrationWithRequest(ctx context.Context, m *SafeMigration, sp *SafeProposal, holders *SafeHolders, policy *SafePolicy, deploy, trx *Transaction, utxos []*TransactionInput, outflow *SafeOutflow, txs []*mtg.Transaction, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_proposals", safeProposalCols), sp.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_proposals %v", err)
+	}
+	if holders != nil {
+		err = s.writeSafeHolders(ctx, tx, holders)
+		if err != nil {
+			return err
+		}
+	}
+	if policy != nil {
+		err = s.writeSafePolicy(ctx, tx, policy)
+		if err != nil {
+			return err
+		}
+	}
+	if deploy != nil {
+		vals := []any{deploy.TransactionHash, deploy.RawTransaction, deploy.Holder, deploy.Chain, deploy.AssetId, deploy.State, deploy.Data, deploy.RequestId, deploy.CreatedAt, deploy.UpdatedAt}
+		err = s.execOne(ctx, tx, buildInsertionSQL("transactions", transactionCols), vals...)
+		if err != nil {
+			return fmt.Errorf("INSERT transactions %v", err)
+		}
+	}
+
+	err = s.writeTransactionWithRequest(ctx, tx, trx, utxos, common.RequestStatePending)
+	if err != nil {
+		return err
+	}
+	if outflow != nil {
+		err = s.writeSafeOutflow(ctx, tx, outflow)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_migrations", safeMigrationCols), m.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_migrations %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SafeMigrationConversion converts the amount of the old safe asset of the
+// migration to the new safe asset, the old safe asset is kept by the keeper
+type SafeMigrationConversion struct {
+	RequestId   string
+	MigrationId string
+	AssetId     string
+	Amount      decimal.Decimal
+	CreatedAt   time.Time
+}
+
+var safeMigrationConversionCols = []string{"request_id", "migration_id", "asset_id", "amount", "created_at"}
+
+// ReadSafeMigrationConvertedAmount returns the total amount of the old safe
+// asset already converted for the migration
+func (s *SQLite3Store) ReadSafeMigrationConvertedAmount(ctx context.Context, migrationId, assetId string) (decimal.Decimal, error) {
+	query := "SELECT amount FROM safe_migration_conversions WHERE migration_id=? AND asset_id=?"
+	rows, err := s.db.QueryContext(ctx, query, migrationId, assetId)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer rows.Close()
+
+	total := decimal.Zero
+	for rows.Next() {
+		var amount string
+		err := rows.Scan(&amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(decimal.RequireFromString(amount))
+	}
+	return total, rows.Err()
+}
+
+func (s *SQLite3Store) WriteSafeMigrationConversionWithRequest(ctx context.Context, c *SafeMigrationConversion, txs []*mtg.Transaction, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	vals := []any{c.RequestId, c.MigrationId, c.AssetId, c.Amount.String(), c.CreatedAt}
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_migration_conversions", safeMigrationConversionCols), vals...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_migration_conversions %v", err)
+	}
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// finishSafeMigration closes the old safe if the transaction is a migration
+// sweep, together with the transaction signatures finished
+func (s *SQLite3Store) finishSafeMigration(ctx context.Context, tx *sql.Tx, transactionHash string, updatedAt time.Time) error {
+	existed, err := s.checkExistence(ctx, tx, "SELECT request_id FROM safe_migrations WHERE transaction_hash=? AND state=?", transactionHash, common.RequestStatePending)
+	if err != nil || !existed {
+		return err
+	}
+
+	var holder string
+	row := tx.QueryRowContext(ctx, "SELECT holder FROM safe_migrations WHERE transaction_hash=?", transactionHash)
+	err = row.Scan(&holder)
+	if err != nil {
+		return err
+	}
+	err = s.execOne(ctx, tx, "UPDATE safes SET state=?, updated_at=? WHERE holder=? AND state=?",
+		common.RequestStateFailed, updatedAt, holder, common.RequestStateDone)
+	if err != nil {
+		return fmt.Errorf("UPDATE safes %v", err)
+	}
+	err = s.execOne(ctx, tx, "UPDATE safe_migrations SET state=?, updated_at=? WHERE transaction_hash=? AND state=?",
+		common.RequestStateDone, updatedAt, transactionHash, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_migrations %v", err)
+	}
+	return nil
+}
+
+func (s *SQLite3Store) failSafeMigration(ctx context.Context, tx *sql.Tx, transactionHash string, updatedAt time.Time) error {
+	_, err := tx.ExecContext(ctx, "UPDATE safe_migrations SET state=?, updated_at=? WHERE transaction_hash=? AND state=?",
+		common.RequestStateFailed, updatedAt, transactionHash, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_migrations %v", err)
+	}
+	return nil
+}
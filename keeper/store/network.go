@@ -37,12 +37,13 @@ type OperationParams struct {
 	OperationPriceAsset  string
 	OperationPriceAmount decimal.Decimal
 	TransactionMinimum   decimal.Decimal
+	ProposalExpiry       time.Duration
 	CreatedAt            time.Time
 }
 
 var assetCols = []string{"asset_id", "mixin_id", "asset_key", "symbol", "name", "decimals", "chain", "created_at"}
 var infoCols = []string{"request_id", "chain", "fee", "height", "hash", "created_at"}
-var paramsCols = []string{"request_id", "chain", "price_asset", "price_amount", "transaction_minimum", "created_at"}
+var paramsCols = []string{"request_id", "chain", "price_asset", "price_amount", "transaction_minimum", "proposal_expiry", "created_at"}
 
 func (s *SQLite3Store) ReadNetworkInfo(ctx context.Context, id string) (*NetworkInfo, error) {
 	query := fmt.Sprintf("SELECT %s FROM network_infos WHERE request_id=?", strings.Join(infoCols, ","))
@@ -103,7 +104,8 @@ func (s *SQLite3Store) ReadLatestOperationParams(ctx context.Context, chain byte
 
 	var p OperationParams
 	var price, minimum string
-	err := row.Scan(&p.RequestId, &p.Chain, &p.OperationPriceAsset, &price, &minimum, &p.CreatedAt)
+	var expiry int64
+	err := row.Scan(&p.RequestId, &p.Chain, &p.OperationPriceAsset, &price, &minimum, &expiry, &p.CreatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
@@ -111,6 +113,7 @@ func (s *SQLite3Store) ReadLatestOperationParams(ctx context.Context, chain byte
 	}
 	p.OperationPriceAmount = decimal.RequireFromString(price)
 	p.TransactionMinimum = decimal.RequireFromString(minimum)
+	p.ProposalExpiry = time.Duration(expiry) * time.Second
 	return &p, nil
 }
 
@@ -131,7 +134,8 @@ func (s *SQLite3Store) WriteOperationParamsFromRequest(ctx context.Context, para
 
 	amount := params.OperationPriceAmount.String()
 	minimum := params.TransactionMinimum.String()
-	vals := []any{params.RequestId, params.Chain, params.OperationPriceAsset, amount, minimum, params.CreatedAt}
+	expiry := int64(params.ProposalExpiry / time.Second)
+	vals := []any{params.RequestId, params.Chain, params.OperationPriceAsset, amount, minimum, expiry, params.CreatedAt}
 	err = s.execOne(ctx, tx, buildInsertionSQL("operation_params", paramsCols), vals...)
 	if err != nil {
 		return fmt.Errorf("INSERT operation_params %v", err)
@@ -178,3 +182,48 @@ func (s *SQLite3Store) WriteAssetMeta(ctx context.Context, asset *Asset) error {
 	}
 	return tx.Commit()
 }
+
+func (s *SQLite3Store) ListNetworkInfos(ctx context.Context, chain byte, offset time.Time) ([]*NetworkInfo, error) {
+	query := fmt.Sprintf("SELECT %s FROM network_infos WHERE chain=? AND created_at>=? ORDER BY created_at ASC, request_id ASC LIMIT 100", strings.Join(infoCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []*NetworkInfo
+	for rows.Next() {
+		var n NetworkInfo
+		err := rows.Scan(&n.RequestId, &n.Chain, &n.Fee, &n.Height, &n.Hash, &n.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, &n)
+	}
+	return infos, nil
+}
+
+func (s *SQLite3Store) ListOperationParams(ctx context.Context, chain byte, offset time.Time) ([]*OperationParams, error) {
+	query := fmt.Sprintf("SELECT %s FROM operation_params WHERE chain=? AND created_at>=? ORDER BY created_at ASC, request_id ASC LIMIT 100", strings.Join(paramsCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var params []*OperationParams
+	for rows.Next() {
+		var p OperationParams
+		var price, minimum string
+		var expiry int64
+		err := rows.Scan(&p.RequestId, &p.Chain, &p.OperationPriceAsset, &price, &minimum, &expiry, &p.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		p.OperationPriceAmount = decimal.RequireFromString(price)
+		p.TransactionMinimum = decimal.RequireFromString(minimum)
+		p.ProposalExpiry = time.Duration(expiry) * time.Second
+		params = append(params, &p)
+	}
+	return params, nil
+}
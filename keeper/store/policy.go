@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/shopspring/decimal"
+)
+
+type SafePolicy struct {
+	RequestId string
+	Holder    string
+	Chain     byte
+	Policy    string
+	ActiveAt  time.Time
+	CreatedAt time.Time
+}
+
+type SafeOutflow struct {
+	RequestId       string
+	Holder          string
+	TransactionHash string
+	AssetId         string
+	Amount          decimal.Decimal
+	UnlockAt        time.Time
+	CreatedAt       time.Time
+}
+
+var safePolicyCols = []string{"request_id", "holder", "chain", "policy", "active_at", "created_at"}
+
+func (p *SafePolicy) values() []any {
+	return []any{p.RequestId, p.Holder, p.Chain, p.Policy, p.ActiveAt, p.CreatedAt}
+}
+
+// the policy is verified before written, so it should never fail to parse
+func (p *SafePolicy) Parse() *common.SafePolicy {
+	sp, err := common.ParseSafePolicy([]byte(p.Policy), p.Chain)
+	if err != nil {
+		panic(fmt.Errorf("common.ParseSafePolicy(%s) => %v", p.Policy, err))
+	}
+	return sp
+}
+
+var safeOutflowCols = []string{"request_id", "holder", "transaction_hash", "asset_id", "amount", "unlock_at", "created_at"}
+
+func (o *SafeOutflow) values() []any {
+	return []any{o.RequestId, o.Holder, o.TransactionHash, o.AssetId, o.Amount.String(), o.UnlockAt, o.CreatedAt}
+}
+
+func (s *SQLite3Store) WriteSafePolicyWithRequest(ctx context.Context, p *SafePolicy, txs []*mtg.Transaction, req *common.Request) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.writeSafePolicy(ctx, tx, p)
+	if err != nil {
+		return err
+	}
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReadActiveSafePolicy returns the latest policy of the holder which has been
+// active at the offset, a policy update may be pending until the delay passed
+func (s *SQLite3Store) ReadActiveSafePolicy(ctx context.Context, holder string, offset time.Time) (*SafePolicy, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_policies WHERE holder=? AND active_at<=? ORDER BY active_at DESC, created_at DESC, request_id DESC LIMIT 1", strings.Join(safePolicyCols, ","))
+	row := s.db.QueryRowContext(ctx, query, holder, offset)
+
+	var p SafePolicy
+	err := row.Scan(&p.RequestId, &p.Holder, &p.Chain, &p.Policy, &p.ActiveAt, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &p, err
+}
+
+func (s *SQLite3Store) ReadSafeOutflow(ctx context.Context, transactionHash string) (*SafeOutflow, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_outflows WHERE transaction_hash=?", strings.Join(safeOutflowCols, ","))
+	row := s.db.QueryRowContext(ctx, query, transactionHash)
+
+	var o SafeOutflow
+	var amount string
+	err := row.Scan(&o.RequestId, &o.Holder, &o.TransactionHash, &o.AssetId, &amount, &o.UnlockAt, &o.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	o.Amount = decimal.RequireFromString(amount)
+	return &o, nil
+}
+
+// ReadSafeOutflowTotal sums the outflows of the asset after the offset,
+// and the revoked transactions are excluded
+func (s *SQLite3Store) ReadSafeOutflowTotal(ctx context.Context, holder, assetId string, offset time.Time) (decimal.Decimal, error) {
+	query := "SELECT o.amount FROM safe_outflows o JOIN transactions t ON o.transaction_hash=t.transaction_hash WHERE o.holder=? AND o.asset_id=? AND o.created_at>? AND t.state!=?"
+	rows, err := s.db.QueryContext(ctx, query, holder, assetId, offset, common.RequestStateFailed)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	defer rows.Close()
+
+	total := decimal.Zero
+	for rows.Next() {
+		var amount string
+		err = rows.Scan(&amount)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(decimal.RequireFromString(amount))
+	}
+	return total, nil
+}
+
+func (s *SQLite3Store) FailRequestWithPolicyViolation(ctx context.Context, req *common.Request, reason string, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=? AND state=?",
+		common.RequestStateFailed, time.Now().UTC(), req.Id, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+	err = s.execOne(ctx, tx, "INSERT INTO safe_policy_violations (request_id, holder, reason, created_at) VALUES (?, ?, ?, ?)",
+		req.Id, req.Holder, reason, req.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_policy_violations %v", err)
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadSafePolicyViolation(ctx context.Context, requestId string) (string, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT reason FROM safe_policy_violations WHERE request_id=?", requestId)
+
+	var reason string
+	err := row.Scan(&reason)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return reason, err
+}
+
+func (s *SQLite3Store) writeSafeOutflow(ctx context.Context, tx *sql.Tx, o *SafeOutflow) error {
+	err := s.execOne(ctx, tx, buildInsertionSQL("safe_outflows", safeOutflowCols), o.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_outflows %v", err)
+	}
+	return nil
+}
+
+func (s *SQLite3Store) writeSafePolicy(ctx context.Context, tx *sql.Tx, p *SafePolicy) error {
+	err := s.execOne(ctx, tx, buildInsertionSQL("safe_policies", safePolicyCols), p.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_policies %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+// ReplayDiff is the first row divergence of a table between the live keeper
+// store and the store rebuilt by replaying the MTG actions
+type ReplayDiff struct {
+	Table  string            `json:"table"`
+	Index  int               `json:"index"`
+	Live   map[string]string `json:"live"`
+	Replay map[string]string `json:"replay"`
+}
+
+// these columns are written with the local clock, so they are not expected
+// to be identical among keepers
+var replayVolatileColumns = map[string][]string{
+	"":               {"updated_at"},
+	"action_results": {"created_at"},
+	"properties":     {"created_at"},
+}
+
+// ReadActionResultByOutputId reads the action result regardless of the request
+// state, an action not handled by the keeper has no result
+func (s *SQLite3Store) ReadActionResultByOutputId(ctx context.Context, outputId string) (*ActionResult, error) {
+	cols := strings.Join(requestTransactionsCols, ",")
+	row := s.db.QueryRowContext(ctx, fmt.Sprintf("SELECT %s FROM action_results where output_id=?", cols), outputId)
+	var ar ActionResult
+	var data string
+	err := row.Scan(&ar.ActionId, &ar.Compaction, &data, &ar.RequestId, &ar.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tb, err := common.Base91Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	ar.Transactions, err = mtg.DeserializeTransactions(tb)
+	return &ar, err
+}
+
+// DiffTables compares all tables of the live store with the replay store,
+// rows are ordered by all stable columns and the first divergence returned
+func (s *SQLite3Store) DiffTables(ctx context.Context, replay *SQLite3Store) (*ReplayDiff, error) {
+	tables, err := s.listTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		cols, err := s.listStableColumns(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		diff, err := s.diffTable(ctx, replay, t, cols)
+		if err != nil || diff != nil {
+			return diff, err
+		}
+	}
+	return nil, nil
+}
+
+func (s *SQLite3Store) diffTable(ctx context.Context, replay *SQLite3Store, table string, cols []string) (*ReplayDiff, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", strings.Join(cols, ","), table, strings.Join(cols, ","))
+	live, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer live.Close()
+	rebuilt, err := replay.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rebuilt.Close()
+
+	for i := 0; ; i++ {
+		lr, err := scanReplayRow(live, cols)
+		if err != nil {
+			return nil, err
+		}
+		rr, err := scanReplayRow(rebuilt, cols)
+		if err != nil {
+			return nil, err
+		}
+		if lr == nil && rr == nil {
+			return nil, nil
+		}
+		if lr == nil || rr == nil || !equalReplayRow(lr, rr) {
+			return &ReplayDiff{Table: table, Index: i, Live: lr, Replay: rr}, nil
+		}
+	}
+}
+
+func (s *SQLite3Store) listTables(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+func (s *SQLite3Store) listStableColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT name FROM pragma_table_info('%s') ORDER BY cid", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		if slices.Contains(replayVolatileColumns[""], name) {
+			continue
+		}
+		if slices.Contains(replayVolatileColumns[table], name) {
+			continue
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
+
+func scanReplayRow(rows *sql.Rows, cols []string) (map[string]string, error) {
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	vals := make([]any, len(cols))
+	ptrs := make([]any, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	err := rows.Scan(ptrs...)
+	if err != nil {
+		return nil, err
+	}
+	row := make(map[string]string, len(cols))
+	for i, c := range cols {
+		switch v := vals[i].(type) {
+		case nil:
+			row[c] = "NULL"
+		case []byte:
+			row[c] = string(v)
+		case time.Time:
+			row[c] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			row[c] = fmt.Sprint(v)
+		}
+	}
+	return row, nil
+}
+
+func equalReplayRow(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
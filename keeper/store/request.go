@@ -97,3 +97,33 @@ func (s *SQLite3Store) ReadLatestRequest(ctx context.Context) (*common.Request,
 
 	return requestFromRow(row)
 }
+
+func (s *SQLite3Store) ListRequests(ctx context.Context, state int, offset uint64) ([]*common.Request, error) {
+	query := fmt.Sprintf("SELECT %s FROM requests WHERE sequence>=? ORDER BY sequence ASC, request_id ASC LIMIT 100", strings.Join(requestCols, ","))
+	params := []any{offset}
+	if state > 0 {
+		query = fmt.Sprintf("SELECT %s FROM requests WHERE state=? AND sequence>=? ORDER BY sequence ASC, request_id ASC LIMIT 100", strings.Join(requestCols, ","))
+		params = []any{state, offset}
+	}
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []*common.Request
+	for rows.Next() {
+		var mh string
+		var r common.Request
+		err := rows.Scan(&r.Id, &mh, &r.MixinIndex, &r.AssetId, &r.Amount, &r.Role, &r.Action, &r.Curve, &r.Holder, &r.ExtraHEX, &r.State, &r.CreatedAt, &time.Time{}, &r.Sequence)
+		if err != nil {
+			return nil, err
+		}
+		r.MixinHash, err = crypto.HashFromString(mh)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, &r)
+	}
+	return requests, nil
+}
@@ -96,7 +96,7 @@ func (s *SQLite3Store) ReadSafeProposalByAddress(ctx context.Context, addr strin
 	return safeProposalFromRow(row)
 }
 
-func (s *SQLite3Store) WriteSafeProposalWithRequest(ctx context.Context, sp *SafeProposal, txs []*mtg.Transaction, req *common.Request) error {
+func (s *SQLite3Store) WriteSafeProposalWithRequest(ctx context.Context, sp *SafeProposal, holders *SafeHolders, policy *SafePolicy, txs []*mtg.Transaction, req *common.Request) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -110,6 +110,18 @@ func (s *SQLite3Store) WriteSafeProposalWithRequest(ctx context.Context, sp *Saf
 	if err != nil {
 		return fmt.Errorf("INSERT safe_proposals %v", err)
 	}
+	if holders != nil {
+		err = s.writeSafeHolders(ctx, tx, holders)
+		if err != nil {
+			return err
+		}
+	}
+	if policy != nil {
+		err = s.writeSafePolicy(ctx, tx, policy)
+		if err != nil {
+			return err
+		}
+	}
 	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
 		common.RequestStateDone, time.Now().UTC(), sp.RequestId)
 	if err != nil {
@@ -123,7 +135,7 @@ func (s *SQLite3Store) WriteSafeProposalWithRequest(ctx context.Context, sp *Saf
 	return tx.Commit()
 }
 
-func (s *SQLite3Store) WriteEthereumSafeProposalWithRequest(ctx context.Context, sp *SafeProposal, trx *Transaction, txs []*mtg.Transaction, req *common.Request) error {
+func (s *SQLite3Store) WriteEthereumSafeProposalWithRequest(ctx context.Context, sp *SafeProposal, holders *SafeHolders, policy *SafePolicy, trx *Transaction, txs []*mtg.Transaction, req *common.Request) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -137,6 +149,18 @@ func (s *SQLite3Store) WriteEthereumSafeProposalWithRequest(ctx context.Context,
 	if err != nil {
 		return fmt.Errorf("INSERT safe_proposals %v", err)
 	}
+	if holders != nil {
+		err = s.writeSafeHolders(ctx, tx, holders)
+		if err != nil {
+			return err
+		}
+	}
+	if policy != nil {
+		err = s.writeSafePolicy(ctx, tx, policy)
+		if err != nil {
+			return err
+		}
+	}
 
 	vals := []any{trx.TransactionHash, trx.RawTransaction, trx.Holder, trx.Chain, trx.AssetId, trx.State, trx.Data, trx.RequestId, trx.CreatedAt, trx.UpdatedAt}
 	err = s.execOne(ctx, tx, buildInsertionSQL("transactions", transactionCols), vals...)
@@ -57,6 +57,7 @@ CREATE TABLE IF NOT EXISTS operation_params (
   price_asset          VARCHAR NOT NULL,
   price_amount         VARCHAR NOT NULL,
   transaction_minimum  VARCHAR NOT NULL,
+  proposal_expiry      INTEGER NOT NULL,
   created_at           TIMESTAMP NOT NULL,
   PRIMARY KEY ('request_id')
 );
@@ -283,3 +284,118 @@ CREATE TABLE IF NOT EXISTS properties (
   created_at    TIMESTAMP NOT NULL,
   PRIMARY KEY ('key')
 );
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_policies (
+  request_id    VARCHAR NOT NULL,
+  holder        VARCHAR NOT NULL,
+  chain         INTEGER NOT NULL,
+  policy        TEXT NOT NULL,
+  active_at     TIMESTAMP NOT NULL,
+  created_at    TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE INDEX IF NOT EXISTS safe_policies_by_holder_active ON safe_policies(holder, active_at);
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_holders (
+  request_id    VARCHAR NOT NULL,
+  holder        VARCHAR NOT NULL,
+  chain         INTEGER NOT NULL,
+  address       VARCHAR NOT NULL,
+  keys          TEXT NOT NULL,
+  threshold     INTEGER NOT NULL,
+  created_at    TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS safe_holders_by_address ON safe_holders(address);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_outflows (
+  request_id         VARCHAR NOT NULL,
+  holder             VARCHAR NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  asset_id           VARCHAR NOT NULL,
+  amount             VARCHAR NOT NULL,
+  unlock_at          TIMESTAMP NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS safe_outflows_by_transaction_hash ON safe_outflows(transaction_hash);
+CREATE INDEX IF NOT EXISTS safe_outflows_by_holder_asset_created ON safe_outflows(holder, asset_id, created_at);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_policy_violations (
+  request_id    VARCHAR NOT NULL,
+  holder        VARCHAR NOT NULL,
+  reason        VARCHAR NOT NULL,
+  created_at    TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_migrations (
+  request_id         VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  holder             VARCHAR NOT NULL,
+  address            VARCHAR NOT NULL,
+  new_holder         VARCHAR NOT NULL,
+  new_address        VARCHAR NOT NULL,
+  transaction_hash   VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS safe_migrations_by_transaction_hash ON safe_migrations(transaction_hash);
+CREATE INDEX IF NOT EXISTS safe_migrations_by_address_created ON safe_migrations(address, created_at);
+
+CREATE TABLE IF NOT EXISTS safe_migration_conversions (
+  request_id         VARCHAR NOT NULL,
+  migration_id       VARCHAR NOT NULL,
+  asset_id           VARCHAR NOT NULL,
+  amount             VARCHAR NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('request_id')
+);
+
+CREATE INDEX IF NOT EXISTS safe_migration_conversions_by_migration_asset ON safe_migration_conversions(migration_id, asset_id);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_messages (
+  message_id         VARCHAR NOT NULL,
+  message_hash       VARCHAR NOT NULL,
+  holder             VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  message            TEXT NOT NULL,
+  raw                TEXT NOT NULL,
+  proof              TEXT,
+  request_id         VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('message_id')
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS safe_messages_by_message_hash ON safe_messages(message_hash);
@@ -24,6 +24,14 @@ type SignatureRequest struct {
 	UpdatedAt       time.Time
 }
 
+// TransactionSignatureRequests are the signature requests of a transaction
+// in the batch approval, the raw is the holder signed transaction if not empty
+type TransactionSignatureRequests struct {
+	TransactionHash string
+	Raw             string
+	Requests        []*SignatureRequest
+}
+
 var signatureCols = []string{"request_id", "transaction_hash", "input_index", "signer", "curve", "message", "signature", "state", "created_at", "updated_at"}
 
 func (s *SQLite3Store) CloseAccountBySignatureRequestsWithRequest(ctx context.Context, requests []*SignatureRequest, transactionHash, raw string, req *common.Request, txs []*mtg.Transaction) error {
@@ -92,6 +100,50 @@ func (s *SQLite3Store) WriteSignatureRequestsWithRequest(ctx context.Context, re
 	return tx.Commit()
 }
 
+// WriteBatchSignatureRequestsWithRequest writes the signature requests of all
+// transactions approved by the batch request, the transactions without any new
+// signature request are left untouched
+func (s *SQLite3Store) WriteBatchSignatureRequestsWithRequest(ctx context.Context, batch []*TransactionSignatureRequests, req *common.Request, txs []*mtg.Transaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
+		common.RequestStateDone, time.Now().UTC(), req.Id)
+	if err != nil {
+		return fmt.Errorf("UPDATE requests %v", err)
+	}
+
+	for _, b := range batch {
+		if len(b.Requests) == 0 {
+			continue
+		}
+		err = s.writeSignatureRequests(ctx, tx, b.Requests, b.TransactionHash, req)
+		if err != nil {
+			return err
+		}
+		if b.Raw == "" {
+			continue
+		}
+		err = s.execOne(ctx, tx, "UPDATE transactions SET raw_transaction=?, updated_at=? WHERE transaction_hash=?",
+			b.Raw, time.Now().UTC(), b.TransactionHash)
+		if err != nil {
+			return fmt.Errorf("UPDATE transactions %v", err)
+		}
+	}
+
+	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, req.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) writeSignatureRequestsWithRequest(ctx context.Context, tx *sql.Tx, requests []*SignatureRequest, transactionHash string, req *common.Request) error {
 	err := s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
 		common.RequestStateDone, time.Now().UTC(), req.Id)
@@ -102,7 +154,10 @@ func (s *SQLite3Store) writeSignatureRequestsWithRequest(ctx context.Context, tx
 	if len(requests) == 0 {
 		return tx.Commit()
 	}
+	return s.writeSignatureRequests(ctx, tx, requests, transactionHash, req)
+}
 
+func (s *SQLite3Store) writeSignatureRequests(ctx context.Context, tx *sql.Tx, requests []*SignatureRequest, transactionHash string, req *common.Request) error {
 	existed, err := s.checkExistence(ctx, tx, "SELECT request_id FROM signature_requests WHERE request_id=? AND state=?", requests[0].RequestId, common.RequestStateInitial)
 	if err != nil || existed {
 		return err
@@ -191,6 +246,10 @@ func (s *SQLite3Store) FinishTransactionSignaturesWithRequest(ctx context.Contex
 	if err != nil {
 		return fmt.Errorf("UPDATE safes %v", err)
 	}
+	err = s.finishSafeMigration(ctx, tx, transactionHash, req.CreatedAt)
+	if err != nil {
+		return err
+	}
 
 	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
 		common.RequestStateDone, time.Now().UTC(), req.Id)
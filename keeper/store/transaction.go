@@ -9,6 +9,7 @@ import (
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/trusted-group/mtg"
 )
@@ -48,7 +49,7 @@ func TransactionInputsFromRawTransaction(trx *Transaction) []*TransactionInput {
 	b := common.DecodeHexOrPanic(trx.RawTransaction)
 	var inputs []*TransactionInput
 	switch trx.Chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainDogecoin:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(b)
 		for _, in := range psbt.UnsignedTx.TxIn {
 			pop := in.PreviousOutPoint
@@ -137,6 +138,46 @@ func (s *SQLite3Store) ReadUnfinishedTransactionsByHolder(ctx context.Context, h
 	return txs, nil
 }
 
+func (s *SQLite3Store) ListTransactionsForHolder(ctx context.Context, holder string, state int, until time.Time) ([]*Transaction, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE holder=? AND state=? AND created_at<? ORDER BY created_at ASC, transaction_hash ASC", strings.Join(transactionCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, holder, state, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []*Transaction
+	for rows.Next() {
+		var tx Transaction
+		err = rows.Scan(&tx.TransactionHash, &tx.RawTransaction, &tx.Holder, &tx.Chain, &tx.AssetId, &tx.State, &tx.Data, &tx.RequestId, &tx.CreatedAt, &tx.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
+
+func (s *SQLite3Store) ListProposedTransactions(ctx context.Context, chain byte, until time.Time) ([]*Transaction, error) {
+	query := fmt.Sprintf("SELECT %s FROM transactions WHERE chain=? AND state=? AND created_at<? ORDER BY created_at ASC, transaction_hash ASC", strings.Join(transactionCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStateInitial, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var txs []*Transaction
+	for rows.Next() {
+		var tx Transaction
+		err = rows.Scan(&tx.TransactionHash, &tx.RawTransaction, &tx.Holder, &tx.Chain, &tx.AssetId, &tx.State, &tx.Data, &tx.RequestId, &tx.CreatedAt, &tx.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, &tx)
+	}
+	return txs, nil
+}
+
 func (s *SQLite3Store) CloseAccountByTransactionWithRequest(ctx context.Context, trx *Transaction, utxos []*TransactionInput, utxoState int, txs []*mtg.Transaction, req *common.Request) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -179,6 +220,10 @@ func (s *SQLite3Store) CloseAccountByTransactionWithRequest(ctx context.Context,
 }
 
 func (s *SQLite3Store) WriteTransactionWithRequest(ctx context.Context, trx *Transaction, utxos []*TransactionInput, txs []*mtg.Transaction, req *common.Request) error {
+	return s.WriteTransactionWithOutflowAndRequest(ctx, trx, utxos, nil, txs, req)
+}
+
+func (s *SQLite3Store) WriteTransactionWithOutflowAndRequest(ctx context.Context, trx *Transaction, utxos []*TransactionInput, outflow *SafeOutflow, txs []*mtg.Transaction, req *common.Request) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
@@ -192,6 +237,12 @@ func (s *SQLite3Store) WriteTransactionWithRequest(ctx context.Context, trx *Tra
 	if err != nil {
 		return err
 	}
+	if outflow != nil {
+		err = s.writeSafeOutflow(ctx, tx, outflow)
+		if err != nil {
+			return err
+		}
+	}
 
 	err = s.writeActionResult(ctx, tx, req.Output.OutputId, "", txs, trx.RequestId)
 	if err != nil {
@@ -262,6 +313,10 @@ func (s *SQLite3Store) RevokeTransactionWithRequest(ctx context.Context, trx *Tr
 	if err != nil {
 		return fmt.Errorf("UPDATE transactions %v", err)
 	}
+	err = s.failSafeMigration(ctx, tx, trx.TransactionHash, req.CreatedAt)
+	if err != nil {
+		return err
+	}
 
 	err = s.execOne(ctx, tx, "UPDATE requests SET state=?, updated_at=? WHERE request_id=?",
 		common.RequestStateDone, time.Now().UTC(), req.Id)
@@ -333,9 +388,9 @@ func (s *SQLite3Store) readTransaction(ctx context.Context, tx *sql.Tx, transact
 
 func transactionHasOutputs(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainDogecoin:
 		return true
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	case ethereum.ChainEthereum, ethereum.ChainPolygon, solana.ChainSolana:
 		return false
 	default:
 		panic(chain)
@@ -344,9 +399,9 @@ func transactionHasOutputs(chain byte) bool {
 
 func transactionHasBalance(chain byte) bool {
 	switch chain {
-	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin:
+	case bitcoin.ChainBitcoin, bitcoin.ChainLitecoin, bitcoin.ChainDogecoin:
 		return false
-	case ethereum.ChainEthereum, ethereum.ChainPolygon:
+	case ethereum.ChainEthereum, ethereum.ChainPolygon, solana.ChainSolana:
 		return true
 	default:
 		panic(chain)
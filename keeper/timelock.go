@@ -0,0 +1,115 @@
+package keeper
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+)
+
+// The observer key becomes usable for recovery once the oldest safe funds are
+// not spent for the timelock. The holders could sign the refresh message to let
+// observer propose a transaction spending to the safe itself, which resets the
+// timelock after approved by the holders as any other transaction.
+func (node *Node) processBitcoinSafeRefreshTimelock(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	return node.processBitcoinSafeSelfSpend(ctx, req, "REFRESH", func(safe *store.Safe, inputs []*bitcoin.Input) []*bitcoin.Input {
+		count, err := node.store.CountUnfinishedTransactionsByHolder(ctx, safe.Holder)
+		logger.Printf("store.CountUnfinishedTransactionsByHolder(%s) => %d %v", safe.Holder, count, err)
+		if err != nil {
+			panic(err)
+		} else if count != 0 {
+			return nil
+		}
+		// the inputs are sorted by age, so the oldest ones are always refreshed
+		if len(inputs) > bitcoin.MaxConsolidationInputs {
+			inputs = inputs[:bitcoin.MaxConsolidationInputs]
+		}
+		return inputs
+	})
+}
+
+func (node *Node) processEthereumSafeRefreshTimelock(ctx context.Context, req *common.Request) ([]*mtg.Transaction, string) {
+	if req.Role != common.RequestRoleObserver {
+		panic(req.Role)
+	}
+	chain := common.SafeCurveChain(req.Curve)
+	safe, err := node.store.ReadSafe(ctx, req.Holder)
+	if err != nil {
+		panic(fmt.Errorf("store.ReadSafe(%s) => %v", req.Holder, err))
+	}
+	if safe == nil || safe.Chain != chain {
+		return node.failRequest(ctx, req, "")
+	}
+	if safe.State != SafeStateApproved {
+		return node.failRequest(ctx, req, "")
+	}
+
+	holders, _ := node.readSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	sig := node.readSafeApprovalSignature(ctx, holders, req.ExtraBytes())
+	msg := []byte(safeSelfSpendMessage("REFRESH", safe, req.Id))
+	err = node.verifySafeMessageSignatureWithHolders(ctx, safe, msg, sig, ethereum.VerifyMessageSignature)
+	logger.Printf("node.verifySafeMessageSignatureWithHolders(%v) => %v", req, err)
+	if err != nil {
+		return node.failRequest(ctx, req, "")
+	}
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, safe.Holder)
+	logger.Printf("store.ReadUnfinishedTransactionsByHolder(%s) => %v %v", safe.Holder, len(pendings), err)
+	if err != nil {
+		panic(err)
+	} else if len(pendings) > 0 {
+		return node.failRequest(ctx, req, "")
+	}
+
+	// any executed safe transaction updates the last transaction time of
+	// the guard, so a zero value transfer to the safe itself is enough
+	_, assetId := node.ethereumParams(safe.Chain)
+	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
+	t, err := ethereum.CreateTransaction(ctx, ethereum.TypeETHTx, chainId, req.Id, safe.Address, safe.Address, ethereum.EthereumEmptyAddress, "0", big.NewInt(safe.Nonce))
+	logger.Printf("ethereum.CreateTransaction(%s, %s, %d) => %v %v", req.Id, safe.Address, safe.Nonce, t, err)
+	if err != nil {
+		panic(err)
+	}
+
+	extra := t.Marshal()
+	stx := node.buildStorageTransaction(ctx, req, []byte(common.Base91Encode(extra)))
+	if stx == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs := []*mtg.Transaction{stx}
+
+	typ := byte(common.ActionEthereumSafeProposeTransaction)
+	crv := common.SafeChainCurve(safe.Chain)
+	tt := node.buildObserverResponseWithStorageTraceId(ctx, req.Id, req.Output, typ, crv, stx.TraceId)
+	if tt == nil {
+		return node.failRequest(ctx, req, "")
+	}
+	txs = append(txs, tt)
+
+	data := common.MarshalJSONOrPanic([]map[string]string{{
+		"receiver": safe.Address, "amount": "0",
+	}})
+	tx := &store.Transaction{
+		TransactionHash: t.TxHash,
+		RawTransaction:  hex.EncodeToString(extra),
+		Holder:          req.Holder,
+		Chain:           safe.Chain,
+		AssetId:         assetId,
+		State:           common.RequestStateInitial,
+		Data:            string(data),
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = node.store.WriteTransactionWithRequest(ctx, tx, nil, txs, req)
+	if err != nil {
+		panic(err)
+	}
+	return txs, ""
+}
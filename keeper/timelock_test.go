@@ -0,0 +1,98 @@
+package keeper
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinKeeperRefreshTimelock(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, mpc, _ := testPrepare(require)
+	testPrepareBond(ctx, require, node, db)
+	input := testPrepareDeposits(ctx, require, node, mpc, 100000)[0]
+
+	holder := testPublicKey(testBitcoinKeyHolderPrivate)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	nonce := uuid.Must(uuid.NewV4()).String()
+	sig := testBitcoinSignMessage(testBitcoinKeyHolderPrivate, safeSelfSpendMessage("REFRESH", safe, nonce))
+
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, holder, common.ActionBitcoinSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, id)
+	require.Nil(err)
+	require.Nil(tx)
+
+	out = testBuildObserverRequest(node, nonce, holder, common.ActionBitcoinSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSABitcoin)
+	testStep(ctx, require, node, out)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	require.Len(psbt.UnsignedTx.TxIn, 1)
+	require.Equal(input.TransactionHash, psbt.UnsignedTx.TxIn[0].PreviousOutPoint.Hash.String())
+	script, err := bitcoin.ParseAddress(testSafeAddress, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Equal(script, psbt.UnsignedTx.TxOut[0].PkScript)
+	pendings, err := node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 1)
+
+	testSafeRevokeTransaction(ctx, require, node, tx.TransactionHash, false)
+	testObserverRequestReplay(ctx, require, node, nonce, holder, common.ActionBitcoinSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSABitcoin)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, tx.State)
+	pendings, err = node.store.ListPendingBitcoinUTXOsForHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+}
+
+func TestEthereumKeeperRefreshTimelock(t *testing.T) {
+	require := require.New(t)
+	ctx, node, db, _, _ := testEthereumPrepare(require)
+
+	testEthereumPrepareBond(ctx, require, node, db, testEthereumBondAssetId)
+	testEthereumObserverHolderDeposit(ctx, require, node, common.SafePolygonChainId, ethereum.EthereumEmptyAddress, "100000000000000")
+
+	holder := testPublicKey(testEthereumKeyHolder)
+	safe, err := node.store.ReadSafe(ctx, holder)
+	require.Nil(err)
+	nonce := uuid.Must(uuid.NewV4()).String()
+	sig := testEthereumSignMessage(require, testEthereumKeyHolder, []byte(safeSelfSpendMessage("REFRESH", safe, nonce)))
+
+	id := uuid.Must(uuid.NewV4()).String()
+	out := testBuildObserverRequest(node, id, holder, common.ActionEthereumSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSAPolygon)
+	testStep(ctx, require, node, out)
+	tx, err := node.store.ReadTransactionByRequestId(ctx, id)
+	require.Nil(err)
+	require.Nil(tx)
+
+	out = testBuildObserverRequest(node, nonce, holder, common.ActionEthereumSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSAPolygon)
+	testStep(ctx, require, node, out)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Equal(common.RequestStateInitial, tx.State)
+	st, err := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(tx.RawTransaction))
+	require.Nil(err)
+	require.Equal(safe.Address, st.Destination.Hex())
+	require.Equal(int64(0), st.Value.Int64())
+	require.Equal(fmt.Sprintf(`[{"amount":"0","receiver":"%s"}]`, safe.Address), tx.Data)
+
+	testEthereumRevokeTransaction(ctx, require, node, tx.TransactionHash, false)
+	testObserverRequestReplay(ctx, require, node, nonce, holder, common.ActionEthereumSafeRefreshTimelock, sig, common.CurveSecp256k1ECDSAPolygon)
+	tx, err = node.store.ReadTransactionByRequestId(ctx, nonce)
+	require.Nil(err)
+	require.Equal(common.RequestStateFailed, tx.State)
+	pendings, err := node.store.ReadUnfinishedTransactionsByHolder(ctx, holder)
+	require.Nil(err)
+	require.Len(pendings, 0)
+}
@@ -118,6 +118,29 @@ func main() {
 						Usage:   "The configuration file path",
 					},
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:   "replay",
+						Usage:  "Replay the MTG actions into a fresh keeper store and diff with the live one",
+						Action: cmd.KeeperReplayCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Value:   "~/.mixin/safe/config.toml",
+								Usage:   "The configuration file path",
+							},
+							&cli.StringFlag{
+								Name:  "dir",
+								Usage: "The empty directory for the replay stores",
+							},
+							&cli.Uint64Flag{
+								Name:  "until",
+								Usage: "The last action sequence to replay",
+							},
+						},
+					},
+				},
 			},
 			{
 				Name:   "observer",
@@ -132,6 +155,101 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "report",
+				Usage:  "Export the safe statements of a period with the observer and keeper stores",
+				Action: cmd.ReportCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "config",
+						Aliases: []string{"c"},
+						Value:   "~/.mixin/safe/config.toml",
+						Usage:   "The observer configuration file path",
+					},
+					&cli.StringFlag{
+						Name:     "safe",
+						Usage:    "The safe holder public key or address",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "from",
+						Usage: "The period start month, date or time, default to the current month",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "The period end month, date or time, default to one month after the start",
+					},
+					&cli.StringFlag{
+						Name:  "format",
+						Value: "csv",
+						Usage: "The output format, csv or json",
+					},
+					&cli.StringFlag{
+						Name:  "output",
+						Usage: "The output file path, default to stdout",
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Manage the node configurations",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "check",
+						Usage:  "Check the configurations and the key pairs across roles before boot",
+						Action: cmd.ConfigCheckCmd,
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:    "config",
+								Aliases: []string{"c"},
+								Usage:   "The configuration file path, or role:path for the other roles",
+							},
+							&cli.StringFlag{
+								Name:  "role",
+								Value: "keeper",
+								Usage: "The role of the configuration files without a role prefix",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "devnet",
+				Usage:  "Run the signers, keepers and observer in one process offline",
+				Action: cmd.DevnetBootCmd,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "dir",
+						Value: "/tmp/mixin-safe-devnet",
+						Usage: "The empty directory for all the node stores",
+					},
+					&cli.IntFlag{
+						Name:  "signers",
+						Value: 4,
+						Usage: "The signer nodes count, each of them runs a keeper node too",
+					},
+					&cli.StringFlag{
+						Name:  "seed",
+						Value: "devnet",
+						Usage: "The seed to derive all the ids and keys",
+					},
+					&cli.StringFlag{
+						Name:  "curves",
+						Value: "1",
+						Usage: "The comma separated curves to prepare keys for",
+					},
+					&cli.IntFlag{
+						Name:  "keys",
+						Value: 1,
+						Usage: "The spare keys count of each curve",
+					},
+					&cli.IntFlag{
+						Name:  "port",
+						Value: 7090,
+						Usage: "The devnet control API port",
+					},
+				},
+			},
 			{
 				Name:   "importobserverkeys",
 				Usage:  "Import observer public keys",
@@ -331,6 +449,130 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:   "exportaccount",
+				Usage:  "Export the output descriptor and wallet policy of a safe account",
+				Action: cmd.ExportSafeDescriptor,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "observer",
+						Value: "https://observer.mixin.one",
+						Usage: "The observer HTTP API endpoint",
+					},
+					&cli.StringFlag{
+						Name:  "id",
+						Usage: "The safe account id",
+					},
+					&cli.StringSliceFlag{
+						Name:  "holder",
+						Usage: "The holder extended public key with fingerprint, e.g. [fingerprint]xpub, repeated in the account holders order for multiple holders",
+					},
+				},
+			},
+			{
+				Name:  "recovery",
+				Usage: "Recover a safe with the holder and observer keys offline after the timelock",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "build",
+						Usage:  "Build the recovery request spending all the safe funds to the receiver",
+						Action: cmd.RecoveryBuildCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "account",
+								Usage: "The safe account JSON file saved from the observer /accounts/:id",
+							},
+							&cli.StringFlag{
+								Name:  "observer",
+								Value: "https://observer.mixin.one",
+								Usage: "The observer HTTP API endpoint to read the account without the file",
+							},
+							&cli.StringFlag{
+								Name:  "id",
+								Usage: "The safe account id to read from the observer",
+							},
+							&cli.StringSliceFlag{
+								Name:  "input",
+								Usage: "The Bitcoin safe output in hash:index:satoshi, all outputs should be provided",
+							},
+							&cli.StringSliceFlag{
+								Name:  "output",
+								Usage: "The Ethereum safe balance in amount or token:amount of the smallest unit",
+							},
+							&cli.StringFlag{
+								Name:  "receiver",
+								Usage: "The receiver address of all the safe funds",
+							},
+							&cli.Int64Flag{
+								Name:  "fee",
+								Value: 10,
+								Usage: "The Bitcoin fee rate in satoshi per vbyte",
+							},
+							&cli.Int64Flag{
+								Name:  "nonce",
+								Usage: "The Ethereum safe nonce, the account nonce by default",
+							},
+							&cli.StringFlag{
+								Name:  "request",
+								Value: "recovery.json",
+								Usage: "The recovery request file to write",
+							},
+						},
+					},
+					{
+						Name:   "sign",
+						Usage:  "Sign the recovery request with the holder key",
+						Action: cmd.RecoverySignCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "request",
+								Value: "recovery.json",
+								Usage: "The recovery request file",
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "The holder private key",
+							},
+						},
+					},
+					{
+						Name:   "observe",
+						Usage:  "Sign the recovery request with the observer key",
+						Action: cmd.RecoveryObserverSignCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "request",
+								Value: "recovery.json",
+								Usage: "The recovery request file",
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "The observer private key of the safe observer public key",
+							},
+						},
+					},
+					{
+						Name:   "finalize",
+						Usage:  "Build the signed recovery transaction and broadcast it optionally",
+						Action: cmd.RecoveryFinalizeCmd,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "request",
+								Value: "recovery.json",
+								Usage: "The recovery request file",
+							},
+							&cli.StringFlag{
+								Name:  "rpc",
+								Usage: "The optional chain RPC to broadcast the transaction",
+							},
+							&cli.StringFlag{
+								Name:  "key",
+								Usage: "The Ethereum private key to send the transaction and pay gas",
+							},
+						},
+					},
+				},
+			},
 			{
 				Name:   "proposetransaction",
 				Usage:  "Propose a safe transaction",
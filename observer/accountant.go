@@ -34,6 +34,7 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 	switch tx.Chain {
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
+	case common.SafeChainDogecoin:
 	default:
 		panic(spsbt.Hash())
 	}
@@ -62,10 +63,11 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 		signed[r.InputIndex] = common.DecodeHexOrPanic(r.Signature.String)
 	}
 
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
 	for idx, in := range spsbt.UnsignedTx.TxIn {
 		pop := in.PreviousOutPoint
 		hash := spsbt.SigHash(idx)
-		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, safe.Chain)
 		if !required {
 			continue
 		}
@@ -75,7 +77,7 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 		signedByHolderObserver := false
 		switch in.Sequence {
 		case bitcoin.MaxTransactionSequence: // normal tx
-			if hex.EncodeToString(hsig.PubKey) != tx.Holder {
+			if !slices.Contains(holders, hex.EncodeToString(hsig.PubKey)) {
 				panic(spsbt.Hash())
 			}
 		default: // recovery tx
@@ -91,7 +93,13 @@ func (node *Node) keeperCombineBitcoinTransactionSignatures(ctx context.Context,
 					pub := hex.EncodeToString(sig.PubKey)
 					pubs = append(pubs, pub)
 				}
-				if slices.Contains(pubs, tx.Holder) && slices.Contains(pubs, opk) {
+				var holderSigs int
+				for _, h := range holders {
+					if slices.Contains(pubs, h) {
+						holderSigs = holderSigs + 1
+					}
+				}
+				if holderSigs >= threshold && slices.Contains(pubs, opk) {
 					signedByHolderObserver = true
 				} else {
 					panic(spsbt.Hash())
@@ -150,14 +158,15 @@ func (node *Node) keeperVerifyEthereumTransactionSignatures(ctx context.Context,
 		panic(st.TxHash)
 	}
 
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
 	sigs := 0
-	for _, pub := range []string{safe.Holder, safe.Observer, safe.Signer} {
+	for _, pub := range append(holders, safe.Observer, safe.Signer) {
 		signed := ethereum.CheckTransactionPartiallySignedBy(raw, pub)
 		if signed {
 			sigs += 1
 		}
 	}
-	if sigs < 2 {
+	if sigs < int(ethereum.GetSafeThreshold(threshold)) {
 		return fmt.Errorf("Ethereum safe transaction %v has insufficient signatures: %d", st, sigs)
 	}
 
@@ -227,7 +236,8 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		return nil, err
 	}
 
-	msgTx, err := psbt.SignedTransaction(tx.Holder, spk, opk)
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
+	msgTx, err := psbt.SignedTransactionWithHolders(holders, threshold, spk, opk)
 	if err != nil {
 		return nil, err
 	}
@@ -236,15 +246,11 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 		return nil, err
 	}
 
-	virtualSize := psbt.EstimateVirtualSize() + 160
 	fvb, err := bitcoin.EstimateAvgFee(tx.Chain, rpc)
 	if err != nil {
 		return nil, err
 	}
-	fee := fvb * int64(virtualSize)
-	if fee < bitcoin.ValueDust(tx.Chain) {
-		fee = bitcoin.ValueDust(tx.Chain)
-	}
+	_, fee := bitcoinEstimateAccountantFee(psbt, fvb, tx.Chain)
 
 	feeInput, err := node.bitcoinRetrieveFeeInputsForTransaction(ctx, uint64(fee), uint64(fvb), tx)
 	if err != nil {
@@ -271,6 +277,17 @@ func (node *Node) bitcoinSpendFullySignedTransaction(ctx context.Context, tx *Tr
 	return msgTx, node.bitcoinBroadcastTransactionAndWriteDeposit(ctx, feeInput, msgTx, tx.Chain)
 }
 
+// bitcoinEstimateAccountantFee returns the virtual size of the safe transaction
+// with the accountant fee input, and the fee paid by the accountant
+func bitcoinEstimateAccountantFee(psbt *bitcoin.PartiallySignedTransaction, fvb int64, chain byte) (int, int64) {
+	virtualSize := psbt.EstimateVirtualSize() + 160
+	fee := fvb * int64(virtualSize)
+	if fee < bitcoin.ValueDust(chain) {
+		fee = bitcoin.ValueDust(chain)
+	}
+	return virtualSize, fee
+}
+
 func (node *Node) bitcoinRetrieveFeeInputsForTransaction(ctx context.Context, fee, fvb uint64, tx *Transaction) (*Output, error) {
 	min, max := uint64(float64(fee)*0.9), uint64(float64(fee)*1.1)
 	old, err := node.store.AssignBitcoinUTXOByRangeForTransaction(ctx, min, max, tx)
@@ -452,6 +469,16 @@ func (s *SQLite3Store) AssignBitcoinUTXOByRangeForTransaction(ctx context.Contex
 	return &o, txn.Commit()
 }
 
+// ReadAccountantFee returns the satoshi of the accountant fee input assigned
+// to the safe transaction, the fee input is spent as a whole
+func (s *SQLite3Store) ReadAccountantFee(ctx context.Context, transactionHash string) (int64, error) {
+	query := "SELECT COALESCE(SUM(satoshi), 0) FROM bitcoin_outputs WHERE spent_by=?"
+	row := s.db.QueryRowContext(ctx, query, transactionHash)
+	var satoshi int64
+	err := row.Scan(&satoshi)
+	return satoshi, err
+}
+
 func (s *SQLite3Store) ReadBitcoinUTXO(ctx context.Context, hash string, index int64, chain byte) (*Output, error) {
 	query := fmt.Sprintf("SELECT %s FROM bitcoin_outputs WHERE chain=? AND transaction_hash=? AND output_index=?", strings.Join(outputCols, ","))
 	row := s.db.QueryRowContext(ctx, query, chain, hash, index)
@@ -614,6 +641,10 @@ func (node *Node) ethereumBroadcastTransactionAndWriteDeposit(ctx context.Contex
 		return "", fmt.Errorf("ValidTransaction => %t %v", success, validErr)
 	}
 
+	err := node.checkLeader()
+	if err != nil {
+		return "", err
+	}
 	hash, err := st.ExecTransaction(ctx, rpc, node.conf.EVMKey)
 	logger.Printf("ExecTransaction(%v, %v) => %s %v", st, rpc, hash, err)
 	if err != nil {
@@ -623,6 +654,10 @@ func (node *Node) ethereumBroadcastTransactionAndWriteDeposit(ctx context.Contex
 }
 
 func (node *Node) bitcoinBroadcastTransaction(hash string, raw []byte, chain byte) error {
+	err := node.checkLeader()
+	if err != nil {
+		return err
+	}
 	rpc, _ := node.bitcoinParams(chain)
 	id, err := bitcoin.RPCSendRawTransaction(rpc, hex.EncodeToString(raw))
 	if err != nil && strings.Contains(err.Error(), "Transaction already in block chain") {
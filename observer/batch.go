@@ -0,0 +1,273 @@
+package observer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/gofrs/uuid/v5"
+)
+
+type batchTransaction struct {
+	Id  string `json:"id"`
+	Raw string `json:"raw"`
+}
+
+// httpCreateTransactionBatch accepts the holder signatures over all the
+// transaction hashes of the same safe reaching the holder threshold, and the
+// holder signed raw of each transaction if not approved before. The batch is sent to keeper after the
+// holder pays the observer once with the batch id as memo.
+func (node *Node) httpCreateTransactionBatch(ctx context.Context, chain byte, transactions []*batchTransaction, signature string) (*TransactionBatch, error) {
+	logger.Printf("node.httpCreateTransactionBatch(%d, %d, %s)", chain, len(transactions), signature)
+	if len(transactions) == 0 || len(transactions) > common.BatchApprovalMaximum {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	var safe *store.Safe
+	var hashes []string
+	for _, t := range transactions {
+		tx, err := node.keeperStore.ReadTransactionByRequestId(ctx, t.Id)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil || tx.Chain != chain {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotFound)
+		}
+		if slices.Contains(hashes, tx.TransactionHash) {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		if safe == nil {
+			safe, err = node.keeperStore.ReadSafe(ctx, tx.Holder)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if safe == nil || safe.State != common.RequestStateDone || tx.Holder != safe.Holder {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		approval, err := node.store.ReadTransactionApproval(ctx, tx.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		if approval == nil || approval.State != common.RequestStateInitial {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		hashes = append(hashes, tx.TransactionHash)
+	}
+
+	ms := common.BatchApprovalMessage(safe.RequestId, hashes)
+	sig, err := node.verifyBatchApprovalSignature(ctx, safe, ms, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, t := range transactions {
+		if t.Raw != "" {
+			err = node.httpApproveSafeTransaction(ctx, chain, t.Raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+		approval, err := node.store.ReadTransactionApproval(ctx, hashes[i])
+		if err != nil {
+			return nil, err
+		}
+		if !node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+	}
+
+	batch := &TransactionBatch{
+		BatchId:      common.UniqueId(safe.RequestId, ms),
+		Chain:        chain,
+		Holder:       safe.Holder,
+		Transactions: hashes,
+		Signature:    hex.EncodeToString(sig),
+		State:        common.RequestStateInitial,
+		CreatedAt:    time.Now().UTC(),
+		UpdatedAt:    time.Now().UTC(),
+	}
+	err = node.store.WriteTransactionBatchIfNotExists(ctx, batch)
+	if err != nil {
+		return nil, err
+	}
+	return node.store.ReadTransactionBatch(ctx, batch.BatchId)
+}
+
+func (node *Node) verifyBatchApprovalSignature(ctx context.Context, safe *store.Safe, ms, signature string) ([]byte, error) {
+	var sig []byte
+	var err error
+	var verify func(public string, sig []byte) error
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		sig, err = base64.RawURLEncoding.DecodeString(signature)
+		hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		verify = func(public string, sig []byte) error {
+			return bitcoin.VerifySignatureDER(public, hash, sig)
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		sig, err = hex.DecodeString(signature)
+		verify = func(public string, sig []byte) error {
+			return ethereum.VerifyMessageSignature(public, []byte(ms), sig)
+		}
+	default:
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = node.verifySafeHolderSignatures(ctx, safe, sig, verify)
+	if err != nil {
+		return nil, fmt.Errorf("invalid holders signature %x", sig)
+	}
+	return sig, nil
+}
+
+func (node *Node) handleTransactionBatchPayment(ctx context.Context, s *mixin.SafeSnapshot) (bool, error) {
+	batch, err := node.store.ReadTransactionBatch(ctx, s.Memo)
+	if err != nil || batch == nil {
+		return false, err
+	}
+	params, err := node.keeperStore.ReadLatestOperationParams(ctx, batch.Chain, s.CreatedAt)
+	if err != nil || params == nil {
+		return false, err
+	}
+	if s.AssetID != params.OperationPriceAsset {
+		return false, nil
+	}
+	if s.Amount.Cmp(params.OperationPriceAmount) < 0 {
+		return true, nil
+	}
+	if batch.State != common.RequestStateInitial {
+		return true, nil
+	}
+	return true, node.store.MarkTransactionBatchPaid(ctx, batch.BatchId)
+}
+
+func (node *Node) transactionBatchLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(3 * time.Second)
+		batches, err := node.store.ListPendingTransactionBatches(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		for _, batch := range batches {
+			err := node.sendToKeeperApproveTransactions(ctx, batch)
+			logger.Printf("node.sendToKeeperApproveTransactions(%v) => %v", batch, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperApproveTransactions(ctx context.Context, batch *TransactionBatch) error {
+	safe, err := node.keeperStore.ReadSafe(ctx, batch.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", batch.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+
+	var signed int
+	var approvals []*Transaction
+	for _, hash := range batch.Transactions {
+		approval, err := node.store.ReadTransactionApproval(ctx, hash)
+		if err != nil {
+			return err
+		}
+		switch approval.State {
+		case common.RequestStateDone:
+			signed += 1
+		case common.RequestStateFailed:
+			// the transaction is revoked after the batch created
+			return node.store.FinishTransactionBatch(ctx, batch.BatchId, common.RequestStateFailed)
+		}
+		locked, err := node.checkSafeOutflowLocked(ctx, hash)
+		if err != nil || locked {
+			return err
+		}
+		approvals = append(approvals, approval)
+	}
+	if signed == len(approvals) {
+		return node.store.FinishTransactionBatch(ctx, batch.BatchId, common.RequestStateDone)
+	}
+	if signed > 0 {
+		// the keeper rejects the batch once any transaction signed, and the
+		// holder could still approve the remaining ones separately
+		return nil
+	}
+
+	ba := &common.BatchApproval{Signature: common.DecodeHexOrPanic(batch.Signature)}
+	for _, approval := range approvals {
+		tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+		if err != nil {
+			return err
+		}
+		ba.Transactions = append(ba.Transactions, &common.BatchApprovalTransaction{
+			RequestId: tx.RequestId,
+			Raw:       common.DecodeHexOrPanic(approval.RawTransaction),
+		})
+	}
+
+	rawId := common.UniqueId(batch.BatchId, batch.Signature)
+	raw := append(uuid.Must(uuid.FromString(rawId)).Bytes(), ba.Encode()...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
+	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	if err != nil {
+		return err
+	}
+
+	var action int
+	switch batch.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		action = common.ActionBitcoinSafeApproveTransactions
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		action = common.ActionEthereumSafeApproveTransactions
+	default:
+		panic(batch.Chain)
+	}
+	id := common.UniqueId(batch.BatchId, batch.BatchId)
+	references := []crypto.Hash{ref}
+	err = node.sendKeeperResponseWithReferences(ctx, safe.Holder, byte(action), batch.Chain, id, ref[:], references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %s)", safe.Holder, action, id, ref)
+	if err != nil {
+		return err
+	}
+
+	if batch.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, batch.UpdatedAt.String())
+	err = node.sendKeeperResponseWithReferences(ctx, safe.Holder, byte(action), batch.Chain, id, ref[:], references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %s)", safe.Holder, action, id, ref)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionBatchRequestTime(ctx, batch.BatchId)
+}
+
+func (b *TransactionBatch) view() map[string]any {
+	return map[string]any{
+		"id":           b.BatchId,
+		"chain":        b.Chain,
+		"transactions": b.Transactions,
+		"state":        common.StateName(int(b.State)),
+		"created_at":   b.CreatedAt,
+		"updated_at":   b.UpdatedAt,
+	}
+}
@@ -0,0 +1,63 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverTransactionBatch(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	batch := &TransactionBatch{
+		BatchId:      "c94ac88f-4671-3976-b60a-09064f1811e8",
+		Chain:        common.SafeChainBitcoin,
+		Holder:       "holder",
+		Transactions: []string{"hash1", "hash2"},
+		Signature:    "signature",
+		State:        common.RequestStateInitial,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	err = db.WriteTransactionBatchIfNotExists(ctx, batch)
+	require.Nil(err)
+	err = db.WriteTransactionBatchIfNotExists(ctx, batch)
+	require.Nil(err)
+	old, err := db.ReadTransactionBatch(ctx, batch.BatchId)
+	require.Nil(err)
+	require.Equal(batch.Transactions, old.Transactions)
+	require.Equal(byte(common.RequestStateInitial), old.State)
+
+	batches, err := db.ListPendingTransactionBatches(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(batches, 0)
+	err = db.FinishTransactionBatch(ctx, batch.BatchId, common.RequestStateDone)
+	require.NotNil(err)
+	err = db.MarkTransactionBatchPaid(ctx, batch.BatchId)
+	require.Nil(err)
+	batches, err = db.ListPendingTransactionBatches(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(batches, 1)
+	require.Equal(batch.Transactions, batches[0].Transactions)
+	err = db.UpdateTransactionBatchRequestTime(ctx, batch.BatchId)
+	require.Nil(err)
+
+	err = db.FinishTransactionBatch(ctx, batch.BatchId, common.RequestStateDone)
+	require.Nil(err)
+	batches, err = db.ListPendingTransactionBatches(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(batches, 0)
+	old, err = db.ReadTransactionBatch(ctx, batch.BatchId)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStateDone), old.State)
+	require.Equal("done", old.view()["state"])
+}
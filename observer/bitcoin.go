@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -33,6 +34,8 @@ func (node *Node) bitcoinParams(chain byte) (string, string) {
 		return node.conf.BitcoinRPC, common.SafeBitcoinChainId
 	case common.SafeChainLitecoin:
 		return node.conf.LitecoinRPC, common.SafeLitecoinChainId
+	case common.SafeChainDogecoin:
+		return node.conf.DogecoinRPC, common.SafeDogecoinChainId
 	default:
 		panic(chain)
 	}
@@ -164,7 +167,7 @@ func (node *Node) bitcoinCheckDepositChange(ctx context.Context, transactionHash
 	}
 	var recipients []map[string]string
 	err = json.Unmarshal([]byte(tx.Data), &recipients)
-	if err != nil || len(recipients) == 0 {
+	if err != nil {
 		panic(fmt.Errorf("store.ReadTransaction(%s) => %s", transactionHash, tx.Data))
 	}
 	return outputIndex >= int64(len(recipients))
@@ -203,7 +206,7 @@ func (node *Node) bitcoinWritePendingDeposit(ctx context.Context, receiver strin
 
 	c, err := node.keeperStore.ReadUnspentUtxoCountForSafe(ctx, receiver)
 	logger.Printf("keeperStore.ReadUnspentUtxoCountForSafe(%s) => %d %v", receiver, c, err)
-	if err != nil || c >= bitcoin.MaxUnspentUtxo/2 {
+	if err != nil || (c >= bitcoin.MaxUnspentUtxo/2 && !change) {
 		return err
 	}
 
@@ -312,8 +315,13 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 	switch chain {
 	case common.SafeChainLitecoin:
 		duration = 1 * time.Minute
+	case common.SafeChainDogecoin:
+		duration = 30 * time.Second
 	case common.SafeChainBitcoin:
 	}
+	if common.CheckTestEnvironment(ctx) {
+		duration = time.Second
+	}
 
 	for {
 		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
@@ -359,8 +367,7 @@ func (node *Node) bitcoinRPCBlocksLoop(ctx context.Context, chain byte) {
 func (node *Node) bitcoinProcessTransaction(ctx context.Context, tx *bitcoin.RPCTransaction, chain byte) error {
 	for index := range tx.Vout {
 		out := tx.Vout[index]
-		skt := out.ScriptPubKey.Type
-		if skt != bitcoin.ScriptPubKeyTypeWitnessScriptHash && skt != bitcoin.ScriptPubKeyTypeWitnessKeyHash {
+		if !bitcoin.IsSafeScriptPubKeyType(out.ScriptPubKey.Type, chain) {
 			continue
 		}
 		if out.N != int64(index) {
@@ -401,6 +408,78 @@ func (node *Node) bitcoinTransactionApprovalLoop(ctx context.Context, chain byte
 	}
 }
 
+func (node *Node) bitcoinConsolidationLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(10 * time.Minute)
+		consolidations, err := node.store.ListConsolidations(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		for _, c := range consolidations {
+			err := node.bitcoinProposeConsolidation(ctx, c)
+			logger.Printf("node.bitcoinProposeConsolidation(%v) => %v", c, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// the consolidation is proposed only when the fee rate is low, and only once
+// for each holder authorisation, the holder should still approve the transaction
+func (node *Node) bitcoinProposeConsolidation(ctx context.Context, c *Consolidation) error {
+	if c.ProposedAt.Valid {
+		return nil
+	}
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, c.Chain, time.Now())
+	if err != nil || info == nil {
+		return err
+	}
+	if info.Fee > bitcoinConsolidationFeeRate(c.Chain) {
+		return nil
+	}
+
+	safe, err := node.keeperStore.ReadSafe(ctx, c.Holder)
+	if err != nil || safe == nil {
+		return err
+	}
+	if safe.State != common.RequestStateDone {
+		return nil
+	}
+	inputs, err := node.keeperStore.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+	logger.Printf("keeperStore.ListAllBitcoinUTXOsForHolder(%s) => %d %v", safe.Holder, len(inputs), err)
+	if err != nil || len(inputs) < bitcoin.ConsolidationUnspentUtxo {
+		return err
+	}
+	count, err := node.store.CountUnfinishedTransactionApprovalsForHolder(ctx, safe.Holder)
+	if err != nil || count > 0 {
+		return err
+	}
+
+	extra := common.DecodeHexOrPanic(c.Signature)
+	action := common.ActionBitcoinSafeConsolidateUTXOs
+	id := c.RequestId
+	err = node.sendKeeperResponseWithHolderSignatures(ctx, safe, byte(action), id, extra)
+	logger.Printf("node.sendKeeperResponseWithHolderSignatures(%s, %d, %s, %x) => %v", safe.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+	return node.store.MarkConsolidationProposed(ctx, c.Address)
+}
+
+func bitcoinConsolidationFeeRate(chain byte) uint64 {
+	switch chain {
+	case common.SafeChainBitcoin:
+		return 10
+	case common.SafeChainLitecoin:
+		return 5
+	case common.SafeChainDogecoin:
+		return 1000
+	default:
+		panic(chain)
+	}
+}
+
 func (node *Node) sendToKeeperBitcoinApproveTransaction(ctx context.Context, approval *Transaction) error {
 	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
 	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
@@ -423,9 +502,18 @@ func (node *Node) sendToKeeperBitcoinApproveNormalTransaction(ctx context.Contex
 	if err != nil || signed {
 		return err
 	}
-	if !bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if !node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		panic(approval.RawTransaction)
 	}
+	locked, err := node.checkSafeOutflowLocked(ctx, approval.TransactionHash)
+	if err != nil || locked {
+		return err
+	}
 
 	rawId := common.UniqueId(approval.RawTransaction, approval.RawTransaction)
 	raw := common.DecodeHexOrPanic(approval.RawTransaction)
@@ -433,7 +521,7 @@ func (node *Node) sendToKeeperBitcoinApproveNormalTransaction(ctx context.Contex
 	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
 	msg := base64.RawURLEncoding.EncodeToString(raw)
 	traceId := common.UniqueId(msg, msg)
-	ref, err := common.WriteStorageUntilSufficient(ctx, node.mixin, raw, traceId, node.safeUser())
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
 	if err != nil {
 		return err
 	}
@@ -482,7 +570,7 @@ func (node *Node) sendToKeeperBitcoinApproveRecoveryTransaction(ctx context.Cont
 	if err != nil {
 		return err
 	}
-	signedByHolder := bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder)
+	signedByHolder := node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 
 	var extra []byte
 	switch {
@@ -508,8 +596,8 @@ func (node *Node) sendToKeeperBitcoinApproveRecoveryTransaction(ctx context.Cont
 	objectRaw = common.AESEncrypt(node.aesKey[:], objectRaw, rawId)
 	msg := base64.RawURLEncoding.EncodeToString(objectRaw)
 	traceId := common.UniqueId(msg, msg)
-	ref, err := common.WriteStorageUntilSufficient(ctx, node.mixin, objectRaw, traceId, node.safeUser())
-	logger.Printf("common.WriteStorageUntilSufficient(%v) => %s %v", msg, ref, err)
+	ref, err := node.writeStorageUntilSufficient(ctx, objectRaw, traceId)
+	logger.Printf("node.writeStorageUntilSufficient(%v) => %s %v", msg, ref, err)
 	if err != nil {
 		return err
 	}
@@ -550,7 +638,7 @@ func (node *Node) bitcoinCheckKeeperSignedTransaction(ctx context.Context, appro
 	msgTx := psbt.UnsignedTx
 	for idx := range msgTx.TxIn {
 		pop := msgTx.TxIn[idx].PreviousOutPoint
-		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop)
+		required := node.checkBitcoinUTXOSignatureRequired(ctx, pop, approval.Chain)
 		if required && len(signed[idx]) < 32 {
 			return false, nil
 		}
@@ -558,9 +646,9 @@ func (node *Node) bitcoinCheckKeeperSignedTransaction(ctx context.Context, appro
 	return true, nil
 }
 
-func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint) bool {
+func (node *Node) checkBitcoinUTXOSignatureRequired(ctx context.Context, pop wire.OutPoint, chain byte) bool {
 	utxo, _, _ := node.keeperStore.ReadBitcoinUTXO(ctx, pop.Hash.String(), int(pop.Index))
-	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script)
+	return bitcoin.CheckMultisigHolderSignerScript(utxo.Script, chain)
 }
 
 func (node *Node) httpCreateBitcoinAccountRecoveryRequest(ctx context.Context, safe *store.Safe, raw, hash string) error {
@@ -603,7 +691,7 @@ func (node *Node) httpCreateBitcoinAccountRecoveryRequest(ctx context.Context, s
 		if approval.RawTransaction != raw {
 			return nil
 		}
-		if bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 			return nil
 		}
 
@@ -617,7 +705,7 @@ func (node *Node) httpCreateBitcoinAccountRecoveryRequest(ctx context.Context, s
 			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 		}
 
-		if !bitcoin.CheckTransactionPartiallySignedBy(raw, safe.Holder) {
+		if !node.checkTransactionSignedByHolders(ctx, safe, raw) {
 			return nil
 		}
 	}
@@ -705,7 +793,7 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 		return nil
 	}
 
-	isHolderSigned := bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	isHolderSigned := node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 
 	opk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 	if err != nil {
@@ -778,8 +866,8 @@ func (node *Node) httpSignBitcoinAccountRecoveryRequest(ctx context.Context, saf
 			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 		}
 	case isHolderSigned: // Close account with holder key
-		if !bitcoin.CheckTransactionPartiallySignedBy(raw, safe.Holder) {
-			return fmt.Errorf("bitcoin.CheckTransactionPartiallySignedBy(%s, %s) holder", raw, safe.Holder)
+		if !node.checkTransactionSignedByHolders(ctx, safe, raw) {
+			return fmt.Errorf("bitcoin.CheckTransactionPartiallySignedByHolders(%s, %s) holder", raw, safe.Holder)
 		}
 	}
 
@@ -808,10 +896,25 @@ func (node *Node) httpApproveBitcoinTransaction(ctx context.Context, raw string)
 	if approval.State != common.RequestStateInitial {
 		return nil
 	}
-	if bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		return nil
 	}
-	if !bitcoin.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	if len(holders) > 1 {
+		// collect the partial signatures of the holder keys until the threshold reached
+		stored, _ := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+		merged := stored.MergePartialSignatures(psbt, holders)
+		logger.Printf("bitcoin.MergePartialSignatures(%s, %v) => %d", txHash, holders, merged)
+		if merged == 0 {
+			return nil
+		}
+		psbt = stored
+	} else if !bitcoin.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
 		return nil
 	}
 	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
@@ -836,7 +939,11 @@ func (node *Node) httpRevokeBitcoinTransaction(ctx context.Context, txHash strin
 	if approval.State != common.RequestStateInitial {
 		return nil
 	}
-	if bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		return nil
 	}
 
@@ -852,13 +959,12 @@ func (node *Node) httpRevokeBitcoinTransaction(ctx context.Context, txHash strin
 	}
 	ms := fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash)
 	msg := bitcoin.HashMessageForSignature(ms, approval.Chain)
-	err = bitcoin.VerifySignatureDER(tx.Holder, msg, sig)
-	logger.Printf("holder: bitcoin.VerifySignatureDER(%v) => %v", tx, err)
-	if err != nil {
-		safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
-		if err != nil {
-			return err
-		}
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	signed := slices.ContainsFunc(holders, func(h string) bool {
+		return bitcoin.VerifySignatureDER(h, msg, sig) == nil
+	})
+	logger.Printf("holder: bitcoin.VerifySignatureDER(%v) => %t", tx, signed)
+	if !signed {
 		odk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
 			return err
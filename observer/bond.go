@@ -13,6 +13,7 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 )
@@ -72,6 +73,10 @@ func (node *Node) checkOrDeployKeeperBond(ctx context.Context, chain byte, asset
 	}
 	entry := node.fetchBondAssetReceiver(ctx, address, assetId)
 	logger.Printf("node.fetchBondAssetReceiver(%s, %s) => %s", address, assetId, entry)
+	err = node.checkLeader()
+	if err != nil {
+		return false, err
+	}
 	rpc, key := node.conf.PolygonRPC, node.conf.EVMKey
 	return false, abi.GetOrDeployFactoryAsset(ctx, rpc, key, assetId, asset.Symbol, asset.Name, entry, holder)
 }
@@ -102,14 +107,21 @@ func (node *Node) fetchAssetMetaFromMessengerOrEthereum(ctx context.Context, id,
 	if err != nil || meta != nil {
 		return meta, err
 	}
+	var token *ethereum.Asset
 	switch chain {
-	case common.SafeChainEthereum:
-	case common.SafeChainPolygon:
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		rpc, _ := node.ethereumParams(chain)
+		token, err = ethereum.FetchAsset(chain, rpc, assetContract)
+	case common.SafeChainSolana:
+		rpc, _ := node.solanaParams()
+		var sa *solana.Asset
+		sa, err = solana.FetchAsset(rpc, assetContract)
+		if sa != nil {
+			token = (*ethereum.Asset)(sa)
+		}
 	default:
 		panic(chain)
 	}
-	rpc, _ := node.ethereumParams(chain)
-	token, err := ethereum.FetchAsset(chain, rpc, assetContract)
 	if err != nil {
 		return nil, err
 	}
@@ -186,7 +198,13 @@ func (node *Node) fetchAssetMeta(ctx context.Context, id string) (*Asset, error)
 }
 
 func (node *Node) checkKeeperHasSufficientBond(ctx context.Context, bondId string, deposit *Deposit) (bool, error) {
-	balance, err := common.SafeAssetBalance(ctx, node.mixin, node.GetKeepers(), node.keeper.Genesis.Threshold, bondId)
+	var balance *mc.Integer
+	var err error
+	if common.CheckTestEnvironment(ctx) {
+		balance, err = node.network.AssetBalance(ctx, node.GetKeepers(), node.keeper.Genesis.Threshold, bondId)
+	} else {
+		balance, err = common.SafeAssetBalance(ctx, node.mixin, node.GetKeepers(), node.keeper.Genesis.Threshold, bondId)
+	}
 	if err != nil {
 		return false, fmt.Errorf("mixin.SafeAssetBalance(%s) => %v", bondId, err)
 	}
@@ -0,0 +1,225 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+type estimateOutput struct {
+	Receiver string `json:"receiver"`
+	Amount   string `json:"amount"`
+}
+
+// parseChainParam accepts either the chain number or the chain asset id
+func parseChainParam(id string) (byte, bool) {
+	for _, c := range []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin, common.SafeChainPolygon, common.SafeChainEthereum, common.SafeChainSolana} {
+		if id == strconv.Itoa(int(c)) || id == common.SafeChainAssetId(c) {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// httpEstimateTransaction previews the cost of a draft transaction proposal of
+// the safe, with the latest network info id for the proposal extra, the fee
+// paid by the observer accountant, the operation price and whether the draft
+// could be accepted by the keeper. It never locks any safe inputs or nonce.
+func (node *Node) httpEstimateTransaction(ctx context.Context, chain byte, address, assetId string, outputs []*estimateOutput, inputs []string) (map[string]any, error) {
+	logger.Printf("node.httpEstimateTransaction(%d, %s, %s, %d, %d)", chain, address, assetId, len(outputs), len(inputs))
+	if len(outputs) == 0 || len(outputs) > 256 {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if safe == nil || safe.Chain != chain || safe.State != common.RequestStateDone {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+	if err != nil || info == nil {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+	plan, err := node.keeperStore.ReadLatestOperationParams(ctx, chain, time.Now())
+	if err != nil || plan == nil {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+
+	data := map[string]any{
+		"chain":           chain,
+		"network_info_id": info.RequestId,
+		"fee_rate":        info.Fee,
+		"operation": map[string]any{
+			"asset_id": plan.OperationPriceAsset,
+			"amount":   plan.OperationPriceAmount.String(),
+		},
+		"transaction_minimum": plan.TransactionMinimum.String(),
+	}
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		err = node.estimateBitcoinTransaction(ctx, safe, info, plan, outputs, inputs, data)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if len(inputs) > 0 {
+			return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		err = node.estimateEthereumTransaction(ctx, safe, info, plan, assetId, outputs, data)
+	case common.SafeChainSolana:
+		// the observer pays the solana fees, so there is nothing to estimate
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	default:
+		panic(chain)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (node *Node) estimateBitcoinTransaction(ctx context.Context, safe *store.Safe, info *store.NetworkInfo, plan *store.OperationParams, draft []*estimateOutput, selected []string, data map[string]any) error {
+	minimum, dust := true, true
+	total := decimal.Zero
+	var outputs []*bitcoin.Output
+	for _, o := range draft {
+		_, err := bitcoin.ParseAddress(o.Receiver, safe.Chain)
+		if err != nil {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		amt, err := decimal.NewFromString(o.Amount)
+		if err != nil || !amt.IsPositive() {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		satoshi := bitcoin.ParseSatoshi(amt.String())
+		minimum = minimum && amt.Cmp(plan.TransactionMinimum) >= 0
+		dust = dust && satoshi >= bitcoin.ValueDust(safe.Chain)
+		total = total.Add(amt)
+		outputs = append(outputs, &bitcoin.Output{Address: o.Receiver, Satoshi: satoshi})
+	}
+
+	unspent, err := node.keeperStore.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+	if err != nil {
+		return err
+	}
+	var inputs []*bitcoin.Input
+	if len(selected) > 0 {
+		for _, s := range selected {
+			i := slices.IndexFunc(unspent, func(in *bitcoin.Input) bool {
+				return fmt.Sprintf("%s:%d", in.TransactionHash, in.Index) == s
+			})
+			if i < 0 || slices.Contains(inputs, unspent[i]) {
+				return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+			}
+			inputs = append(inputs, unspent[i])
+		}
+	} else {
+		inputs, err = bitcoin.SelectInputs(unspent, bitcoin.ParseSatoshi(total.String()), safe.Chain)
+		if err != nil && !bitcoin.IsInsufficientInputError(err) {
+			return err
+		}
+	}
+
+	sufficient := len(inputs) > 0
+	if sufficient && dust {
+		rid := uuid.Must(uuid.NewV4()).Bytes()
+		psbt, err := bitcoin.BuildPartiallySignedTransaction(inputs, outputs, rid, safe.Chain)
+		logger.Printf("bitcoin.BuildPartiallySignedTransaction(%s) => %v %v", safe.Address, psbt, err)
+		if bitcoin.IsInsufficientInputError(err) {
+			sufficient = false
+		} else if err != nil {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		} else {
+			virtualSize, fee := bitcoinEstimateAccountantFee(psbt, int64(info.Fee), safe.Chain)
+			data["virtual_size"] = virtualSize
+			data["fee"] = map[string]any{
+				"asset_id": common.SafeChainAssetId(safe.Chain),
+				"amount":   decimal.New(fee, -bitcoin.ValuePrecision).String(),
+			}
+		}
+	}
+
+	points := make([]string, len(inputs))
+	for i, in := range inputs {
+		points[i] = fmt.Sprintf("%s:%d", in.TransactionHash, in.Index)
+	}
+	data["inputs"] = points
+	data["checks"] = map[string]any{
+		"minimum": minimum && total.Cmp(plan.TransactionMinimum) >= 0,
+		"dust":    dust,
+		"balance": sufficient,
+	}
+	return nil
+}
+
+func (node *Node) estimateEthereumTransaction(ctx context.Context, safe *store.Safe, info *store.NetworkInfo, plan *store.OperationParams, assetId string, draft []*estimateOutput, data map[string]any) error {
+	if assetId == "" {
+		assetId = common.SafeChainAssetId(safe.Chain)
+	}
+	balances, err := node.keeperStore.ReadAllEthereumTokenBalances(ctx, safe.Address)
+	if err != nil {
+		return err
+	}
+	balance, token := big.NewInt(0), ethereum.EthereumEmptyAddress
+	i := slices.IndexFunc(balances, func(b *store.SafeBalance) bool { return b.AssetId == assetId })
+	if i >= 0 {
+		balance, token = balances[i].BigBalance(), balances[i].AssetAddress
+	} else if assetId != common.SafeChainAssetId(safe.Chain) {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	decimals := int32(ethereum.ValuePrecision)
+	if token != ethereum.EthereumEmptyAddress {
+		asset, err := node.keeperStore.ReadAssetMeta(ctx, assetId)
+		if err != nil || asset == nil {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		decimals = int32(asset.Decimals)
+	}
+
+	minimum := true
+	total := decimal.Zero
+	for _, o := range draft {
+		norm := ethereum.NormalizeAddress(o.Receiver)
+		if norm == ethereum.EthereumEmptyAddress || norm == safe.Address {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		amt, err := decimal.NewFromString(o.Amount)
+		if err != nil || !amt.IsPositive() {
+			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		minimum = minimum && amt.Cmp(plan.TransactionMinimum) >= 0
+		total = total.Add(amt)
+	}
+
+	txType := ethereum.TypeETHTx
+	switch {
+	case len(draft) > 1:
+		txType = ethereum.TypeMultiSendTx
+	case token != ethereum.EthereumEmptyAddress:
+		txType = ethereum.TypeERC20Tx
+	}
+	gas := ethereum.EstimateTransactionGas(txType, len(draft))
+	fee := new(big.Int).Mul(new(big.Int).SetUint64(gas), new(big.Int).SetUint64(info.Fee))
+
+	data["gas"] = gas
+	data["fee"] = map[string]any{
+		"asset_id": common.SafeChainAssetId(safe.Chain),
+		"amount":   ethereum.UnitAmount(fee, ethereum.ValuePrecision),
+	}
+	data["checks"] = map[string]any{
+		"minimum": minimum && total.Cmp(plan.TransactionMinimum) >= 0,
+		"dust":    true,
+		"balance": balance.Cmp(ethereum.ParseAmount(total.String(), decimals)) >= 0,
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverTransactionEstimate(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kd, err := store.OpenSQLite3Store(t.TempDir() + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+	node := &Node{store: db, keeperStore: kd}
+
+	index := 0
+	request := func(role, action uint8, createdAt time.Time) *common.Request {
+		index = index + 1
+		req := &common.Request{
+			Id:         uuid.Must(uuid.NewV4()).String(),
+			MixinIndex: index,
+			AssetId:    common.SafeBitcoinChainId,
+			Amount:     decimal.Zero,
+			Role:       role,
+			Action:     action,
+			Curve:      common.CurveSecp256k1ECDSABitcoin,
+			Holder:     testStatementHolder,
+			State:      common.RequestStateInitial,
+			CreatedAt:  createdAt,
+			Sequence:   uint64(index),
+			Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+		}
+		err := kd.WriteRequestIfNotExist(ctx, req)
+		require.Nil(err)
+		return req
+	}
+
+	start := time.Now().UTC().Add(-time.Hour)
+	req := request(common.RequestRoleHolder, common.ActionBitcoinSafeProposeAccount, start)
+	safe := &store.Safe{
+		Holder:    testStatementHolder,
+		Chain:     common.SafeChainBitcoin,
+		Signer:    testStatementHolder,
+		Observer:  testStatementHolder,
+		Timelock:  time.Hour * 24,
+		Path:      "00000000",
+		Address:   testStatementAddress,
+		Extra:     []byte{},
+		Receivers: []string{uuid.Must(uuid.NewV4()).String()},
+		Threshold: 1,
+		RequestId: req.Id,
+		State:     common.RequestStateDone,
+		CreatedAt: start,
+		UpdatedAt: start,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+
+	outputs := []*estimateOutput{{Receiver: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", Amount: "0.001"}}
+	_, err = node.httpEstimateTransaction(ctx, common.SafeChainBitcoin, safe.Address, "", outputs, nil)
+	require.NotNil(err)
+
+	req = request(common.RequestRoleObserver, common.ActionObserverUpdateNetworkStatus, start)
+	info := &store.NetworkInfo{
+		RequestId: req.Id,
+		Chain:     common.SafeChainBitcoin,
+		Fee:       20,
+		Height:    840000,
+		Hash:      "00000000000000000002a4f5cd899ea457314c808897c5c5f1f1cd6ffe2b266a",
+		CreatedAt: start,
+	}
+	err = kd.WriteNetworkInfoFromRequest(ctx, info, req)
+	require.Nil(err)
+	req = request(common.RequestRoleObserver, common.ActionObserverSetOperationParams, start)
+	params := &store.OperationParams{
+		RequestId:            req.Id,
+		Chain:                common.SafeChainBitcoin,
+		OperationPriceAsset:  common.SafeBitcoinChainId,
+		OperationPriceAmount: decimal.RequireFromString("0.0001"),
+		TransactionMinimum:   decimal.RequireFromString("0.0001"),
+		CreatedAt:            start,
+	}
+	err = kd.WriteOperationParamsFromRequest(ctx, params, req)
+	require.Nil(err)
+
+	data, err := node.httpEstimateTransaction(ctx, common.SafeChainBitcoin, safe.Address, "", outputs, nil)
+	require.Nil(err)
+	require.Equal(info.RequestId, data["network_info_id"])
+	require.Equal("0.0001", data["transaction_minimum"])
+	checks := data["checks"].(map[string]any)
+	require.True(checks["minimum"].(bool))
+	require.True(checks["dust"].(bool))
+	require.False(checks["balance"].(bool))
+	require.Len(data["inputs"], 0)
+
+	outputs = append(outputs, &estimateOutput{Receiver: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", Amount: "0.000005"})
+	data, err = node.httpEstimateTransaction(ctx, common.SafeChainBitcoin, safe.Address, "", outputs, nil)
+	require.Nil(err)
+	checks = data["checks"].(map[string]any)
+	require.False(checks["minimum"].(bool))
+	require.False(checks["dust"].(bool))
+
+	outputs = []*estimateOutput{{Receiver: "invalid", Amount: "0.001"}}
+	_, err = node.httpEstimateTransaction(ctx, common.SafeChainBitcoin, safe.Address, "", outputs, nil)
+	require.NotNil(err)
+	_, err = node.httpEstimateTransaction(ctx, common.SafeChainPolygon, safe.Address, "", outputs, nil)
+	require.NotNil(err)
+
+	chain, valid := parseChainParam(common.SafeBitcoinChainId)
+	require.True(valid)
+	require.Equal(byte(common.SafeChainBitcoin), chain)
+	chain, valid = parseChainParam("6")
+	require.True(valid)
+	require.Equal(byte(common.SafeChainPolygon), chain)
+	_, valid = parseChainParam("3")
+	require.False(valid)
+}
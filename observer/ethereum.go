@@ -60,18 +60,24 @@ func (node *Node) deployEthereumGnosisSafeAccount(ctx context.Context, data []by
 	if err != nil {
 		return err
 	}
-	owners, pubs := ethereum.GetSortedSafeOwners(safe.Holder, safe.Signer, safe.Observer)
-	logger.Printf("ethereum.GetSortedSafeOwners(%s, %s, %s) => %v %v", safe.Holder, safe.Signer, safe.Observer, owners, pubs)
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
+	owners, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	logger.Printf("ethereum.GetSortedSafeOwnersWithHolders(%v, %s, %s) => %v %v", holders, safe.Signer, safe.Observer, owners, pubs)
 	var index int64
 	for i, pub := range pubs {
 		if pub == safe.Observer {
 			index = int64(i)
 		}
 	}
+	err = node.checkLeader()
+	if err != nil {
+		return err
+	}
 	timelock := int64(safe.Timelock / time.Hour)
 	chainId := ethereum.GetEvmChainID(int64(safe.Chain))
-	sa, err := ethereum.GetOrDeploySafeAccount(ctx, rpc, node.conf.EVMKey, chainId, owners, 2, timelock, index, t)
-	logger.Printf("ethereum.GetOrDeploySafeAccount(%s, %d, %v, %d, %d, %v) => %s %v", rpc, chainId, owners, 2, timelock, t, sa, err)
+	safeThreshold := ethereum.GetSafeThreshold(threshold)
+	sa, err := ethereum.GetOrDeploySafeAccount(ctx, rpc, node.conf.EVMKey, chainId, owners, safeThreshold, timelock, index, t)
+	logger.Printf("ethereum.GetOrDeploySafeAccount(%s, %d, %v, %d, %d, %v) => %s %v", rpc, chainId, owners, safeThreshold, timelock, t, sa, err)
 	if err != nil {
 		return err
 	}
@@ -320,8 +326,18 @@ func (node *Node) sendKeeperDepositTransaction(ctx context.Context, deposit *Dep
 		} else if !sufficient {
 			return nil
 		}
-		extra := deposit.encodeKeeperExtra(decimals)
-		err = node.sendKeeperResponse(ctx, deposit.Holder, deposit.Category, deposit.Chain, deposit.RequestId, extra)
+		var extra []byte
+		var references []crypto.Hash
+		switch deposit.Chain {
+		case common.SafeChainSolana:
+			extra, references, err = node.writeSolanaDepositStorage(ctx, deposit, decimals)
+			if err != nil {
+				return fmt.Errorf("node.writeSolanaDepositStorage(%s) => %v", deposit.RequestId, err)
+			}
+		default:
+			extra = deposit.encodeKeeperExtra(decimals)
+		}
+		err = node.sendKeeperResponseWithReferences(ctx, deposit.Holder, deposit.Category, deposit.Chain, deposit.RequestId, extra, references)
 		if err != nil {
 			return fmt.Errorf("node.sendKeeperResponse(%s) => %v", deposit.RequestId, err)
 		}
@@ -539,9 +555,18 @@ func (node *Node) sendToKeeperEthereumApproveNormalTransaction(ctx context.Conte
 	if err != nil || signed {
 		return err
 	}
-	if !ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if !node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		panic(approval.RawTransaction)
 	}
+	locked, err := node.checkSafeOutflowLocked(ctx, approval.TransactionHash)
+	if err != nil || locked {
+		return err
+	}
 
 	rawId := common.UniqueId(approval.RawTransaction, approval.RawTransaction)
 	raw := common.DecodeHexOrPanic(approval.RawTransaction)
@@ -549,7 +574,7 @@ func (node *Node) sendToKeeperEthereumApproveNormalTransaction(ctx context.Conte
 	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
 	msg := base64.RawURLEncoding.EncodeToString(raw)
 	traceId := common.UniqueId(msg, msg)
-	ref, err := common.WriteStorageUntilSufficient(ctx, node.mixin, raw, traceId, node.safeUser())
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
 	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
 	if err != nil {
 		return err
@@ -594,7 +619,7 @@ func (node *Node) sendToKeeperEthereumApproveRecoveryTransaction(ctx context.Con
 	if err != nil {
 		return err
 	}
-	signedByHolder := ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	signedByHolder := node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 
 	var extra []byte
 	switch {
@@ -620,7 +645,7 @@ func (node *Node) sendToKeeperEthereumApproveRecoveryTransaction(ctx context.Con
 	objectRaw = common.AESEncrypt(node.aesKey[:], objectRaw, rawId)
 	msg := base64.RawURLEncoding.EncodeToString(objectRaw)
 	traceId := common.UniqueId(msg, msg)
-	ref, err := common.WriteStorageUntilSufficient(ctx, node.mixin, objectRaw, traceId, node.safeUser())
+	ref, err := node.writeStorageUntilSufficient(ctx, objectRaw, traceId)
 	logger.Printf("common.CreateObjectUntilSufficient(%v) => %s %v", msg, ref, err)
 	if err != nil {
 		return err
@@ -697,7 +722,7 @@ func (node *Node) httpCreateEthereumAccountRecoveryRequest(ctx context.Context,
 		if approval.RawTransaction != raw {
 			return nil
 		}
-		if ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+		if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 			return nil
 		}
 
@@ -711,7 +736,7 @@ func (node *Node) httpCreateEthereumAccountRecoveryRequest(ctx context.Context,
 			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 		}
 
-		if !ethereum.CheckTransactionPartiallySignedBy(raw, safe.Holder) {
+		if !node.checkTransactionSignedByHolders(ctx, safe, raw) {
 			return nil
 		}
 	}
@@ -799,7 +824,7 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 		return nil
 	}
 
-	isHolderSigned := ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	isHolderSigned := node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 	if !ethereum.CheckTransactionPartiallySignedBy(raw, safe.Observer) {
 		return fmt.Errorf("ethereum.CheckTransactionPartiallySignedBy(%s, %s) observer", raw, safe.Observer)
 	}
@@ -873,8 +898,8 @@ func (node *Node) httpSignEthereumAccountRecoveryRequest(ctx context.Context, sa
 			return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 		}
 	case isHolderSigned: // Close account with holder key
-		if !ethereum.CheckTransactionPartiallySignedBy(raw, safe.Holder) {
-			return fmt.Errorf("ethereum.CheckTransactionPartiallySignedBy(%s, %s) holder", raw, safe.Holder)
+		if !node.checkTransactionSignedByHolders(ctx, safe, raw) {
+			return fmt.Errorf("ethereum.CheckTransactionPartiallySignedByHolders(%s, %s) holder", raw, safe.Holder)
 		}
 	}
 
@@ -903,10 +928,26 @@ func (node *Node) httpApproveEthereumTransaction(ctx context.Context, raw string
 	if approval.State != common.RequestStateInitial {
 		return nil
 	}
-	if ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		return nil
 	}
-	if !ethereum.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	if len(holders) > 1 {
+		// collect the signatures of the holder keys until the threshold reached
+		stored, _ := ethereum.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+		_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+		merged := stored.MergeSignatures(st, pubs, holders)
+		logger.Printf("ethereum.MergeSignatures(%s, %v) => %d", st.TxHash, holders, merged)
+		if merged == 0 {
+			return nil
+		}
+		raw = hex.EncodeToString(stored.Marshal())
+	} else if !ethereum.CheckTransactionPartiallySignedBy(raw, approval.Holder) {
 		return nil
 	}
 	tx, err := node.keeperStore.ReadTransaction(ctx, st.TxHash)
@@ -930,7 +971,11 @@ func (node *Node) httpRevokeEthereumTransaction(ctx context.Context, txHash stri
 	if approval.State != common.RequestStateInitial {
 		return nil
 	}
-	if ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, approval.Holder) {
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
 		return nil
 	}
 
@@ -945,13 +990,12 @@ func (node *Node) httpRevokeEthereumTransaction(ctx context.Context, txHash stri
 		return err
 	}
 	msg := []byte(fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash))
-	err = ethereum.VerifyMessageSignature(tx.Holder, msg, sig)
-	logger.Printf("holder: ethereum.VerifyMessageSignature(%v) => %v", tx, err)
-	if err != nil {
-		safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
-		if err != nil {
-			return err
-		}
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	signed := slices.ContainsFunc(holders, func(h string) bool {
+		return ethereum.VerifyMessageSignature(h, msg, sig) == nil
+	})
+	logger.Printf("holder: ethereum.VerifyMessageSignature(%v) => %t", tx, signed)
+	if !signed {
 		err = ethereum.VerifyMessageSignature(safe.Observer, msg, sig)
 		logger.Printf("observer: ethereum.VerifyMessageSignature(%v) => %v", tx, err)
 		if err != nil {
@@ -0,0 +1,71 @@
+package observer
+
+import (
+	"context"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/gofrs/uuid/v5"
+)
+
+// transactionExpiryLoop expires the proposed transactions never approved
+// before the proposal expiry of the operation params when proposed, so that
+// the locked inputs or nonce are released and the safe asset refunded
+func (node *Node) transactionExpiryLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(time.Minute)
+		until := time.Now().Add(-keeper.SafeProposalExpiryMinimum)
+		txs, err := node.keeperStore.ListProposedTransactions(ctx, chain, until)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			err := node.sendToKeeperExpireTransaction(ctx, tx)
+			logger.Printf("node.sendToKeeperExpireTransaction(%v) => %v", tx, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperExpireTransaction(ctx context.Context, tx *store.Transaction) error {
+	params, err := node.keeperStore.ReadLatestOperationParams(ctx, tx.Chain, tx.CreatedAt)
+	if err != nil || params == nil || params.ProposalExpiry == 0 {
+		return err
+	}
+	if tx.CreatedAt.Add(params.ProposalExpiry).After(time.Now()) {
+		return nil
+	}
+
+	// the approval paid is being sent to keeper, and the revoked or expired
+	// approval is failed already
+	approval, err := node.store.ReadTransactionApproval(ctx, tx.TransactionHash)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+
+	var action int
+	switch tx.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		action = common.ActionBitcoinSafeExpireTransaction
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		action = common.ActionEthereumSafeExpireTransaction
+	default:
+		panic(tx.Chain)
+	}
+	id := common.UniqueId(tx.TransactionHash, "EXPIRE")
+	extra := uuid.Must(uuid.FromString(tx.RequestId)).Bytes()
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), tx.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x) => %v", tx.Holder, action, id, extra, err)
+	if err != nil {
+		return err
+	}
+	return node.store.ExpireTransactionApproval(ctx, tx.TransactionHash)
+}
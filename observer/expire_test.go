@@ -0,0 +1,88 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverTransactionExpiry(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kd, err := store.OpenSQLite3Store(t.TempDir() + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+
+	err = kd.MigrateOperationParamsExpiry(ctx)
+	require.Nil(err)
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	req := &common.Request{
+		Id:         uuid.Must(uuid.NewV4()).String(),
+		MixinIndex: 1,
+		AssetId:    common.SafeBitcoinChainId,
+		Amount:     decimal.Zero,
+		Role:       common.RequestRoleObserver,
+		Action:     common.ActionObserverSetOperationParams,
+		Curve:      common.CurveSecp256k1ECDSABitcoin,
+		Holder:     testStatementHolder,
+		State:      common.RequestStateInitial,
+		CreatedAt:  start,
+		Sequence:   1,
+		Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+	}
+	err = kd.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	params := &store.OperationParams{
+		RequestId:            req.Id,
+		Chain:                common.SafeChainBitcoin,
+		OperationPriceAsset:  common.SafeBitcoinChainId,
+		OperationPriceAmount: decimal.RequireFromString("0.0001"),
+		TransactionMinimum:   decimal.RequireFromString("0.0001"),
+		ProposalExpiry:       24 * time.Hour,
+		CreatedAt:            start,
+	}
+	err = kd.WriteOperationParamsFromRequest(ctx, params, req)
+	require.Nil(err)
+	op, err := kd.ReadLatestOperationParams(ctx, common.SafeChainBitcoin, start.Add(time.Hour))
+	require.Nil(err)
+	require.Equal(24*time.Hour, op.ProposalExpiry)
+	err = kd.MigrateOperationParamsExpiry(ctx)
+	require.Nil(err)
+
+	txs, err := kd.ListProposedTransactions(ctx, common.SafeChainBitcoin, time.Now())
+	require.Nil(err)
+	require.Len(txs, 0)
+
+	approval := &Transaction{
+		TransactionHash: "b7a4a3c40ebc2fca2e2b5fa1ba4a8c1c1d1bfd0eb7df42a2a52ab23e30b7a4c1",
+		RawTransaction:  "raw",
+		Chain:           common.SafeChainBitcoin,
+		Holder:          testStatementHolder,
+		Signer:          testStatementHolder,
+		State:           common.RequestStateInitial,
+		CreatedAt:       start,
+		UpdatedAt:       start,
+	}
+	err = db.WriteTransactionApprovalIfNotExists(ctx, approval)
+	require.Nil(err)
+	err = db.ExpireTransactionApproval(ctx, approval.TransactionHash)
+	require.Nil(err)
+	old, err := db.ReadTransactionApproval(ctx, approval.TransactionHash)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStateFailed), old.State)
+	require.Equal("raw", old.RawTransaction)
+	err = db.ExpireTransactionApproval(ctx, approval.TransactionHash)
+	require.NotNil(err)
+}
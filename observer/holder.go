@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"math/big"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,7 +16,9 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
 	gc "github.com/ethereum/go-ethereum/common"
 	"github.com/gofrs/uuid/v5"
 )
@@ -31,6 +34,12 @@ func (node *Node) getSafeStatus(ctx context.Context, proposalId string) (string,
 	}
 	switch int(safe.State) {
 	case common.RequestStateFailed:
+		m, err := node.keeperStore.ReadLatestSafeMigration(ctx, safe.Address)
+		if err != nil {
+			return "", err
+		} else if m != nil && m.State == common.RequestStateDone {
+			return "migrated", nil
+		}
 		return "failed", nil
 	case common.RequestStatePending:
 		return "pending", nil
@@ -43,7 +52,7 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 	logger.Printf("node.keeperSaveAccountProposal(%d, %x, %s)", chain, extra, createdAt)
 	var address string
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		wsa, err := bitcoin.UnmarshalWitnessScriptAccount(extra)
 		if err != nil {
 			return err
@@ -55,6 +64,12 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 			return err
 		}
 		address = gs.Address
+	case common.SafeChainSolana:
+		sa, err := solana.UnmarshalSafeAccount(extra)
+		if err != nil {
+			return err
+		}
+		address = sa.Address
 	default:
 		panic(chain)
 	}
@@ -68,10 +83,12 @@ func (node *Node) keeperSaveAccountProposal(ctx context.Context, chain byte, ext
 
 	var assetId string
 	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		_, assetId = node.bitcoinParams(sp.Chain)
 	case common.SafeChainEthereum, common.SafeChainPolygon:
 		_, assetId = node.ethereumParams(sp.Chain)
+	case common.SafeChainSolana:
+		_, assetId = node.solanaParams()
 	}
 	_, err = node.checkOrDeployKeeperBond(ctx, chain, assetId, "", sp.Holder, sp.Address)
 	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", assetId, sp.Holder, err)
@@ -85,12 +102,15 @@ func (node *Node) keeperSaveTransactionProposal(ctx context.Context, chain byte,
 	logger.Printf("node.keeperSaveTransactionProposal(%x, %s)", extra, createdAt)
 	var txHash string
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		psbt, _ := bitcoin.UnmarshalPartiallySignedTransaction(extra)
 		txHash = psbt.UnsignedTx.TxHash().String()
 	case common.SafeChainEthereum, common.SafeChainPolygon:
 		t, _ := ethereum.UnmarshalSafeTransaction(extra)
 		txHash = t.TxHash
+	case common.SafeChainSolana:
+		t, _ := solana.UnmarshalSafeTransaction(extra)
+		txHash = t.TxHash
 	}
 	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
 	if err != nil {
@@ -123,21 +143,28 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 	if err != nil {
 		return err
 	}
+	holders, threshold, err := node.keeperStore.ReadSafeHolderKeys(ctx, sp.Address, sp.Holder)
+	if err != nil {
+		return err
+	}
 
 	var sig []byte
+	var verify func(public string, sig []byte) error
+	var encode func(sig []byte) string
 	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		sig, err = base64.RawURLEncoding.DecodeString(signature)
 		if err != nil {
 			return err
 		}
 		ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, sp.Address)
 		hash := bitcoin.HashMessageForSignature(ms, sp.Chain)
-		err = bitcoin.VerifySignatureDER(sp.Holder, hash, sig)
-		logger.Printf("bitcoin.VerifySignatureDER(%v) => %v", sp, err)
-		if err != nil {
+		verify = func(public string, sig []byte) error {
+			err := bitcoin.VerifySignatureDER(public, hash, sig)
+			logger.Printf("bitcoin.VerifySignatureDER(%v, %s) => %v", sp, public, err)
 			return err
 		}
+		encode = base64.RawURLEncoding.EncodeToString
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		sig, err = hex.DecodeString(signature)
 		if err != nil {
@@ -162,15 +189,79 @@ func (node *Node) httpApproveSafeAccount(ctx context.Context, addr, signature st
 		if err != nil {
 			return err
 		}
-		err = ethereum.VerifyMessageSignature(sp.Holder, st.Message, sig)
-		logger.Printf("ethereum.VerifyMessageSignature(%s %s %s) => %v", sp.Holder, hex.EncodeToString(st.Message), hex.EncodeToString(sig), err)
+		verify = func(public string, sig []byte) error {
+			err := ethereum.VerifyMessageSignature(public, st.Message, sig)
+			logger.Printf("ethereum.VerifyMessageSignature(%s %s %s) => %v", public, hex.EncodeToString(st.Message), hex.EncodeToString(sig), err)
+			return err
+		}
+		encode = hex.EncodeToString
+	case common.SafeChainSolana:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		sa, err := solana.UnmarshalSafeAccount(sp.Extra)
+		logger.Printf("solana.UnmarshalSafeAccount(%s) => %v %v", hex.EncodeToString(sp.Extra), sa, err)
 		if err != nil {
 			return err
 		}
+		tx, err := node.keeperStore.ReadTransaction(ctx, sa.TxHash)
+		logger.Printf("keeperStore.ReadTransaction(%s) => %v %v", sa.TxHash, tx, err)
+		if err != nil {
+			return err
+		}
+		raw, err := hex.DecodeString(tx.RawTransaction)
+		if err != nil {
+			return err
+		}
+		st, err := solana.UnmarshalSafeTransaction(raw)
+		logger.Printf("solana.UnmarshalSafeTransaction(%s) => %v %v", tx.RawTransaction, st, err)
+		if err != nil {
+			return err
+		}
+		verify = func(public string, sig []byte) error {
+			err := solana.VerifyMessageSignature(public, st.Message, sig)
+			logger.Printf("solana.VerifyMessageSignature(%s %x %x) => %v", public, st.Message, sig, err)
+			return err
+		}
+		encode = hex.EncodeToString
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
-	return node.saveAccountApprovalSignature(ctx, sp.Address, signature)
+
+	if len(holders) == 1 {
+		err = verify(sp.Holder, sig)
+		if err != nil {
+			return err
+		}
+		return node.saveAccountApprovalSignature(ctx, sp.Address, signature)
+	}
+	return node.saveAccountHolderSignature(ctx, sp.Address, holders, threshold, sig, verify, encode)
+}
+
+// saveAccountHolderSignature collects the approval signatures of the holder
+// keys, and saves the encoded signatures as the account approval signature
+// once the holders threshold reached
+func (node *Node) saveAccountHolderSignature(ctx context.Context, addr string, holders []string, threshold int, sig []byte, verify func(public string, sig []byte) error, encode func(sig []byte) string) error {
+	index := slices.IndexFunc(holders, func(h string) bool { return verify(h, sig) == nil })
+	if index < 0 {
+		return fmt.Errorf("invalid holders signature %x", sig)
+	}
+	err := node.store.WriteAccountHolderSignature(ctx, addr, holders[index], hex.EncodeToString(sig))
+	if err != nil {
+		return err
+	}
+	signed, err := node.store.ListAccountHolderSignatures(ctx, addr)
+	if err != nil || len(signed) < threshold {
+		return err
+	}
+	sigs := make([][]byte, len(holders))
+	for i, h := range holders {
+		if s := signed[h]; s != "" {
+			sigs[i] = common.DecodeHexOrPanic(s)
+		}
+	}
+	return node.saveAccountApprovalSignature(ctx, addr, encode(common.EncodeHolderSignatures(sigs)))
 }
 
 func (node *Node) httpCreateSafeAccountRecoveryRequest(ctx context.Context, addr, raw, hash string) error {
@@ -195,7 +286,7 @@ func (node *Node) httpCreateSafeAccountRecoveryRequest(ctx context.Context, addr
 	}
 
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.httpCreateBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		return node.httpCreateEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
@@ -204,6 +295,116 @@ func (node *Node) httpCreateSafeAccountRecoveryRequest(ctx context.Context, addr
 	}
 }
 
+// httpAuthorizeSafeConsolidation saves the holder signatures reaching the holder
+// threshold of the safe, which only allow the observer to propose one
+// consolidation, and the holders still approve the transaction proposed
+func (node *Node) httpAuthorizeSafeConsolidation(ctx context.Context, addr, nonce, signature string) error {
+	logger.Printf("node.httpAuthorizeSafeConsolidation(%s, %s, %s)", addr, nonce, signature)
+	id, err := uuid.FromString(nonce)
+	if err != nil {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if safe == nil || safe.State != common.RequestStateDone {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+	default:
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	ms := fmt.Sprintf("CONSOLIDATE:%s:%s:%s", safe.RequestId, safe.Address, id.String())
+	hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+	err = node.verifySafeHolderSignatures(ctx, safe, sig, func(public string, sig []byte) error {
+		return bitcoin.VerifySignatureDER(public, hash, sig)
+	})
+	logger.Printf("node.verifySafeHolderSignatures(%v) => %v", safe, err)
+	if err != nil {
+		return fmt.Errorf("invalid holders signature %x", sig)
+	}
+
+	now := time.Now().UTC()
+	return node.store.WriteConsolidation(ctx, &Consolidation{
+		Address:   safe.Address,
+		Chain:     safe.Chain,
+		Holder:    safe.Holder,
+		RequestId: id.String(),
+		Signature: hex.EncodeToString(sig),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// httpRefreshSafeTimelock proposes a transaction spending the safe funds to
+// itself with the holder signatures reaching the holder threshold, which resets
+// the recovery timelock once the holders approve the transaction, the nonce
+// signed is used as the request id
+func (node *Node) httpRefreshSafeTimelock(ctx context.Context, addr, nonce, signature string) error {
+	logger.Printf("node.httpRefreshSafeTimelock(%s, %s, %s)", addr, nonce, signature)
+	id, err := uuid.FromString(nonce)
+	if err != nil {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, addr)
+	if err != nil {
+		return err
+	}
+	if safe == nil || safe.State != common.RequestStateDone {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	var sig []byte
+	var action int
+	var verify func(public string, sig []byte) error
+	ms := fmt.Sprintf("REFRESH:%s:%s:%s", safe.RequestId, safe.Address, id.String())
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		sig, err = base64.RawURLEncoding.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		hash := bitcoin.HashMessageForSignature(ms, safe.Chain)
+		verify = func(public string, sig []byte) error {
+			return bitcoin.VerifySignatureDER(public, hash, sig)
+		}
+		action = common.ActionBitcoinSafeRefreshTimelock
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		sig, err = hex.DecodeString(signature)
+		if err != nil {
+			return err
+		}
+		verify = func(public string, sig []byte) error {
+			return ethereum.VerifyMessageSignature(public, []byte(ms), sig)
+		}
+		action = common.ActionEthereumSafeRefreshTimelock
+	default:
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	err = node.verifySafeHolderSignatures(ctx, safe, sig, verify)
+	logger.Printf("node.verifySafeHolderSignatures(%v) => %v", safe, err)
+	if err != nil {
+		return fmt.Errorf("invalid holders signature %x", sig)
+	}
+
+	count, err := node.store.CountUnfinishedTransactionApprovalsForHolder(ctx, safe.Holder)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	err = node.sendKeeperResponseWithHolderSignatures(ctx, safe, byte(action), id.String(), sig)
+	logger.Printf("node.sendKeeperResponseWithHolderSignatures(%s, %d, %s, %x) => %v", safe.Holder, action, id, sig, err)
+	return err
+}
+
 func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw, hash string) error {
 	logger.Printf("node.httpSignAccountRecoveryRequest(%s, %s, %s)", addr, raw, hash)
 	proposed, err := node.store.CheckAccountProposed(ctx, addr)
@@ -234,7 +435,7 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 	}
 
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.httpSignBitcoinAccountRecoveryRequest(ctx, safe, raw, hash)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		return node.httpSignEthereumAccountRecoveryRequest(ctx, safe, raw, hash)
@@ -245,10 +446,12 @@ func (node *Node) httpSignAccountRecoveryRequest(ctx context.Context, addr, raw,
 
 func (node *Node) httpApproveSafeTransaction(ctx context.Context, chain byte, raw string) error {
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.httpApproveBitcoinTransaction(ctx, raw)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		return node.httpApproveEthereumTransaction(ctx, raw)
+	case common.SafeChainSolana:
+		return node.httpApproveSolanaTransaction(ctx, raw)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
@@ -256,10 +459,12 @@ func (node *Node) httpApproveSafeTransaction(ctx context.Context, chain byte, ra
 
 func (node *Node) httpRevokeSafeTransaction(ctx context.Context, chain byte, hash, sig string) error {
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return node.httpRevokeBitcoinTransaction(ctx, hash, sig)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		return node.httpRevokeEthereumTransaction(ctx, hash, sig)
+	case common.SafeChainSolana:
+		return node.httpRevokeSolanaTransaction(ctx, hash, sig)
 	default:
 		return fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
 	}
@@ -282,16 +487,21 @@ func (node *Node) holderPayTransactionApproval(ctx context.Context, chain byte,
 	}
 	var signedByHolder, signedByObserver bool
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
-		signedByHolder = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		signedByHolder = node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 		opk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
 			panic(err)
 		}
 		signedByObserver = bitcoin.CheckTransactionPartiallySignedBy(approval.RawTransaction, opk)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
-		signedByHolder = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Holder)
+		signedByHolder = node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
 		signedByObserver = ethereum.CheckTransactionPartiallySignedBy(approval.RawTransaction, safe.Observer)
+	case common.SafeChainSolana:
+		signedByHolder = node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction)
+		holders, _ := node.readSafeHolderKeys(ctx, safe)
+		owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+		signedByObserver = solana.CheckTransactionPartiallySignedBy(approval.RawTransaction, owners, safe.Observer)
 	}
 	if !signedByHolder && !signedByObserver {
 		return nil
@@ -320,7 +530,7 @@ func (deposit *Deposit) encodeKeeperExtra(decimals int32) []byte {
 
 func (d *Deposit) bigAmount(decimals int32) *big.Int {
 	switch d.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		if decimals != bitcoin.ValuePrecision {
 			panic(decimals)
 		}
@@ -328,6 +538,77 @@ func (d *Deposit) bigAmount(decimals int32) *big.Int {
 		return new(big.Int).SetInt64(satoshi)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		return ethereum.ParseAmount(d.Amount, decimals)
+	case common.SafeChainSolana:
+		return new(big.Int).SetUint64(solana.ParseAmount(d.Amount, decimals))
 	}
 	panic(0)
 }
+
+// readSafeHolderKeys returns the holder keys and threshold of the safe, the
+// holder is always the first key
+// writeAccountHolderSignatures writes the encoded approval signatures of the
+// holder keys to the storage, which are too large for the keeper request
+func (node *Node) writeAccountHolderSignatures(ctx context.Context, addr string, sig []byte) (crypto.Hash, error) {
+	rawId := common.UniqueId(addr, hex.EncodeToString(sig))
+	raw := append(uuid.Must(uuid.FromString(rawId)).Bytes(), sig...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
+	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	return ref, err
+}
+
+// sendKeeperResponseWithHolderSignatures sends the holder signature in the
+// request extra, or the observer storage hash of the signatures encoded for
+// multiple holder keys, which are too large for the request
+func (node *Node) sendKeeperResponseWithHolderSignatures(ctx context.Context, safe *store.Safe, typ byte, id string, sig []byte) error {
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	if len(holders) == 1 {
+		return node.sendKeeperResponse(ctx, safe.Holder, typ, safe.Chain, id, sig)
+	}
+	ref, err := node.writeAccountHolderSignatures(ctx, safe.Address, sig)
+	if err != nil {
+		return err
+	}
+	return node.sendKeeperResponseWithReferences(ctx, safe.Holder, typ, safe.Chain, id, ref[:], []crypto.Hash{ref})
+}
+
+func (node *Node) readSafeHolderKeys(ctx context.Context, safe *store.Safe) ([]string, int) {
+	holders, threshold, err := node.keeperStore.ReadSafeHolderKeys(ctx, safe.Address, safe.Holder)
+	if err != nil {
+		panic(fmt.Errorf("keeperStore.ReadSafeHolderKeys(%s) => %v", safe.Address, err))
+	}
+	return holders, threshold
+}
+
+// verifySafeHolderSignatures verifies the holder signature, or the holder
+// signatures encoded with common.EncodeHolderSignatures for multiple holders,
+// which must reach the holder threshold of the safe
+func (node *Node) verifySafeHolderSignatures(ctx context.Context, safe *store.Safe, sig []byte, verify func(public string, sig []byte) error) error {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
+	sigs := [][]byte{sig}
+	if len(holders) > 1 {
+		var err error
+		sigs, err = common.DecodeHolderSignatures(sig, len(holders))
+		if err != nil {
+			return err
+		}
+	}
+	return common.VerifyHolderSignatures(holders, threshold, sigs, verify)
+}
+
+func (node *Node) checkTransactionSignedByHolders(ctx context.Context, safe *store.Safe, raw string) bool {
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		return bitcoin.CheckTransactionPartiallySignedByHolders(raw, holders, threshold)
+	case common.SafeChainPolygon, common.SafeChainEthereum:
+		return ethereum.CheckTransactionPartiallySignedByHolders(raw, holders, threshold)
+	case common.SafeChainSolana:
+		owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+		return solana.CheckTransactionPartiallySignedByHolders(raw, owners, holders, threshold)
+	default:
+		panic(safe.Chain)
+	}
+}
@@ -4,6 +4,7 @@ package observer
 
 import (
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	_ "embed"
 	"encoding/hex"
@@ -15,8 +16,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/btcsuite/btcd/btcutil"
@@ -91,6 +94,14 @@ var GUIDE = `
 `
 
 func (node *Node) StartHTTP(version, readme string) {
+	handler := node.HTTPHandler(version, readme)
+	err := http.ListenAndServe(fmt.Sprintf(":%d", 7080), handler)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) HTTPHandler(version, readme string) http.Handler {
 	VERSION = version
 	GUIDE = strings.TrimSpace(strings.Replace(GUIDE, "README", readme, -1))
 
@@ -101,6 +112,7 @@ func (node *Node) StartHTTP(version, readme string) {
 	router.GET("/", node.httpIndex)
 	router.GET("/favicon.ico", node.httpFavicon)
 	router.GET("/chains", node.httpListChains)
+	router.POST("/chains/:id/estimate", node.httpEstimateChainTransaction)
 	router.GET("/signers", node.httpListSigners)
 	router.GET("/keepers", node.httpListKeepers)
 	router.GET("/deposits", node.httpListDeposits)
@@ -109,14 +121,17 @@ func (node *Node) StartHTTP(version, readme string) {
 	router.POST("/recoveries/:id", node.httpSignRecovery)
 	router.GET("/accounts/:id", node.httpGetAccount)
 	router.POST("/accounts/:id", node.httpApproveAccount)
+	router.GET("/accounts/:id/statements", node.httpGetAccountStatements)
+	router.GET("/accounts/:id/timelock", node.httpGetAccountTimelock)
+	router.GET("/timelocks", node.httpListTimelocks)
 	router.GET("/transactions/:id", node.httpGetTransaction)
 	router.POST("/transactions/:id", node.httpApproveTransaction)
+	router.GET("/batches/:id", node.httpGetTransactionBatch)
+	router.POST("/batches", node.httpCreateBatch)
+	router.GET("/messages/:id", node.httpGetSafeMessage)
+	router.POST("/messages", node.httpCreateMessage)
 	router.GET("/keys/:public", node.httpGetCustomKey)
-	handler := common.HandleCORS(router)
-	err := http.ListenAndServe(fmt.Sprintf(":%d", 7080), handler)
-	if err != nil {
-		panic(err)
-	}
+	return common.HandleCORS(node.handleStandby(router))
 }
 
 func (node *Node) httpIndex(w http.ResponseWriter, r *http.Request, params map[string]string) {
@@ -136,7 +151,7 @@ func (node *Node) httpIndex(w http.ResponseWriter, r *http.Request, params map[s
 		"version":  VERSION,
 		"observer": node.conf.App.AppId,
 		"bond": map[string]any{
-			"chain":    137,
+			"chain":    ethereum.GetEvmChainID(ethereum.ChainPolygon),
 			"contract": node.conf.PolygonFactoryAddress,
 		},
 		"keeper": map[string]any{
@@ -163,7 +178,7 @@ func (node *Node) httpFavicon(w http.ResponseWriter, r *http.Request, params map
 
 func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var cs []map[string]any
-	for _, c := range []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainPolygon, common.SafeChainEthereum} {
+	for _, c := range []byte{common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin, common.SafeChainPolygon, common.SafeChainEthereum, common.SafeChainSolana} {
 		info, err := node.keeperStore.ReadLatestNetworkInfo(r.Context(), c, time.Now())
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -192,7 +207,7 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 			"checkpoint": ckp,
 		}
 		switch c {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 			c, s, err := node.readChainAccountantBalance(r.Context(), int(c))
 			if err != nil {
 				common.RenderError(w, r, err)
@@ -211,12 +226,40 @@ func (node *Node) httpListChains(w http.ResponseWriter, r *http.Request, params
 				return
 			}
 			chain["sender"] = addr
+		case common.SafeChainSolana:
+			payer := node.solanaFeePayer().Public().(ed25519.PublicKey)
+			chain["sender"] = solana.PublicKey(payer).String()
 		}
 		cs = append(cs, chain)
 	}
 	common.RenderJSON(w, r, http.StatusOK, cs)
 }
 
+func (node *Node) httpEstimateChainTransaction(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	chain, valid := parseChainParam(params["id"])
+	if !valid {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "chain"})
+		return
+	}
+	var body struct {
+		Address string            `json:"address"`
+		AssetId string            `json:"asset_id"`
+		Outputs []*estimateOutput `json:"outputs"`
+		Inputs  []string          `json:"inputs"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	data, err := node.httpEstimateTransaction(r.Context(), chain, body.Address, body.AssetId, body.Outputs, body.Inputs)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, data)
+}
+
 func (node *Node) httpListSigners(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	node.httpListNodes(w, r, NodeTypeSigner)
 }
@@ -362,10 +405,86 @@ func (node *Node) httpGetAccount(w http.ResponseWriter, r *http.Request, params
 	node.renderAccount(r.Context(), w, r, safe)
 }
 
+func (node *Node) httpGetAccountStatements(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	query := r.URL.Query()
+	from, to, err := ParseStatementPeriod(query.Get("from"), query.Get("to"))
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+	statements, err := node.BuildStatements(r.Context(), params["id"], from, to)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if statements == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		common.RenderJSON(w, r, http.StatusOK, statements)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.WriteHeader(http.StatusOK)
+		err = WriteStatementsCSV(w, statements)
+		logger.Verbosef("WriteStatementsCSV(%s) => %v", params["id"], err)
+	default:
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": "format"})
+	}
+}
+
+func (node *Node) httpGetAccountTimelock(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	safe, _, err := node.readSafeProposalOrRequest(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if safe == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "safe"})
+		return
+	}
+	t, err := node.store.ReadSafeTimelock(r.Context(), safe.Address)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if t == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "timelock"})
+		return
+	}
+	view := t.view()
+	view["id"] = safe.RequestId
+	view["timelock"] = int64(safe.Timelock / time.Hour)
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
+// httpListTimelocks lists the safes could be recovered with the observer key
+// within the hours, 168 by default
+func (node *Node) httpListTimelocks(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	hours, _ := strconv.ParseInt(r.URL.Query().Get("hours"), 10, 64)
+	if hours <= 0 {
+		hours = 168
+	}
+	before := time.Now().Add(time.Duration(hours) * time.Hour)
+	tls, err := node.store.ListSafeTimelocksBefore(r.Context(), before, safeTimelockListLimit)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	view := make([]map[string]any, 0)
+	for _, t := range tls {
+		view = append(view, t.view())
+	}
+	common.RenderJSON(w, r, http.StatusOK, view)
+}
+
 func (node *Node) httpApproveAccount(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var body struct {
 		Action    string `json:"action"`
 		Address   string `json:"address"`
+		Nonce     string `json:"nonce"`
 		Signature string `json:"signature"`
 		Raw       string `json:"raw"`
 		Hash      string `json:"hash"`
@@ -411,6 +530,18 @@ func (node *Node) httpApproveAccount(w http.ResponseWriter, r *http.Request, par
 			common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
 			return
 		}
+	case "consolidate":
+		err = node.httpAuthorizeSafeConsolidation(r.Context(), body.Address, body.Nonce, body.Signature)
+		if err != nil {
+			common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+			return
+		}
+	case "refresh":
+		err = node.httpRefreshSafeTimelock(r.Context(), body.Address, body.Nonce, body.Signature)
+		if err != nil {
+			common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+			return
+		}
 	default:
 		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": "action"})
 		return
@@ -463,10 +594,19 @@ func (node *Node) httpGetTransaction(w http.ResponseWriter, r *http.Request, par
 		return
 	}
 	if req != nil && req.State == common.RequestStateFailed {
-		common.RenderJSON(w, r, http.StatusOK, map[string]any{
+		reason, err := node.keeperStore.ReadSafePolicyViolation(r.Context(), req.Id)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
+		data := map[string]any{
 			"id":    req.Id,
 			"state": common.StateName(int(req.State)),
-		})
+		}
+		if reason != "" {
+			data["reason"] = reason
+		}
+		common.RenderJSON(w, r, http.StatusOK, data)
 		return
 	}
 	if tx == nil {
@@ -511,6 +651,22 @@ func (node *Node) httpGetTransaction(w http.ResponseWriter, r *http.Request, par
 		"signers":         approval.Signers(r.Context(), node, safe),
 		"state":           common.StateName(tx.State),
 	}
+	outflow, err := node.keeperStore.ReadSafeOutflow(r.Context(), tx.TransactionHash)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if outflow != nil && outflow.UnlockAt.After(outflow.CreatedAt) {
+		data["unlock_at"] = outflow.UnlockAt
+	}
+	op, err := node.keeperStore.ReadLatestOperationParams(r.Context(), tx.Chain, tx.CreatedAt)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if op != nil && op.ProposalExpiry > 0 && tx.State == common.RequestStateInitial {
+		data["expire_at"] = tx.CreatedAt.Add(op.ProposalExpiry)
+	}
 	if approval.SpentRaw.Valid {
 		data["hash"] = approval.SpentHash.String
 		data["raw"] = approval.SpentRaw.String
@@ -519,6 +675,70 @@ func (node *Node) httpGetTransaction(w http.ResponseWriter, r *http.Request, par
 	common.RenderJSON(w, r, http.StatusOK, data)
 }
 
+func (node *Node) httpGetTransactionBatch(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	batch, err := node.store.ReadTransactionBatch(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if batch == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "batch"})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, batch.view())
+}
+
+func (node *Node) httpCreateBatch(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Chain        int                 `json:"chain"`
+		Transactions []*batchTransaction `json:"transactions"`
+		Signature    string              `json:"signature"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	batch, err := node.httpCreateTransactionBatch(r.Context(), byte(body.Chain), body.Transactions, body.Signature)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, batch.view())
+}
+
+func (node *Node) httpGetSafeMessage(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	m, err := node.store.ReadSafeMessage(r.Context(), params["id"])
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
+	if m == nil {
+		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "message"})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, m.view())
+}
+
+func (node *Node) httpCreateMessage(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var body struct {
+		Address   string `json:"address"`
+		Message   string `json:"message"`
+		Signature string `json:"signature"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusBadRequest, map[string]any{"error": err})
+		return
+	}
+	m, err := node.httpCreateSafeMessage(r.Context(), body.Address, body.Message, body.Signature)
+	if err != nil {
+		common.RenderJSON(w, r, http.StatusUnprocessableEntity, map[string]any{"error": err})
+		return
+	}
+	common.RenderJSON(w, r, http.StatusOK, m.view())
+}
+
 func (node *Node) httpApproveTransaction(w http.ResponseWriter, r *http.Request, params map[string]string) {
 	var body struct {
 		Chain     int    `json:"chain"`
@@ -640,7 +860,20 @@ func (node *Node) viewSafeXPubs(ctx context.Context, safe *store.SafeProposal) [
 	return pubs
 }
 
-func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, safe *store.SafeProposal) (*bitcoin.WitnessScriptAccount, error) {
+func viewSafeDescriptor(safe *store.SafeProposal, holders []string, threshold int, wsa *bitcoin.WitnessScriptAccount, xpubs []string) (string, error) {
+	path := decodeKeeperPath(safe.Path)
+	signer, err := bitcoin.ParseDescriptorKey(xpubs[0], path)
+	if err != nil {
+		return "", err
+	}
+	observer, err := bitcoin.ParseDescriptorKey(xpubs[1], path)
+	if err != nil {
+		return "", err
+	}
+	return bitcoin.BuildWitnessScriptDescriptorWithHolders(holders, threshold, signer, observer, wsa.Sequence)
+}
+
+func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context, safe *store.SafeProposal, holders []string, threshold int) (*bitcoin.WitnessScriptAccount, error) {
 	sdk, err := node.deriveBIP32WithKeeperPath(ctx, safe.Signer, safe.Path)
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", safe.Signer, err)
@@ -649,7 +882,7 @@ func (node *Node) buildBitcoinWitnessAccountWithDerivation(ctx context.Context,
 	if err != nil {
 		return nil, fmt.Errorf("bitcoin.DeriveBIP32(%s) => %v", safe.Observer, err)
 	}
-	return bitcoin.BuildWitnessScriptAccount(safe.Holder, sdk, odk, safe.Timelock, safe.Chain)
+	return bitcoin.BuildWitnessScriptAccountWithHolders(holders, threshold, sdk, odk, safe.Timelock, safe.Chain)
 }
 
 func (node *Node) readChainAccountantBalance(ctx context.Context, chain int) (uint64, uint64, error) {
@@ -718,7 +951,7 @@ func (node *Node) viewDeposits(ctx context.Context, deposits []*Deposit, sent ma
 			dm["sent_hash"] = d.TransactionHash
 		} else {
 			switch d.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 				dm["change"] = node.bitcoinCheckDepositChange(ctx, d.TransactionHash, d.OutputIndex, sent[d.TransactionHash])
 			}
 		}
@@ -760,6 +993,31 @@ func viewOutputs(outputs []*bitcoin.Input) []map[string]any {
 	return view
 }
 
+func viewUnconfirmedDeposits(deposits []*UnconfirmedDeposit) []map[string]any {
+	states := map[int]string{
+		UnconfirmedDepositStatePending:   "pending",
+		UnconfirmedDepositStateConfirmed: "confirmed",
+		UnconfirmedDepositStateReplaced:  "replaced",
+		UnconfirmedDepositStateEvicted:   "evicted",
+	}
+	view := make([]map[string]any, 0)
+	for _, d := range deposits {
+		view = append(view, map[string]any{
+			"transaction_hash": d.TransactionHash,
+			"output_index":     d.OutputIndex,
+			"asset_id":         d.AssetId,
+			"amount":           d.Amount,
+			"fee_rate":         d.FeeRate,
+			"state":            states[d.State],
+			"replaced_by":      d.ReplacedBy,
+			"expected_at":      d.ExpectedAt,
+			"created_at":       d.CreatedAt,
+			"updated_at":       d.UpdatedAt,
+		})
+	}
+	return view
+}
+
 type AssetBalance struct {
 	AssetAddress string `json:"asset_address"`
 	Amount       string `json:"amount"`
@@ -790,16 +1048,8 @@ func viewBalances(bs []*store.SafeBalance, txs []*store.Transaction) (map[string
 func viewPendingBalances(txs []*store.Transaction) map[string]*AssetBalance {
 	assetBalance := make(map[string]*AssetBalance)
 	for _, tx := range txs {
-		raw := common.DecodeHexOrPanic(tx.RawTransaction)
-		st, _ := ethereum.UnmarshalSafeTransaction(raw)
-		chainAssetId := ethereum.GetMixinChainID(int64(tx.Chain))
-
-		outputs := st.ExtractOutputs()
-		for _, out := range outputs {
-			assetId := chainAssetId
-			if out.TokenAddress != ethereum.EthereumEmptyAddress {
-				assetId = ethereum.GenerateAssetId(tx.Chain, out.TokenAddress)
-			}
+		for _, out := range viewPendingOutputs(tx) {
+			assetId := out.AssetId
 			amount := out.Amount
 			if amount.Cmp(big.NewInt(0)) < 1 {
 				continue
@@ -820,6 +1070,39 @@ func viewPendingBalances(txs []*store.Transaction) map[string]*AssetBalance {
 	return assetBalance
 }
 
+type pendingOutput struct {
+	AssetId      string
+	TokenAddress string
+	Amount       *big.Int
+}
+
+func viewPendingOutputs(tx *store.Transaction) []*pendingOutput {
+	var outputs []*pendingOutput
+	raw := common.DecodeHexOrPanic(tx.RawTransaction)
+	switch tx.Chain {
+	case common.SafeChainSolana:
+		st, _ := solana.UnmarshalSafeTransaction(raw)
+		for _, out := range st.ExtractOutputs() {
+			assetId := solana.GetMixinChainID()
+			if out.TokenAddress != solana.SolanaEmptyAddress {
+				assetId = solana.GenerateAssetId(out.TokenAddress)
+			}
+			outputs = append(outputs, &pendingOutput{assetId, out.TokenAddress, out.Amount})
+		}
+	default:
+		st, _ := ethereum.UnmarshalSafeTransaction(raw)
+		chainAssetId := ethereum.GetMixinChainID(int64(tx.Chain))
+		for _, out := range st.ExtractOutputs() {
+			assetId := chainAssetId
+			if out.TokenAddress != ethereum.EthereumEmptyAddress {
+				assetId = ethereum.GenerateAssetId(tx.Chain, out.TokenAddress)
+			}
+			outputs = append(outputs, &pendingOutput{assetId, out.TokenAddress, out.Amount})
+		}
+	}
+	return outputs
+}
+
 func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *http.Request, sp *store.SafeProposal) {
 	status, err := node.getSafeStatus(ctx, sp.RequestId)
 	if err != nil {
@@ -831,13 +1114,23 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 		common.RenderError(w, r, err)
 		return
 	}
+	holders, threshold, err := node.keeperStore.ReadSafeHolderKeys(r.Context(), sp.Address, sp.Holder)
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
 	safeAssetId := ""
 	if safe != nil {
 		safeAssetId = safe.SafeAssetId
 	}
+	unconfirmed, err := node.store.ListUnconfirmedDepositsForReceiver(ctx, sp.Address, time.Now().Add(-unconfirmedDepositRecentDuration))
+	if err != nil {
+		common.RenderError(w, r, err)
+		return
+	}
 	switch sp.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
-		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(r.Context(), sp)
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(r.Context(), sp, holders, threshold)
 		if err != nil {
 			common.RenderError(w, r, err)
 			return
@@ -856,18 +1149,29 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 			common.RenderError(w, r, err)
 			return
 		}
+		keys := node.viewSafeXPubs(r.Context(), sp)
+		descriptor, err := viewSafeDescriptor(sp, holders, threshold, wsa, keys)
+		if err != nil {
+			common.RenderError(w, r, err)
+			return
+		}
 		common.RenderJSON(w, r, http.StatusOK, map[string]any{
-			"chain":         sp.Chain,
-			"id":            sp.RequestId,
-			"address":       sp.Address,
-			"outputs":       viewOutputs(mainInputs),
-			"pendings":      viewOutputs(pendings),
-			"script":        hex.EncodeToString(wsa.Script),
-			"keys":          node.viewSafeXPubs(r.Context(), sp),
-			"safe_asset_id": safeAssetId,
-			"state":         status,
+			"chain":            sp.Chain,
+			"id":               sp.RequestId,
+			"address":          sp.Address,
+			"outputs":          viewOutputs(mainInputs),
+			"pendings":         viewOutputs(pendings),
+			"unconfirmed":      viewUnconfirmedDeposits(unconfirmed),
+			"script":           hex.EncodeToString(wsa.Script),
+			"descriptor":       descriptor,
+			"keys":             keys,
+			"holders":          holders,
+			"holder_threshold": threshold,
+			"timelock":         int64(sp.Timelock / time.Hour),
+			"safe_asset_id":    safeAssetId,
+			"state":            status,
 		})
-	case common.SafeChainPolygon, common.SafeChainEthereum:
+	case common.SafeChainPolygon, common.SafeChainEthereum, common.SafeChainSolana:
 		balances, err := node.keeperStore.ReadAllEthereumTokenBalances(r.Context(), sp.Address)
 		if err != nil {
 			common.RenderError(w, r, err)
@@ -884,15 +1188,19 @@ func (node *Node) renderAccount(ctx context.Context, w http.ResponseWriter, r *h
 		}
 		bs, ps := viewBalances(balances, pendings)
 		common.RenderJSON(w, r, http.StatusOK, map[string]any{
-			"chain":          sp.Chain,
-			"id":             sp.RequestId,
-			"address":        sp.Address,
-			"balances":       bs,
-			"pendingbalance": ps,
-			"nonce":          nonce,
-			"keys":           node.viewSafeXPubs(r.Context(), sp),
-			"safe_asset_id":  safeAssetId,
-			"state":          status,
+			"chain":            sp.Chain,
+			"id":               sp.RequestId,
+			"address":          sp.Address,
+			"balances":         bs,
+			"pendingbalance":   ps,
+			"unconfirmed":      viewUnconfirmedDeposits(unconfirmed),
+			"nonce":            nonce,
+			"keys":             node.viewSafeXPubs(r.Context(), sp),
+			"holders":          holders,
+			"holder_threshold": threshold,
+			"timelock":         int64(sp.Timelock / time.Hour),
+			"safe_asset_id":    safeAssetId,
+			"state":            status,
 		})
 	default:
 		common.RenderJSON(w, r, http.StatusNotFound, map[string]any{"error": "chain"})
@@ -1,35 +1,52 @@
 package observer
 
 import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
 	"fmt"
+	"time"
 
+	mc "github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/fox-one/mixin-sdk-go/v2"
 	"github.com/shopspring/decimal"
 )
 
 type Configuration struct {
-	KeeperAppId                 string `toml:"keeper-app-id"`
-	StoreDir                    string `toml:"store-dir"`
-	PrivateKey                  string `toml:"private-key"`
-	Timestamp                   int64  `toml:"timestamp"`
-	KeeperStoreDir              string `toml:"keeper-store-dir"`
-	MonitorConversaionId        string `toml:"monitor-conversation-id"`
-	KeeperPublicKey             string `toml:"keeper-public-key"`
-	AssetId                     string `toml:"asset-id"`
-	CustomKeyPriceAssetId       string `toml:"custom-key-price-asset-id"`
-	CustomKeyPriceAmount        string `toml:"custom-key-price-amount"`
-	OperationPriceAssetId       string `toml:"operation-price-asset-id"`
-	OperationPriceAmount        string `toml:"operation-price-amount"`
-	TransactionMinimum          string `toml:"transaction-minimum"`
-	MixinMessengerAPI           string `toml:"mixin-messenger-api"`
-	MixinRPC                    string `toml:"mixin-rpc"`
-	BitcoinRPC                  string `toml:"bitcoin-rpc"`
-	LitecoinRPC                 string `toml:"litecoin-rpc"`
-	EthereumRPC                 string `toml:"ethereum-rpc"`
-	PolygonRPC                  string `toml:"polygon-rpc"`
-	PolygonFactoryAddress       string `toml:"polygon-factory-address"`
-	PolygonObserverDepositEntry string `toml:"polygon-observer-deposit-entry"`
-	PolygonKeeperDepositEntry   string `toml:"polygon-keeper-deposit-entry"`
-	EVMKey                      string `toml:"evm-key"`
+	KeeperAppId                 string  `toml:"keeper-app-id"`
+	StoreDir                    string  `toml:"store-dir"`
+	PrivateKey                  string  `toml:"private-key"`
+	Timestamp                   int64   `toml:"timestamp"`
+	KeeperStoreDir              string  `toml:"keeper-store-dir"`
+	LeaseHolderId               string  `toml:"lease-holder-id"`
+	MonitorConversaionId        string  `toml:"monitor-conversation-id"`
+	KeeperPublicKey             string  `toml:"keeper-public-key"`
+	AssetId                     string  `toml:"asset-id"`
+	CustomKeyPriceAssetId       string  `toml:"custom-key-price-asset-id"`
+	CustomKeyPriceAmount        string  `toml:"custom-key-price-amount"`
+	OperationPriceAssetId       string  `toml:"operation-price-asset-id"`
+	OperationPriceAmount        string  `toml:"operation-price-amount"`
+	TransactionMinimum          string  `toml:"transaction-minimum"`
+	ProposalExpiryHours         int64   `toml:"proposal-expiry-hours"`
+	MixinMessengerAPI           string  `toml:"mixin-messenger-api"`
+	MixinRPC                    string  `toml:"mixin-rpc"`
+	BitcoinRPC                  string  `toml:"bitcoin-rpc"`
+	LitecoinRPC                 string  `toml:"litecoin-rpc"`
+	DogecoinRPC                 string  `toml:"dogecoin-rpc"`
+	EthereumRPC                 string  `toml:"ethereum-rpc"`
+	PolygonRPC                  string  `toml:"polygon-rpc"`
+	PolygonFactoryAddress       string  `toml:"polygon-factory-address"`
+	PolygonObserverDepositEntry string  `toml:"polygon-observer-deposit-entry"`
+	PolygonKeeperDepositEntry   string  `toml:"polygon-keeper-deposit-entry"`
+	EVMKey                      string  `toml:"evm-key"`
+	SolanaRPC                   string  `toml:"solana-rpc"`
+	SolanaProgram               string  `toml:"solana-program"`
+	SolanaKey                   string  `toml:"solana-key"`
+	SolanaNonceAccount          string  `toml:"solana-nonce-account"`
+	TimelockAlertHours          []int64 `toml:"timelock-alert-hours"`
 	App                         struct {
 		AppId             string `toml:"app-id"`
 		SessionId         string `toml:"session-id"`
@@ -49,5 +66,35 @@ func (c *Configuration) Validate() error {
 	if decimal.RequireFromString(c.TransactionMinimum).Sign() <= 0 {
 		return fmt.Errorf("Configuration.Validate(transaction) minimum %s", c.TransactionMinimum)
 	}
+	expiry := time.Duration(c.ProposalExpiryHours) * time.Hour
+	if expiry != 0 && (expiry < keeper.SafeProposalExpiryMinimum || expiry > keeper.SafeProposalExpiryMaximum) {
+		return fmt.Errorf("Configuration.Validate(transaction) expiry %d", c.ProposalExpiryHours)
+	}
+	if c.SolanaRPC != "" {
+		seed, err := hex.DecodeString(c.SolanaKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return fmt.Errorf("Configuration.Validate(solana) key")
+		}
+		_, err = solana.PublicKeyFromBase58(c.SolanaNonceAccount)
+		if err != nil {
+			return fmt.Errorf("Configuration.Validate(solana) nonce account %s", c.SolanaNonceAccount)
+		}
+	}
+	for _, h := range c.TimelockAlertHours {
+		if h <= 0 {
+			return fmt.Errorf("Configuration.Validate(timelock) alert %d", h)
+		}
+	}
 	return nil
 }
+
+// TestNetwork replaces the Mixin API used by the observer in the test
+// environment, the snapshots are all the transactions received by the
+// observer, and the transaction extra is the hex of the storage extra
+type TestNetwork interface {
+	ReadSafeSnapshots(ctx context.Context, offset time.Time, limit int) ([]*mixin.SafeSnapshot, error)
+	ReadTransactionExtra(ctx context.Context, traceId string) (string, error)
+	WriteStorage(ctx context.Context, extra []byte, traceId string) (crypto.Hash, error)
+	SendTransaction(ctx context.Context, assetId string, receivers []string, threshold int, amount decimal.Decimal, memo, traceId string, references []crypto.Hash) error
+	AssetBalance(ctx context.Context, members []string, threshold int, assetId string) (*mc.Integer, error)
+}
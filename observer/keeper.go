@@ -14,6 +14,16 @@ import (
 )
 
 func (node *Node) deriveBIP32WithKeeperPath(ctx context.Context, public, path string) (string, error) {
+	path32 := decodeKeeperPath(path)
+	sk, err := node.keeperStore.ReadKey(ctx, public)
+	if err != nil {
+		return "", fmt.Errorf("keeperStore.ReadKey(%s) => %v", public, err)
+	}
+	_, sdk, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
+	return sdk, err
+}
+
+func decodeKeeperPath(path string) []uint32 {
 	path8 := common.DecodeHexOrPanic(path)
 	if path8[0] > 3 {
 		panic(path8[0])
@@ -22,12 +32,7 @@ func (node *Node) deriveBIP32WithKeeperPath(ctx context.Context, public, path st
 	for i := 0; i < int(path8[0]); i++ {
 		path32[i] = uint32(path8[1+i])
 	}
-	sk, err := node.keeperStore.ReadKey(ctx, public)
-	if err != nil {
-		return "", fmt.Errorf("keeperStore.ReadKey(%s) => %v", public, err)
-	}
-	_, sdk, err := bitcoin.DeriveBIP32(public, common.DecodeHexOrPanic(sk.Extra), path32...)
-	return sdk, err
+	return path32
 }
 
 func (node *Node) checkTrustedSender(ctx context.Context, address string) (bool, error) {
@@ -55,10 +60,14 @@ func (node *Node) sendKeeperResponseWithReferences(ctx context.Context, holder s
 	case common.SafeChainBitcoin:
 	case common.SafeChainLitecoin:
 		crv = common.CurveSecp256k1ECDSALitecoin
+	case common.SafeChainDogecoin:
+		crv = common.CurveSecp256k1ECDSADogecoin
 	case common.SafeChainEthereum:
 		crv = common.CurveSecp256k1ECDSAEthereum
 	case common.SafeChainPolygon:
 		crv = common.CurveSecp256k1ECDSAPolygon
+	case common.SafeChainSolana:
+		crv = common.CurveEdwards25519Solana
 	default:
 		panic(chain)
 	}
@@ -92,6 +101,20 @@ func (node *Node) sendKeeperTransactionWithReferences(ctx context.Context, op *c
 
 func (node *Node) sendTransactionUntilSufficient(ctx context.Context, assetId string, receivers []string, threshold int, amount decimal.Decimal, memo, traceId string, references []crypto.Hash) error {
 	logger.Printf("node.sendTransactionUntilSufficient(%s, %v, %d, %s, %s, %s, %v)", assetId, receivers, threshold, amount, memo, traceId, references)
-	_, err := common.SendTransactionUntilSufficient(ctx, node.mixin, []string{node.conf.App.AppId}, 1, receivers, threshold, amount, traceId, assetId, memo, node.conf.App.SpendPrivateKey)
+	err := node.checkLeader()
+	if err != nil {
+		return err
+	}
+	if common.CheckTestEnvironment(ctx) {
+		return node.network.SendTransaction(ctx, assetId, receivers, threshold, amount, memo, traceId, references)
+	}
+	_, err = common.SendTransactionUntilSufficient(ctx, node.mixin, []string{node.conf.App.AppId}, 1, receivers, threshold, amount, traceId, assetId, memo, node.conf.App.SpendPrivateKey)
 	return err
 }
+
+func (node *Node) writeStorageUntilSufficient(ctx context.Context, extra []byte, traceId string) (crypto.Hash, error) {
+	if common.CheckTestEnvironment(ctx) {
+		return node.network.WriteStorage(ctx, extra, traceId)
+	}
+	return common.WriteStorageUntilSufficient(ctx, node.mixin, extra, traceId, node.safeUser())
+}
@@ -31,6 +31,8 @@ func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
 		crv = common.CurveSecp256k1ECDSABitcoin
 	case common.SafeChainEthereum:
 		crv = common.CurveSecp256k1ECDSAEthereum
+	case common.SafeChainSolana:
+		crv = common.CurveEdwards25519Default
 	}
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleObserver)
 	if err != nil {
@@ -60,7 +62,16 @@ func (node *Node) safeAddObserverKeys(ctx context.Context, chain byte) error {
 		id := common.UniqueId(observer, observer)
 		extra := append([]byte{common.RequestRoleObserver}, chainCode...)
 		extra = append(extra, common.RequestFlagNone)
-		err = node.sendKeeperResponse(ctx, observer, common.ActionObserverAddKey, chain, id, extra)
+		// the keeper stores keys with the normalized curve, so the key add
+		// request can't use the chain curve like other responses
+		op := &common.Operation{
+			Id:     id,
+			Type:   common.ActionObserverAddKey,
+			Curve:  crv,
+			Public: observer,
+			Extra:  extra,
+		}
+		err = node.sendKeeperTransactionWithReferences(ctx, op, nil)
 		if err != nil {
 			return err
 		}
@@ -76,6 +87,8 @@ func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
 		crv = common.CurveSecp256k1ECDSABitcoin
 	case common.SafeChainEthereum:
 		crv = common.CurveSecp256k1ECDSAEthereum
+	case common.SafeChainSolana:
+		crv = common.CurveEdwards25519Default
 	}
 	count, err := node.keeperStore.CountSpareKeys(ctx, crv, common.RequestFlagNone, common.RequestRoleSigner)
 	if err != nil || count > 1000 {
@@ -86,6 +99,9 @@ func (node *Node) safeRequestSignerKeys(ctx context.Context, chain byte) error {
 		return err
 	}
 	dummy := node.bitcoinDummyHolder()
+	if chain == common.SafeChainSolana {
+		dummy = node.solanaDummyHolder()
+	}
 	id := common.UniqueId(requested.String(), requested.String())
 	keysCount := []byte{16}
 	err = node.sendKeeperResponse(ctx, dummy, common.ActionObserverRequestSignerKeys, chain, id, keysCount)
@@ -121,6 +137,8 @@ func (node *Node) chainKeygenRequestTimeKey(chain byte) (string, error) {
 		return bitcoinKeygenRequestTimeKey, nil
 	case common.SafeChainEthereum:
 		return ethereumKeygenRequestTimeKey, nil
+	case common.SafeChainSolana:
+		return solanaKeygenRequestTimeKey, nil
 	default:
 		return "", fmt.Errorf("invalid keygen request chain")
 	}
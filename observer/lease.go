@@ -0,0 +1,117 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+)
+
+const (
+	observerLeaseName     = "observer"
+	observerLeaseDuration = time.Minute
+)
+
+// leaderLease lets several observers share the same store in active/standby
+// mode, only the holder of the lease runs the loops which send transactions
+// to the keeper, broadcast chain transactions or deploy contracts. The lease
+// is a row of the SQLite store, so it only coordinates the observer processes
+// on the same host, and never the ones with the store on a network filesystem.
+type leaderLease struct {
+	store    *SQLite3Store
+	name     string
+	holder   string
+	duration time.Duration
+	expiry   atomic.Int64
+}
+
+func newLeaderLease(store *SQLite3Store, holder string, duration time.Duration) *leaderLease {
+	return &leaderLease{
+		store:    store,
+		name:     observerLeaseName,
+		holder:   holder,
+		duration: duration,
+	}
+}
+
+// the local expiry is counted from the time before the acquisition, so the
+// lease is always considered lost here no later than in the store
+func (l *leaderLease) acquire(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	acquired, err := l.store.AcquireLease(ctx, l.name, l.holder, now, l.duration)
+	if err != nil {
+		return false, err
+	}
+	if acquired {
+		l.expiry.Store(now.Add(l.duration).UnixNano())
+	} else {
+		l.expiry.Store(0)
+	}
+	return acquired, nil
+}
+
+func (l *leaderLease) release(ctx context.Context) error {
+	l.expiry.Store(0)
+	return l.store.ReleaseLease(ctx, l.name, l.holder)
+}
+
+func (l *leaderLease) held() bool {
+	return time.Now().UnixNano() < l.expiry.Load()
+}
+
+func (node *Node) IsLeader() bool {
+	return node.lease == nil || node.lease.held()
+}
+
+func (node *Node) checkLeader() error {
+	if node.IsLeader() {
+		return nil
+	}
+	return fmt.Errorf("observer %s is not the lease holder", node.lease.holder)
+}
+
+func (node *Node) waitLeaderLease(ctx context.Context) {
+	if node.lease == nil {
+		return
+	}
+	for {
+		acquired, err := node.lease.acquire(ctx)
+		logger.Printf("node.waitLeaderLease(%s) => %t %v", node.lease.holder, acquired, err)
+		if acquired {
+			return
+		}
+		time.Sleep(node.lease.duration / 3)
+	}
+}
+
+// the loops started after the acquisition have no way to stop by themselves,
+// so the boot context is cancelled once the lease is lost, and the node steps
+// down with the error to be restarted as a standby
+func (node *Node) leaderLeaseLoop(ctx context.Context, cancel context.CancelCauseFunc) {
+	if node.lease == nil {
+		return
+	}
+	for {
+		time.Sleep(node.lease.duration / 3)
+		acquired, err := node.lease.acquire(ctx)
+		logger.Verbosef("node.leaderLeaseLoop(%s) => %t %v", node.lease.holder, acquired, err)
+		if !node.lease.held() {
+			cancel(fmt.Errorf("observer %s lease lost %v", node.lease.holder, err))
+			return
+		}
+	}
+}
+
+func (node *Node) handleStandby(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && !node.IsLeader() {
+			common.RenderJSON(w, r, http.StatusServiceUnavailable, map[string]any{"error": "standby"})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,117 @@
+package observer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverLease(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	path := t.TempDir() + "/safe.sqlite3"
+	nodes := make([]*Node, 2)
+	for i, holder := range []string{"observer-a", "observer-b"} {
+		db, err := OpenSQLite3Store(path)
+		require.Nil(err)
+		defer db.Close()
+		nodes[i] = &Node{store: db, lease: newLeaderLease(db, holder, time.Second)}
+	}
+	active, standby := nodes[0], nodes[1]
+	require.False(active.IsLeader())
+	require.False(standby.IsLeader())
+
+	acquired, err := active.lease.acquire(ctx)
+	require.Nil(err)
+	require.True(acquired)
+	require.True(active.IsLeader())
+	acquired, err = standby.lease.acquire(ctx)
+	require.Nil(err)
+	require.False(acquired)
+	require.False(standby.IsLeader())
+	require.NotNil(standby.checkLeader())
+	require.Nil(active.checkLeader())
+
+	lease, err := standby.store.ReadLease(ctx, observerLeaseName)
+	require.Nil(err)
+	require.Equal("observer-a", lease.Holder)
+
+	require.Equal(http.StatusServiceUnavailable, testObserverLeasePost(standby))
+	require.Equal(http.StatusBadRequest, testObserverLeasePost(active))
+
+	time.Sleep(time.Second / 2)
+	acquired, err = active.lease.acquire(ctx)
+	require.Nil(err)
+	require.True(acquired)
+	time.Sleep(time.Second / 2)
+	acquired, err = standby.lease.acquire(ctx)
+	require.Nil(err)
+	require.False(acquired)
+
+	time.Sleep(time.Second)
+	require.False(active.IsLeader())
+	acquired, err = standby.lease.acquire(ctx)
+	require.Nil(err)
+	require.True(acquired)
+	require.True(standby.IsLeader())
+	acquired, err = active.lease.acquire(ctx)
+	require.Nil(err)
+	require.False(acquired)
+	require.Equal(http.StatusServiceUnavailable, testObserverLeasePost(active))
+	require.Equal(http.StatusBadRequest, testObserverLeasePost(standby))
+
+	err = standby.lease.release(ctx)
+	require.Nil(err)
+	require.False(standby.IsLeader())
+	acquired, err = active.lease.acquire(ctx)
+	require.Nil(err)
+	require.True(acquired)
+	lease, err = standby.store.ReadLease(ctx, observerLeaseName)
+	require.Nil(err)
+	require.Equal("observer-a", lease.Holder)
+}
+
+func TestObserverLeaseLost(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	path := t.TempDir() + "/safe.sqlite3"
+	db, err := OpenSQLite3Store(path)
+	require.Nil(err)
+	defer db.Close()
+	active := &Node{store: db, lease: newLeaderLease(db, "observer-a", time.Second)}
+	standby := &Node{store: db, lease: newLeaderLease(db, "observer-b", time.Second)}
+	acquired, err := active.lease.acquire(ctx)
+	require.Nil(err)
+	require.True(acquired)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	go active.leaderLeaseLoop(ctx, cancel)
+	time.Sleep(time.Second / 2)
+	require.Nil(ctx.Err())
+	require.True(active.IsLeader())
+
+	err = db.ReleaseLease(context.Background(), observerLeaseName, "observer-a")
+	require.Nil(err)
+	acquired, err = standby.lease.acquire(context.Background())
+	require.Nil(err)
+	require.True(acquired)
+	<-ctx.Done()
+	require.ErrorContains(context.Cause(ctx), "observer observer-a lease lost")
+	require.False(active.IsLeader())
+	require.True(standby.IsLeader())
+}
+
+func testObserverLeasePost(node *Node) int {
+	handler := node.HTTPHandler("test", "")
+	r := httptest.NewRequest(http.MethodPost, "/accounts/test", strings.NewReader(""))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
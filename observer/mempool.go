@@ -0,0 +1,376 @@
+package observer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/shopspring/decimal"
+)
+
+// the unconfirmed deposits are only shown to the safe holders, the keeper
+// still credits the deposits after the blocks loop with finalization delay
+const unconfirmedDepositRecentDuration = 24 * time.Hour
+
+// bitcoinMempoolLoop records the mempool payments to the safe addresses, and
+// tracks whether they are confirmed, replaced by fee or evicted
+func (node *Node) bitcoinMempoolLoop(ctx context.Context, chain byte) {
+	rpc, _ := node.bitcoinParams(chain)
+	// the outpoints spent by each seen transaction in the mempool
+	seen := make(map[string][]string)
+
+	for {
+		time.Sleep(time.Minute)
+		pool, err := bitcoin.RPCGetRawMempoolWithTransactions(rpc)
+		if err != nil {
+			logger.Printf("bitcoin.RPCGetRawMempoolWithTransactions(%d) => %v", chain, err)
+			continue
+		}
+
+		current := make(map[string]bool, len(pool))
+		for _, entry := range pool {
+			current[entry.TxId] = true
+			if seen[entry.TxId] != nil {
+				continue
+			}
+			tx, err := bitcoin.RPCGetTransaction(chain, rpc, entry.TxId)
+			if err != nil {
+				logger.Verbosef("bitcoin.RPCGetTransaction(%s) => %v", entry.TxId, err)
+				continue
+			}
+			spent := bitcoinSpentOutpoints(tx)
+			seen[entry.TxId] = spent
+			feeRate := bitcoinMempoolFeeRate(entry)
+			err = node.bitcoinWriteUnconfirmedDeposits(ctx, tx, spent, feeRate, chain)
+			if err != nil {
+				panic(err)
+			}
+		}
+		spenders := make(map[string]string)
+		for id, spent := range seen {
+			if !current[id] {
+				delete(seen, id)
+				continue
+			}
+			for _, op := range spent {
+				spenders[op] = id
+			}
+		}
+
+		err = node.bitcoinFinishUnconfirmedDeposits(ctx, chain, current, spenders)
+		if err != nil {
+			logger.Printf("node.bitcoinFinishUnconfirmedDeposits(%d) => %v", chain, err)
+		}
+	}
+}
+
+func (node *Node) bitcoinWriteUnconfirmedDeposits(ctx context.Context, tx *bitcoin.RPCTransaction, spent []string, feeRate int64, chain byte) error {
+	_, assetId := node.bitcoinParams(chain)
+	// the change outputs of the safe transactions are not incoming payments
+	old, err := node.keeperStore.ReadTransaction(ctx, tx.TxId)
+	if err != nil || old != nil {
+		return err
+	}
+	for _, out := range tx.Vout {
+		if !bitcoin.IsSafeScriptPubKeyType(out.ScriptPubKey.Type, chain) {
+			continue
+		}
+		safe, err := node.keeperStore.ReadSafeByAddress(ctx, out.ScriptPubKey.Address)
+		if err != nil {
+			return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v", out.ScriptPubKey.Address, err)
+		} else if safe == nil {
+			continue
+		}
+		amount := decimal.NewFromFloat(out.Value)
+		err = node.writeUnconfirmedDeposit(ctx, tx.TxId, out.N, chain, assetId, amount, safe.Address, safe.Holder, spent, feeRate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *Node) bitcoinFinishUnconfirmedDeposits(ctx context.Context, chain byte, current map[string]bool, spenders map[string]string) error {
+	rpc, _ := node.bitcoinParams(chain)
+	deposits, err := node.store.ListPendingUnconfirmedDeposits(ctx, chain)
+	if err != nil {
+		return err
+	}
+	for _, d := range deposits {
+		if current[d.TransactionHash] {
+			continue
+		}
+		tx, err := bitcoin.RPCGetTransaction(chain, rpc, d.TransactionHash)
+		if err == nil && tx.BlockHash == "" {
+			continue
+		}
+		if err != nil && !strings.Contains(strings.ToLower(err.Error()), "no such") {
+			return err
+		}
+		state, replacedBy := UnconfirmedDepositStateConfirmed, ""
+		if err != nil {
+			state = UnconfirmedDepositStateEvicted
+			for _, op := range d.Spent {
+				if id := spenders[op]; id != "" && id != d.TransactionHash {
+					state, replacedBy = UnconfirmedDepositStateReplaced, id
+					break
+				}
+			}
+		}
+		err = node.store.FinishUnconfirmedDeposits(ctx, d.TransactionHash, state, replacedBy)
+		logger.Printf("store.FinishUnconfirmedDeposits(%s, %d, %s) => %v", d.TransactionHash, state, replacedBy, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bitcoinSpentOutpoints(tx *bitcoin.RPCTransaction) []string {
+	spent := make([]string, 0)
+	for _, in := range tx.Vin {
+		if in.Coinbase != "" {
+			continue
+		}
+		spent = append(spent, fmt.Sprintf("%s:%d", in.TxId, in.VOUT))
+	}
+	return spent
+}
+
+// bitcoinMempoolFeeRate returns the fee rate in satoshis per virtual byte
+func bitcoinMempoolFeeRate(entry *bitcoin.RPCTransaction) int64 {
+	if entry.VSize <= 0 {
+		return 0
+	}
+	fee := bitcoin.ParseSatoshi(decimal.NewFromFloat(entry.Fee).String())
+	return fee / entry.VSize
+}
+
+// ethereumMempoolLoop records the pending transactions paying the safe
+// addresses, either ether or ERC20 transfer calls, and tracks whether they are
+// confirmed, replaced with the same nonce or dropped
+func (node *Node) ethereumMempoolLoop(ctx context.Context, chain byte) {
+	rpc, _ := node.ethereumParams(chain)
+	seen := make(map[string]bool)
+
+	for {
+		time.Sleep(chainBlockDuration(chain))
+		txs, err := ethereum.RPCGetPendingTransactions(rpc)
+		if err != nil {
+			logger.Printf("ethereum.RPCGetPendingTransactions(%d) => %v", chain, err)
+			continue
+		}
+
+		current := make(map[string]bool, len(txs))
+		nonces := make(map[string]string, len(txs))
+		for _, tx := range txs {
+			current[tx.Hash] = true
+			nonces[ethereumTransactionNonceKey(tx)] = tx.Hash
+			if seen[tx.Hash] {
+				continue
+			}
+			seen[tx.Hash] = true
+			err = node.ethereumWriteUnconfirmedDeposits(ctx, tx, chain)
+			if err != nil {
+				panic(err)
+			}
+		}
+		for id := range seen {
+			if !current[id] {
+				delete(seen, id)
+			}
+		}
+
+		err = node.ethereumFinishUnconfirmedDeposits(ctx, chain, current, nonces)
+		if err != nil {
+			logger.Printf("node.ethereumFinishUnconfirmedDeposits(%d) => %v", chain, err)
+		}
+	}
+}
+
+func (node *Node) ethereumWriteUnconfirmedDeposits(ctx context.Context, tx *ethereum.RPCTransaction, chain byte) error {
+	_, chainAssetId := node.ethereumParams(chain)
+	if tx.To == "" {
+		return nil
+	}
+	feeRate, _ := new(big.Int).SetString(tx.GasPrice, 0)
+	if feeRate == nil || !feeRate.IsInt64() {
+		feeRate = big.NewInt(0)
+	}
+	spent := []string{ethereumTransactionNonceKey(tx)}
+
+	value, _ := new(big.Int).SetString(tx.Value, 0)
+	if value != nil && value.Sign() > 0 {
+		receiver := ethereum.NormalizeAddress(tx.To)
+		amount := decimal.NewFromBigInt(value, -ethereum.ValuePrecision)
+		err := node.ethereumWriteUnconfirmedDeposit(ctx, tx.Hash, 0, chain, chainAssetId, amount, receiver, spent, feeRate.Int64())
+		if err != nil {
+			return err
+		}
+	}
+
+	// erc20 transfer(address,uint256)
+	input := strings.ToLower(tx.Input)
+	if !strings.HasPrefix(input, "0xa9059cbb") || len(input) != 138 {
+		return nil
+	}
+	receiver := ethereum.NormalizeAddress("0x" + input[34:74])
+	value, _ = new(big.Int).SetString(input[74:], 16)
+	if value == nil || value.Sign() <= 0 {
+		return nil
+	}
+	token := ethereum.NormalizeAddress(tx.To)
+	assetId := ethereum.GenerateAssetId(chain, token)
+	asset, err := node.keeperStore.ReadAssetMeta(ctx, assetId)
+	if err != nil || asset == nil {
+		return err
+	}
+	amount := decimal.NewFromBigInt(value, -int32(asset.Decimals))
+	return node.ethereumWriteUnconfirmedDeposit(ctx, tx.Hash, 1, chain, assetId, amount, receiver, spent, feeRate.Int64())
+}
+
+func (node *Node) ethereumWriteUnconfirmedDeposit(ctx context.Context, hash string, index int64, chain byte, assetId string, amount decimal.Decimal, receiver string, spent []string, feeRate int64) error {
+	if receiver == ethereum.EthereumEmptyAddress {
+		return nil
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, receiver)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v", receiver, err)
+	} else if safe == nil {
+		return nil
+	}
+	return node.writeUnconfirmedDeposit(ctx, hash, index, chain, assetId, amount, safe.Address, safe.Holder, spent, feeRate)
+}
+
+func (node *Node) ethereumFinishUnconfirmedDeposits(ctx context.Context, chain byte, current map[string]bool, nonces map[string]string) error {
+	rpc, _ := node.ethereumParams(chain)
+	deposits, err := node.store.ListPendingUnconfirmedDeposits(ctx, chain)
+	if err != nil {
+		return err
+	}
+	for _, d := range deposits {
+		if current[d.TransactionHash] {
+			continue
+		}
+		tx, err := ethereum.RPCGetTransactionByHash(rpc, d.TransactionHash)
+		if err != nil {
+			return err
+		}
+		if tx.Hash != "" && tx.BlockNumber == "" {
+			continue
+		}
+		state, replacedBy := UnconfirmedDepositStateConfirmed, ""
+		if tx.Hash == "" {
+			state, replacedBy, err = node.ethereumCheckUnconfirmedDepositReplaced(d, rpc, nonces)
+			if err != nil {
+				return err
+			}
+		}
+		err = node.store.FinishUnconfirmedDeposits(ctx, d.TransactionHash, state, replacedBy)
+		logger.Printf("store.FinishUnconfirmedDeposits(%s, %d, %s) => %v", d.TransactionHash, state, replacedBy, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ethereumCheckUnconfirmedDepositReplaced decides whether the dropped pending
+// transaction is replaced by another one with the same sender nonce, either
+// pending or already confirmed, otherwise it is evicted from the mempool
+func (node *Node) ethereumCheckUnconfirmedDepositReplaced(d *UnconfirmedDeposit, rpc string, nonces map[string]string) (int, string, error) {
+	key := d.Spent[0]
+	if id := nonces[key]; id != "" {
+		return UnconfirmedDepositStateReplaced, id, nil
+	}
+	items := strings.Split(key, ":")
+	if len(items) != 2 {
+		panic(key)
+	}
+	nonce, err := strconv.ParseUint(items[1], 10, 64)
+	if err != nil {
+		panic(key)
+	}
+	count, err := ethereum.RPCGetTransactionCount(rpc, items[0])
+	if err != nil {
+		return 0, "", err
+	}
+	if nonce < count {
+		return UnconfirmedDepositStateReplaced, "", nil
+	}
+	return UnconfirmedDepositStateEvicted, "", nil
+}
+
+func ethereumTransactionNonceKey(tx *ethereum.RPCTransaction) string {
+	nonce, _ := new(big.Int).SetString(tx.Nonce, 0)
+	if nonce == nil {
+		nonce = big.NewInt(0)
+	}
+	return fmt.Sprintf("%s:%s", ethereum.NormalizeAddress(tx.From), nonce.String())
+}
+
+func (node *Node) writeUnconfirmedDeposit(ctx context.Context, hash string, index int64, chain byte, assetId string, amount decimal.Decimal, receiver, holder string, spent []string, feeRate int64) error {
+	expectedAt, err := node.expectUnconfirmedDepositFinalization(ctx, chain, feeRate)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	d := &UnconfirmedDeposit{
+		TransactionHash: hash,
+		OutputIndex:     index,
+		Chain:           chain,
+		AssetId:         assetId,
+		Amount:          amount.String(),
+		Receiver:        receiver,
+		Holder:          holder,
+		Spent:           spent,
+		State:           UnconfirmedDepositStatePending,
+		FeeRate:         feeRate,
+		ExpectedAt:      expectedAt,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = node.store.WriteUnconfirmedDepositIfNotExists(ctx, d)
+	logger.Printf("store.WriteUnconfirmedDepositIfNotExists(%v) => %v", d, err)
+	return err
+}
+
+// expectUnconfirmedDepositFinalization estimates when the deposit would be
+// credited by the keeper, i.e. after the chain finalization delay blocks, and
+// a fee rate lower than the latest network info would wait proportionally more
+func (node *Node) expectUnconfirmedDepositFinalization(ctx context.Context, chain byte, feeRate int64) (time.Time, error) {
+	delay := node.getChainFinalizationDelay(chain)
+	blocks := delay
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if info != nil && feeRate > 0 && uint64(feeRate) < info.Fee {
+		blocks = min(delay*int64(info.Fee)/feeRate, delay*10)
+	}
+	expectedAt := time.Now().Add(time.Duration(blocks) * chainBlockDuration(chain))
+	return expectedAt.Truncate(time.Second).UTC(), nil
+}
+
+func chainBlockDuration(chain byte) time.Duration {
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		return bitcoin.BlockDuration(chain)
+	case common.SafeChainEthereum:
+		return 12 * time.Second
+	case common.SafeChainPolygon:
+		return 2 * time.Second
+	case common.SafeChainSolana:
+		return solana.BlockDuration
+	default:
+		panic(chain)
+	}
+}
@@ -0,0 +1,142 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverUnconfirmedDeposits(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kd, err := store.OpenSQLite3Store(t.TempDir() + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+	node := &Node{store: db, keeperStore: kd, conf: &Configuration{}}
+
+	start := time.Now().UTC().Add(-time.Hour)
+	req := &common.Request{
+		Id:         uuid.Must(uuid.NewV4()).String(),
+		MixinIndex: 1,
+		AssetId:    common.SafeBitcoinChainId,
+		Amount:     decimal.Zero,
+		Role:       common.RequestRoleHolder,
+		Action:     common.ActionBitcoinSafeProposeAccount,
+		Curve:      common.CurveSecp256k1ECDSABitcoin,
+		Holder:     testStatementHolder,
+		State:      common.RequestStateInitial,
+		CreatedAt:  start,
+		Sequence:   1,
+		Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+	}
+	err = kd.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	safe := &store.Safe{
+		Holder:    testStatementHolder,
+		Chain:     common.SafeChainBitcoin,
+		Signer:    testStatementHolder,
+		Observer:  testStatementHolder,
+		Timelock:  time.Hour * 24,
+		Path:      "00000000",
+		Address:   testStatementAddress,
+		Extra:     []byte{},
+		Receivers: []string{uuid.Must(uuid.NewV4()).String()},
+		Threshold: 1,
+		RequestId: req.Id,
+		State:     common.RequestStateDone,
+		CreatedAt: start,
+		UpdatedAt: start,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+
+	var tx bitcoin.RPCTransaction
+	err = json.Unmarshal([]byte(`{
+		"txid": "3f1d0b0e4a8b3fd7f2bd9a3f5b6d9b9bb8d5d0a6d1bda4ab7e5f1d0e0a0d9c21",
+		"vin": [{"txid": "9a1c7b0f7f6e8d0d2f3b1c1a0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a392817", "vout": 1}],
+		"vout": [
+			{"value": 0.0015, "n": 0, "scriptPubKey": {"type": "witness_v0_scripthash", "address": "`+testStatementAddress+`"}},
+			{"value": 0.002, "n": 1, "scriptPubKey": {"type": "witness_v0_keyhash", "address": "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}}
+		]
+	}`), &tx)
+	require.Nil(err)
+	spent := bitcoinSpentOutpoints(&tx)
+	require.Equal([]string{"9a1c7b0f7f6e8d0d2f3b1c1a0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a392817:1"}, spent)
+	require.Equal(int64(10), bitcoinMempoolFeeRate(&bitcoin.RPCTransaction{Fee: 0.0000141, VSize: 141}))
+	require.Equal(int64(0), bitcoinMempoolFeeRate(&bitcoin.RPCTransaction{Fee: 0.0000141}))
+
+	err = node.bitcoinWriteUnconfirmedDeposits(ctx, &tx, spent, 10, common.SafeChainBitcoin)
+	require.Nil(err)
+	deposits, err := db.ListPendingUnconfirmedDeposits(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(deposits, 1)
+	d := deposits[0]
+	require.Equal(tx.TxId, d.TransactionHash)
+	require.Equal(int64(0), d.OutputIndex)
+	require.Equal(common.SafeBitcoinChainId, d.AssetId)
+	require.Equal("0.0015", d.Amount)
+	require.Equal(testStatementHolder, d.Holder)
+	require.Equal(spent, d.Spent)
+	require.Equal(UnconfirmedDepositStatePending, d.State)
+	expected := time.Now().Add(3 * bitcoin.BlockDuration(common.SafeChainBitcoin))
+	require.WithinDuration(expected, d.ExpectedAt, time.Minute)
+
+	err = node.bitcoinWriteUnconfirmedDeposits(ctx, &tx, spent, 10, common.SafeChainBitcoin)
+	require.Nil(err)
+	deposits, err = db.ListUnconfirmedDepositsForReceiver(ctx, testStatementAddress, time.Now().Add(-time.Hour))
+	require.Nil(err)
+	require.Len(deposits, 1)
+
+	req.Id = uuid.Must(uuid.NewV4()).String()
+	req.Role = common.RequestRoleObserver
+	req.Action = common.ActionObserverUpdateNetworkStatus
+	req.Sequence = 2
+	req.MixinIndex = 2
+	req.Output = &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}}
+	err = kd.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	err = kd.WriteNetworkInfoFromRequest(ctx, &store.NetworkInfo{
+		RequestId: req.Id,
+		Chain:     common.SafeChainBitcoin,
+		Fee:       20,
+		Height:    840000,
+		Hash:      "00000000000000000002a4f5cd899ea457314c808897c5c5f1f1cd6ffe2b266a",
+		CreatedAt: start,
+	}, req)
+	require.Nil(err)
+	expectedAt, err := node.expectUnconfirmedDepositFinalization(ctx, common.SafeChainBitcoin, 10)
+	require.Nil(err)
+	require.WithinDuration(time.Now().Add(6*bitcoin.BlockDuration(common.SafeChainBitcoin)), expectedAt, time.Minute)
+	expectedAt, err = node.expectUnconfirmedDepositFinalization(ctx, common.SafeChainBitcoin, 1)
+	require.Nil(err)
+	require.WithinDuration(time.Now().Add(30*bitcoin.BlockDuration(common.SafeChainBitcoin)), expectedAt, time.Minute)
+
+	err = db.FinishUnconfirmedDeposits(ctx, tx.TxId, UnconfirmedDepositStateReplaced, "b7a4a3c40ebc2fca2e2b5fa1ba4a8c1c1d1bfd0eb7df42a2a52ab23e30b7a4c1")
+	require.Nil(err)
+	deposits, err = db.ListPendingUnconfirmedDeposits(ctx, common.SafeChainBitcoin)
+	require.Nil(err)
+	require.Len(deposits, 0)
+	deposits, err = db.ListUnconfirmedDepositsForReceiver(ctx, testStatementAddress, time.Now().Add(-time.Hour))
+	require.Nil(err)
+	require.Len(deposits, 1)
+	require.Equal(UnconfirmedDepositStateReplaced, deposits[0].State)
+	require.Equal("b7a4a3c40ebc2fca2e2b5fa1ba4a8c1c1d1bfd0eb7df42a2a52ab23e30b7a4c1", deposits[0].ReplacedBy)
+	view := viewUnconfirmedDeposits(deposits)
+	require.Equal("replaced", view[0]["state"])
+	deposits, err = db.ListUnconfirmedDepositsForReceiver(ctx, testStatementAddress, time.Now().Add(time.Hour))
+	require.Nil(err)
+	require.Len(deposits, 0)
+}
@@ -0,0 +1,247 @@
+package observer
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/fox-one/mixin-sdk-go/v2"
+	"github.com/gofrs/uuid/v5"
+)
+
+// httpCreateSafeMessage accepts the message and the holder signatures of the
+// message hash, which is the BIP-322 to_sign signature hash for the P2WSH
+// safes, or the EIP-712 safe message hash for the Gnosis safes. The message
+// is sent to keeper after the holder pays the observer with the message id as
+// memo, then the signer signs the same hash and the proof is ready.
+func (node *Node) httpCreateSafeMessage(ctx context.Context, address, message, signature string) (*SafeMessage, error) {
+	logger.Printf("node.httpCreateSafeMessage(%s, %s, %s)", address, message, signature)
+	msg, err := hex.DecodeString(message)
+	if err != nil || len(msg) == 0 {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if safe == nil || safe.State != common.RequestStateDone {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotFound)
+	}
+
+	hash, messageHash, err := node.buildSafeMessageHash(ctx, safe, msg)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := node.verifySafeMessageSignatures(ctx, safe, hash, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &SafeMessage{
+		MessageId:   common.UniqueId(safe.RequestId, messageHash),
+		Chain:       safe.Chain,
+		Holder:      safe.Holder,
+		MessageHash: messageHash,
+		Message:     message,
+		Signature:   hex.EncodeToString(sig),
+		State:       common.RequestStateInitial,
+		CreatedAt:   time.Now().UTC(),
+		UpdatedAt:   time.Now().UTC(),
+	}
+	err = node.store.WriteSafeMessageIfNotExists(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return node.store.ReadSafeMessage(ctx, m.MessageId)
+}
+
+// buildSafeMessageHash returns the hash signed by the holders, and the message
+// hash to identify the message of the safe
+func (node *Node) buildSafeMessageHash(ctx context.Context, safe *store.Safe, msg []byte) ([]byte, string, error) {
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		sp, err := node.keeperStore.ReadSafeProposalByAddress(ctx, safe.Address)
+		if err != nil {
+			return nil, "", err
+		}
+		holders, threshold := node.readSafeHolderKeys(ctx, safe)
+		wsa, err := node.buildBitcoinWitnessAccountWithDerivation(ctx, sp, holders, threshold)
+		if err != nil {
+			return nil, "", err
+		}
+		if wsa.Address != safe.Address {
+			panic(safe.Address)
+		}
+		psbt, err := bitcoin.BuildPartiallySignedMessage(msg, wsa.Script, safe.Chain)
+		if err != nil {
+			return nil, "", fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		return psbt.MessageSigHash(), psbt.Hash(), nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		if len(msg) > ethereum.MessageMaximumSize {
+			return nil, "", fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+		}
+		chainId := ethereum.GetEvmChainID(int64(safe.Chain))
+		hash := ethereum.SafeMessageHash(chainId, safe.Address, msg)
+		return hash, hex.EncodeToString(hash), nil
+	default:
+		return nil, "", fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+}
+
+// verifySafeMessageSignatures accepts the holder signature, or the holder
+// signatures encoded with common.EncodeHolderSignatures for multiple holders
+func (node *Node) verifySafeMessageSignatures(ctx context.Context, safe *store.Safe, hash []byte, signature string) ([]byte, error) {
+	var sig []byte
+	var err error
+	var verify func(public string, sig []byte) error
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		sig, err = base64.RawURLEncoding.DecodeString(signature)
+		verify = func(public string, sig []byte) error {
+			return bitcoin.VerifySignatureDER(public, hash, sig)
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		sig, err = hex.DecodeString(signature)
+		verify = func(public string, sig []byte) error {
+			if len(sig) != 65 || sig[64] <= 30 {
+				return fmt.Errorf("invalid eth_sign signature %x", sig)
+			}
+			return ethereum.VerifyMessageSignature(public, hash, sig)
+		}
+	}
+	if err != nil || len(sig) == 0 {
+		return nil, fmt.Errorf("HTTP: %d", http.StatusNotAcceptable)
+	}
+
+	err = node.verifySafeHolderSignatures(ctx, safe, sig, verify)
+	if err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+func (node *Node) handleSafeMessagePayment(ctx context.Context, s *mixin.SafeSnapshot) (bool, error) {
+	m, err := node.store.ReadSafeMessage(ctx, s.Memo)
+	if err != nil || m == nil {
+		return false, err
+	}
+	params, err := node.keeperStore.ReadLatestOperationParams(ctx, m.Chain, s.CreatedAt)
+	if err != nil || params == nil {
+		return false, err
+	}
+	if s.AssetID != params.OperationPriceAsset {
+		return false, nil
+	}
+	if s.Amount.Cmp(params.OperationPriceAmount) < 0 {
+		return true, nil
+	}
+	if m.State != common.RequestStateInitial {
+		return true, nil
+	}
+	return true, node.store.MarkSafeMessagePaid(ctx, m.MessageId)
+}
+
+func (node *Node) safeMessageLoop(ctx context.Context, chain byte) {
+	for {
+		time.Sleep(3 * time.Second)
+		messages, err := node.store.ListPendingSafeMessages(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		for _, m := range messages {
+			err := node.sendToKeeperSignMessage(ctx, m)
+			logger.Printf("node.sendToKeeperSignMessage(%v) => %v", m, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperSignMessage(ctx context.Context, m *SafeMessage) error {
+	sm := &common.SafeMessage{
+		MessageId: m.MessageId,
+		Message:   common.DecodeHexOrPanic(m.Message),
+		Signature: common.DecodeHexOrPanic(m.Signature),
+	}
+	rawId := common.UniqueId(m.MessageId, m.Signature)
+	raw := append(uuid.Must(uuid.FromString(rawId)).Bytes(), sm.Encode()...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
+	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	if err != nil {
+		return err
+	}
+
+	var action int
+	switch m.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		action = common.ActionBitcoinSafeSignMessage
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		action = common.ActionEthereumSafeSignMessage
+	default:
+		panic(m.Chain)
+	}
+	id := common.UniqueId(m.MessageId, m.MessageId)
+	references := []crypto.Hash{ref}
+	err = node.sendKeeperResponseWithReferences(ctx, m.Holder, byte(action), m.Chain, id, ref[:], references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %s)", m.Holder, action, id, ref)
+	if err != nil {
+		return err
+	}
+
+	if m.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, m.UpdatedAt.String())
+	err = node.sendKeeperResponseWithReferences(ctx, m.Holder, byte(action), m.Chain, id, ref[:], references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %s)", m.Holder, action, id, ref)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateSafeMessageRequestTime(ctx, m.MessageId)
+}
+
+// keeperSaveSafeMessageProof saves the proof of the message signed by the
+// holders and signer, the data is the message id followed by the proof
+func (node *Node) keeperSaveSafeMessageProof(ctx context.Context, data []byte) error {
+	id, err := uuid.FromBytes(data[:16])
+	if err != nil {
+		return err
+	}
+	m, err := node.store.ReadSafeMessage(ctx, id.String())
+	logger.Printf("store.ReadSafeMessage(%s) => %v %v", id, m, err)
+	if err != nil || m == nil || m.State != common.RequestStatePending {
+		return err
+	}
+	return node.store.FinishSafeMessage(ctx, m.MessageId, string(data[16:]))
+}
+
+func (m *SafeMessage) view() map[string]any {
+	view := map[string]any{
+		"id":         m.MessageId,
+		"chain":      m.Chain,
+		"hash":       m.MessageHash,
+		"message":    m.Message,
+		"state":      common.StateName(int(m.State)),
+		"created_at": m.CreatedAt,
+		"updated_at": m.UpdatedAt,
+	}
+	if m.Proof.Valid {
+		view["proof"] = m.Proof.String
+	}
+	return view
+}
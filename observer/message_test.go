@@ -0,0 +1,64 @@
+package observer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverSafeMessage(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	m := &SafeMessage{
+		MessageId:   "c94ac88f-4671-3976-b60a-09064f1811e8",
+		Chain:       common.SafeChainEthereum,
+		Holder:      "holder",
+		MessageHash: "hash",
+		Message:     "6d6978696e",
+		Signature:   "signature",
+		State:       common.RequestStateInitial,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = db.WriteSafeMessageIfNotExists(ctx, m)
+	require.Nil(err)
+	err = db.WriteSafeMessageIfNotExists(ctx, m)
+	require.Nil(err)
+	old, err := db.ReadSafeMessage(ctx, m.MessageId)
+	require.Nil(err)
+	require.Equal(m.MessageHash, old.MessageHash)
+	require.False(old.Proof.Valid)
+
+	messages, err := db.ListPendingSafeMessages(ctx, common.SafeChainEthereum)
+	require.Nil(err)
+	require.Len(messages, 0)
+	err = db.FinishSafeMessage(ctx, m.MessageId, "proof")
+	require.NotNil(err)
+	err = db.MarkSafeMessagePaid(ctx, m.MessageId)
+	require.Nil(err)
+	messages, err = db.ListPendingSafeMessages(ctx, common.SafeChainEthereum)
+	require.Nil(err)
+	require.Len(messages, 1)
+	err = db.UpdateSafeMessageRequestTime(ctx, m.MessageId)
+	require.Nil(err)
+
+	err = db.FinishSafeMessage(ctx, m.MessageId, "proof")
+	require.Nil(err)
+	messages, err = db.ListPendingSafeMessages(ctx, common.SafeChainEthereum)
+	require.Nil(err)
+	require.Len(messages, 0)
+	old, err = db.ReadSafeMessage(ctx, m.MessageId)
+	require.Nil(err)
+	require.Equal("proof", old.Proof.String)
+	require.Equal("done", old.view()["state"])
+	require.Equal("proof", old.view()["proof"])
+}
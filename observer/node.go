@@ -11,10 +11,12 @@ import (
 	"time"
 
 	"github.com/MixinNetwork/bot-api-go-client/v3"
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
 	m "github.com/MixinNetwork/safe/apps/mixin"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper/store"
@@ -38,6 +40,8 @@ type Node struct {
 	mixin       *mixin.Client
 	keeperStore *store.SQLite3Store
 	store       *SQLite3Store
+	lease       *leaderLease
+	network     TestNetwork
 }
 
 func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keeper *mtg.Configuration, mixin *mixin.Client) *Node {
@@ -52,58 +56,112 @@ func NewNode(db *SQLite3Store, kd *store.SQLite3Store, conf *Configuration, keep
 		keeperStore: kd,
 		mixin:       mixin,
 	}
+	if conf.LeaseHolderId != "" {
+		node.lease = newLeaderLease(db, conf.LeaseHolderId, observerLeaseDuration)
+	}
 	node.aesKey = common.ECDHEd25519(conf.PrivateKey, conf.KeeperPublicKey)
 	abi.InitFactoryContractAddress(conf.PolygonFactoryAddress)
+	if conf.SolanaRPC != "" {
+		solana.SetSafeProgram(conf.SolanaProgram)
+	}
 	return node
 }
 
-func (node *Node) Boot(ctx context.Context) {
+// Boot runs the observer loops until the lease is lost, then returns the
+// error, and the loops panicking with the cancelled context are recovered
+func (node *Node) Boot(ctx context.Context) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	node.waitLeaderLease(ctx)
+	go node.leaderLeaseLoop(ctx, cancel)
+
 	err := node.store.Migrate(ctx)
 	if err != nil {
 		panic(err)
 	}
 
-	for _, chain := range []byte{
+	chains := []byte{
 		common.SafeChainBitcoin,
 		common.SafeChainLitecoin,
 		common.SafeChainPolygon,
 		common.SafeChainEthereum,
-	} {
+	}
+	// dogecoin is optional for the existing observers
+	if node.conf.DogecoinRPC != "" {
+		chains = append(chains, common.SafeChainDogecoin)
+	}
+	// solana is optional too, and runs without batch, expiry and timelock
+	if node.conf.SolanaRPC != "" {
+		chains = append(chains, common.SafeChainSolana)
+	}
+	for _, chain := range chains {
 		err := node.sendPriceInfo(ctx, chain)
 		if err != nil {
 			panic(err)
 		}
 
 		switch chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
-			go node.bitcoinNetworkInfoLoop(ctx, chain)
-			go node.bitcoinRPCBlocksLoop(ctx, chain)
-			go node.bitcoinDepositConfirmLoop(ctx, chain)
-			go node.bitcoinTransactionApprovalLoop(ctx, chain)
-			go node.bitcoinTransactionSpendLoop(ctx, chain)
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinNetworkInfoLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinRPCBlocksLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinMempoolLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinDepositConfirmLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinTransactionApprovalLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinTransactionSpendLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinConsolidationLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.transactionBatchLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.transactionExpiryLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.safeMessageLoop(ctx, chain) })
 		case common.SafeChainPolygon, common.SafeChainEthereum:
-			go node.ethereumNetworkInfoLoop(ctx, chain)
-			go node.ethereumRPCBlocksLoop(ctx, chain)
-			go node.ethereumDepositConfirmLoop(ctx, chain)
-			go node.ethereumTransactionApprovalLoop(ctx, chain)
-			go node.ethereumTransactionSpendLoop(ctx, chain)
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumNetworkInfoLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumRPCBlocksLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumMempoolLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumDepositConfirmLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumTransactionApprovalLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.ethereumTransactionSpendLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.transactionBatchLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.transactionExpiryLoop(ctx, chain) })
+			go node.runLoop(ctx, func(ctx context.Context) { node.safeMessageLoop(ctx, chain) })
+		case common.SafeChainSolana:
+			go node.runLoop(ctx, node.solanaNetworkInfoLoop)
+			go node.runLoop(ctx, node.solanaRPCBlocksLoop)
+			go node.runLoop(ctx, node.solanaDepositConfirmLoop)
+			go node.runLoop(ctx, node.solanaTransactionApprovalLoop)
+			go node.runLoop(ctx, node.solanaTransactionSpendLoop)
+			go node.runLoop(ctx, func(ctx context.Context) { node.safeKeyLoop(ctx, chain) })
 		}
 	}
-	go node.safeKeyLoop(ctx, common.SafeChainBitcoin)
-	go node.safeKeyLoop(ctx, common.SafeChainEthereum)
-	go node.mixinWithdrawalsLoop(ctx)
-	go node.sendAccountApprovals(ctx)
-	go node.Blaze(ctx)
-	node.snapshotsLoop(ctx)
+	go node.runLoop(ctx, func(ctx context.Context) { node.safeKeyLoop(ctx, common.SafeChainBitcoin) })
+	go node.runLoop(ctx, func(ctx context.Context) { node.safeKeyLoop(ctx, common.SafeChainEthereum) })
+	go node.runLoop(ctx, node.safeTimelockLoop)
+	go node.runLoop(ctx, node.mixinWithdrawalsLoop)
+	go node.runLoop(ctx, node.sendAccountApprovals)
+	go node.runLoop(ctx, node.Blaze)
+	go node.runLoop(ctx, node.snapshotsLoop)
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
+
+// runLoop runs the loop until the context is done, then the loop panics with
+// the context error, which is expected and recovered
+func (node *Node) runLoop(ctx context.Context, loop func(context.Context)) {
+	defer func() {
+		if r := recover(); r != nil && ctx.Err() == nil {
+			panic(r)
+		}
+	}()
+	loop(ctx)
 }
 
 func (node *Node) sendPriceInfo(ctx context.Context, chain byte) error {
 	var assetId string
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		_, assetId = node.bitcoinParams(chain)
 	case common.SafeChainPolygon, common.SafeChainEthereum:
 		_, assetId = node.ethereumParams(chain)
+	case common.SafeChainSolana:
+		_, assetId = node.solanaParams()
 	default:
 		panic(chain)
 	}
@@ -135,6 +193,10 @@ func (node *Node) sendPriceInfo(ctx context.Context, chain byte) error {
 	extra = append(extra, uuid.Must(uuid.FromString(asset.AssetId)).Bytes()...)
 	extra = binary.BigEndian.AppendUint64(extra, uint64(amount.IntPart()))
 	extra = binary.BigEndian.AppendUint64(extra, uint64(minimum.IntPart()))
+	if expiry := node.conf.ProposalExpiryHours; expiry > 0 {
+		id = common.UniqueId(id, fmt.Sprint(expiry))
+		extra = binary.BigEndian.AppendUint64(extra, uint64(expiry*3600))
+	}
 	return node.sendKeeperResponse(ctx, dummy, common.ActionObserverSetOperationParams, chain, id, extra)
 }
 
@@ -150,6 +212,7 @@ func (node *Node) saveAccountApprovalSignature(ctx context.Context, addr, sig st
 
 func (node *Node) sendAccountApprovals(ctx context.Context) {
 	for {
+		time.Sleep(time.Second)
 		as, err := node.store.ListProposedAccountsWithSig(ctx)
 		if err != nil {
 			panic(err)
@@ -169,7 +232,7 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 			var action byte
 			var assetId string
 			switch sp.Chain {
-			case common.SafeChainBitcoin, common.SafeChainLitecoin:
+			case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 				_, assetId = node.bitcoinParams(sp.Chain)
 				sig, err := base64.RawURLEncoding.DecodeString(account.Signature.String)
 				if err != nil {
@@ -185,6 +248,14 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 				}
 				action = common.ActionEthereumSafeApproveAccount
 				extra = append(rid.Bytes(), sig...)
+			case common.SafeChainSolana:
+				_, assetId = node.solanaParams()
+				sig, err := hex.DecodeString(account.Signature.String)
+				if err != nil {
+					panic(err)
+				}
+				action = common.ActionSolanaSafeApproveAccount
+				extra = append(rid.Bytes(), sig...)
 			default:
 				panic(sp.Chain)
 			}
@@ -205,8 +276,21 @@ func (node *Node) sendAccountApprovals(ctx context.Context) {
 			}
 			id := common.UniqueId(account.Address, account.Signature.String)
 			id = common.UniqueId(id, t.String())
+			var references []crypto.Hash
+			holders, _, err := node.keeperStore.ReadSafeHolderKeys(ctx, sp.Address, sp.Holder)
+			if err != nil {
+				panic(err)
+			}
+			if len(holders) > 1 {
+				ref, err := node.writeAccountHolderSignatures(ctx, sp.Address, extra[16:])
+				if err != nil {
+					panic(err)
+				}
+				extra = append(rid.Bytes(), ref[:]...)
+				references = []crypto.Hash{ref}
+			}
 			logger.Printf("node.sendAccountApprovals(%d, %s, %s, %x)", sp.Chain, sp.Holder, id, extra)
-			err = node.sendKeeperResponse(ctx, sp.Holder, byte(action), sp.Chain, id, extra)
+			err = node.sendKeeperResponseWithReferences(ctx, sp.Holder, byte(action), sp.Chain, id, extra, references)
 			if err != nil {
 				panic(err)
 			}
@@ -224,7 +308,7 @@ func (node *Node) snapshotsLoop(ctx context.Context) {
 		if err != nil {
 			panic(err)
 		}
-		snapshots, err := node.mixin.ReadSafeSnapshots(ctx, "", offset, "ASC", 500)
+		snapshots, err := node.readSafeSnapshots(ctx, offset, 500)
 		if err != nil {
 			logger.Printf("mixin.ReadSafeSnapshots(%s) => %v", offset, err)
 			time.Sleep(1 * time.Second)
@@ -263,7 +347,19 @@ func (node *Node) handleSnapshot(ctx context.Context, s *mixin.SafeSnapshot) err
 	}
 	s.Memo = string(memo)
 
-	handled, err := node.handleTransactionApprovalPayment(ctx, s)
+	handled, err := node.handleTransactionBatchPayment(ctx, s)
+	logger.Printf("node.handleTransactionBatchPayment(%v) => %t %v", s, handled, err)
+	if err != nil || handled {
+		return err
+	}
+
+	handled, err = node.handleSafeMessagePayment(ctx, s)
+	logger.Printf("node.handleSafeMessagePayment(%v) => %t %v", s, handled, err)
+	if err != nil || handled {
+		return err
+	}
+
+	handled, err = node.handleTransactionApprovalPayment(ctx, s)
 	logger.Printf("node.handleTransactionApprovalPayment(%v) => %t %v", s, handled, err)
 	if err != nil || handled {
 		return err
@@ -337,7 +433,7 @@ func (node *Node) processMixinWithdrawalSnapshot(ctx context.Context, s *m.RPCSn
 		}
 		hash := string(extra[64:])
 		switch asset.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 			rpc, _ := node.bitcoinParams(asset.Chain)
 			btx, err := bitcoin.RPCGetTransaction(asset.Chain, rpc, hash)
 			if err != nil {
@@ -441,7 +537,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	switch s.AssetID {
 	case node.conf.AssetId:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionSolanaSafeApproveAccount:
 			return false, nil
 		}
 		if s.Amount.Cmp(decimal.NewFromInt(1)) < 0 {
@@ -449,7 +545,7 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 		}
 	case params.OperationPriceAsset:
 		switch op.Type {
-		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount:
+		case common.ActionBitcoinSafeApproveAccount, common.ActionEthereumSafeApproveAccount, common.ActionSolanaSafeApproveAccount:
 		default:
 			return false, nil
 		}
@@ -461,34 +557,58 @@ func (node *Node) handleKeeperResponse(ctx context.Context, s *mixin.SafeSnapsho
 	}
 
 	rid := uuid.Must(uuid.FromBytes(op.Extra)).String()
-	tx, err := common.SafeReadTransactionRequestUntilSufficient(ctx, node.mixin, rid)
+	extra, err := node.readTransactionExtra(ctx, rid)
 	if err != nil {
 		return false, err
 	}
 	// FIXME should check transaction references and read kernel storage transaction
-	data, _ := hex.DecodeString(tx.Extra)
+	data, _ := hex.DecodeString(extra)
 	data, err = common.Base91Decode(string(data))
 	if err != nil || len(data) < 32 {
 		panic(fmt.Errorf("common.Base91Decode(%s) => %d %v", m, len(data), err))
 	}
 
 	switch op.Type {
-	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction:
+	case common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction, common.ActionSolanaSafeProposeTransaction:
 		return true, node.keeperSaveTransactionProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveTransaction:
 		return true, node.keeperCombineBitcoinTransactionSignatures(ctx, data)
 	case common.ActionEthereumSafeApproveTransaction:
 		return true, node.keeperVerifyEthereumTransactionSignatures(ctx, data)
-	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount:
+	case common.ActionSolanaSafeApproveTransaction:
+		return true, node.keeperVerifySolanaTransactionSignatures(ctx, data)
+	case common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount, common.ActionSolanaSafeProposeAccount:
 		return true, node.keeperSaveAccountProposal(ctx, chain, data, s.CreatedAt)
 	case common.ActionBitcoinSafeApproveAccount:
 		return true, node.deployBitcoinSafeBond(ctx, data)
 	case common.ActionEthereumSafeApproveAccount:
 		return true, node.deployEthereumGnosisSafeAccount(ctx, data)
+	case common.ActionSolanaSafeApproveAccount:
+		return true, node.deploySolanaSafeAccount(ctx, data)
+	case common.ActionBitcoinSafeSignMessage, common.ActionEthereumSafeSignMessage:
+		return true, node.keeperSaveSafeMessageProof(ctx, data)
 	}
 	return true, nil
 }
 
+func (node *Node) readSafeSnapshots(ctx context.Context, offset time.Time, limit int) ([]*mixin.SafeSnapshot, error) {
+	if common.CheckTestEnvironment(ctx) {
+		return node.network.ReadSafeSnapshots(ctx, offset, limit)
+	}
+	return node.mixin.ReadSafeSnapshots(ctx, "", offset, "ASC", limit)
+}
+
+func (node *Node) readTransactionExtra(ctx context.Context, traceId string) (string, error) {
+	if common.CheckTestEnvironment(ctx) {
+		return node.network.ReadTransactionExtra(ctx, traceId)
+	}
+	tx, err := common.SafeReadTransactionRequestUntilSufficient(ctx, node.mixin, traceId)
+	if err != nil {
+		return "", err
+	}
+	return tx.Extra, nil
+}
+
 func (node *Node) readSnapshotsCheckpoint(ctx context.Context) (time.Time, error) {
 	val, err := node.store.ReadProperty(ctx, snapshotsCheckpointKey)
 	if err != nil || val == "" {
@@ -504,6 +624,10 @@ func (node *Node) writeSnapshotsCheckpoint(ctx context.Context, offset time.Time
 func (node *Node) readDepositCheckpoint(ctx context.Context, chain byte) (int64, error) {
 	key := depositCheckpointKey(chain)
 	min := depositCheckpointDefault(chain)
+	if min > 0 && common.CheckTestEnvironment(ctx) {
+		// the chain stand-ins of the test environment start from the genesis
+		min = 1
+	}
 	ckt, err := node.store.ReadProperty(ctx, key)
 	if err != nil || ckt == "" {
 		return min, err
@@ -553,10 +677,16 @@ func depositCheckpointDefault(chain byte) int64 {
 		return 802220
 	case common.SafeChainLitecoin:
 		return 2523300
+	case common.SafeChainDogecoin:
+		return 5420000
 	case common.SafeChainPolygon:
 		return 52950000
 	case common.SafeChainEthereum:
 		return 19175473
+	case common.SafeChainSolana:
+		// solana blocks are too fast to replay from a fixed slot, so the
+		// blocks loop starts from the latest slot without a checkpoint
+		return 0
 	default:
 		panic(chain)
 	}
@@ -564,10 +694,12 @@ func depositCheckpointDefault(chain byte) int64 {
 
 func depositCheckpointKey(chain byte) string {
 	switch chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		return fmt.Sprintf("bitcoin-deposit-checkpoint-%d", chain)
 	case common.SafeChainEthereum, common.SafeChainPolygon:
 		return fmt.Sprintf("ethereum-deposit-checkpoint-%d", chain)
+	case common.SafeChainSolana:
+		return fmt.Sprintf("solana-deposit-checkpoint-%d", chain)
 	default:
 		panic(chain)
 	}
@@ -587,10 +719,14 @@ func (node *Node) getChainFinalizationDelay(chain byte) int64 {
 		return 3
 	case common.SafeChainLitecoin:
 		return 6
+	case common.SafeChainDogecoin:
+		return 30
 	case common.SafeChainEthereum:
 		return 32
 	case common.SafeChainPolygon:
 		return 512
+	case common.SafeChainSolana:
+		return 1
 	default:
 		panic(chain)
 	}
@@ -1,6 +1,7 @@
 package observer
 
 import (
+	"bytes"
 	"context"
 	"encoding/hex"
 	"math/big"
@@ -11,11 +12,17 @@ import (
 
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/safe/apps/bitcoin"
+	btcrpc "github.com/MixinNetwork/safe/apps/bitcoin/rpctest"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	evmrpc "github.com/MixinNetwork/safe/apps/ethereum/rpctest"
+	mixinrpc "github.com/MixinNetwork/safe/apps/mixin/rpctest"
+	solrpc "github.com/MixinNetwork/safe/apps/solana/rpctest"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/common/abi"
 	"github.com/MixinNetwork/safe/keeper"
 	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	ec "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -34,6 +41,15 @@ const (
 	testReceiverAddress         = "0x9d04735aaEB73535672200950fA77C2dFC86eB21"
 )
 
+// testNetwork is the in-memory chains and Mixin asset API of the test node,
+// they are started again for each node built
+var testNetwork struct {
+	bitcoin *btcrpc.Server
+	polygon *evmrpc.Server
+	mixin   *mixinrpc.Server
+	solana  *solrpc.Server
+}
+
 func TestObserver(t *testing.T) {
 	logger.SetLevel(logger.VERBOSE)
 	ctx := context.Background()
@@ -45,6 +61,7 @@ func TestObserver(t *testing.T) {
 	node := testBuildNode(ctx, require, root)
 	require.NotNil(node)
 
+	testSpendBitcoinWithFee(require, 10)
 	fvb, err := bitcoin.EstimateAvgFee(common.SafeChainBitcoin, node.conf.BitcoinRPC)
 	require.Nil(err)
 	require.GreaterOrEqual(fvb, int64(5))
@@ -101,12 +118,17 @@ func TestObserverMigrateBondAsset(t *testing.T) {
 	require.Equal(testMVMBondAssetId, bondId)
 
 	abi.InitFactoryContractAddress(node.conf.PolygonFactoryAddress)
-	err = abi.GetOrDeployFactoryAsset(ctx, node.conf.PolygonRPC, os.Getenv("MVM_DEPLOYER"), assetId, asset.Symbol, asset.Name, testReceiverAddress, holder)
+	testNetwork.mixin.RegisterBond(testReceiverAddress, assetId, holder)
+	deployed, err := abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, bond.Hex())
 	require.Nil(err)
+	require.Equal(0, deployed.Sign())
 
 	bond = abi.GetFactoryAssetAddress(testReceiverAddress, assetId, asset.Symbol, asset.Name, holder)
 	bondId = ethereum.GenerateAssetId(common.SafeChainPolygon, strings.ToLower(bond.Hex()))
 	require.Equal(testPolygonBondAssetId, bondId)
+	deployed, err = abi.CheckFactoryAssetDeployed(node.conf.PolygonRPC, bond.Hex())
+	require.Nil(err)
+	require.Equal(uuid.Must(uuid.FromString(assetId)).Bytes(), deployed.Bytes())
 }
 
 func TestAsset(t *testing.T) {
@@ -286,10 +308,45 @@ func testBuildNode(_ context.Context, require *require.Assertions, root string)
 	db, err := OpenSQLite3Store(conf.Observer.StoreDir + "/observer.sqlite3")
 	require.Nil(err)
 
+	testNetwork.bitcoin = btcrpc.NewServer(bitcoin.ChainBitcoin)
+	testNetwork.polygon = evmrpc.NewServer(ethereum.ChainPolygon)
+	testNetwork.mixin = mixinrpc.NewServer()
+	testNetwork.solana = solrpc.NewServer()
+	testNetwork.polygon.HandleCall(conf.Observer.PolygonFactoryAddress, testNetwork.mixin.CallFactory)
+	conf.Observer.BitcoinRPC = testNetwork.bitcoin.URL()
+	conf.Observer.PolygonRPC = testNetwork.polygon.URL()
+	conf.Observer.MixinMessengerAPI = testNetwork.mixin.URL()
+	conf.Observer.SolanaRPC = testNetwork.solana.URL()
+	conf.Observer.SolanaProgram = testSolanaProgram
+	conf.Observer.SolanaKey = testSolanaKeyPayer
+	conf.Observer.SolanaNonceAccount = testNetwork.solana.CreateNonceAccount(testSolanaAddress(testSolanaKeyPayer))
+
 	node := NewNode(db, kd, conf.Observer, conf.Keeper.MTG, nil)
 	return node
 }
 
+// testSpendBitcoinWithFee funds the safe and spends it back in the mempool
+// paying fvb satoshis per vbyte, so the fee estimation has a sample
+func testSpendBitcoinWithFee(require *require.Assertions, fvb int64) {
+	id, err := testNetwork.bitcoin.Fund(testSafeAddress, 100000)
+	require.Nil(err)
+	testNetwork.bitcoin.Mine(1)
+
+	hash, err := chainhash.NewHashFromStr(id)
+	require.Nil(err)
+	script, err := bitcoin.ParseAddress(testSafeAddress, common.SafeChainBitcoin)
+	require.Nil(err)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(0, script))
+	tx.TxOut[0].Value = 100000 - fvb*int64(tx.SerializeSize())
+	var buf bytes.Buffer
+	err = tx.Serialize(&buf)
+	require.Nil(err)
+	_, err = testNetwork.bitcoin.SendRawTransaction(hex.EncodeToString(buf.Bytes()))
+	require.Nil(err)
+}
+
 func getMVMFactoryAssetAddress(assetId, symbol, name string, holder string) ec.Address {
 	symbol, name = "safe"+symbol, name+" @ Mixin Safe"
 	id := uuid.Must(uuid.FromString(assetId))
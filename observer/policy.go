@@ -0,0 +1,19 @@
+package observer
+
+import (
+	"context"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// the keeper rejects the approval of a transaction delayed by the safe policy,
+// so the observer holds the approval until the outflow unlocked
+func (node *Node) checkSafeOutflowLocked(ctx context.Context, hash string) (bool, error) {
+	outflow, err := node.keeperStore.ReadSafeOutflow(ctx, hash)
+	logger.Printf("keeperStore.ReadSafeOutflow(%s) => %v %v", hash, outflow, err)
+	if err != nil || outflow == nil {
+		return false, err
+	}
+	return time.Now().Before(outflow.UnlockAt.Add(time.Minute)), nil
+}
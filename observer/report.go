@@ -0,0 +1,445 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	StatementEntryDeposit    = "deposit"
+	StatementEntryWithdrawal = "withdrawal"
+)
+
+type StatementEntry struct {
+	Time            time.Time       `json:"time"`
+	Type            string          `json:"type"`
+	AssetId         string          `json:"asset_id"`
+	TransactionHash string          `json:"transaction_hash"`
+	RequestId       string          `json:"request_id"`
+	Amount          decimal.Decimal `json:"amount"`
+	Fee             decimal.Decimal `json:"fee"`
+	AccountantFee   decimal.Decimal `json:"accountant_fee"`
+	Minted          decimal.Decimal `json:"minted"`
+	Burned          decimal.Decimal `json:"burned"`
+	Balance         decimal.Decimal `json:"balance"`
+}
+
+type StatementTotals struct {
+	Deposits       decimal.Decimal `json:"deposits"`
+	Withdrawals    decimal.Decimal `json:"withdrawals"`
+	Fees           decimal.Decimal `json:"fees"`
+	AccountantFees decimal.Decimal `json:"accountant_fees"`
+	Minted         decimal.Decimal `json:"minted"`
+	Burned         decimal.Decimal `json:"burned"`
+}
+
+// StatementReconciliation is expected to be all zero, the minted and burned
+// safe assets should match the chain flows, and the closing balance should
+// match the safe balance in the keeper store if the period ends after now
+type StatementReconciliation struct {
+	MintDifference    decimal.Decimal  `json:"mint_difference"`
+	BurnDifference    decimal.Decimal  `json:"burn_difference"`
+	BalanceDifference *decimal.Decimal `json:"balance_difference"`
+}
+
+// Statement is the accounting of one asset of a safe in the period [From, To),
+// the accountant fees are paid by the observer for bitcoin transactions, and
+// the EVM gas paid by the observer is not recorded in any store
+type Statement struct {
+	Holder         string                  `json:"holder"`
+	Address        string                  `json:"address"`
+	Chain          byte                    `json:"chain"`
+	AssetId        string                  `json:"asset_id"`
+	From           time.Time               `json:"from"`
+	To             time.Time               `json:"to"`
+	Opening        decimal.Decimal         `json:"opening"`
+	Closing        decimal.Decimal         `json:"closing"`
+	Entries        []*StatementEntry       `json:"entries"`
+	Totals         StatementTotals         `json:"totals"`
+	Reconciliation StatementReconciliation `json:"reconciliation"`
+
+	decimals int32
+}
+
+func (node *Node) BuildStatements(ctx context.Context, id string, from, to time.Time) ([]*Statement, error) {
+	return BuildStatements(ctx, node.keeperStore, node.store, id, from, to)
+}
+
+// BuildStatements reads the keeper deposits and transactions of the safe by
+// holder or address, the change outputs of the safe transactions are not
+// deposits and excluded by the observer transaction approvals, it returns nil
+// if the safe is not found
+func BuildStatements(ctx context.Context, kd *store.SQLite3Store, db *SQLite3Store, id string, from, to time.Time) ([]*Statement, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid statement period %s %s", from, to)
+	}
+	safe, err := kd.ReadSafe(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if safe == nil {
+		safe, err = kd.ReadSafeByAddress(ctx, id)
+	}
+	if err != nil || safe == nil {
+		return nil, err
+	}
+
+	sm := make(map[string]*Statement)
+	statement := func(assetId string) (*Statement, error) {
+		if s := sm[assetId]; s != nil {
+			return s, nil
+		}
+		decimals, err := statementAssetDecimals(ctx, kd, safe.Chain, assetId)
+		if err != nil {
+			return nil, err
+		}
+		s := &Statement{
+			Holder:   safe.Holder,
+			Address:  safe.Address,
+			Chain:    safe.Chain,
+			AssetId:  assetId,
+			From:     from,
+			To:       to,
+			Opening:  decimal.Zero,
+			Closing:  decimal.Zero,
+			Entries:  []*StatementEntry{},
+			decimals: decimals,
+		}
+		s.Totals = StatementTotals{
+			Deposits:       decimal.Zero,
+			Withdrawals:    decimal.Zero,
+			Fees:           decimal.Zero,
+			AccountantFees: decimal.Zero,
+			Minted:         decimal.Zero,
+			Burned:         decimal.Zero,
+		}
+		sm[assetId] = s
+		return s, nil
+	}
+
+	var entries []*StatementEntry
+	deposits, err := kd.ListDepositsForHolder(ctx, safe.Holder, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deposits {
+		approval, err := db.ReadTransactionApproval(ctx, d.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		if approval != nil && approval.Holder == safe.Holder {
+			continue
+		}
+		s, err := statement(d.AssetId)
+		if err != nil {
+			return nil, err
+		}
+		amount := decimal.RequireFromString(d.Amount).Shift(-s.decimals)
+		entries = append(entries, &StatementEntry{
+			Time:            d.CreatedAt,
+			Type:            StatementEntryDeposit,
+			AssetId:         d.AssetId,
+			TransactionHash: d.TransactionHash,
+			Amount:          amount,
+			Fee:             decimal.Zero,
+			AccountantFee:   decimal.Zero,
+			Minted:          amount,
+			Burned:          decimal.Zero,
+		})
+	}
+
+	txs, err := kd.ListTransactionsForHolder(ctx, safe.Holder, common.RequestStateDone, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range txs {
+		e, err := buildStatementWithdrawal(ctx, kd, db, safe, t)
+		if err != nil {
+			return nil, err
+		}
+		_, err = statement(e.AssetId)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	slices.SortStableFunc(entries, func(a, b *StatementEntry) int {
+		return a.Time.Compare(b.Time)
+	})
+	for _, e := range entries {
+		s := sm[e.AssetId]
+		change := e.Amount
+		if e.Type == StatementEntryWithdrawal {
+			change = e.Amount.Add(e.Fee).Neg()
+		}
+		if e.Time.Before(from) {
+			s.Opening = s.Opening.Add(change)
+			s.Closing = s.Opening
+			continue
+		}
+		s.Closing = s.Closing.Add(change)
+		e.Balance = s.Closing
+		s.Entries = append(s.Entries, e)
+		switch e.Type {
+		case StatementEntryDeposit:
+			s.Totals.Deposits = s.Totals.Deposits.Add(e.Amount)
+		case StatementEntryWithdrawal:
+			s.Totals.Withdrawals = s.Totals.Withdrawals.Add(e.Amount)
+		}
+		s.Totals.Fees = s.Totals.Fees.Add(e.Fee)
+		s.Totals.AccountantFees = s.Totals.AccountantFees.Add(e.AccountantFee)
+		s.Totals.Minted = s.Totals.Minted.Add(e.Minted)
+		s.Totals.Burned = s.Totals.Burned.Add(e.Burned)
+	}
+
+	statements := make([]*Statement, 0)
+	for _, s := range sm {
+		s.Reconciliation.MintDifference = s.Totals.Minted.Sub(s.Totals.Deposits)
+		s.Reconciliation.BurnDifference = s.Totals.Burned.Sub(s.Totals.Withdrawals)
+		if to.After(time.Now()) {
+			balance, err := readStatementSafeBalance(ctx, kd, safe, s)
+			if err != nil {
+				return nil, err
+			}
+			diff := s.Closing.Sub(balance)
+			s.Reconciliation.BalanceDifference = &diff
+		}
+		statements = append(statements, s)
+	}
+	chainAssetId := common.SafeChainAssetId(safe.Chain)
+	slices.SortFunc(statements, func(a, b *Statement) int {
+		switch {
+		case a.AssetId == chainAssetId:
+			return -1
+		case b.AssetId == chainAssetId:
+			return 1
+		default:
+			return strings.Compare(a.AssetId, b.AssetId)
+		}
+	})
+	return statements, nil
+}
+
+func buildStatementWithdrawal(ctx context.Context, kd *store.SQLite3Store, db *SQLite3Store, safe *store.Safe, t *store.Transaction) (*StatementEntry, error) {
+	var recipients []map[string]string
+	if t.Data != "" {
+		err := json.Unmarshal([]byte(t.Data), &recipients)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e := &StatementEntry{
+		Time:            t.CreatedAt,
+		Type:            StatementEntryWithdrawal,
+		AssetId:         common.SafeChainAssetId(safe.Chain),
+		TransactionHash: t.TransactionHash,
+		RequestId:       t.RequestId,
+		Amount:          decimal.Zero,
+		Fee:             decimal.Zero,
+		AccountantFee:   decimal.Zero,
+		Minted:          decimal.Zero,
+		Burned:          decimal.Zero,
+	}
+	for _, r := range recipients {
+		if token := r["token"]; token != "" {
+			e.AssetId = ethereum.GenerateAssetId(safe.Chain, token)
+		}
+		e.Amount = e.Amount.Add(decimal.RequireFromString(r["amount"]))
+	}
+
+	// the holder pays the safe asset to propose a transaction, while the
+	// recovery transactions are requested by the observer without burning
+	req, err := kd.ReadRequest(ctx, t.RequestId)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil && req.Role == common.RequestRoleHolder {
+		e.Burned = req.Amount
+	}
+
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		inputs, err := kd.ReadBitcoinSpentSatoshi(ctx, t.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		outputs, err := statementBitcoinOutputsSatoshi(t.RawTransaction)
+		if err != nil {
+			return nil, err
+		}
+		e.Fee = decimal.New(inputs-outputs, -bitcoin.ValuePrecision)
+		fee, err := db.ReadAccountantFee(ctx, t.TransactionHash)
+		if err != nil {
+			return nil, err
+		}
+		e.AccountantFee = decimal.New(fee, -bitcoin.ValuePrecision)
+	}
+	return e, nil
+}
+
+// the proposed transactions are stored as PSBT, while the holder signed
+// recovery transactions are stored as raw transactions
+func statementBitcoinOutputsSatoshi(raw string) (int64, error) {
+	b, err := hex.DecodeString(raw)
+	if err != nil {
+		return 0, err
+	}
+	msgTx := wire.NewMsgTx(2)
+	psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(b)
+	if err == nil {
+		msgTx = psbt.UnsignedTx
+	} else {
+		err = msgTx.Deserialize(bytes.NewReader(b))
+		if err != nil {
+			return 0, err
+		}
+	}
+	var satoshi int64
+	for _, out := range msgTx.TxOut {
+		satoshi = satoshi + out.Value
+	}
+	return satoshi, nil
+}
+
+func statementAssetDecimals(ctx context.Context, kd *store.SQLite3Store, chain byte, assetId string) (int32, error) {
+	switch {
+	case assetId != common.SafeChainAssetId(chain):
+	case chain == common.SafeChainBitcoin, chain == common.SafeChainLitecoin, chain == common.SafeChainDogecoin:
+		return bitcoin.ValuePrecision, nil
+	case chain == common.SafeChainSolana:
+		return solana.ValuePrecision, nil
+	default:
+		return ethereum.ValuePrecision, nil
+	}
+	asset, err := kd.ReadAssetMeta(ctx, assetId)
+	if err != nil || asset == nil {
+		return 0, fmt.Errorf("store.ReadAssetMeta(%s) => %v %v", assetId, asset, err)
+	}
+	return int32(asset.Decimals), nil
+}
+
+func readStatementSafeBalance(ctx context.Context, kd *store.SQLite3Store, safe *store.Safe, s *Statement) (decimal.Decimal, error) {
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		mainInputs, err := kd.ListAllBitcoinUTXOsForHolder(ctx, safe.Holder)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		pendings, err := kd.ListPendingBitcoinUTXOsForHolder(ctx, safe.Holder)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		var satoshi int64
+		for _, in := range append(mainInputs, pendings...) {
+			satoshi = satoshi + in.Satoshi
+		}
+		return decimal.New(satoshi, -bitcoin.ValuePrecision), nil
+	default:
+		balances, err := kd.ReadAllEthereumTokenBalances(ctx, safe.Address)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		for _, b := range balances {
+			if b.AssetId == s.AssetId {
+				return decimal.NewFromBigInt(b.BigBalance(), -s.decimals), nil
+			}
+		}
+		return decimal.Zero, nil
+	}
+}
+
+var statementCSVHeader = []string{"holder", "address", "asset_id", "time", "type", "transaction_hash", "request_id", "amount", "fee", "accountant_fee", "minted", "burned", "balance"}
+
+// WriteStatementsCSV writes an opening row, the entries and a closing row for
+// each statement, the closing row has the period totals, and its amount is the
+// deposits minus the withdrawals
+func WriteStatementsCSV(w io.Writer, statements []*Statement) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(statementCSVHeader)
+	if err != nil {
+		return err
+	}
+	for _, s := range statements {
+		rows := [][]string{{
+			s.Holder, s.Address, s.AssetId, s.From.Format(time.RFC3339), "opening",
+			"", "", "", "", "", "", "", s.Opening.String(),
+		}}
+		for _, e := range s.Entries {
+			rows = append(rows, []string{
+				s.Holder, s.Address, s.AssetId, e.Time.Format(time.RFC3339Nano), e.Type,
+				e.TransactionHash, e.RequestId, e.Amount.String(), e.Fee.String(),
+				e.AccountantFee.String(), e.Minted.String(), e.Burned.String(), e.Balance.String(),
+			})
+		}
+		t := s.Totals
+		rows = append(rows, []string{
+			s.Holder, s.Address, s.AssetId, s.To.Format(time.RFC3339), "closing",
+			"", "", t.Deposits.Sub(t.Withdrawals).String(), t.Fees.String(),
+			t.AccountantFees.String(), t.Minted.String(), t.Burned.String(), s.Closing.String(),
+		})
+		err = cw.WriteAll(rows)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteStatementsJSON(w io.Writer, statements []*Statement) error {
+	_, err := w.Write(common.MarshalJSONOrPanic(statements))
+	return err
+}
+
+// ParseStatementPeriod accepts months, dates or RFC3339 times in UTC, the
+// period is the month of from if to is empty, or the current month if both
+func ParseStatementPeriod(from, to string) (time.Time, time.Time, error) {
+	parse := func(s string) (time.Time, error) {
+		for _, layout := range []string{"2006-01", "2006-01-02", time.RFC3339} {
+			t, err := time.Parse(layout, s)
+			if err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid statement time %s", s)
+	}
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if from != "" {
+		t, err := parse(from)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		start = t
+	}
+	end := start.AddDate(0, 1, 0)
+	if to != "" {
+		t, err := parse(to)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		end = t
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid statement period %s %s", from, to)
+	}
+	return start, end, nil
+}
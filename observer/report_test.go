@@ -0,0 +1,206 @@
+package observer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testStatementHolder   = "03911c1ef3960be7304596cfa6073b1d65ad43b421a4c272142cc7a8369b510c56"
+	testStatementAddress  = "bc1qm7qaucdjwzpapugfvmzp2xduzmgndn2wgg4sz6s6cq5p0qd0mkrq4xt7ej"
+	testStatementReceiver = "bc1qzccxhrlm5pxnhqmkfk2ld9dvkfulmd2mf0w4xc"
+)
+
+func TestObserverStatements(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	kd, err := store.OpenSQLite3Store(t.TempDir() + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+
+	index := 0
+	request := func(role uint8, amount string, createdAt time.Time) *common.Request {
+		index = index + 1
+		req := &common.Request{
+			Id:         uuid.Must(uuid.NewV4()).String(),
+			MixinIndex: index,
+			AssetId:    common.SafeBitcoinChainId,
+			Amount:     decimal.RequireFromString(amount),
+			Role:       role,
+			Curve:      common.CurveSecp256k1ECDSABitcoin,
+			Holder:     testStatementHolder,
+			State:      common.RequestStateInitial,
+			CreatedAt:  createdAt,
+			Sequence:   uint64(index),
+			Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+		}
+		err := kd.WriteRequestIfNotExist(ctx, req)
+		require.Nil(err)
+		return req
+	}
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	req := request(common.RequestRoleHolder, "0.0001", start)
+	safe := &store.Safe{
+		Holder:    testStatementHolder,
+		Chain:     common.SafeChainBitcoin,
+		Signer:    testStatementHolder,
+		Observer:  testStatementHolder,
+		Timelock:  time.Hour * 24,
+		Path:      "00000000",
+		Address:   testStatementAddress,
+		Extra:     []byte{},
+		Receivers: []string{uuid.Must(uuid.NewV4()).String()},
+		Threshold: 1,
+		RequestId: req.Id,
+		State:     common.RequestStateDone,
+		CreatedAt: start,
+		UpdatedAt: start,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+
+	deposit := func(hash string, index uint32, satoshi int64, createdAt time.Time) {
+		req := request(common.RequestRoleObserver, "0", createdAt)
+		utxo := &bitcoin.Input{TransactionHash: hash, Index: index, Satoshi: satoshi}
+		err := kd.WriteBitcoinOutputFromRequest(ctx, safe, utxo, req, common.SafeBitcoinChainId, testStatementReceiver, nil)
+		require.Nil(err)
+	}
+	first := "6d0a8b1ee5b1d1d7f8b52bc3d4ff9ff0a2bb2e44d2a9db9f2c35ee53a0e7b58a"
+	second := "b7a4a3c40ebc2fca2e2b5fa1ba4a8c1c1d1bfd0eb7df42a2a52ab23e30b7a4c1"
+	deposit(first, 0, 100000, time.Date(2024, 5, 20, 0, 0, 0, 0, time.UTC))
+	deposit(second, 1, 50000, time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC))
+
+	msgTx := wire.NewMsgTx(2)
+	hash, err := chainhash.NewHashFromStr(first)
+	require.Nil(err)
+	msgTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	msgTx.AddTxOut(wire.NewTxOut(60000, []byte{0}))
+	msgTx.AddTxOut(wire.NewTxOut(39000, []byte{0}))
+	var raw bytes.Buffer
+	err = msgTx.Serialize(&raw)
+	require.Nil(err)
+	withdrawnAt := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	req = request(common.RequestRoleHolder, "0.0006", withdrawnAt)
+	trx := &store.Transaction{
+		TransactionHash: msgTx.TxHash().String(),
+		RawTransaction:  hex.EncodeToString(raw.Bytes()),
+		Holder:          testStatementHolder,
+		Chain:           common.SafeChainBitcoin,
+		AssetId:         common.SafeBitcoinChainId,
+		State:           common.RequestStateDone,
+		Data:            `[{"receiver":"` + testStatementReceiver + `","amount":"0.0006"}]`,
+		RequestId:       req.Id,
+		CreatedAt:       withdrawnAt,
+		UpdatedAt:       withdrawnAt,
+	}
+	utxos := []*store.TransactionInput{{Hash: first, Index: 0}}
+	err = kd.WriteTransactionWithRequest(ctx, trx, utxos, nil, req)
+	require.Nil(err)
+	deposit(trx.TransactionHash, 1, 39000, time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC))
+
+	approval := &Transaction{
+		TransactionHash: trx.TransactionHash,
+		RawTransaction:  trx.RawTransaction,
+		Chain:           common.SafeChainBitcoin,
+		Holder:          testStatementHolder,
+		Signer:          testStatementHolder,
+		State:           common.RequestStateDone,
+		SpentHash:       sql.NullString{Valid: true, String: trx.TransactionHash},
+		SpentRaw:        sql.NullString{Valid: true, String: trx.RawTransaction},
+		CreatedAt:       withdrawnAt,
+		UpdatedAt:       withdrawnAt,
+	}
+	err = db.WriteTransactionApprovalIfNotExists(ctx, approval)
+	require.Nil(err)
+	err = db.WriteBitcoinUTXOIfNotExists(ctx, &Output{
+		TransactionHash: second,
+		Index:           2,
+		Address:         testStatementReceiver,
+		Satoshi:         5000,
+		Chain:           common.SafeChainBitcoin,
+		State:           common.RequestStateInitial,
+		CreatedAt:       start,
+		UpdatedAt:       start,
+	})
+	require.Nil(err)
+	fee, err := db.AssignBitcoinUTXOByRangeForTransaction(ctx, 1000, 10000, approval)
+	require.Nil(err)
+	require.NotNil(fee)
+
+	from, to, err := ParseStatementPeriod("2024-06", "")
+	require.Nil(err)
+	require.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), from)
+	require.Equal(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), to)
+
+	statements, err := BuildStatements(ctx, kd, db, "unknown", from, to)
+	require.Nil(err)
+	require.Nil(statements)
+	statements, err = BuildStatements(ctx, kd, db, testStatementAddress, from, to)
+	require.Nil(err)
+	require.Len(statements, 1)
+	s := statements[0]
+	require.Equal(common.SafeBitcoinChainId, s.AssetId)
+	require.Equal("0.001", s.Opening.String())
+	require.Equal("0.00089", s.Closing.String())
+	require.Len(s.Entries, 2)
+	require.Equal(StatementEntryDeposit, s.Entries[0].Type)
+	require.Equal("0.0015", s.Entries[0].Balance.String())
+	e := s.Entries[1]
+	require.Equal(StatementEntryWithdrawal, e.Type)
+	require.Equal(req.Id, e.RequestId)
+	require.Equal("0.0006", e.Amount.String())
+	require.Equal("0.00001", e.Fee.String())
+	require.Equal("0.00005", e.AccountantFee.String())
+	require.Equal("0.0006", e.Burned.String())
+	require.Equal("0.00089", e.Balance.String())
+	require.Equal("0.0005", s.Totals.Deposits.String())
+	require.Equal("0.0005", s.Totals.Minted.String())
+	require.Equal("0.0006", s.Totals.Withdrawals.String())
+	require.True(s.Reconciliation.MintDifference.IsZero())
+	require.True(s.Reconciliation.BurnDifference.IsZero())
+	require.Nil(s.Reconciliation.BalanceDifference)
+
+	var buf bytes.Buffer
+	err = WriteStatementsCSV(&buf, statements)
+	require.Nil(err)
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.Nil(err)
+	require.Len(rows, 5)
+	require.Equal(statementCSVHeader, rows[0])
+	require.Equal("opening", rows[1][4])
+	require.Equal("0.001", rows[1][12])
+	require.Equal("closing", rows[4][4])
+	require.Equal("-0.0001", rows[4][7])
+	require.Equal("0.00089", rows[4][12])
+
+	from, to, err = ParseStatementPeriod("2024-06-05", "2024-06-10T00:00:00Z")
+	require.Nil(err)
+	statements, err = BuildStatements(ctx, kd, db, testStatementHolder, from, to)
+	require.Nil(err)
+	require.Len(statements[0].Entries, 1)
+	require.Equal("0.0015", statements[0].Closing.String())
+	_, _, err = ParseStatementPeriod("2024-06", "2024-05")
+	require.NotNil(err)
+	_, _, err = ParseStatementPeriod("June", "")
+	require.NotNil(err)
+}
@@ -61,6 +61,17 @@ CREATE TABLE IF NOT EXISTS accounts (
 
 
 
+CREATE TABLE IF NOT EXISTS account_signatures (
+  address       VARCHAR NOT NULL,
+  public_key    VARCHAR NOT NULL,
+  signature     VARCHAR NOT NULL,
+  created_at    TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address', 'public_key')
+);
+
+
+
+
 CREATE TABLE IF NOT EXISTS deposits (
   transaction_hash   VARCHAR NOT NULL,
   output_index       VARCHAR NOT NULL,
@@ -139,6 +150,22 @@ CREATE TABLE IF NOT EXISTS recoveries (
 
 
 
+CREATE TABLE IF NOT EXISTS consolidations (
+  address            VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  holder             VARCHAR NOT NULL,
+  request_id         VARCHAR NOT NULL,
+  signature          VARCHAR NOT NULL,
+  proposed_at        TIMESTAMP,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address')
+);
+
+CREATE INDEX IF NOT EXISTS consolidations_by_chain_created ON consolidations(chain, created_at);
+
+
+
 CREATE TABLE IF NOT EXISTS nodes (
   app_id             VARCHAR NOT NULL,
   node_type          VARCHAR NOT NULL,
@@ -146,3 +173,93 @@ CREATE TABLE IF NOT EXISTS nodes (
   updated_at         TIMESTAMP NOT NULL,
   PRIMARY KEY ('app_id', 'node_type')
 );
+
+
+
+CREATE TABLE IF NOT EXISTS leases (
+  name               VARCHAR NOT NULL,
+  holder             VARCHAR NOT NULL,
+  expires_at         INTEGER NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('name')
+);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_timelocks (
+  address            VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  holder             VARCHAR NOT NULL,
+  active_at          TIMESTAMP,
+  recovery_at        TIMESTAMP,
+  alerted_lead       INTEGER,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('address')
+);
+
+CREATE INDEX IF NOT EXISTS safe_timelocks_by_recovery ON safe_timelocks(recovery_at);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS transaction_batches (
+  batch_id           VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  holder             VARCHAR NOT NULL,
+  transactions       VARCHAR NOT NULL,
+  signature          VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('batch_id')
+);
+
+CREATE INDEX IF NOT EXISTS transaction_batches_by_chain_state_created ON transaction_batches(chain, state, created_at);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS safe_messages (
+  message_id         VARCHAR NOT NULL,
+  chain              INTEGER NOT NULL,
+  holder             VARCHAR NOT NULL,
+  message_hash       VARCHAR NOT NULL,
+  message            TEXT NOT NULL,
+  signature          TEXT NOT NULL,
+  proof              TEXT,
+  state              INTEGER NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('message_id')
+);
+
+CREATE INDEX IF NOT EXISTS safe_messages_by_chain_state_created ON safe_messages(chain, state, created_at);
+
+
+
+
+
+CREATE TABLE IF NOT EXISTS unconfirmed_deposits (
+  transaction_hash   VARCHAR NOT NULL,
+  output_index       INTEGER NOT NULL,
+  chain              INTEGER NOT NULL,
+  asset_id           VARCHAR NOT NULL,
+  amount             VARCHAR NOT NULL,
+  receiver           VARCHAR NOT NULL,
+  holder             VARCHAR NOT NULL,
+  spent              VARCHAR NOT NULL,
+  state              INTEGER NOT NULL,
+  replaced_by        VARCHAR NOT NULL,
+  fee_rate           INTEGER NOT NULL,
+  expected_at        TIMESTAMP NOT NULL,
+  created_at         TIMESTAMP NOT NULL,
+  updated_at         TIMESTAMP NOT NULL,
+  PRIMARY KEY ('transaction_hash', 'output_index')
+);
+
+CREATE INDEX IF NOT EXISTS unconfirmed_deposits_by_chain_state ON unconfirmed_deposits(chain, state);
+CREATE INDEX IF NOT EXISTS unconfirmed_deposits_by_receiver_updated ON unconfirmed_deposits(receiver, updated_at);
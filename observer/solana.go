@@ -0,0 +1,644 @@
+package observer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	solanaKeygenRequestTimeKey = "solana-keygen-request-time"
+
+	// the observer pays the safe account rent and all transaction fees
+	solanaFeePayerMinimum = 10000000
+)
+
+func (node *Node) solanaParams() (string, string) {
+	return node.conf.SolanaRPC, common.SafeSolanaChainId
+}
+
+func (node *Node) solanaDummyHolder() string {
+	seed := common.DecodeHexOrPanic(bitcoinKeyDummyHolderPrivate)
+	priv := ed25519.NewKeyFromSeed(seed)
+	return hex.EncodeToString(priv.Public().(ed25519.PublicKey))
+}
+
+func (node *Node) solanaFeePayer() ed25519.PrivateKey {
+	seed := common.DecodeHexOrPanic(node.conf.SolanaKey)
+	return ed25519.NewKeyFromSeed(seed)
+}
+
+// solanaSendSafeTransaction builds the transaction of the fully signed safe
+// transaction with the durable nonce, so it never expires before included
+func (node *Node) solanaSendSafeTransaction(ctx context.Context, safe, raw string) (string, error) {
+	st, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(raw))
+	if err != nil {
+		return "", err
+	}
+	sf, err := node.keeperStore.ReadSafeByAddress(ctx, safe)
+	if err != nil || sf == nil {
+		return "", fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v %v", safe, sf, err)
+	}
+	holders, _ := node.readSafeHolderKeys(ctx, sf)
+	owners := solana.GetSafeOwnersWithHolders(holders, sf.Signer, sf.Observer)
+
+	rpc, _ := node.solanaParams()
+	payer := node.solanaFeePayer()
+	nonceAccount := solana.MustPublicKeyFromBase58(node.conf.SolanaNonceAccount)
+	authority, nonce, err := solana.RPCGetNonceAccount(rpc, nonceAccount.String())
+	logger.Printf("solana.RPCGetNonceAccount(%s) => %s %s %v", nonceAccount, authority, nonce, err)
+	if err != nil {
+		return "", err
+	}
+	if authority != solana.PublicKey(payer.Public().(ed25519.PublicKey)) {
+		panic(fmt.Errorf("invalid solana nonce account authority %s", authority))
+	}
+	tx, err := st.BuildTransaction(owners, authority, nonceAccount, nonce)
+	logger.Printf("solana.BuildTransaction(%s, %v) => %v", st.TxHash, owners, err)
+	if err != nil {
+		return "", err
+	}
+	err = tx.Sign(payer)
+	if err != nil {
+		return "", err
+	}
+
+	err = node.checkLeader()
+	if err != nil {
+		return "", err
+	}
+	hash, err := solana.RPCSendTransaction(rpc, tx)
+	logger.Printf("solana.RPCSendTransaction(%s) => %s %v", st.TxHash, hash, err)
+	return hash, err
+}
+
+func (node *Node) deploySolanaSafeAccount(ctx context.Context, data []byte) error {
+	logger.Printf("node.deploySolanaSafeAccount(%x)", data)
+	sa, err := solana.UnmarshalSafeAccount(data)
+	if err != nil {
+		return fmt.Errorf("solana.UnmarshalSafeAccount(%x) => %v", data, err)
+	}
+	sp, err := node.keeperStore.ReadSafeProposalByAddress(ctx, sa.Address)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeProposalByAddress(%s) => %v", sa.Address, err)
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, sp.Holder)
+	if err != nil || safe == nil || safe.State != common.RequestStateDone {
+		return fmt.Errorf("keeperStore.ReadSafe(%s) => %v %v", sa.Address, safe, err)
+	}
+	_, solanaAssetId := node.solanaParams()
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, solanaAssetId, "", sp.Holder, sp.Address)
+	logger.Printf("node.checkOrDeployKeeperBond(%s, %s) => %v", solanaAssetId, sp.Holder, err)
+	if err != nil {
+		return err
+	}
+
+	rpc, _ := node.solanaParams()
+	account, _, err := solana.RPCGetAccountInfo(rpc, sa.Config)
+	logger.Printf("solana.RPCGetAccountInfo(%s) => %v %v", sa.Config, account, err)
+	if err != nil {
+		return err
+	}
+	if account == nil {
+		tx, err := node.keeperStore.ReadTransaction(ctx, sa.TxHash)
+		if err != nil || tx == nil {
+			return fmt.Errorf("keeperStore.ReadTransaction(%s) => %v %v", sa.TxHash, tx, err)
+		}
+		_, err = node.solanaSendSafeTransaction(ctx, safe.Address, tx.RawTransaction)
+		if err != nil {
+			return err
+		}
+	} else if account.Owner != solana.SafeProgramId().String() {
+		panic(fmt.Errorf("malicious solana safe config %s %s", sa.Config, account.Owner))
+	}
+
+	err = node.store.MarkAccountDeployed(ctx, safe.Address)
+	logger.Printf("store.MarkAccountDeployed(%s) => %v", safe.Address, err)
+	return err
+}
+
+func (node *Node) solanaNetworkInfoLoop(ctx context.Context) {
+	rpc, assetId := node.solanaParams()
+	chain := byte(common.SafeChainSolana)
+
+	for {
+		time.Sleep(depositNetworkInfoDelay)
+		slot, err := solana.RPCGetSlot(rpc)
+		if err != nil {
+			logger.Printf("solana.RPCGetSlot() => %v", err)
+			continue
+		}
+		info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, chain, time.Now())
+		if err != nil {
+			panic(err)
+		}
+		if info != nil && info.Height > slot {
+			logger.Printf("node.keeperStore.ReadLatestNetworkInfo(%d) => %v %d", chain, info, slot)
+			continue
+		}
+		block, err := solana.RPCGetBlock(rpc, slot)
+		if err != nil || block == nil {
+			logger.Printf("solana.RPCGetBlock(%d) => %v %v", slot, block, err)
+			continue
+		}
+		hash, err := solana.HashFromBase58(block.Blockhash)
+		if err != nil {
+			panic(err)
+		}
+		// the safe transactions are paid by the observer, so the fee is only
+		// the lamports per signature for reference
+		fee := uint64(5000)
+		extra := []byte{chain}
+		extra = binary.BigEndian.AppendUint64(extra, fee)
+		extra = binary.BigEndian.AppendUint64(extra, slot)
+		extra = append(extra, hash[:]...)
+		id := common.UniqueId(assetId, fmt.Sprintf("%s:%d", block.Blockhash, slot))
+		id = common.UniqueId(id, fmt.Sprintf("%d:%d", time.Now().UnixNano(), fee))
+		logger.Printf("node.solanaNetworkInfoLoop(%d) => %d %d %s %s", chain, slot, fee, block.Blockhash, id)
+
+		dummy := node.solanaDummyHolder()
+		action := common.ActionObserverUpdateNetworkStatus
+		err = node.sendKeeperResponse(ctx, dummy, byte(action), chain, id, extra)
+		logger.Verbosef("node.sendKeeperResponse(%d, %s, %x) => %v", chain, id, extra, err)
+	}
+}
+
+func (node *Node) solanaRPCBlocksLoop(ctx context.Context) {
+	rpc, _ := node.solanaParams()
+	chain := byte(common.SafeChainSolana)
+
+	for {
+		checkpoint, err := node.readDepositCheckpoint(ctx, chain)
+		if err != nil {
+			panic(err)
+		}
+		slot, err := solana.RPCGetSlot(rpc)
+		if err != nil {
+			logger.Printf("solana.RPCGetSlot() => %v", err)
+			time.Sleep(time.Second * 5)
+			continue
+		}
+		// no solana safe exists before the first boot of the observer
+		if checkpoint == 0 {
+			checkpoint = int64(slot)
+		}
+		logger.Printf("node.solanaReadDepositCheckpoint(%d) => %d %d", chain, checkpoint, slot)
+		if uint64(checkpoint) > slot {
+			time.Sleep(solana.BlockDuration * 4)
+			continue
+		}
+		err = node.solanaReadBlock(ctx, uint64(checkpoint))
+		logger.Printf("node.solanaReadBlock(%d) => %v", checkpoint, err)
+		if err != nil {
+			time.Sleep(time.Second * 5)
+			continue
+		}
+
+		err = node.store.WriteProperty(ctx, depositCheckpointKey(chain), fmt.Sprint(checkpoint+1))
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (node *Node) solanaReadBlock(ctx context.Context, slot uint64) error {
+	rpc, _ := node.solanaParams()
+	block, err := solana.RPCGetBlock(rpc, slot)
+	if err != nil || block == nil {
+		return err
+	}
+	for _, tx := range block.Transactions {
+		for _, t := range solana.ExtractTransfers(tx) {
+			err := node.solanaWritePendingDeposit(ctx, t)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+	return nil
+}
+
+func (node *Node) solanaWritePendingDeposit(ctx context.Context, transfer *solana.Transfer) error {
+	safe, err := node.keeperStore.ReadSafeByAddress(ctx, transfer.Receiver)
+	logger.Verbosef("keeperStore.ReadSafeByAddress(%s, %s) => %v %v", transfer.Hash, transfer.Receiver, safe, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadSafeByAddress(%s) => %v", transfer.Receiver, err)
+	} else if safe == nil || safe.Chain != common.SafeChainSolana {
+		return nil
+	}
+	old, err := node.keeperStore.ReadDeposit(ctx, transfer.Hash, transfer.Index)
+	logger.Printf("keeperStore.ReadDeposit(%s, %d, %s, %s) => %v %v", transfer.Hash, transfer.Index, transfer.AssetId, transfer.Receiver, old, err)
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadDeposit(%s, %d, %s, %s) => %v %v", transfer.Hash, transfer.Index, transfer.AssetId, transfer.Receiver, old, err)
+	} else if old != nil {
+		return nil
+	}
+
+	asset, err := node.fetchAssetMeta(ctx, transfer.AssetId)
+	logger.Printf("node.fetchAssetMeta(%s, %s) => %v %v", transfer.Hash, transfer.AssetId, asset, err)
+	if err != nil || asset == nil {
+		return err
+	}
+
+	_, err = node.checkOrDeployKeeperBond(ctx, safe.Chain, transfer.AssetId, transfer.TokenAddress, safe.Holder, safe.Address)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", safe.Holder, err)
+	}
+
+	var amount decimal.Decimal
+	rpc, chainAssetId := node.solanaParams()
+	switch transfer.AssetId {
+	case chainAssetId:
+		amount = decimal.NewFromBigInt(transfer.Value, -solana.ValuePrecision)
+	default:
+		token, err := solana.FetchAsset(rpc, transfer.TokenAddress)
+		logger.Printf("solana.FetchAsset(%s, %s) => %v %v", transfer.Hash, transfer.TokenAddress, token, err)
+		if err != nil {
+			return nil
+		}
+		amount = decimal.NewFromBigInt(transfer.Value, -int32(token.Decimals))
+	}
+
+	id := common.UniqueId(transfer.AssetId, safe.Holder)
+	id = common.UniqueId(id, fmt.Sprintf("%s:%d", transfer.Hash, transfer.Index))
+	createdAt := time.Now().UTC()
+	deposit := &Deposit{
+		TransactionHash: transfer.Hash,
+		OutputIndex:     transfer.Index,
+		AssetId:         transfer.AssetId,
+		AssetAddress:    transfer.TokenAddress,
+		Amount:          amount.String(),
+		Receiver:        transfer.Receiver,
+		Sender:          transfer.Sender,
+		Holder:          safe.Holder,
+		Category:        common.ActionObserverHolderDeposit,
+		State:           common.RequestStateInitial,
+		Chain:           safe.Chain,
+		RequestId:       id,
+		CreatedAt:       createdAt,
+		UpdatedAt:       createdAt,
+	}
+
+	err = node.store.WritePendingDepositIfNotExists(ctx, deposit)
+	if err != nil {
+		return fmt.Errorf("store.WritePendingDeposit(%v) => %v", deposit, err)
+	}
+	return nil
+}
+
+func (node *Node) solanaConfirmPendingDeposit(ctx context.Context, deposit *Deposit) error {
+	rpc, solanaAssetId := node.solanaParams()
+
+	asset, err := node.store.ReadAssetMeta(ctx, deposit.AssetId)
+	if err != nil || asset == nil {
+		return err
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, deposit.Holder)
+	if err != nil || safe == nil {
+		return err
+	}
+	bonded, err := node.checkOrDeployKeeperBond(ctx, deposit.Chain, deposit.AssetId, deposit.AssetAddress, deposit.Holder, safe.Address)
+	if err != nil {
+		return fmt.Errorf("node.checkOrDeployKeeperBond(%s) => %v", deposit.Holder, err)
+	} else if !bonded {
+		return nil
+	}
+	decimals := int32(solana.ValuePrecision)
+	switch asset.AssetId {
+	case solanaAssetId:
+	default:
+		decimals = int32(asset.Decimals)
+	}
+
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, deposit.Chain, time.Now())
+	if err != nil {
+		return fmt.Errorf("keeperStore.ReadLatestNetworkInfo(%d) => %v", deposit.Chain, err)
+	} else if info == nil {
+		return nil
+	}
+	if info.CreatedAt.After(time.Now()) {
+		panic(fmt.Errorf("malicious solana network info %v", info))
+	}
+
+	match, stx, err := solana.VerifyDeposit(rpc, deposit.TransactionHash, deposit.AssetAddress, deposit.Receiver, deposit.OutputIndex, deposit.bigAmount(decimals))
+	if err != nil {
+		panic(err)
+	}
+	if match == nil {
+		panic(fmt.Errorf("malicious solana deposit %s", deposit.TransactionHash))
+	}
+	confirmations := info.Height - stx.Slot + 1
+	if info.Height < stx.Slot {
+		confirmations = 0
+	}
+	if !solana.CheckFinalization(confirmations) {
+		return nil
+	}
+
+	return node.sendKeeperDepositTransaction(ctx, deposit, decimals)
+}
+
+// writeSolanaDepositStorage writes the solana transaction signature and the
+// token mint to the storage, because they exceed the keeper request extra size
+func (node *Node) writeSolanaDepositStorage(ctx context.Context, deposit *Deposit, decimals int32) ([]byte, []crypto.Hash, error) {
+	sig, err := solana.DecodeSignature(deposit.TransactionHash)
+	if err != nil {
+		panic(deposit.TransactionHash)
+	}
+	mint := solana.MustPublicKeyFromBase58(deposit.AssetAddress)
+	raw := append(sig, mint[:]...)
+
+	rawId := common.UniqueId(deposit.RequestId, hex.EncodeToString(raw))
+	raw = append(uuid.Must(uuid.FromString(rawId)).Bytes(), raw...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
+	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	extra := []byte{deposit.Chain}
+	extra = append(extra, uuid.Must(uuid.FromString(deposit.AssetId)).Bytes()...)
+	extra = append(extra, ref[:]...)
+	extra = binary.BigEndian.AppendUint64(extra, uint64(deposit.OutputIndex))
+	extra = append(extra, deposit.bigAmount(decimals).Bytes()...)
+	return extra, []crypto.Hash{ref}, nil
+}
+
+func (node *Node) solanaDepositConfirmLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		deposits, err := node.store.ListDeposits(ctx, common.SafeChainSolana, "", common.RequestStateInitial, 0)
+		if err != nil {
+			panic(err)
+		}
+		for _, d := range deposits {
+			err := node.solanaConfirmPendingDeposit(ctx, d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) solanaTransactionApprovalLoop(ctx context.Context) {
+	for {
+		time.Sleep(3 * time.Second)
+		approvals, err := node.store.ListPendingTransactionApprovals(ctx, common.SafeChainSolana)
+		if err != nil {
+			panic(err)
+		}
+		for _, approval := range approvals {
+			err := node.sendToKeeperSolanaApproveTransaction(ctx, approval)
+			logger.Verbosef("node.sendToKeeperSolanaApproveTransaction(%v) => %v", approval, err)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) sendToKeeperSolanaApproveTransaction(ctx context.Context, approval *Transaction) error {
+	signed, err := node.ethereumCheckKeeperSignedTransaction(ctx, approval)
+	logger.Printf("node.ethereumCheckKeeperSignedTransaction(%v) => %t %v", approval, signed, err)
+	if err != nil || signed {
+		return err
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if !node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
+		panic(approval.RawTransaction)
+	}
+	locked, err := node.checkSafeOutflowLocked(ctx, approval.TransactionHash)
+	if err != nil || locked {
+		return err
+	}
+
+	rawId := common.UniqueId(approval.RawTransaction, approval.RawTransaction)
+	raw := common.DecodeHexOrPanic(approval.RawTransaction)
+	raw = append(uuid.Must(uuid.FromString(rawId)).Bytes(), raw...)
+	raw = common.AESEncrypt(node.aesKey[:], raw, rawId)
+	msg := base64.RawURLEncoding.EncodeToString(raw)
+	traceId := common.UniqueId(msg, msg)
+	ref, err := node.writeStorageUntilSufficient(ctx, raw, traceId)
+	logger.Printf("WriteStorageUntilSufficient(%s) => %s %v", traceId, ref, err)
+	if err != nil {
+		return err
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, approval.TransactionHash)
+	if err != nil {
+		return err
+	}
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), ref[:]...)
+	references := []crypto.Hash{ref}
+	action := common.ActionSolanaSafeApproveTransaction
+	err = node.sendKeeperResponseWithReferences(ctx, tx.Holder, byte(action), approval.Chain, id, extra, references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %x, %s)", tx.Holder, action, id, extra, ref)
+	if err != nil {
+		return err
+	}
+
+	if approval.UpdatedAt.Add(keeper.SafeSignatureTimeout).After(time.Now()) {
+		return nil
+	}
+	id = common.UniqueId(id, approval.UpdatedAt.String())
+	err = node.sendKeeperResponseWithReferences(ctx, tx.Holder, byte(action), approval.Chain, id, extra, references)
+	logger.Printf("node.sendKeeperResponseWithReferences(%s, %d, %s, %x, %s)", tx.Holder, action, id, extra, ref)
+	if err != nil {
+		return err
+	}
+	return node.store.UpdateTransactionApprovalRequestTime(ctx, approval.TransactionHash)
+}
+
+func (node *Node) keeperVerifySolanaTransactionSignatures(ctx context.Context, extra []byte) error {
+	logger.Printf("node.keeperVerifySolanaTransactionSignatures(%x)", extra)
+	st, err := solana.UnmarshalSafeTransaction(extra)
+	if err != nil {
+		return err
+	}
+	raw := hex.EncodeToString(st.Marshal())
+
+	tx, err := node.store.ReadTransactionApproval(ctx, st.TxHash)
+	if err != nil || tx.State >= common.RequestStateDone {
+		return err
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, tx.Holder)
+	if err != nil {
+		return err
+	}
+	if safe.Chain != common.SafeChainSolana {
+		panic(st.TxHash)
+	}
+
+	holders, threshold := node.readSafeHolderKeys(ctx, safe)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	// the holders quorum and the signer make the two votes the program requires
+	if !solana.CheckTransactionPartiallySignedByHolders(raw, owners, holders, threshold) {
+		return fmt.Errorf("Solana safe transaction %v has insufficient holder signatures", st)
+	}
+	if !solana.CheckTransactionPartiallySignedBy(raw, owners, safe.Signer) {
+		return fmt.Errorf("Solana safe transaction %v has no signer signature", st)
+	}
+
+	err = node.store.FinishTransactionSignatures(ctx, st.TxHash, raw)
+	logger.Printf("store.FinishTransactionSignatures(%s) => %v", st.TxHash, err)
+	return err
+}
+
+func (node *Node) solanaTransactionSpendLoop(ctx context.Context) {
+	rpc, _ := node.solanaParams()
+	payer := solana.PublicKey(node.solanaFeePayer().Public().(ed25519.PublicKey))
+
+	for {
+		time.Sleep(3 * time.Second)
+		txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainSolana)
+		if err != nil {
+			panic(err)
+		}
+		for _, tx := range txs {
+			b, err := solana.RPCGetBalance(rpc, payer.String())
+			if err != nil || b <= solanaFeePayerMinimum {
+				logger.Verbosef("solana.RPCGetBalance(%s) => %d, %v", payer, b, err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+
+			approval, err := node.keeperStore.ReadTransaction(ctx, tx.TransactionHash)
+			if err != nil || approval == nil {
+				panic(fmt.Errorf("keeperStore.ReadTransaction(%s) => %v %v", tx.TransactionHash, approval, err))
+			}
+			safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+			if err != nil || safe == nil {
+				panic(fmt.Errorf("keeperStore.ReadSafe(%s) => %v %v", approval.Holder, safe, err))
+			}
+			spentHash, err := node.solanaSendSafeTransaction(ctx, safe.Address, tx.RawTransaction)
+			logger.Verbosef("node.solanaSendSafeTransaction(%v) => %v %v", tx, spentHash, err)
+			if err != nil {
+				break
+			}
+			err = node.store.ConfirmFullySignedTransactionApproval(ctx, tx.TransactionHash, spentHash, tx.RawTransaction)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+func (node *Node) httpApproveSolanaTransaction(ctx context.Context, raw string) error {
+	logger.Printf("node.httpApproveSolanaTransaction(%s)", raw)
+	rb, _ := hex.DecodeString(raw)
+	st, err := solana.UnmarshalSafeTransaction(rb)
+	if err != nil {
+		return err
+	}
+
+	approval, err := node.store.ReadTransactionApproval(ctx, st.TxHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", st.TxHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	logger.Printf("store.ReadSafe(%s) => %v %v", approval.Holder, safe, err)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
+		return nil
+	}
+
+	// collect the signatures of the holder keys until the threshold reached
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	stored, _ := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	merged := stored.MergeSignatures(st, owners, holders)
+	logger.Printf("solana.MergeSignatures(%s, %v) => %d", st.TxHash, holders, merged)
+	if merged == 0 {
+		return nil
+	}
+	raw = hex.EncodeToString(stored.Marshal())
+
+	err = node.store.AddTransactionPartials(ctx, st.TxHash, raw)
+	logger.Printf("store.AddTransactionPartials(%s) => %v", st.TxHash, err)
+	return err
+}
+
+func (node *Node) httpRevokeSolanaTransaction(ctx context.Context, txHash string, sigHex string) error {
+	logger.Printf("node.httpRevokeSolanaTransaction(%s, %s)", txHash, sigHex)
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	logger.Verbosef("store.ReadTransactionApproval(%s) => %v %v", txHash, approval, err)
+	if err != nil || approval == nil {
+		return err
+	}
+	if approval.State != common.RequestStateInitial {
+		return nil
+	}
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	if err != nil {
+		return err
+	}
+	if node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction) {
+		return nil
+	}
+
+	tx, err := node.keeperStore.ReadTransaction(ctx, txHash)
+	logger.Verbosef("keeperStore.ReadTransaction(%s) => %v %v", txHash, tx, err)
+	if err != nil {
+		return err
+	}
+
+	sig, err := hex.DecodeString(sigHex)
+	if err != nil {
+		return err
+	}
+	msg := []byte(fmt.Sprintf("REVOKE:%s:%s", tx.RequestId, tx.TransactionHash))
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	signed := slices.ContainsFunc(holders, func(h string) bool {
+		return solana.VerifyMessageSignature(h, msg, sig) == nil
+	})
+	logger.Printf("holder: solana.VerifyMessageSignature(%v) => %t", tx, signed)
+	if !signed {
+		err = solana.VerifyMessageSignature(safe.Observer, msg, sig)
+		logger.Printf("observer: solana.VerifyMessageSignature(%v) => %v", tx, err)
+		if err != nil {
+			return err
+		}
+	}
+
+	id := common.UniqueId(approval.TransactionHash, approval.TransactionHash)
+	rid := uuid.Must(uuid.FromString(tx.RequestId))
+	extra := append(rid.Bytes(), sig...)
+	action := common.ActionSolanaSafeRevokeTransaction
+	err = node.sendKeeperResponse(ctx, tx.Holder, byte(action), approval.Chain, id, extra)
+	logger.Printf("node.sendKeeperResponse(%s, %d, %s, %x)", tx.Holder, action, id, extra)
+	if err != nil {
+		return err
+	}
+
+	err = node.store.RevokeTransactionApproval(ctx, txHash, sigHex+":"+approval.RawTransaction)
+	logger.Printf("store.RevokeTransactionApproval(%s) => %v", txHash, err)
+	return err
+}
@@ -0,0 +1,335 @@
+package observer
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	mc "github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/solana"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/common/abi"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSolanaProgram             = "D3ob8sTZ9hMnVBhLThD1Xd8Su8btV8gY73p7JEuZiAHE"
+	testSolanaKeyHolder           = "4a22ddbae5bda41996d62aed6d0360b53fbc3e2620f5398700776d71424cf376"
+	testSolanaKeySigner           = "063ff3079551538f7e65a021df11204b64316760515178db2d75640e04b0b9e9"
+	testSolanaKeyObserver         = "e3d56734b5bf5af942c63749e81b8917e4412677e088714f3f58092bf003c9a5"
+	testSolanaKeyPayer            = "a975cb317c52306ba5ba63764349b2038b7743c51d703c9592d53e82f9db9a3f"
+	testSolanaKeyDummy            = "f7be911d7ff000817d374c65529e7c0342692c17e4c862c0dafcfabe261b15b7"
+	testSolanaDepositSender       = "66yJPugKJqjkJFN6XnuwgcchawjS7vq57KJgAfN86w9L"
+	testSolanaTransactionReceiver = "14iWFYTAaNttKeLfA1TC1ELri8ajMgULJ457aQ8z1gLR"
+	testSolanaDepositLamports     = 1000000
+	testSolanaTransferLamports    = 100000
+)
+
+func TestObserverSolana(t *testing.T) {
+	logger.SetLevel(logger.VERBOSE)
+	ctx := context.Background()
+	ctx = common.EnableTestEnvironment(ctx)
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "safe-observer-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root)
+	require.NotNil(node)
+	kd, err := store.OpenSQLite3Store(root + "/keeper.sqlite3")
+	require.Nil(err)
+	defer kd.Close()
+
+	testSolanaPrepareFeePayer(require, node)
+	sa := testSolanaProposeAccount(ctx, require, node, kd)
+	testSolanaDeployAccount(ctx, require, node, kd, sa)
+	testSolanaDeposit(ctx, require, node, sa)
+
+	txHash := testSolanaProposeTransaction(ctx, require, node, kd, sa)
+	testSolanaApproveTransaction(ctx, require, node, txHash)
+	testSolanaSpendTransaction(ctx, require, node, sa, txHash)
+}
+
+func testSolanaPrepareFeePayer(require *require.Assertions, node *Node) {
+	payer := solana.PublicKey(node.solanaFeePayer().Public().(ed25519.PublicKey)).String()
+	require.Equal(testSolanaAddress(testSolanaKeyPayer), payer)
+	testNetwork.solana.Deposit(testSolanaDepositSender, payer, 100000000)
+	testNetwork.solana.Mine(1)
+	balance, err := solana.RPCGetBalance(node.conf.SolanaRPC, payer)
+	require.Nil(err)
+	require.Greater(balance, uint64(solanaFeePayerMinimum))
+}
+
+// testSolanaProposeAccount writes the safe proposal and the create transaction
+// signed by the holder and signer to the keeper store, as the keeper does
+// after the safe account approved
+func testSolanaProposeAccount(ctx context.Context, require *require.Assertions, node *Node, kd *store.SQLite3Store) *solana.SafeAccount {
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	signer := testSolanaPublicKey(testSolanaKeySigner)
+	observer := testSolanaPublicKey(testSolanaKeyObserver)
+	req := testSolanaWriteRequest(ctx, require, kd, common.ActionSolanaSafeProposeAccount, holder)
+
+	sa, st, err := solana.BuildSafeAccountWithHolders([]string{holder}, 1, signer, observer, req.Id, solana.TimeLockMinimum)
+	require.Nil(err)
+	st.SetSignature(0, testSolanaSignMessage(testSolanaKeyHolder, st.Message))
+	st.SetSignature(1, testSolanaSignMessage(testSolanaKeySigner, st.Message))
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     common.SafeChainSolana,
+		Holder:    holder,
+		Signer:    signer,
+		Observer:  observer,
+		Timelock:  solana.TimeLockMinimum,
+		Address:   sa.Address,
+		Extra:     sa.Marshal(),
+		Receivers: []string{holder},
+		Threshold: 1,
+		CreatedAt: req.CreatedAt,
+		UpdatedAt: req.CreatedAt,
+	}
+	holders := &store.SafeHolders{
+		RequestId: req.Id,
+		Holder:    holder,
+		Chain:     common.SafeChainSolana,
+		Address:   sa.Address,
+		Keys:      []string{holder},
+		Threshold: 1,
+		CreatedAt: req.CreatedAt,
+	}
+	tx := &store.Transaction{
+		TransactionHash: st.TxHash,
+		RawTransaction:  hex.EncodeToString(st.Marshal()),
+		Holder:          holder,
+		Chain:           common.SafeChainSolana,
+		AssetId:         common.SafeSolanaChainId,
+		State:           common.RequestStateDone,
+		Data:            "",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = kd.WriteEthereumSafeProposalWithRequest(ctx, sp, holders, nil, tx, nil, req)
+	require.Nil(err)
+
+	abi.InitFactoryContractAddress(node.conf.PolygonFactoryAddress)
+	testNetwork.mixin.RegisterBond(node.conf.PolygonKeeperDepositEntry, common.SafeSolanaChainId, holder)
+	err = node.keeperSaveAccountProposal(ctx, common.SafeChainSolana, sa.Marshal(), req.CreatedAt)
+	require.Nil(err)
+	proposed, err := node.store.CheckAccountProposed(ctx, sa.Address)
+	require.Nil(err)
+	require.True(proposed)
+
+	req = testSolanaWriteRequest(ctx, require, kd, common.ActionSolanaSafeApproveAccount, holder)
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       common.RequestStateDone,
+		Nonce:       1,
+		SafeAssetId: common.SafeSolanaChainId,
+		CreatedAt:   req.CreatedAt,
+		UpdatedAt:   req.CreatedAt,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+	return sa
+}
+
+func testSolanaDeployAccount(ctx context.Context, require *require.Assertions, node *Node, kd *store.SQLite3Store, sa *solana.SafeAccount) {
+	require.Equal(int64(-1), testNetwork.solana.SafeNonce(sa.Config))
+	err := node.deploySolanaSafeAccount(ctx, sa.Marshal())
+	require.Nil(err)
+	testNetwork.solana.Mine(1)
+	require.Equal(int64(1), testNetwork.solana.SafeNonce(sa.Config))
+	account, err := node.store.ReadAccount(ctx, sa.Address)
+	require.Nil(err)
+	require.True(account.DeployedAt.Valid)
+
+	safe, err := kd.ReadSafeByAddress(ctx, sa.Address)
+	require.Nil(err)
+	require.Equal(safe.Nonce, testNetwork.solana.SafeNonce(sa.Config))
+	err = node.deploySolanaSafeAccount(ctx, sa.Marshal())
+	require.Nil(err)
+}
+
+func testSolanaDeposit(ctx context.Context, require *require.Assertions, node *Node, sa *solana.SafeAccount) {
+	hash := testNetwork.solana.Deposit(testSolanaDepositSender, sa.Address, testSolanaDepositLamports)
+	testNetwork.solana.Mine(1)
+	tip := testNetwork.solana.Tip()
+	err := node.solanaReadBlock(ctx, tip.Slot)
+	require.Nil(err)
+	err = node.solanaReadBlock(ctx, tip.Slot)
+	require.Nil(err)
+
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	deposits, err := node.store.ListDeposits(ctx, common.SafeChainSolana, holder, common.RequestStateInitial, 0)
+	require.Nil(err)
+	require.Len(deposits, 1)
+	deposit := deposits[0]
+	require.Equal(hash, deposit.TransactionHash)
+	require.Equal(common.SafeSolanaChainId, deposit.AssetId)
+	require.Equal(solana.SolanaEmptyAddress, deposit.AssetAddress)
+	require.Equal(testSolanaDepositSender, deposit.Sender)
+	require.Equal(sa.Address, deposit.Receiver)
+	require.Equal("0.001", deposit.Amount)
+	require.Equal(uint64(testSolanaDepositLamports), testNetwork.solana.Balance(sa.Address))
+}
+
+// testSolanaProposeTransaction writes the transaction proposed by the keeper
+// and saves the observer approval from the keeper response
+func testSolanaProposeTransaction(ctx context.Context, require *require.Assertions, node *Node, kd *store.SQLite3Store, sa *solana.SafeAccount) string {
+	holder := testSolanaPublicKey(testSolanaKeyHolder)
+	req := testSolanaWriteRequest(ctx, require, kd, common.ActionSolanaSafeProposeTransaction, holder)
+	safe, err := kd.ReadSafe(ctx, holder)
+	require.Nil(err)
+
+	outputs := []*solana.Output{{
+		TokenAddress: solana.SolanaEmptyAddress,
+		Destination:  testSolanaTransactionReceiver,
+		Amount:       big.NewInt(testSolanaTransferLamports),
+		Decimals:     solana.ValuePrecision,
+	}}
+	st, err := solana.CreateTransactionFromOutputs(req.Id, sa.Address, sa.Config, outputs, uint64(safe.Nonce))
+	require.Nil(err)
+	holders, _ := node.readSafeHolderKeys(ctx, safe)
+	owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+	st.Signatures = make([][]byte, len(owners))
+	tx := &store.Transaction{
+		TransactionHash: st.TxHash,
+		RawTransaction:  hex.EncodeToString(st.Marshal()),
+		Holder:          holder,
+		Chain:           common.SafeChainSolana,
+		AssetId:         common.SafeSolanaChainId,
+		State:           common.RequestStateInitial,
+		Data:            "",
+		RequestId:       req.Id,
+		CreatedAt:       req.CreatedAt,
+		UpdatedAt:       req.CreatedAt,
+	}
+	err = kd.WriteTransactionWithRequest(ctx, tx, nil, nil, req)
+	require.Nil(err)
+
+	err = node.keeperSaveTransactionProposal(ctx, common.SafeChainSolana, st.Marshal(), req.CreatedAt)
+	require.Nil(err)
+	approval, err := node.store.ReadTransactionApproval(ctx, st.TxHash)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStateInitial), approval.State)
+	require.Equal(holder, approval.Holder)
+	require.Equal(safe.Signer, approval.Signer)
+	require.False(node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction))
+	return st.TxHash
+}
+
+// testSolanaApproveTransaction approves the transaction with the holder
+// signature, then verifies the signer signature from the keeper response
+func testSolanaApproveTransaction(ctx context.Context, require *require.Assertions, node *Node, txHash string) {
+	approval, err := node.store.ReadTransactionApproval(ctx, txHash)
+	require.Nil(err)
+	st, err := solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	require.Nil(err)
+
+	st.SetSignature(1, testSolanaSignMessage(testSolanaKeySigner, st.Message))
+	err = node.keeperVerifySolanaTransactionSignatures(ctx, st.Marshal())
+	require.NotNil(err)
+	st.SetSignature(1, nil)
+	st.SetSignature(0, testSolanaSignMessage(testSolanaKeyDummy, st.Message))
+	err = node.httpApproveSolanaTransaction(ctx, hex.EncodeToString(st.Marshal()))
+	require.Nil(err)
+	approval, err = node.store.ReadTransactionApproval(ctx, txHash)
+	require.Nil(err)
+	safe, err := node.keeperStore.ReadSafe(ctx, approval.Holder)
+	require.Nil(err)
+	require.False(node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction))
+
+	st.SetSignature(0, testSolanaSignMessage(testSolanaKeyHolder, st.Message))
+	err = node.httpApproveSolanaTransaction(ctx, hex.EncodeToString(st.Marshal()))
+	require.Nil(err)
+	approval, err = node.store.ReadTransactionApproval(ctx, txHash)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStateInitial), approval.State)
+	require.True(node.checkTransactionSignedByHolders(ctx, safe, approval.RawTransaction))
+
+	st, err = solana.UnmarshalSafeTransaction(common.DecodeHexOrPanic(approval.RawTransaction))
+	require.Nil(err)
+	st.SetSignature(1, testSolanaSignMessage(testSolanaKeySigner, st.Message))
+	err = node.keeperVerifySolanaTransactionSignatures(ctx, st.Marshal())
+	require.Nil(err)
+	approval, err = node.store.ReadTransactionApproval(ctx, txHash)
+	require.Nil(err)
+	require.Equal(byte(common.RequestStateDone), approval.State)
+}
+
+func testSolanaSpendTransaction(ctx context.Context, require *require.Assertions, node *Node, sa *solana.SafeAccount, txHash string) {
+	txs, err := node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainSolana)
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(txHash, txs[0].TransactionHash)
+
+	spentHash, err := node.solanaSendSafeTransaction(ctx, sa.Address, txs[0].RawTransaction)
+	require.Nil(err)
+	err = node.store.ConfirmFullySignedTransactionApproval(ctx, txHash, spentHash, txs[0].RawTransaction)
+	require.Nil(err)
+	testNetwork.solana.Mine(1)
+	stx, err := solana.RPCGetTransaction(node.conf.SolanaRPC, spentHash)
+	require.Nil(err)
+	require.NotNil(stx)
+
+	txs, err = node.store.ListFullySignedTransactionApprovals(ctx, common.SafeChainSolana)
+	require.Nil(err)
+	require.Len(txs, 0)
+	require.Equal(int64(2), testNetwork.solana.SafeNonce(sa.Config))
+	require.Equal(uint64(testSolanaTransferLamports), testNetwork.solana.Balance(testSolanaTransactionReceiver))
+	require.Equal(uint64(testSolanaDepositLamports-testSolanaTransferLamports), testNetwork.solana.Balance(sa.Address))
+}
+
+func testSolanaWriteRequest(ctx context.Context, require *require.Assertions, kd *store.SQLite3Store, action int, holder string) *common.Request {
+	id := uuid.Must(uuid.NewV4()).String()
+	req := &common.Request{
+		Id:         id,
+		MixinHash:  mc.Sha256Hash([]byte(id)),
+		MixinIndex: 1,
+		AssetId:    common.SafeSolanaChainId,
+		Amount:     decimal.Zero,
+		Role:       common.RequestRoleHolder,
+		Action:     uint8(action),
+		Curve:      common.CurveEdwards25519Solana,
+		Holder:     holder,
+		State:      common.RequestStateInitial,
+		CreatedAt:  time.Now().UTC(),
+		Output:     &mtg.Action{UnifiedOutput: mtg.UnifiedOutput{OutputId: uuid.Must(uuid.NewV4()).String()}},
+	}
+	err := kd.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	return req
+}
+
+func testSolanaPublicKey(priv string) string {
+	key := ed25519.NewKeyFromSeed(common.DecodeHexOrPanic(priv))
+	return hex.EncodeToString(key.Public().(ed25519.PublicKey))
+}
+
+func testSolanaAddress(priv string) string {
+	key := ed25519.NewKeyFromSeed(common.DecodeHexOrPanic(priv))
+	return solana.PublicKey(key.Public().(ed25519.PublicKey)).String()
+}
+
+func testSolanaSignMessage(priv string, msg []byte) []byte {
+	key := ed25519.NewKeyFromSeed(common.DecodeHexOrPanic(priv))
+	return ed25519.Sign(key, msg)
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/MixinNetwork/safe/apps/bitcoin"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/apps/solana"
 	"github.com/MixinNetwork/safe/common"
 	"github.com/MixinNetwork/safe/keeper/store"
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -91,6 +92,17 @@ type Recovery struct {
 	UpdatedAt       time.Time
 }
 
+type Consolidation struct {
+	Address    string
+	Chain      byte
+	Holder     string
+	RequestId  string
+	Signature  string
+	ProposedAt sql.NullTime
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
 type NodeStats struct {
 	AppId     string
 	Type      string
@@ -126,6 +138,12 @@ func (r *Recovery) values() []any {
 	return []any{r.Address, r.Chain, r.Holder, r.Observer, r.RawTransaction, r.TransactionHash, r.State, r.CreatedAt, r.UpdatedAt}
 }
 
+var consolidationCols = []string{"address", "chain", "holder", "request_id", "signature", "proposed_at", "created_at", "updated_at"}
+
+func (c *Consolidation) values() []any {
+	return []any{c.Address, c.Chain, c.Holder, c.RequestId, c.Signature, c.ProposedAt, c.CreatedAt, c.UpdatedAt}
+}
+
 var nodeCols = []string{"app_id", "node_type", "stats", "updated_at"}
 
 func (n *NodeStats) values() []any {
@@ -160,7 +178,7 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 
 	opk, spk := safe.Observer, safe.Signer
 	switch safe.Chain {
-	case common.SafeChainBitcoin, common.SafeChainLitecoin:
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 		var err error
 		opk, err = node.deriveBIP32WithKeeperPath(ctx, safe.Observer, safe.Path)
 		if err != nil {
@@ -176,13 +194,20 @@ func (t *Transaction) Signers(ctx context.Context, node *Node, safe *store.Safe)
 	for idx, pub := range pubs {
 		isSigned := false
 		switch safe.Chain {
-		case common.SafeChainBitcoin, common.SafeChainLitecoin:
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
 			isSigned = bitcoin.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
 		case common.SafeChainPolygon, common.SafeChainEthereum:
 			isSigned = ethereum.CheckTransactionPartiallySignedBy(t.RawTransaction, pub)
+		case common.SafeChainSolana:
+			holders, _ := node.readSafeHolderKeys(ctx, safe)
+			owners := solana.GetSafeOwnersWithHolders(holders, safe.Signer, safe.Observer)
+			isSigned = solana.CheckTransactionPartiallySignedBy(t.RawTransaction, owners, pub)
 		default:
 			panic(safe.Chain)
 		}
+		if idx == 0 {
+			isSigned = node.checkTransactionSignedByHolders(ctx, safe, t.RawTransaction)
+		}
 		if isSigned {
 			switch idx {
 			case 0:
@@ -283,6 +308,50 @@ func (s *SQLite3Store) SaveAccountApprovalSignature(ctx context.Context, addr, s
 	return tx.Commit()
 }
 
+// WriteAccountHolderSignature saves the account approval signature of one
+// holder key, for the account with multiple holder keys
+func (s *SQLite3Store) WriteAccountHolderSignature(ctx context.Context, addr, public, sig string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT created_at FROM account_signatures WHERE address=? AND public_key=?", addr, public)
+	if err != nil || existed {
+		return err
+	}
+
+	cols := []string{"address", "public_key", "signature", "created_at"}
+	err = s.execOne(ctx, tx, buildInsertionSQL("account_signatures", cols), addr, public, sig, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("INSERT account_signatures %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListAccountHolderSignatures(ctx context.Context, addr string) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT public_key,signature FROM account_signatures WHERE address=?", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sigs := make(map[string]string)
+	for rows.Next() {
+		var public, sig string
+		err := rows.Scan(&public, &sig)
+		if err != nil {
+			return nil, err
+		}
+		sigs[public] = sig
+	}
+	return sigs, nil
+}
+
 func (s *SQLite3Store) MarkAccountApproved(ctx context.Context, addr string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -640,6 +709,25 @@ func (s *SQLite3Store) RevokeTransactionApproval(ctx context.Context, transactio
 	return tx.Commit()
 }
 
+func (s *SQLite3Store) ExpireTransactionApproval(ctx context.Context, transactionHash string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE transactions SET state=?, updated_at=? WHERE transaction_hash=? AND state=?",
+		common.RequestStateFailed, time.Now().UTC(), transactionHash, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE transactions %v", err)
+	}
+
+	return tx.Commit()
+}
+
 func (s *SQLite3Store) AddTransactionPartials(ctx context.Context, transactionHash string, raw string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -924,6 +1012,84 @@ func (s *SQLite3Store) ListInitialRecoveries(ctx context.Context, offset int64)
 	return recoveries, nil
 }
 
+// WriteConsolidation replaces the previous consolidation authorisation of
+// the safe, each authorisation could only be proposed once with its request id
+func (s *SQLite3Store) WriteConsolidation(ctx context.Context, c *Consolidation) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT created_at FROM consolidations WHERE address=? AND request_id=?", c.Address, c.RequestId)
+	if err != nil || existed {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM consolidations WHERE address=?", c.Address)
+	if err != nil {
+		return fmt.Errorf("DELETE consolidations %v", err)
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("consolidations", consolidationCols), c.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT consolidations %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) MarkConsolidationProposed(ctx context.Context, address string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	now := time.Now().UTC()
+	err = s.execOne(ctx, tx, "UPDATE consolidations SET proposed_at=?, updated_at=? WHERE address=?", now, now, address)
+	if err != nil {
+		return fmt.Errorf("UPDATE consolidations %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadConsolidation(ctx context.Context, address string) (*Consolidation, error) {
+	query := fmt.Sprintf("SELECT %s FROM consolidations WHERE address=?", strings.Join(consolidationCols, ","))
+	row := s.db.QueryRowContext(ctx, query, address)
+
+	var c Consolidation
+	err := row.Scan(&c.Address, &c.Chain, &c.Holder, &c.RequestId, &c.Signature, &c.ProposedAt, &c.CreatedAt, &c.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &c, err
+}
+
+func (s *SQLite3Store) ListConsolidations(ctx context.Context, chain byte) ([]*Consolidation, error) {
+	query := fmt.Sprintf("SELECT %s FROM consolidations WHERE chain=? ORDER BY created_at ASC", strings.Join(consolidationCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var consolidations []*Consolidation
+	for rows.Next() {
+		var c Consolidation
+		err = rows.Scan(&c.Address, &c.Chain, &c.Holder, &c.RequestId, &c.Signature, &c.ProposedAt, &c.CreatedAt, &c.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		consolidations = append(consolidations, &c)
+	}
+	return consolidations, nil
+}
+
 func (s *SQLite3Store) UpsertNodeStats(ctx context.Context, appId, typ, stats string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -981,3 +1147,536 @@ func (s *SQLite3Store) ListNodeStats(ctx context.Context, typ string) ([]*NodeSt
 	}
 	return nodes, nil
 }
+
+type Lease struct {
+	Name      string
+	Holder    string
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+func (s *SQLite3Store) AcquireLease(ctx context.Context, name, holder string, now time.Time, duration time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer common.Rollback(tx)
+
+	_, err = tx.ExecContext(ctx, "INSERT OR IGNORE INTO leases (name, holder, expires_at, updated_at) VALUES (?, ?, ?, ?)", name, holder, int64(0), now)
+	if err != nil {
+		return false, fmt.Errorf("INSERT leases %v", err)
+	}
+	res, err := tx.ExecContext(ctx, "UPDATE leases SET holder=?, expires_at=?, updated_at=? WHERE name=? AND (holder=? OR expires_at<?)",
+		holder, now.Add(duration).UnixNano(), now, name, holder, now.UnixNano())
+	if err != nil {
+		return false, fmt.Errorf("UPDATE leases %v", err)
+	}
+	rows, err := res.RowsAffected()
+	if err != nil || rows != 1 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (s *SQLite3Store) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	_, err = tx.ExecContext(ctx, "UPDATE leases SET expires_at=?, updated_at=? WHERE name=? AND holder=?",
+		int64(0), time.Now().UTC(), name, holder)
+	if err != nil {
+		return fmt.Errorf("UPDATE leases %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadLease(ctx context.Context, name string) (*Lease, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT name,holder,expires_at,updated_at FROM leases WHERE name=?", name)
+	var l Lease
+	var expiresAt int64
+	err := row.Scan(&l.Name, &l.Holder, &expiresAt, &l.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	l.ExpiresAt = time.Unix(0, expiresAt).UTC()
+	return &l, nil
+}
+
+type SafeTimelock struct {
+	Address     string
+	Chain       byte
+	Holder      string
+	ActiveAt    sql.NullTime
+	RecoveryAt  sql.NullTime
+	AlertedLead sql.NullInt64
+	UpdatedAt   time.Time
+}
+
+var safeTimelockCols = []string{"address", "chain", "holder", "active_at", "recovery_at", "alerted_lead", "updated_at"}
+
+func (t *SafeTimelock) values() []any {
+	return []any{t.Address, t.Chain, t.Holder, t.ActiveAt, t.RecoveryAt, t.AlertedLead, t.UpdatedAt}
+}
+
+func safeTimelockFromRow(row *sql.Row) (*SafeTimelock, error) {
+	var t SafeTimelock
+	err := row.Scan(&t.Address, &t.Chain, &t.Holder, &t.ActiveAt, &t.RecoveryAt, &t.AlertedLead, &t.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &t, err
+}
+
+// WriteSafeTimelock updates the recovery time of the safe, the alerts are only
+// reset if the recovery time is delayed, e.g. the safe timelock is refreshed,
+// because the estimated time of Bitcoin like chains varies with block times
+func (s *SQLite3Store) WriteSafeTimelock(ctx context.Context, t *SafeTimelock) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	query := fmt.Sprintf("SELECT %s FROM safe_timelocks WHERE address=?", strings.Join(safeTimelockCols, ","))
+	old, err := safeTimelockFromRow(tx.QueryRowContext(ctx, query, t.Address))
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		err = s.execOne(ctx, tx, buildInsertionSQL("safe_timelocks", safeTimelockCols), t.values()...)
+		if err != nil {
+			return fmt.Errorf("INSERT safe_timelocks %v", err)
+		}
+		return tx.Commit()
+	}
+
+	var alerted sql.NullInt64
+	if old.RecoveryAt.Valid && t.RecoveryAt.Valid && t.RecoveryAt.Time.Before(old.RecoveryAt.Time.Add(time.Hour)) {
+		alerted = old.AlertedLead
+	}
+	err = s.execOne(ctx, tx, "UPDATE safe_timelocks SET active_at=?, recovery_at=?, alerted_lead=?, updated_at=? WHERE address=?",
+		t.ActiveAt, t.RecoveryAt, alerted, t.UpdatedAt, t.Address)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_timelocks %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) MarkSafeTimelockAlerted(ctx context.Context, address string, lead int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE safe_timelocks SET alerted_lead=?, updated_at=? WHERE address=?", lead, time.Now().UTC(), address)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_timelocks %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadSafeTimelock(ctx context.Context, address string) (*SafeTimelock, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_timelocks WHERE address=?", strings.Join(safeTimelockCols, ","))
+	row := s.db.QueryRowContext(ctx, query, address)
+	return safeTimelockFromRow(row)
+}
+
+// ListSafeTimelocksBefore returns the safes could be recovered before the time,
+// the most urgent ones first
+func (s *SQLite3Store) ListSafeTimelocksBefore(ctx context.Context, before time.Time, limit int) ([]*SafeTimelock, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_timelocks WHERE recovery_at<? ORDER BY recovery_at ASC LIMIT %d", strings.Join(safeTimelockCols, ","), limit)
+	rows, err := s.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timelocks []*SafeTimelock
+	for rows.Next() {
+		var t SafeTimelock
+		err = rows.Scan(&t.Address, &t.Chain, &t.Holder, &t.ActiveAt, &t.RecoveryAt, &t.AlertedLead, &t.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		timelocks = append(timelocks, &t)
+	}
+	return timelocks, nil
+}
+
+type TransactionBatch struct {
+	BatchId      string
+	Chain        byte
+	Holder       string
+	Transactions []string
+	Signature    string
+	State        byte
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+var transactionBatchCols = []string{"batch_id", "chain", "holder", "transactions", "signature", "state", "created_at", "updated_at"}
+
+func (b *TransactionBatch) values() []any {
+	return []any{b.BatchId, b.Chain, b.Holder, strings.Join(b.Transactions, ","), b.Signature, b.State, b.CreatedAt, b.UpdatedAt}
+}
+
+func transactionBatchFromRow(row *sql.Row) (*TransactionBatch, error) {
+	var b TransactionBatch
+	var hashes string
+	err := row.Scan(&b.BatchId, &b.Chain, &b.Holder, &hashes, &b.Signature, &b.State, &b.CreatedAt, &b.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	b.Transactions = strings.Split(hashes, ",")
+	return &b, err
+}
+
+func (s *SQLite3Store) WriteTransactionBatchIfNotExists(ctx context.Context, b *TransactionBatch) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT batch_id FROM transaction_batches WHERE batch_id=?", b.BatchId)
+	if err != nil || existed {
+		return err
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("transaction_batches", transactionBatchCols), b.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT transaction_batches %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) MarkTransactionBatchPaid(ctx context.Context, id string) error {
+	return s.updateTransactionBatchState(ctx, id, common.RequestStateInitial, common.RequestStatePending)
+}
+
+func (s *SQLite3Store) FinishTransactionBatch(ctx context.Context, id string, state int) error {
+	return s.updateTransactionBatchState(ctx, id, common.RequestStatePending, state)
+}
+
+func (s *SQLite3Store) updateTransactionBatchState(ctx context.Context, id string, from, to int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE transaction_batches SET state=?, updated_at=? WHERE batch_id=? AND state=?",
+		to, time.Now().UTC(), id, from)
+	if err != nil {
+		return fmt.Errorf("UPDATE transaction_batches %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) UpdateTransactionBatchRequestTime(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE transaction_batches SET updated_at=? WHERE batch_id=? AND state=?",
+		time.Now().UTC(), id, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE transaction_batches %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadTransactionBatch(ctx context.Context, id string) (*TransactionBatch, error) {
+	query := fmt.Sprintf("SELECT %s FROM transaction_batches WHERE batch_id=?", strings.Join(transactionBatchCols, ","))
+	row := s.db.QueryRowContext(ctx, query, id)
+	return transactionBatchFromRow(row)
+}
+
+func (s *SQLite3Store) ListPendingTransactionBatches(ctx context.Context, chain byte) ([]*TransactionBatch, error) {
+	query := fmt.Sprintf("SELECT %s FROM transaction_batches WHERE chain=? AND state=? ORDER BY created_at ASC", strings.Join(transactionBatchCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStatePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []*TransactionBatch
+	for rows.Next() {
+		var b TransactionBatch
+		var hashes string
+		err = rows.Scan(&b.BatchId, &b.Chain, &b.Holder, &hashes, &b.Signature, &b.State, &b.CreatedAt, &b.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		b.Transactions = strings.Split(hashes, ",")
+		batches = append(batches, &b)
+	}
+	return batches, nil
+}
+
+type SafeMessage struct {
+	MessageId   string
+	Chain       byte
+	Holder      string
+	MessageHash string
+	Message     string
+	Signature   string
+	Proof       sql.NullString
+	State       byte
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+var safeMessageCols = []string{"message_id", "chain", "holder", "message_hash", "message", "signature", "proof", "state", "created_at", "updated_at"}
+
+func (m *SafeMessage) values() []any {
+	return []any{m.MessageId, m.Chain, m.Holder, m.MessageHash, m.Message, m.Signature, m.Proof, m.State, m.CreatedAt, m.UpdatedAt}
+}
+
+func safeMessageFromRow(row *sql.Row) (*SafeMessage, error) {
+	var m SafeMessage
+	err := row.Scan(&m.MessageId, &m.Chain, &m.Holder, &m.MessageHash, &m.Message, &m.Signature, &m.Proof, &m.State, &m.CreatedAt, &m.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &m, err
+}
+
+func (s *SQLite3Store) WriteSafeMessageIfNotExists(ctx context.Context, m *SafeMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT message_id FROM safe_messages WHERE message_id=?", m.MessageId)
+	if err != nil || existed {
+		return err
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("safe_messages", safeMessageCols), m.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT safe_messages %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) MarkSafeMessagePaid(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE safe_messages SET state=?, updated_at=? WHERE message_id=? AND state=?",
+		common.RequestStatePending, time.Now().UTC(), id, common.RequestStateInitial)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_messages %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) FinishSafeMessage(ctx context.Context, id, proof string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE safe_messages SET proof=?, state=?, updated_at=? WHERE message_id=? AND state=?",
+		proof, common.RequestStateDone, time.Now().UTC(), id, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_messages %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) UpdateSafeMessageRequestTime(ctx context.Context, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	err = s.execOne(ctx, tx, "UPDATE safe_messages SET updated_at=? WHERE message_id=? AND state=?",
+		time.Now().UTC(), id, common.RequestStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE safe_messages %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ReadSafeMessage(ctx context.Context, id string) (*SafeMessage, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_messages WHERE message_id=?", strings.Join(safeMessageCols, ","))
+	row := s.db.QueryRowContext(ctx, query, id)
+	return safeMessageFromRow(row)
+}
+
+func (s *SQLite3Store) ListPendingSafeMessages(ctx context.Context, chain byte) ([]*SafeMessage, error) {
+	query := fmt.Sprintf("SELECT %s FROM safe_messages WHERE chain=? AND state=? ORDER BY created_at ASC", strings.Join(safeMessageCols, ","))
+	rows, err := s.db.QueryContext(ctx, query, chain, common.RequestStatePending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*SafeMessage
+	for rows.Next() {
+		var m SafeMessage
+		err = rows.Scan(&m.MessageId, &m.Chain, &m.Holder, &m.MessageHash, &m.Message, &m.Signature, &m.Proof, &m.State, &m.CreatedAt, &m.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &m)
+	}
+	return messages, nil
+}
+
+const (
+	UnconfirmedDepositStatePending   = 1
+	UnconfirmedDepositStateConfirmed = 2
+	UnconfirmedDepositStateReplaced  = 3
+	UnconfirmedDepositStateEvicted   = 4
+)
+
+// UnconfirmedDeposit is a payment to a safe address seen in the mempool, it is
+// only informational and never credited, the deposits are still only written
+// after the blocks are finalized
+type UnconfirmedDeposit struct {
+	TransactionHash string
+	OutputIndex     int64
+	Chain           byte
+	AssetId         string
+	Amount          string
+	Receiver        string
+	Holder          string
+	Spent           []string
+	State           int
+	ReplacedBy      string
+	FeeRate         int64
+	ExpectedAt      time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+var unconfirmedDepositCols = []string{"transaction_hash", "output_index", "chain", "asset_id", "amount", "receiver", "holder", "spent", "state", "replaced_by", "fee_rate", "expected_at", "created_at", "updated_at"}
+
+func (d *UnconfirmedDeposit) values() []any {
+	return []any{d.TransactionHash, d.OutputIndex, d.Chain, d.AssetId, d.Amount, d.Receiver, d.Holder, strings.Join(d.Spent, ","), d.State, d.ReplacedBy, d.FeeRate, d.ExpectedAt, d.CreatedAt, d.UpdatedAt}
+}
+
+func (s *SQLite3Store) WriteUnconfirmedDepositIfNotExists(ctx context.Context, d *UnconfirmedDeposit) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	existed, err := s.checkExistence(ctx, tx, "SELECT transaction_hash FROM unconfirmed_deposits WHERE transaction_hash=? AND output_index=?", d.TransactionHash, d.OutputIndex)
+	if err != nil || existed {
+		return err
+	}
+
+	err = s.execOne(ctx, tx, buildInsertionSQL("unconfirmed_deposits", unconfirmedDepositCols), d.values()...)
+	if err != nil {
+		return fmt.Errorf("INSERT unconfirmed_deposits %v", err)
+	}
+	return tx.Commit()
+}
+
+// FinishUnconfirmedDeposits updates all the pending outputs of the transaction
+// once it is confirmed, replaced or evicted from the mempool
+func (s *SQLite3Store) FinishUnconfirmedDeposits(ctx context.Context, hash string, state int, replacedBy string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	_, err = tx.ExecContext(ctx, "UPDATE unconfirmed_deposits SET state=?, replaced_by=?, updated_at=? WHERE transaction_hash=? AND state=?",
+		state, replacedBy, time.Now().UTC(), hash, UnconfirmedDepositStatePending)
+	if err != nil {
+		return fmt.Errorf("UPDATE unconfirmed_deposits %v", err)
+	}
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListPendingUnconfirmedDeposits(ctx context.Context, chain byte) ([]*UnconfirmedDeposit, error) {
+	query := fmt.Sprintf("SELECT %s FROM unconfirmed_deposits WHERE chain=? AND state=? ORDER BY created_at ASC", strings.Join(unconfirmedDepositCols, ","))
+	return s.listUnconfirmedDeposits(ctx, query, chain, UnconfirmedDepositStatePending)
+}
+
+// ListUnconfirmedDepositsForReceiver returns the pending deposits of the safe
+// address, and those confirmed, replaced or evicted since the time
+func (s *SQLite3Store) ListUnconfirmedDepositsForReceiver(ctx context.Context, receiver string, since time.Time) ([]*UnconfirmedDeposit, error) {
+	query := fmt.Sprintf("SELECT %s FROM unconfirmed_deposits WHERE receiver=? AND (state=? OR updated_at>?) ORDER BY created_at ASC", strings.Join(unconfirmedDepositCols, ","))
+	return s.listUnconfirmedDeposits(ctx, query, receiver, UnconfirmedDepositStatePending, since)
+}
+
+func (s *SQLite3Store) listUnconfirmedDeposits(ctx context.Context, query string, params ...any) ([]*UnconfirmedDeposit, error) {
+	rows, err := s.db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deposits []*UnconfirmedDeposit
+	for rows.Next() {
+		var d UnconfirmedDeposit
+		var spent string
+		err := rows.Scan(&d.TransactionHash, &d.OutputIndex, &d.Chain, &d.AssetId, &d.Amount, &d.Receiver, &d.Holder, &spent, &d.State, &d.ReplacedBy, &d.FeeRate, &d.ExpectedAt, &d.CreatedAt, &d.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Spent = strings.Split(spent, ",")
+		deposits = append(deposits, &d)
+	}
+	return deposits, nil
+}
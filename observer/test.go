@@ -0,0 +1,28 @@
+package observer
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/common"
+)
+
+// TestBoot starts the bitcoin loops of the observer in the test environment,
+// with the Mixin API replaced by the network. The store migrations are not
+// needed for new stores, and the operation params and network info are not
+// sent, they are managed by the test environment instead.
+func (node *Node) TestBoot(ctx context.Context, network TestNetwork) {
+	if !common.CheckTestEnvironment(ctx) {
+		panic(node.conf.App.AppId)
+	}
+	node.network = network
+
+	chain := byte(common.SafeChainBitcoin)
+	go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinRPCBlocksLoop(ctx, chain) })
+	go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinDepositConfirmLoop(ctx, chain) })
+	go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinTransactionApprovalLoop(ctx, chain) })
+	go node.runLoop(ctx, func(ctx context.Context) { node.bitcoinTransactionSpendLoop(ctx, chain) })
+	go node.runLoop(ctx, node.sendAccountApprovals)
+	go node.runLoop(ctx, node.snapshotsLoop)
+	logger.Printf("node.TestBoot(%s)", node.conf.App.AppId)
+}
@@ -0,0 +1,212 @@
+package observer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/bot-api-go-client/v3"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper/store"
+)
+
+const safeTimelockListLimit = 100
+
+// safeTimelockLoop tracks when the observer key could be used to recover each
+// safe, i.e. the oldest Bitcoin output is not spent for the timelock, or the
+// Gnosis safe guard has no transaction for the timelock, and alerts the safe
+// receivers before that at the configured lead hours
+func (node *Node) safeTimelockLoop(ctx context.Context) {
+	for {
+		time.Sleep(10 * time.Minute)
+		safes, err := node.keeperStore.ListSafesWithState(ctx, common.RequestStateDone)
+		if err != nil {
+			panic(err)
+		}
+		for _, safe := range safes {
+			err := node.updateSafeTimelock(ctx, safe)
+			logger.Verbosef("node.updateSafeTimelock(%s) => %v", safe.Address, err)
+		}
+		time.Sleep(20 * time.Minute)
+	}
+}
+
+func (node *Node) updateSafeTimelock(ctx context.Context, safe *store.Safe) error {
+	var t *SafeTimelock
+	var err error
+	switch safe.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		t, err = node.readBitcoinSafeTimelock(ctx, safe)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		t, err = node.readEthereumSafeTimelock(ctx, safe)
+	case common.SafeChainSolana:
+		// the solana safe program keeps the last execution time in the
+		// config account, which is not tracked yet
+		return nil
+	default:
+		panic(safe.Chain)
+	}
+	if err != nil || t == nil {
+		return err
+	}
+	err = node.store.WriteSafeTimelock(ctx, t)
+	if err != nil {
+		return err
+	}
+	t, err = node.store.ReadSafeTimelock(ctx, safe.Address)
+	if err != nil {
+		return err
+	}
+	return node.alertSafeTimelock(ctx, safe, t)
+}
+
+func (node *Node) readBitcoinSafeTimelock(ctx context.Context, safe *store.Safe) (*SafeTimelock, error) {
+	t := &SafeTimelock{
+		Address:   safe.Address,
+		Chain:     safe.Chain,
+		Holder:    safe.Holder,
+		UpdatedAt: time.Now().UTC(),
+	}
+	oldest, createdAt, err := node.keeperStore.ReadOldestBitcoinUTXOForSafe(ctx, safe.Address)
+	logger.Verbosef("keeperStore.ReadOldestBitcoinUTXOForSafe(%s) => %v %v", safe.Address, oldest, err)
+	if err != nil {
+		return nil, err
+	}
+	if oldest == nil {
+		return t, nil
+	}
+
+	rpc, _ := node.bitcoinParams(safe.Chain)
+	info, err := node.keeperStore.ReadLatestNetworkInfo(ctx, safe.Chain, time.Now())
+	if err != nil || info == nil {
+		return nil, err
+	}
+	_, bo, err := bitcoin.RPCGetTransactionOutput(safe.Chain, rpc, oldest.TransactionHash, int64(oldest.Index))
+	logger.Verbosef("bitcoin.RPCGetTransactionOutput(%s, %d) => %v %v", oldest.TransactionHash, oldest.Index, bo, err)
+	if err != nil {
+		return nil, err
+	}
+	height := bo.Height
+	if height == 0 || height > info.Height {
+		height = info.Height
+	}
+	// the same margin as the recovery request check
+	sequence := uint64(bitcoin.ParseSequence(safe.Timelock, safe.Chain))
+	unlock := int64(height+sequence+100) - int64(info.Height)
+	recoveryAt := info.CreatedAt.Add(time.Duration(unlock) * bitcoin.BlockDuration(safe.Chain))
+	t.ActiveAt = sql.NullTime{Valid: true, Time: createdAt}
+	t.RecoveryAt = sql.NullTime{Valid: true, Time: recoveryAt.Truncate(time.Minute).UTC()}
+	return t, nil
+}
+
+func (node *Node) readEthereumSafeTimelock(ctx context.Context, safe *store.Safe) (*SafeTimelock, error) {
+	account, err := node.store.ReadAccount(ctx, safe.Address)
+	if err != nil || account == nil || !account.DeployedAt.Valid {
+		return nil, err
+	}
+	rpc, _ := node.ethereumParams(safe.Chain)
+	latestTxTime, err := ethereum.GetSafeLastTxTime(rpc, safe.Address)
+	logger.Verbosef("ethereum.GetSafeLastTxTime(%s %s) => %v %v", rpc, safe.Address, latestTxTime, err)
+	if err != nil {
+		return nil, err
+	}
+	// the same margin as the recovery request check
+	recoveryAt := latestTxTime.Add(safe.Timelock + 1*time.Hour)
+	return &SafeTimelock{
+		Address:    safe.Address,
+		Chain:      safe.Chain,
+		Holder:     safe.Holder,
+		ActiveAt:   sql.NullTime{Valid: true, Time: latestTxTime.UTC()},
+		RecoveryAt: sql.NullTime{Valid: true, Time: recoveryAt.UTC()},
+		UpdatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+func (node *Node) alertSafeTimelock(ctx context.Context, safe *store.Safe, t *SafeTimelock) error {
+	if !t.RecoveryAt.Valid {
+		return nil
+	}
+	lead, ok := selectSafeTimelockAlertLead(node.conf.TimelockAlertHours, time.Until(t.RecoveryAt.Time), t.AlertedLead)
+	if !ok {
+		return nil
+	}
+
+	msg := fmt.Sprintf("⏳ Safe %s could be recovered with the observer key within %d hours, at %s.\n", safe.Address, lead, t.RecoveryAt.Time.Format(time.RFC3339))
+	if lead == 0 {
+		msg = fmt.Sprintf("⌛ Safe %s could be recovered with the observer key now.\n", safe.Address)
+	}
+	msg = msg + "Refresh the safe timelock if the holder key is still available."
+
+	app := node.conf.App
+	id := common.UniqueId(safe.Address, fmt.Sprintf("TIMELOCK:%d:%d", t.RecoveryAt.Time.Unix(), lead))
+	var messages []*bot.MessageRequest
+	for _, r := range safe.Receivers {
+		messages = append(messages, &bot.MessageRequest{
+			ConversationId: bot.UniqueConversationId(app.AppId, r),
+			RecipientId:    r,
+			Category:       bot.MessageCategoryPlainText,
+			MessageId:      common.UniqueId(id, r),
+			DataBase64:     base64.RawURLEncoding.EncodeToString([]byte(msg)),
+		})
+	}
+	if node.conf.MonitorConversaionId != "" {
+		messages = append(messages, &bot.MessageRequest{
+			ConversationId: node.conf.MonitorConversaionId,
+			Category:       bot.MessageCategoryPlainText,
+			MessageId:      common.UniqueId(id, node.conf.MonitorConversaionId),
+			DataBase64:     base64.RawURLEncoding.EncodeToString([]byte(msg)),
+		})
+	}
+	su := node.safeUser()
+	err := bot.PostMessages(ctx, messages, &su)
+	logger.Printf("bot.PostMessages(%s, %d) => %d %v", safe.Address, lead, len(messages), err)
+	if err != nil {
+		return err
+	}
+	return node.store.MarkSafeTimelockAlerted(ctx, safe.Address, lead)
+}
+
+// selectSafeTimelockAlertLead returns the most urgent lead hours reached by
+// the remaining time, 0 if the recovery is already possible, and only if it
+// has not been alerted with the same or a more urgent lead
+func selectSafeTimelockAlertLead(hours []int64, remaining time.Duration, alerted sql.NullInt64) (int64, bool) {
+	if len(hours) == 0 {
+		return 0, false
+	}
+	leads := append([]int64{0}, hours...)
+	slices.Sort(leads)
+	for _, lead := range leads {
+		if remaining > time.Duration(lead)*time.Hour {
+			continue
+		}
+		if alerted.Valid && alerted.Int64 <= lead {
+			return 0, false
+		}
+		return lead, true
+	}
+	return 0, false
+}
+
+func (t *SafeTimelock) view() map[string]any {
+	view := map[string]any{
+		"address":     t.Address,
+		"chain":       t.Chain,
+		"active_at":   nil,
+		"recovery_at": nil,
+		"updated_at":  t.UpdatedAt,
+	}
+	if t.ActiveAt.Valid {
+		view["active_at"] = t.ActiveAt.Time
+	}
+	if t.RecoveryAt.Valid {
+		view["recovery_at"] = t.RecoveryAt.Time
+		view["recoverable"] = !t.RecoveryAt.Time.After(time.Now())
+	}
+	return view
+}
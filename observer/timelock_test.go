@@ -0,0 +1,79 @@
+package observer
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/safe/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserverTimelockAlerts(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	hours := []int64{24, 168}
+	none := sql.NullInt64{}
+	_, ok := selectSafeTimelockAlertLead(nil, time.Hour, none)
+	require.False(ok)
+	_, ok = selectSafeTimelockAlertLead(hours, 200*time.Hour, none)
+	require.False(ok)
+	lead, ok := selectSafeTimelockAlertLead(hours, 100*time.Hour, none)
+	require.True(ok)
+	require.Equal(int64(168), lead)
+	_, ok = selectSafeTimelockAlertLead(hours, 100*time.Hour, sql.NullInt64{Valid: true, Int64: 168})
+	require.False(ok)
+	lead, ok = selectSafeTimelockAlertLead(hours, 10*time.Hour, sql.NullInt64{Valid: true, Int64: 168})
+	require.True(ok)
+	require.Equal(int64(24), lead)
+	lead, ok = selectSafeTimelockAlertLead(hours, -time.Minute, sql.NullInt64{Valid: true, Int64: 24})
+	require.True(ok)
+	require.Equal(int64(0), lead)
+	_, ok = selectSafeTimelockAlertLead(hours, -time.Hour, sql.NullInt64{Valid: true, Int64: 0})
+	require.False(ok)
+
+	db, err := OpenSQLite3Store(t.TempDir() + "/observer.sqlite3")
+	require.Nil(err)
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	tl := &SafeTimelock{
+		Address:    "address",
+		Chain:      common.SafeChainBitcoin,
+		Holder:     "holder",
+		ActiveAt:   sql.NullTime{Valid: true, Time: now},
+		RecoveryAt: sql.NullTime{Valid: true, Time: now.Add(10 * time.Hour)},
+		UpdatedAt:  now,
+	}
+	err = db.WriteSafeTimelock(ctx, tl)
+	require.Nil(err)
+	err = db.MarkSafeTimelockAlerted(ctx, tl.Address, 24)
+	require.Nil(err)
+	tls, err := db.ListSafeTimelocksBefore(ctx, now.Add(24*time.Hour), safeTimelockListLimit)
+	require.Nil(err)
+	require.Len(tls, 1)
+	require.Equal(int64(24), tls[0].AlertedLead.Int64)
+	tls, err = db.ListSafeTimelocksBefore(ctx, now.Add(time.Hour), safeTimelockListLimit)
+	require.Nil(err)
+	require.Len(tls, 0)
+
+	// the estimated recovery time varies a bit, and the alert is kept
+	tl.RecoveryAt.Time = now.Add(10*time.Hour + 10*time.Minute)
+	err = db.WriteSafeTimelock(ctx, tl)
+	require.Nil(err)
+	old, err := db.ReadSafeTimelock(ctx, tl.Address)
+	require.Nil(err)
+	require.True(old.AlertedLead.Valid)
+	require.Equal(int64(24), old.AlertedLead.Int64)
+
+	// the refreshed timelock resets the alerts
+	tl.RecoveryAt.Time = now.Add(240 * time.Hour)
+	err = db.WriteSafeTimelock(ctx, tl)
+	require.Nil(err)
+	old, err = db.ReadSafeTimelock(ctx, tl.Address)
+	require.Nil(err)
+	require.False(old.AlertedLead.Valid)
+	require.True(old.RecoveryAt.Time.Equal(tl.RecoveryAt.Time))
+}
@@ -0,0 +1,309 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client talks to the observer HTTP API, all holder approvals and
+// account closings are submitted through the observer
+type Client struct {
+	endpoint string
+	client   *http.Client
+}
+
+type ChainHead struct {
+	Id     string `json:"id"`
+	Height uint64 `json:"height"`
+	Fee    uint64 `json:"fee"`
+	Hash   string `json:"hash"`
+}
+
+type Chain struct {
+	Id    string     `json:"id"`
+	Chain byte       `json:"chain"`
+	Head  *ChainHead `json:"head"`
+}
+
+type Account struct {
+	Id          string   `json:"id"`
+	Chain       byte     `json:"chain"`
+	Address     string   `json:"address"`
+	Script      string   `json:"script"`
+	Descriptor  string   `json:"descriptor"`
+	Keys        []string `json:"keys"`
+	Timelock    int64    `json:"timelock"`
+	SafeAssetId string   `json:"safe_asset_id"`
+	Nonce       int64    `json:"nonce"`
+	State       string   `json:"state"`
+
+	Holders         []string `json:"holders"`
+	HolderThreshold int      `json:"holder_threshold"`
+
+	Unconfirmed []*UnconfirmedDeposit `json:"unconfirmed"`
+}
+
+type UnconfirmedDeposit struct {
+	TransactionHash string    `json:"transaction_hash"`
+	OutputIndex     int64     `json:"output_index"`
+	AssetId         string    `json:"asset_id"`
+	Amount          string    `json:"amount"`
+	FeeRate         int64     `json:"fee_rate"`
+	State           string    `json:"state"`
+	ReplacedBy      string    `json:"replaced_by"`
+	ExpectedAt      time.Time `json:"expected_at"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+type Timelock struct {
+	Id          string     `json:"id"`
+	Chain       byte       `json:"chain"`
+	Address     string     `json:"address"`
+	Timelock    int64      `json:"timelock"`
+	ActiveAt    *time.Time `json:"active_at"`
+	RecoveryAt  *time.Time `json:"recovery_at"`
+	Recoverable bool       `json:"recoverable"`
+}
+
+type Transaction struct {
+	Id             string     `json:"id"`
+	Chain          byte       `json:"chain"`
+	AccountId      string     `json:"account_id"`
+	AccountAddress string     `json:"account_address"`
+	Hash           string     `json:"hash"`
+	Raw            string     `json:"raw"`
+	Signers        []string   `json:"signers"`
+	State          string     `json:"state"`
+	ExpireAt       *time.Time `json:"expire_at"`
+}
+
+type Batch struct {
+	Id           string   `json:"id"`
+	Chain        byte     `json:"chain"`
+	Transactions []string `json:"transactions"`
+	State        string   `json:"state"`
+}
+
+type BatchTransaction struct {
+	Id  string `json:"id"`
+	Raw string `json:"raw,omitempty"`
+}
+
+type EstimateOutput struct {
+	Receiver string `json:"receiver"`
+	Amount   string `json:"amount"`
+}
+
+type Estimate struct {
+	Chain              byte     `json:"chain"`
+	NetworkInfoId      string   `json:"network_info_id"`
+	FeeRate            uint64   `json:"fee_rate"`
+	VirtualSize        int      `json:"virtual_size"`
+	Gas                uint64   `json:"gas"`
+	Inputs             []string `json:"inputs"`
+	TransactionMinimum string   `json:"transaction_minimum"`
+	Fee                struct {
+		AssetId string `json:"asset_id"`
+		Amount  string `json:"amount"`
+	} `json:"fee"`
+	Operation struct {
+		AssetId string `json:"asset_id"`
+		Amount  string `json:"amount"`
+	} `json:"operation"`
+	Checks struct {
+		Minimum bool `json:"minimum"`
+		Dust    bool `json:"dust"`
+		Balance bool `json:"balance"`
+	} `json:"checks"`
+}
+
+func NewClient(endpoint string) *Client {
+	return &Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: 20 * time.Second},
+	}
+}
+
+func (c *Client) ListChains(ctx context.Context) ([]*Chain, error) {
+	var chains []*Chain
+	err := c.request(ctx, http.MethodGet, "/chains", nil, &chains)
+	return chains, err
+}
+
+// ReadChain returns the latest network info of the chain, the head id
+// should be used in the transaction proposal to specify the fee rate
+func (c *Client) ReadChain(ctx context.Context, chain byte) (*Chain, error) {
+	chains, err := c.ListChains(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ci := range chains {
+		if ci.Chain == chain {
+			return ci, nil
+		}
+	}
+	return nil, nil
+}
+
+func (c *Client) ReadAccount(ctx context.Context, id string) (*Account, error) {
+	var account Account
+	err := c.request(ctx, http.MethodGet, "/accounts/"+id, nil, &account)
+	return &account, err
+}
+
+func (c *Client) ApproveAccount(ctx context.Context, id, address, signature string) (*Account, error) {
+	var account Account
+	body := map[string]string{
+		"action":    "approve",
+		"address":   address,
+		"signature": signature,
+	}
+	err := c.request(ctx, http.MethodPost, "/accounts/"+id, body, &account)
+	return &account, err
+}
+
+func (c *Client) CloseAccount(ctx context.Context, id, address, raw, hash string) (*Account, error) {
+	var account Account
+	body := map[string]string{
+		"action":  "close",
+		"address": address,
+		"raw":     raw,
+		"hash":    hash,
+	}
+	err := c.request(ctx, http.MethodPost, "/accounts/"+id, body, &account)
+	return &account, err
+}
+
+func (c *Client) ConsolidateAccount(ctx context.Context, id, address, nonce, signature string) (*Account, error) {
+	var account Account
+	body := map[string]string{
+		"action":    "consolidate",
+		"address":   address,
+		"nonce":     nonce,
+		"signature": signature,
+	}
+	err := c.request(ctx, http.MethodPost, "/accounts/"+id, body, &account)
+	return &account, err
+}
+
+// ReadAccountTimelock returns when the observer key could be used to recover
+// the account, the holder should refresh the timelock before that
+func (c *Client) ReadAccountTimelock(ctx context.Context, id string) (*Timelock, error) {
+	var timelock Timelock
+	err := c.request(ctx, http.MethodGet, "/accounts/"+id+"/timelock", nil, &timelock)
+	return &timelock, err
+}
+
+func (c *Client) RefreshAccountTimelock(ctx context.Context, id, address, nonce, signature string) (*Account, error) {
+	var account Account
+	body := map[string]string{
+		"action":    "refresh",
+		"address":   address,
+		"nonce":     nonce,
+		"signature": signature,
+	}
+	err := c.request(ctx, http.MethodPost, "/accounts/"+id, body, &account)
+	return &account, err
+}
+
+func (c *Client) ReadTransaction(ctx context.Context, id string) (*Transaction, error) {
+	var tx Transaction
+	err := c.request(ctx, http.MethodGet, "/transactions/"+id, nil, &tx)
+	return &tx, err
+}
+
+func (c *Client) ApproveTransaction(ctx context.Context, id string, chain byte, raw string) (*Transaction, error) {
+	var tx Transaction
+	body := map[string]any{
+		"action": "approve",
+		"chain":  chain,
+		"raw":    raw,
+	}
+	err := c.request(ctx, http.MethodPost, "/transactions/"+id, body, &tx)
+	return &tx, err
+}
+
+func (c *Client) RevokeTransaction(ctx context.Context, id string, chain byte, signature string) (*Transaction, error) {
+	var tx Transaction
+	body := map[string]any{
+		"action":    "revoke",
+		"chain":     chain,
+		"signature": signature,
+	}
+	err := c.request(ctx, http.MethodPost, "/transactions/"+id, body, &tx)
+	return &tx, err
+}
+
+func (c *Client) ReadBatch(ctx context.Context, id string) (*Batch, error) {
+	var batch Batch
+	err := c.request(ctx, http.MethodGet, "/batches/"+id, nil, &batch)
+	return &batch, err
+}
+
+// CreateBatch submits the holder signature over all the transaction hashes,
+// then the holder should pay the observer with the batch id as memo once
+func (c *Client) CreateBatch(ctx context.Context, chain byte, transactions []*BatchTransaction, signature string) (*Batch, error) {
+	var batch Batch
+	body := map[string]any{
+		"chain":        chain,
+		"transactions": transactions,
+		"signature":    signature,
+	}
+	err := c.request(ctx, http.MethodPost, "/batches", body, &batch)
+	return &batch, err
+}
+
+// EstimateTransaction previews the draft proposal of the safe address, the
+// inputs are only for bitcoin like chains, and the asset id for EVM chains
+func (c *Client) EstimateTransaction(ctx context.Context, chain byte, address, assetId string, outputs []*EstimateOutput, inputs []string) (*Estimate, error) {
+	var estimate Estimate
+	body := map[string]any{
+		"address":  address,
+		"asset_id": assetId,
+		"outputs":  outputs,
+		"inputs":   inputs,
+	}
+	err := c.request(ctx, http.MethodPost, fmt.Sprintf("/chains/%d/estimate", chain), body, &estimate)
+	return &estimate, err
+}
+
+func (c *Client) request(ctx context.Context, method, path string, body, resp any) error {
+	var data []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	var raw json.RawMessage
+	err = json.NewDecoder(res.Body).Decode(&raw)
+	if err != nil {
+		return fmt.Errorf("%s %s => %d %v", method, path, res.StatusCode, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		var e struct {
+			Error any `json:"error"`
+		}
+		_ = json.Unmarshal(raw, &e)
+		return fmt.Errorf("%s %s => %d %v", method, path, res.StatusCode, e.Error)
+	}
+	return json.Unmarshal(raw, resp)
+}
@@ -0,0 +1,423 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/btcutil/psbt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gofrs/uuid/v5"
+)
+
+// Holder signs all approvals with the holder private key, the key never
+// leaves the holder, only signatures and signed transactions are sent
+type Holder struct {
+	chain   byte
+	private *btcec.PrivateKey
+}
+
+func NewHolder(chain byte, priv string) (*Holder, error) {
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+	default:
+		return nil, fmt.Errorf("invalid chain %d", chain)
+	}
+	seed, err := hex.DecodeString(priv)
+	if err != nil || len(seed) != 32 {
+		return nil, fmt.Errorf("invalid private key %d", len(seed))
+	}
+	private, _ := btcec.PrivKeyFromBytes(seed)
+	return &Holder{chain: chain, private: private}, nil
+}
+
+func (h *Holder) Chain() byte {
+	return h.chain
+}
+
+func (h *Holder) Public() string {
+	return hex.EncodeToString(h.private.PubKey().SerializeCompressed())
+}
+
+// SignMessage signs the message with the chain specific message prefix,
+// Bitcoin signatures are base64 DER and Ethereum signatures are hex
+func (h *Holder) SignMessage(msg []byte) string {
+	switch h.chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		hash := bitcoin.HashMessageForSignature(string(msg), h.chain)
+		sig := ecdsa.Sign(h.private, hash).Serialize()
+		return encodeMessageSignature(h.chain, sig)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		return encodeMessageSignature(h.chain, h.signEthereumMessage(msg))
+	default:
+		panic(h.chain)
+	}
+}
+
+func (h *Holder) SignRevoke(id, hash string) string {
+	ms := fmt.Sprintf("REVOKE:%s:%s", id, hash)
+	return h.SignMessage([]byte(ms))
+}
+
+// SignConsolidation authorises the observer to propose one transaction
+// consolidating the Bitcoin safe outputs back to the safe address, the nonce
+// is used as the request id so the signature can't be replayed
+func (h *Holder) SignConsolidation(account *Account, nonce string) (string, error) {
+	switch h.chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		ms := fmt.Sprintf("CONSOLIDATE:%s:%s:%s", account.Id, account.Address, nonce)
+		return h.SignMessage([]byte(ms)), nil
+	default:
+		return "", fmt.Errorf("invalid consolidation chain %d", h.chain)
+	}
+}
+
+// SignTimelockRefresh authorises the observer to propose one transaction spending
+// the safe funds to itself, which delays the observer key recovery, the nonce
+// is used as the request id so the signature can't be replayed
+func (h *Holder) SignTimelockRefresh(account *Account, nonce string) string {
+	ms := fmt.Sprintf("REFRESH:%s:%s:%s", account.Id, account.Address, nonce)
+	return h.SignMessage([]byte(ms))
+}
+
+// SignPolicy signs the exact policy JSON to replace the active policy of the
+// account, which is sent to keeper with the signatures of other holders, the
+// nonce is the request id so the signature can't be replayed
+func (h *Holder) SignPolicy(account *Account, nonce string, policy []byte) string {
+	ms := common.SafePolicyMessage(account.Id, account.Address, nonce, policy)
+	return h.SignMessage([]byte(ms))
+}
+
+// SignBatchApproval signs all the transaction hashes of the account in order,
+// which approves them with a single payment to the observer
+func (h *Holder) SignBatchApproval(account *Account, hashes []string) string {
+	ms := common.BatchApprovalMessage(account.Id, hashes)
+	return h.SignMessage([]byte(ms))
+}
+
+// SignAccountApproval signs the account proposal, for Ethereum safes the
+// holder signs the transaction to enable the safe guard, which is rebuilt
+// from the signer and observer keys of the account
+func (h *Holder) SignAccountApproval(ctx context.Context, account *Account) (string, error) {
+	switch h.chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		ms := fmt.Sprintf("APPROVE:%s:%s", account.Id, account.Address)
+		return h.SignMessage([]byte(ms)), nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		signer, observer, err := parseAccountKeys(account)
+		if err != nil {
+			return "", err
+		}
+		lock := time.Duration(account.Timelock) * time.Hour
+		holders, threshold := h.accountHolders(account)
+		gs, t, err := ethereum.BuildGnosisSafeWithHolders(ctx, "", holders, threshold, signer, observer, account.Id, lock, h.chain)
+		if err != nil {
+			return "", err
+		}
+		if gs.Address != account.Address {
+			return "", fmt.Errorf("invalid account address %s %s", gs.Address, account.Address)
+		}
+		return hex.EncodeToString(h.signEthereumMessage(t.Message)), nil
+	default:
+		panic(h.chain)
+	}
+}
+
+// SignTransaction returns the raw transaction with the holder signatures,
+// the account is required to locate the holder in the safe owners, and
+// the signatures of other holder keys in the raw transaction are kept
+func (h *Holder) SignTransaction(account *Account, raw string) (string, string, error) {
+	rb, err := hex.DecodeString(raw)
+	if err != nil {
+		return "", "", err
+	}
+	switch h.chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		hpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		if err != nil {
+			return "", "", err
+		}
+		holders, _ := h.accountHolders(account)
+		pub := h.private.PubKey().SerializeCompressed()
+		for idx := range hpsbt.UnsignedTx.TxIn {
+			hash := hpsbt.SigHash(idx)
+			sig := ecdsa.Sign(h.private, hash).Serialize()
+			partials := slices.DeleteFunc(hpsbt.Inputs[idx].PartialSigs, func(ps *psbt.PartialSig) bool {
+				return !slices.Contains(holders, hex.EncodeToString(ps.PubKey)) || bytes.Equal(ps.PubKey, pub)
+			})
+			hpsbt.Inputs[idx].PartialSigs = append(partials, &psbt.PartialSig{
+				PubKey:    pub,
+				Signature: sig,
+			})
+		}
+		return hex.EncodeToString(hpsbt.Marshal()), hpsbt.Hash(), nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		st, err := ethereum.UnmarshalSafeTransaction(rb)
+		if err != nil {
+			return "", "", err
+		}
+		signer, observer, err := parseAccountKeys(account)
+		if err != nil {
+			return "", "", err
+		}
+		holders, _ := h.accountHolders(account)
+		_, pubs := ethereum.GetSortedSafeOwnersWithHolders(holders, signer, observer)
+		for i, pub := range pubs {
+			if pub == h.Public() {
+				st.SetSignature(i, h.signEthereumMessage(st.Message))
+			}
+		}
+		return hex.EncodeToString(st.Marshal()), st.TxHash, nil
+	default:
+		panic(h.chain)
+	}
+}
+
+// ApproveAccount signs the account proposal and submits it to observer,
+// the account is ready to receive deposits after the approval
+func (h *Holder) ApproveAccount(ctx context.Context, c *Client, id string) (*Account, error) {
+	account, err := c.ReadAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.Chain != h.chain {
+		return nil, fmt.Errorf("invalid account chain %d", account.Chain)
+	}
+	sig, err := h.SignAccountApproval(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApproveAccount(ctx, id, account.Address, sig)
+}
+
+// ApproveTransaction signs the proposed transaction and submits it to
+// observer, then the holder should pay the observer with the transaction
+// hash as memo to activate the approval
+func (h *Holder) ApproveTransaction(ctx context.Context, c *Client, id string) (*Transaction, error) {
+	tx, account, err := h.readTransactionWithAccount(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+	raw, _, err := h.SignTransaction(account, tx.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.ApproveTransaction(ctx, id, h.chain, raw)
+}
+
+// ApproveTransactions signs all the proposed transactions of the same account,
+// and submits them to observer in a batch, then the holder should pay the
+// observer with the batch id as memo to activate the approvals, the other
+// holders of an account with multiple holder keys sign as the cosigners
+func (h *Holder) ApproveTransactions(ctx context.Context, c *Client, ids []string, cosigners ...*Holder) (*Batch, error) {
+	var account *Account
+	var hashes []string
+	var transactions []*BatchTransaction
+	signers := append([]*Holder{h}, cosigners...)
+	for _, id := range ids {
+		tx, err := c.ReadTransaction(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if tx.Chain != h.chain {
+			return nil, fmt.Errorf("invalid transaction chain %d", tx.Chain)
+		}
+		if account == nil {
+			account, err = c.ReadAccount(ctx, tx.AccountId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if tx.AccountId != account.Id {
+			return nil, fmt.Errorf("invalid transaction account %s", tx.AccountId)
+		}
+		raw := tx.Raw
+		for _, s := range signers {
+			raw, _, err = s.SignTransaction(account, raw)
+			if err != nil {
+				return nil, err
+			}
+		}
+		hashes = append(hashes, tx.Hash)
+		transactions = append(transactions, &BatchTransaction{Id: id, Raw: raw})
+	}
+	if account == nil {
+		return nil, fmt.Errorf("empty batch")
+	}
+	sig, err := encodeHolderSignatures(account, signers, func(s *Holder) string {
+		return s.SignBatchApproval(account, hashes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.CreateBatch(ctx, h.chain, transactions, encodeMessageSignature(h.chain, sig))
+}
+
+// ConsolidateAccount submits the consolidation authorisation to observer, the
+// other holders of an account with multiple holder keys sign as the cosigners,
+// and each consolidation proposed should still be approved by the holders
+func (h *Holder) ConsolidateAccount(ctx context.Context, c *Client, id string, cosigners ...*Holder) (*Account, error) {
+	account, err := c.ReadAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if account.Chain != h.chain {
+		return nil, fmt.Errorf("invalid account chain %d", account.Chain)
+	}
+	nonce := uuid.Must(uuid.NewV4()).String()
+	_, err = h.SignConsolidation(account, nonce)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := encodeHolderSignatures(account, append([]*Holder{h}, cosigners...), func(s *Holder) string {
+		sig, _ := s.SignConsolidation(account, nonce)
+		return sig
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c.ConsolidateAccount(ctx, id, account.Address, nonce, encodeMessageSignature(h.chain, sig))
+}
+
+func (h *Holder) RevokeTransaction(ctx context.Context, c *Client, id string) (*Transaction, error) {
+	tx, err := c.ReadTransaction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tx.Chain != h.chain {
+		return nil, fmt.Errorf("invalid transaction chain %d", tx.Chain)
+	}
+	sig := h.SignRevoke(tx.Id, tx.Hash)
+	return c.RevokeTransaction(ctx, id, h.chain, sig)
+}
+
+// CloseAccount signs the recovery transaction, which should be proposed
+// with the recovery flag, and submits it to observer to close the account
+func (h *Holder) CloseAccount(ctx context.Context, c *Client, id string) (*Account, error) {
+	tx, account, err := h.readTransactionWithAccount(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+	raw, hash, err := h.SignTransaction(account, tx.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return c.CloseAccount(ctx, account.Id, account.Address, raw, hash)
+}
+
+func (h *Holder) readTransactionWithAccount(ctx context.Context, c *Client, id string) (*Transaction, *Account, error) {
+	tx, err := c.ReadTransaction(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tx.Chain != h.chain {
+		return nil, nil, fmt.Errorf("invalid transaction chain %d", tx.Chain)
+	}
+	account, err := c.ReadAccount(ctx, tx.AccountId)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tx, account, nil
+}
+
+func (h *Holder) signEthereumMessage(msg []byte) []byte {
+	hash := ethereum.HashMessageForSignature(hex.EncodeToString(msg))
+	sig, err := crypto.Sign(hash, h.private.ToECDSA())
+	if err != nil {
+		panic(err)
+	}
+	return ethereum.ProcessSignature(sig)
+}
+
+// accountHolders returns the holder keys of the account, which is only the
+// holder itself for a nil account or one without multiple holder keys
+func (h *Holder) accountHolders(account *Account) ([]string, int) {
+	if account == nil || len(account.Holders) == 0 {
+		return []string{h.Public()}, 1
+	}
+	return account.Holders, account.HolderThreshold
+}
+
+// encodeHolderSignatures signs with all the signers, and encodes the signatures
+// with common.EncodeHolderSignatures if the account has multiple holder keys
+func encodeHolderSignatures(account *Account, signers []*Holder, sign func(h *Holder) string) ([]byte, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("invalid account signers %s", account.Id)
+	}
+	holders, _ := signers[0].accountHolders(account)
+	sigs := make([][]byte, len(holders))
+	for _, h := range signers {
+		i := slices.Index(holders, h.Public())
+		if i < 0 || h.chain != account.Chain {
+			return nil, fmt.Errorf("invalid account signer %s", h.Public())
+		}
+		var sig []byte
+		var err error
+		switch h.chain {
+		case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+			sig, err = base64.RawURLEncoding.DecodeString(sign(h))
+		default:
+			sig, err = hex.DecodeString(sign(h))
+		}
+		if err != nil {
+			return nil, err
+		}
+		sigs[i] = sig
+	}
+	if len(holders) == 1 {
+		return sigs[0], nil
+	}
+	return common.EncodeHolderSignatures(sigs), nil
+}
+
+// encodeMessageSignature encodes the message signature as the holder sends
+// to observer, base64 for Bitcoin like chains and hex for Ethereum chains
+func encodeMessageSignature(chain byte, sig []byte) string {
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		return base64.RawURLEncoding.EncodeToString(sig)
+	default:
+		return hex.EncodeToString(sig)
+	}
+}
+
+func parseAccountKeys(account *Account) (string, string, error) {
+	if len(account.Keys) != 2 {
+		return "", "", fmt.Errorf("invalid account keys %v", account.Keys)
+	}
+	pubs := make([]string, 2)
+	for i, k := range account.Keys {
+		_, xpub, found := cutFingerprint(k)
+		if !found {
+			return "", "", fmt.Errorf("invalid account key %s", k)
+		}
+		key, err := hdkeychain.NewKeyFromString(xpub)
+		if err != nil {
+			return "", "", err
+		}
+		pub, err := key.ECPubKey()
+		if err != nil {
+			return "", "", err
+		}
+		pubs[i] = hex.EncodeToString(pub.SerializeCompressed())
+	}
+	return pubs[0], pubs[1], nil
+}
+
+func cutFingerprint(key string) (string, string, bool) {
+	if len(key) < 10 || key[0] != '[' || key[9] != ']' {
+		return "", "", false
+	}
+	return key[1:9], key[10:], true
+}
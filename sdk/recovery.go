@@ -0,0 +1,428 @@
+package sdk
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/gofrs/uuid/v5"
+)
+
+// Recovery is the offline recovery request passed between the holders and
+// the observer operator. After the safe timelock expires, the holder and the
+// observer keys are enough to spend all the safe funds, so the safe could be
+// recovered without the signer group, keeper or observer services.
+type Recovery struct {
+	Account *Account `json:"account"`
+	Hash    string   `json:"hash"`
+	Raw     string   `json:"raw"`
+}
+
+type RecoverySummary struct {
+	Receiver string
+	Amount   string
+	Token    string
+}
+
+// BuildBitcoinRecovery spends all the inputs to the receiver with the fee
+// rate in satoshi per vbyte, the inputs should be all the safe outputs
+func BuildBitcoinRecovery(account *Account, inputs []*bitcoin.Input, receiver string, feeRate int64) (*Recovery, error) {
+	if len(inputs) == 0 || feeRate <= 0 {
+		return nil, fmt.Errorf("invalid recovery inputs %d or fee rate %d", len(inputs), feeRate)
+	}
+	if receiver == account.Address {
+		return nil, fmt.Errorf("invalid recovery receiver %s", receiver)
+	}
+	wsa, err := buildRecoveryWitnessScriptAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	var balance int64
+	for _, in := range inputs {
+		in.Script = wsa.Script
+		in.Sequence = wsa.Sequence
+		in.RouteBackup = true
+		balance = balance + in.Satoshi
+	}
+	outputs := []*bitcoin.Output{{Address: receiver, Satoshi: balance}}
+	rid := uuid.FromStringOrNil(common.UniqueId(account.Id, "RECOVERY")).Bytes()
+	psbt, err := bitcoin.BuildPartiallySignedTransaction(inputs, outputs, rid, account.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	// the transaction is built with the full balance to avoid the change
+	// output, then the fee is deducted from the receiver output before signing
+	fee := int64(psbt.EstimateVirtualSize()) * feeRate
+	out := psbt.UnsignedTx.TxOut[0]
+	if out.Value-fee <= bitcoin.ValueDust(account.Chain) {
+		return nil, fmt.Errorf("insufficient recovery balance %d for fee %d", balance, fee)
+	}
+	out.Value = out.Value - fee
+	return &Recovery{
+		Account: account,
+		Hash:    psbt.Hash(),
+		Raw:     hex.EncodeToString(psbt.Marshal()),
+	}, nil
+}
+
+// BuildEthereumRecovery transfers all the outputs to their destinations with
+// the safe nonce, the outputs should be all the safe balances
+func BuildEthereumRecovery(ctx context.Context, account *Account, outputs []*ethereum.Output, nonce int64) (*Recovery, error) {
+	if len(outputs) == 0 || len(outputs) > 256 {
+		return nil, fmt.Errorf("invalid recovery outputs %d", len(outputs))
+	}
+	for _, o := range outputs {
+		if o.Destination == account.Address || o.Amount == nil || o.Amount.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid recovery output %v", o)
+		}
+	}
+	err := verifyRecoveryGnosisSafe(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	typ := ethereum.TypeMultiSendTx
+	if len(outputs) == 1 {
+		typ = ethereum.TypeETHTx
+		if outputs[0].TokenAddress != ethereum.EthereumEmptyAddress {
+			typ = ethereum.TypeERC20Tx
+		}
+	}
+	id := common.UniqueId(account.Id, "RECOVERY")
+	chainId := ethereum.GetEvmChainID(int64(account.Chain))
+	st, err := ethereum.CreateTransactionFromOutputs(ctx, typ, chainId, id, account.Address, outputs, big.NewInt(nonce))
+	if err != nil {
+		return nil, err
+	}
+	return &Recovery{
+		Account: account,
+		Hash:    st.TxHash,
+		Raw:     hex.EncodeToString(st.Marshal()),
+	}, nil
+}
+
+// SignWithHolder adds the holder signatures, each holder key should sign the
+// request until the holders threshold reached
+func (r *Recovery) SignWithHolder(h *Holder) error {
+	if h.Chain() != r.Account.Chain {
+		return fmt.Errorf("invalid holder chain %d", h.Chain())
+	}
+	holders, _ := h.accountHolders(r.Account)
+	if !slices.Contains(holders, h.Public()) {
+		return fmt.Errorf("invalid holder %s", h.Public())
+	}
+	return r.sign(h)
+}
+
+// SignWithObserver adds the observer signatures with the observer private
+// key, the Bitcoin safe observer key is derived with the descriptor path
+func (r *Recovery) SignWithObserver(priv string) error {
+	seed, err := hex.DecodeString(priv)
+	if err != nil || len(seed) != 32 {
+		return fmt.Errorf("invalid private key %d", len(seed))
+	}
+	private, _ := btcec.PrivKeyFromBytes(seed)
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		_, observer, err := parseDescriptorKeys(r.Account)
+		if err != nil {
+			return err
+		}
+		private, err = deriveDescriptorPrivateKey(private, observer)
+		if err != nil {
+			return err
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+	default:
+		return fmt.Errorf("invalid chain %d", r.Account.Chain)
+	}
+
+	// the observer signs the recovery transaction the same way as a holder
+	h := &Holder{chain: r.Account.Chain, private: private}
+	_, observer, err := r.accountKeys()
+	if err != nil {
+		return err
+	}
+	if h.Public() != observer {
+		return fmt.Errorf("invalid observer %s %s", h.Public(), observer)
+	}
+	return r.sign(h)
+}
+
+// Summary returns the outputs of the recovery transaction for review, the
+// observer operator should always check them before signing
+func (r *Recovery) Summary() ([]*RecoverySummary, error) {
+	rb, err := hex.DecodeString(r.Raw)
+	if err != nil {
+		return nil, err
+	}
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		if err != nil {
+			return nil, err
+		}
+		out := psbt.UnsignedTx.TxOut[0]
+		receiver, err := bitcoin.ExtractPkScriptAddr(out.PkScript, r.Account.Chain)
+		if err != nil {
+			return nil, err
+		}
+		return []*RecoverySummary{{
+			Receiver: receiver,
+			Amount:   fmt.Sprint(out.Value),
+		}}, nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		st, err := ethereum.UnmarshalSafeTransaction(rb)
+		if err != nil {
+			return nil, err
+		}
+		var summary []*RecoverySummary
+		for _, o := range st.ExtractOutputs() {
+			summary = append(summary, &RecoverySummary{
+				Receiver: o.Destination,
+				Amount:   o.Amount.String(),
+				Token:    o.TokenAddress,
+			})
+		}
+		return summary, nil
+	default:
+		return nil, fmt.Errorf("invalid chain %d", r.Account.Chain)
+	}
+}
+
+// Finalize returns the broadcastable Bitcoin transaction, or the Ethereum
+// execTransaction call data to the safe address, the Gnosis safe guard only
+// accepts the observer signature after the timelock expired
+func (r *Recovery) Finalize() (string, error) {
+	rb, err := hex.DecodeString(r.Raw)
+	if err != nil {
+		return "", err
+	}
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		if err != nil {
+			return "", err
+		}
+		if !psbt.IsRecoveryTransaction() {
+			return "", fmt.Errorf("invalid recovery transaction %s", psbt.Hash())
+		}
+		signer, observer, err := r.accountKeys()
+		if err != nil {
+			return "", err
+		}
+		msgTx, err := psbt.SignedTransactionWithHolders(r.Account.Holders, r.Account.HolderThreshold, signer, observer)
+		if err != nil {
+			return "", err
+		}
+		raw, err := bitcoin.MarshalWiredTransaction(msgTx, wire.WitnessEncoding, r.Account.Chain)
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(raw), nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		st, err := ethereum.UnmarshalSafeTransaction(rb)
+		if err != nil {
+			return "", err
+		}
+		if !ethereum.CheckTransactionPartiallySignedByHolders(r.Raw, r.Account.Holders, r.Account.HolderThreshold) {
+			return "", fmt.Errorf("ethereum.CheckTransactionPartiallySignedByHolders(%s) holder", r.Hash)
+		}
+		_, observer, err := r.accountKeys()
+		if err != nil {
+			return "", err
+		}
+		if !ethereum.CheckTransactionPartiallySignedBy(r.Raw, observer) {
+			return "", fmt.Errorf("ethereum.CheckTransactionPartiallySignedBy(%s) observer", r.Hash)
+		}
+		data, err := st.ExecTransactionData()
+		if err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(data), nil
+	default:
+		return "", fmt.Errorf("invalid chain %d", r.Account.Chain)
+	}
+}
+
+func (r *Recovery) sign(h *Holder) error {
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		rb, err := hex.DecodeString(r.Raw)
+		if err != nil {
+			return err
+		}
+		psbt, err := bitcoin.UnmarshalPartiallySignedTransaction(rb)
+		if err != nil {
+			return err
+		}
+		if !psbt.IsRecoveryTransaction() {
+			return fmt.Errorf("invalid recovery transaction %s", psbt.Hash())
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+	default:
+		return fmt.Errorf("invalid chain %d", r.Account.Chain)
+	}
+	raw, hash, err := h.SignTransaction(r.Account, r.Raw)
+	if err != nil {
+		return err
+	}
+	if hash != r.Hash {
+		return fmt.Errorf("invalid recovery hash %s %s", hash, r.Hash)
+	}
+	r.Raw = raw
+	return nil
+}
+
+// accountKeys returns the signer and observer public keys of the safe, which
+// are derived with the descriptor path for Bitcoin safes
+func (r *Recovery) accountKeys() (string, string, error) {
+	switch r.Account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		_, err := buildRecoveryWitnessScriptAccount(r.Account)
+		if err != nil {
+			return "", "", err
+		}
+		signer, observer, err := parseDescriptorKeys(r.Account)
+		if err != nil {
+			return "", "", err
+		}
+		spk, err := signer.Derive()
+		if err != nil {
+			return "", "", err
+		}
+		opk, err := observer.Derive()
+		if err != nil {
+			return "", "", err
+		}
+		return spk, opk, nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		return parseAccountKeys(r.Account)
+	default:
+		return "", "", fmt.Errorf("invalid chain %d", r.Account.Chain)
+	}
+}
+
+func buildRecoveryWitnessScriptAccount(account *Account) (*bitcoin.WitnessScriptAccount, error) {
+	switch account.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+	default:
+		return nil, fmt.Errorf("invalid chain %d", account.Chain)
+	}
+	signer, observer, err := parseDescriptorKeys(account)
+	if err != nil {
+		return nil, err
+	}
+	spk, err := signer.Derive()
+	if err != nil {
+		return nil, err
+	}
+	opk, err := observer.Derive()
+	if err != nil {
+		return nil, err
+	}
+	lock := time.Duration(account.Timelock) * time.Hour
+	wsa, err := bitcoin.BuildWitnessScriptAccountWithHolders(account.Holders, account.HolderThreshold, spk, opk, lock, account.Chain)
+	if err != nil {
+		return nil, err
+	}
+	if wsa.Address != account.Address || hex.EncodeToString(wsa.Script) != account.Script {
+		return nil, fmt.Errorf("invalid account address %s %s", wsa.Address, account.Address)
+	}
+	return wsa, nil
+}
+
+func verifyRecoveryGnosisSafe(ctx context.Context, account *Account) error {
+	signer, observer, err := parseAccountKeys(account)
+	if err != nil {
+		return err
+	}
+	lock := time.Duration(account.Timelock) * time.Hour
+	gs, _, err := ethereum.BuildGnosisSafeWithHolders(ctx, "", account.Holders, account.HolderThreshold, signer, observer, account.Id, lock, account.Chain)
+	if err != nil {
+		return err
+	}
+	if gs.Address != account.Address {
+		return fmt.Errorf("invalid account address %s %s", gs.Address, account.Address)
+	}
+	return nil
+}
+
+// parseDescriptorKeys returns the signer and observer keys with the derivation
+// path from the descriptor, i.e. s:pk([fingerprint]xpub/0/0) and v:pk(...)
+func parseDescriptorKeys(account *Account) (*bitcoin.DescriptorKey, *bitcoin.DescriptorKey, error) {
+	signer, err := parseDescriptorKey(account.Descriptor, "s:pk(")
+	if err != nil {
+		return nil, nil, err
+	}
+	observer, err := parseDescriptorKey(account.Descriptor, "v:pk(")
+	if err != nil {
+		return nil, nil, err
+	}
+	return signer, observer, nil
+}
+
+func parseDescriptorKey(descriptor, fragment string) (*bitcoin.DescriptorKey, error) {
+	_, key, found := strings.Cut(descriptor, fragment)
+	if !found {
+		return nil, fmt.Errorf("invalid descriptor %s", descriptor)
+	}
+	key, _, found = strings.Cut(key, ")")
+	if !found {
+		return nil, fmt.Errorf("invalid descriptor %s", descriptor)
+	}
+	origin, key, found := strings.Cut(key, "]")
+	if !found {
+		return nil, fmt.Errorf("invalid descriptor key %s", key)
+	}
+	parts := strings.Split(key, "/")
+	path := make([]uint32, len(parts)-1)
+	for i, p := range parts[1:] {
+		index, err := strconv.ParseUint(p, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid descriptor key path %s", key)
+		}
+		path[i] = uint32(index)
+	}
+	return bitcoin.ParseDescriptorKey(origin+"]"+parts[0], path)
+}
+
+// deriveDescriptorPrivateKey derives the private key of the descriptor key
+// with the chain code of the extended public key, the path is not hardened
+func deriveDescriptorPrivateKey(private *btcec.PrivateKey, key *bitcoin.DescriptorKey) (*btcec.PrivateKey, error) {
+	xpub, err := hdkeychain.NewKeyFromString(key.XPub)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := xpub.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	if !pub.IsEqual(private.PubKey()) {
+		return nil, fmt.Errorf("invalid private key for %s", key.Info())
+	}
+	version := chaincfg.MainNetParams.HDPrivateKeyID[:]
+	parentFP := []byte{0x00, 0x00, 0x00, 0x00}
+	extPriv := hdkeychain.NewExtendedKey(version, private.Serialize(), xpub.ChainCode(), parentFP, 0, 0, true)
+	for _, i := range key.Path {
+		extPriv, err = extPriv.Derive(i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return extPriv.ECPrivKey()
+}
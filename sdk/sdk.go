@@ -0,0 +1,333 @@
+package sdk
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/shopspring/decimal"
+)
+
+// Network describes the safe network a holder talks to, the keeper MTG
+// receives all holder operations, and the observer receives the fees
+// for transaction approvals and account recoveries
+type Network struct {
+	KeeperMembers   []string
+	KeeperThreshold int
+	ObserverId      string
+}
+
+// Payment is the instruction for a Mixin transfer to make, the holder
+// should pay it with any Mixin wallet, and the trace id is the request
+// id used to query the result from the observer HTTP API
+type Payment struct {
+	TraceId   string
+	AssetId   string
+	Amount    decimal.Decimal
+	Memo      string
+	Receivers []string
+	Threshold int
+}
+
+type AccountProposal struct {
+	Chain     byte
+	Timelock  time.Duration
+	Receivers []string
+	Threshold byte
+	Observer  string
+	Policy    *common.SafePolicy
+
+	// Holders are the other holder keys besides the proposer, and the
+	// HolderThreshold of all holder keys are required to move funds
+	Holders         []string
+	HolderThreshold byte
+}
+
+type TransactionProposal struct {
+	Chain         byte
+	Recovery      bool
+	NetworkInfoId string
+	Receiver      string
+	SafeAssetId   string
+	Amount        decimal.Decimal
+}
+
+func EncodeOperation(op *common.Operation) string {
+	return base64.RawURLEncoding.EncodeToString(op.Encode())
+}
+
+func DecodeOperation(memo string) (*common.Operation, error) {
+	b, err := base64.RawURLEncoding.DecodeString(memo)
+	if err != nil {
+		return nil, err
+	}
+	return common.DecodeOperation(b)
+}
+
+// ProposeAccount builds the payment to propose a safe account, the asset
+// and amount should be the operation price returned by the network
+func (n *Network) ProposeAccount(id, holder string, ap *AccountProposal, assetId string, amount decimal.Decimal) (*Payment, error) {
+	action, err := actionForChain(ap.Chain, common.ActionBitcoinSafeProposeAccount, common.ActionEthereumSafeProposeAccount)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := encodeAccountProposal(holder, ap)
+	if err != nil {
+		return nil, err
+	}
+
+	op := &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  common.SafeChainCurve(ap.Chain),
+		Public: holder,
+		Extra:  extra,
+	}
+	return n.keeperPayment(op, assetId, amount), nil
+}
+
+// MigrateAccount builds the payment to move all funds of the account of holder
+// to a new account of newHolder, proposed with ap. The payment is in the
+// operation price asset, the old account is closed after the sweep is signed
+func (n *Network) MigrateAccount(id, holder, newHolder string, ap *AccountProposal, assetId string, amount decimal.Decimal) (*Payment, error) {
+	action, err := actionForChain(ap.Chain, common.ActionBitcoinSafeMigrateAccount, common.ActionEthereumSafeMigrateAccount)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyHolderKey(ap.Chain, holder)
+	if err != nil {
+		return nil, err
+	}
+	if newHolder == holder {
+		return nil, fmt.Errorf("invalid new holder %s", newHolder)
+	}
+	proposal, err := encodeAccountProposal(newHolder, ap)
+	if err != nil {
+		return nil, err
+	}
+	extra := append(common.DecodeHexOrPanic(newHolder), proposal...)
+
+	op := &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  common.SafeChainCurve(ap.Chain),
+		Public: holder,
+		Extra:  extra,
+	}
+	return n.keeperPayment(op, assetId, amount), nil
+}
+
+// ConvertAsset builds the payment to convert the safe asset of the migrated
+// account of holder to the safe asset of the new account, the amount of the
+// new safe asset is paid to the receivers of the new account
+func (n *Network) ConvertAsset(id string, chain byte, holder, safeAssetId string, amount decimal.Decimal) (*Payment, error) {
+	action, err := actionForChain(chain, common.ActionBitcoinSafeConvertAsset, common.ActionEthereumSafeConvertAsset)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyHolderKey(chain, holder)
+	if err != nil {
+		return nil, err
+	}
+	if !amount.IsPositive() {
+		return nil, fmt.Errorf("invalid amount %s", amount)
+	}
+
+	op := &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  common.SafeChainCurve(chain),
+		Public: holder,
+	}
+	return n.keeperPayment(op, safeAssetId, amount), nil
+}
+
+func encodeAccountProposal(holder string, ap *AccountProposal) ([]byte, error) {
+	err := verifyHolderKey(ap.Chain, holder)
+	if err != nil {
+		return nil, err
+	}
+	if ap.Timelock < bitcoin.TimeLockMinimum || ap.Timelock > bitcoin.TimeLockMaximum {
+		return nil, fmt.Errorf("invalid timelock %s", ap.Timelock)
+	}
+	if ap.Timelock%time.Hour != 0 {
+		return nil, fmt.Errorf("invalid timelock %s", ap.Timelock)
+	}
+	total := len(ap.Receivers)
+	if total == 0 || total > 255 || int(ap.Threshold) > total || ap.Threshold == 0 {
+		return nil, fmt.Errorf("invalid receivers %d/%d", ap.Threshold, total)
+	}
+
+	extra := binary.BigEndian.AppendUint16(nil, uint16(ap.Timelock/time.Hour))
+	extra = append(extra, ap.Threshold, byte(total))
+	for _, r := range ap.Receivers {
+		uid, err := uuid.FromString(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid receiver %s", r)
+		}
+		extra = append(extra, uid.Bytes()...)
+	}
+	if ap.Observer != "" {
+		err = verifyHolderKey(ap.Chain, ap.Observer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid observer %s", ap.Observer)
+		}
+		extra = append(extra, common.DecodeHexOrPanic(ap.Observer)...)
+	}
+	if len(ap.Holders) > 0 {
+		holders := append([]string{holder}, ap.Holders...)
+		err = common.VerifySafeHolders(holders, ap.HolderThreshold, ap.Chain)
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, common.SafeHoldersFlag, ap.HolderThreshold, byte(len(ap.Holders)))
+		for _, h := range ap.Holders {
+			extra = append(extra, common.DecodeHexOrPanic(h)...)
+		}
+	}
+	if ap.Policy != nil {
+		err = ap.Policy.Verify(ap.Chain)
+		if err != nil {
+			return nil, err
+		}
+		extra = append(extra, common.MarshalJSONOrPanic(ap.Policy)...)
+	}
+	return extra, nil
+}
+
+// ProposeTransaction builds the payment to propose a transaction, the amount
+// is paid in the safe asset of the account, and will be transferred to the
+// receiver. A recovery transaction moves all the account funds to receiver
+func (n *Network) ProposeTransaction(id, holder string, tp *TransactionProposal) (*Payment, error) {
+	action, err := actionForChain(tp.Chain, common.ActionBitcoinSafeProposeTransaction, common.ActionEthereumSafeProposeTransaction)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyHolderKey(tp.Chain, holder)
+	if err != nil {
+		return nil, err
+	}
+	if !tp.Amount.IsPositive() {
+		return nil, fmt.Errorf("invalid amount %s", tp.Amount)
+	}
+	iid, err := uuid.FromString(tp.NetworkInfoId)
+	if err != nil || iid.String() == uuid.Nil.String() {
+		return nil, fmt.Errorf("invalid network info %s", tp.NetworkInfoId)
+	}
+	switch tp.Chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		_, err = bitcoin.ParseAddress(tp.Receiver, tp.Chain)
+		if err != nil {
+			return nil, err
+		}
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		norm := ethereum.NormalizeAddress(tp.Receiver)
+		if norm == ethereum.EthereumEmptyAddress {
+			return nil, fmt.Errorf("invalid receiver %s", tp.Receiver)
+		}
+	}
+
+	extra := []byte{common.FlagProposeNormalTransaction}
+	if tp.Recovery {
+		extra[0] = common.FlagProposeRecoveryTransaction
+	}
+	extra = append(extra, iid.Bytes()...)
+	extra = append(extra, []byte(tp.Receiver)...)
+
+	op := &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  common.SafeChainCurve(tp.Chain),
+		Public: holder,
+		Extra:  extra,
+	}
+	return n.keeperPayment(op, tp.SafeAssetId, tp.Amount), nil
+}
+
+// UpdatePolicy builds the payment to replace the spending policy of the safe,
+// the policy is signed by the signers, which should reach the holder threshold
+// of the account. The asset and amount should be the operation price returned
+// by the network, and the new policy becomes active after the delay of the
+// current one
+func (n *Network) UpdatePolicy(id, holder string, account *Account, signers []*Holder, policy *common.SafePolicy, assetId string, amount decimal.Decimal) (*Payment, error) {
+	action, err := actionForChain(account.Chain, common.ActionBitcoinSafeUpdatePolicy, common.ActionEthereumSafeUpdatePolicy)
+	if err != nil {
+		return nil, err
+	}
+	err = verifyHolderKey(account.Chain, holder)
+	if err != nil {
+		return nil, err
+	}
+	err = policy.Verify(account.Chain)
+	if err != nil {
+		return nil, err
+	}
+
+	pb := common.MarshalJSONOrPanic(policy)
+	sig, err := encodeHolderSignatures(account, signers, func(h *Holder) string {
+		return h.SignPolicy(account, id, pb)
+	})
+	if err != nil {
+		return nil, err
+	}
+	update := &common.SafePolicyUpdate{Policy: pb, Signature: sig}
+	op := &common.Operation{
+		Id:     id,
+		Type:   action,
+		Curve:  common.SafeChainCurve(account.Chain),
+		Public: holder,
+		Extra:  update.Encode(),
+	}
+	return n.keeperPayment(op, assetId, amount), nil
+}
+
+// PayObserver builds the payment to activate a transaction approval or an
+// account recovery, the memo is the transaction hash
+func (n *Network) PayObserver(traceId, hash, assetId string, amount decimal.Decimal) *Payment {
+	return &Payment{
+		TraceId:   traceId,
+		AssetId:   assetId,
+		Amount:    amount,
+		Memo:      hash,
+		Receivers: []string{n.ObserverId},
+		Threshold: 1,
+	}
+}
+
+func (n *Network) keeperPayment(op *common.Operation, assetId string, amount decimal.Decimal) *Payment {
+	return &Payment{
+		TraceId:   op.Id,
+		AssetId:   assetId,
+		Amount:    amount,
+		Memo:      EncodeOperation(op),
+		Receivers: n.KeeperMembers,
+		Threshold: n.KeeperThreshold,
+	}
+}
+
+func actionForChain(chain byte, bitcoinAction, ethereumAction int) (byte, error) {
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		return byte(bitcoinAction), nil
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		return byte(ethereumAction), nil
+	default:
+		return 0, fmt.Errorf("invalid chain %d", chain)
+	}
+}
+
+func verifyHolderKey(chain byte, public string) error {
+	switch chain {
+	case common.SafeChainBitcoin, common.SafeChainLitecoin, common.SafeChainDogecoin:
+		return bitcoin.VerifyHolderKey(public)
+	case common.SafeChainEthereum, common.SafeChainPolygon:
+		return ethereum.VerifyHolderKey(public)
+	default:
+		return fmt.Errorf("invalid chain %d", chain)
+	}
+}
@@ -0,0 +1,600 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/safe/apps/bitcoin"
+	"github.com/MixinNetwork/safe/apps/ethereum"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/keeper"
+	"github.com/MixinNetwork/safe/keeper/store"
+	"github.com/MixinNetwork/safe/observer"
+	"github.com/MixinNetwork/trusted-group/mtg"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/gofrs/uuid/v5"
+	"github.com/pelletier/go-toml"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testHolderPrivate = "52250bb9b9edc5d54466182778a6470a5ee34033c215c92dd250b9c2ce543556"
+	testReceiverId    = "e459de8b-4edd-44ff-a119-b1d707f8521a"
+	testOperationId   = "31d2ea9c-95eb-3355-b65b-ba096853bc18"
+	testTimelock      = 24 * time.Hour
+)
+
+func TestOperations(t *testing.T) {
+	require := require.New(t)
+
+	network := &Network{
+		KeeperMembers:   []string{uuid.Must(uuid.NewV4()).String(), uuid.Must(uuid.NewV4()).String()},
+		KeeperThreshold: 2,
+		ObserverId:      uuid.Must(uuid.NewV4()).String(),
+	}
+	holder, err := NewHolder(common.SafeChainBitcoin, testHolderPrivate)
+	require.Nil(err)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	ap := &AccountProposal{
+		Chain:     common.SafeChainBitcoin,
+		Timelock:  testTimelock,
+		Receivers: []string{testReceiverId},
+		Threshold: 1,
+	}
+	pay, err := network.ProposeAccount(id, holder.Public(), ap, testOperationId, decimal.NewFromInt(1))
+	require.Nil(err)
+	require.Equal(id, pay.TraceId)
+	require.Equal(network.KeeperMembers, pay.Receivers)
+	require.Equal(2, pay.Threshold)
+	op, err := DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(id, op.Id)
+	require.Equal(byte(common.ActionBitcoinSafeProposeAccount), op.Type)
+	require.Equal(byte(common.CurveSecp256k1ECDSABitcoin), op.Curve)
+	require.Equal(holder.Public(), op.Public)
+	require.Equal(uint16(24), binary.BigEndian.Uint16(op.Extra[:2]))
+	require.Equal([]byte{1, 1}, op.Extra[2:4])
+	require.Equal(uuid.FromStringOrNil(testReceiverId).Bytes(), op.Extra[4:])
+
+	ap.Timelock = time.Minute
+	_, err = network.ProposeAccount(id, holder.Public(), ap, testOperationId, decimal.NewFromInt(1))
+	require.NotNil(err)
+	ap.Timelock = testTimelock
+	ap.Threshold = 2
+	_, err = network.ProposeAccount(id, holder.Public(), ap, testOperationId, decimal.NewFromInt(1))
+	require.NotNil(err)
+	ap.Chain = common.SafeChainPolygon
+	ap.Threshold = 1
+	pay, err = network.ProposeAccount(id, holder.Public(), ap, testOperationId, decimal.NewFromInt(1))
+	require.Nil(err)
+	op, err = DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(byte(common.ActionEthereumSafeProposeAccount), op.Type)
+	require.Equal(byte(common.CurveSecp256k1ECDSAPolygon), op.Curve)
+
+	ap.Chain = common.SafeChainBitcoin
+	priv, _ := btcec.NewPrivateKey()
+	newHolder := hex.EncodeToString(priv.PubKey().SerializeCompressed())
+	pay, err = network.MigrateAccount(id, holder.Public(), newHolder, ap, testOperationId, decimal.NewFromInt(1))
+	require.Nil(err)
+	op, err = DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(byte(common.ActionBitcoinSafeMigrateAccount), op.Type)
+	require.Equal(holder.Public(), op.Public)
+	require.Equal(newHolder, hex.EncodeToString(op.Extra[:33]))
+	require.Equal(uint16(24), binary.BigEndian.Uint16(op.Extra[33:35]))
+	require.Equal(uuid.FromStringOrNil(testReceiverId).Bytes(), op.Extra[37:])
+	_, err = network.MigrateAccount(id, holder.Public(), holder.Public(), ap, testOperationId, decimal.NewFromInt(1))
+	require.NotNil(err)
+	bond := uuid.Must(uuid.NewV4()).String()
+	pay, err = network.ConvertAsset(id, common.SafeChainBitcoin, holder.Public(), bond, decimal.RequireFromString("0.001"))
+	require.Nil(err)
+	require.Equal(bond, pay.AssetId)
+	op, err = DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(byte(common.ActionBitcoinSafeConvertAsset), op.Type)
+	require.Equal(holder.Public(), op.Public)
+	require.Len(op.Extra, 0)
+	_, err = network.ConvertAsset(id, common.SafeChainBitcoin, holder.Public(), bond, decimal.Zero)
+	require.NotNil(err)
+
+	tp := &TransactionProposal{
+		Chain:         common.SafeChainBitcoin,
+		NetworkInfoId: uuid.Must(uuid.NewV4()).String(),
+		Receiver:      "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e",
+		SafeAssetId:   uuid.Must(uuid.NewV4()).String(),
+		Amount:        decimal.RequireFromString("0.001"),
+	}
+	pay, err = network.ProposeTransaction(id, holder.Public(), tp)
+	require.Nil(err)
+	require.Equal(tp.SafeAssetId, pay.AssetId)
+	require.Equal("0.001", pay.Amount.String())
+	op, err = DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(byte(common.ActionBitcoinSafeProposeTransaction), op.Type)
+	require.Equal(byte(common.FlagProposeNormalTransaction), op.Extra[0])
+	require.Equal(uuid.FromStringOrNil(tp.NetworkInfoId).Bytes(), op.Extra[1:17])
+	require.Equal(tp.Receiver, string(op.Extra[17:]))
+	tp.Recovery = true
+	pay, err = network.ProposeTransaction(id, holder.Public(), tp)
+	require.Nil(err)
+	op, _ = DecodeOperation(pay.Memo)
+	require.Equal(byte(common.FlagProposeRecoveryTransaction), op.Extra[0])
+	tp.Receiver = "0x9d04735aaEB73535672200950fA77C2dFC86eB21"
+	_, err = network.ProposeTransaction(id, holder.Public(), tp)
+	require.NotNil(err)
+
+	account := &Account{Id: testOperationId, Chain: common.SafeChainBitcoin, Address: "address"}
+	policy := &common.SafePolicy{Allowlist: []string{"bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"}}
+	pay, err = network.UpdatePolicy(id, holder.Public(), account, []*Holder{holder}, policy, testOperationId, decimal.NewFromInt(1))
+	require.Nil(err)
+	op, err = DecodeOperation(pay.Memo)
+	require.Nil(err)
+	require.Equal(byte(common.ActionBitcoinSafeUpdatePolicy), op.Type)
+	update, err := common.DecodeSafePolicyUpdate(op.Extra)
+	require.Nil(err)
+	require.Equal(`{"allowlist":["bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"]}`, string(update.Policy))
+	msg := bitcoin.HashMessageForSignature(common.SafePolicyMessage(testOperationId, "address", id, update.Policy), common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, update.Signature))
+	_, err = network.UpdatePolicy(id, holder.Public(), account, nil, policy, testOperationId, decimal.NewFromInt(1))
+	require.NotNil(err)
+
+	pay = network.PayObserver(id, "hash", testOperationId, decimal.NewFromInt(1))
+	require.Equal([]string{network.ObserverId}, pay.Receivers)
+	require.Equal(1, pay.Threshold)
+	require.Equal("hash", pay.Memo)
+}
+
+func TestHolderSignatures(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	holder, err := NewHolder(common.SafeChainBitcoin, testHolderPrivate)
+	require.Nil(err)
+	sig := holder.SignRevoke(testOperationId, "hash")
+	sb, err := base64.RawURLEncoding.DecodeString(sig)
+	require.Nil(err)
+	msg := bitcoin.HashMessageForSignature("REVOKE:"+testOperationId+":hash", common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, sb))
+	sig, err = holder.SignConsolidation(&Account{Id: testOperationId, Address: "address"}, testOperationId)
+	require.Nil(err)
+	sb, err = base64.RawURLEncoding.DecodeString(sig)
+	require.Nil(err)
+	msg = bitcoin.HashMessageForSignature("CONSOLIDATE:"+testOperationId+":address:"+testOperationId, common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, sb))
+	sig = holder.SignTimelockRefresh(&Account{Id: testOperationId, Address: "address"}, testOperationId)
+	sb, err = base64.RawURLEncoding.DecodeString(sig)
+	require.Nil(err)
+	msg = bitcoin.HashMessageForSignature("REFRESH:"+testOperationId+":address:"+testOperationId, common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, sb))
+	sig = holder.SignBatchApproval(&Account{Id: testOperationId}, []string{"hash1", "hash2"})
+	sb, err = base64.RawURLEncoding.DecodeString(sig)
+	require.Nil(err)
+	msg = bitcoin.HashMessageForSignature(common.BatchApprovalMessage(testOperationId, []string{"hash1", "hash2"}), common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, sb))
+	spk, _ := testGenerateKey(require)
+	opk, _ := testGenerateKey(require)
+	wsa, err := bitcoin.BuildWitnessScriptAccount(holder.Public(), spk, opk, testTimelock, common.SafeChainBitcoin)
+	require.Nil(err)
+	input := &bitcoin.Input{
+		TransactionHash: crypto.Sha256Hash([]byte(testOperationId)).String(),
+		Index:           0,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        uint32(bitcoin.ParseSequence(testTimelock, common.SafeChainBitcoin)),
+	}
+	outputs := []*bitcoin.Output{{Address: "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e", Satoshi: 50000}}
+	psbt, err := bitcoin.BuildPartiallySignedTransaction([]*bitcoin.Input{input}, outputs, nil, common.SafeChainBitcoin)
+	require.Nil(err)
+	raw, hash, err := holder.SignTransaction(nil, hex.EncodeToString(psbt.Marshal()))
+	require.Nil(err)
+	require.Equal(psbt.Hash(), hash)
+	hpsbt, err := bitcoin.UnmarshalPartiallySignedTransaction(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	require.Len(hpsbt.Inputs[0].PartialSigs, 1)
+	require.Equal(holder.Public(), hex.EncodeToString(hpsbt.Inputs[0].PartialSigs[0].PubKey))
+
+	holder, err = NewHolder(common.SafeChainPolygon, testHolderPrivate)
+	require.Nil(err)
+	sig = holder.SignRevoke(testOperationId, "hash")
+	sb, err = hex.DecodeString(sig)
+	require.Nil(err)
+	require.Nil(ethereum.VerifyMessageSignature(holder.Public(), []byte("REVOKE:"+testOperationId+":hash"), sb))
+	sig = holder.SignBatchApproval(&Account{Id: testOperationId}, []string{"hash1", "hash2"})
+	sb, err = hex.DecodeString(sig)
+	require.Nil(err)
+	require.Nil(ethereum.VerifyMessageSignature(holder.Public(), []byte(common.BatchApprovalMessage(testOperationId, []string{"hash1", "hash2"})), sb))
+	_, err = holder.SignConsolidation(&Account{Id: testOperationId, Address: "address"}, testOperationId)
+	require.NotNil(err)
+
+	signer, signerChainCode := testGenerateKey(require)
+	observerKey, observerChainCode := testGenerateKey(require)
+	keys := []string{testViewXPub(require, signer, signerChainCode), testViewXPub(require, observerKey, observerChainCode)}
+	spk, opk, err = parseAccountKeys(&Account{Keys: keys})
+	require.Nil(err)
+	require.Equal(signer, spk)
+	require.Equal(observerKey, opk)
+
+	id := uuid.Must(uuid.NewV4()).String()
+	gs, st, err := ethereum.BuildGnosisSafe(ctx, "", holder.Public(), signer, observerKey, id, testTimelock, common.SafeChainPolygon)
+	require.Nil(err)
+	account := &Account{
+		Id:       id,
+		Chain:    common.SafeChainPolygon,
+		Address:  gs.Address,
+		Keys:     keys,
+		Timelock: int64(testTimelock / time.Hour),
+	}
+	sig, err = holder.SignAccountApproval(ctx, account)
+	require.Nil(err)
+	sb, err = hex.DecodeString(sig)
+	require.Nil(err)
+	require.Nil(ethereum.VerifyMessageSignature(holder.Public(), st.Message, sb))
+	account.Address = ethereum.EthereumEmptyAddress
+	_, err = holder.SignAccountApproval(ctx, account)
+	require.NotNil(err)
+	account.Address = gs.Address
+
+	raw, hash, err = holder.SignTransaction(account, hex.EncodeToString(st.Marshal()))
+	require.Nil(err)
+	require.Equal(st.TxHash, hash)
+	require.True(ethereum.CheckTransactionPartiallySignedBy(raw, holder.Public()))
+	require.False(ethereum.CheckTransactionPartiallySignedBy(raw, signer))
+}
+
+func TestOfflineRecovery(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	holder, err := NewHolder(common.SafeChainBitcoin, testHolderPrivate)
+	require.Nil(err)
+	signer, signerChainCode := testGenerateKey(require)
+	seed := make([]byte, 32)
+	_, err = rand.Read(seed)
+	require.Nil(err)
+	_, observerPub := btcec.PrivKeyFromBytes(seed)
+	observerKey := hex.EncodeToString(observerPub.SerializeCompressed())
+	observerChainCode := make([]byte, 32)
+	_, err = rand.Read(observerChainCode)
+	require.Nil(err)
+	keys := []string{testViewXPub(require, signer, signerChainCode), testViewXPub(require, observerKey, observerChainCode)}
+
+	_, sdk, err := bitcoin.DeriveBIP32(signer, signerChainCode, 0, 0)
+	require.Nil(err)
+	_, odk, err := bitcoin.DeriveBIP32(observerKey, observerChainCode, 0, 0)
+	require.Nil(err)
+	wsa, err := bitcoin.BuildWitnessScriptAccount(holder.Public(), sdk, odk, testTimelock, common.SafeChainBitcoin)
+	require.Nil(err)
+	sdkey, err := bitcoin.ParseDescriptorKey(keys[0], []uint32{0, 0})
+	require.Nil(err)
+	odkey, err := bitcoin.ParseDescriptorKey(keys[1], []uint32{0, 0})
+	require.Nil(err)
+	descriptor, err := bitcoin.BuildWitnessScriptDescriptor(holder.Public(), sdkey, odkey, wsa.Sequence)
+	require.Nil(err)
+	account := &Account{
+		Id:              uuid.Must(uuid.NewV4()).String(),
+		Chain:           common.SafeChainBitcoin,
+		Address:         wsa.Address,
+		Script:          hex.EncodeToString(wsa.Script),
+		Descriptor:      descriptor,
+		Keys:            keys,
+		Timelock:        int64(testTimelock / time.Hour),
+		Holders:         []string{holder.Public()},
+		HolderThreshold: 1,
+	}
+
+	receiver := "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e"
+	inputs := []*bitcoin.Input{{
+		TransactionHash: crypto.Sha256Hash([]byte(account.Id)).String(),
+		Index:           1,
+		Satoshi:         100000,
+	}}
+	_, err = BuildBitcoinRecovery(account, inputs, account.Address, 10)
+	require.NotNil(err)
+	r, err := BuildBitcoinRecovery(account, inputs, receiver, 10)
+	require.Nil(err)
+	summary, err := r.Summary()
+	require.Nil(err)
+	require.Len(summary, 1)
+	require.Equal(receiver, summary[0].Receiver)
+	_, err = r.Finalize()
+	require.NotNil(err)
+
+	err = r.SignWithObserver(hex.EncodeToString(seed[:31]) + "00")
+	require.NotNil(err)
+	err = r.SignWithHolder(holder)
+	require.Nil(err)
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(r.Raw, holder.Public()))
+	_, err = r.Finalize()
+	require.NotNil(err)
+	err = r.SignWithObserver(hex.EncodeToString(seed))
+	require.Nil(err)
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(r.Raw, odk))
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(r.Raw, holder.Public()))
+	raw, err := r.Finalize()
+	require.Nil(err)
+	tx, err := btcutil.NewTxFromBytes(common.DecodeHexOrPanic(raw))
+	require.Nil(err)
+	msgTx := tx.MsgTx()
+	require.Len(msgTx.TxOut, 2)
+	require.Equal(uint32(wsa.Sequence), msgTx.TxIn[0].Sequence)
+	require.Less(msgTx.TxOut[0].Value, int64(100000))
+	require.Len(msgTx.TxIn[0].Witness, 4)
+	addr, err := btcutil.DecodeAddress(wsa.Address, bitcoin.NetConfig(common.SafeChainBitcoin))
+	require.Nil(err)
+	pkScript, err := txscript.PayToAddrScript(addr)
+	require.Nil(err)
+	pof := txscript.NewCannedPrevOutputFetcher(pkScript, 100000)
+	vm, err := txscript.NewEngine(pkScript, msgTx, 0, txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(msgTx, pof), 100000, pof)
+	require.Nil(err)
+	require.Nil(vm.Execute())
+
+	holder, err = NewHolder(common.SafeChainPolygon, testHolderPrivate)
+	require.Nil(err)
+	id := uuid.Must(uuid.NewV4()).String()
+	gs, _, err := ethereum.BuildGnosisSafe(ctx, "", holder.Public(), signer, observerKey, id, testTimelock, common.SafeChainPolygon)
+	require.Nil(err)
+	account = &Account{
+		Id:              id,
+		Chain:           common.SafeChainPolygon,
+		Address:         gs.Address,
+		Keys:            keys,
+		Timelock:        int64(testTimelock / time.Hour),
+		Holders:         []string{holder.Public()},
+		HolderThreshold: 1,
+	}
+	outputs := []*ethereum.Output{{
+		TokenAddress: ethereum.EthereumEmptyAddress,
+		Destination:  "0xA03A8590BB3A2cA5c747c8b99C63DA399424a055",
+		Amount:       big.NewInt(100000),
+	}}
+	r, err = BuildEthereumRecovery(ctx, account, outputs, 3)
+	require.Nil(err)
+	err = r.SignWithHolder(holder)
+	require.Nil(err)
+	_, err = r.Finalize()
+	require.NotNil(err)
+	err = r.SignWithObserver(hex.EncodeToString(seed))
+	require.Nil(err)
+	require.True(ethereum.CheckTransactionPartiallySignedBy(r.Raw, holder.Public()))
+	require.True(ethereum.CheckTransactionPartiallySignedBy(r.Raw, observerKey))
+	data, err := r.Finalize()
+	require.Nil(err)
+	require.True(strings.HasPrefix(data, "6a761202"))
+}
+
+func TestObserverClient(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	node, db, kd := testBuildObserver(ctx, require)
+	server := httptest.NewServer(node.HTTPHandler("test", "README"))
+	defer server.Close()
+	client := NewClient(server.URL)
+
+	holder, err := NewHolder(common.SafeChainBitcoin, testHolderPrivate)
+	require.Nil(err)
+	signer, signerChainCode := testGenerateKey(require)
+	observerKey, observerChainCode := testGenerateKey(require)
+	testWriteKey(ctx, require, kd, signer, signerChainCode)
+	testWriteKey(ctx, require, kd, observerKey, observerChainCode)
+
+	path := []byte{2, 0, 0, 0}
+	_, sdk, err := bitcoin.DeriveBIP32(signer, signerChainCode, 0, 0)
+	require.Nil(err)
+	_, odk, err := bitcoin.DeriveBIP32(observerKey, observerChainCode, 0, 0)
+	require.Nil(err)
+	wsa, err := bitcoin.BuildWitnessScriptAccount(holder.Public(), sdk, odk, testTimelock, common.SafeChainBitcoin)
+	require.Nil(err)
+
+	_, err = client.ReadAccount(ctx, uuid.Must(uuid.NewV4()).String())
+	require.NotNil(err)
+	require.True(strings.Contains(err.Error(), "404"))
+
+	now := time.Now().UTC()
+	req := testWriteRequest(ctx, require, kd, holder.Public(), common.ActionBitcoinSafeProposeAccount)
+	sp := &store.SafeProposal{
+		RequestId: req.Id,
+		Chain:     common.SafeChainBitcoin,
+		Holder:    holder.Public(),
+		Signer:    signer,
+		Observer:  observerKey,
+		Timelock:  testTimelock,
+		Path:      hex.EncodeToString(path),
+		Address:   wsa.Address,
+		Extra:     wsa.Marshal(),
+		Receivers: []string{testReceiverId},
+		Threshold: 1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = kd.WriteSafeProposalWithRequest(ctx, sp, nil, nil, nil, req)
+	require.Nil(err)
+	err = db.WriteAccountProposalIfNotExists(ctx, wsa.Address, now)
+	require.Nil(err)
+
+	account, err := client.ReadAccount(ctx, sp.RequestId)
+	require.Nil(err)
+	require.Equal(sp.RequestId, account.Id)
+	require.Equal(wsa.Address, account.Address)
+	require.Equal(hex.EncodeToString(wsa.Script), account.Script)
+	require.Equal(int64(24), account.Timelock)
+	require.Equal("proposed", account.State)
+	spk, opk, err := parseAccountKeys(account)
+	require.Nil(err)
+	require.Equal(signer, spk)
+	require.Equal(observerKey, opk)
+	require.True(strings.HasPrefix(account.Descriptor, "wsh(thresh(2,pk("+holder.Public()+"),s:pk("+account.Keys[0]+"/0/0),"))
+	require.True(strings.HasSuffix(account.Descriptor[:len(account.Descriptor)-9], fmt.Sprintf("n:older(%d))))", wsa.Sequence)))
+
+	account, err = holder.ApproveAccount(ctx, client, sp.RequestId)
+	require.Nil(err)
+	require.Equal(wsa.Address, account.Address)
+	a, err := db.ReadAccount(ctx, wsa.Address)
+	require.Nil(err)
+	sig, err := base64.RawURLEncoding.DecodeString(a.Signature.String)
+	require.Nil(err)
+	ms := fmt.Sprintf("APPROVE:%s:%s", sp.RequestId, wsa.Address)
+	msg := bitcoin.HashMessageForSignature(ms, common.SafeChainBitcoin)
+	require.Nil(bitcoin.VerifySignatureDER(holder.Public(), msg, sig))
+
+	req = testWriteRequest(ctx, require, kd, holder.Public(), common.ActionBitcoinSafeApproveAccount)
+	safe := &store.Safe{
+		Holder:      sp.Holder,
+		Chain:       sp.Chain,
+		Signer:      sp.Signer,
+		Observer:    sp.Observer,
+		Timelock:    sp.Timelock,
+		Path:        sp.Path,
+		Address:     sp.Address,
+		Extra:       sp.Extra,
+		Receivers:   sp.Receivers,
+		Threshold:   sp.Threshold,
+		RequestId:   req.Id,
+		State:       common.RequestStateDone,
+		SafeAssetId: uuid.Must(uuid.NewV4()).String(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	err = kd.WriteSafeWithRequest(ctx, safe, nil, req)
+	require.Nil(err)
+	account, err = client.ReadAccount(ctx, sp.RequestId)
+	require.Nil(err)
+	require.Equal(safe.SafeAssetId, account.SafeAssetId)
+	require.Equal("approved", account.State)
+
+	req = testWriteRequest(ctx, require, kd, holder.Public(), common.ActionBitcoinSafeProposeTransaction)
+	input := &bitcoin.Input{
+		TransactionHash: crypto.Sha256Hash([]byte(req.Id)).String(),
+		Index:           0,
+		Satoshi:         100000,
+		Script:          wsa.Script,
+		Sequence:        uint32(bitcoin.ParseSequence(testTimelock, common.SafeChainBitcoin)),
+	}
+	outputs := []*bitcoin.Output{{Address: "bc1qevu9qqpfqp4s9jq3xxulfh08rgyjy8rn76aj7e", Satoshi: 50000}}
+	psbt, err := bitcoin.BuildPartiallySignedTransaction([]*bitcoin.Input{input}, outputs, uuid.FromStringOrNil(req.Id).Bytes(), common.SafeChainBitcoin)
+	require.Nil(err)
+	tx := &store.Transaction{
+		TransactionHash: psbt.Hash(),
+		RawTransaction:  hex.EncodeToString(psbt.Marshal()),
+		Holder:          holder.Public(),
+		Chain:           common.SafeChainBitcoin,
+		AssetId:         common.SafeBitcoinChainId,
+		State:           common.RequestStateInitial,
+		Data:            "",
+		RequestId:       req.Id,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	err = kd.WriteTransactionWithRequest(ctx, tx, nil, nil, req)
+	require.Nil(err)
+	err = db.WriteTransactionApprovalIfNotExists(ctx, &observer.Transaction{
+		TransactionHash: tx.TransactionHash,
+		RawTransaction:  tx.RawTransaction,
+		Chain:           tx.Chain,
+		Holder:          tx.Holder,
+		Signer:          safe.Signer,
+		State:           common.RequestStateInitial,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	})
+	require.Nil(err)
+
+	ot, err := client.ReadTransaction(ctx, req.Id)
+	require.Nil(err)
+	require.Equal(tx.TransactionHash, ot.Hash)
+	require.Equal(sp.RequestId, ot.AccountId)
+	require.Len(ot.Signers, 0)
+	_, err = holder.ApproveTransaction(ctx, client, req.Id)
+	require.Nil(err)
+	ot, err = client.ReadTransaction(ctx, req.Id)
+	require.Nil(err)
+	require.Equal([]string{"holder"}, ot.Signers)
+	require.True(bitcoin.CheckTransactionPartiallySignedBy(ot.Raw, holder.Public()))
+}
+
+func testBuildObserver(ctx context.Context, require *require.Assertions) (*observer.Node, *observer.SQLite3Store, *store.SQLite3Store) {
+	f, _ := os.ReadFile("../config/example.toml")
+	var conf struct {
+		Observer *observer.Configuration `toml:"observer"`
+		Keeper   *keeper.Configuration   `toml:"keeper"`
+	}
+	err := toml.Unmarshal(f, &conf)
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "safe-sdk-test")
+	require.Nil(err)
+	kd, err := keeper.OpenSQLite3Store(root + "/keeper.sqlite3")
+	require.Nil(err)
+	kr, err := keeper.OpenSQLite3ReadOnlyStore(root + "/keeper.sqlite3")
+	require.Nil(err)
+	db, err := observer.OpenSQLite3Store(root + "/observer.sqlite3")
+	require.Nil(err)
+	conf.Observer.StoreDir = root
+	node := observer.NewNode(db, kr, conf.Observer, conf.Keeper.MTG, nil)
+	return node, db, kd
+}
+
+func testGenerateKey(require *require.Assertions) (string, []byte) {
+	seed := make([]byte, 32)
+	_, err := rand.Read(seed)
+	require.Nil(err)
+	_, pub := btcec.PrivKeyFromBytes(seed)
+	chainCode := make([]byte, 32)
+	_, err = rand.Read(chainCode)
+	require.Nil(err)
+	return hex.EncodeToString(pub.SerializeCompressed()), chainCode
+}
+
+func testViewXPub(require *require.Assertions, public string, chainCode []byte) string {
+	xpub, pub, err := bitcoin.DeriveBIP32(public, chainCode)
+	require.Nil(err)
+	require.Equal(public, pub)
+	finger := btcutil.Hash160(common.DecodeHexOrPanic(public))[:4]
+	return fmt.Sprintf("[%x]%s", finger, xpub)
+}
+
+func testWriteKey(ctx context.Context, require *require.Assertions, kd *store.SQLite3Store, public string, chainCode []byte) {
+	req := testWriteRequest(ctx, require, kd, public, common.ActionObserverAddKey)
+	err := kd.WriteKeyFromRequest(ctx, req, common.RequestRoleObserver, chainCode, common.RequestFlagNone)
+	require.Nil(err)
+}
+
+func testWriteRequest(ctx context.Context, require *require.Assertions, kd *store.SQLite3Store, public string, action byte) *common.Request {
+	id := uuid.Must(uuid.NewV4()).String()
+	req := &common.Request{
+		Id:         id,
+		MixinHash:  crypto.Sha256Hash([]byte(id)),
+		MixinIndex: 0,
+		AssetId:    testOperationId,
+		Amount:     decimal.NewFromInt(1),
+		Role:       common.RequestRoleObserver,
+		Action:     action,
+		Curve:      common.CurveSecp256k1ECDSABitcoin,
+		Holder:     public,
+		ExtraHEX:   "",
+		State:      common.RequestStateInitial,
+		CreatedAt:  time.Now().UTC(),
+		Output: &mtg.Action{
+			UnifiedOutput: mtg.UnifiedOutput{OutputId: common.UniqueId(id, "output")},
+		},
+	}
+	err := kd.WriteRequestIfNotExist(ctx, req)
+	require.Nil(err)
+	return req
+}
@@ -1,18 +1,20 @@
 package signer
 
 import (
+	"bytes"
 	"context"
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"math/big"
-	"os"
 	"testing"
 
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/multi-party-sig/pkg/math/curve"
 	"github.com/MixinNetwork/safe/apps/ethereum"
+	gethabi "github.com/MixinNetwork/safe/apps/ethereum/abi"
+	"github.com/MixinNetwork/safe/apps/ethereum/rpctest"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -46,7 +48,6 @@ var (
 		ByzantiumBlock: big.NewInt(0),
 	}
 
-	rpc       = "https://polygon-rpc.com"
 	chainID   = 137
 	threshold = 2
 	timelock  = 1
@@ -185,12 +186,30 @@ func testPrepareEthereumAccount(ctx context.Context, require *require.Assertions
 	}
 	testSafeTransactionMarshal(require, tx)
 
-	safeAddress, err := ethereum.GetOrDeploySafeAccount(ctx, rpc, os.Getenv("MVM_DEPLOYER"), int64(chainID), owners, int64(threshold), int64(timelock), 2, tx)
+	rpc := rpctest.NewServer(ethereum.ChainPolygon)
+	defer rpc.Close()
+	rpc.HandleCall(addrStr, testEthereumGuardedSafe(require))
+	safeAddress, err := ethereum.GetOrDeploySafeAccount(ctx, rpc.URL(), "", int64(chainID), owners, int64(threshold), int64(timelock), 2, tx)
 	require.Nil(err)
 	require.Equal("0x4f5974a056029EFA7e4B7b51a7Bbcb8FEc6E8970", safeAddress.Hex())
 	return safeAddress.Hex()
 }
 
+// testEthereumGuardedSafe answers the getStorageAt of a deployed safe account
+// with the guard enabled, so no deployment transaction is needed
+func testEthereumGuardedSafe(require *require.Assertions) rpctest.CallHandler {
+	safe, err := gethabi.GnosisSafeMetaData.GetAbi()
+	require.Nil(err)
+	method := safe.Methods["getStorageAt"]
+	return func(data []byte) ([]byte, error) {
+		if !bytes.Equal(data[:4], method.ID) {
+			return nil, fmt.Errorf("unknown method %x", data[:4])
+		}
+		guard := common.HexToAddress(ethereum.EthereumSafeGuardAddress)
+		return method.Outputs.Pack(common.LeftPadBytes(guard.Bytes(), 32))
+	}
+}
+
 func testSafeTransactionMarshal(require *require.Assertions, tx *ethereum.SafeTransaction) {
 	extra := tx.Marshal()
 	txDuplicate, err := ethereum.UnmarshalSafeTransaction(extra)
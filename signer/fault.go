@@ -0,0 +1,338 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/MixinNetwork/safe/signer/protocol"
+	"github.com/gofrs/uuid/v5"
+)
+
+const (
+	FaultReasonCulprit = 1
+	FaultReasonTimeout = 2
+
+	// faults reported in this window before a session is prepared
+	// are used to deprioritise the signers of the session
+	SessionFaultWindow = 24 * time.Hour
+	// a signer with this many faulty sessions in the window is
+	// excluded from a session whenever enough healthy signers prepared,
+	// and a session only counts as faulty for the signer when at least
+	// threshold distinct members reported it, so that a single reporter
+	// can't demote an honest signer
+	SessionFaultLimit = 3
+	// the session waits this long for enough healthy signers to prepare,
+	// then the session is prepared with whoever is available
+	SessionPrepareGrace = 3 * time.Minute
+
+	sessionFaultsPrefix = "FAULTS"
+	sessionFaultsLimit  = 32
+)
+
+type SessionFault struct {
+	SessionId  string
+	SignerId   string
+	ReporterId string
+	Reason     int
+	Round      int
+	CreatedAt  time.Time
+	ReportedAt sql.NullTime
+}
+
+type FaultStats struct {
+	SignerId string
+	Culprits int
+	Timeouts int
+}
+
+// faults observed by this node are written locally first, and only count
+// after the report has been accepted by the signer group, thus all nodes
+// make the same member selection from the same MTG action stream
+func (node *Node) recordSessionFaults(ctx context.Context, mps *MultiPartySession, err error) {
+	var reason int
+	var culprits []party.ID
+	var perr protocol.Error
+	if errors.As(err, &perr) && len(perr.Culprits) > 0 {
+		reason, culprits = FaultReasonCulprit, perr.Culprits
+	} else {
+		reason, culprits = FaultReasonTimeout, mps.missing(node.id)
+	}
+
+	sid := uuid.Must(uuid.FromBytes(mps.id)).String()
+	var faults []*SessionFault
+	for _, id := range culprits {
+		if id == node.id || node.findMember(string(id)) < 0 {
+			continue
+		}
+		faults = append(faults, &SessionFault{
+			SessionId:  sid,
+			SignerId:   string(id),
+			ReporterId: string(node.id),
+			Reason:     reason,
+			Round:      int(mps.round),
+			CreatedAt:  time.Now().UTC(),
+		})
+	}
+	if len(faults) > sessionFaultsLimit {
+		faults = faults[:sessionFaultsLimit]
+	}
+	err = node.store.WriteSessionFaultsIfNotExist(ctx, faults)
+	logger.Printf("store.WriteSessionFaultsIfNotExist(%s, %d) => %v", sid, len(faults), err)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (node *Node) encodeSessionFaults(ctx context.Context, sessionId string) []byte {
+	faults, err := node.store.ListSessionFaultsByReporter(ctx, sessionId, string(node.id))
+	if err != nil {
+		panic(err)
+	}
+	if len(faults) == 0 {
+		return nil
+	}
+	extra := []byte(sessionFaultsPrefix)
+	for _, f := range faults {
+		index := node.findMember(f.SignerId)
+		if index < 0 {
+			panic(f.SignerId)
+		}
+		extra = append(extra, byte(index), byte(f.Reason), byte(f.Round))
+	}
+	return extra
+}
+
+func (node *Node) decodeSessionFaults(sessionId, reporter string, extra []byte, reportedAt time.Time) ([]*SessionFault, bool) {
+	if !bytes.HasPrefix(extra, []byte(sessionFaultsPrefix)) {
+		return nil, false
+	}
+	extra = extra[len(sessionFaultsPrefix):]
+	if len(extra)%3 != 0 || len(extra)/3 > sessionFaultsLimit {
+		return nil, false
+	}
+	members := node.GetMembers()
+	var faults []*SessionFault
+	for i := 0; i < len(extra); i += 3 {
+		index, reason, rn := int(extra[i]), int(extra[i+1]), extra[i+2]
+		if index >= len(members) || members[index] == reporter {
+			return nil, false
+		}
+		switch reason {
+		case FaultReasonCulprit, FaultReasonTimeout:
+		default:
+			return nil, false
+		}
+		faults = append(faults, &SessionFault{
+			SessionId:  sessionId,
+			SignerId:   members[index],
+			ReporterId: reporter,
+			Reason:     reason,
+			Round:      int(rn),
+			CreatedAt:  reportedAt,
+			ReportedAt: sql.NullTime{Valid: true, Time: reportedAt},
+		})
+	}
+	return faults, true
+}
+
+func (node *Node) countHealthySigners(ctx context.Context, signers []string, at time.Time) int {
+	faults, err := node.store.CountRecentFaults(ctx, at, node.threshold)
+	if err != nil {
+		panic(err)
+	}
+	var healthy int
+	for _, id := range signers {
+		if faults[id] < SessionFaultLimit {
+			healthy = healthy + 1
+		}
+	}
+	return healthy
+}
+
+// the grace prepare is only retried until the session timeout, after that
+// the session is dropped by the other signers anyway
+func (node *Node) retryUnpreparedSessions(ctx context.Context) {
+	before := time.Now().Add(-SessionPrepareGrace)
+	after := before.Add(-SessionTimeout)
+	sessions, err := node.store.ListUnpreparedSessions(ctx, after, before, 64)
+	if err != nil {
+		panic(err)
+	}
+	for _, s := range sessions {
+		op := s.asOperation()
+		op.Extra = []byte(PrepareExtra)
+		extra := common.AESEncrypt(node.aesKey[:], op.Encode(), op.Id)
+		traceId := fmt.Sprintf("SESSION:%s:SIGNER:%s:PREPARE:GRACE", op.Id, string(node.id))
+		err := node.sendTransactionToSignerGroupUntilSufficient(ctx, extra, traceId)
+		logger.Printf("node.sendTransactionToSignerGroupUntilSufficient(%v, GRACE) => %v", op, err)
+		if err != nil {
+			break
+		}
+	}
+}
+
+func (s *SQLite3Store) WriteSessionFaultsIfNotExist(ctx context.Context, faults []*SessionFault) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer common.Rollback(tx)
+
+	for _, f := range faults {
+		query := "SELECT reported_at FROM session_faults WHERE session_id=? AND reporter_id=? AND signer_id=?"
+		row := tx.QueryRowContext(ctx, query, f.SessionId, f.ReporterId, f.SignerId)
+		var reportedAt sql.NullTime
+		err = row.Scan(&reportedAt)
+		if err == nil {
+			if reportedAt.Valid || !f.ReportedAt.Valid {
+				continue
+			}
+			query = "UPDATE session_faults SET reason=?, round=?, reported_at=? WHERE session_id=? AND reporter_id=? AND signer_id=? AND reported_at IS NULL"
+			err = s.execOne(ctx, tx, query, f.Reason, f.Round, f.ReportedAt, f.SessionId, f.ReporterId, f.SignerId)
+			if err != nil {
+				return fmt.Errorf("SQLite3Store UPDATE session_faults %v", err)
+			}
+			continue
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+
+		cols := []string{"session_id", "signer_id", "reporter_id", "reason", "round", "created_at", "reported_at"}
+		err = s.execOne(ctx, tx, buildInsertionSQL("session_faults", cols),
+			f.SessionId, f.SignerId, f.ReporterId, f.Reason, f.Round, f.CreatedAt, f.ReportedAt)
+		if err != nil {
+			return fmt.Errorf("SQLite3Store INSERT session_faults %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLite3Store) ListSessionFaultsByReporter(ctx context.Context, sessionId, reporter string) ([]*SessionFault, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query := "SELECT session_id, signer_id, reporter_id, reason, round, created_at, reported_at FROM session_faults WHERE session_id=? AND reporter_id=? ORDER BY signer_id ASC"
+	rows, err := s.db.QueryContext(ctx, query, sessionId, reporter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var faults []*SessionFault
+	for rows.Next() {
+		var f SessionFault
+		err := rows.Scan(&f.SessionId, &f.SignerId, &f.ReporterId, &f.Reason, &f.Round, &f.CreatedAt, &f.ReportedAt)
+		if err != nil {
+			return nil, err
+		}
+		faults = append(faults, &f)
+	}
+	return faults, nil
+}
+
+// CountRecentFaults returns the number of faulty sessions of each signer
+// accepted by the group in the fault window before the given time, a session
+// is only counted when at least reporters distinct members reported it
+func (s *SQLite3Store) CountRecentFaults(ctx context.Context, at time.Time, reporters int) (map[string]int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.countFaultsByQuery(ctx, at.Add(-SessionFaultWindow), at, reporters)
+}
+
+func (s *SQLite3Store) countFaultsByQuery(ctx context.Context, begin, end time.Time, reporters int) (map[string]int, error) {
+	query := "SELECT signer_id, COUNT(*) FROM (SELECT session_id, signer_id FROM session_faults WHERE reported_at IS NOT NULL AND reported_at>? AND reported_at<? GROUP BY session_id, signer_id HAVING COUNT(DISTINCT reporter_id)>=?) GROUP BY signer_id"
+	rows, err := s.db.QueryContext(ctx, query, begin, end, reporters)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	faults := make(map[string]int)
+	for rows.Next() {
+		var signer string
+		var count int
+		err := rows.Scan(&signer, &count)
+		if err != nil {
+			return nil, err
+		}
+		faults[signer] = count
+	}
+	return faults, nil
+}
+
+func (s *SQLite3Store) CountDailyFaults(ctx context.Context, members []party.ID, begin, end time.Time, reporters int) ([]*FaultStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer common.Rollback(tx)
+
+	stats := make([]*FaultStats, len(members))
+	for i, id := range members {
+		fs := &FaultStats{SignerId: string(id)}
+		query := "SELECT COUNT(*) FROM (SELECT session_id FROM session_faults WHERE signer_id=? AND reason=? AND reported_at>? AND reported_at<? GROUP BY session_id HAVING COUNT(DISTINCT reporter_id)>=?)"
+		row := tx.QueryRowContext(ctx, query, id, FaultReasonCulprit, begin, end, reporters)
+		err = row.Scan(&fs.Culprits)
+		if err != nil {
+			return nil, err
+		}
+		row = tx.QueryRowContext(ctx, query, id, FaultReasonTimeout, begin, end, reporters)
+		err = row.Scan(&fs.Timeouts)
+		if err != nil {
+			return nil, err
+		}
+		stats[i] = fs
+	}
+	return stats, nil
+}
+
+func (s *SQLite3Store) ListUnpreparedSessions(ctx context.Context, after, before time.Time, limit int) ([]*Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cols := "session_id, mixin_hash, mixin_index, operation, curve, public, extra, state, created_at"
+	query := fmt.Sprintf("SELECT %s FROM sessions WHERE state=? AND committed_at IS NOT NULL AND prepared_at IS NULL AND committed_at>? AND committed_at<? ORDER BY created_at ASC, session_id ASC LIMIT %d", cols, limit)
+	rows, err := s.db.QueryContext(ctx, query, common.RequestStateInitial, after, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		var r Session
+		err := rows.Scan(&r.Id, &r.MixinHash, &r.MixinIndex, &r.Operation, &r.Curve, &r.Public, &r.Extra, &r.State, &r.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &r)
+	}
+	return sessions, nil
+}
+
+// sortSignersByFaults keeps the prepared order, except that signers with
+// more recent faults are moved behind the healthier ones
+func sortSignersByFaults(signers []party.ID, faults map[string]int) []party.ID {
+	sorted := slices.Clone(signers)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return faults[string(sorted[i])] < faults[string(sorted[j])]
+	})
+	return sorted
+}
@@ -0,0 +1,216 @@
+package signer
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/multi-party-sig/pkg/party"
+	"github.com/MixinNetwork/safe/common"
+	"github.com/gofrs/uuid/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionFaults(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	saverStore, port := testStartSaver(require)
+	root, err := os.MkdirTemp("", "safe-signer-fault-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root, 0, saverStore, port)
+	members := node.GetMembers()
+
+	now := time.Now().UTC()
+	faulty := uuid.Must(uuid.NewV4()).String()
+	err = node.store.WriteSessionFaultsIfNotExist(ctx, []*SessionFault{{
+		SessionId:  faulty,
+		SignerId:   members[1],
+		ReporterId: string(node.id),
+		Reason:     FaultReasonTimeout,
+		Round:      3,
+		CreatedAt:  now,
+	}})
+	require.Nil(err)
+	extra := node.encodeSessionFaults(ctx, faulty)
+	require.Equal([]byte{'F', 'A', 'U', 'L', 'T', 'S', 1, FaultReasonTimeout, 3}, extra)
+	faults, err := node.store.CountRecentFaults(ctx, now.Add(time.Minute), node.threshold)
+	require.Nil(err)
+	require.Len(faults, 0)
+
+	reporter := members[2]
+	faultsReported, reported := node.decodeSessionFaults(faulty, reporter, extra, now)
+	require.True(reported)
+	require.Len(faultsReported, 1)
+	require.Equal(members[1], faultsReported[0].SignerId)
+	_, reported = node.decodeSessionFaults(faulty, members[1], extra, now)
+	require.False(reported)
+	_, reported = node.decodeSessionFaults(faulty, reporter, []byte("mixin"), now)
+	require.False(reported)
+	err = node.store.WriteSessionFaultsIfNotExist(ctx, faultsReported)
+	require.Nil(err)
+	faultsReported, _ = node.decodeSessionFaults(faulty, string(node.id), extra, now)
+	err = node.store.WriteSessionFaultsIfNotExist(ctx, faultsReported)
+	require.Nil(err)
+	faults, err = node.store.CountRecentFaults(ctx, now.Add(time.Minute), node.threshold)
+	require.Nil(err)
+	require.Equal(1, faults[members[1]])
+	require.Equal(1, len(faults))
+
+	op := &common.Operation{
+		Id:     uuid.Must(uuid.NewV4()).String(),
+		Type:   common.OperationTypeSignInput,
+		Curve:  common.CurveSecp256k1ECDSABitcoin,
+		Public: "2fcab7304cc1a392037bdcff",
+		Extra:  []byte("mixin"),
+	}
+	createdAt := now.Add(time.Minute)
+	err = node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(op.Id)), 0, createdAt, true)
+	require.Nil(err)
+	for i, id := range members {
+		err = node.store.PrepareSessionSignerIfNotExist(ctx, op.Id, id, createdAt.Add(time.Duration(i)*time.Second))
+		require.Nil(err)
+	}
+	err = node.store.MarkSessionPrepared(ctx, op.Id, createdAt.Add(time.Minute))
+	require.Nil(err)
+
+	signers, err := node.store.ListSessionPreparedMembers(ctx, op.Id, node.threshold+1)
+	require.Nil(err)
+	require.Equal([]party.ID{party.ID(members[0]), party.ID(members[2]), party.ID(members[3])}, signers)
+	require.Equal(len(members), node.countHealthySigners(ctx, members, createdAt))
+
+	for i := 0; i < SessionFaultLimit; i++ {
+		faulty := uuid.Must(uuid.NewV4()).String()
+		for _, reporter := range []string{members[2], members[0]} {
+			err = node.store.WriteSessionFaultsIfNotExist(ctx, []*SessionFault{{
+				SessionId:  faulty,
+				SignerId:   members[3],
+				ReporterId: reporter,
+				Reason:     FaultReasonCulprit,
+				Round:      2,
+				CreatedAt:  now,
+				ReportedAt: sql.NullTime{Valid: true, Time: now},
+			}})
+			require.Nil(err)
+		}
+	}
+	faults, err = node.store.CountRecentFaults(ctx, now.Add(time.Minute), node.threshold)
+	require.Nil(err)
+	require.Equal(1, faults[members[1]])
+	require.Equal(SessionFaultLimit, faults[members[3]])
+	require.Equal(len(members)-1, node.countHealthySigners(ctx, members, createdAt))
+
+	all := []party.ID{party.ID(members[0]), party.ID(members[1]), party.ID(members[2]), party.ID(members[3])}
+	sorted := sortSignersByFaults(all, faults)
+	require.Equal([]party.ID{party.ID(members[0]), party.ID(members[2]), party.ID(members[1]), party.ID(members[3])}, sorted)
+	sorted = sortSignersByFaults([]party.ID{all[3], all[1], all[2], all[0]}, faults)
+	require.Equal([]party.ID{party.ID(members[2]), party.ID(members[0]), party.ID(members[1]), party.ID(members[3])}, sorted)
+
+	op = &common.Operation{
+		Id:     uuid.Must(uuid.NewV4()).String(),
+		Type:   common.OperationTypeSignInput,
+		Curve:  common.CurveSecp256k1ECDSABitcoin,
+		Public: "2fcab7304cc1a392037bdcff",
+		Extra:  []byte("safe"),
+	}
+	err = node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(op.Id)), 0, createdAt, true)
+	require.Nil(err)
+	for i, id := range []string{members[3], members[1], members[0], members[2]} {
+		err = node.store.PrepareSessionSignerIfNotExist(ctx, op.Id, id, createdAt.Add(time.Duration(i)*time.Second))
+		require.Nil(err)
+	}
+	err = node.store.MarkSessionCommitted(ctx, op.Id)
+	require.Nil(err)
+	sessions, err := node.store.ListUnpreparedSessions(ctx, time.Now().Add(-SessionTimeout), time.Now().Add(time.Second), 64)
+	require.Nil(err)
+	require.Len(sessions, 1)
+	require.Equal(op.Id, sessions[0].Id)
+	sessions, err = node.store.ListUnpreparedSessions(ctx, time.Now().Add(-SessionTimeout), time.Now().Add(-SessionPrepareGrace), 64)
+	require.Nil(err)
+	require.Len(sessions, 0)
+	sessions, err = node.store.ListUnpreparedSessions(ctx, time.Now().Add(time.Second), time.Now().Add(SessionTimeout), 64)
+	require.Nil(err)
+	require.Len(sessions, 0)
+	err = node.store.MarkSessionPrepared(ctx, op.Id, createdAt.Add(time.Minute))
+	require.Nil(err)
+
+	signers, err = node.store.ListSessionPreparedMembers(ctx, op.Id, node.threshold+1)
+	require.Nil(err)
+	require.Equal([]party.ID{party.ID(members[0]), party.ID(members[2]), party.ID(members[1])}, signers)
+
+	for i := 0; i < 4*FaultWorkPenalty; i++ {
+		sessionId := uuid.Must(uuid.NewV4()).String()
+		if i < 2*FaultWorkPenalty {
+			err = node.store.WriteSessionWorkIfNotExist(ctx, sessionId, members[1], 1, []byte("work"))
+			require.Nil(err)
+			err = node.store.WriteSessionWorkIfNotExist(ctx, sessionId, members[2], 1, []byte("work"))
+			require.Nil(err)
+		}
+		err = node.store.WriteSessionWorkIfNotExist(ctx, sessionId, members[3], 1, []byte("work"))
+		require.Nil(err)
+	}
+	// 0, 32 - 16, 32 and 64 - 3 * 16 works after the fault penalties
+	works := node.DailyWorks(ctx, now.Add(24*time.Hour))
+	require.Equal([]byte{0, 127, 255, 127}, works)
+}
+
+func TestSessionFaultsSingleReporter(t *testing.T) {
+	require := require.New(t)
+	ctx := common.EnableTestEnvironment(context.Background())
+
+	saverStore, port := testStartSaver(require)
+	root, err := os.MkdirTemp("", "safe-signer-fault-test")
+	require.Nil(err)
+	node := testBuildNode(ctx, require, root, 0, saverStore, port)
+	members := node.GetMembers()
+
+	now := time.Now().UTC()
+	for i := 0; i < SessionFaultLimit*2; i++ {
+		err = node.store.WriteSessionFaultsIfNotExist(ctx, []*SessionFault{{
+			SessionId:  uuid.Must(uuid.NewV4()).String(),
+			SignerId:   members[1],
+			ReporterId: members[3],
+			Reason:     FaultReasonCulprit,
+			Round:      2,
+			CreatedAt:  now,
+			ReportedAt: sql.NullTime{Valid: true, Time: now},
+		}})
+		require.Nil(err)
+	}
+	faults, err := node.store.CountRecentFaults(ctx, now.Add(time.Minute), node.threshold)
+	require.Nil(err)
+	require.Len(faults, 0)
+	faults, err = node.store.CountRecentFaults(ctx, now.Add(time.Minute), 1)
+	require.Nil(err)
+	require.Equal(SessionFaultLimit*2, faults[members[1]])
+	require.Equal(len(members), node.countHealthySigners(ctx, members, now.Add(time.Minute)))
+
+	op := &common.Operation{
+		Id:     uuid.Must(uuid.NewV4()).String(),
+		Type:   common.OperationTypeSignInput,
+		Curve:  common.CurveSecp256k1ECDSABitcoin,
+		Public: "2fcab7304cc1a392037bdcff",
+		Extra:  []byte("mixin"),
+	}
+	createdAt := now.Add(time.Minute)
+	err = node.store.WriteSessionIfNotExist(ctx, op, crypto.Sha256Hash([]byte(op.Id)), 0, createdAt, true)
+	require.Nil(err)
+	for i, id := range members {
+		err = node.store.PrepareSessionSignerIfNotExist(ctx, op.Id, id, createdAt.Add(time.Duration(i)*time.Second))
+		require.Nil(err)
+	}
+	err = node.store.MarkSessionPrepared(ctx, op.Id, createdAt.Add(time.Minute))
+	require.Nil(err)
+	signers, err := node.store.ListSessionPreparedMembers(ctx, op.Id, node.threshold+1)
+	require.Nil(err)
+	require.Equal([]party.ID{party.ID(members[0]), party.ID(members[1]), party.ID(members[2])}, signers)
+
+	stats, err := node.store.CountDailyFaults(ctx, node.GetPartySlice(), now.Add(-time.Minute), now.Add(time.Minute), node.threshold)
+	require.Nil(err)
+	for _, fs := range stats {
+		require.Equal(0, fs.Culprits+fs.Timeouts)
+	}
+}
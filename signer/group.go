@@ -3,10 +3,12 @@ package signer
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"database/sql"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"maps"
 	"slices"
 	"time"
 
@@ -187,6 +189,12 @@ func (node *Node) processSignerPrepare(ctx context.Context, op *common.Operation
 	if len(signers) <= node.threshold {
 		return nil
 	}
+	healthy := node.countHealthySigners(ctx, slices.Collect(maps.Keys(signers)), out.SequencerCreatedAt)
+	waiting := out.SequencerCreatedAt.Before(s.CreatedAt.Add(SessionPrepareGrace))
+	if healthy <= node.threshold && len(signers) < len(node.GetMembers()) && waiting {
+		logger.Printf("node.processSignerPrepare(%v) => %d/%d healthy", op, healthy, len(signers))
+		return nil
+	}
 	err = node.store.MarkSessionPrepared(ctx, op.Id, out.SequencerCreatedAt)
 	logger.Printf("node.MarkSessionPrepared(%v) => %v", op, err)
 	return err
@@ -209,6 +217,15 @@ func (node *Node) processSignerResult(ctx context.Context, op *common.Operation,
 			panic(fmt.Errorf("store.WriteSessionSignerIfNotExist(%v) => %v", op, err))
 		}
 	case common.OperationTypeSignInput:
+		faults, reported := node.decodeSessionFaults(op.Id, out.Senders[0], op.Extra, out.SequencerCreatedAt)
+		if reported {
+			err = node.store.WriteSessionFaultsIfNotExist(ctx, faults)
+			logger.Printf("store.WriteSessionFaultsIfNotExist(%v, %d) => %v", op, len(faults), err)
+			if err != nil {
+				panic(err)
+			}
+			op.Extra = nil
+		}
 		err = node.store.UpdateSessionSigner(ctx, op.Id, out.Senders[0], op.Extra, out.SequencerCreatedAt, self)
 		if err != nil {
 			panic(fmt.Errorf("store.UpdateSessionSigner(%v) => %v", op, err))
@@ -436,8 +453,14 @@ func (node *Node) verifySessionSignature(ctx context.Context, crv byte, holder s
 		res := mpub.Verify(hash, msig)
 		logger.Printf("mixin.Verify(%v, %x) => %t", hash, msig[:], res)
 		return res, sig
-	case common.CurveEdwards25519Default,
-		common.CurveSecp256k1SchnorrBitcoin:
+	case common.CurveEdwards25519Default:
+		if len(public) != ed25519.PublicKeySize || len(sig) != ed25519.SignatureSize {
+			return false, nil
+		}
+		res := ed25519.Verify(public, msg, sig)
+		logger.Printf("ed25519.Verify(%x, %x, %x) => %t", public, msg, sig, res)
+		return res, sig
+	case common.CurveSecp256k1SchnorrBitcoin:
 		return common.CheckTestEnvironment(ctx), sig // TODO
 	default:
 		panic(crv)
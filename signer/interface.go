@@ -40,3 +40,10 @@ type Network interface {
 	ReceiveMessage(context.Context) (*messenger.MixinMessage, error)
 	QueueMessage(ctx context.Context, receiver string, b []byte) error
 }
+
+// TestNetwork delivers the signer results to the MTG directly in the test
+// environment, the bytes are the JSON encoded mtg.Action
+type TestNetwork interface {
+	Network
+	QueueMTGOutput(ctx context.Context, b []byte) error
+}
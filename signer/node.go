@@ -150,6 +150,7 @@ func (node *Node) loopInitialSessions(ctx context.Context) {
 				break
 			}
 		}
+		node.retryUnpreparedSessions(ctx)
 	}
 }
 
@@ -237,7 +238,7 @@ func (node *Node) loopPendingSessions(ctx context.Context) {
 				if signed {
 					op.Extra = sig
 				} else {
-					op.Extra = nil
+					op.Extra = node.encodeSessionFaults(ctx, op.Id)
 				}
 			default:
 				panic(op.Id)
@@ -310,7 +311,7 @@ func (node *Node) acceptIncomingMessages(ctx context.Context) {
 		if err != nil {
 			panic(err)
 		}
-		if r == nil {
+		if r == nil || !r.PreparedAt.Valid {
 			continue
 		}
 		threshold := node.threshold + 1
@@ -364,6 +365,9 @@ func (node *Node) handlerLoop(ctx context.Context, start round.Session, sessionI
 	res, err := node.loopMultiPartySession(ctx, mps, h, roundTimeout)
 	missing := mps.missing(node.id)
 	logger.Printf("node.loopMultiPartySession(%x, %d) => %v with %v missing", mps.id, mps.round, err, missing)
+	if err != nil {
+		node.recordSessionFaults(ctx, mps, err)
+	}
 	return res, err
 }
 
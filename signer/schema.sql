@@ -69,3 +69,18 @@ CREATE TABLE IF NOT EXISTS action_results (
 );
 
 CREATE INDEX IF NOT EXISTS action_results_by_session ON action_results(session_id);
+
+
+CREATE TABLE IF NOT EXISTS session_faults (
+	session_id   VARCHAR NOT NULL,
+	signer_id    VARCHAR NOT NULL,
+	reporter_id  VARCHAR NOT NULL,
+	reason       INTEGER NOT NULL,
+	round        INTEGER NOT NULL,
+	created_at   TIMESTAMP NOT NULL,
+	reported_at  TIMESTAMP,
+	PRIMARY KEY ('session_id', 'reporter_id', 'signer_id')
+);
+
+CREATE INDEX IF NOT EXISTS session_faults_by_signer_reported ON session_faults(signer_id, reported_at);
+CREATE INDEX IF NOT EXISTS session_faults_by_reported ON session_faults(reported_at);
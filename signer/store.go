@@ -352,7 +352,21 @@ func (s *SQLite3Store) ListSessionPreparedMembers(ctx context.Context, sessionId
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	query := fmt.Sprintf("SELECT signer_id FROM session_signers WHERE session_id=? ORDER BY created_at ASC LIMIT %d", threshold)
+	var createdAt time.Time
+	var preparedAt sql.NullTime
+	query := "SELECT created_at, prepared_at FROM sessions WHERE session_id=?"
+	row := s.db.QueryRowContext(ctx, query, sessionId)
+	err := row.Scan(&createdAt, &preparedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if preparedAt.Valid {
+		createdAt = preparedAt.Time
+	}
+
+	query = "SELECT signer_id FROM session_signers WHERE session_id=? ORDER BY created_at ASC, signer_id ASC"
 	rows, err := s.db.QueryContext(ctx, query, sessionId)
 	if err != nil {
 		return nil, err
@@ -368,6 +382,17 @@ func (s *SQLite3Store) ListSessionPreparedMembers(ctx context.Context, sessionId
 		}
 		signers = append(signers, party.ID(signer))
 	}
+
+	// the session is signed by threshold members, so a fault is counted
+	// only when all the other threshold-1 members of a session reported it
+	faults, err := s.countFaultsByQuery(ctx, createdAt.Add(-SessionFaultWindow), createdAt, threshold-1)
+	if err != nil {
+		return nil, err
+	}
+	signers = sortSignersByFaults(signers, faults)
+	if len(signers) > threshold {
+		signers = signers[:threshold]
+	}
 	return signers, nil
 }
 
@@ -60,6 +60,28 @@ func TestPrepare(require *require.Assertions) (context.Context, []*Node, *saver.
 	return ctx, nodes, saverStore
 }
 
+// TestBoot starts the signer loops in the test environment, the network must
+// be a TestNetwork and the store migrations are not needed for new stores
+func (node *Node) TestBoot(ctx context.Context) {
+	go node.testLoop(ctx, node.loopBackup)
+	go node.testLoop(ctx, node.loopInitialSessions)
+	go node.testLoop(ctx, node.loopPreparedSessions)
+	go node.testLoop(ctx, node.loopPendingSessions)
+	go node.testLoop(ctx, node.acceptIncomingMessages)
+	logger.Printf("node.TestBoot(%s, %d)", node.id, node.Index())
+}
+
+// testLoop runs the loop until the test environment context is done, then
+// the loop panics with the context error, which is expected and recovered
+func (node *Node) testLoop(ctx context.Context, loop func(context.Context)) {
+	defer func() {
+		if r := recover(); r != nil && ctx.Err() == nil {
+			panic(r)
+		}
+	}()
+	loop(ctx)
+}
+
 func TestFROSTPrepareKeys(ctx context.Context, require *require.Assertions, nodes []*Node, curve uint8) string {
 	const public = "fb17b60698d36d45bc624c8e210b4c845233c99a7ae312a27e883a8aa8444b9b"
 	sid := common.UniqueId("prepare", public)
@@ -328,7 +350,7 @@ func (node *Node) mtgQueueTestOutput(ctx context.Context, memo []byte) error {
 	out.Extra = mtg.EncodeMixinExtraBase64(node.conf.AppId, memo)
 	out.Extra = hex.EncodeToString([]byte(out.Extra))
 	data := common.MarshalJSONOrPanic(out)
-	network := node.network.(*testNetwork)
+	network := node.network.(TestNetwork)
 	return network.QueueMTGOutput(ctx, data)
 }
 
@@ -9,6 +9,10 @@ import (
 	"github.com/MixinNetwork/safe/common"
 )
 
+// each faulty session costs the signer this many works, so that
+// the custodian rewards the reliable nodes more
+const FaultWorkPenalty = 16
+
 // TODO put all works query to the custodian module
 func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
 	day := time.Hour * 24
@@ -26,6 +30,15 @@ func (node *Node) DailyWorks(ctx context.Context, now time.Time) []byte {
 		}
 	}
 
+	faults, err := node.store.CountDailyFaults(ctx, members, begin, end, node.threshold)
+	if err != nil {
+		panic(err)
+	}
+	for i, f := range faults {
+		penalty := (f.Culprits + f.Timeouts) * FaultWorkPenalty
+		works[i] = max(works[i]-penalty, 0)
+	}
+
 	return normalizeWorks(works)
 }
 
@@ -55,10 +68,13 @@ func (s *SQLite3Store) CountDailyWorks(ctx context.Context, members []party.ID,
 }
 
 func normalizeWorks(works []int) []byte {
-	max := slices.Max(works)
+	top := slices.Max(works)
 	norms := make([]byte, len(works))
+	if top == 0 {
+		return norms
+	}
 	for i, w := range works {
-		norms[i] = byte(255 * w / max)
+		norms[i] = byte(255 * w / top)
 	}
 	return norms
 }